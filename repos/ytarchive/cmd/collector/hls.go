@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/hls"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// hlsBuildTimeout bounds packaging one rendition of a video
+const hlsBuildTimeout = 6 * time.Hour
+
+// setupHLS packages videos for adaptive streaming: renditions are built on
+// first play, and those named by a channel's policy as downloads complete
+func (c *Collector) setupHLS(ctx context.Context) error {
+	// ffmpeg reads the videos and the renditions are cached next to them
+	local, ok := c.storage.(*storage.LocalBackend)
+	if !ok {
+		return fmt.Errorf("hls packaging needs local storage, not %s", c.storage.Location(""))
+	}
+	if !downloader.MergerAvailable() {
+		return fmt.Errorf("hls packaging needs ffmpeg")
+	}
+
+	c.hls = hls.NewPackager(local.Root(), downloader.NewMerger(downloader.WithMergeTimeout(hlsBuildTimeout)))
+	c.hlsPrebuilder = hls.NewPrebuilder(c.hls, c.channelHLSPolicy)
+	c.onDownloadCompleted(c.hlsPrebuilder.VideoCompleted)
+	go c.hlsPrebuilder.Run(ctx)
+	return nil
+}
+
+// channelHLSPolicy reads the HLS policy from a channel's record
+func (c *Collector) channelHLSPolicy(ctx context.Context, channelID string) (hls.Policy, error) {
+	data, err := c.records.Channel(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return hls.Policy{}, nil
+	}
+	if err != nil {
+		return hls.Policy{}, err
+	}
+
+	var channel struct {
+		HLS *hls.Policy `json:"hls"`
+	}
+	if err := json.Unmarshal(data, &channel); err != nil {
+		return hls.Policy{}, fmt.Errorf("failed to parse channel: %w", err)
+	}
+	if channel.HLS == nil {
+		return hls.Policy{}, nil
+	}
+	return *channel.HLS, nil
+}
+
+// hlsHandler serves HLS playlists and segments, and queues the renditions
+// of a channel's policy for its archived videos
+// URL format:
+//
+//	GET  /hls/{channel_id}/{video_id}/master.m3u8
+//	GET  /hls/{channel_id}/{video_id}/{rendition}/index.m3u8
+//	GET  /hls/{channel_id}/{video_id}/{rendition}/segment_00000.ts
+//	POST /hls/{channel_id}
+func (c *Collector) hlsHandler(w http.ResponseWriter, r *http.Request) {
+	if c.hls == nil {
+		http.Error(w, "HLS packaging is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/hls/"), "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1:
+		c.queueChannelHLS(w, parts[0])
+	case r.Method != http.MethodGet && r.Method != http.MethodHead:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case len(parts) == 3 && parts[2] == "master.m3u8":
+		c.serveHLSMaster(w, r, parts[0], parts[1])
+	case len(parts) == 4 && parts[3] == "index.m3u8":
+		c.serveHLSPlaylist(w, r, parts[0], parts[1], parts[2])
+	case len(parts) == 4:
+		c.serveHLSSegment(w, r, parts[0], parts[1], parts[2], parts[3])
+	default:
+		http.Error(w, "Invalid path. Expected /hls/{channel_id}/{video_id}/master.m3u8", http.StatusBadRequest)
+	}
+}
+
+// serveHLSMaster serves the master playlist of a video
+func (c *Collector) serveHLSMaster(w http.ResponseWriter, r *http.Request, channelID, videoID string) {
+	data, err := c.hls.Master(channelID, videoID)
+	if err != nil {
+		c.writeHLSError(w, channelID, videoID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// serveHLSPlaylist serves the playlist of a rendition, building the rendition first if needed
+func (c *Collector) serveHLSPlaylist(w http.ResponseWriter, r *http.Request, channelID, videoID, rendition string) {
+	path, err := c.hls.Playlist(r.Context(), channelID, videoID, rendition)
+	if err != nil {
+		c.writeHLSError(w, channelID, videoID, err)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.writeHLSError(w, channelID, videoID, err)
+		return
+	}
+
+	// A rendition being built grows, so players must fetch its playlist again
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// serveHLSSegment serves a segment of a rendition
+func (c *Collector) serveHLSSegment(w http.ResponseWriter, r *http.Request, channelID, videoID, rendition, segment string) {
+	path, err := c.hls.SegmentPath(channelID, videoID, rendition, segment)
+	if err != nil {
+		c.writeHLSError(w, channelID, videoID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp2t")
+	w.Header().Set("Cache-Control", "max-age=86400")
+	http.ServeFile(w, r, path)
+}
+
+// queueChannelHLS queues a channel's archived videos for its policy renditions
+func (c *Collector) queueChannelHLS(w http.ResponseWriter, channelID string) {
+	queued, err := c.hlsPrebuilder.QueueChannel(channelID)
+	if err != nil {
+		c.writeHLSError(w, channelID, "", err)
+		return
+	}
+
+	logging.Info("queued channel for hls prebuild", "channel_id", channelID, "videos", queued)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"channel_id": channelID, "queued": queued})
+}
+
+// writeHLSError maps packaging errors to HTTP responses
+func (c *Collector) writeHLSError(w http.ResponseWriter, channelID, videoID string, err error) {
+	switch {
+	case errors.Is(err, hls.ErrNoSource), errors.Is(err, hls.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, hls.ErrUnknownRendition):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, context.Canceled):
+		// The player went away
+	case errors.Is(err, context.DeadlineExceeded):
+		// The rendition is still being built; the player can ask again
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "Rendition is being built", http.StatusServiceUnavailable)
+	default:
+		logging.Error("failed to serve hls", "channel_id", channelID, "video_id", videoID, "error", err)
+		http.Error(w, "Failed to package video", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timholm/ytarchive/internal/importer"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/store"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// importState tracks the running or last import; one import runs at a time
+type importState struct {
+	mu      sync.Mutex
+	running bool
+	result  *importer.Result
+	err     string
+}
+
+// ImportRequest starts an import of a directory of yt-dlp downloads
+type ImportRequest struct {
+	Path   string `json:"path"` // relative to IMPORT_PATH
+	Mode   string `json:"mode"` // hardlink (default), copy or move
+	DryRun bool   `json:"dry_run"`
+}
+
+// importHandler starts an import with POST and reports its progress with GET
+func (c *Collector) importHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.writeImportStatus(w, http.StatusOK)
+	case http.MethodPost:
+		c.startImport(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startImport validates an import request and runs the import in the background
+func (c *Collector) startImport(w http.ResponseWriter, r *http.Request) {
+	var req ImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	mode, err := importer.ParseMode(req.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	root, err := resolveImportPath(c.config.ImportPath, req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		http.Error(w, "Import path is not a directory", http.StatusBadRequest)
+		return
+	}
+
+	c.imports.mu.Lock()
+	if c.imports.running {
+		c.imports.mu.Unlock()
+		c.writeImportStatus(w, http.StatusConflict)
+		return
+	}
+	c.imports.running = true
+	c.imports.result = &importer.Result{Path: root, Mode: mode, DryRun: req.DryRun, Channels: []string{}, StartedAt: time.Now()}
+	c.imports.err = ""
+	c.imports.mu.Unlock()
+
+	go c.runImport(root, importer.Options{Mode: mode, DryRun: req.DryRun})
+
+	logging.Info("import started", "path", root, "mode", mode, "dry_run", req.DryRun)
+	c.writeImportStatus(w, http.StatusAccepted)
+}
+
+// runImport imports a directory and records the result
+func (c *Collector) runImport(root string, opts importer.Options) {
+	opts.Progress = func(progress importer.Result) {
+		c.imports.mu.Lock()
+		c.imports.result = &progress
+		c.imports.mu.Unlock()
+	}
+
+	catalog := &collectorCatalog{c: c, episodes: make(map[string]int)}
+	result, err := importer.New(c.storage, catalog).Import(context.Background(), root, opts)
+
+	c.imports.mu.Lock()
+	c.imports.running = false
+	c.imports.result = result
+	if err != nil {
+		c.imports.err = err.Error()
+	}
+	c.imports.mu.Unlock()
+
+	if err != nil {
+		logging.Error("import failed", "path", root, "error", err)
+		return
+	}
+	logging.Info("import complete",
+		"path", root,
+		"found", result.Found,
+		"imported", result.Imported,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+		"bytes", result.Bytes,
+	)
+}
+
+// writeImportStatus writes the state of the running or last import
+func (c *Collector) writeImportStatus(w http.ResponseWriter, status int) {
+	c.imports.mu.Lock()
+	response := map[string]interface{}{
+		"running": c.imports.running,
+		"result":  c.imports.result,
+	}
+	if c.imports.err != "" {
+		response["error"] = c.imports.err
+	}
+	data, err := json.Marshal(response)
+	c.imports.mu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to encode import status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// resolveImportPath resolves a requested path against the import directory,
+// rejecting paths outside it
+func resolveImportPath(importPath, requested string) (string, error) {
+	root := filepath.Clean(importPath)
+	path := filepath.Join(root, filepath.FromSlash(requested))
+	if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("import path %q is outside %s", requested, root)
+	}
+	return path, nil
+}
+
+// collectorCatalog records imported videos the way uploads are recorded: in
+// the state database and the channel's SQLite database
+type collectorCatalog struct {
+	c        *Collector
+	episodes map[string]int // last episode number of each channel
+}
+
+// Channel finds the archive channel of a YouTube channel, adding it if needed
+func (cc *collectorCatalog) Channel(ctx context.Context, channel *youtube.Channel) (string, error) {
+	channelID, err := cc.findChannel(ctx, channel.ID)
+	if err != nil {
+		return "", err
+	}
+	if channelID == "" {
+		channelID = uuid.New().String()
+		if err := cc.addChannel(ctx, channelID, channel); err != nil {
+			return "", err
+		}
+		logging.Info("added channel for import", "channel_id", channelID, "youtube_id", channel.ID)
+	}
+
+	cc.episodes[channelID] = cc.countVideos(ctx, channelID)
+	return channelID, nil
+}
+
+// findChannel returns the archive ID of a YouTube channel, or "" if it is not archived
+func (cc *collectorCatalog) findChannel(ctx context.Context, youtubeID string) (string, error) {
+	channels, err := cc.c.records.Store().ListChannels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get channel list: %w", err)
+	}
+	for _, channel := range channels {
+		if channel.YouTubeID == youtubeID {
+			return channel.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// addChannel adds a channel the way the API does, so it can be synced
+func (cc *collectorCatalog) addChannel(ctx context.Context, channelID string, channel *youtube.Channel) error {
+	youtubeURL := channel.URL
+	if youtubeURL == "" {
+		youtubeURL = "https://www.youtube.com/channel/" + channel.ID
+	}
+	now := time.Now()
+	channelJSON, err := json.Marshal(map[string]interface{}{
+		"id":          channelID,
+		"youtube_url": youtubeURL,
+		"youtube_id":  channel.ID,
+		"name":        channel.Name,
+		"description": channel.Description,
+		"video_count": 0,
+		"status":      "pending",
+		"created_at":  now,
+		"updated_at":  now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel: %w", err)
+	}
+
+	if err := cc.c.records.PutChannel(ctx, channelJSON); err != nil {
+		return fmt.Errorf("failed to save channel: %w", err)
+	}
+	return nil
+}
+
+// countVideos counts the videos known for a channel, which is the last episode number in use
+func (cc *collectorCatalog) countVideos(ctx context.Context, channelID string) int {
+	counts, err := cc.c.records.Store().CountVideos(ctx, channelID)
+	if err != nil {
+		return 0
+	}
+
+	var count int
+	for _, n := range counts {
+		count += n
+	}
+	return count
+}
+
+// Archived reports whether a video has been downloaded already
+func (cc *collectorCatalog) Archived(ctx context.Context, channelID, videoID string) (bool, error) {
+	videoData, err := cc.c.records.Video(ctx, channelID, videoID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	var video struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(videoData, &video); err != nil {
+		return false, nil
+	}
+	return video.Status == "downloaded" || video.Status == "completed", nil
+}
+
+// AddVideo records an imported video as downloaded
+func (cc *collectorCatalog) AddVideo(ctx context.Context, video *importer.Imported) error {
+	info := video.Info
+	episode, known := cc.videoEpisode(ctx, video.ChannelID, info.ID)
+	if !known {
+		cc.episodes[video.ChannelID]++
+		episode = cc.episodes[video.ChannelID]
+	}
+
+	metadata := &UploadRequest{
+		VideoID:       info.ID,
+		ChannelID:     video.ChannelID,
+		ChannelName:   info.Channel.Name,
+		Title:         info.Title,
+		Description:   info.Description,
+		Duration:      info.Duration,
+		UploadDate:    info.UploadDate,
+		EpisodeNumber: episode,
+		Filename:      filepath.Base(video.FilePath),
+		FileSize:      video.FileSize,
+		Format:        strings.TrimPrefix(filepath.Ext(video.FilePath), "."),
+	}
+	if err := cc.saveVideo(ctx, video, metadata); err != nil {
+		return err
+	}
+	cc.c.downloadCompleted(metadata.VideoID, video.FilePath)
+	return nil
+}
+
+// videoEpisode returns the episode number of a video already recorded, such
+// as one a sync discovered but that has not been downloaded yet
+func (cc *collectorCatalog) videoEpisode(ctx context.Context, channelID, videoID string) (int, bool) {
+	videoData, err := cc.c.records.Video(ctx, channelID, videoID)
+	if err != nil {
+		return 0, false
+	}
+	var video struct {
+		EpisodeNumber int `json:"episode_number"`
+	}
+	if err := json.Unmarshal(videoData, &video); err != nil || video.EpisodeNumber == 0 {
+		return 0, false
+	}
+	return video.EpisodeNumber, true
+}
+
+// saveVideo writes the record of an imported video, in the shape the
+// scheduler gives discovered videos, keeping any fields it already has
+func (cc *collectorCatalog) saveVideo(ctx context.Context, video *importer.Imported, metadata *UploadRequest) error {
+	info := video.Info
+	record := make(map[string]interface{})
+	if videoData, err := cc.c.records.Video(ctx, video.ChannelID, info.ID); err == nil {
+		json.Unmarshal(videoData, &record)
+	}
+
+	now := time.Now()
+	if _, ok := record["created_at"]; !ok {
+		record["created_at"] = now
+	}
+	record["id"] = info.ID
+	record["youtube_id"] = info.ID
+	record["channel_id"] = video.ChannelID
+	record["title"] = info.Title
+	record["description"] = info.Description
+	record["duration"] = info.Duration
+	record["upload_date"] = info.UploadDate
+	record["thumbnail_url"] = info.ThumbnailURL
+	record["view_count"] = info.ViewCount
+	record["kind"] = info.Kind
+	record["episode_number"] = metadata.EpisodeNumber
+	record["channel_name"] = info.Channel.Name
+	setDownloaded(record, metadata, video.FilePath, video.FileSize, video.Checksum)
+	if len(info.Chapters) > 0 {
+		record["chapters"] = info.Chapters
+	} else {
+		delete(record, "chapters")
+	}
+	record["imported_at"] = now
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal video: %w", err)
+	}
+	if err := cc.c.records.PutVideo(ctx, video.ChannelID, info.ID, data); err != nil {
+		return fmt.Errorf("failed to save video: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/timholm/ytarchive/internal/library"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/types"
+)
+
+// postgresSource loads the library records from the collector's PostgreSQL database
+type postgresSource struct {
+	db *sql.DB
+}
+
+// Channel loads a channel, or returns nil if it does not exist
+func (s *postgresSource) Channel(ctx context.Context, channelID string) (*types.Channel, error) {
+	var channel types.Channel
+	var description sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, name, description, video_count, created_at, updated_at
+		FROM channels WHERE id = $1
+	`, channelID).Scan(&channel.ID, &channel.Name, &description, &channel.VideoCount, &channel.CreatedAt, &channel.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	channel.Description = description.String
+	return &channel, nil
+}
+
+// Videos loads the downloaded videos of a channel
+func (s *postgresSource) Videos(ctx context.Context, channelID string) ([]types.Video, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, channel_id, title, description, duration, upload_date, file_path, file_size, status, created_at, updated_at
+		FROM videos WHERE channel_id = $1 AND status = 'downloaded'
+	`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var videos []types.Video
+	for rows.Next() {
+		var video types.Video
+		var description, uploadDate, filePath sql.NullString
+		var duration, fileSize sql.NullInt64
+		if err := rows.Scan(&video.ID, &video.ChannelID, &video.Title, &description, &duration, &uploadDate,
+			&filePath, &fileSize, &video.Status, &video.CreatedAt, &video.UpdatedAt); err != nil {
+			return nil, err
+		}
+		video.Description = description.String
+		video.Duration = int(duration.Int64)
+		video.UploadDate = uploadDate.String
+		video.FilePath = filePath.String
+		video.FileSize = fileSize.Int64
+		videos = append(videos, video)
+	}
+	return videos, rows.Err()
+}
+
+// channelIDs returns the IDs of all channels
+func (s *postgresSource) channelIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM channels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// setupLibrary creates the library exporter, keeps it updated as downloads
+// complete and builds the initial view in the background
+func (c *Collector) setupLibrary(ctx context.Context) error {
+	mode, err := library.ParseLinkMode(c.config.LibraryLinkMode)
+	if err != nil {
+		return err
+	}
+
+	// The library links to the archived files, so they must be on a local volume
+	local, ok := c.storage.(*storage.LocalBackend)
+	if !ok {
+		return fmt.Errorf("library export needs local storage, not %s", c.storage.Location(""))
+	}
+
+	source := &postgresSource{db: c.db}
+	exporter := library.NewExporter(local.Root(), c.config.LibraryPath, source, library.WithLinkMode(mode))
+	c.onDownloadCompleted(exporter.VideoCompleted)
+
+	go func() {
+		channelIDs, err := source.channelIDs(ctx)
+		if err != nil {
+			logging.Warn("failed to list channels for the library", "error", err)
+			return
+		}
+		if err := exporter.RefreshAll(ctx, channelIDs); err != nil {
+			logging.Warn("library export incomplete", "error", err)
+			return
+		}
+		logging.Info("library export complete", "path", c.config.LibraryPath, "channels", len(channelIDs))
+	}()
+	return nil
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -17,14 +22,17 @@ import (
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
 
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/hls"
 	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/store"
 )
 
 const (
-	httpPort         = 8081
-	maxUploadSize    = 10 * 1024 * 1024 * 1024 // 10GB max file size
-	videoKeyPrefix   = "video:"
-	channelKeyPrefix = "channel:"
+	httpPort        = 8081
+	maxUploadSize   = 10 * 1024 * 1024 * 1024 // 10GB max file size
+	maxMetadataSize = 10 * 1024 * 1024        // metadata fields are buffered in memory
 )
 
 // CollectorConfig holds the collector configuration
@@ -36,6 +44,17 @@ type CollectorConfig struct {
 	PostgresUser string
 	PostgresPass string
 	RedisURL     string
+
+	// LibraryPath is where the media server library view is built; empty disables it
+	LibraryPath     string
+	LibraryLinkMode string // hardlink or symlink
+
+	// PresignStreams redirects stream requests to presigned object store URLs
+	PresignStreams bool
+	PresignExpiry  time.Duration
+
+	// ImportPath is the directory yt-dlp downloads are imported from
+	ImportPath string
 }
 
 // Collector handles receiving and storing video files
@@ -44,6 +63,23 @@ type Collector struct {
 	db     *sql.DB
 	redis  *redis.Client
 	ready  atomic.Bool
+
+	// records keeps channels and videos in the state database, which is the
+	// collector's PostgreSQL database, and caches them in Redis
+	records *store.Records
+
+	// storage holds the archived files, on the local volume or in an object store
+	storage storage.Backend
+
+	imports importState
+
+	// hls packages videos for adaptive streaming; nil without local storage or ffmpeg
+	hls           *hls.Packager
+	hlsPrebuilder *hls.Prebuilder
+
+	// downloadHooks are called with the video ID and file path of each
+	// download once it is recorded; they are registered before serving
+	downloadHooks []func(videoID, filePath string)
 }
 
 // UploadRequest contains metadata for an uploaded video
@@ -81,25 +117,40 @@ func main() {
 	defer collector.db.Close()
 	logging.Info("connected to PostgreSQL")
 
-	// Initialize database schema
-	if err := collector.initSchema(); err != nil {
-		logging.Error("failed to initialize database schema", "error", err)
-		os.Exit(1)
-	}
-
 	// Connect to Redis
 	if err := collector.connectRedis(); err != nil {
 		logging.Warn("failed to connect to Redis, continuing without it", "error", err)
+		collector.redis = nil
 	} else {
 		defer collector.redis.Close()
 		logging.Info("connected to Redis")
 	}
 
-	// Ensure storage directory exists
-	if err := os.MkdirAll(config.StoragePath, 0755); err != nil {
-		logging.Error("failed to create storage directory", "error", err)
+	// Migrate the state database, which the controller shares
+	state, err := store.NewPostgres(context.Background(), collector.db)
+	if err != nil {
+		logging.Error("failed to initialize state database", "error", err)
+		os.Exit(1)
+	}
+	collector.records = store.NewRecords(state, collector.redis)
+
+	// Open the storage backend, creating the storage directory if it is local
+	backend, err := storage.BackendFromEnv(config.StoragePath)
+	if err != nil {
+		logging.Error("failed to open storage backend", "error", err)
 		os.Exit(1)
 	}
+	if local, ok := backend.(*storage.LocalBackend); ok {
+		if err := os.MkdirAll(local.Root(), 0755); err != nil {
+			logging.Error("failed to create storage directory", "error", err)
+			os.Exit(1)
+		}
+	}
+	collector.storage = backend
+	logging.Info("using storage backend", "location", backend.Location(""))
+	if _, ok := backend.(storage.Linker); !ok {
+		logging.Warn("storage backend cannot deduplicate, identical uploads are each stored in full", "location", backend.Location(""))
+	}
 
 	// Set up HTTP server
 	mux := http.NewServeMux()
@@ -111,6 +162,10 @@ func main() {
 	mux.HandleFunc("/api/stats", collector.statsHandler)
 	mux.HandleFunc("/stream/", collector.streamVideoHandler)
 	mux.HandleFunc("/thumbnail/", collector.thumbnailHandler)
+	mux.HandleFunc("/import", collector.importHandler)
+	mux.HandleFunc("/hls/", collector.hlsHandler)
+	mux.HandleFunc("/videos/", collector.videoFilesHandler)
+	mux.HandleFunc("/blobs/prune", collector.pruneBlobsHandler)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", httpPort),
@@ -120,9 +175,28 @@ func main() {
 	}
 
 	// Handle shutdown signals
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Keep the media server library view in step with the archive
+	if config.LibraryPath != "" {
+		if err := collector.setupLibrary(ctx); err != nil {
+			logging.Error("failed to set up library export", "error", err)
+			os.Exit(1)
+		}
+		logging.Info("exporting media server library", "path", config.LibraryPath)
+	}
+
+	// Index downloads for search as they complete
+	if err := collector.setupSearchIndex(); err != nil {
+		logging.Warn("failed to set up search indexing, downloads will be indexed by the controller", "error", err)
+	}
+
+	// Package videos for adaptive streaming
+	if err := collector.setupHLS(ctx); err != nil {
+		logging.Warn("hls streaming disabled", "error", err)
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -152,11 +226,22 @@ func loadConfig() (*CollectorConfig, error) {
 		PostgresUser: os.Getenv("POSTGRES_USER"),
 		PostgresPass: os.Getenv("POSTGRES_PASSWORD"),
 		RedisURL:     os.Getenv("REDIS_URL"),
+
+		LibraryPath:     os.Getenv("LIBRARY_PATH"),
+		LibraryLinkMode: os.Getenv("LIBRARY_LINK_MODE"),
+
+		PresignStreams: os.Getenv("PRESIGN_STREAMS") == "true",
+		PresignExpiry:  time.Hour,
+
+		ImportPath: os.Getenv("IMPORT_PATH"),
 	}
 
 	if config.StoragePath == "" {
 		config.StoragePath = "/data"
 	}
+	if config.ImportPath == "" {
+		config.ImportPath = filepath.Join(config.StoragePath, "import")
+	}
 	if config.PostgresHost == "" {
 		config.PostgresHost = "postgres"
 	}
@@ -172,6 +257,13 @@ func loadConfig() (*CollectorConfig, error) {
 	if config.PostgresPass == "" {
 		config.PostgresPass = "postgres"
 	}
+	if value := os.Getenv("PRESIGN_EXPIRY_MINUTES"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return nil, fmt.Errorf("invalid PRESIGN_EXPIRY_MINUTES %q", value)
+		}
+		config.PresignExpiry = time.Duration(minutes) * time.Minute
+	}
 
 	return config, nil
 }
@@ -226,44 +318,6 @@ func (c *Collector) connectRedis() error {
 	return c.redis.Ping(ctx).Err()
 }
 
-func (c *Collector) initSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS channels (
-		id VARCHAR(64) PRIMARY KEY,
-		youtube_id VARCHAR(64) NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		description TEXT,
-		video_count INTEGER DEFAULT 0,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS videos (
-		id VARCHAR(64) PRIMARY KEY,
-		channel_id VARCHAR(64) NOT NULL REFERENCES channels(id),
-		title VARCHAR(500) NOT NULL,
-		description TEXT,
-		duration INTEGER,
-		upload_date VARCHAR(20),
-		episode_number INTEGER,
-		filename VARCHAR(500),
-		file_path VARCHAR(1000),
-		file_size BIGINT,
-		resolution VARCHAR(20),
-		format VARCHAR(20),
-		status VARCHAR(20) DEFAULT 'pending',
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_videos_channel ON videos(channel_id);
-	CREATE INDEX IF NOT EXISTS idx_videos_status ON videos(status);
-	`
-
-	_, err := c.db.Exec(schema)
-	return err
-}
-
 func (c *Collector) healthzHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
@@ -285,93 +339,127 @@ func (c *Collector) uploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form
+	// Stream the multipart form: the worker sends the metadata fields first,
+	// so the video can be written to storage as it arrives
 	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
-	if err := r.ParseMultipartForm(100 * 1024 * 1024); err != nil { // 100MB memory limit
+	reader, err := r.MultipartReader()
+	if err != nil {
 		logging.Error("failed to parse multipart form", "error", err)
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
-	defer r.MultipartForm.RemoveAll()
-
-	// Get metadata
-	metadataStr := r.FormValue("metadata")
-	if metadataStr == "" {
-		http.Error(w, "Missing metadata", http.StatusBadRequest)
-		return
-	}
 
 	var metadata UploadRequest
-	if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
-		logging.Error("failed to parse metadata", "error", err)
-		http.Error(w, "Invalid metadata", http.StatusBadRequest)
-		return
-	}
+	var hasMetadata bool
+	var info []byte
+	var destKey, checksum string
+	var written int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logging.Error("failed to parse multipart form", "error", err)
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
 
-	// Get file
-	file, header, err := r.FormFile("video")
-	if err != nil {
-		logging.Error("failed to get video file", "error", err)
-		http.Error(w, "Missing video file", http.StatusBadRequest)
-		return
-	}
-	defer file.Close()
+		switch part.FormName() {
+		case "metadata":
+			data, err := io.ReadAll(io.LimitReader(part, maxMetadataSize))
+			if err == nil {
+				err = json.Unmarshal(data, &metadata)
+			}
+			if err != nil {
+				logging.Error("failed to parse metadata", "error", err)
+				http.Error(w, "Invalid metadata", http.StatusBadRequest)
+				return
+			}
+			hasMetadata = true
 
-	logging.Info("receiving video upload",
-		"video_id", metadata.VideoID,
-		"channel_id", metadata.ChannelID,
-		"filename", header.Filename,
-		"size", header.Size,
-	)
+		case "info":
+			if info, err = io.ReadAll(io.LimitReader(part, maxMetadataSize)); err != nil {
+				logging.Error("failed to read metadata.json", "error", err)
+				http.Error(w, "Failed to parse form", http.StatusBadRequest)
+				return
+			}
 
-	// Ensure channel exists in database
-	if err := c.ensureChannel(metadata.ChannelID, metadata.ChannelName); err != nil {
-		logging.Error("failed to ensure channel exists", "error", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
-	}
+		case "video":
+			if !hasMetadata {
+				http.Error(w, "Missing metadata", http.StatusBadRequest)
+				return
+			}
 
-	// Create destination directory
-	destDir := filepath.Join(c.config.StoragePath, "channels", metadata.ChannelID, "videos", metadata.VideoID)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		logging.Error("failed to create destination directory", "error", err)
-		http.Error(w, "Storage error", http.StatusInternalServerError)
-		return
-	}
+			logging.Info("receiving video upload",
+				"video_id", metadata.VideoID,
+				"channel_id", metadata.ChannelID,
+				"filename", part.FileName(),
+			)
 
-	// Determine filename
-	filename := metadata.Filename
-	if filename == "" {
-		filename = header.Filename
+			// Determine filename
+			filename := metadata.Filename
+			if filename == "" {
+				filename = part.FileName()
+			}
+			destKey = storage.VideoKey(metadata.ChannelID, metadata.VideoID, path.Base(filename))
+
+			// Hash the video as it is stored, so it is never read twice
+			hashing := storage.NewHashingReader(part)
+			if err := c.storage.Put(r.Context(), destKey, hashing, -1); err != nil {
+				logging.Error("failed to write file", "key", destKey, "error", err)
+				http.Error(w, "Storage error", http.StatusInternalServerError)
+				return
+			}
+			written = hashing.Size()
+			checksum = hashing.Checksum()
+		}
+		part.Close()
 	}
-	destPath := filepath.Join(destDir, filename)
 
-	// Write file
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		logging.Error("failed to create destination file", "error", err)
-		http.Error(w, "Storage error", http.StatusInternalServerError)
+	if !hasMetadata {
+		http.Error(w, "Missing metadata", http.StatusBadRequest)
 		return
 	}
-	defer destFile.Close()
+	if destKey == "" {
+		http.Error(w, "Missing video file", http.StatusBadRequest)
+		return
+	}
+	destPath := c.storage.Location(destKey)
 
-	written, err := io.Copy(destFile, file)
+	// Store identical content, such as re-uploads on another channel, only once
+	deduplicated, err := storage.Deduplicate(r.Context(), c.storage, destKey, checksum, written)
 	if err != nil {
-		logging.Error("failed to write file", "error", err)
-		os.Remove(destPath)
-		http.Error(w, "Storage error", http.StatusInternalServerError)
-		return
+		logging.Warn("failed to deduplicate video", "video_id", metadata.VideoID, "error", err)
+	} else if deduplicated {
+		logging.Info("video content already archived, linked to existing copy",
+			"video_id", metadata.VideoID,
+			"checksum", checksum,
+		)
+	}
+
+	// Keep the worker's metadata.json (chapters, SponsorBlock segments) next to the video
+	if info != nil {
+		infoKey := storage.VideoKey(metadata.ChannelID, metadata.VideoID, "metadata.json")
+		if err := c.storage.Put(r.Context(), infoKey, bytes.NewReader(info), int64(len(info))); err != nil {
+			logging.Warn("failed to write metadata.json", "video_id", metadata.VideoID, "error", err)
+		}
 	}
 
-	// Store metadata in PostgreSQL
-	if err := c.storeVideoMetadata(&metadata, destPath, written); err != nil {
+	// Mark the video downloaded in the state database, with the chapters
+	// feeds list, so they need not read metadata.json back from storage
+	var chapters []downloader.Chapter
+	if info != nil {
+		if _, chapters, err = downloader.ParseEmbedInfo(info); err != nil {
+			logging.Warn("failed to parse metadata.json", "video_id", metadata.VideoID, "error", err)
+		}
+	}
+	// Then update the library, search index and HLS renditions from the record
+	if err := c.recordDownload(r.Context(), &metadata, chapters, destPath, written, checksum); err != nil {
 		logging.Error("failed to store video metadata", "error", err)
 		// Don't delete the file, just log the error
-	}
-
-	// Update Redis if available
-	if c.redis != nil {
-		c.updateRedisVideoStatus(metadata.ChannelID, metadata.VideoID, "downloaded", destPath, written)
+	} else {
+		c.downloadCompleted(metadata.VideoID, destPath)
 	}
 
 	logging.Info("video upload complete",
@@ -379,83 +467,90 @@ func (c *Collector) uploadHandler(w http.ResponseWriter, r *http.Request) {
 		"channel_id", metadata.ChannelID,
 		"file_path", destPath,
 		"file_size", written,
+		"checksum", checksum,
 	)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":   true,
-		"video_id":  metadata.VideoID,
-		"file_path": destPath,
-		"file_size": written,
+		"success":      true,
+		"video_id":     metadata.VideoID,
+		"file_path":    destPath,
+		"file_size":    written,
+		"checksum":     checksum,
+		"deduplicated": deduplicated,
 	})
 }
 
-func (c *Collector) ensureChannel(channelID, channelName string) error {
-	query := `
-		INSERT INTO channels (id, youtube_id, name)
-		VALUES ($1, $1, $2)
-		ON CONFLICT (id) DO UPDATE SET
-			name = COALESCE(NULLIF($2, ''), channels.name),
-			updated_at = CURRENT_TIMESTAMP
-	`
-	_, err := c.db.Exec(query, channelID, channelName)
-	return err
+// onDownloadCompleted registers fn to be called each time a download is recorded
+func (c *Collector) onDownloadCompleted(fn func(videoID, filePath string)) {
+	c.downloadHooks = append(c.downloadHooks, fn)
 }
 
-func (c *Collector) storeVideoMetadata(metadata *UploadRequest, filePath string, fileSize int64) error {
-	query := `
-		INSERT INTO videos (id, channel_id, title, description, duration, upload_date,
-		                    episode_number, filename, file_path, file_size, resolution, format, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 'downloaded')
-		ON CONFLICT (id) DO UPDATE SET
-			title = $3,
-			description = $4,
-			file_path = $9,
-			file_size = $10,
-			resolution = $11,
-			format = $12,
-			status = 'downloaded',
-			updated_at = CURRENT_TIMESTAMP
-	`
-	_, err := c.db.Exec(query,
-		metadata.VideoID,
-		metadata.ChannelID,
-		metadata.Title,
-		metadata.Description,
-		metadata.Duration,
-		metadata.UploadDate,
-		metadata.EpisodeNumber,
-		metadata.Filename,
-		filePath,
-		fileSize,
-		metadata.Resolution,
-		metadata.Format,
-	)
-	return err
+// downloadCompleted calls the download hooks, in the order they were registered
+func (c *Collector) downloadCompleted(videoID, filePath string) {
+	for _, hook := range c.downloadHooks {
+		hook(videoID, filePath)
+	}
 }
 
-func (c *Collector) updateRedisVideoStatus(channelID, videoID, status, filePath string, fileSize int64) {
-	ctx := context.Background()
-	videoKey := videoKeyPrefix + channelID + ":" + videoID
-
-	// Get existing video data
-	videoData, err := c.redis.Get(ctx, videoKey).Result()
+// recordDownload marks a video downloaded, creating its record from the
+// upload's metadata if no sync discovered it
+func (c *Collector) recordDownload(ctx context.Context, metadata *UploadRequest, chapters []downloader.Chapter, filePath string, fileSize int64, checksum string) error {
+	_, err := c.records.UpdateVideo(ctx, metadata.ChannelID, metadata.VideoID, func(video map[string]interface{}) {
+		setDownloaded(video, metadata, filePath, fileSize, checksum)
+		setChapters(video, chapters)
+	})
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	video := map[string]interface{}{
+		"id":             metadata.VideoID,
+		"youtube_id":     metadata.VideoID,
+		"channel_id":     metadata.ChannelID,
+		"channel_name":   metadata.ChannelName,
+		"title":          metadata.Title,
+		"description":    metadata.Description,
+		"duration":       metadata.Duration,
+		"upload_date":    metadata.UploadDate,
+		"episode_number": metadata.EpisodeNumber,
+		"created_at":     time.Now(),
+	}
+	setDownloaded(video, metadata, filePath, fileSize, checksum)
+	setChapters(video, chapters)
+
+	data, err := json.Marshal(video)
 	if err != nil {
-		return
-	}
-
-	var video map[string]interface{}
-	if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-		return
+		return fmt.Errorf("failed to marshal video: %w", err)
 	}
+	return c.records.PutVideo(ctx, metadata.ChannelID, metadata.VideoID, data)
+}
 
-	video["status"] = status
+// setDownloaded records the archived file on a video record
+func setDownloaded(video map[string]interface{}, metadata *UploadRequest, filePath string, fileSize int64, checksum string) {
+	video["status"] = "downloaded"
 	video["file_path"] = filePath
 	video["file_size"] = fileSize
+	video["checksum"] = checksum
 	video["updated_at"] = time.Now()
+	if metadata.Filename != "" {
+		video["filename"] = metadata.Filename
+	}
+	if metadata.Resolution != "" {
+		video["resolution"] = metadata.Resolution
+	}
+	if metadata.Format != "" {
+		video["format"] = metadata.Format
+	}
+}
 
-	updatedData, _ := json.Marshal(video)
-	c.redis.Set(ctx, videoKey, updatedData, 0)
+// setChapters records the chapters of the archived file on a video record
+func setChapters(video map[string]interface{}, chapters []downloader.Chapter) {
+	if len(chapters) > 0 {
+		video["chapters"] = chapters
+	} else {
+		delete(video, "chapters")
+	}
 }
 
 func (c *Collector) listVideosHandler(w http.ResponseWriter, r *http.Request) {
@@ -611,44 +706,45 @@ func (c *Collector) streamVideoHandler(w http.ResponseWriter, r *http.Request) {
 	channelID := parts[0]
 	videoID := parts[1]
 
-	// Find video file
-	videoDir := filepath.Join(c.config.StoragePath, "channels", channelID, "videos", videoID)
-
-	// Look for video file with various extensions
-	patterns := []string{"video.mp4", "video.mkv", "video.webm", "*.mp4", "*.mkv", "*.webm"}
-	var videoPath string
-	for _, pattern := range patterns {
-		matches, _ := filepath.Glob(filepath.Join(videoDir, pattern))
-		if len(matches) > 0 {
-			videoPath = matches[0]
-			break
-		}
-	}
-
-	if videoPath == "" {
-		logging.Warn("video file not found", "channel_id", channelID, "video_id", videoID, "dir", videoDir)
+	// Find video file, looking for various extensions; audio-only profiles keep an .m4a
+	patterns := []string{"video.mp4", "video.mkv", "video.webm", "*.mp4", "*.mkv", "*.webm", "*.m4a"}
+	object, err := c.findVideoFile(r.Context(), channelID, videoID, patterns)
+	if errors.Is(err, storage.ErrNotExist) {
+		logging.Warn("video file not found", "channel_id", channelID, "video_id", videoID)
 		http.Error(w, "Video file not found", http.StatusNotFound)
 		return
 	}
-
-	// Get file info for Content-Length
-	fileInfo, err := os.Stat(videoPath)
 	if err != nil {
+		logging.Error("failed to find video file", "channel_id", channelID, "video_id", videoID, "error", err)
 		http.Error(w, "Failed to read video file", http.StatusInternalServerError)
 		return
 	}
 
+	// Let the object store serve the video directly if it can
+	if c.config.PresignStreams {
+		url, err := c.storage.PresignGet(r.Context(), object.Key, c.config.PresignExpiry)
+		if err == nil {
+			http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+			return
+		}
+		if !errors.Is(err, storage.ErrPresignUnsupported) {
+			logging.Warn("failed to presign video URL", "key", object.Key, "error", err)
+		}
+	}
+
 	// Set headers for video streaming
 	w.Header().Set("Accept-Ranges", "bytes")
-	w.Header().Set("Content-Type", getContentType(videoPath))
+	w.Header().Set("Content-Type", getContentType(object.Key))
 
-	// Serve the file (http.ServeFile handles range requests)
-	http.ServeFile(w, r, videoPath)
+	// Serve the object (http.ServeContent handles range requests)
+	content := storage.NewReadSeeker(r.Context(), c.storage, object.Key, object.Size)
+	defer content.Close()
+	http.ServeContent(w, r, object.Name(), object.ModTime, content)
 
 	logging.Info("streaming video",
 		"channel_id", channelID,
 		"video_id", videoID,
-		"file_size", fileInfo.Size(),
+		"file_size", object.Size,
 	)
 }
 
@@ -667,26 +763,53 @@ func (c *Collector) thumbnailHandler(w http.ResponseWriter, r *http.Request) {
 	channelID := parts[0]
 	videoID := parts[1]
 
-	// Find thumbnail file
-	videoDir := filepath.Join(c.config.StoragePath, "channels", channelID, "videos", videoID)
-
 	// Look for thumbnail
 	patterns := []string{"thumbnail.jpg", "thumbnail.png", "thumbnail.webp", "*.jpg", "*.png", "*.webp"}
-	var thumbPath string
-	for _, pattern := range patterns {
-		matches, _ := filepath.Glob(filepath.Join(videoDir, pattern))
-		if len(matches) > 0 {
-			thumbPath = matches[0]
-			break
-		}
-	}
-
-	if thumbPath == "" {
+	object, err := c.findVideoFile(r.Context(), channelID, videoID, patterns)
+	if errors.Is(err, storage.ErrNotExist) {
 		http.Error(w, "Thumbnail not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		logging.Error("failed to find thumbnail", "channel_id", channelID, "video_id", videoID, "error", err)
+		http.Error(w, "Failed to read thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", getContentType(object.Key))
+	content := storage.NewReadSeeker(r.Context(), c.storage, object.Key, object.Size)
+	defer content.Close()
+	http.ServeContent(w, r, object.Name(), object.ModTime, content)
+}
 
-	http.ServeFile(w, r, thumbPath)
+// findVideoFile returns the first file in a video's directory that matches
+// one of the patterns, trying the patterns in order
+func (c *Collector) findVideoFile(ctx context.Context, channelID, videoID string, patterns []string) (storage.ObjectInfo, error) {
+	if strings.Contains(channelID, "..") || strings.Contains(videoID, "..") {
+		return storage.ObjectInfo{}, storage.ErrNotExist
+	}
+
+	prefix := storage.VideoKey(channelID, videoID, "") + "/"
+	var files []storage.ObjectInfo
+	err := c.storage.List(ctx, prefix, func(object storage.ObjectInfo) error {
+		// Only files directly in the video directory, not subtitles
+		if !strings.Contains(strings.TrimPrefix(object.Key, prefix), "/") {
+			files = append(files, object)
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	for _, pattern := range patterns {
+		for _, file := range files {
+			if matched, _ := path.Match(pattern, file.Name()); matched {
+				return file, nil
+			}
+		}
+	}
+	return storage.ObjectInfo{}, storage.ErrNotExist
 }
 
 func splitPath(path string) []string {
@@ -730,6 +853,8 @@ func getContentType(path string) string {
 		return "video/x-matroska"
 	case ".webm":
 		return "video/webm"
+	case ".m4a":
+		return "audio/mp4"
 	case ".jpg", ".jpeg":
 		return "image/jpeg"
 	case ".png":
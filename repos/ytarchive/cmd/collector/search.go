@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/search"
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+// setupSearchIndex adds videos to the global search index as their downloads
+// complete. The index stays open for the life of the collector.
+func (c *Collector) setupSearchIndex() error {
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		return fmt.Errorf("failed to open search index: %w", err)
+	}
+
+	// Chapters and subtitles are read from the archive volume; videos kept in
+	// an object store are indexed by their metadata only
+	var archivePath string
+	if local, ok := c.storage.(*storage.LocalBackend); ok {
+		archivePath = local.Root()
+	}
+
+	c.onDownloadCompleted(search.NewIndexer(index, archivePath, c.records).VideoCompleted)
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+// VerifyRequest asks for the checksum of a video's file to be verified
+type VerifyRequest struct {
+	FilePath string `json:"file_path"` // as recorded when the video was uploaded
+	Checksum string `json:"checksum"`
+}
+
+// videoFilesHandler manages the archived files of a video for the controller,
+// which does not mount the archive volume.
+// DELETE /videos/{channel_id}/{video_id} deletes them, and
+// POST /videos/{channel_id}/{video_id}/verify verifies a checksum
+func (c *Collector) videoFilesHandler(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/videos/"))
+	if len(parts) < 2 || strings.Contains(parts[0], "..") || strings.Contains(parts[1], "..") {
+		http.Error(w, "Invalid path. Expected /videos/{channel_id}/{video_id}", http.StatusBadRequest)
+		return
+	}
+	channelID, videoID := parts[0], parts[1]
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		c.deleteVideoFiles(w, r, channelID, videoID)
+	case len(parts) == 3 && parts[2] == "verify" && r.Method == http.MethodPost:
+		c.verifyVideoFile(w, r, channelID, videoID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// deleteVideoFiles deletes every file of a video, such as one pruned by a
+// retention policy, and the blob named by the blob parameter
+func (c *Collector) deleteVideoFiles(w http.ResponseWriter, r *http.Request, channelID, videoID string) {
+	blob := r.URL.Query().Get("blob")
+	if blob != "" && !isChecksum(blob) {
+		http.Error(w, "Invalid blob checksum", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := c.deleteVideoObjects(r.Context(), channelID, videoID)
+	if err == nil && blob != "" {
+		err = c.storage.Delete(r.Context(), storage.BlobKey(blob))
+	}
+	if err != nil {
+		logging.Error("failed to delete video files", "channel_id", channelID, "video_id", videoID, "error", err)
+		http.Error(w, "Failed to delete video files", http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info("deleted video files",
+		"channel_id", channelID,
+		"video_id", videoID,
+		"files", deleted,
+	)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+}
+
+// deleteVideoObjects deletes the objects under a video's directory and
+// returns how many were deleted
+func (c *Collector) deleteVideoObjects(ctx context.Context, channelID, videoID string) (int, error) {
+	prefix := storage.VideoKey(channelID, videoID, "") + "/"
+	var keys []string
+	err := c.storage.List(ctx, prefix, func(object storage.ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, key := range keys {
+		if err := c.storage.Delete(ctx, key); err != nil {
+			return i, err
+		}
+	}
+
+	// Local directories outlive their files
+	if local, ok := c.storage.(*storage.LocalBackend); ok {
+		if err := os.RemoveAll(local.Location(prefix)); err != nil {
+			return len(keys), fmt.Errorf("failed to delete video directory: %w", err)
+		}
+	}
+	return len(keys), nil
+}
+
+// verifyVideoFile hashes a video's file and reports whether it matches the
+// expected checksum. A missing file does not match.
+func (c *Collector) verifyVideoFile(w http.ResponseWriter, r *http.Request, channelID, videoID string) {
+	var req VerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Checksum == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	key, err := c.objectKey(req.FilePath)
+	if err != nil || !strings.HasPrefix(key, storage.VideoKey(channelID, videoID, "")+"/") {
+		http.Error(w, "File path is not in the video's directory", http.StatusBadRequest)
+		return
+	}
+
+	checksum, err := c.hashObject(r.Context(), key)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		logging.Error("failed to hash video file", "key", key, "error", err)
+		http.Error(w, "Failed to read video file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"valid": err == nil && checksum == req.Checksum})
+}
+
+// pruneBlobsHandler deletes the deduplicated blobs no video links to any
+// more, on backends that deduplicate
+// URL format: POST /blobs/prune
+func (c *Collector) pruneBlobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pruned := 0
+	if pruner, ok := c.storage.(storage.BlobPruner); ok {
+		var err error
+		pruned, err = pruner.PruneBlobs(r.Context())
+		if err != nil {
+			logging.Error("failed to prune blobs", "pruned", pruned, "error", err)
+			http.Error(w, "Failed to prune blobs", http.StatusInternalServerError)
+			return
+		}
+	}
+	if pruned > 0 {
+		logging.Info("pruned unreferenced blobs", "pruned", pruned)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"pruned": pruned})
+}
+
+// hashObject returns the SHA-256 checksum of an object
+func (c *Collector) hashObject(ctx context.Context, key string) (string, error) {
+	reader, err := c.storage.Open(ctx, key, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hashing := storage.NewHashingReader(reader)
+	if _, err := io.Copy(io.Discard, hashing); err != nil {
+		return "", err
+	}
+	return hashing.Checksum(), nil
+}
+
+// objectKey returns the key of the object at a location recorded for a video
+func (c *Collector) objectKey(location string) (string, error) {
+	root := strings.TrimSuffix(c.storage.Location(""), "/") + "/"
+	if !strings.HasPrefix(location, root) {
+		return "", fmt.Errorf("%s is not in %s", location, root)
+	}
+	return strings.TrimPrefix(location, root), nil
+}
+
+// isChecksum reports whether s is a hex SHA-256 checksum
+func isChecksum(s string) bool {
+	return len(s) == 64 && strings.Trim(s, "0123456789abcdef") == ""
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/timholm/ytarchive/internal/api"
 	"github.com/timholm/ytarchive/internal/logging"
 	"github.com/timholm/ytarchive/internal/scheduler"
+	"github.com/timholm/ytarchive/internal/store"
 )
 
 func main() {
@@ -30,19 +31,58 @@ func main() {
 	defer redisClient.Close()
 	logging.Info("connected to Redis")
 
+	// Channels, videos and sync jobs are kept in the state database; Redis caches them
+	state, err := store.Open(context.Background(), store.URLFromEnv())
+	if err != nil {
+		logging.Error("failed to open state database", "error", err)
+		os.Exit(1)
+	}
+	defer state.Close()
+	records := store.NewRecords(state, redisClient)
+	warmCache(redisClient, records)
+	logging.Info("opened state database")
+
 	// Initialize Kubernetes client
-	k8sClient, err := initK8sClient()
+	k8sConfig, err := initK8sConfig()
+	if err != nil {
+		logging.Error("failed to load Kubernetes config", "error", err)
+		os.Exit(1)
+	}
+	k8sClient, err := kubernetes.NewForConfig(k8sConfig)
 	if err != nil {
 		logging.Error("failed to create Kubernetes client", "error", err)
 		os.Exit(1)
 	}
 	logging.Info("connected to Kubernetes")
+	namespace := getEnvWithDefault("K8S_NAMESPACE", "default")
+
+	// Syncs run in the controller, or as Argo workflows
+	var workflows *scheduler.WorkflowRunner
+	switch backend := getEnvWithDefault("SYNC_BACKEND", scheduler.SyncBackendJobs); backend {
+	case scheduler.SyncBackendJobs:
+	case scheduler.SyncBackendArgo:
+		template := getEnvWithDefault("ARGO_SYNC_TEMPLATE", scheduler.DefaultSyncTemplate)
+		workflows, err = scheduler.NewWorkflowRunner(k8sConfig, getEnvWithDefault("ARGO_NAMESPACE", namespace), template)
+		if err != nil {
+			logging.Error("failed to create Argo workflow client", "error", err)
+			os.Exit(1)
+		}
+		logging.Info("running syncs as Argo workflows", "template", template)
+	default:
+		logging.Error("unknown sync backend", "backend", backend)
+		os.Exit(1)
+	}
 
 	// Initialize scheduler
-	jobScheduler := scheduler.NewScheduler(k8sClient, redisClient, getEnvWithDefault("K8S_NAMESPACE", "default"))
+	jobScheduler := scheduler.NewScheduler(k8sClient, redisClient, namespace, records, workflows)
 
 	// Initialize API handlers
-	handlers := api.NewHandlers(redisClient, jobScheduler)
+	handlers := api.NewHandlers(redisClient, jobScheduler, records)
+	bootstrapCtx, cancelBootstrap := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := handlers.BootstrapAdmin(bootstrapCtx); err != nil {
+		logging.Error("failed to create admin user", "error", err)
+	}
+	cancelBootstrap()
 
 	// Set up Gin router
 	router := api.SetupRoutes(handlers)
@@ -83,6 +123,25 @@ func main() {
 	logging.Info("server exited gracefully")
 }
 
+// warmCache caches the state database in Redis again when Redis has lost it,
+// as after a restart without persistence
+func warmCache(redisClient *redis.Client, records *store.Records) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if cached, err := redisClient.Exists(ctx, "channels").Result(); err != nil || cached > 0 {
+		return
+	}
+	count, err := records.Warm(ctx)
+	if err != nil {
+		logging.Warn("failed to warm cache", "error", err)
+		return
+	}
+	if count > 0 {
+		logging.Info("warmed cache from state database", "records", count)
+	}
+}
+
 // initRedis creates and tests a Redis connection
 func initRedis() (*redis.Client, error) {
 	redisAddr := getEnvWithDefault("REDIS_URL", "localhost:6379")
@@ -110,8 +169,8 @@ func initRedis() (*redis.Client, error) {
 	return client, nil
 }
 
-// initK8sClient creates a Kubernetes client
-func initK8sClient() (*kubernetes.Clientset, error) {
+// initK8sConfig loads the Kubernetes client config
+func initK8sConfig() (*rest.Config, error) {
 	var config *rest.Config
 	var err error
 
@@ -132,7 +191,7 @@ func initK8sClient() (*kubernetes.Clientset, error) {
 		logging.Info("using in-cluster Kubernetes config")
 	}
 
-	return kubernetes.NewForConfig(config)
+	return config, nil
 }
 
 // getEnvWithDefault returns environment variable value or default if not set
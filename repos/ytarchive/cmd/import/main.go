@@ -0,0 +1,149 @@
+// Command import adopts a directory of yt-dlp downloads into the archive. The
+// directory must contain the .info.json files yt-dlp --write-info-json writes
+// and be visible to the collector under its IMPORT_PATH. The collector runs
+// the import; this command starts it and follows its progress.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/importer"
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// pollInterval is how often the import's progress is checked
+const pollInterval = 5 * time.Second
+
+// importStatus is the collector's report of an import
+type importStatus struct {
+	Running bool             `json:"running"`
+	Result  *importer.Result `json:"result"`
+	Error   string           `json:"error"`
+}
+
+func main() {
+	collectorURL := flag.String("collector", getEnvWithDefault("COLLECTOR_URL", "http://collector.ytarchive.svc.cluster.local:8081"), "collector URL")
+	path := flag.String("path", "", "directory to import, relative to the collector's IMPORT_PATH")
+	mode := flag.String("mode", "hardlink", "how videos are placed in the archive: hardlink, copy or move")
+	dryRun := flag.Bool("dry-run", false, "list the videos that would be imported without importing them")
+	flag.Parse()
+
+	if _, err := importer.ParseMode(*mode); err != nil {
+		logging.Error("invalid mode", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	baseURL := strings.TrimSuffix(*collectorURL, "/")
+	body, _ := json.Marshal(map[string]interface{}{"path": *path, "mode": *mode, "dry_run": *dryRun})
+	status, err := request(ctx, http.MethodPost, baseURL+"/import", body)
+	if err != nil {
+		logging.Error("failed to start import", "error", err)
+		os.Exit(1)
+	}
+	logging.Info("import started", "path", status.Result.Path, "mode", *mode, "dry_run", *dryRun)
+
+	// The import goes on in the collector if this command is interrupted
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for status.Running {
+		select {
+		case <-ctx.Done():
+			logging.Warn("stopped following the import; it continues in the collector")
+			os.Exit(1)
+		case <-ticker.C:
+		}
+
+		status, err = request(ctx, http.MethodGet, baseURL+"/import", nil)
+		if err != nil {
+			logging.Warn("failed to get import progress", "error", err)
+			continue
+		}
+		if status.Running && status.Result != nil {
+			logging.Info("importing",
+				"found", status.Result.Found,
+				"imported", status.Result.Imported,
+				"skipped", status.Result.Skipped,
+				"failed", status.Result.Failed,
+			)
+		}
+	}
+
+	result := status.Result
+	for _, e := range result.Errors {
+		logging.Warn("not imported", "path", e.Path, "reason", e.Reason)
+	}
+	if status.Error != "" {
+		logging.Error("import failed", "error", status.Error)
+		os.Exit(1)
+	}
+	logging.Info("import complete",
+		"dry_run", result.DryRun,
+		"found", result.Found,
+		"imported", result.Imported,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+		"bytes", result.Bytes,
+		"channels", len(result.Channels),
+	)
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// request sends a request to the collector's import endpoint
+func request(ctx context.Context, method, url string, body []byte) (*importStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return nil, fmt.Errorf("another import is already running")
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	var status importStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse collector response: %w", err)
+	}
+	if status.Result == nil {
+		return nil, fmt.Errorf("collector has no import")
+	}
+	return &status, nil
+}
+
+// getEnvWithDefault returns an environment variable or a default value
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
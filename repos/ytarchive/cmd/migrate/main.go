@@ -0,0 +1,127 @@
+// Command migrate moves an archive between storage backends, for example from
+// the Trident volume to an S3-compatible bucket. Files already in the
+// destination with the same size are skipped, so an interrupted migration can
+// simply be run again.
+//
+// migrate state instead merges the state kept in Redis and the per-channel
+// databases into the state database; see migrateState.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		if err := migrateState(os.Args[2:]); err != nil {
+			logging.Error("state migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	from := flag.String("from", getEnvWithDefault("STORAGE_PATH", "/data"), "source: a directory or s3://bucket/prefix")
+	to := flag.String("to", "", "destination: a directory or s3://bucket/prefix")
+	prefix := flag.String("prefix", "channels/", "only migrate files whose path starts with this prefix")
+	dryRun := flag.Bool("dry-run", false, "list the files that would be copied without copying them")
+	deleteSource := flag.Bool("delete-source", false, "delete each file from the source once it is in the destination")
+	includeDatabases := flag.Bool("include-databases", false, "also copy the per-channel SQLite databases, which otherwise stay on STORAGE_PATH")
+	flag.Parse()
+
+	if *to == "" {
+		logging.Error("no destination given, use -to")
+		os.Exit(1)
+	}
+	if *to == *from {
+		logging.Error("source and destination are the same", "location", *from)
+		os.Exit(1)
+	}
+
+	// Both ends use the S3 endpoint and credentials of the environment
+	s3Config := storage.S3ConfigFromEnv()
+	src, err := storage.OpenBackend(*from, s3Config)
+	if err != nil {
+		logging.Error("failed to open source", "error", err)
+		os.Exit(1)
+	}
+	dst, err := storage.OpenBackend(*to, s3Config)
+	if err != nil {
+		logging.Error("failed to open destination", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// Videos keep their own copy in a backend that cannot link, so their blobs would be stored twice
+	_, deduplicates := dst.(storage.Linker)
+	opts := storage.MigrateOptions{
+		Prefix:       *prefix,
+		DryRun:       *dryRun,
+		DeleteSource: *deleteSource,
+		Skip: func(key string) bool {
+			if !*includeDatabases && isDatabaseFile(key) {
+				return true
+			}
+			return !deduplicates && storage.IsBlobKey(key)
+		},
+	}
+
+	logging.Info("migrating archive",
+		"from", src.Location(""),
+		"to", dst.Location(""),
+		"prefix", *prefix,
+		"dry_run", *dryRun,
+	)
+	result, err := storage.Migrate(ctx, src, dst, opts)
+	if err != nil {
+		logging.Error("migration stopped", "error", err)
+	}
+
+	logging.Info("migration complete",
+		"dry_run", *dryRun,
+		"copied", result.Copied,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+		"bytes", result.Bytes,
+	)
+	if err != nil || result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// signalContext returns a context canceled on SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// isDatabaseFile reports whether key is a SQLite database or one of its
+// journal files. SQLite needs a local file system, so the databases are not
+// moved to object storage.
+func isDatabaseFile(key string) bool {
+	name := path.Base(key)
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if name == db.DBFileName+suffix {
+			return true
+		}
+	}
+	return strings.HasSuffix(name, ".db")
+}
+
+// getEnvWithDefault returns an environment variable or a default value
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// migrateState moves the archive's state into the state database, once, when
+// upgrading from the releases that kept it in Redis, the per-channel SQLite
+// databases and the collector's PostgreSQL database. It can be run again; a
+// second run changes nothing.
+//
+//	migrate state [-dry-run] [-legacy postgres://...] [-channel-dbs=false]
+func migrateState(args []string) error {
+	flags := flag.NewFlagSet("state", flag.ExitOnError)
+	stateURL := flags.String("state", store.URLFromEnv(), "state database URL, postgres:// or sqlite://")
+	redisURL := flags.String("redis", getEnvWithDefault("REDIS_URL", "localhost:6379"), "Redis address or redis:// URL")
+	legacyURL := flags.String("legacy", "", "another state database to merge, such as the collector's postgres:// URL when it is not the state database")
+	channelDBs := flags.Bool("channel-dbs", true, "merge the video statuses and comments of the per-channel SQLite databases and the entries of the per-playlist ones")
+	dryRun := flags.Bool("dry-run", false, "count what would be saved without saving it")
+	flags.Parse(args)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	redisClient, err := connectRedis(ctx, *redisURL)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	state, err := store.Open(ctx, *stateURL)
+	if err != nil {
+		return fmt.Errorf("failed to open state database: %w", err)
+	}
+	defer state.Close()
+
+	opts := store.ReconcileOptions{Redis: redisClient, ChannelDBs: *channelDBs, DryRun: *dryRun}
+	if *legacyURL != "" {
+		legacy, err := store.Open(ctx, *legacyURL)
+		if err != nil {
+			return fmt.Errorf("failed to open legacy database: %w", err)
+		}
+		defer legacy.Close()
+		opts.Legacy = legacy
+	}
+
+	logging.Info("reconciling archive state", "dry_run", *dryRun, "channel_dbs", *channelDBs, "legacy", *legacyURL != "")
+	result, err := store.Reconcile(ctx, state, opts)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile state: %w", err)
+	}
+	logging.Info("state reconciled",
+		"dry_run", *dryRun,
+		"channels", result.Channels,
+		"playlists", result.Playlists,
+		"videos", result.Videos,
+		"sync_jobs", result.SyncJobs,
+		"comments", result.Comments,
+		"conflicts", result.Conflicts,
+	)
+	if *dryRun {
+		return nil
+	}
+
+	// Redis becomes a cache of the reconciled state
+	cached, err := store.NewRecords(state, redisClient).Warm(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to refresh cache: %w", err)
+	}
+	logging.Info("cache refreshed", "records", cached)
+	return nil
+}
+
+// connectRedis connects to Redis at an address or redis:// URL
+func connectRedis(ctx context.Context, redisURL string) (*redis.Client, error) {
+	opts := &redis.Options{Addr: redisURL, Password: os.Getenv("REDIS_PASSWORD")}
+	if strings.HasPrefix(redisURL, "redis://") {
+		parsed, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		opts = parsed
+	}
+
+	client := redis.NewClient(opts)
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,100 @@
+// Command remux backfills archived videos with embedded metadata. Each video is
+// remuxed, without re-encoding, with the title, channel, date, description,
+// chapters, thumbnail and subtitles stored next to it, so media servers such as
+// Jellyfin and Plex can read them from the file.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+func main() {
+	storagePath := flag.String("storage", getEnvWithDefault("STORAGE_PATH", "/data"), "archive storage path")
+	channelID := flag.String("channel", "", "only remux videos of this channel")
+	videoID := flag.String("video", "", "only remux this video")
+	dryRun := flag.Bool("dry-run", false, "list the videos that would be remuxed without changing them")
+	flag.Parse()
+
+	if !*dryRun && !downloader.MergerAvailable() {
+		logging.Error("ffmpeg not found, cannot remux videos")
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	channelPattern := "*"
+	if *channelID != "" {
+		channelPattern = *channelID
+	}
+	videoPattern := "*"
+	if *videoID != "" {
+		videoPattern = *videoID
+	}
+
+	videoDirs, err := filepath.Glob(filepath.Join(*storagePath, "channels", channelPattern, "videos", videoPattern))
+	if err != nil {
+		logging.Error("invalid channel or video ID", "error", err)
+		os.Exit(1)
+	}
+
+	merger := downloader.NewMerger()
+	var remuxed, skipped, failed int
+	for _, videoDir := range videoDirs {
+		if ctx.Err() != nil {
+			logging.Warn("interrupted, stopping")
+			break
+		}
+
+		videoPath := downloader.FindVideoFile(videoDir)
+		if videoPath == "" {
+			skipped++
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(videoDir, "metadata.json")); err != nil {
+			logging.Warn("skipping video without metadata.json", "path", videoPath)
+			skipped++
+			continue
+		}
+
+		if *dryRun {
+			logging.Info("would remux video", "path", videoPath)
+			remuxed++
+			continue
+		}
+
+		if _, err := downloader.EmbedArchived(ctx, merger, videoDir); err != nil {
+			logging.Error("failed to remux video", "path", videoPath, "error", err)
+			failed++
+			continue
+		}
+		logging.Info("remuxed video", "path", videoPath)
+		remuxed++
+	}
+
+	logging.Info("remux backfill complete",
+		"dry_run", *dryRun,
+		"remuxed", remuxed,
+		"skipped", skipped,
+		"failed", failed,
+	)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// getEnvWithDefault returns an environment variable or a default value
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,240 @@
+// Command reprofile re-processes archived videos under a quality profile, by
+// default the one each channel is assigned. Videos are transcoded, scaled down,
+// moved to another container or cut to their audio as the profile asks;
+// codec preferences and size caps only apply to new downloads. The video
+// records are updated with the new files and checksums so the scrubber keeps
+// verifying them, and cached HLS renditions are removed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/hls"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/profiles"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+func main() {
+	storagePath := flag.String("storage", getEnvWithDefault("STORAGE_PATH", "/data"), "archive storage path")
+	redisURL := flag.String("redis", getEnvWithDefault("REDIS_URL", "localhost:6379"), "Redis address or redis:// URL")
+	stateURL := flag.String("state", store.URLFromEnv(), "state database URL, postgres:// or sqlite://")
+	channelID := flag.String("channel", "", "only re-process videos of this channel")
+	videoID := flag.String("video", "", "only re-process this video")
+	profileName := flag.String("profile", "", "profile to apply; empty uses each channel's assigned profile")
+	dryRun := flag.Bool("dry-run", false, "list the videos that would be re-processed without changing them")
+	flag.Parse()
+
+	if !*dryRun && !downloader.MergerAvailable() {
+		logging.Error("ffmpeg not found, cannot re-process videos")
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	redisClient, err := connectRedis(ctx, *redisURL)
+	if err != nil {
+		logging.Error("failed to connect to Redis", "error", err)
+		os.Exit(1)
+	}
+	defer redisClient.Close()
+
+	state, err := store.Open(ctx, *stateURL)
+	if err != nil {
+		logging.Error("failed to open state database", "error", err)
+		os.Exit(1)
+	}
+	defer state.Close()
+
+	records := store.NewRecords(state, redisClient)
+	profileStore := profiles.NewStore(redisClient, records)
+	var override *downloader.Profile
+	if *profileName != "" {
+		override, err = profileStore.Get(ctx, *profileName)
+		if err != nil {
+			logging.Error("failed to get profile", "profile", *profileName, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	channelPattern := "*"
+	if *channelID != "" {
+		channelPattern = *channelID
+	}
+	videoPattern := "*"
+	if *videoID != "" {
+		videoPattern = *videoID
+	}
+
+	videoDirs, err := filepath.Glob(filepath.Join(*storagePath, "channels", channelPattern, "videos", videoPattern))
+	if err != nil {
+		logging.Error("invalid channel or video ID", "error", err)
+		os.Exit(1)
+	}
+
+	r := &reprofiler{
+		records:   records,
+		merger:    downloader.NewMerger(),
+		packager:  hls.NewPackager(*storagePath, nil),
+		integrity: storage.NewIntegrityChecker(storage.NewManager(*storagePath)),
+		profiles:  make(map[string]*downloader.Profile),
+	}
+
+	var reprofiled, skipped, failed int
+	for _, videoDir := range videoDirs {
+		if ctx.Err() != nil {
+			logging.Warn("interrupted, stopping")
+			break
+		}
+
+		videoPath := downloader.FindVideoFile(videoDir)
+		if videoPath == "" {
+			skipped++
+			continue
+		}
+		channel := filepath.Base(filepath.Dir(filepath.Dir(videoDir)))
+		video := filepath.Base(videoDir)
+
+		profile := override
+		if profile == nil {
+			profile, err = r.channelProfile(ctx, profileStore, channel)
+			if err != nil {
+				logging.Error("failed to get channel profile", "channel_id", channel, "error", err)
+				failed++
+				continue
+			}
+		}
+
+		if *dryRun {
+			logging.Info("would re-process video", "path", videoPath, "profile", profile.Name)
+			reprofiled++
+			continue
+		}
+
+		newPath, changed, err := downloader.ReprofileArchived(ctx, r.merger, videoDir, profile)
+		if err != nil {
+			logging.Error("failed to re-process video", "path", videoPath, "profile", profile.Name, "error", err)
+			failed++
+			continue
+		}
+		if !changed {
+			skipped++
+			continue
+		}
+		if err := r.recordFile(ctx, channel, video, newPath, profile.Name); err != nil {
+			logging.Error("failed to record re-processed video", "path", newPath, "error", err)
+			failed++
+			continue
+		}
+		logging.Info("re-processed video", "path", newPath, "profile", profile.Name)
+		reprofiled++
+	}
+
+	logging.Info("reprofile complete",
+		"dry_run", *dryRun,
+		"reprofiled", reprofiled,
+		"skipped", skipped,
+		"failed", failed,
+	)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// reprofiler records re-processed videos in the state database
+type reprofiler struct {
+	records   *store.Records
+	merger    *downloader.Merger
+	packager  *hls.Packager
+	integrity *storage.IntegrityChecker
+	profiles  map[string]*downloader.Profile // by channel ID
+}
+
+// channelProfile returns the profile assigned to a channel, looked up once per channel
+func (r *reprofiler) channelProfile(ctx context.Context, profileStore *profiles.Store, channelID string) (*downloader.Profile, error) {
+	if profile, ok := r.profiles[channelID]; ok {
+		return profile, nil
+	}
+	profile, err := profileStore.ForChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	r.profiles[channelID] = profile
+	return profile, nil
+}
+
+// recordFile updates the record of a video to its new file and checksum, and
+// removes the HLS renditions of the old file
+func (r *reprofiler) recordFile(ctx context.Context, channelID, videoID, filePath, profileName string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat video: %w", err)
+	}
+	checksum, err := r.integrity.CalculateChecksum(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	_, err = r.records.UpdateVideo(ctx, channelID, videoID, func(record map[string]interface{}) {
+		// Keep the path as the worker reported it, only renaming the file
+		if recorded, ok := record["file_path"].(string); ok && recorded != "" && !strings.Contains(recorded, "://") {
+			record["file_path"] = filepath.Join(filepath.Dir(recorded), filepath.Base(filePath))
+		}
+		record["file_size"] = info.Size()
+		record["checksum"] = checksum
+		record["profile"] = profileName
+		record["updated_at"] = time.Now()
+	})
+	// A file the archive has no record of has nothing to update
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+
+	if err := r.packager.Remove(channelID, videoID); err != nil {
+		logging.Warn("failed to remove HLS renditions", "channel_id", channelID, "video_id", videoID, "error", err)
+	}
+	return nil
+}
+
+// connectRedis connects to Redis at an address or redis:// URL
+func connectRedis(ctx context.Context, redisURL string) (*redis.Client, error) {
+	opts := &redis.Options{Addr: redisURL, Password: os.Getenv("REDIS_PASSWORD")}
+	if strings.HasPrefix(redisURL, "redis://") {
+		parsed, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		opts = parsed
+	}
+
+	client := redis.NewClient(opts)
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return client, nil
+}
+
+// getEnvWithDefault returns an environment variable or a default value
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,229 @@
+// Command sync runs the steps of a sync workflow, for the argo sync backend.
+// The workflow runs discover, then a worker per batch of discovered videos,
+// then verify and export:
+//
+//	sync discover -sync-job ID -channel ID -youtube-id ID
+//	sync verify -sync-job ID -channel ID -videos videos.json
+//	sync export -channel ID
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/scheduler"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: sync discover|verify|export [flags]")
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var err error
+	switch os.Args[1] {
+	case "discover":
+		err = discover(ctx, os.Args[2:])
+	case "verify":
+		err = verify(ctx, os.Args[2:])
+	case "export":
+		err = export(ctx, os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown step %q\n", os.Args[1])
+		os.Exit(2)
+	}
+	if err != nil {
+		logging.Error("sync step failed", "step", os.Args[1], "error", err)
+		os.Exit(1)
+	}
+}
+
+// discover saves the channel's new videos and writes the batches for the
+// download steps and the full list for the verify step
+func discover(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	redisURL := flags.String("redis", getEnvWithDefault("REDIS_URL", "localhost:6379"), "Redis address or redis:// URL")
+	stateURL := flags.String("state", store.URLFromEnv(), "state database URL, postgres:// or sqlite://")
+	syncJobID := flags.String("sync-job", "", "sync job ID")
+	channelID := flags.String("channel", "", "channel ID")
+	youtubeID := flags.String("youtube-id", "", "YouTube channel ID")
+	batchSize := flags.Int("batch-size", 10, "videos per download step")
+	batchesOutput := flags.String("batches-output", "/tmp/batches.json", "file to write the download batches to")
+	videosOutput := flags.String("videos-output", "/tmp/videos.json", "file to write the discovered video IDs to")
+	flags.Parse(args)
+
+	if *channelID == "" || *youtubeID == "" {
+		return fmt.Errorf("-channel and -youtube-id are required")
+	}
+
+	steps, closeSteps, err := openSyncSteps(ctx, *redisURL, *stateURL)
+	if err != nil {
+		return err
+	}
+	defer closeSteps()
+
+	videoIDs, err := steps.Discover(ctx, *syncJobID, *channelID, *youtubeID)
+	if err != nil {
+		return fmt.Errorf("failed to discover videos: %w", err)
+	}
+	if videoIDs == nil {
+		videoIDs = []string{}
+	}
+	batches := scheduler.SyncBatches(videoIDs, *batchSize)
+
+	if err := writeJSON(*videosOutput, videoIDs); err != nil {
+		return err
+	}
+	if err := writeJSON(*batchesOutput, batches); err != nil {
+		return err
+	}
+
+	logging.Info("discovery complete",
+		"job_id", *syncJobID,
+		"channel_id", *channelID,
+		"videos", len(videoIDs),
+		"batches", len(batches),
+	)
+	return nil
+}
+
+// verify checks the downloads of a sync. It fails if nothing was downloaded
+// but some videos failed, like a sync run by the controller.
+func verify(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	redisURL := flags.String("redis", getEnvWithDefault("REDIS_URL", "localhost:6379"), "Redis address or redis:// URL")
+	stateURL := flags.String("state", store.URLFromEnv(), "state database URL, postgres:// or sqlite://")
+	syncJobID := flags.String("sync-job", "", "sync job ID")
+	channelID := flags.String("channel", "", "channel ID")
+	videosFile := flags.String("videos", "/tmp/videos.json", "file with the video IDs written by discover")
+	output := flags.String("output", "/tmp/verify.json", "file to write the verification result to")
+	flags.Parse(args)
+
+	if *channelID == "" {
+		return fmt.Errorf("-channel is required")
+	}
+
+	data, err := os.ReadFile(*videosFile)
+	if err != nil {
+		return fmt.Errorf("failed to read video IDs: %w", err)
+	}
+	var videoIDs []string
+	if err := json.Unmarshal(data, &videoIDs); err != nil {
+		return fmt.Errorf("failed to parse video IDs: %w", err)
+	}
+
+	steps, closeSteps, err := openSyncSteps(ctx, *redisURL, *stateURL)
+	if err != nil {
+		return err
+	}
+	defer closeSteps()
+
+	result, err := steps.Verify(ctx, *syncJobID, *channelID, videoIDs)
+	if err != nil {
+		return fmt.Errorf("failed to verify downloads: %w", err)
+	}
+	if err := writeJSON(*output, result); err != nil {
+		return err
+	}
+
+	if result.Downloaded == 0 && result.Failed > 0 {
+		return fmt.Errorf("all %d videos failed", result.Failed)
+	}
+	return nil
+}
+
+// export publishes the videos archived by a sync
+func export(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	redisURL := flags.String("redis", getEnvWithDefault("REDIS_URL", "localhost:6379"), "Redis address or redis:// URL")
+	stateURL := flags.String("state", store.URLFromEnv(), "state database URL, postgres:// or sqlite://")
+	channelID := flags.String("channel", "", "channel ID")
+	flags.Parse(args)
+
+	if *channelID == "" {
+		return fmt.Errorf("-channel is required")
+	}
+
+	steps, closeSteps, err := openSyncSteps(ctx, *redisURL, *stateURL)
+	if err != nil {
+		return err
+	}
+	defer closeSteps()
+
+	return steps.Export(ctx, *channelID)
+}
+
+// openSyncSteps connects to Redis and the state database. The returned
+// function closes both.
+func openSyncSteps(ctx context.Context, redisURL, stateURL string) (*scheduler.SyncSteps, func(), error) {
+	redisClient, err := connectRedis(ctx, redisURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := store.Open(ctx, stateURL)
+	if err != nil {
+		redisClient.Close()
+		return nil, nil, fmt.Errorf("failed to open state database: %w", err)
+	}
+
+	steps := scheduler.NewSyncSteps(redisClient, store.NewRecords(state, redisClient))
+	return steps, func() {
+		state.Close()
+		redisClient.Close()
+	}, nil
+}
+
+// writeJSON writes a value to a file as JSON
+func writeJSON(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// connectRedis connects to Redis at an address or redis:// URL
+func connectRedis(ctx context.Context, redisURL string) (*redis.Client, error) {
+	opts := &redis.Options{Addr: redisURL, Password: os.Getenv("REDIS_PASSWORD")}
+	if strings.HasPrefix(redisURL, "redis://") {
+		parsed, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		opts = parsed
+	}
+
+	client := redis.NewClient(opts)
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return client, nil
+}
+
+// getEnvWithDefault returns an environment variable or a default value
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// workerTokenRenewBefore is how long before its token expires the worker gets a new one
+const workerTokenRenewBefore = time.Hour
+
+// workerToken exchanges the worker API key for a token scoped to this worker,
+// which the controller only accepts for reports on videos the worker has
+// claimed, and renews it before it expires
+type workerToken struct {
+	controllerURL string
+	apiKey        string
+	workerID      string
+	httpClient    *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newWorkerToken creates a token source for a worker API key
+func newWorkerToken(controllerURL, apiKey, workerID string) *workerToken {
+	return &workerToken{
+		controllerURL: strings.TrimSuffix(controllerURL, "/"),
+		apiKey:        apiKey,
+		workerID:      workerID,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Token returns the current worker token, getting a new one when it is close to expiring.
+// It has the signature of a downloader.TokenSource.
+func (t *workerToken) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Until(t.expires) > workerTokenRenewBefore {
+		return t.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"worker_id": t.workerID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.controllerURL+"/api/auth/worker-token", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request worker token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read worker token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("worker token request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	var issued struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(data, &issued); err != nil {
+		return "", fmt.Errorf("failed to parse worker token: %w", err)
+	}
+	t.token = issued.Token
+	t.expires = issued.ExpiresAt
+	return t.token, nil
+}
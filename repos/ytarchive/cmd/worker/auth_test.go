@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWorkerToken(t *testing.T) {
+	var requests int
+	expires := time.Now().Add(12 * time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/api/auth/worker-token" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer yta_ab12cd34_secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["worker_id"] != "worker-1" {
+			t.Errorf("worker_id = %q", body["worker_id"])
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "scoped", "expires_at": expires})
+	}))
+	defer server.Close()
+
+	source := newWorkerToken(server.URL+"/", "yta_ab12cd34_secret", "worker-1")
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if token != "scoped" {
+			t.Errorf("Token() = %q, want scoped", token)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requested %d tokens, want 1 while the token is fresh", requests)
+	}
+
+	// A token about to expire is renewed
+	expires = time.Now().Add(workerTokenRenewBefore + time.Hour)
+	source.expires = time.Now().Add(time.Minute)
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requested %d tokens, want 2 after the token neared expiry", requests)
+	}
+}
+
+func TestWorkerToken_Rejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"Invalid or expired credentials"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if _, err := newWorkerToken(server.URL, "yta_revoked_key", "worker-1").Token(); err == nil {
+		t.Error("Token() succeeded with a rejected API key")
+	}
+}
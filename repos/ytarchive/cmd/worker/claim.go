@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/queue"
+)
+
+// Reliable claiming: a claimed item moves atomically from the queue to the
+// worker's processing list, and stays there until the worker is done with it.
+// While the worker is alive it keeps its lease key fresh; a worker whose lease
+// has expired is gone, and any worker requeues the items it left behind.
+const (
+	// Per-worker lease, refreshed by the heartbeat
+	leaseKeyPrefix = "ytarchive:download:lease:"
+
+	// Set of worker IDs that may have a processing list
+	workersKey = "ytarchive:download:workers"
+
+	// Default time after the last heartbeat before a worker's claims are requeued
+	defaultLeaseTTLSeconds = 60
+
+	// How often to look for expired claims
+	reapInterval = 30 * time.Second
+)
+
+// claimer claims videos from the download queue on behalf of one worker
+type claimer struct {
+	client   *redis.Client
+	queue    *queue.PriorityQueue
+	workerID string
+	leaseTTL time.Duration
+}
+
+// newClaimer creates a claimer for a worker
+func newClaimer(client *redis.Client, workerID string, leaseTTL time.Duration) *claimer {
+	return &claimer{client: client, queue: queue.NewPriorityQueue(client), workerID: workerID, leaseTTL: leaseTTL}
+}
+
+// processingKey returns the key of a worker's processing list
+func processingKey(workerID string) string {
+	return queue.ProcessingKey(workerID)
+}
+
+// leaseKey returns the key of a worker's lease
+func leaseKey(workerID string) string {
+	return leaseKeyPrefix + workerID
+}
+
+// register takes the worker's lease and requeues anything a previous run with
+// the same worker ID left in its processing list
+func (c *claimer) register(ctx context.Context) error {
+	moved, err := c.queue.RequeueWorker(ctx, processingKey(c.workerID), workersKey, "", c.workerID)
+	if err != nil {
+		return err
+	}
+	if moved > 0 {
+		logging.Info("requeued videos claimed by a previous run", "worker_id", c.workerID, "count", moved)
+	}
+
+	if err := c.client.SAdd(ctx, workersKey, c.workerID).Err(); err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+	return c.client.Set(ctx, leaseKey(c.workerID), time.Now().Unix(), c.leaseTTL).Err()
+}
+
+// heartbeat refreshes the worker's lease until ctx is cancelled
+func (c *claimer) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(c.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := c.client.SetXX(ctx, leaseKey(c.workerID), time.Now().Unix(), c.leaseTTL).Result()
+		if err != nil {
+			logging.Warn("failed to refresh worker lease", "worker_id", c.workerID, "error", err)
+			continue
+		}
+		if !ok {
+			// The lease expired, so another worker may have requeued our claims
+			logging.Warn("worker lease expired, current video may be downloaded twice", "worker_id", c.workerID)
+			c.client.SAdd(ctx, workersKey, c.workerID)
+			c.client.Set(ctx, leaseKey(c.workerID), time.Now().Unix(), c.leaseTTL)
+		}
+	}
+}
+
+// claim atomically moves the next item from the queue to the worker's
+// processing list. It returns an empty item if the queue is empty.
+func (c *claimer) claim(ctx context.Context) (item, channelID, videoID string, err error) {
+	item, err = c.queue.Claim(ctx, processingKey(c.workerID))
+	if err != nil || item == "" {
+		return "", "", "", err
+	}
+
+	channelID, videoID, err = queue.ParseItem(item)
+	if err != nil {
+		// Drop the malformed item rather than claiming it forever
+		c.ack(ctx, item)
+		return "", "", "", err
+	}
+	return item, channelID, videoID, nil
+}
+
+// claimVideo claims a given video, for a worker downloading a batch of a sync
+// workflow. The video is taken off the queue so no other worker downloads it.
+func (c *claimer) claimVideo(ctx context.Context, channelID, videoID string) (string, error) {
+	return c.queue.ClaimVideo(ctx, processingKey(c.workerID), channelID, videoID)
+}
+
+// ack removes a finished item from the worker's processing list
+func (c *claimer) ack(ctx context.Context, item string) {
+	if err := c.client.LRem(ctx, processingKey(c.workerID), 1, item).Err(); err != nil {
+		logging.Warn("failed to remove video from processing list", "worker_id", c.workerID, "item", item, "error", err)
+	}
+}
+
+// release puts an unfinished item back at the front of the queue, so the next
+// free worker resumes it
+func (c *claimer) release(ctx context.Context, item string) error {
+	return c.queue.Release(ctx, processingKey(c.workerID), item)
+}
+
+// requeueExpired requeues the claims of workers whose lease has expired and
+// returns how many items were requeued
+func (c *claimer) requeueExpired(ctx context.Context) (int64, error) {
+	workers, err := c.client.SMembers(ctx, workersKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list workers: %w", err)
+	}
+
+	var total int64
+	for _, workerID := range workers {
+		if workerID == c.workerID {
+			continue
+		}
+		moved, err := c.queue.RequeueWorker(ctx, processingKey(workerID), workersKey, leaseKey(workerID), workerID)
+		if err != nil {
+			return total, err
+		}
+		if moved > 0 {
+			logging.Info("requeued videos of an expired worker",
+				"worker_id", c.workerID,
+				"expired_worker_id", workerID,
+				"count", moved,
+			)
+			total += moved
+		}
+	}
+	return total, nil
+}
+
+// unregister gives up the worker's lease on a clean shutdown. Items still in
+// the processing list are requeued by the next worker that looks.
+func (c *claimer) unregister(ctx context.Context) {
+	if err := c.client.Del(ctx, leaseKey(c.workerID)).Err(); err != nil {
+		logging.Warn("failed to release worker lease", "worker_id", c.workerID, "error", err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/queue"
+)
+
+func TestClaimKeys(t *testing.T) {
+	if got := processingKey("worker-1"); got != "ytarchive:download:processing:worker-1" {
+		t.Errorf("processingKey() = %q", got)
+	}
+	if got := leaseKey("worker-1"); got != "ytarchive:download:lease:worker-1" {
+		t.Errorf("leaseKey() = %q", got)
+	}
+}
+
+// newTestClaimers starts an in-memory Redis and registers a claimer for each worker ID
+func newTestClaimers(t *testing.T, workerIDs ...string) (*miniredis.Miniredis, *redis.Client, []*claimer) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	claimers := make([]*claimer, 0, len(workerIDs))
+	for _, workerID := range workerIDs {
+		c := newClaimer(client, workerID, time.Minute)
+		if err := c.register(context.Background()); err != nil {
+			t.Fatalf("register(%s) error = %v", workerID, err)
+		}
+		claimers = append(claimers, c)
+	}
+	return server, client, claimers
+}
+
+// enqueue queues videos of a channel as backfill
+func enqueue(t *testing.T, client *redis.Client, channelID string, videoIDs ...string) {
+	t.Helper()
+	if _, err := queue.NewPriorityQueue(client).Enqueue(context.Background(), channelID, videoIDs, queue.PriorityBackfill, 1); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+}
+
+// processing returns the items in a worker's processing list
+func processing(t *testing.T, client *redis.Client, workerID string) []string {
+	t.Helper()
+	items, err := client.LRange(context.Background(), processingKey(workerID), 0, -1).Result()
+	if err != nil {
+		t.Fatalf("LRange() error = %v", err)
+	}
+	return items
+}
+
+// queued returns the items in the download queue in claim order
+func queued(t *testing.T, client *redis.Client) []string {
+	t.Helper()
+	items, err := client.ZRange(context.Background(), queue.PriorityQueueKey, 0, -1).Result()
+	if err != nil {
+		t.Fatalf("ZRange() error = %v", err)
+	}
+	return items
+}
+
+func TestClaimOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	_, client, claimers := newTestClaimers(t, "worker-a", "worker-b")
+	a, b := claimers[0], claimers[1]
+	enqueue(t, client, "chan-1", "vid-1")
+
+	item, channelID, videoID, err := a.claim(ctx)
+	if err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+	if item != "chan-1:vid-1" || channelID != "chan-1" || videoID != "vid-1" {
+		t.Fatalf("claim() = %q, %q, %q", item, channelID, videoID)
+	}
+
+	item, _, _, err = b.claim(ctx)
+	if err != nil || item != "" {
+		t.Errorf("second claim() = %q, %v, want an empty queue", item, err)
+	}
+	if got := processing(t, client, "worker-a"); len(got) != 1 || got[0] != "chan-1:vid-1" {
+		t.Errorf("worker-a processing = %v", got)
+	}
+	if got := processing(t, client, "worker-b"); len(got) != 0 {
+		t.Errorf("worker-b processing = %v, want empty", got)
+	}
+	if got := queued(t, client); len(got) != 0 {
+		t.Errorf("queue = %v, want empty", got)
+	}
+}
+
+func TestRequeueExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	server, client, claimers := newTestClaimers(t, "worker-a", "worker-b")
+	a, b := claimers[0], claimers[1]
+	enqueue(t, client, "chan-1", "vid-1", "vid-2")
+
+	item, _, _, err := a.claim(ctx)
+	if err != nil || item != "chan-1:vid-1" {
+		t.Fatalf("claim() = %q, %v", item, err)
+	}
+
+	// worker-a holds its lease, so its claim stays put
+	moved, err := b.requeueExpired(ctx)
+	if err != nil || moved != 0 {
+		t.Fatalf("requeueExpired() with a live lease = %d, %v, want 0", moved, err)
+	}
+	if got := processing(t, client, "worker-a"); len(got) != 1 {
+		t.Fatalf("worker-a processing = %v", got)
+	}
+
+	// worker-a stops renewing its lease
+	server.FastForward(2 * time.Minute)
+	moved, err = b.requeueExpired(ctx)
+	if err != nil || moved != 1 {
+		t.Fatalf("requeueExpired() after expiry = %d, %v, want 1", moved, err)
+	}
+	if got := processing(t, client, "worker-a"); len(got) != 0 {
+		t.Errorf("worker-a processing = %v, want empty", got)
+	}
+	if member, _ := client.SIsMember(ctx, workersKey, "worker-a").Result(); member {
+		t.Error("expired worker-a is still registered")
+	}
+
+	// The requeued video goes to the front, ahead of videos never claimed
+	if got := queued(t, client); len(got) != 2 || got[0] != "chan-1:vid-1" {
+		t.Errorf("queue = %v, want chan-1:vid-1 first", got)
+	}
+	item, _, _, err = b.claim(ctx)
+	if err != nil || item != "chan-1:vid-1" {
+		t.Errorf("claim() after requeue = %q, %v, want chan-1:vid-1", item, err)
+	}
+}
+
+func TestRegisterRequeuesPreviousRun(t *testing.T) {
+	ctx := context.Background()
+	_, client, claimers := newTestClaimers(t, "worker-a")
+	enqueue(t, client, "chan-1", "vid-1")
+	if item, _, _, err := claimers[0].claim(ctx); err != nil || item == "" {
+		t.Fatalf("claim() = %q, %v", item, err)
+	}
+
+	// A restart with the same worker ID does not wait for the lease to expire
+	restarted := newClaimer(client, "worker-a", time.Minute)
+	if err := restarted.register(ctx); err != nil {
+		t.Fatalf("register() error = %v", err)
+	}
+	if got := queued(t, client); len(got) != 1 || got[0] != "chan-1:vid-1" {
+		t.Errorf("queue = %v, want chan-1:vid-1", got)
+	}
+	if got := processing(t, client, "worker-a"); len(got) != 0 {
+		t.Errorf("processing = %v, want empty", got)
+	}
+}
+
+func TestReleaseByOwnerOnly(t *testing.T) {
+	ctx := context.Background()
+	_, client, claimers := newTestClaimers(t, "worker-a", "worker-b")
+	a, b := claimers[0], claimers[1]
+	enqueue(t, client, "chan-1", "vid-1", "vid-2")
+
+	item, _, _, err := a.claim(ctx)
+	if err != nil || item != "chan-1:vid-1" {
+		t.Fatalf("claim() = %q, %v", item, err)
+	}
+
+	if err := b.release(ctx, item); !errors.Is(err, queue.ErrNotClaimed) {
+		t.Fatalf("release() by another worker error = %v, want ErrNotClaimed", err)
+	}
+	if got := processing(t, client, "worker-a"); len(got) != 1 || got[0] != item {
+		t.Errorf("worker-a processing = %v after release by worker-b", got)
+	}
+	if got := queued(t, client); len(got) != 1 || got[0] != "chan-1:vid-2" {
+		t.Errorf("queue = %v after release by worker-b, want only chan-1:vid-2", got)
+	}
+
+	if err := a.release(ctx, item); err != nil {
+		t.Fatalf("release() by owner error = %v", err)
+	}
+	if got := processing(t, client, "worker-a"); len(got) != 0 {
+		t.Errorf("worker-a processing = %v, want empty", got)
+	}
+	if got := queued(t, client); len(got) != 2 || got[0] != item {
+		t.Errorf("queue = %v, want %s first", got, item)
+	}
+
+	// Releasing twice does not queue the video twice
+	if err := a.release(ctx, item); !errors.Is(err, queue.ErrNotClaimed) {
+		t.Errorf("second release() error = %v, want ErrNotClaimed", err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/credentials"
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// defaultCredentialCooldownMinutes is how long a credential set YouTube refused is left out of rotation
+const defaultCredentialCooldownMinutes = 30
+
+// credentialRotator chooses the cookies the worker downloads with from the
+// credential pool, and moves on to another set when YouTube refuses one
+type credentialRotator struct {
+	pool     *credentials.Pool
+	ytClient *youtube.Client
+	dl       *downloader.Downloader
+	workerID string
+	cooldown time.Duration
+	fallback []*http.Cookie // from YOUTUBE_COOKIES_FILE or YOUTUBE_COOKIES, used when no set is usable
+
+	current   string    // set in use, empty while using the fallback cookies
+	updatedAt time.Time // when the set in use was last changed, to notice new cookies
+	tried     []string  // sets YouTube refused for the current video
+}
+
+// newCredentialRotator creates a rotator that starts out with the fallback cookies
+func newCredentialRotator(pool *credentials.Pool, ytClient *youtube.Client, dl *downloader.Downloader, workerID string, cooldown time.Duration, fallback []*http.Cookie) *credentialRotator {
+	r := &credentialRotator{
+		pool:     pool,
+		ytClient: ytClient,
+		dl:       dl,
+		workerID: workerID,
+		cooldown: cooldown,
+		fallback: fallback,
+	}
+	r.apply(fallback)
+	return r
+}
+
+// begin picks the cookies for the next video. The set in use is kept while it
+// stays usable, so an account is not switched for every video; otherwise the
+// set that has gone unused longest is taken.
+func (r *credentialRotator) begin(ctx context.Context) {
+	r.tried = nil
+
+	if r.current != "" {
+		set, err := r.pool.Get(ctx, r.current)
+		if err != nil && !errors.Is(err, credentials.ErrNotFound) {
+			logging.Warn("failed to get credential set, keeping its cookies",
+				"worker_id", r.workerID,
+				"credential_set", r.current,
+				"error", err,
+			)
+			return
+		}
+		if err == nil && set.Usable(time.Now()) {
+			if !set.UpdatedAt.Equal(r.updatedAt) {
+				r.use(set)
+			}
+			return
+		}
+	}
+
+	set, err := r.pool.Acquire(ctx)
+	if err != nil {
+		if !errors.Is(err, credentials.ErrNoCredentials) {
+			logging.Warn("failed to acquire credential set", "worker_id", r.workerID, "error", err)
+		}
+		r.useFallback()
+		return
+	}
+	r.use(set)
+}
+
+// recordUse counts a video against the set in use
+func (r *credentialRotator) recordUse(ctx context.Context) {
+	if r.current == "" {
+		return
+	}
+	if err := r.pool.RecordUse(ctx, r.current); err != nil {
+		logging.Warn("failed to record credential use",
+			"worker_id", r.workerID,
+			"credential_set", r.current,
+			"error", err,
+		)
+	}
+}
+
+// rotate is called when YouTube refused the cookies in use. The set in use is
+// put on cooldown and checked, which quarantines it if it is signed out, and
+// the worker switches to a set not yet tried for this video. It reports
+// whether there is another set to retry with.
+func (r *credentialRotator) rotate(ctx context.Context, cause error) bool {
+	if r.current == "" {
+		return false
+	}
+	failed := r.current
+	r.tried = append(r.tried, failed)
+
+	if err := r.pool.RecordFailure(ctx, failed, cause, r.cooldown); err != nil {
+		logging.Warn("failed to record credential failure",
+			"worker_id", r.workerID,
+			"credential_set", failed,
+			"error", err,
+		)
+	}
+	if set, err := r.pool.Check(ctx, failed); err != nil {
+		logging.Warn("failed to check credential set",
+			"worker_id", r.workerID,
+			"credential_set", failed,
+			"error", err,
+		)
+	} else if set.State == credentials.StateQuarantined {
+		logging.Warn("credential set is signed out, quarantined",
+			"worker_id", r.workerID,
+			"credential_set", failed,
+			"reason", set.Reason,
+		)
+	}
+
+	next, err := r.pool.Acquire(ctx, r.tried...)
+	if err != nil {
+		logging.Warn("no other credential set to rotate to",
+			"worker_id", r.workerID,
+			"credential_set", failed,
+			"error", err,
+		)
+		r.useFallback()
+		return false
+	}
+	if !r.use(next) {
+		return false
+	}
+	logging.Info("rotated credential set",
+		"worker_id", r.workerID,
+		"from", failed,
+		"to", next.Name,
+		"cause", cause,
+	)
+	return true
+}
+
+// use switches to the cookies of a set
+func (r *credentialRotator) use(set *credentials.Set) bool {
+	cookies, err := set.ParsedCookies()
+	if err != nil {
+		logging.Warn("failed to parse credential set",
+			"worker_id", r.workerID,
+			"credential_set", set.Name,
+			"error", err,
+		)
+		r.tried = append(r.tried, set.Name)
+		return false
+	}
+	r.apply(cookies)
+	r.current = set.Name
+	r.updatedAt = set.UpdatedAt
+	logging.Info("using credential set",
+		"worker_id", r.workerID,
+		"credential_set", set.Name,
+		"cookie_count", len(cookies),
+	)
+	return true
+}
+
+// useFallback switches to the fallback cookies
+func (r *credentialRotator) useFallback() {
+	if r.current == "" {
+		return
+	}
+	logging.Warn("no usable credential set, using fallback cookies",
+		"worker_id", r.workerID,
+		"has_cookies", len(r.fallback) > 0,
+	)
+	r.apply(r.fallback)
+	r.current = ""
+	r.updatedAt = time.Time{}
+}
+
+// apply sets the cookies of the YouTube client and the downloader
+func (r *credentialRotator) apply(cookies []*http.Cookie) {
+	r.ytClient.SetCookies(cookies)
+	r.dl.SetCookies(youtube.GetCookieHeader(cookies))
+}
+
+// refusedCredentials reports whether an error means YouTube refused the
+// cookies: 403 Forbidden from the player or a stream, or a request to sign in
+func refusedCredentials(err error) bool {
+	var statusErr *downloader.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusForbidden
+	}
+	return youtube.IsCredentialError(err)
+}
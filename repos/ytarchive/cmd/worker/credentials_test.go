@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+func TestRefusedCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"stream forbidden", fmt.Errorf("all 3 download attempts failed: %w", &downloader.StatusError{StatusCode: http.StatusForbidden}), true},
+		{"stream gone", &downloader.StatusError{StatusCode: http.StatusGone}, false},
+		{"player forbidden", &youtube.StatusError{StatusCode: http.StatusForbidden}, true},
+		{"bot check", &youtube.PlayabilityError{Status: "LOGIN_REQUIRED", Reason: "Sign in to confirm you're not a bot"}, true},
+		{"unavailable", &youtube.PlayabilityError{Status: "ERROR", Reason: "Video unavailable"}, false},
+		{"network", errors.New("request failed: EOF"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refusedCredentials(tt.err); got != tt.want {
+				t.Errorf("refusedCredentials(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -18,15 +19,16 @@ import (
 
 	"github.com/go-redis/redis/v8"
 
+	"github.com/timholm/ytarchive/internal/credentials"
 	"github.com/timholm/ytarchive/internal/downloader"
 	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/queue"
+	"github.com/timholm/ytarchive/internal/ratelimit"
+	"github.com/timholm/ytarchive/internal/sponsorblock"
 	"github.com/timholm/ytarchive/internal/youtube"
 )
 
 const (
-	// Unified queue key for all channels - KEDA monitors this
-	unifiedQueueKey = "ytarchive:download:queue"
-
 	// How long to wait when queue is empty before checking again
 	emptyQueueWaitTime = 5 * time.Second
 
@@ -44,6 +46,12 @@ type WorkerConfig struct {
 	WorkerID      string
 	ControllerURL string
 	CollectorURL  string
+	APIKey        string // worker API key, for controllers with authentication enabled
+
+	// A worker run by a sync workflow downloads the videos of one batch of a
+	// channel and exits, rather than claiming from the queue
+	ChannelID string
+	VideoIDs  []string
 }
 
 // healthStatus tracks worker health for readiness probes
@@ -99,70 +107,71 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigChan
-		logging.Info("received shutdown signal, finishing current video...", "signal", sig.String())
+		logging.Info("received shutdown signal, requeueing current video...", "signal", sig.String())
 		cancel()
 	}()
 
-	// Create HTTP progress reporter (for controller API)
+	// Create HTTP progress reporter (for controller API), and look videos up there
 	var reporter *downloader.ProgressReporter
+	var videos *videoClient
 	if config.ControllerURL != "" {
+		var token downloader.TokenSource
+		if config.APIKey != "" {
+			token = newWorkerToken(config.ControllerURL, config.APIKey, config.WorkerID).Token
+		}
 		reporter = downloader.NewProgressReporter(config.ControllerURL, config.WorkerID)
+		reporter.SetTokenSource(token)
+		videos = newVideoClient(config.ControllerURL, token)
 	}
 
 	// Create Redis progress reporter (for UI polling)
 	redisProgressReporter := downloader.NewRedisProgressReporter(redisClient, config.WorkerID)
 	logging.Info("Redis progress reporter initialized")
 
-	// Load cookies for authenticated requests (bypass bot detection)
-	var ytClientOpts []youtube.ClientOption
-	var cookieHeader string
+	// Cookies for authenticated requests (bypass bot detection) come from the
+	// credential pool managed in the web UI. A cookies file or environment
+	// variable is used while no set in the pool is usable.
+	var fallbackCookies []*http.Cookie
 
-	// Check for cookies in Redis first (set via web UI)
-	redisCookies, err := redisClient.Get(ctx, "config:cookies").Result()
-	if err == nil && redisCookies != "" {
-		cookies, err := youtube.LoadCookiesFromString(redisCookies)
+	if cookiesFile := os.Getenv("YOUTUBE_COOKIES_FILE"); cookiesFile != "" {
+		cookies, err := youtube.LoadCookiesFromFile(cookiesFile)
 		if err != nil {
-			logging.Warn("failed to parse cookies from Redis", "error", err)
+			logging.Warn("failed to load cookies from file", "path", cookiesFile, "error", err)
 		} else if len(cookies) > 0 {
-			ytClientOpts = append(ytClientOpts, youtube.WithCookies(cookies))
-			cookieHeader = youtube.GetCookieHeader(cookies)
-			logging.Info("loaded cookies from Redis", "cookie_count", len(cookies))
-		}
-	}
-
-	// Fallback: Check for cookies file path
-	if cookieHeader == "" {
-		if cookiesFile := os.Getenv("YOUTUBE_COOKIES_FILE"); cookiesFile != "" {
-			cookies, err := youtube.LoadCookiesFromFile(cookiesFile)
-			if err != nil {
-				logging.Warn("failed to load cookies from file", "path", cookiesFile, "error", err)
-			} else if len(cookies) > 0 {
-				ytClientOpts = append(ytClientOpts, youtube.WithCookies(cookies))
-				cookieHeader = youtube.GetCookieHeader(cookies)
-				logging.Info("loaded cookies from file", "path", cookiesFile, "cookie_count", len(cookies))
-			}
+			fallbackCookies = cookies
+			logging.Info("loaded fallback cookies from file", "path", cookiesFile, "cookie_count", len(cookies))
 		}
 	}
 
 	// Fallback: Check for cookies in environment variable (Netscape format)
-	if cookiesEnv := os.Getenv("YOUTUBE_COOKIES"); cookiesEnv != "" && cookieHeader == "" {
+	if cookiesEnv := os.Getenv("YOUTUBE_COOKIES"); cookiesEnv != "" && len(fallbackCookies) == 0 {
 		cookies, err := youtube.LoadCookiesFromString(cookiesEnv)
 		if err != nil {
 			logging.Warn("failed to load cookies from environment", "error", err)
 		} else if len(cookies) > 0 {
-			ytClientOpts = append(ytClientOpts, youtube.WithCookies(cookies))
-			cookieHeader = youtube.GetCookieHeader(cookies)
-			logging.Info("loaded cookies from environment", "cookie_count", len(cookies))
+			fallbackCookies = cookies
+			logging.Info("loaded fallback cookies from environment", "cookie_count", len(cookies))
 		}
 	}
 
+	// The single cookie blob of earlier versions becomes the pool's default set
+	credentialPool := credentials.NewPool(redisClient)
+	if migrated, err := credentialPool.MigrateLegacy(ctx); err != nil {
+		logging.Warn("failed to migrate cookies to the credential pool", "error", err)
+	} else if migrated {
+		logging.Info("moved cookies into the credential pool", "credential_set", credentials.DefaultSetName)
+	}
+
+	// Rate limits are shared with the controller and every other worker
+	limiter := ratelimit.NewShared(redisClient, ratelimit.SharedConfigFromEnv())
+
 	// Create YouTube client for fetching stream URLs
-	ytClient, err := youtube.NewClient(ytClientOpts...)
+	ytClient, err := youtube.NewClient(youtube.WithRateLimiter(limiter))
 	if err != nil {
 		logging.Error("failed to create YouTube client", "error", err)
 		os.Exit(1)
 	}
-	logging.Info("YouTube client initialized", "has_cookies", cookieHeader != "")
+	logging.Info("YouTube client initialized")
 
 	// Create downloader with default config
 	dlConfig := downloader.DefaultConfig(config.StoragePath)
@@ -175,15 +184,39 @@ func main() {
 		logging.Info("ffmpeg not available, limiting to combined streams (max 720p)")
 	}
 
+	if err := dlConfig.Validate(); err != nil {
+		logging.Error("invalid downloader configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Load the local SponsorBlock database, if one is configured
+	segments := &segmentSource{categories: dlConfig.SponsorBlockCategories}
+	if dlConfig.SponsorBlockDB != "" {
+		sponsorDB, err := sponsorblock.Load(dlConfig.SponsorBlockDB)
+		if err != nil {
+			logging.Warn("failed to load SponsorBlock database, continuing without segments",
+				"path", dlConfig.SponsorBlockDB,
+				"error", err,
+			)
+		} else {
+			segments.db = sponsorDB
+			logging.Info("SponsorBlock database loaded",
+				"path", dlConfig.SponsorBlockDB,
+				"mode", dlConfig.SegmentMode,
+				"categories", strings.Join(dlConfig.SponsorBlockCategories, ","),
+			)
+		}
+	}
+
 	dl := downloader.NewDownloader(dlConfig, reporter, config.WorkerID)
 
 	// Set Redis progress reporter for UI polling
 	dl.SetRedisReporter(redisProgressReporter)
+	dl.SetRateLimiter(limiter)
 
-	// Pass cookies to downloader
-	if cookieHeader != "" {
-		dl.SetCookies(cookieHeader)
-	}
+	// Pass cookies to the YouTube client and downloader, rotating between credential sets
+	cooldown := time.Duration(getEnvInt("CREDENTIAL_COOLDOWN_MINUTES", defaultCredentialCooldownMinutes)) * time.Minute
+	creds := newCredentialRotator(credentialPool, ytClient, dl, config.WorkerID, cooldown, fallbackCookies)
 
 	// Mark worker as ready
 	health.ready.Store(true)
@@ -197,7 +230,25 @@ func main() {
 	downloadDelay := time.Duration(getEnvInt("DOWNLOAD_DELAY_SECONDS", defaultDownloadDelaySeconds)) * time.Second
 	logging.Info("download delay configured", "delay", downloadDelay)
 
-	// Main processing loop - runs continuously until shutdown
+	// Claims are held under a lease that the heartbeat keeps fresh until the worker exits
+	leaseTTL := time.Duration(getEnvInt("LEASE_TTL_SECONDS", defaultLeaseTTLSeconds)) * time.Second
+	claims := newClaimer(redisClient, config.WorkerID, leaseTTL)
+	if err := claims.register(ctx); err != nil {
+		logging.Error("failed to register worker", "error", err)
+		os.Exit(1)
+	}
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	defer stopHeartbeat()
+	go claims.heartbeat(heartbeatCtx)
+	logging.Info("worker registered", "worker_id", config.WorkerID, "lease_ttl", leaseTTL)
+
+	var lastReap time.Time
+	batchMode := len(config.VideoIDs) > 0
+	if batchMode {
+		logging.Info("downloading sync workflow batch", "channel_id", config.ChannelID, "videos", len(config.VideoIDs))
+	}
+
+	// Main processing loop - runs continuously until shutdown, or through the batch
 	for {
 		// Check if context is cancelled (shutdown requested)
 		if ctx.Err() != nil {
@@ -205,8 +256,39 @@ func main() {
 			break
 		}
 
-		// Claim ONE video from the unified queue
-		channelID, videoID, err := claimOneVideo(ctx, redisClient)
+		// Put the claims of workers that died back on the queue
+		if time.Since(lastReap) >= reapInterval {
+			if _, err := claims.requeueExpired(ctx); err != nil {
+				logging.Warn("failed to requeue expired claims", "error", err)
+			}
+			lastReap = time.Now()
+		}
+
+		var item, channelID, videoID string
+		if batchMode {
+			if len(config.VideoIDs) == 0 {
+				break
+			}
+			channelID, videoID = config.ChannelID, config.VideoIDs[0]
+			config.VideoIDs = config.VideoIDs[1:]
+
+			// A retried step only downloads what the previous attempt did not
+			if info, err := fetchVideoInfo(ctx, videos, channelID, videoID); err == nil &&
+				(info.Status == "downloaded" || info.Status == "completed") {
+				logging.Info("video already downloaded, skipping", "channel_id", channelID, "video_id", videoID)
+				continue
+			}
+
+			item, err = claims.claimVideo(ctx, channelID, videoID)
+			if err != nil {
+				logging.Error("failed to claim video", "channel_id", channelID, "video_id", videoID, "error", err)
+				failCount++
+				continue
+			}
+		} else {
+			// Claim ONE video from the unified queue
+			item, channelID, videoID, err = claims.claim(ctx)
+		}
 		if err != nil {
 			if ctx.Err() != nil {
 				break // Context cancelled during claim
@@ -230,9 +312,49 @@ func main() {
 		)
 
 		// Process the video
-		success := processVideo(ctx, config, redisClient, ytClient, dl, reporter, channelID, videoID)
+		creds.begin(ctx)
+		started := time.Now()
+		success := processVideo(ctx, config, videos, ytClient, dl, reporter, segments, creds, channelID, videoID)
+
+		// An interrupted video goes back on the queue; its partial files are kept for resuming.
+		// A batch is retried by its workflow instead.
+		if !success && ctx.Err() != nil && batchMode {
+			claims.ack(context.Background(), item)
+			failCount++
+			break
+		}
+		if !success && ctx.Err() != nil {
+			releaseCtx, cancelRelease := context.WithTimeout(context.Background(), 5*time.Second)
+			err := claims.release(releaseCtx, item)
+			if errors.Is(err, queue.ErrNotClaimed) {
+				logging.Warn("interrupted video was already requeued by another worker",
+					"worker_id", config.WorkerID,
+					"video_id", videoID,
+				)
+			} else if err != nil {
+				logging.Error("failed to requeue interrupted video, it will be requeued when the lease expires",
+					"worker_id", config.WorkerID,
+					"video_id", videoID,
+					"error", err,
+				)
+			} else {
+				logging.Info("requeued interrupted video",
+					"worker_id", config.WorkerID,
+					"video_id", videoID,
+				)
+			}
+			cancelRelease()
+			break
+		}
+		claims.ack(ctx, item)
+
 		if success {
 			successCount++
+
+			// The controller sizes the worker pool by how long downloads take
+			if err := claims.queue.RecordDownloadTime(ctx, time.Since(started)); err != nil {
+				logging.Warn("failed to record download time", "error", err)
+			}
 		} else {
 			failCount++
 		}
@@ -253,15 +375,25 @@ func main() {
 		}
 	}
 
+	stopHeartbeat()
+	unregisterCtx, cancelUnregister := context.WithTimeout(context.Background(), 5*time.Second)
+	claims.unregister(unregisterCtx)
+	cancelUnregister()
+
 	// Report final statistics
 	logging.Info("worker shutting down",
 		"worker_id", config.WorkerID,
 		"success_count", successCount,
 		"failed_count", failCount,
 	)
+
+	// A failed batch fails its workflow step, which Argo retries
+	if batchMode && failCount > 0 {
+		os.Exit(1)
+	}
 }
 
-// VideoInfo holds video metadata from Redis
+// VideoInfo holds the record of a video, as the controller returns it
 type VideoInfo struct {
 	ID            string `json:"id"`
 	YouTubeID     string `json:"youtube_id"`
@@ -278,7 +410,7 @@ type VideoInfo struct {
 }
 
 // processVideo downloads a single video and returns success/failure
-func processVideo(ctx context.Context, config *WorkerConfig, redisClient *redis.Client, ytClient *youtube.Client, dl *downloader.Downloader, reporter *downloader.ProgressReporter, channelID, videoID string) bool {
+func processVideo(ctx context.Context, config *WorkerConfig, videos *videoClient, ytClient *youtube.Client, dl *downloader.Downloader, reporter *downloader.ProgressReporter, segments *segmentSource, creds *credentialRotator, channelID, videoID string) bool {
 	// Report download starting
 	if reporter != nil {
 		status := &downloader.VideoStatus{
@@ -294,23 +426,24 @@ func processVideo(ctx context.Context, config *WorkerConfig, redisClient *redis.
 		}
 	}
 
-	// Fetch video info from Redis (for episode number and channel name)
+	// Fetch video info from the controller (for episode number, channel name and profile)
 	var videoInfo *VideoInfo
-	if redisClient != nil {
-		info, err := fetchVideoInfo(ctx, redisClient, channelID, videoID)
+	var profile *downloader.Profile
+	if videos != nil {
+		info, channelProfile, err := videos.fetch(ctx, channelID, videoID)
 		if err != nil {
-			logging.Warn("failed to fetch video info from Redis, will use defaults",
+			logging.Warn("failed to fetch video info from the controller, will use defaults",
 				"worker_id", config.WorkerID,
 				"video_id", videoID,
 				"error", err,
 			)
 		} else {
-			videoInfo = info
+			videoInfo, profile = info, channelProfile
 		}
 	}
 
 	// Fetch stream URLs from YouTube
-	streamInfo, err := ytClient.GetStreamURLContext(ctx, videoID)
+	streamInfo, err := fetchStreamInfo(ctx, ytClient, creds, videoID)
 	if err != nil {
 		logging.Error("failed to fetch stream info",
 			"worker_id", config.WorkerID,
@@ -374,9 +507,45 @@ func processVideo(ctx context.Context, config *WorkerConfig, redisClient *redis.
 		req.ViewCount = videoInfo.ViewCount
 	}
 
+	// Chapters and subtitles are added to the file when available; the download goes ahead without them
+	metadata, err := ytClient.GetVideoMetadataContext(ctx, videoID)
+	if err != nil {
+		logging.Warn("failed to fetch video metadata, downloading without chapters or subtitles",
+			"worker_id", config.WorkerID,
+			"video_id", videoID,
+			"error", err,
+		)
+	} else {
+		req.Chapters = convertChapters(metadata.Chapters)
+		req.Captions = convertCaptions(metadata.Captions)
+		if req.Duration == 0 {
+			req.Duration = metadata.Duration
+		}
+	}
+	req.Segments = segments.segments(videoID)
+
+	// The channel's quality profile decides which streams are kept and how they are processed
+	req.Profile = profile
+
 	// Download the video
 	result := dl.Download(ctx, req)
 
+	// Stream URLs only work with the cookies they were fetched with; when a
+	// stream is refused, fetch them again with the next credential set
+	for !result.Success && ctx.Err() == nil && refusedCredentials(result.Error) && creds.rotate(ctx, result.Error) {
+		logging.Warn("stream refused, retrying with another credential set",
+			"worker_id", config.WorkerID,
+			"video_id", videoID,
+			"error", result.Error,
+		)
+		retryInfo, err := fetchStreamInfo(ctx, ytClient, creds, videoID)
+		if err != nil {
+			break
+		}
+		req.Streams = convertFormatsToStreams(retryInfo.Formats)
+		result = dl.Download(ctx, req)
+	}
+
 	if result.Success {
 		logging.Info("successfully downloaded video",
 			"worker_id", config.WorkerID,
@@ -428,6 +597,14 @@ func processVideo(ctx context.Context, config *WorkerConfig, redisClient *redis.
 					}
 				}
 			}
+			if uploadErr != nil && ctx.Err() != nil {
+				// Interrupted by shutdown; the finished download is kept and uploaded on resume
+				logging.Info("upload interrupted, keeping downloaded video",
+					"worker_id", config.WorkerID,
+					"video_id", videoID,
+				)
+				return false
+			}
 			if uploadErr != nil {
 				logging.Error("failed to upload to collector after retries",
 					"worker_id", config.WorkerID,
@@ -474,6 +651,16 @@ func processVideo(ctx context.Context, config *WorkerConfig, redisClient *redis.
 		return true
 	}
 
+	if ctx.Err() != nil {
+		// Interrupted by shutdown; the partial files are kept so the download can resume
+		logging.Info("download interrupted, keeping partial files",
+			"worker_id", config.WorkerID,
+			"channel_id", channelID,
+			"video_id", videoID,
+		)
+		return false
+	}
+
 	logging.Error("failed to download video",
 		"worker_id", config.WorkerID,
 		"channel_id", channelID,
@@ -510,6 +697,21 @@ func processVideo(ctx context.Context, config *WorkerConfig, redisClient *redis.
 	return false
 }
 
+// fetchStreamInfo fetches the stream URLs of a video, moving on to the next
+// credential set for as long as YouTube refuses the cookies in use
+func fetchStreamInfo(ctx context.Context, ytClient *youtube.Client, creds *credentialRotator, videoID string) (*youtube.StreamInfo, error) {
+	for {
+		streamInfo, err := ytClient.GetStreamURLContext(ctx, videoID)
+		if err == nil {
+			creds.recordUse(ctx)
+			return streamInfo, nil
+		}
+		if ctx.Err() != nil || !refusedCredentials(err) || !creds.rotate(ctx, err) {
+			return nil, err
+		}
+	}
+}
+
 // startHealthServer starts an HTTP server for health checks
 func startHealthServer(health *healthStatus) {
 	mux := http.NewServeMux()
@@ -557,6 +759,17 @@ func loadConfig() (*WorkerConfig, error) {
 		WorkerID:      os.Getenv("WORKER_ID"),
 		ControllerURL: os.Getenv("CONTROLLER_URL"),
 		CollectorURL:  os.Getenv("COLLECTOR_URL"),
+		APIKey:        os.Getenv("WORKER_API_KEY"),
+		ChannelID:     os.Getenv("CHANNEL_ID"),
+	}
+
+	for _, videoID := range strings.Split(os.Getenv("VIDEO_IDS"), ",") {
+		if videoID = strings.TrimSpace(videoID); videoID != "" {
+			config.VideoIDs = append(config.VideoIDs, videoID)
+		}
+	}
+	if len(config.VideoIDs) > 0 && config.ChannelID == "" {
+		return nil, fmt.Errorf("VIDEO_IDS needs CHANNEL_ID")
 	}
 
 	// Validate required fields
@@ -622,42 +835,13 @@ func connectRedis(redisURL string) (*redis.Client, error) {
 	return client, nil
 }
 
-// claimOneVideo atomically claims a single video from the unified queue using RPOP.
-// Queue items are formatted as "channelID:videoID".
-// Returns the channelID and videoID parsed from the queue item.
-func claimOneVideo(ctx context.Context, client *redis.Client) (channelID, videoID string, err error) {
-	queueItem, err := client.RPop(ctx, unifiedQueueKey).Result()
-	if err == redis.Nil {
-		// Queue is empty
-		return "", "", nil
+// fetchVideoInfo retrieves video metadata from the controller
+func fetchVideoInfo(ctx context.Context, videos *videoClient, channelID, videoID string) (*VideoInfo, error) {
+	if videos == nil {
+		return nil, fmt.Errorf("no controller to fetch video info from")
 	}
-	if err != nil {
-		return "", "", fmt.Errorf("failed to pop from queue: %w", err)
-	}
-
-	// Parse queue item format: "channelID:videoID"
-	parts := strings.SplitN(queueItem, ":", 2)
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid queue item format: %s (expected channelID:videoID)", queueItem)
-	}
-
-	return parts[0], parts[1], nil
-}
-
-// fetchVideoInfo retrieves video metadata from Redis
-func fetchVideoInfo(ctx context.Context, client *redis.Client, channelID, videoID string) (*VideoInfo, error) {
-	videoKey := "video:" + channelID + ":" + videoID
-	data, err := client.Get(ctx, videoKey).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch video info: %w", err)
-	}
-
-	var info VideoInfo
-	if err := json.Unmarshal([]byte(data), &info); err != nil {
-		return nil, fmt.Errorf("failed to parse video info: %w", err)
-	}
-
-	return &info, nil
+	info, _, err := videos.fetch(ctx, channelID, videoID)
+	return info, err
 }
 
 // UploadMetadata contains metadata for uploading a video to the collector
@@ -714,6 +898,20 @@ func uploadToCollector(ctx context.Context, collectorURL, filePath string, metad
 			return
 		}
 
+		// Add metadata.json (chapters, segments) so it is stored alongside the video
+		infoPath := filepath.Join(filepath.Dir(filePath), "metadata.json")
+		if info, err := os.ReadFile(infoPath); err == nil {
+			infoPart, err := writer.CreateFormFile("info", "metadata.json")
+			if err != nil {
+				errChan <- fmt.Errorf("failed to create info field: %w", err)
+				return
+			}
+			if _, err := infoPart.Write(info); err != nil {
+				errChan <- fmt.Errorf("failed to write info field: %w", err)
+				return
+			}
+		}
+
 		// Add file part - streams directly from file
 		part, err := writer.CreateFormFile("video", filepath.Base(filePath))
 		if err != nil {
@@ -768,6 +966,59 @@ func uploadToCollector(ctx context.Context, collectorURL, filePath string, metad
 	return nil
 }
 
+// segmentSource looks up the SponsorBlock segments of videos
+type segmentSource struct {
+	db         *sponsorblock.DB
+	categories []string
+}
+
+// segments returns the configured categories of SponsorBlock segments for a video
+func (s *segmentSource) segments(videoID string) []downloader.Segment {
+	if s == nil || s.db == nil {
+		return nil
+	}
+
+	var segments []downloader.Segment
+	for _, segment := range s.db.Segments(videoID, s.categories) {
+		segments = append(segments, downloader.Segment{
+			Start:    segment.Start(),
+			End:      segment.End(),
+			Category: segment.Category,
+		})
+	}
+	return segments
+}
+
+// convertChapters converts YouTube chapters to downloader chapters
+func convertChapters(chapters []youtube.Chapter) []downloader.Chapter {
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	converted := make([]downloader.Chapter, 0, len(chapters))
+	for _, c := range chapters {
+		converted = append(converted, downloader.Chapter{
+			Title: c.Title,
+			Start: c.StartTime,
+			End:   c.EndTime,
+		})
+	}
+	return converted
+}
+
+// convertCaptions converts YouTube caption tracks to downloader captions
+func convertCaptions(captions []youtube.CaptionInfo) []downloader.Caption {
+	converted := make([]downloader.Caption, 0, len(captions))
+	for _, c := range captions {
+		converted = append(converted, downloader.Caption{
+			Language:  c.LanguageCode,
+			URL:       c.BaseURL,
+			Automatic: c.IsAutomatic,
+		})
+	}
+	return converted
+}
+
 // convertFormatsToStreams converts YouTube DownloadableFormats to downloader Streams
 func convertFormatsToStreams(formats []youtube.DownloadableFormat) []downloader.Stream {
 	streams := make([]downloader.Stream, 0, len(formats))
@@ -787,16 +1038,20 @@ func convertFormatsToStreams(formats []youtube.DownloadableFormat) []downloader.
 			FPS:           f.FPS,
 		}
 
-		// Parse mime type to determine codecs and extension
+		// Parse mime type to determine codecs and extension; MP4 may hold AV1 as well as H.264
+		codecs := downloader.CodecsFromMimeType(f.MimeType)
 		if strings.Contains(f.MimeType, "video/mp4") {
 			stream.Extension = "mp4"
 			stream.VCodec = "h264"
-			if strings.Contains(f.MimeType, "avc1") {
-				stream.VCodec = "h264"
+			if len(codecs) > 0 {
+				stream.VCodec = downloader.VideoCodecFamily(codecs[0])
 			}
 		} else if strings.Contains(f.MimeType, "video/webm") {
 			stream.Extension = "webm"
 			stream.VCodec = "vp9"
+			if len(codecs) > 0 {
+				stream.VCodec = downloader.VideoCodecFamily(codecs[0])
+			}
 		} else if strings.Contains(f.MimeType, "audio/mp4") {
 			stream.Extension = "m4a"
 			stream.ACodec = "aac"
@@ -101,6 +101,43 @@ func TestUploadToCollector_Success(t *testing.T) {
 	}
 }
 
+func TestUploadToCollector_IncludesMetadataJSON(t *testing.T) {
+	var receivedInfo []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			t.Errorf("failed to parse multipart form: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if info, _, err := r.FormFile("info"); err == nil {
+			receivedInfo, _ = io.ReadAll(info)
+			info.Close()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	testFilePath := filepath.Join(tempDir, "video.mp4")
+	if err := os.WriteFile(testFilePath, []byte("video"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	info := `{"id":"test123","chapters":[{"title":"Intro","start_time":0,"end_time":10}]}`
+	if err := os.WriteFile(filepath.Join(tempDir, "metadata.json"), []byte(info), 0644); err != nil {
+		t.Fatalf("failed to write metadata.json: %v", err)
+	}
+
+	metadata := &UploadMetadata{VideoID: "test123", Filename: "video.mp4"}
+	if err := uploadToCollector(context.Background(), server.URL, testFilePath, metadata); err != nil {
+		t.Fatalf("uploadToCollector failed: %v", err)
+	}
+
+	if string(receivedInfo) != info {
+		t.Errorf("info = %q, want %q", receivedInfo, info)
+	}
+}
+
 func TestUploadToCollector_FileNotFound(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+)
+
+// videoClient looks up the records of videos through the controller API, as
+// workers do not read the state database
+type videoClient struct {
+	controllerURL string
+	token         downloader.TokenSource
+	httpClient    *http.Client
+}
+
+// newVideoClient creates a client for the controller at controllerURL that
+// authenticates with tokens from token, if it is not nil
+func newVideoClient(controllerURL string, token downloader.TokenSource) *videoClient {
+	return &videoClient{
+		controllerURL: strings.TrimSuffix(controllerURL, "/"),
+		token:         token,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// fetch returns the record of a video of channelID and the quality profile
+// of the channel, nil for the default
+func (v *videoClient) fetch(ctx context.Context, channelID, videoID string) (*VideoInfo, *downloader.Profile, error) {
+	target := v.controllerURL + "/api/workers/videos/" + url.PathEscape(channelID) + "/" + url.PathEscape(videoID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if v.token != nil {
+		token, err := v.token()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get controller token: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch video info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, nil, fmt.Errorf("video info request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Video   VideoInfo           `json:"video"`
+		Profile *downloader.Profile `json:"profile"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse video info: %w", err)
+	}
+	return &result.Video, result.Profile, nil
+}
@@ -0,0 +1,520 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/auth"
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+const (
+	// authSecretKey holds the generated token secret when AUTH_SECRET is not set
+	authSecretKey = "config:auth_secret"
+
+	// sessionCookie carries the session token of the web UI, so the browser
+	// also sends it for video, image and playlist requests
+	sessionCookie = "ytarchive_session"
+
+	// principalKey is the gin context key of the authenticated principal
+	principalKey = "principal"
+
+	// Default lifetime of a session token
+	defaultSessionHours = 168
+
+	// Lifetime of a worker token; workers renew theirs before it expires
+	workerTokenTTL = 12 * time.Hour
+)
+
+// anonymous is the principal of every request while authentication is disabled
+var anonymous = &auth.Principal{Name: "anonymous", Role: auth.RoleAdmin}
+
+// LoginRequest signs a user in
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// PutUserRequest creates or updates a user
+type PutUserRequest struct {
+	Password string `json:"password"` // required for new users; empty keeps the password
+	Role     string `json:"role" binding:"required"`
+}
+
+// CreateAPIKeyRequest creates an API key
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role" binding:"required"`
+}
+
+// WorkerTokenRequest exchanges a worker API key for a worker token
+type WorkerTokenRequest struct {
+	WorkerID string `json:"worker_id" binding:"required"`
+}
+
+// authEnabled reports whether AUTH_ENABLED turns on authentication
+func authEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("AUTH_ENABLED"))
+	return enabled
+}
+
+// sessionTTL returns the lifetime of session tokens from AUTH_SESSION_HOURS
+func sessionTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("AUTH_SESSION_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultSessionHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// BootstrapAdmin creates the first admin from ADMIN_USERNAME (default admin)
+// and ADMIN_PASSWORD when authentication is enabled and there are no users yet
+func (h *Handlers) BootstrapAdmin(ctx context.Context) error {
+	if !h.authEnabled {
+		logging.Warn("authentication is disabled, anyone who can reach the API can change the archive; set AUTH_ENABLED=true")
+		return nil
+	}
+
+	count, err := h.authStore.CountUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		logging.Warn("authentication is enabled but there are no users; set ADMIN_PASSWORD to create an admin")
+		return nil
+	}
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+	if _, err := h.authStore.PutUser(ctx, username, password, auth.RoleAdmin); err != nil {
+		return fmt.Errorf("failed to create admin: %w", err)
+	}
+	logging.Info("created admin user", "username", username)
+	return nil
+}
+
+// authenticate identifies who made a request from its bearer token, API key
+// or session cookie. Requests without valid credentials are rejected while
+// authentication is enabled.
+func (h *Handlers) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.authEnabled {
+			c.Set(principalKey, anonymous)
+			c.Next()
+			return
+		}
+
+		credential := requestCredential(c)
+		if credential == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		principal, err := h.resolvePrincipal(c.Request.Context(), credential)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired credentials"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error authenticating request: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// requireRole rejects requests by principals whose role does not allow role
+func (h *Handlers) requireRole(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !principalOf(c).Role.Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("This needs the %s role", role)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireClaim lets workers report only on the videos they have claimed from
+// the download queue. Other principals that got past requireRole pass.
+func (h *Handlers) requireClaim() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := principalOf(c)
+		if principal.Role != auth.RoleWorker {
+			c.Next()
+			return
+		}
+		if principal.WorkerID == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Exchange the worker API key for a worker token first"})
+			return
+		}
+
+		claimed, err := h.queue.ClaimedBy(c.Request.Context(), principal.WorkerID, c.Param("id"))
+		if err != nil {
+			log.Printf("Error checking claims of worker %s: %v", principal.WorkerID, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check worker claims"})
+			return
+		}
+		if !claimed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Video is not claimed by this worker"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// resolvePrincipal returns the principal of an API key or token. What a token
+// grants is looked up again on every request, so changing a user's role or
+// deleting a user or API key takes effect at once.
+func (h *Handlers) resolvePrincipal(ctx context.Context, credential string) (*auth.Principal, error) {
+	if auth.IsAPIKey(credential) {
+		apiKey, err := h.authStore.LookupAPIKey(ctx, credential)
+		if err != nil {
+			return nil, err
+		}
+		return &auth.Principal{Name: apiKey.Name, Role: apiKey.Role, KeyID: apiKey.ID}, nil
+	}
+
+	signer, err := h.getAuthSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := signer.Verify(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	switch claims.Kind {
+	case auth.KindSession:
+		user, err := h.authStore.GetUser(ctx, claims.Subject)
+		if errors.Is(err, auth.ErrNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &auth.Principal{Name: user.Username, Role: user.Role}, nil
+	case auth.KindWorker:
+		apiKey, err := h.authStore.GetAPIKey(ctx, claims.KeyID)
+		if errors.Is(err, auth.ErrNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		if err != nil {
+			return nil, err
+		}
+		if apiKey.Role != auth.RoleWorker {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return &auth.Principal{Name: apiKey.Name, Role: auth.RoleWorker, KeyID: apiKey.ID, WorkerID: claims.WorkerID}, nil
+	default:
+		return nil, auth.ErrInvalidCredentials
+	}
+}
+
+// Login handles POST /api/auth/login - sign in with a username and password.
+// The session token is set as a cookie for the web UI and returned for other clients.
+func (h *Handlers) Login(c *gin.Context) {
+	if !h.authEnabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Authentication is not enabled"})
+		return
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	user, err := h.authStore.Authenticate(ctx, req.Username, req.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		log.Printf("Failed sign-in for %q from %s", req.Username, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error signing in %s: %v", req.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	signer, err := h.getAuthSigner(ctx)
+	if err != nil {
+		log.Printf("Error loading auth secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+	ttl := sessionTTL()
+	token, expires := signer.Issue(auth.Claims{Kind: auth.KindSession, Subject: user.Username}, ttl)
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(sessionCookie, token, int(ttl.Seconds()), "/", "", isSecureRequest(c), true)
+	c.JSON(http.StatusOK, gin.H{
+		"username":   user.Username,
+		"role":       user.Role,
+		"token":      token,
+		"expires_at": expires,
+	})
+}
+
+// Logout handles POST /api/auth/logout - clear the session cookie
+func (h *Handlers) Logout(c *gin.Context) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(sessionCookie, "", -1, "/", "", isSecureRequest(c), true)
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out"})
+}
+
+// GetCurrentUser handles GET /api/auth/me - who the request was made by
+func (h *Handlers) GetCurrentUser(c *gin.Context) {
+	principal := principalOf(c)
+	c.JSON(http.StatusOK, gin.H{
+		"auth_enabled": h.authEnabled,
+		"name":         principal.Name,
+		"role":         principal.Role,
+		"worker_id":    principal.WorkerID,
+	})
+}
+
+// IssueWorkerToken handles POST /api/auth/worker-token - exchange a worker
+// API key for a token that can only report on the videos one worker has claimed
+func (h *Handlers) IssueWorkerToken(c *gin.Context) {
+	principal := principalOf(c)
+	if principal.Role != auth.RoleWorker || principal.KeyID == "" || principal.WorkerID != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Worker tokens are issued for worker API keys"})
+		return
+	}
+
+	var req WorkerTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if len(req.WorkerID) > 128 || strings.ContainsAny(req.WorkerID, " \t\r\n") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worker_id"})
+		return
+	}
+
+	signer, err := h.getAuthSigner(c.Request.Context())
+	if err != nil {
+		log.Printf("Error loading auth secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue worker token"})
+		return
+	}
+	token, expires := signer.Issue(auth.Claims{
+		Kind:     auth.KindWorker,
+		Subject:  principal.Name,
+		WorkerID: req.WorkerID,
+		KeyID:    principal.KeyID,
+	}, workerTokenTTL)
+
+	c.JSON(http.StatusOK, gin.H{
+		"worker_id":  req.WorkerID,
+		"token":      token,
+		"expires_at": expires,
+	})
+}
+
+// ListUsers handles GET /api/auth/users
+func (h *Handlers) ListUsers(c *gin.Context) {
+	users, err := h.authStore.ListUsers(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+	for i := range users {
+		users[i].PasswordHash = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "count": len(users)})
+}
+
+// PutUser handles PUT /api/auth/users/:username - create a user, or change
+// the role or password of one
+func (h *Handlers) PutUser(c *gin.Context) {
+	username := c.Param("username")
+
+	var req PutUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if isSelf(c, username) && role != auth.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove your own admin role"})
+		return
+	}
+
+	user, err := h.authStore.PutUser(c.Request.Context(), username, req.Password, role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user.PasswordHash = ""
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /api/auth/users/:username
+func (h *Handlers) DeleteUser(c *gin.Context) {
+	username := c.Param("username")
+	if isSelf(c, username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot delete your own account"})
+		return
+	}
+
+	err := h.authStore.DeleteUser(c.Request.Context(), username)
+	if errors.Is(err, auth.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted", "username": username})
+}
+
+// ListAPIKeys handles GET /api/auth/keys
+func (h *Handlers) ListAPIKeys(c *gin.Context) {
+	keys, err := h.authStore.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+	for i := range keys {
+		keys[i].SecretHash = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "count": len(keys)})
+}
+
+// CreateAPIKey handles POST /api/auth/keys - the key is only returned in this response
+func (h *Handlers) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, apiKey, err := h.authStore.CreateAPIKey(c.Request.Context(), req.Name, role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	apiKey.SecretHash = ""
+	c.JSON(http.StatusCreated, gin.H{"key": key, "api_key": apiKey})
+}
+
+// DeleteAPIKey handles DELETE /api/auth/keys/:id - revoke an API key and the worker tokens issued for it
+func (h *Handlers) DeleteAPIKey(c *gin.Context) {
+	id := c.Param("id")
+
+	err := h.authStore.DeleteAPIKey(c.Request.Context(), id)
+	if errors.Is(err, auth.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting API key %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted", "id": id})
+}
+
+// getAuthSigner returns the token signer, keyed with AUTH_SECRET or a secret
+// generated once and kept in Redis so every controller replica shares it
+func (h *Handlers) getAuthSigner(ctx context.Context) (*auth.Signer, error) {
+	h.authSignerMu.Lock()
+	defer h.authSignerMu.Unlock()
+
+	if h.authSigner != nil {
+		return h.authSigner, nil
+	}
+
+	secret := os.Getenv("AUTH_SECRET")
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+		}
+		if err := h.redis.SetNX(ctx, authSecretKey, hex.EncodeToString(random), 0).Err(); err != nil {
+			return nil, fmt.Errorf("failed to store auth secret: %w", err)
+		}
+		stored, err := h.redis.Get(ctx, authSecretKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load auth secret: %w", err)
+		}
+		secret = stored
+	}
+
+	h.authSigner = auth.NewSigner([]byte(secret))
+	return h.authSigner, nil
+}
+
+// principalOf returns the principal authenticate stored for a request
+func principalOf(c *gin.Context) *auth.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*auth.Principal); ok {
+			return principal
+		}
+	}
+	return &auth.Principal{}
+}
+
+// isSelf reports whether a request was made by the user with a username
+func isSelf(c *gin.Context, username string) bool {
+	principal := principalOf(c)
+	return principal.KeyID == "" && principal.Name == username
+}
+
+// requestCredential returns the bearer token or API key of a request, or its session cookie
+func requestCredential(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	cookie, err := c.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie
+}
+
+// isSecureRequest reports whether a request reached us, or the proxy in front of us, over HTTPS
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
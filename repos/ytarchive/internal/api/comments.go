@@ -0,0 +1,115 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/db"
+)
+
+// Page size limits of the comment listing
+const (
+	defaultCommentLimit = 100
+	maxCommentLimit     = 1000
+)
+
+// VideoComment is an archived comment, reply or chat replay message
+type VideoComment struct {
+	ID              string    `json:"id"`
+	ParentID        string    `json:"parent_id,omitempty"` // the comment a reply answers
+	AuthorName      string    `json:"author_name"`
+	AuthorChannelID string    `json:"author_channel_id,omitempty"`
+	Text            string    `json:"text"`
+	LikeCount       int64     `json:"like_count,omitempty"`
+	ReplyCount      int       `json:"reply_count,omitempty"`
+	PublishedText   string    `json:"published_text,omitempty"` // relative age when archived
+	OffsetMs        int64     `json:"offset_ms,omitempty"`      // position in the video of a chat message
+	Amount          string    `json:"amount,omitempty"`         // Super Chat amount
+	ArchivedAt      time.Time `json:"archived_at"`
+}
+
+// GetVideoComments handles GET /api/videos/:id/comments - the archived comments
+// of a video, or its live chat replay with type=chat. q searches them instead.
+func (h *Handlers) GetVideoComments(c *gin.Context) {
+	videoID := c.Param("id")
+	ctx := c.Request.Context()
+
+	kind := c.DefaultQuery("type", db.CommentKindComment)
+	if kind != db.CommentKindComment && kind != db.CommentKindChat {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be comment or chat"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultCommentLimit)))
+	if err != nil || limit < 1 || limit > maxCommentLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxCommentLimit)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative number"})
+		return
+	}
+	query := c.Query("q")
+
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	state := h.records.Store()
+	var archived []db.Comment
+	var total int
+	if query != "" {
+		archived, err = state.SearchComments(ctx, videoID, kind, query, limit)
+		total = len(archived)
+	} else {
+		total, err = state.CountComments(ctx, videoID, kind)
+		if err == nil {
+			archived, err = state.ListComments(ctx, videoID, kind, limit, offset)
+		}
+	}
+	if err != nil {
+		log.Printf("Error fetching comments of video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	comments := make([]VideoComment, 0, len(archived))
+	for _, comment := range archived {
+		comments = append(comments, VideoComment{
+			ID:              comment.ID,
+			ParentID:        comment.ParentID,
+			AuthorName:      comment.AuthorName,
+			AuthorChannelID: comment.AuthorChannelID,
+			Text:            comment.Text,
+			LikeCount:       comment.LikeCount,
+			ReplyCount:      comment.ReplyCount,
+			PublishedText:   comment.PublishedText,
+			OffsetMs:        comment.OffsetMs,
+			Amount:          comment.Amount,
+			ArchivedAt:      comment.ArchivedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":    videoID,
+		"channel_id":  channelID,
+		"type":        kind,
+		"comments":    comments,
+		"count":       len(comments),
+		"total":       total,
+		"offset":      offset,
+		"limit":       limit,
+		"archived_at": video.CommentsArchivedAt,
+	})
+}
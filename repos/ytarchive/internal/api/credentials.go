@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/credentials"
+)
+
+// PutCredentialRequest is the request body for adding or replacing a credential set
+type PutCredentialRequest struct {
+	Cookies string `json:"cookies" binding:"required"` // Netscape cookies.txt format
+}
+
+// ListCredentials handles GET /api/credentials - the credential sets workers
+// rotate between, with their usage; cookies are never returned
+func (h *Handlers) ListCredentials(c *gin.Context) {
+	sets, err := h.credentials.List(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing credential sets: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list credential sets"})
+		return
+	}
+
+	quarantined := 0
+	for i := range sets {
+		sets[i].Cookies = ""
+		if sets[i].State == credentials.StateQuarantined {
+			quarantined++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"credentials": sets, "count": len(sets), "quarantined": quarantined})
+}
+
+// PutCredential handles PUT /api/credentials/:name - add a credential set, or
+// replace its cookies, which also takes it out of quarantine
+func (h *Handlers) PutCredential(c *gin.Context) {
+	name := c.Param("name")
+
+	var req PutCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := credentials.ValidateName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := credentials.ValidateCookies(req.Cookies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	set, err := h.credentials.Put(c.Request.Context(), name, req.Cookies)
+	if err != nil {
+		log.Printf("Error saving credential set %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save credential set"})
+		return
+	}
+	log.Printf("Credential set %s saved", name)
+	set.Cookies = ""
+	c.JSON(http.StatusOK, set)
+}
+
+// DeleteCredential handles DELETE /api/credentials/:name
+func (h *Handlers) DeleteCredential(c *gin.Context) {
+	name := c.Param("name")
+
+	err := h.credentials.Delete(c.Request.Context(), name)
+	if errors.Is(err, credentials.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting credential set %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete credential set"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Credential set deleted", "name": name})
+}
+
+// CheckCredential handles POST /api/credentials/:name/check - ask YouTube now
+// whether a set is still signed in; a signed-out set is quarantined
+func (h *Handlers) CheckCredential(c *gin.Context) {
+	name := c.Param("name")
+
+	set, err := h.credentials.Check(c.Request.Context(), name)
+	if errors.Is(err, credentials.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error checking credential set %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check credential set"})
+		return
+	}
+	set.Cookies = ""
+	c.JSON(http.StatusOK, set)
+}
+
+// RestoreCredential handles POST /api/credentials/:name/restore - put a
+// quarantined or cooling down set back into rotation
+func (h *Handlers) RestoreCredential(c *gin.Context) {
+	name := c.Param("name")
+
+	set, err := h.credentials.Restore(c.Request.Context(), name)
+	if errors.Is(err, credentials.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error restoring credential set %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore credential set"})
+		return
+	}
+	set.Cookies = ""
+	c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/feed"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// feedSecretKey holds the generated feed token secret when FEED_SECRET is not set
+const feedSecretKey = "config:feed_secret"
+
+// Feed media types
+const (
+	feedMediaAudio = "audio"
+	feedMediaVideo = "video"
+)
+
+// Files of a video served to feed readers, see GetFeedMedia
+const (
+	feedFileStream    = "stream"
+	feedFileAudio     = "audio"
+	feedFileThumbnail = "thumbnail"
+)
+
+// audioFiles are the audio files written by DownloadAudioOnly, in the order
+// GetVideoAudio looks for them, with their MIME types
+var audioFiles = []struct {
+	name     string
+	mimeType string
+}{
+	{"audio.m4a", "audio/mp4"},
+	{"audio.mp3", "audio/mpeg"},
+	{"audio.webm", "audio/webm"},
+	{"audio.opus", "audio/ogg"},
+}
+
+// GetChannelFeedURLs handles GET /api/channels/:id/feed - Get the signed feed URLs of a channel
+func (h *Handlers) GetChannelFeedURLs(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	signer, err := h.getFeedSigner(ctx)
+	if err != nil {
+		log.Printf("Error loading feed secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign feed URL"})
+		return
+	}
+
+	token := signer.Sign(channelID)
+	feedURL := feedBaseURL(c) + "/feeds/channels/" + channelID + ".xml?token=" + url.QueryEscape(token)
+	c.JSON(http.StatusOK, gin.H{
+		"channel_id": channelID,
+		"audio_url":  feedURL,
+		"video_url":  feedURL + "&media=" + feedMediaVideo,
+	})
+}
+
+// GetChannelFeed handles GET /feeds/channels/:id.xml - Podcast RSS feed of a channel's archived videos.
+// Access needs the channel's feed token in ?token=. With ?media=video the enclosures
+// are the videos; by default they are the audio files, or the videos if there is no audio.
+func (h *Handlers) GetChannelFeed(c *gin.Context) {
+	channelID := strings.TrimSuffix(c.Param("id"), ".xml")
+	ctx := c.Request.Context()
+
+	token, ok := h.verifyFeedToken(c, channelID)
+	if !ok {
+		return
+	}
+
+	media := c.DefaultQuery("media", feedMediaAudio)
+	if media != feedMediaAudio && media != feedMediaVideo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "media must be audio or video"})
+		return
+	}
+
+	channelData, err := h.records.Channel(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channel"})
+		return
+	}
+	var channel Channel
+	if err := json.Unmarshal(channelData, &channel); err != nil {
+		log.Printf("Error unmarshaling channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse channel data"})
+		return
+	}
+
+	videos, err := h.getChannelVideos(ctx, channelID)
+	if err != nil {
+		log.Printf("Error fetching videos for channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch videos"})
+		return
+	}
+
+	base := feedBaseURL(c)
+	tokenQuery := "?token=" + url.QueryEscape(token)
+	items := make([]feed.Item, 0, len(videos))
+	for _, video := range videos {
+		if video.Status != "downloaded" && video.Status != "completed" {
+			continue
+		}
+		items = append(items, feedItem(base, tokenQuery, channelID, video, media))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published.After(items[j].Published)
+	})
+
+	info := feed.Channel{
+		Title:       channel.Name,
+		Description: channel.Description,
+		Author:      channel.Name,
+		Link:        channel.YouTubeURL,
+		FeedURL:     base + c.Request.URL.RequestURI(),
+	}
+	if info.Description == "" {
+		info.Description = "Archived videos of " + channel.Name
+	}
+	if len(items) > 0 {
+		info.ImageURL = items[0].ImageURL
+	}
+
+	body, err := feed.Build(info, items, time.Now())
+	if err != nil {
+		log.Printf("Error building feed for channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", body)
+}
+
+// GetFeedChapters handles GET /feeds/channels/:id/chapters/:video.json - Podcasting 2.0 chapters of a video
+func (h *Handlers) GetFeedChapters(c *gin.Context) {
+	channelID := c.Param("id")
+	videoID := strings.TrimSuffix(c.Param("video"), ".json")
+
+	if _, ok := h.verifyFeedToken(c, channelID); !ok {
+		return
+	}
+
+	// The token only covers the videos of its own channel
+	videoData, err := h.records.Video(c.Request.Context(), channelID, videoID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video"})
+		return
+	}
+	var video Video
+	if err := json.Unmarshal(videoData, &video); err != nil {
+		log.Printf("Error unmarshaling video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse video data"})
+		return
+	}
+
+	chapters := feedChapters(video.Chapters)
+	if len(chapters) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video has no chapters"})
+		return
+	}
+
+	body, err := feed.ChaptersJSON(chapters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build chapters"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json+chapters; charset=utf-8", body)
+}
+
+// GetFeedMedia handles GET /feeds/channels/:id/videos/:video/:file - the
+// enclosure or artwork of a feed item: stream, audio or thumbnail. Podcast
+// apps cannot sign in, so the feed token of the video's channel grants access.
+func (h *Handlers) GetFeedMedia(c *gin.Context) {
+	channelID := c.Param("id")
+	videoID := c.Param("video")
+
+	if _, ok := h.verifyFeedToken(c, channelID); !ok {
+		return
+	}
+
+	// The token only covers the videos of its own channel
+	_, err := h.records.Video(c.Request.Context(), channelID, videoID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video"})
+		return
+	}
+
+	switch c.Param("file") {
+	case feedFileStream:
+		h.serveVideoStream(c, channelID, videoID)
+	case feedFileAudio:
+		h.serveVideoAudio(c, channelID, videoID)
+	case feedFileThumbnail:
+		h.serveVideoThumbnail(c, channelID, videoID)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	}
+}
+
+// verifyFeedToken checks the ?token= of a feed request, writing a 401 response
+// and returning false if it does not grant access to the channel
+func (h *Handlers) verifyFeedToken(c *gin.Context, channelID string) (string, bool) {
+	signer, err := h.getFeedSigner(c.Request.Context())
+	if err != nil {
+		log.Printf("Error loading feed secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check feed token"})
+		return "", false
+	}
+
+	token := c.Query("token")
+	if !signer.Verify(channelID, token) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing feed token"})
+		return "", false
+	}
+	return token, true
+}
+
+// getFeedSigner returns the feed token signer. The secret comes from FEED_SECRET,
+// or else is generated once and kept in Redis so feed URLs survive restarts.
+func (h *Handlers) getFeedSigner(ctx context.Context) (*feed.Signer, error) {
+	h.feedSignerMu.Lock()
+	defer h.feedSignerMu.Unlock()
+
+	if h.feedSigner != nil {
+		return h.feedSigner, nil
+	}
+
+	secret := os.Getenv("FEED_SECRET")
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate feed secret: %w", err)
+		}
+		if err := h.redis.SetNX(ctx, feedSecretKey, hex.EncodeToString(random), 0).Err(); err != nil {
+			return nil, fmt.Errorf("failed to store feed secret: %w", err)
+		}
+		stored, err := h.redis.Get(ctx, feedSecretKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load feed secret: %w", err)
+		}
+		secret = stored
+	}
+
+	h.feedSigner = feed.NewSigner([]byte(secret))
+	return h.feedSigner, nil
+}
+
+// feedItem builds the feed item of an archived video. Its files are linked
+// through GetFeedMedia, which accepts the feed token.
+func feedItem(base, tokenQuery, channelID string, video Video, media string) feed.Item {
+	videoDir := videoDirectory(channelID, video.ID)
+	mediaURL := base + "/feeds/channels/" + channelID + "/videos/" + video.ID + "/"
+	item := feed.Item{
+		GUID:        video.ID,
+		Title:       video.Title,
+		Description: video.Description,
+		Link:        "https://www.youtube.com/watch?v=" + video.ID,
+		Published:   feed.ParseUploadDate(video.UploadDate),
+		Duration:    video.Duration,
+		ImageURL:    mediaURL + feedFileThumbnail + tokenQuery,
+	}
+	if item.Published.IsZero() {
+		item.Published = video.CreatedAt
+	}
+
+	item.EnclosureURL = mediaURL + feedFileStream + tokenQuery
+	item.EnclosureType = videoMimeType(video.FilePath)
+	item.EnclosureLength = video.FileSize
+	if media == feedMediaAudio {
+		for _, audio := range audioFiles {
+			if info, err := os.Stat(filepath.Join(videoDir, audio.name)); err == nil {
+				item.EnclosureURL = mediaURL + feedFileAudio + tokenQuery
+				item.EnclosureType = audio.mimeType
+				item.EnclosureLength = info.Size()
+				break
+			}
+		}
+	}
+
+	if chapters := feedChapters(video.Chapters); len(chapters) > 0 {
+		item.Chapters = chapters
+		item.ChaptersURL = base + "/feeds/channels/" + channelID + "/chapters/" + video.ID + ".json" + tokenQuery
+	}
+	return item
+}
+
+// feedChapters converts the chapters recorded for a video to feed chapters
+func feedChapters(chapters []downloader.Chapter) []feed.Chapter {
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	converted := make([]feed.Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		converted = append(converted, feed.Chapter{Title: chapter.Title, Start: chapter.Start})
+	}
+	return converted
+}
+
+// videoMimeType returns the MIME type of a video file
+func videoMimeType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mkv":
+		return "video/x-matroska"
+	case ".webm":
+		return "video/webm"
+	default:
+		return "video/mp4"
+	}
+}
+
+// videoDirectory returns the storage directory of a video
+func videoDirectory(channelID, videoID string) string {
+	return filepath.Join(getStoragePath(), "channels", channelID, "videos", videoID)
+}
+
+// feedBaseURL returns the external base URL for links in feeds, from FEED_BASE_URL
+// or else the request, honoring the headers set by reverse proxies
+func feedBaseURL(c *gin.Context) string {
+	if base := os.Getenv("FEED_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
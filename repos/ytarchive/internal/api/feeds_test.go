@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// setupFeedTest serves the API with authentication enabled. Channel c1 has
+// video v1 and channel c2 has video v2; v1 has a thumbnail, an audio file and
+// chapters, and v2 has chapters.
+func setupFeedTest(t *testing.T) (http.Handler, *Handlers) {
+	t.Helper()
+	ctx := context.Background()
+
+	storagePath := t.TempDir()
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("FEED_SECRET", "feed-test-secret")
+	t.Setenv("FEED_BASE_URL", "https://archive.example.com")
+	t.Setenv("STORAGE_PATH", storagePath)
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	state, err := store.Open(ctx, "sqlite://"+filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { state.Close() })
+	records := store.NewRecords(state, client)
+
+	for _, channelID := range []string{"c1", "c2"} {
+		if err := records.PutChannel(ctx, []byte(`{"id":"`+channelID+`","name":"Channel `+channelID+`"}`)); err != nil {
+			t.Fatalf("PutChannel() error = %v", err)
+		}
+	}
+	videos := map[string]string{"c1": "v1", "c2": "v2"}
+	for channelID, videoID := range videos {
+		doc := `{"id":"` + videoID + `","channel_id":"` + channelID + `","title":"Video ` + videoID + `","status":"downloaded","upload_date":"20240102",` +
+			`"chapters":[{"title":"Intro of ` + videoID + `","start_time":0,"end_time":10}]}`
+		if err := records.PutVideo(ctx, channelID, videoID, []byte(doc)); err != nil {
+			t.Fatalf("PutVideo() error = %v", err)
+		}
+	}
+
+	videoDir := filepath.Join(storagePath, "channels", "c1", "videos", "v1")
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(videoDir, "thumbnail.jpg"), []byte("jpeg"), 0644)
+	os.WriteFile(filepath.Join(videoDir, "audio.m4a"), []byte("audio"), 0644)
+
+	handlers := NewHandlers(client, nil, records)
+	return SetupRoutes(handlers), handlers
+}
+
+func TestFeedMediaWithAuthEnabled(t *testing.T) {
+	router, handlers := setupFeedTest(t)
+	signer, err := handlers.getFeedSigner(context.Background())
+	if err != nil {
+		t.Fatalf("getFeedSigner() error = %v", err)
+	}
+	token := url.QueryEscape(signer.Sign("c1"))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"feed", "/feeds/channels/c1.xml?token=" + token, http.StatusOK,
+			"https://archive.example.com/feeds/channels/c1/videos/v1/audio?token=" + token},
+		{"thumbnail", "/feeds/channels/c1/videos/v1/thumbnail?token=" + token, http.StatusOK, "jpeg"},
+		{"audio", "/feeds/channels/c1/videos/v1/audio?token=" + token, http.StatusOK, "audio"},
+		{"no token", "/feeds/channels/c1/videos/v1/audio", http.StatusUnauthorized, ""},
+		{"invalid token", "/feeds/channels/c1/videos/v1/audio?token=invalid", http.StatusUnauthorized, ""},
+		{"token of another channel", "/feeds/channels/c2/videos/v2/audio?token=" + token, http.StatusUnauthorized, ""},
+		{"video of another channel", "/feeds/channels/c1/videos/v2/audio?token=" + token, http.StatusNotFound, ""},
+		{"unknown file", "/feeds/channels/c1/videos/v1/metadata?token=" + token, http.StatusNotFound, ""},
+		{"api route", "/api/videos/v1/audio?token=" + token, http.StatusUnauthorized, ""},
+		{"chapters in feed", "/feeds/channels/c1.xml?token=" + token, http.StatusOK,
+			"https://archive.example.com/feeds/channels/c1/chapters/v1.json?token=" + token},
+		{"chapters", "/feeds/channels/c1/chapters/v1.json?token=" + token, http.StatusOK, `"title":"Intro of v1"`},
+		{"chapters without token", "/feeds/channels/c1/chapters/v1.json", http.StatusUnauthorized, ""},
+		{"chapters of another channel's video", "/feeds/channels/c1/chapters/v2.json?token=" + token, http.StatusNotFound, ""},
+		{"chapters of an unknown video", "/feeds/channels/c1/chapters/v3.json?token=" + token, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.path, recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			body, _ := io.ReadAll(recorder.Body)
+			if tt.wantBody != "" && !strings.Contains(strings.ReplaceAll(string(body), "&amp;", "&"), tt.wantBody) {
+				t.Errorf("GET %s body = %s, want it to contain %s", tt.path, body, tt.wantBody)
+			}
+		})
+	}
+}
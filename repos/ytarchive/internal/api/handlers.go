@@ -3,66 +3,87 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 
+	"github.com/timholm/ytarchive/internal/auth"
+	"github.com/timholm/ytarchive/internal/credentials"
 	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/feed"
+	"github.com/timholm/ytarchive/internal/hls"
+	"github.com/timholm/ytarchive/internal/profiles"
+	"github.com/timholm/ytarchive/internal/queue"
 	"github.com/timholm/ytarchive/internal/scheduler"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/store"
 	"github.com/timholm/ytarchive/internal/types"
 	"github.com/timholm/ytarchive/internal/validation"
 )
 
 const (
 	// Redis key prefixes
-	channelKeyPrefix  = "channel:"
-	channelListKey    = "channels"
-	videoKeyPrefix    = "video:"
 	jobKeyPrefix      = "job:"
 	activeJobsKey     = "jobs:active"
 	progressKeyPrefix = "progress:"
-	cookiesKey        = "config:cookies"
 )
 
 // Channel represents a YouTube channel being tracked (API-specific extension of types.Channel)
 type Channel struct {
-	ID          string    `json:"id"`
-	YouTubeURL  string    `json:"youtube_url"`
-	YouTubeID   string    `json:"youtube_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	VideoCount  int       `json:"video_count"`
-	Status      string    `json:"status"` // pending, syncing, synced, error
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	LastSyncAt  time.Time `json:"last_sync_at,omitempty"`
+	ID              string                   `json:"id"`
+	YouTubeURL      string                   `json:"youtube_url"`
+	YouTubeID       string                   `json:"youtube_id"`
+	Name            string                   `json:"name"`
+	Description     string                   `json:"description,omitempty"`
+	VideoCount      int                      `json:"video_count"`
+	Status          string                   `json:"status"`          // pending, syncing, synced, error
+	Kinds           []string                 `json:"kinds,omitempty"` // video kinds to archive; empty means video and premiere
+	Retention       *storage.RetentionPolicy `json:"retention,omitempty"`
+	Weight          float64                  `json:"weight,omitempty"`           // share of the workers against other channels; 0 means queue.DefaultWeight
+	ArchiveComments bool                     `json:"archive_comments,omitempty"` // archive comments and chat replays of downloaded videos
+	HLS             *hls.Policy              `json:"hls,omitempty"`              // HLS renditions built as videos are downloaded
+	Profile         string                   `json:"profile,omitempty"`          // quality profile videos are downloaded with; empty means the default
+	CreatedAt       time.Time                `json:"created_at"`
+	UpdatedAt       time.Time                `json:"updated_at"`
+	LastSyncAt      time.Time                `json:"last_sync_at,omitempty"`
+	NextSyncAt      *time.Time               `json:"next_sync_at,omitempty"` // from the channel's schedule; not stored on the channel
 }
 
 // Video represents a video from a channel (API-specific extension of types.Video)
+
 type Video struct {
-	ID           string    `json:"id"`
-	YouTubeID    string    `json:"youtube_id"`
-	ChannelID    string    `json:"channel_id"`
-	Title        string    `json:"title"`
-	Description  string    `json:"description,omitempty"`
-	Duration     int       `json:"duration"` // in seconds
-	UploadDate   string    `json:"upload_date,omitempty"`
-	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
-	ViewCount    int64     `json:"view_count,omitempty"`
-	Status       string    `json:"status"` // pending, downloading, downloaded, error
-	FilePath     string    `json:"file_path,omitempty"`
-	FileSize     int64     `json:"file_size,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                 string               `json:"id"`
+	YouTubeID          string               `json:"youtube_id"`
+	ChannelID          string               `json:"channel_id"`
+	Title              string               `json:"title"`
+	Description        string               `json:"description,omitempty"`
+	Duration           int                  `json:"duration"` // in seconds
+	UploadDate         string               `json:"upload_date,omitempty"`
+	ThumbnailURL       string               `json:"thumbnail_url,omitempty"`
+	ViewCount          int64                `json:"view_count,omitempty"`
+	Kind               string               `json:"kind,omitempty"` // video, short, live, premiere
+	Status             string               `json:"status"`         // pending, downloading, downloaded, error
+	FilePath           string               `json:"file_path,omitempty"`
+	FileSize           int64                `json:"file_size,omitempty"`
+	Chapters           []downloader.Chapter `json:"chapters,omitempty"`       // of the archived file
+	RemovedAt          *time.Time           `json:"removed_at,omitempty"`     // when the video disappeared from its channel upstream
+	RemovedReason      string               `json:"removed_reason,omitempty"` // why YouTube no longer lists it
+	CommentsArchivedAt *time.Time           `json:"comments_archived_at,omitempty"`
+	CommentCount       int                  `json:"comment_count,omitempty"`
+	ChatMessageCount   int                  `json:"chat_message_count,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
 }
 
 // Job represents a download job (API-specific extension of types.Job)
@@ -78,6 +99,10 @@ type Job struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	CompletedAt time.Time `json:"completed_at,omitempty"`
+
+	// From the download queue; not stored on the job
+	Queued        int   `json:"queued"`                   // videos of the channel waiting in the queue
+	QueuePosition int64 `json:"queue_position,omitempty"` // position of the channel's next video
 }
 
 // Ensure API types can be converted to canonical types
@@ -181,20 +206,52 @@ const activityKey = "activity:feed"
 
 // AddChannelRequest is the request body for adding a channel
 type AddChannelRequest struct {
-	YouTubeURL string `json:"youtube_url" binding:"required"`
+	YouTubeURL string   `json:"youtube_url" binding:"required"`
+	Kinds      []string `json:"kinds"`
+}
+
+// UpdateChannelRequest is the request body for updating channel settings
+type UpdateChannelRequest struct {
+	Kinds           []string                 `json:"kinds"`            // nil leaves the kinds unchanged; empty restores the default
+	Retention       *storage.RetentionPolicy `json:"retention"`        // nil leaves the policy unchanged; all zero removes it
+	Weight          *float64                 `json:"weight"`           // nil leaves the weight unchanged; 0 restores the default
+	ArchiveComments *bool                    `json:"archive_comments"` // nil leaves comment archiving unchanged
+	HLS             *hls.Policy              `json:"hls"`              // nil leaves the policy unchanged; empty removes it
+	Profile         *string                  `json:"profile"`          // nil leaves the profile unchanged; empty restores the default
 }
 
 // Handlers contains all API handlers
 type Handlers struct {
 	redis     *redis.Client
+	records   *store.Records // channels, videos and sync jobs, cached in Redis
 	scheduler *scheduler.Scheduler
+	queue     *queue.PriorityQueue
+
+	feedSignerMu sync.Mutex
+	feedSigner   *feed.Signer // created on first use; see getFeedSigner
+
+	authEnabled  bool
+	authStore    *auth.Store
+	authSignerMu sync.Mutex
+	authSigner   *auth.Signer // created on first use; see getAuthSigner
+
+	credentials *credentials.Pool // cookie sets workers rotate between
+	profiles    *profiles.Store   // quality profiles channels can be assigned
 }
 
 // NewHandlers creates a new Handlers instance
-func NewHandlers(redisClient *redis.Client, sched *scheduler.Scheduler) *Handlers {
+func NewHandlers(redisClient *redis.Client, sched *scheduler.Scheduler, records *store.Records) *Handlers {
 	return &Handlers{
 		redis:     redisClient,
+		records:   records,
 		scheduler: sched,
+		queue:     queue.NewPriorityQueue(redisClient),
+
+		authEnabled: authEnabled(),
+		authStore:   auth.NewStore(redisClient),
+
+		credentials: credentials.NewPool(redisClient),
+		profiles:    profiles.NewStore(redisClient, records),
 	}
 }
 
@@ -218,25 +275,27 @@ func (h *Handlers) AddChannel(c *gin.Context) {
 		youtubeID = extractedID
 	}
 
+	kinds, err := validation.ValidateVideoKinds(req.Kinds)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Check if channel already exists
 	ctx := c.Request.Context()
-	existingChannels, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
+	existingChannels, err := h.records.Store().ListChannels(ctx)
+	if err != nil {
 		log.Printf("Error checking existing channels: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing channels"})
 		return
 	}
 
-	for _, chID := range existingChannels {
-		channelData, err := h.redis.Get(ctx, channelKeyPrefix+chID).Result()
-		if err != nil {
+	for _, existing := range existingChannels {
+		if existing.YouTubeID != youtubeID {
 			continue
 		}
 		var existingChannel Channel
-		if err := json.Unmarshal([]byte(channelData), &existingChannel); err != nil {
-			continue
-		}
-		if existingChannel.YouTubeID == youtubeID {
+		if err := json.Unmarshal(existing.Document(), &existingChannel); err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "Channel already exists", "channel": existingChannel})
 			return
 		}
@@ -249,11 +308,11 @@ func (h *Handlers) AddChannel(c *gin.Context) {
 		YouTubeURL: req.YouTubeURL,
 		YouTubeID:  youtubeID,
 		Status:     "pending",
+		Kinds:      kinds,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
 
-	// Save to Redis
 	channelJSON, err := json.Marshal(channel)
 	if err != nil {
 		log.Printf("Error marshaling channel: %v", err)
@@ -261,11 +320,8 @@ func (h *Handlers) AddChannel(c *gin.Context) {
 		return
 	}
 
-	pipe := h.redis.Pipeline()
-	pipe.Set(ctx, channelKeyPrefix+channel.ID, channelJSON, 0)
-	pipe.SAdd(ctx, channelListKey, channel.ID)
-	if _, err := pipe.Exec(ctx); err != nil {
-		log.Printf("Error saving channel to Redis: %v", err)
+	if err := h.records.PutChannel(ctx, channelJSON); err != nil {
+		log.Printf("Error saving channel: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save channel"})
 		return
 	}
@@ -278,26 +334,21 @@ func (h *Handlers) AddChannel(c *gin.Context) {
 func (h *Handlers) ListChannels(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
+	records, err := h.records.Store().ListChannels(ctx)
+	if err != nil {
 		log.Printf("Error fetching channel list: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
 		return
 	}
 
-	channels := make([]Channel, 0, len(channelIDs))
-	for _, id := range channelIDs {
-		channelData, err := h.redis.Get(ctx, channelKeyPrefix+id).Result()
-		if err != nil {
-			log.Printf("Error fetching channel %s: %v", id, err)
-			continue
-		}
-
+	channels := make([]Channel, 0, len(records))
+	for _, record := range records {
 		var channel Channel
-		if err := json.Unmarshal([]byte(channelData), &channel); err != nil {
-			log.Printf("Error unmarshaling channel %s: %v", id, err)
+		if err := json.Unmarshal(record.Document(), &channel); err != nil {
+			log.Printf("Error unmarshaling channel %s: %v", record.ID, err)
 			continue
 		}
+		h.fillNextSyncAt(ctx, &channel)
 		channels = append(channels, channel)
 	}
 
@@ -310,8 +361,8 @@ func (h *Handlers) GetChannel(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Get channel
-	channelData, err := h.redis.Get(ctx, channelKeyPrefix+channelID).Result()
-	if err == redis.Nil {
+	channelData, err := h.records.Channel(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
@@ -322,12 +373,14 @@ func (h *Handlers) GetChannel(c *gin.Context) {
 	}
 
 	var channel Channel
-	if err := json.Unmarshal([]byte(channelData), &channel); err != nil {
+	if err := json.Unmarshal(channelData, &channel); err != nil {
 		log.Printf("Error unmarshaling channel %s: %v", channelID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse channel data"})
 		return
 	}
 
+	h.fillNextSyncAt(ctx, &channel)
+
 	// Get videos for this channel
 	videos, err := h.getChannelVideos(ctx, channelID)
 	if err != nil {
@@ -343,8 +396,8 @@ func (h *Handlers) SyncChannel(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Verify channel exists
-	channelData, err := h.redis.Get(ctx, channelKeyPrefix+channelID).Result()
-	if err == redis.Nil {
+	channelData, err := h.records.Channel(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
@@ -355,7 +408,7 @@ func (h *Handlers) SyncChannel(c *gin.Context) {
 	}
 
 	var channel Channel
-	if err := json.Unmarshal([]byte(channelData), &channel); err != nil {
+	if err := json.Unmarshal(channelData, &channel); err != nil {
 		log.Printf("Error unmarshaling channel %s: %v", channelID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse channel data"})
 		return
@@ -369,6 +422,14 @@ func (h *Handlers) SyncChannel(c *gin.Context) {
 
 	// Start sync using scheduler
 	jobID, err := h.scheduler.StartSync(ctx, channel.ID, channel.YouTubeID)
+	if errors.Is(err, scheduler.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Channel is already syncing"})
+		return
+	}
+	if errors.Is(err, scheduler.ErrSyncLimitReached) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many syncs running, try again later"})
+		return
+	}
 	if err != nil {
 		log.Printf("Error starting sync for channel %s: %v", channelID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sync: " + err.Error()})
@@ -376,10 +437,13 @@ func (h *Handlers) SyncChannel(c *gin.Context) {
 	}
 
 	// Update channel status
+	now := time.Now()
 	channel.Status = "syncing"
-	channel.UpdatedAt = time.Now()
-	channelJSON, _ := json.Marshal(channel)
-	if err := h.redis.Set(ctx, channelKeyPrefix+channelID, channelJSON, 0).Err(); err != nil {
+	channel.UpdatedAt = now
+	if _, err := h.records.UpdateChannel(ctx, channelID, func(channelMap map[string]interface{}) {
+		channelMap["status"] = "syncing"
+		channelMap["updated_at"] = now
+	}); err != nil {
 		log.Printf("Error updating channel status: %v", err)
 	}
 
@@ -387,40 +451,147 @@ func (h *Handlers) SyncChannel(c *gin.Context) {
 	c.JSON(http.StatusAccepted, gin.H{"message": "Sync started", "job_id": jobID, "channel": channel})
 }
 
-// DeleteChannel handles DELETE /api/channels/:id
-func (h *Handlers) DeleteChannel(c *gin.Context) {
+// UpdateChannel handles PATCH /api/channels/:id - Update channel settings
+func (h *Handlers) UpdateChannel(c *gin.Context) {
 	channelID := c.Param("id")
 	ctx := c.Request.Context()
 
-	// Check if channel exists
-	exists, err := h.redis.Exists(ctx, channelKeyPrefix+channelID).Result()
+	var req UpdateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	kinds, err := validation.ValidateVideoKinds(req.Kinds)
 	if err != nil {
-		log.Printf("Error checking channel existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check channel"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Retention != nil {
+		if err := req.Retention.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid retention policy: " + err.Error()})
+			return
+		}
+	}
+	if req.HLS != nil {
+		if err := req.HLS.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid HLS policy: " + err.Error()})
+			return
+		}
+	}
+	if req.Weight != nil && (*req.Weight < 0 || *req.Weight > queue.MaxWeight) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("weight must be between 0 and %g", queue.MaxWeight)})
 		return
 	}
-	if exists == 0 {
+	if req.Profile != nil && *req.Profile != "" {
+		if _, err := h.profiles.Get(ctx, *req.Profile); errors.Is(err, profiles.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown profile %q", *req.Profile)})
+			return
+		} else if err != nil {
+			log.Printf("Error fetching profile %s: %v", *req.Profile, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+			return
+		}
+	}
+
+	channelData, err := h.records.Channel(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
+	if err != nil {
+		log.Printf("Error fetching channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channel"})
+		return
+	}
 
-	// Delete channel and remove from list
-	pipe := h.redis.Pipeline()
-	pipe.Del(ctx, channelKeyPrefix+channelID)
-	pipe.SRem(ctx, channelListKey, channelID)
+	// Update the raw record so fields maintained by the scheduler are preserved
+	var channelMap map[string]interface{}
+	if err := json.Unmarshal(channelData, &channelMap); err != nil {
+		log.Printf("Error unmarshaling channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse channel data"})
+		return
+	}
+
+	if req.Kinds != nil {
+		if len(kinds) > 0 {
+			channelMap["kinds"] = kinds
+		} else {
+			delete(channelMap, "kinds")
+		}
+	}
+	if req.Retention != nil {
+		if !req.Retention.IsZero() {
+			channelMap["retention"] = req.Retention
+		} else {
+			delete(channelMap, "retention")
+		}
+	}
+	if req.Weight != nil {
+		if *req.Weight > 0 {
+			channelMap["weight"] = *req.Weight
+		} else {
+			delete(channelMap, "weight")
+		}
+	}
+	if req.ArchiveComments != nil {
+		if *req.ArchiveComments {
+			channelMap["archive_comments"] = true
+		} else {
+			delete(channelMap, "archive_comments")
+		}
+	}
+	if req.HLS != nil {
+		if !req.HLS.IsZero() {
+			channelMap["hls"] = req.HLS
+		} else {
+			delete(channelMap, "hls")
+		}
+	}
+	if req.Profile != nil {
+		if *req.Profile != "" && *req.Profile != profiles.DefaultName {
+			channelMap["profile"] = *req.Profile
+		} else {
+			delete(channelMap, "profile")
+		}
+	}
+	channelMap["updated_at"] = time.Now()
 
-	// Also delete associated videos
-	videoKeys, _ := h.redis.Keys(ctx, videoKeyPrefix+channelID+":*").Result()
-	for _, key := range videoKeys {
-		pipe.Del(ctx, key)
+	channelJSON, _ := json.Marshal(channelMap)
+	if err := h.records.PutChannel(ctx, channelJSON); err != nil {
+		log.Printf("Error updating channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update channel"})
+		return
 	}
 
-	if _, err := pipe.Exec(ctx); err != nil {
+	var channel Channel
+	json.Unmarshal(channelJSON, &channel)
+
+	log.Printf("Channel updated: %s (kinds: %v)", channelID, kinds)
+	c.JSON(http.StatusOK, channel)
+}
+
+// DeleteChannel handles DELETE /api/channels/:id
+func (h *Handlers) DeleteChannel(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	// Check if channel exists
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	// Delete channel with its videos and sync history
+	if err := h.records.DeleteChannel(ctx, channelID); err != nil {
 		log.Printf("Error deleting channel %s: %v", channelID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete channel"})
 		return
 	}
 
+	if err := h.scheduler.DeleteSchedule(ctx, channelID); err != nil {
+		log.Printf("Error deleting schedule for channel %s: %v", channelID, err)
+	}
+
 	log.Printf("Channel deleted: %s", channelID)
 	c.JSON(http.StatusOK, gin.H{"message": "Channel deleted successfully"})
 }
@@ -451,6 +622,7 @@ func (h *Handlers) ListJobs(c *gin.Context) {
 		}
 		jobs = append(jobs, job)
 	}
+	h.addQueueInfo(ctx, jobs)
 
 	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "count": len(jobs)})
 }
@@ -460,8 +632,8 @@ func (h *Handlers) GetProgress(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Get all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
+	channelIDs, err := h.records.ChannelIDs(ctx)
+	if err != nil {
 		log.Printf("Error fetching channel list: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress"})
 		return
@@ -516,8 +688,8 @@ func (h *Handlers) GetStats(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Get all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
+	channelIDs, err := h.records.ChannelIDs(ctx)
+	if err != nil {
 		log.Printf("Error fetching channel list: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
 		return
@@ -591,85 +763,6 @@ func (h *Handlers) GetActivity(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"activities": result, "count": len(result)})
 }
 
-// SearchVideos handles GET /api/search - Full-text search across videos using SQLite FTS5
-func (h *Handlers) SearchVideos(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query 'q' is required"})
-		return
-	}
-
-	channelID := c.Query("channel")
-	status := c.Query("status")
-	limitStr := c.DefaultQuery("limit", "50")
-
-	limit := 50
-	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
-		limit = l
-	}
-
-	// If no channel specified, search across all channels
-	ctx := c.Request.Context()
-	channelIDs := []string{channelID}
-	if channelID == "" {
-		var err error
-		channelIDs, err = h.redis.SMembers(ctx, channelListKey).Result()
-		if err != nil && err != redis.Nil {
-			log.Printf("Error fetching channel list: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
-			return
-		}
-	}
-
-	var allResults []gin.H
-	for _, chID := range channelIDs {
-		if chID == "" {
-			continue
-		}
-
-		// Open the channel's SQLite database
-		channelDB, err := db.OpenChannelDB(chID)
-		if err != nil {
-			log.Printf("Error opening channel DB for %s: %v", chID, err)
-			continue
-		}
-
-		var results []db.SearchResult
-		if status != "" {
-			results, err = db.SearchVideosWithFilter(channelDB, query, db.VideoStatus(status), limit)
-		} else {
-			results, err = db.SearchVideos(channelDB, query, limit)
-		}
-		channelDB.Close()
-
-		if err != nil {
-			log.Printf("Error searching channel %s: %v", chID, err)
-			continue
-		}
-
-		for _, r := range results {
-			allResults = append(allResults, gin.H{
-				"id":          r.ID,
-				"channel_id":  chID,
-				"title":       r.Title,
-				"description": r.Description,
-				"duration":    r.Duration,
-				"upload_date": r.UploadDate,
-				"status":      r.Status,
-				"file_path":   r.FilePath,
-				"file_size":   r.FileSize,
-				"rank":        r.Rank,
-			})
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"query":   query,
-		"results": allResults,
-		"count":   len(allResults),
-	})
-}
-
 // ListVideos handles GET /api/videos - Lists all videos with optional filters
 func (h *Handlers) ListVideos(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -677,11 +770,13 @@ func (h *Handlers) ListVideos(c *gin.Context) {
 	// Get optional query parameters
 	search := c.Query("search")
 	status := c.Query("status")
+	kind := c.Query("kind")
 	channelID := c.Query("channel")
+	removed := c.Query("removed")
 
 	// Get all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
+	channelIDs, err := h.records.ChannelIDs(ctx)
+	if err != nil {
 		log.Printf("Error fetching channel list: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch videos"})
 		return
@@ -708,6 +803,14 @@ func (h *Handlers) ListVideos(c *gin.Context) {
 		if status != "" && video.Status != status {
 			continue
 		}
+		// Filter by kind
+		if kind != "" && video.Kind != kind {
+			continue
+		}
+		// Filter by whether the video was removed upstream
+		if !matchesRemoved(video.RemovedAt, removed) {
+			continue
+		}
 		// Filter by search term (title contains search string)
 		if search != "" && !containsIgnoreCase(video.Title, search) {
 			continue
@@ -724,35 +827,18 @@ func (h *Handlers) GetVideo(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Search for the video across all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	_, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video"})
 		return
 	}
-
-	for _, channelID := range channelIDs {
-		videoData, err := h.redis.Get(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err == redis.Nil {
-			continue
-		}
-		if err != nil {
-			log.Printf("Error fetching video %s: %v", videoID, err)
-			continue
-		}
-
-		var video Video
-		if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-			log.Printf("Error unmarshaling video %s: %v", videoID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse video data"})
-			return
-		}
-
-		c.JSON(http.StatusOK, video)
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
 		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	c.JSON(http.StatusOK, video)
 }
 
 // TriggerDownload handles POST /api/videos/:id/download - Trigger download for specific video
@@ -761,67 +847,53 @@ func (h *Handlers) TriggerDownload(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Search for the video across all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trigger download"})
 		return
 	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		videoKey := videoKeyPrefix + channelID + ":" + videoID
-		videoData, err := h.redis.Get(ctx, videoKey).Result()
-		if err == redis.Nil {
-			continue
-		}
-		if err != nil {
-			log.Printf("Error fetching video %s: %v", videoID, err)
-			continue
-		}
-
-		var video Video
-		if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-			log.Printf("Error unmarshaling video %s: %v", videoID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse video data"})
-			return
-		}
-
-		// Check if already downloading or downloaded
-		if video.Status == "downloading" {
-			c.JSON(http.StatusConflict, gin.H{"error": "Video is already downloading"})
-			return
-		}
-		if video.Status == "downloaded" {
-			c.JSON(http.StatusConflict, gin.H{"error": "Video is already downloaded"})
-			return
-		}
-
-		// Update video status to pending for download
-		video.Status = "pending"
-		video.UpdatedAt = time.Now()
-		videoJSON, _ := json.Marshal(video)
-		if err := h.redis.Set(ctx, videoKey, videoJSON, 0).Err(); err != nil {
-			log.Printf("Error updating video status: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue video for download"})
-			return
-		}
+	// Check if already downloading or downloaded
+	if video.Status == "downloading" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Video is already downloading"})
+		return
+	}
+	if video.Status == "downloaded" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Video is already downloaded"})
+		return
+	}
 
-		// Add to download queue (unified queue for all channels)
-		// Format: "channelID:videoID"
-		queueKey := "ytarchive:download:queue"
-		queueItem := channelID + ":" + videoID
-		if err := h.redis.RPush(ctx, queueKey, queueItem).Err(); err != nil {
-			log.Printf("Error adding video to download queue: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue video for download"})
-			return
-		}
+	// Update video status to pending for download
+	video.Status = "pending"
+	video.UpdatedAt = time.Now()
+	videoJSON, _ := json.Marshal(video)
+	if err := h.records.PutVideo(ctx, channelID, videoID, videoJSON); err != nil {
+		log.Printf("Error updating video status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue video for download"})
+		return
+	}
 
-		log.Printf("Download triggered for video %s", videoID)
-		c.JSON(http.StatusAccepted, gin.H{"message": "Download queued", "video": video})
+	// Requested downloads go ahead of new uploads and backfill
+	if _, err := h.queue.Enqueue(ctx, channelID, []string{videoID}, queue.PriorityManual, queue.DefaultWeight); err != nil {
+		log.Printf("Error adding video to download queue: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue video for download"})
 		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	response := gin.H{"message": "Download queued", "video": video}
+	if entry, err := h.queue.Position(ctx, channelID, videoID); err != nil {
+		log.Printf("Error fetching queue position of video %s: %v", videoID, err)
+	} else if entry != nil {
+		response["queue_position"] = entry.Position
+	}
+
+	log.Printf("Download triggered for video %s", videoID)
+	c.JSON(http.StatusAccepted, response)
 }
 
 // GetChannelVideos handles GET /api/channels/:id/videos - Get videos for a specific channel
@@ -830,16 +902,15 @@ func (h *Handlers) GetChannelVideos(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Verify channel exists
-	exists, err := h.redis.Exists(ctx, channelKeyPrefix+channelID).Result()
-	if err != nil {
+	if _, err := h.records.Channel(ctx, channelID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
 		log.Printf("Error checking channel existence: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channel videos"})
 		return
 	}
-	if exists == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
-		return
-	}
 
 	videos, err := h.getChannelVideos(ctx, channelID)
 	if err != nil {
@@ -848,6 +919,28 @@ func (h *Handlers) GetChannelVideos(c *gin.Context) {
 		return
 	}
 
+	// Filter by kind if specified
+	if kind := c.Query("kind"); kind != "" {
+		filtered := make([]Video, 0, len(videos))
+		for _, video := range videos {
+			if video.Kind == kind {
+				filtered = append(filtered, video)
+			}
+		}
+		videos = filtered
+	}
+
+	// Filter by whether the video was removed upstream
+	if removed := c.Query("removed"); removed != "" {
+		filtered := make([]Video, 0, len(videos))
+		for _, video := range videos {
+			if matchesRemoved(video.RemovedAt, removed) {
+				filtered = append(filtered, video)
+			}
+		}
+		videos = filtered
+	}
+
 	c.JSON(http.StatusOK, gin.H{"videos": videos, "count": len(videos), "channel_id": channelID})
 }
 
@@ -874,7 +967,10 @@ func (h *Handlers) GetJob(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, job)
+	jobs := []Job{job}
+	h.addQueueInfo(ctx, jobs)
+
+	c.JSON(http.StatusOK, jobs[0])
 }
 
 // CancelJob handles POST /api/jobs/:id/cancel - Cancel a running job
@@ -1000,42 +1096,28 @@ func (h *Handlers) IndexChannelVideos(c *gin.Context) {
 	}
 	defer channelDB.Close()
 
-	// Insert each video into SQLite
-	indexed := 0
-	for _, video := range videos {
-		dbVideo := &db.Video{
-			ID:           video.YouTubeID,
-			Title:        video.Title,
-			Description:  video.Description,
-			Duration:     int64(video.Duration),
-			UploadDate:   video.UploadDate,
-			ThumbnailURL: video.ThumbnailURL,
-			ViewCount:    video.ViewCount,
-			Status:       db.VideoStatus(video.Status),
-			FilePath:     video.FilePath,
-			FileSize:     video.FileSize,
-		}
-		if err := db.InsertVideo(channelDB, dbVideo); err != nil {
-			log.Printf("Error inserting video %s: %v", video.YouTubeID, err)
-			continue
-		}
-		indexed++
+	// Replace the videos in SQLite with the records
+	if err := db.ReplaceVideos(channelDB, channelIndexVideos(videos)); err != nil {
+		log.Printf("Error rebuilding channel DB for %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild index"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Index rebuilt successfully",
 		"channel_id":    channelID,
 		"videos_found":  len(videos),
-		"videos_indexed": indexed,
+		"videos_indexed": len(videos),
 	})
 }
 
 // IndexAllChannels handles POST /api/index - Rebuild FTS index for all channels
+// and start catching the global search index up
 func (h *Handlers) IndexAllChannels(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Get all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
+	channelIDs, err := h.records.ChannelIDs(ctx)
 	if err != nil {
 		log.Printf("Error fetching channel list: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
@@ -1062,35 +1144,30 @@ func (h *Handlers) IndexAllChannels(c *gin.Context) {
 			continue
 		}
 
-		// Insert videos
-		indexed := 0
-		for _, video := range videos {
-			dbVideo := &db.Video{
-				ID:           video.YouTubeID,
-				Title:        video.Title,
-				Description:  video.Description,
-				Duration:     int64(video.Duration),
-				UploadDate:   video.UploadDate,
-				ThumbnailURL: video.ThumbnailURL,
-				ViewCount:    video.ViewCount,
-				Status:       db.VideoStatus(video.Status),
-				FilePath:     video.FilePath,
-				FileSize:     video.FileSize,
-			}
-			if err := db.InsertVideo(channelDB, dbVideo); err == nil {
-				indexed++
-			}
-		}
+		// Replace the videos with the records
+		err = db.ReplaceVideos(channelDB, channelIndexVideos(videos))
 		channelDB.Close()
+		if err != nil {
+			log.Printf("Error rebuilding channel DB for %s: %v", channelID, err)
+			results = append(results, gin.H{"channel_id": channelID, "error": err.Error()})
+			continue
+		}
 
-		totalIndexed += indexed
+		totalIndexed += len(videos)
 		results = append(results, gin.H{
 			"channel_id":     channelID,
 			"videos_found":   len(videos),
-			"videos_indexed": indexed,
+			"videos_indexed": len(videos),
 		})
 	}
 
+	// Catch the global search index up with the records in the background
+	go func() {
+		if _, err := h.scheduler.UpdateSearchIndex(context.Background()); err != nil {
+			log.Printf("Error updating search index: %v", err)
+		}
+	}()
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":        "Index rebuilt for all channels",
 		"channels":       len(channelIDs),
@@ -1099,24 +1176,46 @@ func (h *Handlers) IndexAllChannels(c *gin.Context) {
 	})
 }
 
+// channelIndexVideos returns the rows of a channel database for the videos of a channel
+func channelIndexVideos(videos []Video) []*db.Video {
+	rows := make([]*db.Video, 0, len(videos))
+	for _, video := range videos {
+		rows = append(rows, &db.Video{
+			ID:            video.YouTubeID,
+			Title:         video.Title,
+			Description:   video.Description,
+			Duration:      int64(video.Duration),
+			UploadDate:    video.UploadDate,
+			ThumbnailURL:  video.ThumbnailURL,
+			ViewCount:     video.ViewCount,
+			Kind:          video.Kind,
+			Status:        db.VideoStatus(video.Status),
+			FilePath:      video.FilePath,
+			FileSize:      video.FileSize,
+			RemovedAt:     video.RemovedAt,
+			RemovedReason: video.RemovedReason,
+		})
+	}
+	return rows
+}
+
 // getChannelVideos retrieves all videos for a channel
 func (h *Handlers) getChannelVideos(ctx context.Context, channelID string) ([]Video, error) {
-	videoKeys, err := h.redis.Keys(ctx, videoKeyPrefix+channelID+":*").Result()
+	records, err := h.records.Store().ListVideos(ctx, channelID)
 	if err != nil {
 		return nil, err
 	}
 
-	videos := make([]Video, 0, len(videoKeys))
-	for _, key := range videoKeys {
-		videoData, err := h.redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
+	videos := make([]Video, 0, len(records))
+	for _, record := range records {
 		var video Video
-		if err := json.Unmarshal([]byte(videoData), &video); err != nil {
+		if err := json.Unmarshal(record.Document(), &video); err != nil {
 			continue
 		}
+		// Videos discovered before kinds were recorded all came from the Videos tab
+		if video.Kind == "" {
+			video.Kind = db.KindVideo
+		}
 		videos = append(videos, video)
 	}
 
@@ -1358,6 +1457,11 @@ func (h *Handlers) UpdateProgress(c *gin.Context) {
 		req.VideoID = videoID
 	}
 
+	// Workers report as the worker their token was issued to
+	if principal := principalOf(c); principal.WorkerID != "" {
+		req.WorkerID = principal.WorkerID
+	}
+
 	// Store progress in Redis with key progress:{videoID}
 	progressKey := progressKeyPrefix + videoID
 	progressData := map[string]interface{}{
@@ -1407,8 +1511,7 @@ func (h *Handlers) UpdateVideoStatus(c *gin.Context) {
 
 	// If channelID is provided, update the specific video
 	if req.ChannelID != "" {
-		videoKey := videoKeyPrefix + req.ChannelID + ":" + videoID
-		if err := h.updateVideoInRedis(ctx, videoKey, req); err != nil {
+		if err := h.updateVideoRecord(ctx, req.ChannelID, videoID, req); err != nil {
 			log.Printf("Error updating video %s: %v", videoID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update video status"})
 			return
@@ -1418,65 +1521,43 @@ func (h *Handlers) UpdateVideoStatus(c *gin.Context) {
 	}
 
 	// If no channelID, search for video across all channels
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find video"})
 		return
 	}
-
-	for _, channelID := range channelIDs {
-		videoKey := videoKeyPrefix + channelID + ":" + videoID
-		exists, err := h.redis.Exists(ctx, videoKey).Result()
-		if err != nil {
-			continue
-		}
-		if exists > 0 {
-			if err := h.updateVideoInRedis(ctx, videoKey, req); err != nil {
-				log.Printf("Error updating video %s: %v", videoID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update video status"})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{"message": "Video status updated", "video_id": videoID})
-			return
-		}
-	}
-
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
-}
-
-// updateVideoInRedis updates a video's status in Redis
-func (h *Handlers) updateVideoInRedis(ctx context.Context, videoKey string, req UpdateVideoStatusRequest) error {
-	videoData, err := h.redis.Get(ctx, videoKey).Result()
-	if err != nil {
-		return err
-	}
-
-	var video map[string]interface{}
-	if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-		return err
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
 	}
 
-	// Update fields
-	video["status"] = req.Status
-	video["updated_at"] = time.Now()
-
-	if req.FilePath != "" {
-		video["file_path"] = req.FilePath
-	}
-	if req.FileSize > 0 {
-		video["file_size"] = req.FileSize
-	}
-	if req.Error != "" {
-		video["error"] = req.Error
+	if err := h.updateVideoRecord(ctx, channelID, videoID, req); err != nil {
+		log.Printf("Error updating video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update video status"})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Video status updated", "video_id": videoID})
+}
 
-	videoJSON, err := json.Marshal(video)
-	if err != nil {
-		return err
-	}
+// updateVideoRecord updates a video's status
+func (h *Handlers) updateVideoRecord(ctx context.Context, channelID, videoID string, req UpdateVideoStatusRequest) error {
+	_, err := h.records.UpdateVideo(ctx, channelID, videoID, func(video map[string]interface{}) {
+		// Update fields
+		video["status"] = req.Status
+		video["updated_at"] = time.Now()
 
-	return h.redis.Set(ctx, videoKey, videoJSON, 0).Err()
+		if req.FilePath != "" {
+			video["file_path"] = req.FilePath
+		}
+		if req.FileSize > 0 {
+			video["file_size"] = req.FileSize
+		}
+		if req.Error != "" {
+			video["error"] = req.Error
+		}
+	})
+	return err
 }
 
 // CookiesRequest is the request body for saving cookies
@@ -1490,19 +1571,20 @@ type CookiesResponse struct {
 	Message    string `json:"message,omitempty"`
 }
 
-// GetCookies handles GET /api/cookies - Check if cookies are configured
+// GetCookies handles GET /api/cookies - Check if cookies are configured,
+// in any set of the credential pool
 func (h *Handlers) GetCookies(c *gin.Context) {
 	ctx := c.Request.Context()
-	exists, err := h.redis.Exists(ctx, cookiesKey).Result()
+	sets, err := h.credentials.List(ctx)
 	if err != nil {
 		log.Printf("Error checking cookies: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check cookies"})
 		return
 	}
-	c.JSON(http.StatusOK, CookiesResponse{Configured: exists > 0})
+	c.JSON(http.StatusOK, CookiesResponse{Configured: len(sets) > 0})
 }
 
-// SaveCookies handles POST /api/cookies - Save cookies configuration
+// SaveCookies handles POST /api/cookies - Save cookies as the default credential set
 func (h *Handlers) SaveCookies(c *gin.Context) {
 	var req CookiesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1513,8 +1595,12 @@ func (h *Handlers) SaveCookies(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cookies format. Please paste cookies in Netscape/cookies.txt format."})
 		return
 	}
+	if err := credentials.ValidateCookies(req.Cookies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := c.Request.Context()
-	if err := h.redis.Set(ctx, cookiesKey, req.Cookies, 0).Err(); err != nil {
+	if _, err := h.credentials.Put(ctx, credentials.DefaultSetName, req.Cookies); err != nil {
 		log.Printf("Error saving cookies: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cookies"})
 		return
@@ -1523,16 +1609,17 @@ func (h *Handlers) SaveCookies(c *gin.Context) {
 	c.JSON(http.StatusOK, CookiesResponse{Configured: true, Message: "Cookies saved successfully"})
 }
 
-// DeleteCookies handles DELETE /api/cookies - Delete cookies configuration
+// DeleteCookies handles DELETE /api/cookies - Delete the default credential set
 func (h *Handlers) DeleteCookies(c *gin.Context) {
 	ctx := c.Request.Context()
-	if err := h.redis.Del(ctx, cookiesKey).Err(); err != nil {
+	if err := h.credentials.Delete(ctx, credentials.DefaultSetName); err != nil && !errors.Is(err, credentials.ErrNotFound) {
 		log.Printf("Error deleting cookies: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cookies"})
 		return
 	}
 	log.Printf("Cookies deleted")
-	c.JSON(http.StatusOK, CookiesResponse{Configured: false, Message: "Cookies deleted successfully"})
+	sets, err := h.credentials.List(ctx)
+	c.JSON(http.StatusOK, CookiesResponse{Configured: err == nil && len(sets) > 0, Message: "Cookies deleted successfully"})
 }
 
 // GetVideoStream handles GET /api/videos/:id/stream - Stream video file
@@ -1541,37 +1628,24 @@ func (h *Handlers) GetVideoStream(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Find video to get channel ID
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video"})
 		return
 	}
-
-	var channelID string
-	for _, cID := range channelIDs {
-		videoData, err := h.redis.Get(ctx, videoKeyPrefix+cID+":"+videoID).Result()
-		if err == redis.Nil {
-			continue
-		}
-		if err != nil {
-			continue
-		}
-
-		var video Video
-		if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-			continue
-		}
-
-		channelID = cID
-		break
-	}
-
-	if channelID == "" {
+	if video == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
 		return
 	}
 
+	h.serveVideoStream(c, channelID, videoID)
+}
+
+// serveVideoStream streams a video file from the collector
+func (h *Handlers) serveVideoStream(c *gin.Context, channelID, videoID string) {
+	ctx := c.Request.Context()
+
 	// Proxy to collector service
 	collectorURL := getCollectorURL()
 	streamURL := fmt.Sprintf("%s/stream/%s/%s", collectorURL, channelID, videoID)
@@ -1617,38 +1691,37 @@ func (h *Handlers) GetVideoThumbnail(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Find video to get channel ID
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thumbnail"})
 		return
 	}
+	if video == nil {
+		// Fallback to YouTube thumbnail
+		c.Redirect(http.StatusTemporaryRedirect, "https://img.youtube.com/vi/"+videoID+"/maxresdefault.jpg")
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		exists, err := h.redis.Exists(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err != nil || exists == 0 {
-			continue
-		}
+	h.serveVideoThumbnail(c, channelID, videoID)
+}
 
-		storagePath := getStoragePath()
-		videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
+// serveVideoThumbnail serves the archived thumbnail of a video, or redirects to YouTube's
+func (h *Handlers) serveVideoThumbnail(c *gin.Context, channelID, videoID string) {
+	storagePath := getStoragePath()
+	videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
 
-		// Try different thumbnail formats
-		patterns := []string{"thumbnail.jpg", "thumbnail.webp", "thumbnail.png"}
-		for _, pattern := range patterns {
-			path := videoDir + "/" + pattern
-			if _, err := os.Stat(path); err == nil {
-				c.File(path)
-				return
-			}
+	// Try different thumbnail formats
+	patterns := []string{"thumbnail.jpg", "thumbnail.webp", "thumbnail.png"}
+	for _, pattern := range patterns {
+		path := videoDir + "/" + pattern
+		if _, err := os.Stat(path); err == nil {
+			c.File(path)
+			return
 		}
-
-		// If no local thumbnail, try to get from YouTube
-		c.Redirect(http.StatusTemporaryRedirect, "https://img.youtube.com/vi/"+videoID+"/maxresdefault.jpg")
-		return
 	}
 
-	// Fallback to YouTube thumbnail
+	// If no local thumbnail, try to get from YouTube
 	c.Redirect(http.StatusTemporaryRedirect, "https://img.youtube.com/vi/"+videoID+"/maxresdefault.jpg")
 }
 
@@ -1657,31 +1730,26 @@ func (h *Handlers) GetVideoMetadata(c *gin.Context) {
 	videoID := c.Param("id")
 	ctx := c.Request.Context()
 
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch metadata"})
 		return
 	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		exists, err := h.redis.Exists(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err != nil || exists == 0 {
-			continue
-		}
-
-		storagePath := getStoragePath()
-		metadataPath := storagePath + "/channels/" + channelID + "/videos/" + videoID + "/metadata.json"
-
-		if _, err := os.Stat(metadataPath); err == nil {
-			c.File(metadataPath)
-			return
-		}
+	storagePath := getStoragePath()
+	metadataPath := storagePath + "/channels/" + channelID + "/videos/" + videoID + "/metadata.json"
 
-		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata file not found"})
+	if _, err := os.Stat(metadataPath); err == nil {
+		c.File(metadataPath)
 		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	c.JSON(http.StatusNotFound, gin.H{"error": "Metadata file not found"})
 }
 
 // GetVideoAudio handles GET /api/videos/:id/audio - Serve audio file
@@ -1689,45 +1757,44 @@ func (h *Handlers) GetVideoAudio(c *gin.Context) {
 	videoID := c.Param("id")
 	ctx := c.Request.Context()
 
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audio"})
 		return
 	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		exists, err := h.redis.Exists(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err != nil || exists == 0 {
-			continue
-		}
+	h.serveVideoAudio(c, channelID, videoID)
+}
 
-		storagePath := getStoragePath()
-		videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
-
-		// Look for audio file with various extensions
-		patterns := []string{"audio.m4a", "audio.mp3", "audio.webm", "audio.opus"}
-		var audioPath string
-		for _, pattern := range patterns {
-			path := videoDir + "/" + pattern
-			if _, err := os.Stat(path); err == nil {
-				audioPath = path
-				break
-			}
-		}
+// serveVideoAudio serves the audio file of a video
+func (h *Handlers) serveVideoAudio(c *gin.Context, channelID, videoID string) {
+	storagePath := getStoragePath()
+	videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
 
-		if audioPath == "" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found"})
-			return
+	// Look for audio file with various extensions
+	patterns := []string{"audio.m4a", "audio.mp3", "audio.webm", "audio.opus"}
+	var audioPath string
+	for _, pattern := range patterns {
+		path := videoDir + "/" + pattern
+		if _, err := os.Stat(path); err == nil {
+			audioPath = path
+			break
 		}
+	}
 
-		// Serve the file with proper content type
-		c.Header("Accept-Ranges", "bytes")
-		c.File(audioPath)
+	if audioPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found"})
 		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	// Serve the file with proper content type
+	c.Header("Accept-Ranges", "bytes")
+	c.File(audioPath)
 }
 
 // GetVideoSubtitles handles GET /api/videos/:id/subtitles - Serve subtitles file
@@ -1736,51 +1803,45 @@ func (h *Handlers) GetVideoSubtitles(c *gin.Context) {
 	lang := c.DefaultQuery("lang", "en")
 	ctx := c.Request.Context()
 
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subtitles"})
 		return
 	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		exists, err := h.redis.Exists(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err != nil || exists == 0 {
-			continue
-		}
-
-		storagePath := getStoragePath()
-		videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
-
-		// Look for subtitle file - try specific language first, then default
-		var subtitlePath string
-		patterns := []string{
-			"subtitles." + lang + ".vtt",
-			"subtitles.vtt",
-			"subtitles." + lang + ".srt",
-			"subtitles.srt",
-		}
+	storagePath := getStoragePath()
+	videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
 
-		for _, pattern := range patterns {
-			path := videoDir + "/" + pattern
-			if _, err := os.Stat(path); err == nil {
-				subtitlePath = path
-				break
-			}
-		}
+	// Look for subtitle file - try specific language first, then default
+	var subtitlePath string
+	patterns := []string{
+		"subtitles." + lang + ".vtt",
+		"subtitles.vtt",
+		"subtitles." + lang + ".srt",
+		"subtitles.srt",
+	}
 
-		if subtitlePath == "" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Subtitle file not found"})
-			return
+	for _, pattern := range patterns {
+		path := videoDir + "/" + pattern
+		if _, err := os.Stat(path); err == nil {
+			subtitlePath = path
+			break
 		}
+	}
 
-		// Set content type for WebVTT
-		c.Header("Content-Type", "text/vtt; charset=utf-8")
-		c.File(subtitlePath)
+	if subtitlePath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subtitle file not found"})
 		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	// Set content type for WebVTT
+	c.Header("Content-Type", "text/vtt; charset=utf-8")
+	c.File(subtitlePath)
 }
 
 // ListVideoSubtitles handles GET /api/videos/:id/subtitles/list - List available subtitle languages
@@ -1788,62 +1849,56 @@ func (h *Handlers) ListVideoSubtitles(c *gin.Context) {
 	videoID := c.Param("id")
 	ctx := c.Request.Context()
 
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list subtitles"})
 		return
 	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		exists, err := h.redis.Exists(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err != nil || exists == 0 {
-			continue
-		}
-
-		storagePath := getStoragePath()
-		videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
+	storagePath := getStoragePath()
+	videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
 
-		// Find all subtitle files
-		subtitles := []map[string]string{}
+	// Find all subtitle files
+	subtitles := []map[string]string{}
 
-		// Check for default subtitle file
-		if _, err := os.Stat(videoDir + "/subtitles.vtt"); err == nil {
-			subtitles = append(subtitles, map[string]string{
-				"lang": "en",
-				"file": "subtitles.vtt",
-				"url":  "/api/videos/" + videoID + "/subtitles",
-			})
-		}
+	// Check for default subtitle file
+	if _, err := os.Stat(videoDir + "/subtitles.vtt"); err == nil {
+		subtitles = append(subtitles, map[string]string{
+			"lang": "en",
+			"file": "subtitles.vtt",
+			"url":  "/api/videos/" + videoID + "/subtitles",
+		})
+	}
 
-		// Look for language-specific files
-		entries, err := os.ReadDir(videoDir)
-		if err == nil {
-			for _, entry := range entries {
-				name := entry.Name()
-				// Match pattern: subtitles.{lang}.vtt
-				if len(name) > 14 && name[:10] == "subtitles." && name[len(name)-4:] == ".vtt" {
-					lang := name[10 : len(name)-4]
-					if lang != "" {
-						subtitles = append(subtitles, map[string]string{
-							"lang": lang,
-							"file": name,
-							"url":  "/api/videos/" + videoID + "/subtitles?lang=" + lang,
-						})
-					}
+	// Look for language-specific files
+	entries, err := os.ReadDir(videoDir)
+	if err == nil {
+		for _, entry := range entries {
+			name := entry.Name()
+			// Match pattern: subtitles.{lang}.vtt
+			if len(name) > 14 && name[:10] == "subtitles." && name[len(name)-4:] == ".vtt" {
+				lang := name[10 : len(name)-4]
+				if lang != "" {
+					subtitles = append(subtitles, map[string]string{
+						"lang": lang,
+						"file": name,
+						"url":  "/api/videos/" + videoID + "/subtitles?lang=" + lang,
+					})
 				}
 			}
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"video_id":  videoID,
-			"subtitles": subtitles,
-			"count":     len(subtitles),
-		})
-		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":  videoID,
+		"subtitles": subtitles,
+		"count":     len(subtitles),
+	})
 }
 
 // VideoFilesResponse contains information about available files for a video
@@ -1866,70 +1921,64 @@ func (h *Handlers) GetVideoFiles(c *gin.Context) {
 	videoID := c.Param("id")
 	ctx := c.Request.Context()
 
-	channelIDs, err := h.redis.SMembers(ctx, channelListKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching channel list: %v", err)
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video files"})
 		return
 	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
 
-	for _, channelID := range channelIDs {
-		exists, err := h.redis.Exists(ctx, videoKeyPrefix+channelID+":"+videoID).Result()
-		if err != nil || exists == 0 {
-			continue
-		}
-
-		storagePath := getStoragePath()
-		videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
-
-		response := VideoFilesResponse{
-			VideoID: videoID,
-		}
+	storagePath := getStoragePath()
+	videoDir := storagePath + "/channels/" + channelID + "/videos/" + videoID
 
-		// Check for video file
-		for _, ext := range []string{"mp4", "mkv", "webm"} {
-			if _, err := os.Stat(videoDir + "/video." + ext); err == nil {
-				response.HasVideo = true
-				response.VideoURL = "/api/videos/" + videoID + "/stream"
-				break
-			}
-		}
+	response := VideoFilesResponse{
+		VideoID: videoID,
+	}
 
-		// Check for audio file
-		for _, ext := range []string{"m4a", "mp3", "webm", "opus"} {
-			if _, err := os.Stat(videoDir + "/audio." + ext); err == nil {
-				response.HasAudio = true
-				response.AudioURL = "/api/videos/" + videoID + "/audio"
-				break
-			}
+	// Check for video file
+	for _, ext := range []string{"mp4", "mkv", "webm"} {
+		if _, err := os.Stat(videoDir + "/video." + ext); err == nil {
+			response.HasVideo = true
+			response.VideoURL = "/api/videos/" + videoID + "/stream"
+			break
 		}
+	}
 
-		// Check for subtitles
-		if _, err := os.Stat(videoDir + "/subtitles.vtt"); err == nil {
-			response.HasSubs = true
-			response.SubsURL = "/api/videos/" + videoID + "/subtitles"
+	// Check for audio file
+	for _, ext := range []string{"m4a", "mp3", "webm", "opus"} {
+		if _, err := os.Stat(videoDir + "/audio." + ext); err == nil {
+			response.HasAudio = true
+			response.AudioURL = "/api/videos/" + videoID + "/audio"
+			break
 		}
+	}
 
-		// Check for thumbnail
-		for _, ext := range []string{"jpg", "webp", "png"} {
-			if _, err := os.Stat(videoDir + "/thumbnail." + ext); err == nil {
-				response.HasThumb = true
-				response.ThumbURL = "/api/videos/" + videoID + "/thumbnail"
-				break
-			}
-		}
+	// Check for subtitles
+	if _, err := os.Stat(videoDir + "/subtitles.vtt"); err == nil {
+		response.HasSubs = true
+		response.SubsURL = "/api/videos/" + videoID + "/subtitles"
+	}
 
-		// Check for metadata
-		if _, err := os.Stat(videoDir + "/metadata.json"); err == nil {
-			response.HasMeta = true
-			response.MetaURL = "/api/videos/" + videoID + "/metadata"
+	// Check for thumbnail
+	for _, ext := range []string{"jpg", "webp", "png"} {
+		if _, err := os.Stat(videoDir + "/thumbnail." + ext); err == nil {
+			response.HasThumb = true
+			response.ThumbURL = "/api/videos/" + videoID + "/thumbnail"
+			break
 		}
+	}
 
-		c.JSON(http.StatusOK, response)
-		return
+	// Check for metadata
+	if _, err := os.Stat(videoDir + "/metadata.json"); err == nil {
+		response.HasMeta = true
+		response.MetaURL = "/api/videos/" + videoID + "/metadata"
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	c.JSON(http.StatusOK, response)
 }
 
 // getStoragePath returns the configured storage path
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// VideoVersion is the metadata a video had on YouTube from RecordedAt until the next version
+type VideoVersion struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// GetVideoHistory handles GET /api/videos/:id/history - the metadata versions of a
+// video, oldest first, and whether it was removed upstream
+func (h *Handlers) GetVideoHistory(c *gin.Context) {
+	videoID := c.Param("id")
+	ctx := c.Request.Context()
+
+	channelID, videoData, err := h.records.FindVideo(ctx, videoID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video history"})
+		return
+	}
+
+	// Edits found by syncs are kept on the video record
+	var video struct {
+		Video
+		Versions []VideoVersion `json:"versions"`
+	}
+	if err := json.Unmarshal(videoData, &video); err != nil {
+		log.Printf("Error unmarshaling video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse video data"})
+		return
+	}
+	versions := video.Versions
+	if versions == nil {
+		versions = make([]VideoVersion, 0)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":       videoID,
+		"channel_id":     channelID,
+		"title":          video.Title,
+		"removed_at":     video.RemovedAt,
+		"removed_reason": video.RemovedReason,
+		"versions":       versions,
+		"count":          len(versions),
+	})
+}
+
+// findVideo looks up the record of a video in every channel. Returns a nil
+// video if no channel has it.
+func (h *Handlers) findVideo(ctx context.Context, videoID string) (string, *Video, error) {
+	channelID, videoData, err := h.records.FindVideo(ctx, videoID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var video Video
+	if err := json.Unmarshal(videoData, &video); err != nil {
+		return "", nil, fmt.Errorf("failed to parse video data: %w", err)
+	}
+	return channelID, &video, nil
+}
+
+// matchesRemoved reports whether a video passes the removed query filter:
+// "true" keeps videos removed upstream, "false" keeps the others, and any
+// other value keeps every video
+func matchesRemoved(removedAt *time.Time, filter string) bool {
+	removed, err := strconv.ParseBool(filter)
+	if err != nil {
+		return true
+	}
+	return (removedAt != nil) == removed
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// hlsResponseHeaders are the collector response headers relayed to players
+var hlsResponseHeaders = []string{"Content-Type", "Content-Length", "Cache-Control", "Retry-After", "Last-Modified", "ETag"}
+
+// GetVideoHLS handles GET /api/videos/:id/hls/*file - HLS playlists and
+// segments of a video. The collector packages the renditions on demand, so
+// the first request for a rendition can take a while.
+func (h *Handlers) GetVideoHLS(c *gin.Context) {
+	videoID := c.Param("id")
+	file := c.Param("file")
+	ctx := c.Request.Context()
+
+	if file == "/" || strings.Contains(file, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid HLS file"})
+		return
+	}
+
+	channelID, video, err := h.findVideo(ctx, videoID)
+	if err != nil {
+		log.Printf("Error finding video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video"})
+		return
+	}
+	if video == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getCollectorURL()+"/hls/"+channelID+"/"+videoID+file, nil)
+	if err != nil {
+		log.Printf("Error creating collector request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream video"})
+		return
+	}
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	client := &http.Client{Timeout: 0} // No timeout; a rendition's first segment may take a while
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error streaming HLS from collector: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to stream video from storage"})
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, key := range hlsResponseHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			c.Header(key, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	io.Copy(c.Writer, resp.Body)
+}
+
+// PrebuildChannelHLS handles POST /api/channels/:id/hls - queue the videos
+// already archived for a channel for the renditions its HLS policy names
+func (h *Handlers) PrebuildChannelHLS(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	if _, err := h.records.Channel(ctx, channelID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+		log.Printf("Error fetching channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channel"})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, getCollectorURL()+"/hls/"+channelID, nil)
+	if err != nil {
+		log.Printf("Error creating collector request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reach collector"})
+		return
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error reaching collector: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach collector"})
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read collector response"})
+		return
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		c.JSON(resp.StatusCode, gin.H{"error": strings.TrimSpace(string(data))})
+		return
+	}
+	c.Data(resp.StatusCode, "application/json", data)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportRequest starts an import of yt-dlp downloads
+type ImportRequest struct {
+	Path   string `json:"path"`           // directory under the collector's IMPORT_PATH
+	Mode   string `json:"mode,omitempty"` // hardlink (default), copy or move
+	DryRun bool   `json:"dry_run"`
+}
+
+// StartImport handles POST /api/import. The collector, which owns the archive
+// volume and the PostgreSQL catalog, runs the import in the background.
+func (h *Handlers) StartImport(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		return
+	}
+	proxyImport(c, http.MethodPost, body)
+}
+
+// GetImport handles GET /api/import - progress of the running or last import
+func (h *Handlers) GetImport(c *gin.Context) {
+	proxyImport(c, http.MethodGet, nil)
+}
+
+// proxyImport forwards an import request to the collector and relays its response
+func proxyImport(c *gin.Context, method string, body []byte) {
+	req, err := http.NewRequestWithContext(c.Request.Context(), method, getCollectorURL()+"/import", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating collector request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reach collector"})
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error reaching collector: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach collector"})
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read collector response"})
+		return
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": string(bytes.TrimSpace(data))})
+		return
+	}
+	c.Data(resp.StatusCode, "application/json", data)
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/timholm/ytarchive/internal/scheduler"
+	"github.com/timholm/ytarchive/internal/store"
+	"github.com/timholm/ytarchive/internal/validation"
+)
+
+// Playlist represents a YouTube playlist being archived
+type Playlist struct {
+	ID                string    `json:"id"`
+	YouTubeURL        string    `json:"youtube_url"`
+	YouTubeID         string    `json:"youtube_id"`
+	Title             string    `json:"title"`
+	Description       string    `json:"description,omitempty"`
+	OwnerName         string    `json:"owner_name,omitempty"`
+	VideoCount        int       `json:"video_count"`
+	Status            string    `json:"status"` // pending, syncing, synced, error
+	SyncIntervalHours float64   `json:"sync_interval_hours,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	LastSyncAt        time.Time `json:"last_sync_at,omitempty"`
+}
+
+// PlaylistEntry is a playlist item in playlist order, joined with its archived video
+type PlaylistEntry struct {
+	Position    int        `json:"position"`
+	VideoID     string     `json:"video_id"`
+	ChannelID   string     `json:"channel_id,omitempty"`
+	ChannelName string     `json:"channel_name,omitempty"`
+	Title       string     `json:"title"`
+	Duration    int64      `json:"duration"`
+	Status      string     `json:"status"` // pending, downloading, downloaded, error, unavailable
+	RemovedAt   *time.Time `json:"removed_at,omitempty"`
+}
+
+// AddPlaylistRequest is the request body for adding a playlist
+type AddPlaylistRequest struct {
+	YouTubeURL        string  `json:"youtube_url" binding:"required"`
+	SyncIntervalHours float64 `json:"sync_interval_hours"`
+}
+
+// AddPlaylist handles POST /api/playlists
+func (h *Handlers) AddPlaylist(c *gin.Context) {
+	var req AddPlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	youtubeID, err := validation.ValidatePlaylistInput(req.YouTubeURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube playlist: " + err.Error()})
+		return
+	}
+
+	if req.SyncIntervalHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sync_interval_hours must not be negative"})
+		return
+	}
+
+	// Check if playlist already exists
+	ctx := c.Request.Context()
+	existing, err := h.getPlaylists(ctx)
+	if err != nil {
+		log.Printf("Error checking existing playlists: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing playlists"})
+		return
+	}
+	for _, playlist := range existing {
+		if playlist.YouTubeID == youtubeID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Playlist already exists", "playlist": playlist})
+			return
+		}
+	}
+
+	now := time.Now()
+	playlist := Playlist{
+		ID:                uuid.New().String(),
+		YouTubeURL:        req.YouTubeURL,
+		YouTubeID:         youtubeID,
+		Status:            "pending",
+		SyncIntervalHours: req.SyncIntervalHours,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	playlistJSON, err := json.Marshal(playlist)
+	if err != nil {
+		log.Printf("Error marshaling playlist: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create playlist"})
+		return
+	}
+
+	if err := h.records.PutPlaylist(ctx, playlistJSON); err != nil {
+		log.Printf("Error saving playlist: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save playlist"})
+		return
+	}
+
+	log.Printf("Playlist added: %s (YouTube ID: %s)", playlist.ID, playlist.YouTubeID)
+	c.JSON(http.StatusCreated, playlist)
+}
+
+// ListPlaylists handles GET /api/playlists
+func (h *Handlers) ListPlaylists(c *gin.Context) {
+	playlists, err := h.getPlaylists(c.Request.Context())
+	if err != nil {
+		log.Printf("Error fetching playlist list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"playlists": playlists, "count": len(playlists)})
+}
+
+// GetPlaylist handles GET /api/playlists/:id
+func (h *Handlers) GetPlaylist(c *gin.Context) {
+	playlist, ok := h.loadPlaylist(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.getPlaylistEntries(c.Request.Context(), playlist.ID, false)
+	if err != nil {
+		log.Printf("Error fetching entries for playlist %s: %v", playlist.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"playlist": playlist, "videos": entries})
+}
+
+// GetPlaylistVideos handles GET /api/playlists/:id/videos
+// Pass include_removed=true to also list entries that were removed from the playlist.
+func (h *Handlers) GetPlaylistVideos(c *gin.Context) {
+	playlist, ok := h.loadPlaylist(c)
+	if !ok {
+		return
+	}
+
+	includeRemoved, _ := strconv.ParseBool(c.Query("include_removed"))
+
+	entries, err := h.getPlaylistEntries(c.Request.Context(), playlist.ID, includeRemoved)
+	if err != nil {
+		log.Printf("Error fetching entries for playlist %s: %v", playlist.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist videos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"videos": entries, "count": len(entries)})
+}
+
+// SyncPlaylist handles POST /api/playlists/:id/sync
+func (h *Handlers) SyncPlaylist(c *gin.Context) {
+	playlist, ok := h.loadPlaylist(c)
+	if !ok {
+		return
+	}
+
+	if playlist.Status == "syncing" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Playlist is already syncing"})
+		return
+	}
+
+	jobID, err := h.scheduler.StartPlaylistSync(c.Request.Context(), playlist.ID, playlist.YouTubeID)
+	if errors.Is(err, scheduler.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Playlist is already syncing"})
+		return
+	}
+	if errors.Is(err, scheduler.ErrSyncLimitReached) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many syncs running, try again later"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting sync for playlist %s: %v", playlist.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sync: " + err.Error()})
+		return
+	}
+
+	playlist.Status = "syncing"
+
+	log.Printf("Sync started for playlist %s, job ID: %s", playlist.ID, jobID)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Sync started", "job_id": jobID, "playlist": playlist})
+}
+
+// DeletePlaylist handles DELETE /api/playlists/:id
+// Archived videos are kept; only the playlist and its entries are removed.
+func (h *Handlers) DeletePlaylist(c *gin.Context) {
+	playlist, ok := h.loadPlaylist(c)
+	if !ok {
+		return
+	}
+	playlistID := playlist.ID
+
+	if err := h.records.DeletePlaylist(c.Request.Context(), playlistID); err != nil {
+		log.Printf("Error deleting playlist %s: %v", playlistID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete playlist"})
+		return
+	}
+
+	log.Printf("Playlist deleted: %s", playlistID)
+	c.JSON(http.StatusOK, gin.H{"message": "Playlist deleted successfully"})
+}
+
+// loadPlaylist fetches the playlist named by the :id parameter, writing an error response on failure
+func (h *Handlers) loadPlaylist(c *gin.Context) (*Playlist, bool) {
+	playlistID := c.Param("id")
+
+	playlistData, err := h.records.Playlist(c.Request.Context(), playlistID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching playlist %s: %v", playlistID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return nil, false
+	}
+
+	var playlist Playlist
+	if err := json.Unmarshal(playlistData, &playlist); err != nil {
+		log.Printf("Error unmarshaling playlist %s: %v", playlistID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse playlist data"})
+		return nil, false
+	}
+
+	return &playlist, true
+}
+
+// getPlaylists returns all tracked playlists
+func (h *Handlers) getPlaylists(ctx context.Context) ([]Playlist, error) {
+	playlistIDs, err := h.records.PlaylistIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := make([]Playlist, 0, len(playlistIDs))
+	for _, id := range playlistIDs {
+		playlistData, err := h.records.Playlist(ctx, id)
+		if err != nil {
+			log.Printf("Error fetching playlist %s: %v", id, err)
+			continue
+		}
+
+		var playlist Playlist
+		if err := json.Unmarshal(playlistData, &playlist); err != nil {
+			log.Printf("Error unmarshaling playlist %s: %v", id, err)
+			continue
+		}
+		playlists = append(playlists, playlist)
+	}
+
+	return playlists, nil
+}
+
+// getPlaylistEntries returns the playlist order with each video's archive status
+func (h *Handlers) getPlaylistEntries(ctx context.Context, playlistID string, includeRemoved bool) ([]PlaylistEntry, error) {
+	items, err := h.records.Store().ListPlaylistItems(ctx, playlistID, includeRemoved)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]PlaylistEntry, 0, len(items))
+	for _, item := range items {
+		entry := PlaylistEntry{
+			Position:    item.Position,
+			VideoID:     item.VideoID,
+			ChannelID:   item.ChannelID,
+			ChannelName: item.ChannelName,
+			Title:       item.Title,
+			Duration:    item.Duration,
+			Status:      "unavailable",
+			RemovedAt:   item.RemovedAt,
+		}
+
+		if item.ChannelID != "" {
+			videoData, err := h.records.Video(ctx, item.ChannelID, item.VideoID)
+			if err == nil {
+				var video Video
+				if err := json.Unmarshal(videoData, &video); err == nil {
+					entry.Status = video.Status
+				}
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/profiles"
+)
+
+// ProfileResponse is a quality profile with how it is used
+type ProfileResponse struct {
+	downloader.Profile
+	BuiltIn  bool     `json:"built_in"`
+	Channels []string `json:"channels"` // IDs of the channels assigned the profile
+}
+
+// ListProfiles handles GET /api/profiles - the built-in and custom quality
+// profiles, with the channels assigned each
+func (h *Handlers) ListProfiles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	list, err := h.profiles.List(ctx)
+	if err != nil {
+		log.Printf("Error listing profiles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list profiles"})
+		return
+	}
+
+	response := make([]ProfileResponse, 0, len(list))
+	for _, profile := range list {
+		channels, err := h.profiles.ChannelsUsing(ctx, profile.Name)
+		if err != nil {
+			log.Printf("Error listing channels of profile %s: %v", profile.Name, err)
+		}
+		response = append(response, ProfileResponse{
+			Profile:  profile,
+			BuiltIn:  profiles.IsBuiltIn(profile.Name),
+			Channels: nonNilStrings(channels),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": response, "count": len(response)})
+}
+
+// GetProfile handles GET /api/profiles/:name
+func (h *Handlers) GetProfile(c *gin.Context) {
+	name := c.Param("name")
+	ctx := c.Request.Context()
+
+	profile, err := h.profiles.Get(ctx, name)
+	if errors.Is(err, profiles.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching profile %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	channels, err := h.profiles.ChannelsUsing(ctx, name)
+	if err != nil {
+		log.Printf("Error listing channels of profile %s: %v", name, err)
+	}
+	c.JSON(http.StatusOK, ProfileResponse{
+		Profile:  *profile,
+		BuiltIn:  profiles.IsBuiltIn(name),
+		Channels: nonNilStrings(channels),
+	})
+}
+
+// PutProfile handles PUT /api/profiles/:name - add or replace a custom
+// profile. Channels assigned it use the new settings for their next downloads;
+// videos already archived are changed with the reprofile command.
+func (h *Handlers) PutProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	var profile downloader.Profile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	profile.Name = name
+
+	if profiles.IsBuiltIn(name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Built-in profiles cannot be changed"})
+		return
+	}
+	if err := profiles.ValidateName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := profile.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile: " + err.Error()})
+		return
+	}
+
+	if err := h.profiles.Put(c.Request.Context(), &profile); err != nil {
+		log.Printf("Error saving profile %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save profile"})
+		return
+	}
+
+	log.Printf("Profile %s saved", name)
+	c.JSON(http.StatusOK, ProfileResponse{Profile: profile, Channels: []string{}})
+}
+
+// DeleteProfile handles DELETE /api/profiles/:name - only custom profiles no
+// channel is assigned can be deleted
+func (h *Handlers) DeleteProfile(c *gin.Context) {
+	name := c.Param("name")
+
+	err := h.profiles.Delete(c.Request.Context(), name)
+	switch {
+	case errors.Is(err, profiles.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	case errors.Is(err, profiles.ErrBuiltIn), errors.Is(err, profiles.ErrInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Error deleting profile %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete profile"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Profile deleted", "name": name})
+}
+
+// nonNilStrings returns an empty slice for nil, so it is encoded as [] rather than null
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
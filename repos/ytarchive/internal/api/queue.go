@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size limits of the queue listing
+const (
+	defaultQueueLimit = 50
+	maxQueueLimit     = 500
+)
+
+// GetQueue handles GET /api/jobs/queue - List queued downloads in the order workers will claim them
+func (h *Handlers) GetQueue(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultQueueLimit)), 10, 64)
+	if err != nil || limit < 1 || limit > maxQueueLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxQueueLimit)})
+		return
+	}
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative number"})
+		return
+	}
+
+	total, err := h.queue.Len(ctx)
+	if err != nil {
+		log.Printf("Error fetching queue length: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+	entries, err := h.queue.Entries(ctx, offset, limit)
+	if err != nil {
+		log.Printf("Error fetching queue: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total": total, "offset": offset, "limit": limit})
+}
+
+// GetVideoQueuePosition handles GET /api/jobs/queue/:video - Get the queue position of a video
+func (h *Handlers) GetVideoQueuePosition(c *gin.Context) {
+	videoID := c.Param("video")
+	ctx := c.Request.Context()
+
+	entry, err := h.queue.Find(ctx, videoID)
+	if err != nil {
+		log.Printf("Error fetching queue position of video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue position"})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video is not queued"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// addQueueInfo sets the queue fields of jobs from the download queue
+func (h *Handlers) addQueueInfo(ctx context.Context, jobs []Job) {
+	channels, err := h.queue.Channels(ctx)
+	if err != nil {
+		log.Printf("Error fetching queue summary: %v", err)
+		return
+	}
+
+	for i := range jobs {
+		summary := channels[jobs[i].ChannelID]
+		jobs[i].Queued = summary.Queued
+		jobs[i].QueuePosition = summary.NextPosition
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+func TestReadsAfterCacheLoss(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("STORAGE_PATH", t.TempDir())
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	state, err := store.Open(ctx, "sqlite://"+filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { state.Close() })
+	records := store.NewRecords(state, client)
+
+	if err := records.PutChannel(ctx, []byte(`{"id":"c1","name":"Channel","profile":"space-saver"}`)); err != nil {
+		t.Fatalf("PutChannel() error = %v", err)
+	}
+	if err := records.PutVideo(ctx, "c1", "v1", []byte(`{"id":"v1","channel_id":"c1","title":"Video","status":"downloaded",`+
+		`"versions":[{"title":"Old","recorded_at":"2024-01-01T00:00:00Z"},{"title":"Video","recorded_at":"2024-02-01T00:00:00Z"}]}`)); err != nil {
+		t.Fatalf("PutVideo() error = %v", err)
+	}
+	if _, err := state.SaveComments(ctx, "c1", "v1", db.CommentKindComment, []db.Comment{{ID: "c-1", Text: "First!"}}); err != nil {
+		t.Fatalf("SaveComments() error = %v", err)
+	}
+
+	if err := records.PutPlaylist(ctx, []byte(`{"id":"p1","youtube_id":"PL123","status":"synced"}`)); err != nil {
+		t.Fatalf("PutPlaylist() error = %v", err)
+	}
+	if _, _, err := state.SavePlaylistItems(ctx, "p1", []db.PlaylistItem{{VideoID: "v1", Position: 1, ChannelID: "c1"}}); err != nil {
+		t.Fatalf("SavePlaylistItems() error = %v", err)
+	}
+
+	// Redis restarts without persistence
+	server.FlushAll()
+	router := SetupRoutes(NewHandlers(client, nil, records))
+
+	tests := []struct {
+		path  string
+		check func(body map[string]interface{}) bool
+	}{
+		{"/api/videos/v1", func(body map[string]interface{}) bool { return body["title"] == "Video" }},
+		{"/api/videos", func(body map[string]interface{}) bool { return body["count"] == float64(1) }},
+		{"/api/channels/c1/videos", func(body map[string]interface{}) bool { return body["count"] == float64(1) }},
+		{"/api/videos/v1/history", func(body map[string]interface{}) bool { return body["count"] == float64(2) }},
+		{"/api/videos/v1/comments", func(body map[string]interface{}) bool { return body["total"] == float64(1) }},
+		{"/api/playlists", func(body map[string]interface{}) bool { return body["count"] == float64(1) }},
+		{"/api/playlists/p1/videos", func(body map[string]interface{}) bool {
+			videos, _ := body["videos"].([]interface{})
+			if len(videos) != 1 {
+				return false
+			}
+			entry, _ := videos[0].(map[string]interface{})
+			return entry["status"] == "downloaded"
+		}},
+		{"/api/stats", func(body map[string]interface{}) bool { return body["total_channels"] == float64(1) }},
+		{"/api/workers/videos/c1/v1", func(body map[string]interface{}) bool {
+			profile, _ := body["profile"].(map[string]interface{})
+			return profile["name"] == "space-saver"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d, want 200: %s", tt.path, recorder.Code, recorder.Body.String())
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || !tt.check(body) {
+				t.Errorf("GET %s body = %s", tt.path, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/scheduler"
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+// PruneChannel handles POST /api/channels/:id/prune - Apply the channel's retention policy now.
+// With ?dry_run=true nothing is deleted; a policy in the request body is previewed
+// instead of the channel's own, so a policy can be checked before it is saved.
+func (h *Handlers) PruneChannel(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	var policy *storage.RetentionPolicy
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		if !dryRun {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A policy in the request body can only be used with dry_run=true"})
+			return
+		}
+		if err := policy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid retention policy: " + err.Error()})
+			return
+		}
+	}
+
+	var plan *scheduler.RetentionPlan
+	var err error
+	if policy != nil {
+		plan, err = h.scheduler.PlanRetention(ctx, channelID, *policy)
+	} else {
+		plan, err = h.scheduler.EnforceRetention(ctx, channelID, dryRun)
+	}
+	if err != nil {
+		log.Printf("Error applying retention policy for channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply retention policy"})
+		return
+	}
+
+	if !dryRun {
+		log.Printf("Retention policy applied to channel %s: %d of %d videos pruned", channelID, plan.Pruned, len(plan.Videos))
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// PruneAllChannels handles POST /api/prune - Apply every channel's retention policy now.
+// With ?dry_run=true nothing is deleted.
+func (h *Handlers) PruneAllChannels(c *gin.Context) {
+	ctx := c.Request.Context()
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	channelIDs, err := h.records.ChannelIDs(ctx)
+	if err != nil {
+		log.Printf("Error fetching channel list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
+		return
+	}
+
+	plans := make([]*scheduler.RetentionPlan, 0)
+	var totalBytes int64
+	var totalVideos, totalPruned int
+	for _, channelID := range channelIDs {
+		policy, err := h.scheduler.GetRetentionPolicy(ctx, channelID)
+		if err != nil || policy.IsZero() {
+			continue
+		}
+
+		plan, err := h.scheduler.EnforceRetention(ctx, channelID, dryRun)
+		if err != nil {
+			log.Printf("Error applying retention policy for channel %s: %v", channelID, err)
+			continue
+		}
+
+		plans = append(plans, plan)
+		totalBytes += plan.Bytes
+		totalVideos += len(plan.Videos)
+		totalPruned += plan.Pruned
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":  dryRun,
+		"channels": plans,
+		"videos":   totalVideos,
+		"bytes":    totalBytes,
+		"pruned":   totalPruned,
+	})
+}
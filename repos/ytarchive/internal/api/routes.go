@@ -9,6 +9,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/timholm/ytarchive/internal/auth"
 	"github.com/timholm/ytarchive/web"
 )
 
@@ -22,7 +24,7 @@ func SetupRoutes(handlers *Handlers) *gin.Engine {
 	// Add middleware
 	router.Use(gin.Recovery())
 	router.Use(requestLogger())
-	router.Use(corsMiddleware())
+	router.Use(corsMiddleware(handlers.authEnabled))
 
 	// Health check handler
 	healthHandler := func(c *gin.Context) {
@@ -91,72 +93,158 @@ func SetupRoutes(handlers *Handlers) *gin.Engine {
 	// Prometheus metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Podcast feeds, authorized by a signed token in the URL since podcast apps cannot send auth headers
+	feeds := router.Group("/feeds")
+	{
+		feeds.GET("/channels/:id", handlers.GetChannelFeed)
+		feeds.GET("/channels/:id/chapters/:video", handlers.GetFeedChapters)
+		feeds.GET("/channels/:id/videos/:video/:file", handlers.GetFeedMedia)
+	}
+
+	// Sign-in, before authentication so people without a session can reach it
+	router.POST("/api/auth/login", handlers.Login)
+	router.POST("/api/auth/logout", handlers.Logout)
+
+	// Roles needed beyond authentication; viewers can read everything else
+	curator := handlers.requireRole(auth.RoleCurator)
+	admin := handlers.requireRole(auth.RoleAdmin)
+	worker := handlers.requireRole(auth.RoleWorker)
+
 	// API routes
-	api := router.Group("/api")
+	api := router.Group("/api", handlers.authenticate())
 	{
+		viewer := api.Group("", handlers.requireRole(auth.RoleViewer))
+
 		// Dashboard endpoints
-		api.GET("/stats", handlers.GetStats)
-		api.GET("/activity", handlers.GetActivity)
+		viewer.GET("/stats", handlers.GetStats)
+		viewer.GET("/activity", handlers.GetActivity)
+
+		// Auth endpoints - who am I, worker tokens, and users and API keys for admins
+		api.GET("/auth/me", handlers.GetCurrentUser)
+		api.POST("/auth/worker-token", worker, handlers.IssueWorkerToken)
+		authAdmin := api.Group("/auth", admin)
+		{
+			authAdmin.GET("/users", handlers.ListUsers)
+			authAdmin.PUT("/users/:username", handlers.PutUser)
+			authAdmin.DELETE("/users/:username", handlers.DeleteUser)
+			authAdmin.GET("/keys", handlers.ListAPIKeys)
+			authAdmin.POST("/keys", handlers.CreateAPIKey)
+			authAdmin.DELETE("/keys/:id", handlers.DeleteAPIKey)
+		}
 
 		// Channel endpoints
-		channels := api.Group("/channels")
+		channels := viewer.Group("/channels")
 		{
-			channels.POST("", handlers.AddChannel)
+			channels.POST("", curator, handlers.AddChannel)
 			channels.GET("", handlers.ListChannels)
 			channels.GET("/:id", handlers.GetChannel)
-			channels.POST("/:id/sync", handlers.SyncChannel)
-			channels.POST("/:id/index", handlers.IndexChannelVideos)
-			channels.DELETE("/:id", handlers.DeleteChannel)
+			channels.PATCH("/:id", curator, handlers.UpdateChannel)
+			channels.POST("/:id/sync", curator, handlers.SyncChannel)
+			channels.POST("/:id/index", curator, handlers.IndexChannelVideos)
+			channels.DELETE("/:id", admin, handlers.DeleteChannel)
 			channels.GET("/:id/videos", handlers.GetChannelVideos)
+			channels.GET("/:id/syncs", handlers.GetChannelSyncHistory)
+			channels.GET("/:id/schedule", handlers.GetChannelSchedule)
+			channels.PUT("/:id/schedule", curator, handlers.SetChannelSchedule)
+			channels.DELETE("/:id/schedule", curator, handlers.DeleteChannelSchedule)
+			channels.POST("/:id/prune", curator, handlers.PruneChannel)
+			channels.GET("/:id/feed", handlers.GetChannelFeedURLs)
+			channels.POST("/:id/hls", curator, handlers.PrebuildChannelHLS)
+		}
+
+		// Playlist endpoints
+		playlists := viewer.Group("/playlists")
+		{
+			playlists.POST("", curator, handlers.AddPlaylist)
+			playlists.GET("", handlers.ListPlaylists)
+			playlists.GET("/:id", handlers.GetPlaylist)
+			playlists.POST("/:id/sync", curator, handlers.SyncPlaylist)
+			playlists.DELETE("/:id", admin, handlers.DeletePlaylist)
+			playlists.GET("/:id/videos", handlers.GetPlaylistVideos)
 		}
 
 		// Index endpoint - rebuild FTS index for all channels
-		api.POST("/index", handlers.IndexAllChannels)
+		api.POST("/index", curator, handlers.IndexAllChannels)
+
+		// Retention endpoint - apply retention policies for all channels
+		api.POST("/prune", admin, handlers.PruneAllChannels)
+
+		// Import endpoints - adopt videos downloaded with yt-dlp
+		api.POST("/import", admin, handlers.StartImport)
+		api.GET("/import", curator, handlers.GetImport)
 
-		// Search endpoint
-		api.GET("/search", handlers.SearchVideos)
+		// Search endpoints - videos, and moments in their transcripts
+		viewer.GET("/search", handlers.SearchVideos)
+		viewer.GET("/search/transcripts", handlers.SearchTranscripts)
 
 		// Video endpoints
-		videos := api.Group("/videos")
+		videos := viewer.Group("/videos")
 		{
 			videos.GET("", handlers.ListVideos)
 			videos.GET("/:id", handlers.GetVideo)
 			videos.GET("/:id/stream", handlers.GetVideoStream)
+			videos.GET("/:id/hls/*file", handlers.GetVideoHLS)
 			videos.GET("/:id/thumbnail", handlers.GetVideoThumbnail)
 			videos.GET("/:id/metadata", handlers.GetVideoMetadata)
 			videos.GET("/:id/audio", handlers.GetVideoAudio)
 			videos.GET("/:id/subtitles", handlers.GetVideoSubtitles)
 			videos.GET("/:id/subtitles/list", handlers.ListVideoSubtitles)
 			videos.GET("/:id/files", handlers.GetVideoFiles)
-			videos.POST("/:id/download", handlers.TriggerDownload)
-			videos.POST("/:id/status", handlers.UpdateVideoStatus)
+			videos.GET("/:id/history", handlers.GetVideoHistory)
+			videos.GET("/:id/comments", handlers.GetVideoComments)
+			videos.POST("/:id/download", curator, handlers.TriggerDownload)
 		}
 
 		// Job endpoints
-		jobs := api.Group("/jobs")
+		jobs := viewer.Group("/jobs")
 		{
 			jobs.GET("", handlers.ListJobs)
 			jobs.GET("/progress", handlers.GetJobsProgress)
+			jobs.GET("/queue", handlers.GetQueue)
+			jobs.GET("/queue/:video", handlers.GetVideoQueuePosition)
 			jobs.GET("/:id", handlers.GetJob)
-			jobs.POST("/:id/cancel", handlers.CancelJob)
+			jobs.POST("/:id/cancel", curator, handlers.CancelJob)
 		}
 
 		// Progress endpoint (legacy, kept for compatibility)
-		api.GET("/progress", handlers.GetProgress)
+		viewer.GET("/progress", handlers.GetProgress)
 
-		// Worker progress reporting endpoint
-		api.POST("/progress/:id", handlers.UpdateProgress)
+		// Worker reporting endpoints, limited to the videos a worker has claimed
+		api.POST("/progress/:id", worker, handlers.requireClaim(), handlers.UpdateProgress)
+		api.POST("/videos/:id/status", worker, handlers.requireClaim(), handlers.UpdateVideoStatus)
+
+		// Worker lookups, also before a claim, so a retried batch can skip what it downloaded
+		api.GET("/workers/videos/:channel/:id", worker, handlers.GetWorkerVideo)
 
 		// Downloads progress endpoint - returns all active download progress
-		downloads := api.Group("/downloads")
+		downloads := viewer.Group("/downloads")
 		{
 			downloads.GET("/progress", handlers.GetDownloadsProgress)
 		}
 
 		// Cookies endpoints
-		api.GET("/cookies", handlers.GetCookies)
-		api.POST("/cookies", handlers.SaveCookies)
-		api.DELETE("/cookies", handlers.DeleteCookies)
+		api.GET("/cookies", admin, handlers.GetCookies)
+		api.POST("/cookies", admin, handlers.SaveCookies)
+		api.DELETE("/cookies", admin, handlers.DeleteCookies)
+
+		// Credential pool endpoints - the cookie sets workers rotate between
+		creds := api.Group("/credentials", admin)
+		{
+			creds.GET("", handlers.ListCredentials)
+			creds.PUT("/:name", handlers.PutCredential)
+			creds.DELETE("/:name", handlers.DeleteCredential)
+			creds.POST("/:name/check", handlers.CheckCredential)
+			creds.POST("/:name/restore", handlers.RestoreCredential)
+		}
+
+		// Quality profile endpoints - anyone can read them, admins change them
+		profiles := viewer.Group("/profiles")
+		{
+			profiles.GET("", handlers.ListProfiles)
+			profiles.GET("/:name", handlers.GetProfile)
+			profiles.PUT("/:name", admin, handlers.PutProfile)
+			profiles.DELETE("/:name", admin, handlers.DeleteProfile)
+		}
 	}
 
 	return router
@@ -196,15 +284,18 @@ func requestLogger() gin.HandlerFunc {
 }
 
 // corsMiddleware adds CORS headers to responses
-func corsMiddleware() gin.HandlerFunc {
-	// Get CORS origin from environment variable, default to "*" if not set
+func corsMiddleware(authEnabled bool) gin.HandlerFunc {
+	// Get CORS origin from environment variable. Without one, any origin may
+	// call an open API, but only the web UI's own origin one that needs auth.
 	corsOrigin := os.Getenv("CORS_ORIGIN")
-	if corsOrigin == "" {
+	if corsOrigin == "" && !authEnabled {
 		corsOrigin = "*"
 	}
 
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", corsOrigin)
+		if corsOrigin != "" {
+			c.Header("Access-Control-Allow-Origin", corsOrigin)
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
 		c.Header("Access-Control-Max-Age", "86400")
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// SetScheduleRequest is the request body for setting a channel's sync schedule.
+// Exactly one of Cron and IntervalHours must be set.
+type SetScheduleRequest struct {
+	Cron          string  `json:"cron"`
+	IntervalHours float64 `json:"interval_hours"`
+	Enabled       *bool   `json:"enabled"` // defaults to true
+}
+
+// GetChannelSchedule handles GET /api/channels/:id/schedule
+func (h *Handlers) GetChannelSchedule(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	schedule, err := h.scheduler.GetSchedule(ctx, channelID)
+	if err != nil {
+		log.Printf("Error fetching schedule for channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return
+	}
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel has no schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// SetChannelSchedule handles PUT /api/channels/:id/schedule
+func (h *Handlers) SetChannelSchedule(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	var req SetScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	schedule, err := h.scheduler.SetSchedule(ctx, channelID, req.Cron, req.IntervalHours, enabled)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+		return
+	}
+
+	log.Printf("Schedule set for channel %s (cron=%q, interval_hours=%v, enabled=%v)", channelID, req.Cron, req.IntervalHours, enabled)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteChannelSchedule handles DELETE /api/channels/:id/schedule
+func (h *Handlers) DeleteChannelSchedule(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	if err := h.scheduler.DeleteSchedule(ctx, channelID); err != nil {
+		log.Printf("Error deleting schedule for channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		return
+	}
+
+	log.Printf("Schedule deleted for channel %s", channelID)
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted successfully"})
+}
+
+// channelExists writes a 404 or 500 response and returns false if the channel cannot be found
+func (h *Handlers) channelExists(c *gin.Context, channelID string) bool {
+	_, err := h.records.Channel(c.Request.Context(), channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return false
+	}
+	if err != nil {
+		log.Printf("Error checking channel existence: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check channel"})
+		return false
+	}
+	return true
+}
+
+// fillNextSyncAt sets NextSyncAt from the channel's schedule, if it has an enabled one
+func (h *Handlers) fillNextSyncAt(ctx context.Context, channel *Channel) {
+	schedule, err := h.scheduler.GetSchedule(ctx, channel.ID)
+	if err != nil {
+		log.Printf("Error fetching schedule for channel %s: %v", channel.ID, err)
+		return
+	}
+	if schedule != nil {
+		channel.NextSyncAt = schedule.NextSyncAt
+	}
+}
@@ -0,0 +1,464 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// Page size limits of search results
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// Cues of surrounding text returned on each side of a transcript hit
+const (
+	defaultTranscriptContext = 2
+	maxTranscriptContext     = 10
+)
+
+// SearchHit is a video matching a search, with the matches highlighted
+type SearchHit struct {
+	Video
+	ChannelName    string  `json:"channel_name,omitempty"`
+	TitleHighlight string  `json:"title_highlight,omitempty"` // HTML escaped title with the matches in <mark>
+	Snippet        string  `json:"snippet,omitempty"`         // HTML escaped best matching passage with the matches in <mark>
+	Rank           float64 `json:"rank"`                      // lower is more relevant
+}
+
+// TranscriptHit is a moment in a video where its transcript matches a search
+type TranscriptHit struct {
+	VideoID       string  `json:"video_id"`
+	ChannelID     string  `json:"channel_id"`
+	ChannelName   string  `json:"channel_name,omitempty"`
+	Title         string  `json:"title"`
+	Language      string  `json:"language"`
+	Source        string  `json:"source"` // "subtitles" or "captions"
+	Start         float64 `json:"start"`  // seconds into the video
+	StartMs       int64   `json:"start_ms"`
+	EndMs         int64   `json:"end_ms"`
+	Text          string  `json:"text"`           // HTML escaped cue text with the matches in <mark>
+	ContextBefore string  `json:"context_before"` // HTML escaped text of the cues before
+	ContextAfter  string  `json:"context_after"`  // HTML escaped text of the cues after
+	URL           string  `json:"url"`            // web UI link opening the player at the moment
+	Rank          float64 `json:"rank"`           // lower is more relevant
+}
+
+// SearchFacetCount is the number of matching videos with one value of a facet
+type SearchFacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"` // channel name for the channel facet
+	Count int    `json:"count"`
+}
+
+// SearchVideos handles GET /api/search - Full-text search of the titles,
+// descriptions, chapters and transcripts of the videos of all channels.
+// Until the global search index has been built, falls back to searching
+// the titles and descriptions in each channel's database.
+func (h *Handlers) SearchVideos(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	search, err := parseIndexSearch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		log.Printf("Error opening search index: %v", err)
+		h.searchChannelDatabases(c, search)
+		return
+	}
+	defer index.Close()
+
+	if count, err := db.CountIndexedVideos(index); err != nil || count == 0 {
+		h.searchChannelDatabases(c, search)
+		return
+	}
+
+	result, err := db.SearchIndex(index, search)
+	if err != nil {
+		log.Printf("Error searching index: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search videos"})
+		return
+	}
+
+	channelNames := h.channelNames(ctx, result)
+	hits, err := h.searchHits(ctx, result.Hits, channelNames)
+	if err != nil {
+		log.Printf("Error fetching search hits: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search videos"})
+		return
+	}
+
+	facets := make(map[string][]SearchFacetCount, len(result.Facets))
+	for facet, counts := range result.Facets {
+		facetCounts := make([]SearchFacetCount, 0, len(counts))
+		for _, count := range counts {
+			facetCount := SearchFacetCount{Value: count.Value, Count: count.Count}
+			if facet == db.FacetChannel {
+				facetCount.Label = channelNames[count.Value]
+			}
+			facetCounts = append(facetCounts, facetCount)
+		}
+		facets[facet] = facetCounts
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   search.Query,
+		"results": hits,
+		"count":   len(hits),
+		"total":   result.Total,
+		"offset":  search.Offset,
+		"limit":   search.Limit,
+		"facets":  facets,
+	})
+}
+
+// parseIndexSearch reads the search and its filters from the query parameters
+func parseIndexSearch(c *gin.Context) (db.IndexSearch, error) {
+	search := db.IndexSearch{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Status: c.Query("status"),
+		Kind:   c.Query("kind"),
+	}
+	if search.Query == "" {
+		return search, fmt.Errorf("Search query 'q' is required")
+	}
+
+	if channels := c.Query("channel"); channels != "" {
+		for _, channelID := range strings.Split(channels, ",") {
+			if channelID = strings.TrimSpace(channelID); channelID != "" {
+				search.ChannelIDs = append(search.ChannelIDs, channelID)
+			}
+		}
+	}
+	if removed, err := strconv.ParseBool(c.Query("removed")); err == nil {
+		search.Removed = &removed
+	}
+
+	var err error
+	if search.UploadedFrom, err = parseSearchDate(c.Query("from")); err != nil {
+		return search, fmt.Errorf("from %w", err)
+	}
+	if search.UploadedTo, err = parseSearchDate(c.Query("to")); err != nil {
+		return search, fmt.Errorf("to %w", err)
+	}
+
+	if duration := c.Query("duration"); duration != "" {
+		if search.MinDuration, search.MaxDuration, err = db.DurationRange(duration); err != nil {
+			return search, fmt.Errorf("duration must be short, medium or long")
+		}
+	}
+	if minDuration := c.Query("min_duration"); minDuration != "" {
+		if search.MinDuration, err = strconv.ParseInt(minDuration, 10, 64); err != nil || search.MinDuration < 0 {
+			return search, fmt.Errorf("min_duration must be a number of seconds")
+		}
+	}
+	if maxDuration := c.Query("max_duration"); maxDuration != "" {
+		if search.MaxDuration, err = strconv.ParseInt(maxDuration, 10, 64); err != nil || search.MaxDuration < 0 {
+			return search, fmt.Errorf("max_duration must be a number of seconds")
+		}
+	}
+
+	search.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || search.Limit < 1 || search.Limit > maxSearchLimit {
+		return search, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+	}
+	search.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || search.Offset < 0 {
+		return search, fmt.Errorf("offset must be a non-negative number")
+	}
+
+	return search, nil
+}
+
+// parseSearchDate converts a YYYY-MM-DD or YYYYMMDD date to the YYYYMMDD
+// form upload dates are stored in
+func parseSearchDate(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	date := strings.ReplaceAll(value, "-", "")
+	if _, err := time.Parse("20060102", date); err != nil {
+		return "", fmt.Errorf("must be a date as YYYY-MM-DD")
+	}
+	return date, nil
+}
+
+// searchHits returns the video records of index hits, falling back to what
+// the index holds for videos whose record is gone
+func (h *Handlers) searchHits(ctx context.Context, indexHits []db.IndexHit, channelNames map[string]string) ([]SearchHit, error) {
+	hits := make([]SearchHit, 0, len(indexHits))
+	if len(indexHits) == 0 {
+		return hits, nil
+	}
+
+	for _, indexHit := range indexHits {
+		video := Video{
+			ID:          indexHit.VideoID,
+			YouTubeID:   indexHit.VideoID,
+			ChannelID:   indexHit.ChannelID,
+			Title:       indexHit.Title,
+			Description: indexHit.Description,
+			Duration:    int(indexHit.Duration),
+			UploadDate:  indexHit.UploadDate,
+			Kind:        indexHit.Kind,
+			Status:      indexHit.Status,
+			RemovedAt:   indexHit.RemovedAt,
+		}
+		record, err := h.records.Video(ctx, indexHit.ChannelID, indexHit.VideoID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			if err := json.Unmarshal(record, &video); err != nil {
+				log.Printf("Error unmarshaling video %s: %v", indexHit.VideoID, err)
+			}
+		}
+
+		hits = append(hits, SearchHit{
+			Video:          video,
+			ChannelName:    channelNames[indexHit.ChannelID],
+			TitleHighlight: indexHit.TitleHighlight,
+			Snippet:        indexHit.Snippet,
+			Rank:           indexHit.Rank,
+		})
+	}
+
+	return hits, nil
+}
+
+// channelNames returns the names of the channels among the hits and the channel facet
+func (h *Handlers) channelNames(ctx context.Context, result *db.IndexSearchResult) map[string]string {
+	var channelIDs []string
+	for _, hit := range result.Hits {
+		channelIDs = append(channelIDs, hit.ChannelID)
+	}
+	for _, count := range result.Facets[db.FacetChannel] {
+		channelIDs = append(channelIDs, count.Value)
+	}
+	return h.channelNamesByID(ctx, channelIDs)
+}
+
+// channelNamesByID returns the names of channels by their IDs, which may repeat
+func (h *Handlers) channelNamesByID(ctx context.Context, ids []string) map[string]string {
+	names := make(map[string]string)
+
+	seen := make(map[string]bool)
+	var channelIDs []string
+	for _, channelID := range ids {
+		if !seen[channelID] {
+			seen[channelID] = true
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+	if len(channelIDs) == 0 {
+		return names
+	}
+
+	for _, channelID := range channelIDs {
+		data, err := h.records.Channel(ctx, channelID)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				log.Printf("Error fetching channel name of %s: %v", channelID, err)
+			}
+			continue
+		}
+		var channel Channel
+		if err := json.Unmarshal(data, &channel); err == nil {
+			names[channelID] = channel.Name
+		}
+	}
+	return names
+}
+
+// SearchTranscripts handles GET /api/search/transcripts - Full-text search of
+// what is said in the videos of all channels. Each result is a caption with
+// the time it is shown, the text around it and a link that opens the player
+// at that moment.
+func (h *Handlers) SearchTranscripts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	search, err := parseTranscriptSearch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		log.Printf("Error opening search index: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Search index is unavailable"})
+		return
+	}
+	defer index.Close()
+
+	result, err := db.SearchTranscripts(index, search)
+	if err != nil {
+		log.Printf("Error searching transcripts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search transcripts"})
+		return
+	}
+
+	channelIDs := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		channelIDs[i] = hit.ChannelID
+	}
+	channelNames := h.channelNamesByID(ctx, channelIDs)
+
+	hits := make([]TranscriptHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		seconds := hit.StartMs / 1000
+		hits = append(hits, TranscriptHit{
+			VideoID:       hit.VideoID,
+			ChannelID:     hit.ChannelID,
+			ChannelName:   channelNames[hit.ChannelID],
+			Title:         hit.Title,
+			Language:      hit.Language,
+			Source:        hit.Source,
+			Start:         float64(hit.StartMs) / 1000,
+			StartMs:       hit.StartMs,
+			EndMs:         hit.EndMs,
+			Text:          hit.Highlight,
+			ContextBefore: hit.Before,
+			ContextAfter:  hit.After,
+			URL:           fmt.Sprintf("/#/videos/%s?t=%d", hit.VideoID, seconds),
+			Rank:          hit.Rank,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   search.Query,
+		"results": hits,
+		"count":   len(hits),
+		"total":   result.Total,
+		"offset":  search.Offset,
+		"limit":   search.Limit,
+	})
+}
+
+// parseTranscriptSearch reads a transcript search and its filters from the query parameters
+func parseTranscriptSearch(c *gin.Context) (db.TranscriptSearch, error) {
+	search := db.TranscriptSearch{
+		Query:    strings.TrimSpace(c.Query("q")),
+		VideoID:  c.Query("video"),
+		Language: c.Query("lang"),
+	}
+	if search.Query == "" {
+		return search, fmt.Errorf("Search query 'q' is required")
+	}
+
+	if channels := c.Query("channel"); channels != "" {
+		for _, channelID := range strings.Split(channels, ",") {
+			if channelID = strings.TrimSpace(channelID); channelID != "" {
+				search.ChannelIDs = append(search.ChannelIDs, channelID)
+			}
+		}
+	}
+
+	var err error
+	search.Context, err = strconv.Atoi(c.DefaultQuery("context", strconv.Itoa(defaultTranscriptContext)))
+	if err != nil || search.Context < 0 || search.Context > maxTranscriptContext {
+		return search, fmt.Errorf("context must be between 0 and %d", maxTranscriptContext)
+	}
+	search.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || search.Limit < 1 || search.Limit > maxSearchLimit {
+		return search, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+	}
+	search.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || search.Offset < 0 {
+		return search, fmt.Errorf("offset must be a non-negative number")
+	}
+
+	return search, nil
+}
+
+// searchChannelDatabases searches the titles and descriptions in each
+// channel's database, for when the global search index has not been built
+func (h *Handlers) searchChannelDatabases(c *gin.Context, search db.IndexSearch) {
+	ctx := c.Request.Context()
+
+	// If no channel specified, search across all channels
+	channelIDs := search.ChannelIDs
+	if len(channelIDs) == 0 {
+		var err error
+		channelIDs, err = h.records.ChannelIDs(ctx)
+		if err != nil {
+			log.Printf("Error fetching channel list: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
+			return
+		}
+	}
+
+	allResults := make([]gin.H, 0)
+	for _, chID := range channelIDs {
+		if chID == "" {
+			continue
+		}
+
+		// Open the channel's SQLite database
+		channelDB, err := db.OpenChannelDB(chID)
+		if err != nil {
+			log.Printf("Error opening channel DB for %s: %v", chID, err)
+			continue
+		}
+
+		var results []db.SearchResult
+		if search.Status != "" {
+			results, err = db.SearchVideosWithFilter(channelDB, search.Query, db.VideoStatus(search.Status), search.Limit)
+		} else {
+			results, err = db.SearchVideos(channelDB, search.Query, search.Limit)
+		}
+		channelDB.Close()
+
+		if err != nil {
+			log.Printf("Error searching channel %s: %v", chID, err)
+			continue
+		}
+
+		for _, r := range results {
+			if search.Kind != "" && r.Kind != search.Kind {
+				continue
+			}
+			if search.Removed != nil && (r.RemovedAt != nil) != *search.Removed {
+				continue
+			}
+			allResults = append(allResults, gin.H{
+				"id":             r.ID,
+				"channel_id":     chID,
+				"title":          r.Title,
+				"description":    r.Description,
+				"duration":       r.Duration,
+				"upload_date":    r.UploadDate,
+				"kind":           r.Kind,
+				"status":         r.Status,
+				"file_path":      r.FilePath,
+				"file_size":      r.FileSize,
+				"removed_at":     r.RemovedAt,
+				"removed_reason": r.RemovedReason,
+				"rank":           r.Rank,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   search.Query,
+		"results": allResults,
+		"count":   len(allResults),
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/scheduler"
+)
+
+const (
+	defaultSyncHistoryLimit = 20
+	maxSyncHistoryLimit     = 200
+)
+
+// GetChannelSyncHistory handles GET /api/channels/:id/syncs - the sync jobs of a channel, latest first
+func (h *Handlers) GetChannelSyncHistory(c *gin.Context) {
+	channelID := c.Param("id")
+	ctx := c.Request.Context()
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSyncHistoryLimit)))
+	if err != nil || limit < 1 || limit > maxSyncHistoryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSyncHistoryLimit)})
+		return
+	}
+
+	if !h.channelExists(c, channelID) {
+		return
+	}
+
+	jobs, err := h.records.Store().ListSyncJobs(ctx, channelID, limit)
+	if err != nil {
+		log.Printf("Error fetching sync history of channel %s: %v", channelID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync history"})
+		return
+	}
+
+	syncs := make([]scheduler.SyncJob, 0, len(jobs))
+	for _, job := range jobs {
+		var syncJob scheduler.SyncJob
+		if err := json.Unmarshal(job.Document(), &syncJob); err != nil {
+			log.Printf("Error unmarshaling sync job %s: %v", job.ID, err)
+			continue
+		}
+		syncs = append(syncs, syncJob)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"syncs": syncs, "count": len(syncs), "channel_id": channelID})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// WorkerVideo is what a worker needs to download a video: its record and the
+// quality profile of its channel, nil for the worker's default
+type WorkerVideo struct {
+	Video   json.RawMessage     `json:"video"`
+	Profile *downloader.Profile `json:"profile"`
+}
+
+// GetWorkerVideo handles GET /api/workers/videos/:channel/:id - the record of
+// a video of a channel or playlist for a worker, which does not read the
+// records itself
+func (h *Handlers) GetWorkerVideo(c *gin.Context) {
+	channelID := c.Param("channel")
+	videoID := c.Param("id")
+	ctx := c.Request.Context()
+
+	videoData, err := h.records.Video(ctx, channelID, videoID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching video %s: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch video"})
+		return
+	}
+
+	response := WorkerVideo{Video: videoData}
+	profile, err := h.profiles.ForChannel(ctx, channelID)
+	if err != nil {
+		log.Printf("Error fetching profile of channel %s, the worker uses its default: %v", channelID, err)
+	} else {
+		response.Profile = profile
+	}
+	c.JSON(http.StatusOK, response)
+}
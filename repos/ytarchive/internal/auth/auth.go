@@ -0,0 +1,67 @@
+// Package auth authenticates users, services and workers of the REST API and
+// decides what each may do.
+//
+// People sign in with a user account and get a session token; services such
+// as the collector or scripts use API keys. Every user and API key has a Role.
+// Workers authenticate with a worker API key, which can only be exchanged for
+// a short-lived token scoped to one worker ID; that token can only report
+// progress for the downloads the worker has claimed.
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Role is what a user or API key may do
+type Role string
+
+const (
+	// RoleViewer can browse and stream the archive
+	RoleViewer Role = "viewer"
+	// RoleCurator can also add, sync and configure channels and playlists
+	RoleCurator Role = "curator"
+	// RoleAdmin can also delete channels, manage cookies, users and API keys
+	RoleAdmin Role = "admin"
+	// RoleWorker is for download workers; it is separate from the other roles
+	RoleWorker Role = "worker"
+)
+
+// ErrInvalidCredentials is returned when a password, API key or token is not valid
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// rank orders the roles people hold; a higher rank includes the lower ones
+var rank = map[Role]int{
+	RoleViewer:  1,
+	RoleCurator: 2,
+	RoleAdmin:   3,
+}
+
+// ParseRole checks that a role name is one of the roles
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := rank[role]; ok || role == RoleWorker {
+		return role, nil
+	}
+	return "", fmt.Errorf("unknown role %q, expected viewer, curator, admin or worker", name)
+}
+
+// Allows reports whether a holder of the role may do what required allows.
+// Admins may do anything; workers may only do what workers do.
+func (r Role) Allows(required Role) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	if r == RoleWorker || required == RoleWorker {
+		return r == required
+	}
+	return rank[r] >= rank[required]
+}
+
+// Principal is who a request was made by
+type Principal struct {
+	Name     string `json:"name"` // username, or API key name
+	Role     Role   `json:"role"`
+	KeyID    string `json:"key_id,omitempty"`    // API key used, directly or to get a worker token
+	WorkerID string `json:"worker_id,omitempty"` // set for worker tokens only
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleCurator, false},
+		{RoleCurator, RoleViewer, true},
+		{RoleCurator, RoleAdmin, false},
+		{RoleAdmin, RoleCurator, true},
+		{RoleAdmin, RoleWorker, true},
+		{RoleWorker, RoleWorker, true},
+		{RoleWorker, RoleViewer, false},
+		{RoleCurator, RoleWorker, false},
+		{"", RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("Role(%q).Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, name := range []string{"viewer", "curator", "admin", "worker"} {
+		if role, err := ParseRole(name); err != nil || string(role) != name {
+			t.Errorf("ParseRole(%q) = %q, %v", name, role, err)
+		}
+	}
+	for _, name := range []string{"", "Admin", "owner"} {
+		if _, err := ParseRole(name); err == nil {
+			t.Errorf("ParseRole(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+
+	token, expires := signer.Issue(Claims{Kind: KindWorker, Subject: "workers", WorkerID: "worker-1", KeyID: "ab12cd34"}, time.Hour)
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("Issue() expires at %v, want %v", expires, now.Add(time.Hour))
+	}
+	if strings.ContainsAny(token, "+/= ") {
+		t.Errorf("token %q is not URL safe", token)
+	}
+
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.Kind != KindWorker || claims.WorkerID != "worker-1" || claims.KeyID != "ab12cd34" || claims.Subject != "workers" {
+		t.Errorf("Verify() = %+v", claims)
+	}
+
+	other := NewSigner([]byte("other"))
+	other.now = signer.now
+	otherToken, _ := other.Issue(Claims{Kind: KindWorker, WorkerID: "worker-1"}, time.Hour)
+	payload, signature, _ := strings.Cut(token, ".")
+	forged, _ := signer.Issue(Claims{Kind: KindWorker, WorkerID: "worker-2"}, 2*time.Hour)
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	invalid := map[string]string{
+		"empty":          "",
+		"no signature":   payload,
+		"other secret":   otherToken,
+		"swapped claims": forgedPayload + "." + signature,
+	}
+	for name, token := range invalid {
+		if _, err := signer.Verify(token); err != ErrInvalidCredentials {
+			t.Errorf("Verify(%s) error = %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := signer.Verify(token); err != ErrInvalidCredentials {
+		t.Errorf("Verify() of an expired token error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	key := FormatAPIKey("ab12cd34", "s3cret")
+	if !IsAPIKey(key) {
+		t.Errorf("IsAPIKey(%q) = false", key)
+	}
+	id, secret, ok := ParseAPIKey(key)
+	if !ok || id != "ab12cd34" || secret != "s3cret" {
+		t.Errorf("ParseAPIKey(%q) = %q, %q, %v", key, id, secret, ok)
+	}
+
+	for _, key := range []string{"", "ab12cd34_s3cret", "yta_", "yta_ab12cd34", "yta__s3cret", "yta_ab12cd34_"} {
+		if _, _, ok := ParseAPIKey(key); ok {
+			t.Errorf("ParseAPIKey(%q) succeeded", key)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	if _, err := HashPassword("short"); err == nil {
+		t.Error("HashPassword() accepted a short password")
+	}
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if strings.Contains(hash, "correct horse") {
+		t.Error("hash contains the password")
+	}
+}
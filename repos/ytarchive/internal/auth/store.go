@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Redis hashes of users by username and API keys by ID
+	usersKey   = "auth:users"
+	apiKeysKey = "auth:apikeys"
+
+	// apiKeyPrefix starts every API key, so keys are told apart from tokens
+	apiKeyPrefix = "yta_"
+
+	// MinPasswordLength is the shortest password accepted for a user
+	MinPasswordLength = 8
+
+	// dummyHash is compared against when a user does not exist
+	dummyHash = "$2a$10$o9M7rxzZXXalS/ShYSEjx.z1QvvW.aF6.P482v8YqoDrfhJUhLFl."
+)
+
+// ErrNotFound is returned for users and API keys that do not exist
+var ErrNotFound = errors.New("not found")
+
+// usernamePattern matches the usernames accepted for users
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@-]{0,63}$`)
+
+// User is a person who signs in to the web UI
+type User struct {
+	Username     string    `json:"username"`
+	Role         Role      `json:"role"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// APIKey is a key services and workers authenticate with. Only a hash of the
+// secret part of the key is stored.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Role       Role      `json:"role"`
+	SecretHash string    `json:"secret_hash,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Store keeps users and API keys in Redis
+type Store struct {
+	redis *redis.Client
+}
+
+// NewStore creates a store backed by a Redis client
+func NewStore(client *redis.Client) *Store {
+	return &Store{redis: client}
+}
+
+// PutUser creates a user, or updates the role and, if password is not empty,
+// the password of an existing one
+func (s *Store) PutUser(ctx context.Context, username, password string, role Role) (*User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, fmt.Errorf("invalid username %q", username)
+	}
+	if role == RoleWorker {
+		return nil, fmt.Errorf("users cannot have the worker role")
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUser(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		if password == "" {
+			return nil, fmt.Errorf("a password is required for a new user")
+		}
+		user = &User{Username: username, CreatedAt: time.Now().UTC()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if password != "" {
+		hash, err := HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = hash
+	}
+	user.Role = role
+	user.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user: %w", err)
+	}
+	if err := s.redis.HSet(ctx, usersKey, username, data).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+	return user, nil
+}
+
+// GetUser returns a user, or ErrNotFound
+func (s *Store) GetUser(ctx context.Context, username string) (*User, error) {
+	data, err := s.redis.HGet(ctx, usersKey, username).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var user User
+	if err := json.Unmarshal([]byte(data), &user); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	return &user, nil
+}
+
+// ListUsers returns every user, by username
+func (s *Store) ListUsers(ctx context.Context) ([]User, error) {
+	values, err := s.redis.HVals(ctx, usersKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	users := make([]User, 0, len(values))
+	for _, data := range values {
+		var user User
+		if err := json.Unmarshal([]byte(data), &user); err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// CountUsers returns the number of users
+func (s *Store) CountUsers(ctx context.Context) (int64, error) {
+	count, err := s.redis.HLen(ctx, usersKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteUser deletes a user, which ends their sessions
+func (s *Store) DeleteUser(ctx context.Context, username string) error {
+	deleted, err := s.redis.HDel(ctx, usersKey, username).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the user with a username and password, or ErrInvalidCredentials
+func (s *Store) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	user, err := s.GetUser(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		// Spend the time a wrong password would, so usernames cannot be probed
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// CreateAPIKey creates an API key and returns it with its record. The key
+// is only ever returned here.
+func (s *Store) CreateAPIKey(ctx context.Context, name string, role Role) (string, *APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("an API key needs a name")
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiKey := &APIKey{
+		ID:         id,
+		Name:       name,
+		Role:       role,
+		SecretHash: hashSecret(secret),
+		CreatedAt:  time.Now().UTC(),
+	}
+	data, err := json.Marshal(apiKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal API key: %w", err)
+	}
+	created, err := s.redis.HSetNX(ctx, apiKeysKey, id, data).Result()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+	if !created {
+		return "", nil, fmt.Errorf("API key ID %s is taken, try again", id)
+	}
+	return FormatAPIKey(id, secret), apiKey, nil
+}
+
+// GetAPIKey returns the record of an API key by ID, or ErrNotFound
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	data, err := s.redis.HGet(ctx, apiKeysKey, id).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	var apiKey APIKey
+	if err := json.Unmarshal([]byte(data), &apiKey); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key: %w", err)
+	}
+	return &apiKey, nil
+}
+
+// LookupAPIKey returns the record of an API key, or ErrInvalidCredentials
+func (s *Store) LookupAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	id, secret, ok := ParseAPIKey(key)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	apiKey, err := s.GetAPIKey(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return apiKey, nil
+}
+
+// ListAPIKeys returns every API key record, oldest first
+func (s *Store) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	values, err := s.redis.HVals(ctx, apiKeysKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]APIKey, 0, len(values))
+	for _, data := range values {
+		var apiKey APIKey
+		if err := json.Unmarshal([]byte(data), &apiKey); err != nil {
+			continue
+		}
+		keys = append(keys, apiKey)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// DeleteAPIKey revokes an API key, and the worker tokens issued for it
+func (s *Store) DeleteAPIKey(ctx context.Context, id string) error {
+	deleted, err := s.redis.HDel(ctx, apiKeysKey, id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// FormatAPIKey returns the key for an API key ID and secret: yta_{id}_{secret}
+func FormatAPIKey(id, secret string) string {
+	return apiKeyPrefix + id + "_" + secret
+}
+
+// ParseAPIKey splits an API key into its ID and secret
+func ParseAPIKey(key string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// IsAPIKey reports whether a credential is shaped like an API key rather than a token
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// hashSecret returns the stored hash of an API key secret. Secrets are
+// random, so a fast hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Token kinds
+const (
+	// KindSession tokens are issued to users when they sign in
+	KindSession = "session"
+	// KindWorker tokens are issued to workers in exchange for a worker API key
+	KindWorker = "worker"
+)
+
+// Claims are the contents of a signed token
+type Claims struct {
+	Kind      string `json:"kind"`
+	Subject   string `json:"sub"`           // username, or the name of the worker API key
+	WorkerID  string `json:"wid,omitempty"` // worker tokens only
+	KeyID     string `json:"kid,omitempty"` // worker API key the token was issued for
+	ExpiresAt int64  `json:"exp"`           // Unix seconds
+}
+
+// Signer issues and checks tokens. A token is its base64 encoded claims and
+// their HMAC, so checking one needs no lookup; what a token grants is still
+// checked against the user or API key it was issued for, so deleting those
+// revokes it. Changing the secret revokes every token.
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewSigner creates a signer with the given secret
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret, now: time.Now}
+}
+
+// Issue returns a token for claims that expires after ttl
+func (s *Signer) Issue(claims Claims, ttl time.Duration) (string, time.Time) {
+	expires := s.now().Add(ttl).Truncate(time.Second)
+	claims.ExpiresAt = expires.Unix()
+
+	data, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + s.sign(payload), expires
+}
+
+// Verify returns the claims of a token that was issued by the signer and has not expired
+func (s *Signer) Verify(token string) (*Claims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return nil, ErrInvalidCredentials
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if s.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidCredentials
+	}
+	return &claims, nil
+}
+
+// sign returns the HMAC of a token payload
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("auth:token:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
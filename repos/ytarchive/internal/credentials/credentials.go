@@ -0,0 +1,133 @@
+// Package credentials keeps a pool of named YouTube cookie sets, one per
+// account, that workers rotate between.
+//
+// A worker uses one set until YouTube refuses it, with 403 Forbidden or a
+// "Sign in to confirm" playability error. It then cools that set down for a
+// while, checks whether it is still signed in, and moves on to the set that
+// has gone unused longest. A set that is no longer signed in is quarantined
+// until an admin replaces its cookies or restores it.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// State is whether workers may use a credential set
+type State string
+
+const (
+	// StateActive sets are handed out to workers
+	StateActive State = "active"
+	// StateQuarantined sets are signed out or expired and are not used until restored
+	StateQuarantined State = "quarantined"
+)
+
+// DefaultSetName names the set that the single cookie blob of earlier
+// versions is moved into, and that the /api/cookies endpoints manage
+const DefaultSetName = "default"
+
+var (
+	// ErrNotFound is returned for credential sets that do not exist
+	ErrNotFound = errors.New("credential set not found")
+	// ErrNoCredentials is returned when no credential set can be used
+	ErrNoCredentials = errors.New("no usable credential set")
+)
+
+// namePattern matches the names accepted for credential sets
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@-]{0,63}$`)
+
+// Set is the cookies of one YouTube account, in Netscape cookies.txt format
+type Set struct {
+	Name          string     `json:"name"`
+	Cookies       string     `json:"cookies,omitempty"`
+	State         State      `json:"state"`
+	Reason        string     `json:"reason,omitempty"` // why the set was quarantined
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	LastCheck     string     `json:"last_check,omitempty"` // signed_in, signed_out, or the error of the last health check
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Usage is kept apart from the set, so workers can count without rewriting it
+	Usage Usage `json:"usage"`
+}
+
+// Usage counts how a credential set has fared with workers
+type Usage struct {
+	Uses          int64      `json:"uses"`     // videos whose streams were fetched with the set
+	Failures      int64      `json:"failures"` // times YouTube refused the set
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"` // the set is skipped until then
+}
+
+// Usable reports whether a set may be handed to a worker at a time
+func (s *Set) Usable(now time.Time) bool {
+	if s.State != StateActive {
+		return false
+	}
+	return s.Usage.CooldownUntil == nil || !now.Before(*s.Usage.CooldownUntil)
+}
+
+// ValidateName checks that a credential set name is accepted
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid credential set name %q", name)
+	}
+	return nil
+}
+
+// choose picks the usable set, other than those excluded, that has gone
+// unused longest; sets never used come first
+func choose(sets []Set, exclude []string, now time.Time) (*Set, bool) {
+	var candidates []Set
+	for _, set := range sets {
+		if !set.Usable(now) || contains(exclude, set.Name) {
+			continue
+		}
+		candidates = append(candidates, set)
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].Usage.LastUsedAt, candidates[j].Usage.LastUsedAt
+		switch {
+		case a == nil && b == nil:
+			return candidates[i].Name < candidates[j].Name
+		case a == nil || b == nil:
+			return a == nil
+		case !a.Equal(*b):
+			return a.Before(*b)
+		default:
+			return candidates[i].Name < candidates[j].Name
+		}
+	})
+	return &candidates[0], true
+}
+
+// contains reports whether a name is in a list
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateError shortens an error message for storing with a set
+func truncateError(err error) string {
+	msg := strings.TrimSpace(err.Error())
+	if len(msg) > 300 {
+		msg = msg[:300] + "..."
+	}
+	return msg
+}
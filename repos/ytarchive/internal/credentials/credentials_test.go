@@ -0,0 +1,156 @@
+package credentials
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChoose(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		sets    []Set
+		exclude []string
+		want    string // empty when no set can be chosen
+	}{
+		{
+			name: "least recently used",
+			sets: []Set{
+				{Name: "a", State: StateActive, Usage: Usage{LastUsedAt: at(-time.Minute)}},
+				{Name: "b", State: StateActive, Usage: Usage{LastUsedAt: at(-time.Hour)}},
+			},
+			want: "b",
+		},
+		{
+			name: "never used first",
+			sets: []Set{
+				{Name: "a", State: StateActive, Usage: Usage{LastUsedAt: at(-time.Hour)}},
+				{Name: "c", State: StateActive},
+				{Name: "b", State: StateActive},
+			},
+			want: "b",
+		},
+		{
+			name: "skips quarantined and cooling down",
+			sets: []Set{
+				{Name: "a", State: StateQuarantined},
+				{Name: "b", State: StateActive, Usage: Usage{CooldownUntil: at(time.Minute)}},
+				{Name: "c", State: StateActive, Usage: Usage{LastUsedAt: at(-time.Second)}},
+			},
+			want: "c",
+		},
+		{
+			name: "cooldown over",
+			sets: []Set{
+				{Name: "a", State: StateActive, Usage: Usage{CooldownUntil: at(-time.Minute)}},
+			},
+			want: "a",
+		},
+		{
+			name: "excluded",
+			sets: []Set{
+				{Name: "a", State: StateActive},
+				{Name: "b", State: StateActive, Usage: Usage{LastUsedAt: at(-time.Minute)}},
+			},
+			exclude: []string{"a"},
+			want:    "b",
+		},
+		{
+			name: "none usable",
+			sets: []Set{
+				{Name: "a", State: StateQuarantined},
+				{Name: "b", State: StateActive},
+			},
+			exclude: []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, ok := choose(tt.sets, tt.exclude, now)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("choose() = %s, want none", set.Name)
+				}
+				return
+			}
+			if !ok || set.Name != tt.want {
+				t.Errorf("choose() = %v, %v, want %s", set, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	usage := parseUsage(map[string]string{
+		usageUses:          "12",
+		usageFailures:      "2",
+		usageLastUsed:      "1717243200",
+		usageLastError:     "video not playable: Sign in to confirm you’re not a bot",
+		usageCooldownUntil: "garbage",
+	})
+
+	if usage.Uses != 12 || usage.Failures != 2 {
+		t.Errorf("counters = %d, %d, want 12, 2", usage.Uses, usage.Failures)
+	}
+	if usage.LastUsedAt == nil || usage.LastUsedAt.Unix() != 1717243200 {
+		t.Errorf("LastUsedAt = %v", usage.LastUsedAt)
+	}
+	if usage.LastFailureAt != nil || usage.CooldownUntil != nil {
+		t.Errorf("missing and invalid timestamps should be nil, got %v and %v", usage.LastFailureAt, usage.CooldownUntil)
+	}
+	if usage.LastError == "" {
+		t.Error("LastError was not read")
+	}
+
+	if empty := parseUsage(nil); empty.Uses != 0 || empty.LastUsedAt != nil {
+		t.Errorf("parseUsage(nil) = %+v, want zero usage", empty)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"default", "archive-bot@gmail.com", "account_2"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-leading", "with space", "a/b", strings.Repeat("x", 65)} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) should fail", name)
+		}
+	}
+}
+
+func TestParseCookies(t *testing.T) {
+	valid := ".youtube.com\tTRUE\t/\tTRUE\t0\tSAPISID\tabc\n.example.com\tTRUE\t/\tTRUE\t0\tother\tx\n"
+	cookies, err := parseCookies(valid)
+	if err != nil {
+		t.Fatalf("parseCookies() error = %v", err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "SAPISID" {
+		t.Errorf("parseCookies() = %v, want only the YouTube cookie", cookies)
+	}
+
+	for _, invalid := range []string{"", "not cookies", ".example.com\tTRUE\t/\tTRUE\t0\tother\tx"} {
+		if _, err := parseCookies(invalid); err == nil {
+			t.Errorf("parseCookies(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestTruncateError(t *testing.T) {
+	long := errors.New(strings.Repeat("e", 400))
+	if got := truncateError(long); len(got) != 303 || !strings.HasSuffix(got, "...") {
+		t.Errorf("truncateError() length = %d, want 300 and an ellipsis", len(got))
+	}
+	if got := truncateError(errors.New(" short ")); got != "short" {
+		t.Errorf("truncateError() = %q, want %q", got, "short")
+	}
+}
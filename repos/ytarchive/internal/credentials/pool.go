@@ -0,0 +1,391 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/ratelimit"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+const (
+	// setsKey is a Redis hash of credential sets by name
+	setsKey = "credentials:sets"
+	// usageKeyPrefix starts the Redis hash of each set's usage counters
+	usageKeyPrefix = "credentials:usage:"
+	// legacyCookiesKey held the one cookie blob of earlier versions
+	legacyCookiesKey = "config:cookies"
+
+	// checkTimeout bounds a health check
+	checkTimeout = 30 * time.Second
+)
+
+// Fields of a set's usage hash
+const (
+	usageUses          = "uses"
+	usageFailures      = "failures"
+	usageLastUsed      = "last_used"
+	usageLastFailure   = "last_failure"
+	usageLastError     = "last_error"
+	usageCooldownUntil = "cooldown_until"
+)
+
+// Pool keeps credential sets and their usage in Redis
+type Pool struct {
+	redis   *redis.Client
+	limiter ratelimit.BudgetLimiter // shared with everyone else calling YouTube
+}
+
+// NewPool creates a pool backed by a Redis client
+func NewPool(client *redis.Client) *Pool {
+	return &Pool{
+		redis:   client,
+		limiter: ratelimit.NewShared(client, ratelimit.SharedConfigFromEnv()),
+	}
+}
+
+// usageKey returns the Redis key of a set's usage counters
+func usageKey(name string) string {
+	return usageKeyPrefix + name
+}
+
+// Put adds a credential set, or replaces the cookies of an existing one. New
+// cookies are a fresh sign-in, so a replaced set is restored and its cooldown
+// cleared.
+func (p *Pool) Put(ctx context.Context, name, cookies string) (*Set, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if _, err := parseCookies(cookies); err != nil {
+		return nil, err
+	}
+
+	set, err := p.Get(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		set = &Set{Name: name, CreatedAt: time.Now().UTC()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	set.Cookies = cookies
+	set.State = StateActive
+	set.Reason = ""
+	set.QuarantinedAt = nil
+	set.LastCheck = ""
+	set.LastCheckedAt = nil
+	if err := p.save(ctx, set); err != nil {
+		return nil, err
+	}
+	if err := p.redis.HDel(ctx, usageKey(name), usageCooldownUntil).Err(); err != nil {
+		return nil, fmt.Errorf("failed to clear cooldown: %w", err)
+	}
+	set.Usage.CooldownUntil = nil
+	return set, nil
+}
+
+// Get returns a credential set with its usage, or ErrNotFound
+func (p *Pool) Get(ctx context.Context, name string) (*Set, error) {
+	data, err := p.redis.HGet(ctx, setsKey, name).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential set: %w", err)
+	}
+
+	var set Set
+	if err := json.Unmarshal([]byte(data), &set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credential set: %w", err)
+	}
+
+	usage, err := p.redis.HGetAll(ctx, usageKey(name)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential usage: %w", err)
+	}
+	set.Usage = parseUsage(usage)
+	return &set, nil
+}
+
+// List returns every credential set with its usage, by name
+func (p *Pool) List(ctx context.Context) ([]Set, error) {
+	values, err := p.redis.HGetAll(ctx, setsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credential sets: %w", err)
+	}
+
+	sets := make([]Set, 0, len(values))
+	for _, data := range values {
+		var set Set
+		if err := json.Unmarshal([]byte(data), &set); err != nil {
+			continue
+		}
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+
+	pipe := p.redis.Pipeline()
+	usages := make([]*redis.StringStringMapCmd, len(sets))
+	for i := range sets {
+		usages[i] = pipe.HGetAll(ctx, usageKey(sets[i].Name))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get credential usage: %w", err)
+	}
+	for i := range sets {
+		sets[i].Usage = parseUsage(usages[i].Val())
+	}
+	return sets, nil
+}
+
+// Delete removes a credential set and its usage
+func (p *Pool) Delete(ctx context.Context, name string) error {
+	deleted, err := p.redis.HDel(ctx, setsKey, name).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete credential set: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	if err := p.redis.Del(ctx, usageKey(name)).Err(); err != nil {
+		return fmt.Errorf("failed to delete credential usage: %w", err)
+	}
+	return nil
+}
+
+// Acquire returns the usable set, other than those excluded, that has gone
+// unused longest, or ErrNoCredentials
+func (p *Pool) Acquire(ctx context.Context, exclude ...string) (*Set, error) {
+	sets, err := p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set, ok := choose(sets, exclude, time.Now())
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return set, nil
+}
+
+// RecordUse counts a video fetched with a set
+func (p *Pool) RecordUse(ctx context.Context, name string) error {
+	pipe := p.redis.TxPipeline()
+	pipe.HIncrBy(ctx, usageKey(name), usageUses, 1)
+	pipe.HSet(ctx, usageKey(name), usageLastUsed, time.Now().Unix())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record credential use: %w", err)
+	}
+	return nil
+}
+
+// RecordFailure counts a request YouTube refused with a set, and keeps the
+// set out of rotation for the cooldown
+func (p *Pool) RecordFailure(ctx context.Context, name string, cause error, cooldown time.Duration) error {
+	now := time.Now()
+	pipe := p.redis.TxPipeline()
+	pipe.HIncrBy(ctx, usageKey(name), usageFailures, 1)
+	pipe.HSet(ctx, usageKey(name),
+		usageLastFailure, now.Unix(),
+		usageLastError, truncateError(cause),
+		usageCooldownUntil, now.Add(cooldown).Unix(),
+	)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record credential failure: %w", err)
+	}
+	return nil
+}
+
+// Quarantine takes a set out of rotation until it is restored or its cookies are replaced
+func (p *Pool) Quarantine(ctx context.Context, name, reason string) (*Set, error) {
+	set, err := p.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if set.State == StateQuarantined {
+		return set, nil
+	}
+
+	now := time.Now().UTC()
+	set.State = StateQuarantined
+	set.Reason = reason
+	set.QuarantinedAt = &now
+	if err := p.save(ctx, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Restore puts a quarantined or cooling down set back into rotation
+func (p *Pool) Restore(ctx context.Context, name string) (*Set, error) {
+	set, err := p.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	set.State = StateActive
+	set.Reason = ""
+	set.QuarantinedAt = nil
+	if err := p.save(ctx, set); err != nil {
+		return nil, err
+	}
+	if err := p.redis.HDel(ctx, usageKey(name), usageCooldownUntil).Err(); err != nil {
+		return nil, fmt.Errorf("failed to clear cooldown: %w", err)
+	}
+	set.Usage.CooldownUntil = nil
+	return set, nil
+}
+
+// Check asks YouTube whether a set is still signed in, and quarantines it if
+// it is not. A check that could not reach YouTube is recorded with the set
+// but changes nothing else.
+func (p *Pool) Check(ctx context.Context, name string) (*Set, error) {
+	set, err := p.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	signedIn, checkErr := p.checkSignedIn(ctx, set.Cookies)
+	now := time.Now().UTC()
+	set.LastCheckedAt = &now
+	switch {
+	case checkErr != nil:
+		set.LastCheck = "error: " + truncateError(checkErr)
+	case signedIn:
+		set.LastCheck = "signed_in"
+	default:
+		set.LastCheck = "signed_out"
+		if set.State != StateQuarantined {
+			set.State = StateQuarantined
+			set.Reason = "signed out: the cookies expired or the account was logged out"
+			set.QuarantinedAt = &now
+		}
+	}
+	if err := p.save(ctx, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// MigrateLegacy moves the single cookie blob of earlier versions into the
+// set named DefaultSetName, unless that set already exists. It reports
+// whether there was a blob to move.
+func (p *Pool) MigrateLegacy(ctx context.Context) (bool, error) {
+	cookies, err := p.redis.Get(ctx, legacyCookiesKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get legacy cookies: %w", err)
+	}
+
+	now := time.Now().UTC()
+	set := &Set{
+		Name:      DefaultSetName,
+		Cookies:   cookies,
+		State:     StateActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal credential set: %w", err)
+	}
+	if err := p.redis.HSetNX(ctx, setsKey, DefaultSetName, data).Err(); err != nil {
+		return false, fmt.Errorf("failed to save credential set: %w", err)
+	}
+	if err := p.redis.Del(ctx, legacyCookiesKey).Err(); err != nil {
+		return false, fmt.Errorf("failed to delete legacy cookies: %w", err)
+	}
+	return true, nil
+}
+
+// save writes a set, without its usage
+func (p *Pool) save(ctx context.Context, set *Set) error {
+	set.UpdatedAt = time.Now().UTC()
+	stored := *set
+	stored.Usage = Usage{}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential set: %w", err)
+	}
+	if err := p.redis.HSet(ctx, setsKey, set.Name, data).Err(); err != nil {
+		return fmt.Errorf("failed to save credential set: %w", err)
+	}
+	return nil
+}
+
+// checkSignedIn makes a lightweight authenticated call with a set's cookies
+func (p *Pool) checkSignedIn(ctx context.Context, cookies string) (bool, error) {
+	parsed, err := parseCookies(cookies)
+	if err != nil {
+		return false, err
+	}
+	client, err := youtube.NewClient(youtube.WithCookies(parsed), youtube.WithRateLimiter(p.limiter))
+	if err != nil {
+		return false, fmt.Errorf("failed to create YouTube client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	return client.CheckLogin(ctx)
+}
+
+// parseCookies parses Netscape format cookies, which must include YouTube or Google ones
+func parseCookies(cookies string) ([]*http.Cookie, error) {
+	parsed, err := youtube.LoadCookiesFromString(cookies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cookies: %w", err)
+	}
+	parsed = youtube.FilterYouTubeCookies(parsed)
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no YouTube cookies found, paste cookies in Netscape cookies.txt format")
+	}
+	return parsed, nil
+}
+
+// ValidateCookies checks that cookies can be used for a credential set
+func ValidateCookies(cookies string) error {
+	_, err := parseCookies(cookies)
+	return err
+}
+
+// ParsedCookies parses the cookies of a set for use with youtube.WithCookies
+func (s *Set) ParsedCookies() ([]*http.Cookie, error) {
+	return parseCookies(s.Cookies)
+}
+
+// parseUsage reads a set's usage hash
+func parseUsage(fields map[string]string) Usage {
+	usage := Usage{
+		Uses:      parseInt(fields[usageUses]),
+		Failures:  parseInt(fields[usageFailures]),
+		LastError: fields[usageLastError],
+	}
+	usage.LastUsedAt = parseUnix(fields[usageLastUsed])
+	usage.LastFailureAt = parseUnix(fields[usageLastFailure])
+	usage.CooldownUntil = parseUnix(fields[usageCooldownUntil])
+	return usage
+}
+
+// parseInt parses a counter, treating a missing one as 0
+func parseInt(value string) int64 {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// parseUnix parses a Unix timestamp, returning nil for a missing one
+func parseUnix(value string) *time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0).UTC()
+	return &t
+}
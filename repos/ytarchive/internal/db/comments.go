@@ -0,0 +1,203 @@
+// Package db provides SQLite database operations for the YouTube Channel Archiver.
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Comment is an archived comment, reply or live chat message on a video.
+type Comment struct {
+	ID              string
+	VideoID         string
+	Kind            string // CommentKindComment or CommentKindChat
+	ParentID        string // the comment a reply answers
+	AuthorName      string
+	AuthorChannelID string
+	Text            string
+	LikeCount       int64
+	ReplyCount      int
+	PublishedText   string // relative age when archived, e.g. "2 years ago"
+	OffsetMs        int64  // position in the video of a chat message
+	Amount          string // Super Chat amount
+	Position        int    // order in which YouTube listed it
+	ArchivedAt      time.Time
+}
+
+// SaveComments stores the comments of a video of one kind in the order they
+// were listed. Comments archived before are updated, and ones no longer
+// listed upstream are kept. Returns the number of comments stored.
+func SaveComments(db *sql.DB, videoID, kind string, comments []Comment) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO comments (id, video_id, kind, parent_id, author_name, author_channel_id, text,
+		                      like_count, reply_count, published_text, offset_ms, amount, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			author_name = excluded.author_name,
+			text = excluded.text,
+			like_count = excluded.like_count,
+			reply_count = excluded.reply_count,
+			published_text = excluded.published_text,
+			amount = excluded.amount,
+			position = excluded.position,
+			archived_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare comment insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, comment := range comments {
+		if _, err := stmt.Exec(
+			comment.ID, videoID, kind, comment.ParentID, comment.AuthorName, comment.AuthorChannelID,
+			comment.Text, comment.LikeCount, comment.ReplyCount, comment.PublishedText,
+			comment.OffsetMs, comment.Amount, i,
+		); err != nil {
+			return 0, fmt.Errorf("failed to save comment %s: %w", comment.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit comments: %w", err)
+	}
+	return len(comments), nil
+}
+
+// GetComments returns a page of the archived comments of a video of one kind.
+// Comments come in the order YouTube listed them, with replies after the
+// comment they answer; chat messages come in the order they were shown.
+func GetComments(db *sql.DB, videoID, kind string, limit, offset int) ([]Comment, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, video_id, kind, parent_id, author_name, author_channel_id, text, like_count,
+		       reply_count, published_text, offset_ms, amount, position, archived_at
+		FROM comments
+		WHERE video_id = ? AND kind = ?
+		ORDER BY offset_ms ASC, position ASC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.Query(query, videoID, kind, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanComments(rows)
+}
+
+// CountComments returns the number of archived comments of a video of one kind.
+func CountComments(db *sql.DB, videoID, kind string) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM comments WHERE video_id = ? AND kind = ?`, videoID, kind).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+	return count, nil
+}
+
+// GetAllComments returns every archived comment and chat message in the
+// database, by video and kind, each in the order GetComments returns them.
+func GetAllComments(db *sql.DB) ([]Comment, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := db.Query(`
+		SELECT id, video_id, kind, parent_id, author_name, author_channel_id, text, like_count,
+		       reply_count, published_text, offset_ms, amount, position, archived_at
+		FROM comments
+		ORDER BY video_id, kind, offset_ms ASC, position ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanComments(rows)
+}
+
+// SearchComments performs a full-text search on the text and authors of the
+// archived comments of a video of one kind, best matches first.
+func SearchComments(db *sql.DB, videoID, kind, query string, limit int) ([]Comment, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	sqlQuery := `
+		SELECT c.id, c.video_id, c.kind, c.parent_id, c.author_name, c.author_channel_id, c.text,
+		       c.like_count, c.reply_count, c.published_text, c.offset_ms, c.amount, c.position,
+		       c.archived_at
+		FROM comments_fts
+		JOIN comments c ON comments_fts.rowid = c.rowid
+		WHERE comments_fts MATCH ? AND c.video_id = ? AND c.kind = ?
+		ORDER BY bm25(comments_fts)
+		LIMIT ?
+	`
+
+	rows, err := db.Query(sqlQuery, prepareFTSQuery(query), videoID, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search comments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanComments(rows)
+}
+
+// scanComments reads comment rows in the column order used by the queries above.
+func scanComments(rows *sql.Rows) ([]Comment, error) {
+	var comments []Comment
+	for rows.Next() {
+		var comment Comment
+		var parentID, authorName, authorChannelID, publishedText, amount sql.NullString
+		var offsetMs, position sql.NullInt64
+		if err := rows.Scan(
+			&comment.ID, &comment.VideoID, &comment.Kind, &parentID, &authorName, &authorChannelID,
+			&comment.Text, &comment.LikeCount, &comment.ReplyCount, &publishedText, &offsetMs,
+			&amount, &position, &comment.ArchivedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comment.ParentID = parentID.String
+		comment.AuthorName = authorName.String
+		comment.AuthorChannelID = authorChannelID.String
+		comment.PublishedText = publishedText.String
+		comment.OffsetMs = offsetMs.Int64
+		comment.Amount = amount.String
+		comment.Position = int(position.Int64)
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+
+	return comments, nil
+}
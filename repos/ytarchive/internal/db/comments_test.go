@@ -0,0 +1,91 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSaveComments(t *testing.T) {
+	db := setupTestDB(t)
+
+	comments := []Comment{
+		{ID: "c1", AuthorName: "@alice", Text: "Great video about bridges", LikeCount: 1200, ReplyCount: 1},
+		{ID: "c1.r1", ParentID: "c1", AuthorName: "@bob", Text: "Agreed"},
+		{ID: "c2", AuthorName: "@carol", Text: "Where was this filmed?"},
+	}
+	if n, err := SaveComments(db, "vid", CommentKindComment, comments); err != nil || n != 3 {
+		t.Fatalf("SaveComments() = %d, %v, want 3, nil", n, err)
+	}
+
+	chat := []Comment{
+		{ID: "m2", AuthorName: "viewer2", Text: "bridges!", OffsetMs: 9000, Amount: "$5.00"},
+		{ID: "m1", AuthorName: "viewer1", Text: "hello", OffsetMs: 1500},
+	}
+	if _, err := SaveComments(db, "vid", CommentKindChat, chat); err != nil {
+		t.Fatalf("SaveComments() chat error = %v", err)
+	}
+
+	got, err := GetComments(db, "vid", CommentKindComment, 0, 0)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	if len(got) != 3 || got[0].ID != "c1" || got[1].ParentID != "c1" || got[2].ID != "c2" {
+		t.Errorf("GetComments() = %+v, want comments in listed order", got)
+	}
+	if got[0].LikeCount != 1200 || got[0].ReplyCount != 1 || got[0].Kind != CommentKindComment {
+		t.Errorf("GetComments()[0] = %+v", got[0])
+	}
+
+	got, _ = GetComments(db, "vid", CommentKindChat, 0, 0)
+	if len(got) != 2 || got[0].ID != "m1" || got[1].Amount != "$5.00" {
+		t.Errorf("GetComments() chat = %+v, want messages by offset", got)
+	}
+
+	page, _ := GetComments(db, "vid", CommentKindComment, 1, 1)
+	if len(page) != 1 || page[0].ID != "c1.r1" {
+		t.Errorf("GetComments() page = %+v, want c1.r1", page)
+	}
+
+	// Archiving again updates edited comments and keeps deleted ones
+	if _, err := SaveComments(db, "vid", CommentKindComment, []Comment{
+		{ID: "c2", AuthorName: "@carol", Text: "Where was this filmed? Looks like Oregon"},
+	}); err != nil {
+		t.Fatalf("SaveComments() again error = %v", err)
+	}
+	if count, _ := CountComments(db, "vid", CommentKindComment); count != 3 {
+		t.Errorf("CountComments() = %d, want 3", count)
+	}
+
+	results, err := SearchComments(db, "vid", CommentKindComment, "oregon", 10)
+	if err != nil {
+		t.Fatalf("SearchComments() error = %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "c2" {
+		t.Errorf("SearchComments(oregon) = %+v, want the edited comment", results)
+	}
+
+	results, _ = SearchComments(db, "vid", CommentKindComment, "filmed", 10)
+	if len(results) != 1 {
+		t.Errorf("SearchComments(filmed) returned %d results, want the old text removed from the index", len(results))
+	}
+
+	results, _ = SearchComments(db, "vid", CommentKindChat, "bridges", 10)
+	if len(results) != 1 || results[0].ID != "m2" {
+		t.Errorf("SearchComments() chat = %+v, want only chat messages", results)
+	}
+
+	if results, _ := SearchComments(db, "other", CommentKindComment, "bridges", 10); len(results) != 0 {
+		t.Errorf("SearchComments() on another video = %+v, want none", results)
+	}
+
+	if _, err := SearchComments(db, "vid", CommentKindComment, "", 10); err == nil {
+		t.Error("SearchComments() expected error for empty query")
+	}
+
+	all, err := GetAllComments(db)
+	if err != nil {
+		t.Fatalf("GetAllComments() error = %v", err)
+	}
+	if len(all) != 5 || all[0].Kind != CommentKindChat || all[0].ID != "m1" || all[2].Kind != CommentKindComment {
+		t.Errorf("GetAllComments() = %+v, want chat messages by offset, then comments", all)
+	}
+}
@@ -0,0 +1,441 @@
+// Package db provides SQLite database operations for the YouTube Channel Archiver.
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// IndexedVideo is a video in the global search index.
+type IndexedVideo struct {
+	ChannelID   string
+	VideoID     string
+	Title       string
+	Description string
+	Chapters    string // chapter titles, one per line
+	Transcript  string // subtitle text, one caption per line
+	UploadDate  string // YYYYMMDD
+	Duration    int64
+	Kind        string
+	Status      string
+	RemovedAt   *time.Time
+	Fingerprint string // identifies the version of the video the entry was built from
+	IndexedAt   time.Time
+}
+
+// IndexSearch describes a search of the global index. Empty fields do not filter.
+type IndexSearch struct {
+	Query        string
+	ChannelIDs   []string
+	Status       string
+	Kind         string
+	Removed      *bool  // only videos removed upstream, or only ones still listed
+	UploadedFrom string // YYYYMMDD, inclusive
+	UploadedTo   string // YYYYMMDD, inclusive
+	MinDuration  int64  // seconds
+	MaxDuration  int64  // seconds
+	Limit        int
+	Offset       int
+}
+
+// IndexHit is a video matching a search of the global index.
+type IndexHit struct {
+	IndexedVideo
+	Rank           float64 // BM25 relevance score (lower is more relevant)
+	TitleHighlight string  // HTML escaped title with the matches in <mark>
+	Snippet        string  // HTML escaped best matching passage with the matches in <mark>
+}
+
+// FacetCount is the number of matching videos with one value of a facet.
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// IndexSearchResult is a page of hits with the total number of matches and
+// the facet counts across all of them.
+type IndexSearchResult struct {
+	Hits   []IndexHit
+	Total  int
+	Facets map[string][]FacetCount
+}
+
+// Facets counted by SearchIndex. Each facet is counted with every filter but
+// its own applied, so the counts show what changing that filter would find.
+const (
+	FacetChannel  = "channel"
+	FacetStatus   = "status"
+	FacetKind     = "kind"
+	FacetYear     = "year"     // from the upload date
+	FacetDuration = "duration" // DurationShort, DurationMedium or DurationLong
+)
+
+// Duration ranges counted by the duration facet, as YouTube's search filters them.
+const (
+	DurationShort  = "short"  // under 4 minutes
+	DurationMedium = "medium" // 4 to 20 minutes
+	DurationLong   = "long"   // over 20 minutes
+)
+
+// DurationRange returns the MinDuration and MaxDuration that select one of
+// the duration facet's ranges.
+func DurationRange(name string) (int64, int64, error) {
+	switch name {
+	case DurationShort:
+		return 0, 4*60 - 1, nil
+	case DurationMedium:
+		return 4 * 60, 20 * 60, nil
+	case DurationLong:
+		return 20*60 + 1, 0, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown duration range %q", name)
+	}
+}
+
+// facetColumns holds the expression each facet groups by.
+var facetColumns = map[string]string{
+	FacetChannel: "v.channel_id",
+	FacetStatus:  "v.status",
+	FacetKind:    "v.kind",
+	FacetYear:    "substr(v.upload_date, 1, 4)",
+	FacetDuration: `CASE WHEN v.duration < 240 THEN 'short'
+	                     WHEN v.duration <= 1200 THEN 'medium'
+	                     ELSE 'long' END`,
+}
+
+// facetLimit is the most values counted for a facet
+const facetLimit = 50
+
+// Markers snippet() and highlight() put around matches; they cannot occur in
+// text, so the text can be escaped before they are turned into <mark> tags.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// IndexVideo adds a video to the search index, replacing the entry it had.
+func IndexVideo(db *sql.DB, video *IndexedVideo) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if video == nil {
+		return fmt.Errorf("video is nil")
+	}
+
+	query := `
+		INSERT INTO indexed_videos (channel_id, video_id, title, description, chapters, transcript,
+		                            upload_date, duration, kind, status, removed_at, fingerprint)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(channel_id, video_id) DO UPDATE SET
+			title = excluded.title,
+			description = excluded.description,
+			chapters = excluded.chapters,
+			transcript = excluded.transcript,
+			upload_date = excluded.upload_date,
+			duration = excluded.duration,
+			kind = excluded.kind,
+			status = excluded.status,
+			removed_at = excluded.removed_at,
+			fingerprint = excluded.fingerprint,
+			indexed_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := db.Exec(query,
+		video.ChannelID, video.VideoID, video.Title, video.Description, video.Chapters, video.Transcript,
+		video.UploadDate, video.Duration, video.Kind, video.Status, video.RemovedAt, video.Fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to index video %s: %w", video.VideoID, err)
+	}
+	return nil
+}
+
+// GetIndexFingerprints returns the fingerprint of each indexed video of a channel.
+func GetIndexFingerprints(db *sql.DB, channelID string) (map[string]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := db.Query(`SELECT video_id, fingerprint FROM indexed_videos WHERE channel_id = ?`, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	fingerprints := make(map[string]string)
+	for rows.Next() {
+		var videoID string
+		var fingerprint sql.NullString
+		if err := rows.Scan(&videoID, &fingerprint); err != nil {
+			return nil, fmt.Errorf("failed to scan index fingerprint: %w", err)
+		}
+		fingerprints[videoID] = fingerprint.String
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating index fingerprints: %w", err)
+	}
+
+	return fingerprints, nil
+}
+
+// RemoveIndexedVideo removes a video and its transcript from the search index.
+func RemoveIndexedVideo(db *sql.DB, channelID, videoID string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	for _, table := range []string{"indexed_videos", "transcript_cues"} {
+		query := "DELETE FROM " + table + " WHERE channel_id = ? AND video_id = ?"
+		if _, err := db.Exec(query, channelID, videoID); err != nil {
+			return fmt.Errorf("failed to remove video %s from index: %w", videoID, err)
+		}
+	}
+	return nil
+}
+
+// RemoveIndexedChannel removes every video of a channel and their transcripts
+// from the search index.
+func RemoveIndexedChannel(db *sql.DB, channelID string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	for _, table := range []string{"indexed_videos", "transcript_cues"} {
+		if _, err := db.Exec("DELETE FROM "+table+" WHERE channel_id = ?", channelID); err != nil {
+			return fmt.Errorf("failed to remove channel %s from index: %w", channelID, err)
+		}
+	}
+	return nil
+}
+
+// GetIndexedChannels returns the IDs of the channels with videos in the search index.
+func GetIndexedChannels(db *sql.DB) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT channel_id FROM indexed_videos`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexed channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channelIDs []string
+	for rows.Next() {
+		var channelID string
+		if err := rows.Scan(&channelID); err != nil {
+			return nil, fmt.Errorf("failed to scan indexed channel: %w", err)
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating indexed channels: %w", err)
+	}
+
+	return channelIDs, nil
+}
+
+// CountIndexedVideos returns the number of videos in the search index.
+func CountIndexedVideos(db *sql.DB) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM indexed_videos`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count indexed videos: %w", err)
+	}
+	return count, nil
+}
+
+// SearchIndex performs a full-text search of the titles, descriptions,
+// chapters and transcripts of all indexed videos. Titles weigh the most,
+// then chapters, then descriptions and transcripts. Returns the requested
+// page of hits, best first, along with the facet counts of all matches.
+func SearchIndex(db *sql.DB, search IndexSearch) (*IndexSearchResult, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if strings.TrimSpace(search.Query) == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if search.Limit <= 0 {
+		search.Limit = 50
+	}
+
+	match := prepareFTSQuery(search.Query)
+	where, args := search.filters("")
+	args = append([]interface{}{matchStart, matchEnd, matchStart, matchEnd, match}, args...)
+
+	sqlQuery := `
+		SELECT v.channel_id, v.video_id, v.title, v.description, v.upload_date, v.duration,
+		       v.kind, v.status, v.removed_at, v.fingerprint, v.indexed_at,
+		       bm25(indexed_videos_fts, 10.0, 2.0, 5.0, 1.0) AS rank,
+		       highlight(indexed_videos_fts, 0, ?, ?),
+		       snippet(indexed_videos_fts, -1, ?, ?, '…', 24)
+		FROM indexed_videos_fts
+		JOIN indexed_videos v ON indexed_videos_fts.rowid = v.rowid
+		WHERE indexed_videos_fts MATCH ?` + where + `
+		ORDER BY rank
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.Query(sqlQuery, append(args, search.Limit, search.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search index: %w", err)
+	}
+	defer rows.Close()
+
+	result := &IndexSearchResult{Hits: []IndexHit{}, Facets: make(map[string][]FacetCount)}
+	for rows.Next() {
+		var hit IndexHit
+		var description, uploadDate, kind, status, fingerprint sql.NullString
+		var duration sql.NullInt64
+		var removedAt sql.NullTime
+		if err := rows.Scan(
+			&hit.ChannelID, &hit.VideoID, &hit.Title, &description, &uploadDate, &duration,
+			&kind, &status, &removedAt, &fingerprint, &hit.IndexedAt,
+			&hit.Rank, &hit.TitleHighlight, &hit.Snippet,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		hit.Description = description.String
+		hit.UploadDate = uploadDate.String
+		hit.Duration = duration.Int64
+		hit.Kind = kind.String
+		hit.Status = status.String
+		hit.Fingerprint = fingerprint.String
+		if removedAt.Valid {
+			hit.RemovedAt = &removedAt.Time
+		}
+		hit.TitleHighlight = markMatches(hit.TitleHighlight)
+		hit.Snippet = markMatches(hit.Snippet)
+		result.Hits = append(result.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search hits: %w", err)
+	}
+
+	where, args = search.filters("")
+	countQuery := `
+		SELECT COUNT(*)
+		FROM indexed_videos_fts
+		JOIN indexed_videos v ON indexed_videos_fts.rowid = v.rowid
+		WHERE indexed_videos_fts MATCH ?` + where
+	if err := db.QueryRow(countQuery, append([]interface{}{match}, args...)...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count search hits: %w", err)
+	}
+
+	for facet := range facetColumns {
+		counts, err := search.countFacet(db, match, facet)
+		if err != nil {
+			return nil, err
+		}
+		result.Facets[facet] = counts
+	}
+
+	return result, nil
+}
+
+// countFacet counts the matching videos for each value of a facet, most first.
+func (s IndexSearch) countFacet(db *sql.DB, match, facet string) ([]FacetCount, error) {
+	where, args := s.filters(facet)
+	query := `
+		SELECT ` + facetColumns[facet] + ` AS value, COUNT(*) AS count
+		FROM indexed_videos_fts
+		JOIN indexed_videos v ON indexed_videos_fts.rowid = v.rowid
+		WHERE indexed_videos_fts MATCH ?` + where + `
+		GROUP BY value
+		ORDER BY count DESC, value ASC
+		LIMIT ?
+	`
+
+	rows, err := db.Query(query, append(append([]interface{}{match}, args...), facetLimit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count %s facet: %w", facet, err)
+	}
+	defer rows.Close()
+
+	counts := []FacetCount{}
+	for rows.Next() {
+		var value sql.NullString
+		var count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan %s facet: %w", facet, err)
+		}
+		if value.String == "" {
+			continue
+		}
+		counts = append(counts, FacetCount{Value: value.String, Count: count})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s facet: %w", facet, err)
+	}
+
+	return counts, nil
+}
+
+// filters returns the WHERE conditions for the search filters, leaving out
+// the one a facet counts by when skip names it.
+func (s IndexSearch) filters(skip string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(s.ChannelIDs) > 0 && skip != FacetChannel {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(s.ChannelIDs)), ", ")
+		conditions = append(conditions, "v.channel_id IN ("+placeholders+")")
+		for _, channelID := range s.ChannelIDs {
+			args = append(args, channelID)
+		}
+	}
+	if s.Status != "" && skip != FacetStatus {
+		conditions = append(conditions, "v.status = ?")
+		args = append(args, s.Status)
+	}
+	if s.Kind != "" && skip != FacetKind {
+		conditions = append(conditions, "v.kind = ?")
+		args = append(args, s.Kind)
+	}
+	if s.Removed != nil {
+		if *s.Removed {
+			conditions = append(conditions, "v.removed_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "v.removed_at IS NULL")
+		}
+	}
+	if s.UploadedFrom != "" && skip != FacetYear {
+		conditions = append(conditions, "v.upload_date >= ?")
+		args = append(args, s.UploadedFrom)
+	}
+	if s.UploadedTo != "" && skip != FacetYear {
+		conditions = append(conditions, "v.upload_date <= ?")
+		args = append(args, s.UploadedTo)
+	}
+	if s.MinDuration > 0 && skip != FacetDuration {
+		conditions = append(conditions, "v.duration >= ?")
+		args = append(args, s.MinDuration)
+	}
+	if s.MaxDuration > 0 && skip != FacetDuration {
+		conditions = append(conditions, "v.duration <= ?")
+		args = append(args, s.MaxDuration)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// markMatches escapes text marked by snippet() or highlight() for HTML and
+// wraps the matches in <mark> tags.
+func markMatches(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, matchStart, "<mark>")
+	return strings.ReplaceAll(text, matchEnd, "</mark>")
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupTestIndex opens a search index in a temporary directory
+func setupTestIndex(t *testing.T) *sql.DB {
+	t.Helper()
+
+	index, err := openSearchIndex(filepath.Join(t.TempDir(), "search", DBFileName))
+	if err != nil {
+		t.Fatalf("Failed to open search index: %v", err)
+	}
+	t.Cleanup(func() {
+		index.Close()
+	})
+	return index
+}
+
+func TestSearchIndex(t *testing.T) {
+	index := setupTestIndex(t)
+
+	removedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	videos := []*IndexedVideo{
+		{ChannelID: "ch1", VideoID: "v1", Title: "Building a bridge", Description: "Timber framing", UploadDate: "20230105", Duration: 1500, Kind: KindVideo, Status: "downloaded"},
+		{ChannelID: "ch1", VideoID: "v2", Title: "Workshop tour", Chapters: "Intro\nThe bridge clamp", UploadDate: "20240210", Duration: 180, Kind: KindShort, Status: "pending"},
+		{ChannelID: "ch2", VideoID: "v3", Title: "Live Q&A", Transcript: "someone asked about the <bridge> we built", UploadDate: "20240620", Duration: 600, Kind: KindLive, Status: "downloaded", RemovedAt: &removedAt},
+		{ChannelID: "ch2", VideoID: "v4", Title: "Unrelated", Description: "Nothing to see", UploadDate: "20240701", Duration: 300, Kind: KindVideo, Status: "downloaded"},
+	}
+	for _, video := range videos {
+		if err := IndexVideo(index, video); err != nil {
+			t.Fatalf("IndexVideo() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		search IndexSearch
+		want   []string
+	}{
+		{"title ranks first", IndexSearch{Query: "bridge"}, []string{"v1", "v2", "v3"}},
+		{"transcript", IndexSearch{Query: "asked"}, []string{"v3"}},
+		{"chapters", IndexSearch{Query: "clamp"}, []string{"v2"}},
+		{"channel", IndexSearch{Query: "bridge", ChannelIDs: []string{"ch2"}}, []string{"v3"}},
+		{"status", IndexSearch{Query: "bridge", Status: "downloaded"}, []string{"v1", "v3"}},
+		{"kind", IndexSearch{Query: "bridge", Kind: KindShort}, []string{"v2"}},
+		{"removed", IndexSearch{Query: "bridge", Removed: boolPtr(true)}, []string{"v3"}},
+		{"date range", IndexSearch{Query: "bridge", UploadedFrom: "20240101", UploadedTo: "20240331"}, []string{"v2"}},
+		{"duration", IndexSearch{Query: "bridge", MinDuration: 240, MaxDuration: 1200}, []string{"v3"}},
+		{"page", IndexSearch{Query: "bridge", Limit: 1, Offset: 1}, []string{"v2"}},
+		{"no match", IndexSearch{Query: "nonexistent"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SearchIndex(index, tt.search)
+			if err != nil {
+				t.Fatalf("SearchIndex() error = %v", err)
+			}
+			got := make([]string, 0, len(result.Hits))
+			for _, hit := range result.Hits {
+				got = append(got, hit.VideoID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SearchIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndex_HighlightsAndFacets(t *testing.T) {
+	index := setupTestIndex(t)
+
+	videos := []*IndexedVideo{
+		{ChannelID: "ch1", VideoID: "v1", Title: "Bridge <basics>", UploadDate: "20230105", Duration: 1500, Status: "downloaded"},
+		{ChannelID: "ch1", VideoID: "v2", Title: "Another bridge", UploadDate: "20240210", Duration: 180, Status: "pending"},
+		{ChannelID: "ch2", VideoID: "v3", Title: "Q&A", Transcript: "the bridge & the river", UploadDate: "20240620", Duration: 600, Status: "downloaded"},
+	}
+	for _, video := range videos {
+		if err := IndexVideo(index, video); err != nil {
+			t.Fatalf("IndexVideo() error = %v", err)
+		}
+	}
+
+	result, err := SearchIndex(index, IndexSearch{Query: "bridge", ChannelIDs: []string{"ch1"}})
+	if err != nil {
+		t.Fatalf("SearchIndex() error = %v", err)
+	}
+	if result.Total != 2 {
+		t.Errorf("Total = %d, want 2", result.Total)
+	}
+	if result.Hits[0].TitleHighlight != "<mark>Bridge</mark> &lt;basics&gt;" {
+		t.Errorf("TitleHighlight = %q, want escaped title with the match marked", result.Hits[0].TitleHighlight)
+	}
+
+	// The channel facet ignores the channel filter, the others apply it
+	wantFacets := map[string][]FacetCount{
+		FacetChannel:  {{"ch1", 2}, {"ch2", 1}},
+		FacetStatus:   {{"downloaded", 1}, {"pending", 1}},
+		FacetYear:     {{"2023", 1}, {"2024", 1}},
+		FacetDuration: {{DurationLong, 1}, {DurationShort, 1}},
+	}
+	for facet, want := range wantFacets {
+		got := result.Facets[facet]
+		if len(got) != len(want) {
+			t.Errorf("Facets[%s] = %v, want %v", facet, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Facets[%s] = %v, want %v", facet, got, want)
+				break
+			}
+		}
+	}
+
+	result, err = SearchIndex(index, IndexSearch{Query: "river"})
+	if err != nil {
+		t.Fatalf("SearchIndex() error = %v", err)
+	}
+	if len(result.Hits) != 1 || !strings.Contains(result.Hits[0].Snippet, "<mark>river</mark>") || !strings.Contains(result.Hits[0].Snippet, "&amp;") {
+		t.Errorf("Snippet = %+v, want the escaped transcript with the match marked", result.Hits)
+	}
+}
+
+func TestIndexVideo_Replaces(t *testing.T) {
+	index := setupTestIndex(t)
+
+	video := &IndexedVideo{ChannelID: "ch1", VideoID: "v1", Title: "Old title", Fingerprint: "a"}
+	if err := IndexVideo(index, video); err != nil {
+		t.Fatalf("IndexVideo() error = %v", err)
+	}
+	video.Title = "New title"
+	video.Fingerprint = "b"
+	if err := IndexVideo(index, video); err != nil {
+		t.Fatalf("IndexVideo() error = %v", err)
+	}
+
+	if result, _ := SearchIndex(index, IndexSearch{Query: "old"}); len(result.Hits) != 0 {
+		t.Errorf("SearchIndex(old) = %v, want the old title gone", result.Hits)
+	}
+	if result, _ := SearchIndex(index, IndexSearch{Query: "new"}); len(result.Hits) != 1 {
+		t.Errorf("SearchIndex(new) = %v, want the new title", result.Hits)
+	}
+
+	fingerprints, err := GetIndexFingerprints(index, "ch1")
+	if err != nil || fingerprints["v1"] != "b" {
+		t.Errorf("GetIndexFingerprints() = %v, %v, want v1: b", fingerprints, err)
+	}
+
+	if err := RemoveIndexedChannel(index, "ch1"); err != nil {
+		t.Fatalf("RemoveIndexedChannel() error = %v", err)
+	}
+	if count, _ := CountIndexedVideos(index); count != 0 {
+		t.Errorf("CountIndexedVideos() = %d, want 0", count)
+	}
+	if result, _ := SearchIndex(index, IndexSearch{Query: "new"}); len(result.Hits) != 0 {
+		t.Errorf("SearchIndex(new) = %v, want no hits after removal", result.Hits)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
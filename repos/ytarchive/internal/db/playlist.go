@@ -0,0 +1,160 @@
+// Package db provides SQLite database operations for the YouTube Channel Archiver.
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PlaylistItem represents a playlist entry in the playlist database.
+type PlaylistItem struct {
+	VideoID          string
+	Position         int
+	ChannelID        string
+	YouTubeChannelID string
+	ChannelName      string
+	Title            string
+	Duration         int64
+	ThumbnailURL     string
+	AddedAt          time.Time
+	RemovedAt        *time.Time
+}
+
+// SyncPlaylistItems replaces the playlist order with the given items.
+// Items that are no longer in the playlist are kept with removed_at set so
+// the archive still knows they were once part of it.
+// Returns the number of newly added and newly removed items.
+func SyncPlaylistItems(db *sql.DB, items []PlaylistItem) (added, removed int, err error) {
+	if db == nil {
+		return 0, 0, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Mark every current item as unseen; upserts below restore the position
+	if _, err := tx.Exec(`UPDATE playlist_items SET position = -1 WHERE removed_at IS NULL`); err != nil {
+		return 0, 0, fmt.Errorf("failed to reset playlist positions: %w", err)
+	}
+
+	query := `
+		INSERT INTO playlist_items (video_id, position, channel_id, youtube_channel_id, channel_name, title, duration, thumbnail_url)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(video_id) DO UPDATE SET
+			position = excluded.position,
+			channel_id = excluded.channel_id,
+			youtube_channel_id = excluded.youtube_channel_id,
+			channel_name = excluded.channel_name,
+			title = excluded.title,
+			duration = excluded.duration,
+			thumbnail_url = excluded.thumbnail_url,
+			removed_at = NULL
+	`
+
+	for _, item := range items {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM playlist_items WHERE video_id = ? AND removed_at IS NULL`, item.VideoID).Scan(&exists); err != nil {
+			return 0, 0, fmt.Errorf("failed to check playlist item: %w", err)
+		}
+		if exists == 0 {
+			added++
+		}
+
+		if _, err := tx.Exec(query,
+			item.VideoID,
+			item.Position,
+			item.ChannelID,
+			item.YouTubeChannelID,
+			item.ChannelName,
+			item.Title,
+			item.Duration,
+			item.ThumbnailURL,
+		); err != nil {
+			return 0, 0, fmt.Errorf("failed to upsert playlist item: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE playlist_items SET removed_at = CURRENT_TIMESTAMP WHERE position = -1 AND removed_at IS NULL`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to mark removed playlist items: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	removed = int(rowsAffected)
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit playlist items: %w", err)
+	}
+
+	return added, removed, nil
+}
+
+// GetPlaylistItems returns the playlist entries in playlist order.
+// Removed entries are only included when includeRemoved is true and are listed last.
+func GetPlaylistItems(db *sql.DB, includeRemoved bool) ([]PlaylistItem, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	query := `
+		SELECT video_id, position, channel_id, youtube_channel_id, channel_name, title, duration, thumbnail_url, added_at, removed_at
+		FROM playlist_items
+	`
+	if !includeRemoved {
+		query += ` WHERE removed_at IS NULL`
+	}
+	query += ` ORDER BY removed_at IS NOT NULL, position ASC`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query playlist items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []PlaylistItem
+	for rows.Next() {
+		var item PlaylistItem
+		var channelID, youtubeChannelID, channelName, title, thumbnailURL sql.NullString
+		var duration sql.NullInt64
+		var removedAt sql.NullTime
+
+		if err := rows.Scan(
+			&item.VideoID,
+			&item.Position,
+			&channelID,
+			&youtubeChannelID,
+			&channelName,
+			&title,
+			&duration,
+			&thumbnailURL,
+			&item.AddedAt,
+			&removedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan playlist item: %w", err)
+		}
+
+		item.ChannelID = channelID.String
+		item.YouTubeChannelID = youtubeChannelID.String
+		item.ChannelName = channelName.String
+		item.Title = title.String
+		item.Duration = duration.Int64
+		item.ThumbnailURL = thumbnailURL.String
+		if removedAt.Valid {
+			item.RemovedAt = &removedAt.Time
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating playlist items: %w", err)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// setupTestPlaylistDB creates an in-memory SQLite database with the playlist schema applied.
+func setupTestPlaylistDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	if _, err := db.Exec(PlaylistSchema); err != nil {
+		db.Close()
+		t.Fatalf("Failed to apply schema: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
+
+func TestSyncPlaylistItems(t *testing.T) {
+	db := setupTestPlaylistDB(t)
+
+	first := []PlaylistItem{
+		{VideoID: "video1", Position: 1, ChannelID: "ch-a", Title: "One"},
+		{VideoID: "video2", Position: 2, ChannelID: "ch-b", Title: "Two"},
+		{VideoID: "video3", Position: 3, ChannelID: "ch-a", Title: "Three"},
+	}
+
+	added, removed, err := SyncPlaylistItems(db, first)
+	if err != nil {
+		t.Fatalf("SyncPlaylistItems() error = %v", err)
+	}
+	if added != 3 || removed != 0 {
+		t.Errorf("SyncPlaylistItems() = (%d, %d), want (3, 0)", added, removed)
+	}
+
+	// Reorder, drop video2 and add video4
+	second := []PlaylistItem{
+		{VideoID: "video3", Position: 1, ChannelID: "ch-a", Title: "Three"},
+		{VideoID: "video4", Position: 2, ChannelID: "ch-c", Title: "Four"},
+		{VideoID: "video1", Position: 3, ChannelID: "ch-a", Title: "One"},
+	}
+
+	added, removed, err = SyncPlaylistItems(db, second)
+	if err != nil {
+		t.Fatalf("SyncPlaylistItems() error = %v", err)
+	}
+	if added != 1 || removed != 1 {
+		t.Errorf("SyncPlaylistItems() = (%d, %d), want (1, 1)", added, removed)
+	}
+
+	items, err := GetPlaylistItems(db, false)
+	if err != nil {
+		t.Fatalf("GetPlaylistItems() error = %v", err)
+	}
+
+	wantOrder := []string{"video3", "video4", "video1"}
+	if len(items) != len(wantOrder) {
+		t.Fatalf("GetPlaylistItems() returned %d items, want %d", len(items), len(wantOrder))
+	}
+	for i, id := range wantOrder {
+		if items[i].VideoID != id {
+			t.Errorf("items[%d].VideoID = %q, want %q", i, items[i].VideoID, id)
+		}
+	}
+
+	all, err := GetPlaylistItems(db, true)
+	if err != nil {
+		t.Fatalf("GetPlaylistItems(includeRemoved) error = %v", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("GetPlaylistItems(includeRemoved) returned %d items, want 4", len(all))
+	}
+	last := all[len(all)-1]
+	if last.VideoID != "video2" || last.RemovedAt == nil {
+		t.Errorf("removed item = %q (removed_at %v), want video2 with removed_at set", last.VideoID, last.RemovedAt)
+	}
+
+	// Re-adding a removed video clears removed_at
+	added, _, err = SyncPlaylistItems(db, append(second, PlaylistItem{VideoID: "video2", Position: 4}))
+	if err != nil {
+		t.Fatalf("SyncPlaylistItems() error = %v", err)
+	}
+	if added != 1 {
+		t.Errorf("re-adding removed item: added = %d, want 1", added)
+	}
+}
+
+func TestSyncPlaylistItems_NilDB(t *testing.T) {
+	if _, _, err := SyncPlaylistItems(nil, nil); err == nil {
+		t.Error("SyncPlaylistItems(nil) expected error")
+	}
+	if _, err := GetPlaylistItems(nil, false); err == nil {
+		t.Error("GetPlaylistItems(nil) expected error")
+	}
+}
@@ -12,6 +12,7 @@ CREATE TABLE IF NOT EXISTS videos (
     upload_date TEXT,
     thumbnail_url TEXT,
     view_count INTEGER,
+    kind TEXT DEFAULT 'video',
     status TEXT DEFAULT 'pending',
     file_path TEXT,
     file_size INTEGER,
@@ -20,6 +21,8 @@ CREATE TABLE IF NOT EXISTS videos (
     download_completed_at DATETIME,
     retry_count INTEGER DEFAULT 0,
     last_error TEXT,
+    removed_at DATETIME,
+    removed_reason TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -41,6 +44,29 @@ CREATE TABLE IF NOT EXISTS sync_history (
     status TEXT
 );
 
+-- Comments table archives the comments and live chat replay of each video.
+-- Replies reference their comment in parent_id; chat messages have the
+-- position in the video where they were shown in offset_ms.
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    video_id TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'comment',
+    parent_id TEXT,
+    author_name TEXT,
+    author_channel_id TEXT,
+    text TEXT NOT NULL,
+    like_count INTEGER DEFAULT 0,
+    reply_count INTEGER DEFAULT 0,
+    published_text TEXT,
+    offset_ms INTEGER,
+    amount TEXT,
+    position INTEGER,
+    archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for reading the comments of a video in order
+CREATE INDEX IF NOT EXISTS idx_comments_video ON comments(video_id, kind, position);
+
 -- Trigger to update the updated_at timestamp on video updates
 CREATE TRIGGER IF NOT EXISTS update_videos_timestamp
 AFTER UPDATE ON videos
@@ -79,6 +105,189 @@ BEGIN
     INSERT INTO videos_fts(videos_fts, rowid, title, description)
     VALUES ('delete', old.rowid, old.title, old.description);
 END;
+
+-- FTS5 virtual table for full-text search on comments and chat messages
+CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+    author_name,
+    text,
+    content='comments',
+    content_rowid='rowid'
+);
+
+-- Trigger to keep comments FTS index in sync on INSERT
+CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments
+BEGIN
+    INSERT INTO comments_fts(rowid, author_name, text)
+    VALUES (new.rowid, new.author_name, new.text);
+END;
+
+-- Trigger to keep comments FTS index in sync on UPDATE
+CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE ON comments
+BEGIN
+    INSERT INTO comments_fts(comments_fts, rowid, author_name, text)
+    VALUES ('delete', old.rowid, old.author_name, old.text);
+    INSERT INTO comments_fts(rowid, author_name, text)
+    VALUES (new.rowid, new.author_name, new.text);
+END;
+
+-- Trigger to keep comments FTS index in sync on DELETE
+CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments
+BEGIN
+    INSERT INTO comments_fts(comments_fts, rowid, author_name, text)
+    VALUES ('delete', old.rowid, old.author_name, old.text);
+END;
+`
+
+// PlaylistSchema defines the database schema for a playlist database.
+// Playlists span channels, so entries only reference the channel that owns
+// the archived video; the video itself lives in that channel's database.
+const PlaylistSchema = `
+-- Playlist items table stores the playlist order
+CREATE TABLE IF NOT EXISTS playlist_items (
+    video_id TEXT PRIMARY KEY,
+    position INTEGER NOT NULL,
+    channel_id TEXT,
+    youtube_channel_id TEXT,
+    channel_name TEXT,
+    title TEXT,
+    duration INTEGER,
+    thumbnail_url TEXT,
+    added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    removed_at DATETIME
+);
+
+-- Index for reading the playlist in order
+CREATE INDEX IF NOT EXISTS idx_playlist_items_position ON playlist_items(position);
+
+-- Sync history table tracks synchronization operations
+CREATE TABLE IF NOT EXISTS sync_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at DATETIME,
+    completed_at DATETIME,
+    videos_found INTEGER,
+    videos_downloaded INTEGER,
+    videos_failed INTEGER,
+    status TEXT
+);
+`
+
+// SearchIndexSchema defines the database schema for the global search index.
+// The index covers the videos of every channel so a search is one query; the
+// channel databases and Redis remain the record of the videos themselves.
+const SearchIndexSchema = `
+-- Indexed videos table holds the searchable text and filter fields of each video.
+-- chapters holds the chapter titles and transcript the subtitle text, one line each.
+CREATE TABLE IF NOT EXISTS indexed_videos (
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    chapters TEXT,
+    transcript TEXT,
+    upload_date TEXT,
+    duration INTEGER,
+    kind TEXT,
+    status TEXT,
+    removed_at DATETIME,
+    fingerprint TEXT,
+    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (channel_id, video_id)
+);
+
+-- Indexes for the search filters
+CREATE INDEX IF NOT EXISTS idx_indexed_videos_upload_date ON indexed_videos(upload_date);
+CREATE INDEX IF NOT EXISTS idx_indexed_videos_duration ON indexed_videos(duration);
+CREATE INDEX IF NOT EXISTS idx_indexed_videos_status ON indexed_videos(status);
+
+-- FTS5 virtual table for full-text search on everything indexed
+CREATE VIRTUAL TABLE IF NOT EXISTS indexed_videos_fts USING fts5(
+    title,
+    description,
+    chapters,
+    transcript,
+    content='indexed_videos',
+    content_rowid='rowid'
+);
+
+-- Trigger to keep FTS index in sync on INSERT
+CREATE TRIGGER IF NOT EXISTS indexed_videos_fts_insert AFTER INSERT ON indexed_videos
+BEGIN
+    INSERT INTO indexed_videos_fts(rowid, title, description, chapters, transcript)
+    VALUES (new.rowid, new.title, new.description, new.chapters, new.transcript);
+END;
+
+-- Trigger to keep FTS index in sync on UPDATE
+CREATE TRIGGER IF NOT EXISTS indexed_videos_fts_update AFTER UPDATE ON indexed_videos
+BEGIN
+    INSERT INTO indexed_videos_fts(indexed_videos_fts, rowid, title, description, chapters, transcript)
+    VALUES ('delete', old.rowid, old.title, old.description, old.chapters, old.transcript);
+    INSERT INTO indexed_videos_fts(rowid, title, description, chapters, transcript)
+    VALUES (new.rowid, new.title, new.description, new.chapters, new.transcript);
+END;
+
+-- Trigger to keep FTS index in sync on DELETE
+CREATE TRIGGER IF NOT EXISTS indexed_videos_fts_delete AFTER DELETE ON indexed_videos
+BEGIN
+    INSERT INTO indexed_videos_fts(indexed_videos_fts, rowid, title, description, chapters, transcript)
+    VALUES ('delete', old.rowid, old.title, old.description, old.chapters, old.transcript);
+END;
+
+-- Transcript cues table holds the time-coded captions of each video, so a
+-- search can find the moment something is said. source tells whether they
+-- came from the subtitle files in the archive or were fetched from YouTube.
+CREATE TABLE IF NOT EXISTS transcript_cues (
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    language TEXT NOT NULL,
+    source TEXT NOT NULL,
+    start_ms INTEGER NOT NULL,
+    end_ms INTEGER NOT NULL,
+    text TEXT NOT NULL
+);
+
+-- Index for reading the transcript of a video in order
+CREATE INDEX IF NOT EXISTS idx_transcript_cues_video ON transcript_cues(channel_id, video_id, language, start_ms);
+
+-- FTS5 virtual table for full-text search on cue text
+CREATE VIRTUAL TABLE IF NOT EXISTS transcript_cues_fts USING fts5(
+    text,
+    content='transcript_cues',
+    content_rowid='rowid'
+);
+
+-- Trigger to keep cue FTS index in sync on INSERT
+CREATE TRIGGER IF NOT EXISTS transcript_cues_fts_insert AFTER INSERT ON transcript_cues
+BEGIN
+    INSERT INTO transcript_cues_fts(rowid, text) VALUES (new.rowid, new.text);
+END;
+
+-- Trigger to keep cue FTS index in sync on DELETE; cues are replaced, never updated
+CREATE TRIGGER IF NOT EXISTS transcript_cues_fts_delete AFTER DELETE ON transcript_cues
+BEGIN
+    INSERT INTO transcript_cues_fts(transcript_cues_fts, rowid, text) VALUES ('delete', old.rowid, old.text);
+END;
+`
+
+// columnMigrations lists columns added to the videos table after its first release.
+// CREATE TABLE IF NOT EXISTS leaves existing databases untouched, so InitSchema
+// adds any of these that are missing.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"videos", "kind", "TEXT DEFAULT 'video'"},
+	{"videos", "removed_at", "DATETIME"},
+	{"videos", "removed_reason", "TEXT"},
+}
+
+// postMigrationSchema holds statements that depend on migrated columns.
+const postMigrationSchema = `
+-- Index for filtering videos by kind
+CREATE INDEX IF NOT EXISTS idx_videos_kind ON videos(kind);
+
+-- Index for finding videos removed upstream
+CREATE INDEX IF NOT EXISTS idx_videos_removed_at ON videos(removed_at);
 `
 
 // VideoStatus represents the possible statuses for a video download.
@@ -95,6 +304,37 @@ const (
 	StatusFailed VideoStatus = "failed"
 	// StatusSkipped indicates the video was skipped (e.g., already exists, too large)
 	StatusSkipped VideoStatus = "skipped"
+	// StatusPruned indicates the video's files were removed by a retention policy
+	StatusPruned VideoStatus = "pruned"
+)
+
+// Video kinds stored in the kind column.
+const (
+	// KindVideo is a regular upload from the Videos tab
+	KindVideo = "video"
+	// KindShort is a video from the Shorts tab
+	KindShort = "short"
+	// KindLive is a live stream replay from the Live tab
+	KindLive = "live"
+	// KindPremiere is a premiered video
+	KindPremiere = "premiere"
+)
+
+// Comment kinds stored in the kind column of the comments table.
+const (
+	// CommentKindComment is a comment or a reply to one
+	CommentKindComment = "comment"
+	// CommentKindChat is a message from a live chat replay
+	CommentKindChat = "chat"
+)
+
+// Transcript sources stored in the source column of the transcript_cues table.
+const (
+	// TranscriptSourceSubtitles is a subtitle file downloaded with the video
+	TranscriptSourceSubtitles = "subtitles"
+	// TranscriptSourceCaptions is a caption track fetched from YouTube for a
+	// video without subtitle files, often generated automatically
+	TranscriptSourceCaptions = "captions"
 )
 
 // SyncStatus represents the possible statuses for a sync operation.
@@ -31,9 +31,9 @@ func SearchVideos(db *sql.DB, query string, limit int) ([]SearchResult, error) {
 
 	sqlQuery := `
 		SELECT v.id, v.title, v.description, v.duration, v.upload_date, v.thumbnail_url,
-		       v.view_count, v.status, v.file_path, v.file_size, v.checksum,
+		       v.view_count, v.kind, v.status, v.file_path, v.file_size, v.checksum,
 		       v.download_started_at, v.download_completed_at, v.retry_count, v.last_error,
-		       v.created_at, v.updated_at, bm25(videos_fts) as rank
+		       v.removed_at, v.removed_reason, v.created_at, v.updated_at, bm25(videos_fts) as rank
 		FROM videos_fts
 		JOIN videos v ON videos_fts.rowid = v.rowid
 		WHERE videos_fts MATCH ?
@@ -66,9 +66,9 @@ func SearchVideosByTitle(db *sql.DB, query string, limit int) ([]SearchResult, e
 
 	sqlQuery := `
 		SELECT v.id, v.title, v.description, v.duration, v.upload_date, v.thumbnail_url,
-		       v.view_count, v.status, v.file_path, v.file_size, v.checksum,
+		       v.view_count, v.kind, v.status, v.file_path, v.file_size, v.checksum,
 		       v.download_started_at, v.download_completed_at, v.retry_count, v.last_error,
-		       v.created_at, v.updated_at, bm25(videos_fts) as rank
+		       v.removed_at, v.removed_reason, v.created_at, v.updated_at, bm25(videos_fts) as rank
 		FROM videos_fts
 		JOIN videos v ON videos_fts.rowid = v.rowid
 		WHERE videos_fts MATCH ?
@@ -101,9 +101,9 @@ func SearchVideosWithFilter(db *sql.DB, query string, status VideoStatus, limit
 
 	sqlQuery := `
 		SELECT v.id, v.title, v.description, v.duration, v.upload_date, v.thumbnail_url,
-		       v.view_count, v.status, v.file_path, v.file_size, v.checksum,
+		       v.view_count, v.kind, v.status, v.file_path, v.file_size, v.checksum,
 		       v.download_started_at, v.download_completed_at, v.retry_count, v.last_error,
-		       v.created_at, v.updated_at, bm25(videos_fts) as rank
+		       v.removed_at, v.removed_reason, v.created_at, v.updated_at, bm25(videos_fts) as rank
 		FROM videos_fts
 		JOIN videos v ON videos_fts.rowid = v.rowid
 		WHERE videos_fts MATCH ? AND v.status = ?
@@ -172,9 +172,9 @@ func scanSearchResults(rows *sql.Rows) ([]SearchResult, error) {
 
 	for rows.Next() {
 		var result SearchResult
-		var description, uploadDate, thumbnailURL, filePath, checksum, lastError sql.NullString
+		var description, uploadDate, thumbnailURL, kind, filePath, checksum, lastError, removedReason sql.NullString
 		var duration, viewCount, fileSize sql.NullInt64
-		var downloadStartedAt, downloadCompletedAt sql.NullTime
+		var downloadStartedAt, downloadCompletedAt, removedAt sql.NullTime
 
 		err := rows.Scan(
 			&result.ID,
@@ -184,6 +184,7 @@ func scanSearchResults(rows *sql.Rows) ([]SearchResult, error) {
 			&uploadDate,
 			&thumbnailURL,
 			&viewCount,
+			&kind,
 			&result.Status,
 			&filePath,
 			&fileSize,
@@ -192,6 +193,8 @@ func scanSearchResults(rows *sql.Rows) ([]SearchResult, error) {
 			&downloadCompletedAt,
 			&result.RetryCount,
 			&lastError,
+			&removedAt,
+			&removedReason,
 			&result.CreatedAt,
 			&result.UpdatedAt,
 			&result.Rank,
@@ -203,9 +206,11 @@ func scanSearchResults(rows *sql.Rows) ([]SearchResult, error) {
 		result.Description = description.String
 		result.UploadDate = uploadDate.String
 		result.ThumbnailURL = thumbnailURL.String
+		result.Kind = kind.String
 		result.FilePath = filePath.String
 		result.Checksum = checksum.String
 		result.LastError = lastError.String
+		result.RemovedReason = removedReason.String
 		result.Duration = duration.Int64
 		result.ViewCount = viewCount.Int64
 		result.FileSize = fileSize.Int64
@@ -216,6 +221,9 @@ func scanSearchResults(rows *sql.Rows) ([]SearchResult, error) {
 		if downloadCompletedAt.Valid {
 			result.DownloadCompletedAt = &downloadCompletedAt.Time
 		}
+		if removedAt.Valid {
+			result.RemovedAt = &removedAt.Time
+		}
 
 		results = append(results, result)
 	}
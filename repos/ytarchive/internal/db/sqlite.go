@@ -1,5 +1,7 @@
 // Package db provides SQLite database operations for the YouTube Channel Archiver.
 // Each channel gets its own SQLite database at: {STORAGE_PATH}/channels/{channel_id}/metadata.db
+// Each playlist gets its own SQLite database at: {STORAGE_PATH}/playlists/{playlist_id}/metadata.db
+// The search index of all channels is a SQLite database at: {STORAGE_PATH}/search/metadata.db
 package db
 
 import (
@@ -84,6 +86,87 @@ func OpenChannelDB(channelID string) (*sql.DB, error) {
 	return db, nil
 }
 
+// PlaylistDataDir returns the base directory for all playlist data.
+// It sits next to the channel data directory so playlists are never
+// mistaken for channels when the channel directory is listed.
+func PlaylistDataDir() string {
+	dir := filepath.Join(filepath.Dir(DataDir()), "playlists")
+	os.MkdirAll(dir, 0755)
+	return dir
+}
+
+// OpenPlaylistDB opens or creates a SQLite database for the specified playlist.
+// The database is stored at /data/playlists/{playlist_id}/metadata.db
+// Playlist entries are kept in the state database now; these databases are
+// only read when moving an archive to it.
+func OpenPlaylistDB(playlistID string) (*sql.DB, error) {
+	if playlistID == "" {
+		return nil, fmt.Errorf("playlist ID cannot be empty")
+	}
+
+	playlistDir := filepath.Join(PlaylistDataDir(), playlistID)
+	dbPath := filepath.Join(playlistDir, DBFileName)
+
+	if err := os.MkdirAll(playlistDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create playlist directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath+"?_foreign_keys=on&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	if _, err := db.Exec(PlaylistSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return db, nil
+}
+
+// SearchIndexPath returns the path of the global search index database.
+// Like the playlists, it sits next to the channel data directory.
+func SearchIndexPath() string {
+	return filepath.Join(filepath.Dir(DataDir()), "search", DBFileName)
+}
+
+// OpenSearchIndex opens or creates the global search index database.
+// The database is stored at /data/search/metadata.db
+func OpenSearchIndex() (*sql.DB, error) {
+	return openSearchIndex(SearchIndexPath())
+}
+
+// openSearchIndex opens or creates a search index database at dbPath.
+func openSearchIndex(dbPath string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create search index directory: %w", err)
+	}
+
+	// The controller and the collector both write to the index, so wait for
+	// the other's lock instead of failing
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	if _, err := db.Exec(SearchIndexSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return db, nil
+}
+
 // InitSchema creates the required database tables if they don't exist.
 func InitSchema(db *sql.DB) error {
 	if db == nil {
@@ -96,9 +179,61 @@ func InitSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	// Bring databases created by older versions up to date
+	if err := migrateColumns(db); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(postMigrationSchema); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
 	return nil
 }
 
+// migrateColumns adds any columns from columnMigrations that are missing.
+func migrateColumns(db *sql.DB) error {
+	for _, m := range columnMigrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+
+	return nil
+}
+
+// columnExists reports whether table has the named column.
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to read table info for %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan table info for %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
 // Close closes the database connection.
 func Close(db *sql.DB) error {
 	if db == nil {
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestInitSchema_MigratesExistingDatabase(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	// A videos table as created before the kind column existed
+	_, err = db.Exec(`
+		CREATE TABLE videos (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT,
+			duration INTEGER,
+			upload_date TEXT,
+			thumbnail_url TEXT,
+			view_count INTEGER,
+			status TEXT DEFAULT 'pending',
+			file_path TEXT,
+			file_size INTEGER,
+			checksum TEXT,
+			download_started_at DATETIME,
+			download_completed_at DATETIME,
+			retry_count INTEGER DEFAULT 0,
+			last_error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO videos (id, title) VALUES ('legacy', 'Legacy Video');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("InitSchema() error = %v", err)
+	}
+
+	// Running it again must be a no-op
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("InitSchema() second run error = %v", err)
+	}
+
+	legacy, err := GetVideoByID(db, "legacy")
+	if err != nil {
+		t.Fatalf("GetVideoByID() error = %v", err)
+	}
+	if legacy == nil || legacy.Kind != KindVideo {
+		t.Errorf("legacy video kind = %v, want %q", legacy, KindVideo)
+	}
+
+	if err := InsertVideo(db, &Video{ID: "short1", Title: "A Short", Kind: KindShort}); err != nil {
+		t.Fatalf("InsertVideo() error = %v", err)
+	}
+	short, err := GetVideoByID(db, "short1")
+	if err != nil {
+		t.Fatalf("GetVideoByID() error = %v", err)
+	}
+	if short.Kind != KindShort {
+		t.Errorf("short video kind = %q, want %q", short.Kind, KindShort)
+	}
+
+	for _, column := range []string{"removed_at", "removed_reason"} {
+		if exists, err := columnExists(db, "videos", column); err != nil || !exists {
+			t.Errorf("column %s was not added: %v", column, err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package db provides SQLite database operations for the YouTube Channel Archiver.
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// TranscriptCue is a caption of an indexed video and when it is shown.
+type TranscriptCue struct {
+	Language string
+	Source   string // TranscriptSourceSubtitles or TranscriptSourceCaptions
+	StartMs  int64
+	EndMs    int64
+	Text     string
+}
+
+// TranscriptSearch describes a search of the transcripts in the global index.
+// Empty fields do not filter.
+type TranscriptSearch struct {
+	Query      string
+	ChannelIDs []string
+	VideoID    string
+	Language   string
+	Context    int // number of cues of surrounding text on each side of a hit
+	Limit      int
+	Offset     int
+}
+
+// TranscriptHit is a cue matching a search of the transcripts.
+type TranscriptHit struct {
+	TranscriptCue
+	ChannelID string
+	VideoID   string
+	Title     string  // title of the video
+	Highlight string  // HTML escaped cue text with the matches in <mark>
+	Before    string  // HTML escaped text of the cues before the hit
+	After     string  // HTML escaped text of the cues after the hit
+	Rank      float64 // BM25 relevance score (lower is more relevant)
+}
+
+// TranscriptSearchResult is a page of transcript hits with the total number of matches.
+type TranscriptSearchResult struct {
+	Hits  []TranscriptHit
+	Total int
+}
+
+// ReplaceTranscriptCues replaces the transcript cues of a video in the search index.
+func ReplaceTranscriptCues(db *sql.DB, channelID, videoID string, cues []TranscriptCue) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM transcript_cues WHERE channel_id = ? AND video_id = ?`, channelID, videoID); err != nil {
+		return fmt.Errorf("failed to clear transcript of %s: %w", videoID, err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO transcript_cues (channel_id, video_id, language, source, start_ms, end_ms, text)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare cue insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, cue := range cues {
+		if _, err := stmt.Exec(channelID, videoID, cue.Language, cue.Source, cue.StartMs, cue.EndMs, cue.Text); err != nil {
+			return fmt.Errorf("failed to save transcript cue of %s: %w", videoID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transcript: %w", err)
+	}
+	return nil
+}
+
+// GetTranscriptCues returns the transcript cues of a video in one language, in order.
+func GetTranscriptCues(db *sql.DB, channelID, videoID, language string) ([]TranscriptCue, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := db.Query(`
+		SELECT language, source, start_ms, end_ms, text
+		FROM transcript_cues
+		WHERE channel_id = ? AND video_id = ? AND language = ?
+		ORDER BY start_ms ASC
+	`, channelID, videoID, language)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transcript: %w", err)
+	}
+	defer rows.Close()
+
+	var cues []TranscriptCue
+	for rows.Next() {
+		var cue TranscriptCue
+		if err := rows.Scan(&cue.Language, &cue.Source, &cue.StartMs, &cue.EndMs, &cue.Text); err != nil {
+			return nil, fmt.Errorf("failed to scan transcript cue: %w", err)
+		}
+		cues = append(cues, cue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transcript cues: %w", err)
+	}
+
+	return cues, nil
+}
+
+// SearchTranscripts performs a full-text search of the transcript cues of
+// all indexed videos. Returns the requested page of hits, best first, each
+// with the text of the cues around it.
+func SearchTranscripts(db *sql.DB, search TranscriptSearch) (*TranscriptSearchResult, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if strings.TrimSpace(search.Query) == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if search.Limit <= 0 {
+		search.Limit = 50
+	}
+
+	match := prepareFTSQuery(search.Query)
+	where, args := search.filters()
+
+	sqlQuery := `
+		SELECT c.channel_id, c.video_id, COALESCE(v.title, ''), c.language, c.source,
+		       c.start_ms, c.end_ms, c.text, bm25(transcript_cues_fts) AS rank,
+		       highlight(transcript_cues_fts, 0, ?, ?)
+		FROM transcript_cues_fts
+		JOIN transcript_cues c ON transcript_cues_fts.rowid = c.rowid
+		LEFT JOIN indexed_videos v ON v.channel_id = c.channel_id AND v.video_id = c.video_id
+		WHERE transcript_cues_fts MATCH ?` + where + `
+		ORDER BY rank, c.start_ms
+		LIMIT ? OFFSET ?
+	`
+
+	queryArgs := append([]interface{}{matchStart, matchEnd, match}, args...)
+	rows, err := db.Query(sqlQuery, append(queryArgs, search.Limit, search.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+
+	result := &TranscriptSearchResult{Hits: []TranscriptHit{}}
+	for rows.Next() {
+		var hit TranscriptHit
+		if err := rows.Scan(
+			&hit.ChannelID, &hit.VideoID, &hit.Title, &hit.Language, &hit.Source,
+			&hit.StartMs, &hit.EndMs, &hit.Text, &hit.Rank, &hit.Highlight,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan transcript hit: %w", err)
+		}
+		hit.Highlight = markMatches(hit.Highlight)
+		result.Hits = append(result.Hits, hit)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transcript hits: %w", err)
+	}
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM transcript_cues_fts
+		JOIN transcript_cues c ON transcript_cues_fts.rowid = c.rowid
+		WHERE transcript_cues_fts MATCH ?` + where
+	if err := db.QueryRow(countQuery, append([]interface{}{match}, args...)...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count transcript hits: %w", err)
+	}
+
+	if search.Context > 0 {
+		for i := range result.Hits {
+			if err := addCueContext(db, &result.Hits[i], search.Context); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// filters returns the WHERE conditions for the transcript search filters.
+func (s TranscriptSearch) filters() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(s.ChannelIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(s.ChannelIDs)), ", ")
+		conditions = append(conditions, "c.channel_id IN ("+placeholders+")")
+		for _, channelID := range s.ChannelIDs {
+			args = append(args, channelID)
+		}
+	}
+	if s.VideoID != "" {
+		conditions = append(conditions, "c.video_id = ?")
+		args = append(args, s.VideoID)
+	}
+	if s.Language != "" {
+		conditions = append(conditions, "c.language = ?")
+		args = append(args, s.Language)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// addCueContext fills in the text of the n cues before and after a hit.
+func addCueContext(db *sql.DB, hit *TranscriptHit, n int) error {
+	before, err := cueTexts(db, `
+		SELECT text FROM transcript_cues
+		WHERE channel_id = ? AND video_id = ? AND language = ? AND start_ms < ?
+		ORDER BY start_ms DESC
+		LIMIT ?
+	`, hit, n)
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	after, err := cueTexts(db, `
+		SELECT text FROM transcript_cues
+		WHERE channel_id = ? AND video_id = ? AND language = ? AND start_ms > ?
+		ORDER BY start_ms ASC
+		LIMIT ?
+	`, hit, n)
+	if err != nil {
+		return err
+	}
+
+	hit.Before = html.EscapeString(strings.Join(before, " "))
+	hit.After = html.EscapeString(strings.Join(after, " "))
+	return nil
+}
+
+// cueTexts returns the text of the cues a context query selects around a hit.
+func cueTexts(db *sql.DB, query string, hit *TranscriptHit, n int) ([]string, error) {
+	rows, err := db.Query(query, hit.ChannelID, hit.VideoID, hit.Language, hit.StartMs, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transcript context: %w", err)
+	}
+	defer rows.Close()
+
+	var texts []string
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, fmt.Errorf("failed to scan transcript context: %w", err)
+		}
+		texts = append(texts, text)
+	}
+	return texts, rows.Err()
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSearchTranscripts(t *testing.T) {
+	index := setupTestIndex(t)
+
+	if err := IndexVideo(index, &IndexedVideo{ChannelID: "ch1", VideoID: "v1", Title: "Building a bridge"}); err != nil {
+		t.Fatalf("IndexVideo() error = %v", err)
+	}
+	cues := []TranscriptCue{
+		{Language: "en", Source: TranscriptSourceSubtitles, StartMs: 0, EndMs: 2000, Text: "welcome back"},
+		{Language: "en", Source: TranscriptSourceSubtitles, StartMs: 2000, EndMs: 4000, Text: "today we <clamp> the beams"},
+		{Language: "en", Source: TranscriptSourceSubtitles, StartMs: 4000, EndMs: 6000, Text: "then we build the bridge"},
+		{Language: "en", Source: TranscriptSourceSubtitles, StartMs: 6000, EndMs: 8000, Text: "and test it"},
+		{Language: "de", Source: TranscriptSourceCaptions, StartMs: 4000, EndMs: 6000, Text: "dann bauen wir die bridge"},
+	}
+	if err := ReplaceTranscriptCues(index, "ch1", "v1", cues); err != nil {
+		t.Fatalf("ReplaceTranscriptCues() error = %v", err)
+	}
+	if err := ReplaceTranscriptCues(index, "ch2", "v2", []TranscriptCue{
+		{Language: "en", Source: TranscriptSourceCaptions, StartMs: 90000, EndMs: 92000, Text: "a bridge too far"},
+	}); err != nil {
+		t.Fatalf("ReplaceTranscriptCues() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		search TranscriptSearch
+		want   int
+	}{
+		{"all transcripts", TranscriptSearch{Query: "bridge"}, 3},
+		{"by channel", TranscriptSearch{Query: "bridge", ChannelIDs: []string{"ch2"}}, 1},
+		{"by video", TranscriptSearch{Query: "bridge", VideoID: "v1"}, 2},
+		{"by language", TranscriptSearch{Query: "bridge", Language: "de"}, 1},
+		{"no match", TranscriptSearch{Query: "kayak"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SearchTranscripts(index, tt.search)
+			if err != nil {
+				t.Fatalf("SearchTranscripts() error = %v", err)
+			}
+			if result.Total != tt.want || len(result.Hits) != tt.want {
+				t.Errorf("SearchTranscripts() found %d of %d, want %d", len(result.Hits), result.Total, tt.want)
+			}
+		})
+	}
+
+	result, err := SearchTranscripts(index, TranscriptSearch{Query: "clamp", Context: 1})
+	if err != nil || len(result.Hits) != 1 {
+		t.Fatalf("SearchTranscripts() = %+v, %v, want 1 hit", result, err)
+	}
+	hit := result.Hits[0]
+	if hit.Title != "Building a bridge" || hit.StartMs != 2000 || hit.EndMs != 4000 {
+		t.Errorf("hit = %+v, want the cue at 2s of v1", hit)
+	}
+	if want := "today we &lt;<mark>clamp</mark>&gt; the beams"; hit.Highlight != want {
+		t.Errorf("Highlight = %q, want %q", hit.Highlight, want)
+	}
+	if hit.Before != "welcome back" || hit.After != "then we build the bridge" {
+		t.Errorf("context = %q / %q, want the neighbouring cues", hit.Before, hit.After)
+	}
+
+	// Replacing a transcript drops the old cues, and removing the video drops the new ones
+	if err := ReplaceTranscriptCues(index, "ch1", "v1", cues[:1]); err != nil {
+		t.Fatalf("ReplaceTranscriptCues() error = %v", err)
+	}
+	if result, _ := SearchTranscripts(index, TranscriptSearch{Query: "clamp"}); result.Total != 0 {
+		t.Errorf("SearchTranscripts() found %d replaced cues", result.Total)
+	}
+	if err := RemoveIndexedVideo(index, "ch1", "v1"); err != nil {
+		t.Fatalf("RemoveIndexedVideo() error = %v", err)
+	}
+	if remaining, _ := GetTranscriptCues(index, "ch1", "v1", "en"); len(remaining) != 0 {
+		t.Errorf("GetTranscriptCues() = %+v after removing the video", remaining)
+	}
+}
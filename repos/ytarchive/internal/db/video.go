@@ -16,6 +16,7 @@ type Video struct {
 	UploadDate          string
 	ThumbnailURL        string
 	ViewCount           int64
+	Kind                string
 	Status              VideoStatus
 	FilePath            string
 	FileSize            int64
@@ -24,6 +25,8 @@ type Video struct {
 	DownloadCompletedAt *time.Time
 	RetryCount          int
 	LastError           string
+	RemovedAt           *time.Time // when the video disappeared from the channel on YouTube
+	RemovedReason       string     // why YouTube no longer serves it, from the player response
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
 }
@@ -49,8 +52,8 @@ func InsertVideo(db *sql.DB, video *Video) error {
 	}
 
 	query := `
-		INSERT INTO videos (id, title, description, duration, upload_date, thumbnail_url, view_count, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO videos (id, title, description, duration, upload_date, thumbnail_url, view_count, kind, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			title = excluded.title,
 			description = excluded.description,
@@ -58,6 +61,7 @@ func InsertVideo(db *sql.DB, video *Video) error {
 			upload_date = excluded.upload_date,
 			thumbnail_url = excluded.thumbnail_url,
 			view_count = excluded.view_count,
+			kind = excluded.kind,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
@@ -66,6 +70,11 @@ func InsertVideo(db *sql.DB, video *Video) error {
 		status = StatusPending
 	}
 
+	kind := video.Kind
+	if kind == "" {
+		kind = KindVideo
+	}
+
 	_, err := db.Exec(query,
 		video.ID,
 		video.Title,
@@ -74,6 +83,7 @@ func InsertVideo(db *sql.DB, video *Video) error {
 		video.UploadDate,
 		video.ThumbnailURL,
 		video.ViewCount,
+		kind,
 		status,
 	)
 	if err != nil {
@@ -124,8 +134,8 @@ func getVideosByStatus(db *sql.DB, status VideoStatus) ([]Video, error) {
 
 	query := `
 		SELECT id, title, description, duration, upload_date, thumbnail_url, view_count,
-		       status, file_path, file_size, checksum, download_started_at, download_completed_at,
-		       retry_count, last_error, created_at, updated_at
+		       kind, status, file_path, file_size, checksum, download_started_at, download_completed_at,
+		       retry_count, last_error, removed_at, removed_reason, created_at, updated_at
 		FROM videos
 		WHERE status = ?
 		ORDER BY upload_date DESC
@@ -148,8 +158,8 @@ func GetVideoByID(db *sql.DB, videoID string) (*Video, error) {
 
 	query := `
 		SELECT id, title, description, duration, upload_date, thumbnail_url, view_count,
-		       status, file_path, file_size, checksum, download_started_at, download_completed_at,
-		       retry_count, last_error, created_at, updated_at
+		       kind, status, file_path, file_size, checksum, download_started_at, download_completed_at,
+		       retry_count, last_error, removed_at, removed_reason, created_at, updated_at
 		FROM videos
 		WHERE id = ?
 	`
@@ -225,6 +235,57 @@ func MarkDownloadCompleted(db *sql.DB, videoID, filePath string, fileSize int64,
 	return nil
 }
 
+// ReplaceVideos replaces every video in the database with the given videos,
+// for rebuilding a channel database from the records of its channel.
+func ReplaceVideos(db *sql.DB, videos []*Video) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM videos`); err != nil {
+		return fmt.Errorf("failed to clear videos: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO videos (id, title, description, duration, upload_date, thumbnail_url, view_count,
+		                    kind, status, file_path, file_size, checksum, removed_at, removed_reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare video insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, video := range videos {
+		status := video.Status
+		if status == "" {
+			status = StatusPending
+		}
+		kind := video.Kind
+		if kind == "" {
+			kind = KindVideo
+		}
+		if _, err := stmt.Exec(
+			video.ID, video.Title, video.Description, video.Duration, video.UploadDate,
+			video.ThumbnailURL, video.ViewCount, kind, status, video.FilePath, video.FileSize,
+			video.Checksum, video.RemovedAt, video.RemovedReason,
+		); err != nil {
+			return fmt.Errorf("failed to insert video %s: %w", video.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit videos: %w", err)
+	}
+	return nil
+}
+
 // MarkDownloadFailed marks a video as failed with an error message.
 func MarkDownloadFailed(db *sql.DB, videoID, errorMsg string) error {
 	if db == nil {
@@ -283,8 +344,8 @@ func GetAllVideos(db *sql.DB) ([]Video, error) {
 
 	query := `
 		SELECT id, title, description, duration, upload_date, thumbnail_url, view_count,
-		       status, file_path, file_size, checksum, download_started_at, download_completed_at,
-		       retry_count, last_error, created_at, updated_at
+		       kind, status, file_path, file_size, checksum, download_started_at, download_completed_at,
+		       retry_count, last_error, removed_at, removed_reason, created_at, updated_at
 		FROM videos
 		ORDER BY upload_date DESC
 	`
@@ -428,9 +489,9 @@ func GetLatestSyncHistory(db *sql.DB) (*SyncHistory, error) {
 // scanVideo scans a single video row.
 func scanVideo(row *sql.Row) (*Video, error) {
 	var video Video
-	var description, uploadDate, thumbnailURL, filePath, checksum, lastError sql.NullString
+	var description, uploadDate, thumbnailURL, kind, filePath, checksum, lastError, removedReason sql.NullString
 	var duration, viewCount, fileSize sql.NullInt64
-	var downloadStartedAt, downloadCompletedAt sql.NullTime
+	var downloadStartedAt, downloadCompletedAt, removedAt sql.NullTime
 
 	err := row.Scan(
 		&video.ID,
@@ -440,6 +501,7 @@ func scanVideo(row *sql.Row) (*Video, error) {
 		&uploadDate,
 		&thumbnailURL,
 		&viewCount,
+		&kind,
 		&video.Status,
 		&filePath,
 		&fileSize,
@@ -448,6 +510,8 @@ func scanVideo(row *sql.Row) (*Video, error) {
 		&downloadCompletedAt,
 		&video.RetryCount,
 		&lastError,
+		&removedAt,
+		&removedReason,
 		&video.CreatedAt,
 		&video.UpdatedAt,
 	)
@@ -458,9 +522,11 @@ func scanVideo(row *sql.Row) (*Video, error) {
 	video.Description = description.String
 	video.UploadDate = uploadDate.String
 	video.ThumbnailURL = thumbnailURL.String
+	video.Kind = kind.String
 	video.FilePath = filePath.String
 	video.Checksum = checksum.String
 	video.LastError = lastError.String
+	video.RemovedReason = removedReason.String
 	video.Duration = duration.Int64
 	video.ViewCount = viewCount.Int64
 	video.FileSize = fileSize.Int64
@@ -471,6 +537,9 @@ func scanVideo(row *sql.Row) (*Video, error) {
 	if downloadCompletedAt.Valid {
 		video.DownloadCompletedAt = &downloadCompletedAt.Time
 	}
+	if removedAt.Valid {
+		video.RemovedAt = &removedAt.Time
+	}
 
 	return &video, nil
 }
@@ -481,9 +550,9 @@ func scanVideos(rows *sql.Rows) ([]Video, error) {
 
 	for rows.Next() {
 		var video Video
-		var description, uploadDate, thumbnailURL, filePath, checksum, lastError sql.NullString
+		var description, uploadDate, thumbnailURL, kind, filePath, checksum, lastError, removedReason sql.NullString
 		var duration, viewCount, fileSize sql.NullInt64
-		var downloadStartedAt, downloadCompletedAt sql.NullTime
+		var downloadStartedAt, downloadCompletedAt, removedAt sql.NullTime
 
 		err := rows.Scan(
 			&video.ID,
@@ -493,6 +562,7 @@ func scanVideos(rows *sql.Rows) ([]Video, error) {
 			&uploadDate,
 			&thumbnailURL,
 			&viewCount,
+			&kind,
 			&video.Status,
 			&filePath,
 			&fileSize,
@@ -501,6 +571,8 @@ func scanVideos(rows *sql.Rows) ([]Video, error) {
 			&downloadCompletedAt,
 			&video.RetryCount,
 			&lastError,
+			&removedAt,
+			&removedReason,
 			&video.CreatedAt,
 			&video.UpdatedAt,
 		)
@@ -511,9 +583,11 @@ func scanVideos(rows *sql.Rows) ([]Video, error) {
 		video.Description = description.String
 		video.UploadDate = uploadDate.String
 		video.ThumbnailURL = thumbnailURL.String
+		video.Kind = kind.String
 		video.FilePath = filePath.String
 		video.Checksum = checksum.String
 		video.LastError = lastError.String
+		video.RemovedReason = removedReason.String
 		video.Duration = duration.Int64
 		video.ViewCount = viewCount.Int64
 		video.FileSize = fileSize.Int64
@@ -524,6 +598,9 @@ func scanVideos(rows *sql.Rows) ([]Video, error) {
 		if downloadCompletedAt.Valid {
 			video.DownloadCompletedAt = &downloadCompletedAt.Time
 		}
+		if removedAt.Valid {
+			video.RemovedAt = &removedAt.Time
+		}
 
 		videos = append(videos, video)
 	}
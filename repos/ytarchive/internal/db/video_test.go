@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -524,6 +525,49 @@ func TestGetAllVideos(t *testing.T) {
 	}
 }
 
+func TestReplaceVideos(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := InsertVideo(db, &Video{ID: "gone", Title: "Deleted upstream"}); err != nil {
+		t.Fatalf("Failed to insert test video: %v", err)
+	}
+	if err := InsertVideo(db, &Video{ID: "kept", Title: "Bridges", Status: StatusPending}); err != nil {
+		t.Fatalf("Failed to insert test video: %v", err)
+	}
+
+	removedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	err := ReplaceVideos(db, []*Video{
+		{ID: "kept", Title: "Bridges of Oregon", Status: StatusCompleted, FilePath: "/data/kept.mp4", FileSize: 1024},
+		{ID: "new", Title: "Tunnels", Kind: KindShort, RemovedAt: &removedAt, RemovedReason: "private"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceVideos() error = %v", err)
+	}
+
+	if video, _ := GetVideoByID(db, "gone"); video != nil {
+		t.Errorf("GetVideoByID(gone) = %+v, want the video removed", video)
+	}
+	kept, err := GetVideoByID(db, "kept")
+	if err != nil || kept == nil {
+		t.Fatalf("GetVideoByID(kept) = %v, %v", kept, err)
+	}
+	if kept.Status != StatusCompleted || kept.FilePath != "/data/kept.mp4" || kept.FileSize != 1024 {
+		t.Errorf("GetVideoByID(kept) = %+v, want the status and file replaced", kept)
+	}
+	added, _ := GetVideoByID(db, "new")
+	if added == nil || added.Status != StatusPending || added.Kind != KindShort || added.RemovedAt == nil || added.RemovedReason != "private" {
+		t.Errorf("GetVideoByID(new) = %+v", added)
+	}
+
+	// The full-text index follows the replaced titles
+	if results, err := SearchVideos(db, "oregon", 10); err != nil || len(results) != 1 || results[0].ID != "kept" {
+		t.Errorf("SearchVideos(oregon) = %+v, %v; want kept", results, err)
+	}
+	if results, _ := SearchVideos(db, "deleted", 10); len(results) != 0 {
+		t.Errorf("SearchVideos(deleted) = %+v, want none", results)
+	}
+}
+
 func TestGetVideoCount(t *testing.T) {
 	db := setupTestDB(t)
 
@@ -0,0 +1,231 @@
+package downloader
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SponsorBlock handling modes
+const (
+	// SegmentModeMark adds a chapter for each segment and keeps the video intact
+	SegmentModeMark = "mark"
+	// SegmentModeRemove cuts the segments out of the video
+	SegmentModeRemove = "remove"
+)
+
+// Chapter is a titled section of a video. Times are in seconds; an End of zero
+// means the chapter runs to the end of the video.
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start_time"`
+	End   float64 `json:"end_time"`
+}
+
+// Segment is a span of a video to mark or remove, such as a sponsor read
+type Segment struct {
+	Start    float64 `json:"start_time"`
+	End      float64 `json:"end_time"`
+	Category string  `json:"category"`
+}
+
+// segmentTitles are the chapter titles used for marked segments
+var segmentTitles = map[string]string{
+	"sponsor":        "Sponsor",
+	"selfpromo":      "Self-promotion",
+	"interaction":    "Interaction reminder",
+	"intro":          "Intro",
+	"outro":          "Outro",
+	"preview":        "Preview",
+	"music_offtopic": "Non-music section",
+	"filler":         "Filler",
+}
+
+// segmentTitle returns the chapter title for a segment category
+func segmentTitle(category string) string {
+	if title, ok := segmentTitles[category]; ok {
+		return title
+	}
+	if category == "" {
+		return "Segment"
+	}
+	return strings.ToUpper(category[:1]) + category[1:]
+}
+
+// mergeSegments returns segments sorted by start time, with overlapping and
+// adjacent segments combined. Segments with no length are dropped.
+func mergeSegments(segments []Segment) []Segment {
+	sorted := make([]Segment, 0, len(segments))
+	for _, segment := range segments {
+		if segment.End > segment.Start && segment.Start >= 0 {
+			sorted = append(sorted, segment)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	var merged []Segment
+	for _, segment := range sorted {
+		if n := len(merged); n > 0 && segment.Start <= merged[n-1].End {
+			if segment.End > merged[n-1].End {
+				merged[n-1].End = segment.End
+			}
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// chapterEnd returns the end of a chapter, treating an unset end as open-ended
+func chapterEnd(chapter Chapter) float64 {
+	if chapter.End <= chapter.Start {
+		return math.Inf(1)
+	}
+	return chapter.End
+}
+
+// clampChapter builds a chapter, leaving End unset if it is open-ended
+func clampChapter(title string, start, end float64) Chapter {
+	if math.IsInf(end, 1) {
+		end = 0
+	}
+	return Chapter{Title: title, Start: start, End: end}
+}
+
+// MarkSegments returns chapters with each segment as a chapter of its own,
+// titled after its category. The chapters a segment overlaps are split around it.
+// If there are no chapters, the rest of the video becomes untitled chapters, the
+// last of which ends at duration (in seconds, 0 if unknown).
+func MarkSegments(chapters []Chapter, segments []Segment, duration float64) []Chapter {
+	segments = mergeSegments(segments)
+	if len(segments) == 0 {
+		return chapters
+	}
+	if len(chapters) == 0 {
+		chapters = []Chapter{{Start: 0, End: duration}}
+	}
+
+	var marked []Chapter
+	for _, chapter := range chapters {
+		start, end := chapter.Start, chapterEnd(chapter)
+		for _, segment := range segments {
+			if segment.End <= start || segment.Start >= end {
+				continue
+			}
+			if segment.Start > start {
+				marked = append(marked, clampChapter(chapter.Title, start, segment.Start))
+			}
+			start = segment.End
+		}
+		if start < end {
+			marked = append(marked, clampChapter(chapter.Title, start, end))
+		}
+	}
+
+	for _, segment := range segments {
+		marked = append(marked, Chapter{Title: segmentTitle(segment.Category), Start: segment.Start, End: segment.End})
+	}
+	sort.SliceStable(marked, func(i, j int) bool {
+		return marked[i].Start < marked[j].Start
+	})
+	return marked
+}
+
+// CutChapters returns chapters with their times adjusted for the segments that
+// are removed from the video. Chapters that lie entirely within removed
+// segments are dropped.
+func CutChapters(chapters []Chapter, removed []Segment) []Chapter {
+	removed = mergeSegments(removed)
+	if len(removed) == 0 {
+		return chapters
+	}
+
+	var cut []Chapter
+	for _, chapter := range chapters {
+		start, end := shiftTime(chapter.Start, removed), chapterEnd(chapter)
+		if !math.IsInf(end, 1) {
+			end = shiftTime(end, removed)
+		}
+		if end <= start {
+			continue
+		}
+		cut = append(cut, clampChapter(chapter.Title, start, end))
+	}
+	return cut
+}
+
+// shiftTime maps a time in the original video to the time in the video with
+// removed segments cut out. Times inside a segment map to where it was cut.
+func shiftTime(t float64, removed []Segment) float64 {
+	shift := 0.0
+	for _, segment := range removed {
+		if t <= segment.Start {
+			break
+		}
+		if t < segment.End {
+			return segment.Start - shift
+		}
+		shift += segment.End - segment.Start
+	}
+	return t - shift
+}
+
+// keepExpression builds an ffmpeg select expression that drops the removed segments
+func keepExpression(removed []Segment) string {
+	parts := make([]string, 0, len(removed))
+	for _, segment := range removed {
+		parts = append(parts, fmt.Sprintf("between(t,%s,%s)", formatSeconds(segment.Start), formatSeconds(segment.End)))
+	}
+	return "not(" + strings.Join(parts, "+") + ")"
+}
+
+// formatSeconds formats seconds for an ffmpeg expression
+func formatSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// writeFFMetadata writes chapters to an ffmpeg metadata file. Open-ended
+// chapters end at the start of the next chapter, or at duration for the last.
+func writeFFMetadata(path string, chapters []Chapter, duration float64) error {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+
+	for i, chapter := range chapters {
+		end := chapter.End
+		if end <= chapter.Start {
+			if i+1 < len(chapters) {
+				end = chapters[i+1].Start
+			} else {
+				end = duration
+			}
+		}
+		if end <= chapter.Start {
+			continue
+		}
+
+		b.WriteString("\n[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&b, "START=%d\n", int64(math.Round(chapter.Start*1000)))
+		fmt.Fprintf(&b, "END=%d\n", int64(math.Round(end*1000)))
+		fmt.Fprintf(&b, "title=%s\n", escapeFFMetadata(chapter.Title))
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// ffmetadataEscaper escapes the characters that are special in ffmpeg metadata files
+var ffmetadataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"=", `\=`,
+	";", `\;`,
+	"#", `\#`,
+	"\n", "\\\n",
+)
+
+// escapeFFMetadata escapes a value for an ffmpeg metadata file
+func escapeFFMetadata(value string) string {
+	return ffmetadataEscaper.Replace(value)
+}
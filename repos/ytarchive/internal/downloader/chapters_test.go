@@ -0,0 +1,193 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeSegments(t *testing.T) {
+	segments := []Segment{
+		{Start: 50, End: 60, Category: "sponsor"},
+		{Start: 10, End: 20, Category: "sponsor"},
+		{Start: 15, End: 25, Category: "selfpromo"},
+		{Start: 25, End: 30, Category: "sponsor"},
+		{Start: 70, End: 70, Category: "sponsor"},
+	}
+
+	want := []Segment{
+		{Start: 10, End: 30, Category: "sponsor"},
+		{Start: 50, End: 60, Category: "sponsor"},
+	}
+	if got := mergeSegments(segments); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSegments() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMarkSegments(t *testing.T) {
+	chapters := []Chapter{
+		{Title: "Intro", Start: 0, End: 60},
+		{Title: "Main", Start: 60, End: 300},
+	}
+
+	tests := []struct {
+		name     string
+		chapters []Chapter
+		segments []Segment
+		duration float64
+		want     []Chapter
+	}{
+		{
+			name:     "no segments",
+			chapters: chapters,
+			want:     chapters,
+		},
+		{
+			name:     "segment inside a chapter",
+			chapters: chapters,
+			segments: []Segment{{Start: 100, End: 130, Category: "sponsor"}},
+			want: []Chapter{
+				{Title: "Intro", Start: 0, End: 60},
+				{Title: "Main", Start: 60, End: 100},
+				{Title: "Sponsor", Start: 100, End: 130},
+				{Title: "Main", Start: 130, End: 300},
+			},
+		},
+		{
+			name:     "segment across a chapter boundary",
+			chapters: chapters,
+			segments: []Segment{{Start: 50, End: 70, Category: "selfpromo"}},
+			want: []Chapter{
+				{Title: "Intro", Start: 0, End: 50},
+				{Title: "Self-promotion", Start: 50, End: 70},
+				{Title: "Main", Start: 70, End: 300},
+			},
+		},
+		{
+			name:     "no chapters",
+			segments: []Segment{{Start: 0, End: 15, Category: "intro"}},
+			duration: 120,
+			want: []Chapter{
+				{Title: "Intro", Start: 0, End: 15},
+				{Title: "", Start: 15, End: 120},
+			},
+		},
+		{
+			name:     "open-ended last chapter",
+			chapters: []Chapter{{Title: "All", Start: 0}},
+			segments: []Segment{{Start: 30, End: 40, Category: "sponsor"}},
+			want: []Chapter{
+				{Title: "All", Start: 0, End: 30},
+				{Title: "Sponsor", Start: 30, End: 40},
+				{Title: "All", Start: 40},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MarkSegments(tt.chapters, tt.segments, tt.duration)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MarkSegments() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutChapters(t *testing.T) {
+	chapters := []Chapter{
+		{Title: "Intro", Start: 0, End: 60},
+		{Title: "Ad", Start: 60, End: 90},
+		{Title: "Main", Start: 90, End: 300},
+		{Title: "Outro", Start: 300},
+	}
+	removed := []Segment{
+		{Start: 60, End: 90},
+		{Start: 200, End: 220},
+	}
+
+	want := []Chapter{
+		{Title: "Intro", Start: 0, End: 60},
+		{Title: "Main", Start: 60, End: 250},
+		{Title: "Outro", Start: 250},
+	}
+	if got := CutChapters(chapters, removed); !reflect.DeepEqual(got, want) {
+		t.Errorf("CutChapters() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteFFMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chapters.ffmetadata")
+	chapters := []Chapter{
+		{Title: "Intro", Start: 0, End: 12.5},
+		{Title: "Q&A; a=b #1", Start: 12.5},
+		{Title: "Ending", Start: 90},
+	}
+
+	if err := writeFFMetadata(path, chapters, 100); err != nil {
+		t.Fatalf("writeFFMetadata() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `;FFMETADATA1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=12500
+title=Intro
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=12500
+END=90000
+title=Q&A\; a\=b \#1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=90000
+END=100000
+title=Ending
+`
+	if string(data) != want {
+		t.Errorf("writeFFMetadata() wrote\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestBuildMergeArgsWithOptions(t *testing.T) {
+	m := NewMerger()
+	o := newMergeOptions([]MergeOption{
+		WithChapters([]Chapter{{Title: "A", Start: 0, End: 10}}),
+		WithRemovedSegments([]Segment{{Start: 1, End: 2.5}}),
+	})
+
+	args := m.buildMergeArgsWithOptions("v.mp4", "a.m4a", "out.mp4", "meta.txt", o)
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"-f ffmetadata -i meta.txt",
+		"-map_metadata 2 -map_chapters 2",
+		"[0:v]select='not(between(t,1.000,2.500))',setpts=N/FRAME_RATE/TB[v]",
+		"[1:a]aselect='not(between(t,1.000,2.500))',asetpts=N/SR/TB[a]",
+		"-map [v] -map [a]",
+		"-c:v libx264",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args %q missing %q", joined, want)
+		}
+	}
+	if strings.Contains(joined, "-c:v copy") {
+		t.Errorf("args %q should re-encode when cutting", joined)
+	}
+
+	// Chapters are shifted for the removed segment
+	if o.chapters[0].End != 8.5 {
+		t.Errorf("chapter end = %v, want 8.5", o.chapters[0].End)
+	}
+}
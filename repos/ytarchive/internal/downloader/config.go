@@ -29,9 +29,13 @@ type Config struct {
 	// WriteSubtitles enables subtitle download
 	WriteSubtitles bool
 
-	// MergeOutputFormat specifies the output format after merging
+	// MergeOutputFormat specifies the output format after merging (mp4 or mkv)
 	MergeOutputFormat string
 
+	// EmbedMetadata writes the title, channel, date, description, thumbnail and
+	// subtitles into the video file, so media servers can read them
+	EmbedMetadata bool
+
 	// Retries is the number of download retries
 	Retries int
 
@@ -50,6 +54,17 @@ type Config struct {
 
 	// ReadTimeout is the timeout for read operations (seconds)
 	ReadTimeout int
+
+	// SponsorBlockDB is the path to a local SponsorBlock-compatible JSON database.
+	// Segments are only applied when it is set.
+	SponsorBlockDB string
+
+	// SponsorBlockCategories are the segment categories to apply (default sponsor)
+	SponsorBlockCategories []string
+
+	// SegmentMode is what happens to SponsorBlock segments: SegmentModeMark adds
+	// chapters for them, SegmentModeRemove cuts them out (empty means mark)
+	SegmentMode string
 }
 
 // DefaultConfig returns a Config with sensible defaults
@@ -62,12 +77,17 @@ func DefaultConfig(outputPath string) *Config {
 		WriteInfoJSON:        getEnvBool("WRITE_INFO_JSON", true),
 		WriteSubtitles:       getEnvBool("WRITE_SUBTITLES", true),
 		MergeOutputFormat:    getEnvString("MERGE_OUTPUT_FORMAT", "mp4"),
+		EmbedMetadata:        getEnvBool("EMBED_METADATA", false),
 		Retries:              getEnvInt("DOWNLOAD_RETRIES", 3),
 		RetryDelays:          []int{5, 15, 45},                            // Exponential backoff: 5s, 15s, 45s
 		PreferCombinedStream: getEnvBool("PREFER_COMBINED_STREAM", false), // False to prefer highest quality
 		ChunkSize:            int64(getEnvInt("CHUNK_SIZE", 1024*1024)),   // 1MB default
 		ConnectionTimeout:    getEnvInt("CONNECTION_TIMEOUT", 30),
 		ReadTimeout:          getEnvInt("READ_TIMEOUT", 60),
+
+		SponsorBlockDB:         getEnvString("SPONSORBLOCK_DB", ""),
+		SponsorBlockCategories: getEnvSlice("SPONSORBLOCK_CATEGORIES", []string{"sponsor"}),
+		SegmentMode:            getEnvString("SPONSORBLOCK_MODE", SegmentModeMark),
 	}
 }
 
@@ -102,6 +122,20 @@ func WithWriteThumbnail(write bool) ConfigOption {
 	}
 }
 
+// WithEmbedMetadata sets whether to embed metadata, thumbnail and subtitles in the video file
+func WithEmbedMetadata(embed bool) ConfigOption {
+	return func(c *Config) {
+		c.EmbedMetadata = embed
+	}
+}
+
+// WithMergeOutputFormat sets the container of merged videos (mp4 or mkv)
+func WithMergeOutputFormat(format string) ConfigOption {
+	return func(c *Config) {
+		c.MergeOutputFormat = format
+	}
+}
+
 // WithWriteInfoJSON sets whether to write metadata JSON
 func WithWriteInfoJSON(write bool) ConfigOption {
 	return func(c *Config) {
@@ -177,6 +211,15 @@ func (c *Config) VideoDir(channelID, videoID string) string {
 	return filepath.Join(c.OutputPath, videoID)
 }
 
+// OutputFormat returns the container extension of finished videos: "mkv" when
+// MergeOutputFormat asks for Matroska, otherwise "mp4"
+func (c *Config) OutputFormat() string {
+	if strings.EqualFold(c.MergeOutputFormat, "mkv") {
+		return "mkv"
+	}
+	return "mp4"
+}
+
 // SubtitleLangsString returns the subtitle languages as a comma-separated string
 func (c *Config) SubtitleLangsString() string {
 	if len(c.SubtitleLangs) == 0 {
@@ -196,6 +239,9 @@ func (c *Config) Validate() error {
 	if c.Retries <= 0 {
 		return ErrInvalidConfig("retries must be positive")
 	}
+	if c.SegmentMode != "" && c.SegmentMode != SegmentModeMark && c.SegmentMode != SegmentModeRemove {
+		return ErrInvalidConfig("segment mode must be mark or remove")
+	}
 	return nil
 }
 
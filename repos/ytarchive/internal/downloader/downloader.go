@@ -8,11 +8,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/timholm/ytarchive/internal/logging"
 	"github.com/timholm/ytarchive/internal/metrics"
+	"github.com/timholm/ytarchive/internal/ratelimit"
 )
 
 // Downloader handles downloading videos using native HTTP
@@ -25,6 +27,7 @@ type Downloader struct {
 	userAgent     string
 	cookieHeader  string
 	useAndroid    bool
+	rateLimiter   ratelimit.BudgetLimiter // limits stream requests, nil for no limit
 }
 
 // NewDownloader creates a new Downloader with the given configuration
@@ -58,6 +61,11 @@ func (d *Downloader) SetCookies(cookieHeader string) {
 	d.cookieHeader = cookieHeader
 }
 
+// SetRateLimiter sets the limiter stream requests wait on, from the media budget
+func (d *Downloader) SetRateLimiter(limiter ratelimit.BudgetLimiter) {
+	d.rateLimiter = limiter
+}
+
 // DownloadResult contains the result of a download operation
 type DownloadResult struct {
 	VideoID   string
@@ -70,6 +78,17 @@ type DownloadResult struct {
 	Duration  time.Duration
 }
 
+// StatusError is returned when a stream request is answered with an unexpected
+// HTTP status. A 403 Forbidden usually means the stream URL or the cookies it
+// was fetched with are no longer accepted.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
 // DownloadRequest contains all information needed to download a video
 type DownloadRequest struct {
 	VideoID              string
@@ -84,6 +103,10 @@ type DownloadRequest struct {
 	EpisodeNumber        int // Episode number based on upload date order (oldest = 1)
 	Streams              []Stream
 	AvailableResolutions []ResolutionOption
+	Chapters             []Chapter // embedded in the output file
+	Segments             []Segment // SponsorBlock segments, marked or removed according to Config.SegmentMode
+	Captions             []Caption // subtitle tracks; those in Config.SubtitleLangs are downloaded
+	Profile              *Profile  // quality profile of the channel; nil uses the Config alone
 }
 
 // Download downloads a video with retry logic and progress reporting
@@ -94,6 +117,23 @@ func (d *Downloader) Download(ctx context.Context, req *DownloadRequest) *Downlo
 		ChannelID: req.ChannelID,
 	}
 
+	// A run that was interrupted after downloading, e.g. while uploading, left a
+	// finished video behind; metadata.json is written last so it marks one
+	if filePath, fileSize := d.findDownloadedFile(req.ChannelID, req.VideoID); filePath != "" {
+		if _, err := os.Stat(filepath.Join(d.getVideoDir(req.ChannelID, req.VideoID), "metadata.json")); err == nil {
+			logging.Info("video already downloaded, skipping download",
+				"worker_id", d.workerID,
+				"video_id", req.VideoID,
+				"file_path", filePath,
+			)
+			result.Success = true
+			result.FilePath = filePath
+			result.FileSize = fileSize
+			result.Duration = time.Since(startTime)
+			return result
+		}
+	}
+
 	var lastErr error
 	for attempt := 1; attempt <= d.config.Retries; attempt++ {
 		result.Attempts = attempt
@@ -195,8 +235,13 @@ func (d *Downloader) downloadVideo(ctx context.Context, req *DownloadRequest) er
 		return fmt.Errorf("failed to create video directory: %w", err)
 	}
 
+	// Audio-only profiles keep the audio and nothing else
+	if req.Profile != nil && req.Profile.AudioOnly {
+		return d.downloadAudioOnlyVideo(ctx, req, videoDir)
+	}
+
 	// Select best stream
-	selector := NewStreamSelector(d.config.MaxHeight, d.config.PreferCombinedStream)
+	selector := d.streamSelector(req)
 	videoStream, audioStream, err := selector.SelectBestStream(req.Streams)
 	if err != nil {
 		return fmt.Errorf("failed to select stream: %w", err)
@@ -206,23 +251,32 @@ func (d *Downloader) downloadVideo(ctx context.Context, req *DownloadRequest) er
 		"video_id", req.VideoID,
 		"format_id", videoStream.FormatID,
 		"quality", QualityLabel(videoStream.Height),
+		"codec", videoStream.VCodec,
 		"type", videoStream.StreamType,
 		"has_audio_stream", audioStream != nil,
 	)
 
 	// Generate the final filename using channel name, episode number, and title
-	// Format: {channel}-ep{number}-{title}.{ext}
-	var finalFilename string
-	if req.ChannelName != "" && req.EpisodeNumber > 0 && req.Title != "" {
-		finalFilename = GenerateVideoFilename(req.ChannelName, req.EpisodeNumber, req.Title, "mp4")
-	} else {
-		// Fallback to video.mp4 if we don't have complete info
-		finalFilename = "video.mp4"
-	}
+	format := req.Profile.outputFormat(d.config)
+	finalFilename := finalVideoFilename(req, format)
 
 	// Report progress: starting
 	d.reportProgress(req.VideoID, "downloading", 0, 0, 0, "", "")
 
+	// Thumbnail and subtitles come first so they can be embedded when merging
+	d.downloadThumbnail(ctx, req, videoDir)
+	d.downloadCaptions(ctx, req)
+
+	// Chapters, segments and embedded metadata are applied while merging; until then the file has none
+	processOpts, _ := d.processingOptions(req)
+	if d.config.EmbedMetadata {
+		processOpts = append(processOpts, EmbedOptions(embedInfo(req), videoDir)...)
+	}
+	if req.Profile != nil && req.Profile.Transcode != nil {
+		processOpts = append(processOpts, WithTranscode(req.Profile.Transcode))
+	}
+	processed := false
+
 	// Download based on stream type
 	var videoPath string
 	if videoStream.IsSegmented {
@@ -258,10 +312,20 @@ func (d *Downloader) downloadVideo(ctx context.Context, req *DownloadRequest) er
 				// FFmpeg cannot read and write to the same file
 				useTempOutput := outputPath == videoPath
 				if useTempOutput {
-					outputPath = filepath.Join(videoDir, "merged_temp.mp4")
+					outputPath = filepath.Join(videoDir, "merged_temp."+format)
 				}
 
-				result := merger.MergeWithCodecCopy(ctx, videoPath, audioPath, outputPath)
+				result := merger.MergeWithCodecCopy(ctx, videoPath, audioPath, outputPath, processOpts...)
+				if result.Error != nil && len(processOpts) > 0 {
+					// Don't lose the audio over chapters or metadata; merge plainly instead
+					logging.Warn("failed to merge with chapters and metadata, merging without them",
+						"video_id", req.VideoID,
+						"error", result.Error,
+					)
+					result = merger.MergeWithCodecCopy(ctx, videoPath, audioPath, outputPath)
+				} else if result.Error == nil {
+					processed = len(processOpts) > 0
+				}
 				if result.Error != nil {
 					logging.Warn("failed to merge streams, keeping video only",
 						"video_id", req.VideoID,
@@ -294,29 +358,36 @@ func (d *Downloader) downloadVideo(ctx context.Context, req *DownloadRequest) er
 				}
 			}
 		}
-	} else if finalFilename != "video.mp4" && !videoStream.IsSegmented {
-		// If no merging needed, rename the video file to the final filename
-		newPath := filepath.Join(videoDir, finalFilename)
-		if err := os.Rename(videoPath, newPath); err == nil {
-			videoPath = newPath
+	} else if !videoStream.IsSegmented {
+		// If no merging needed, rename the video file to the final filename, keeping its container
+		newPath := filepath.Join(videoDir, strings.TrimSuffix(finalFilename, filepath.Ext(finalFilename))+filepath.Ext(videoPath))
+		if newPath != videoPath {
+			if err := os.Rename(videoPath, newPath); err == nil {
+				videoPath = newPath
+			}
 		}
 	}
 
-	// Download thumbnail
-	if d.config.WriteThumbnail && req.ThumbnailURL != "" {
-		thumbPath := filepath.Join(videoDir, "thumbnail.jpg")
-		if err := d.downloadFile(ctx, req.ThumbnailURL, thumbPath, nil); err != nil {
-			logging.Warn("failed to download thumbnail",
+	// A combined stream is not merged, so it needs a remux of its own
+	needsRemux := len(processOpts) > 0 || (format == "mkv" && !isMatroska(videoPath))
+	if !processed && needsRemux && audioStream == nil && MergerAvailable() {
+		d.reportProgress(req.VideoID, "processing", 95, 0, 0, "", "")
+		remuxedPath := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "." + format
+		if err := remuxInPlace(ctx, NewMerger(), videoPath, remuxedPath, processOpts); err != nil {
+			logging.Warn("failed to remux video, keeping it as downloaded",
 				"video_id", req.VideoID,
 				"error", err,
 			)
+		} else {
+			videoPath = remuxedPath
+			processed = len(processOpts) > 0
 		}
 	}
 
 	// Write metadata JSON
 	if d.config.WriteInfoJSON {
 		metadataPath := filepath.Join(videoDir, "metadata.json")
-		if err := d.writeMetadata(req, metadataPath, videoPath, videoStream); err != nil {
+		if err := d.writeMetadata(req, metadataPath, videoPath, videoStream, processed); err != nil {
 			logging.Warn("failed to write metadata",
 				"video_id", req.VideoID,
 				"error", err,
@@ -330,6 +401,75 @@ func (d *Downloader) downloadVideo(ctx context.Context, req *DownloadRequest) er
 	return nil
 }
 
+// streamSelector returns the stream selector for a request, from the Config
+// and the request's profile
+func (d *Downloader) streamSelector(req *DownloadRequest) *StreamSelector {
+	selector := NewStreamSelector(req.Profile.maxHeight(d.config.MaxHeight), d.config.PreferCombinedStream)
+	if req.Profile != nil {
+		selector.WithPreferredCodecs(req.Profile.Codecs).WithSizeLimit(req.Profile.MaxBytesPerMinute, req.Duration)
+	}
+	return selector
+}
+
+// finalVideoFilename returns the name a video is saved under:
+// {channel}-ep{number}-{title}.{ext}, or video.{ext} without complete info
+func finalVideoFilename(req *DownloadRequest, ext string) string {
+	if req.ChannelName != "" && req.EpisodeNumber > 0 && req.Title != "" {
+		return GenerateVideoFilename(req.ChannelName, req.EpisodeNumber, req.Title, ext)
+	}
+	return "video." + ext
+}
+
+// downloadAudioOnlyVideo downloads the best audio stream of a video for an
+// audio-only profile, M4A when YouTube offers it, along with the thumbnail,
+// subtitles and metadata. Nothing is embedded in the audio file.
+func (d *Downloader) downloadAudioOnlyVideo(ctx context.Context, req *DownloadRequest, videoDir string) error {
+	audioStream := NewStreamSelector(0, false).findBestAudio(req.Streams)
+	if audioStream == nil {
+		return fmt.Errorf("failed to select stream: no audio stream found")
+	}
+
+	logging.Info("selected audio stream",
+		"video_id", req.VideoID,
+		"format_id", audioStream.FormatID,
+		"codec", audioStream.ACodec,
+		"bitrate", audioStream.Bitrate,
+	)
+
+	d.reportProgress(req.VideoID, "downloading", 0, 0, 0, "", "")
+	d.downloadThumbnail(ctx, req, videoDir)
+	d.downloadCaptions(ctx, req)
+
+	var audioPath string
+	var err error
+	if audioStream.IsSegmented {
+		audioPath, err = d.downloadSegmentedStream(ctx, req.VideoID, audioStream, videoDir)
+	} else {
+		audioPath, err = d.downloadAudioStream(ctx, req.VideoID, audioStream, videoDir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download audio stream: %w", err)
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(audioPath), ".")
+	finalPath := filepath.Join(videoDir, finalVideoFilename(req, ext))
+	if err := os.Rename(audioPath, finalPath); err != nil {
+		return fmt.Errorf("failed to rename audio file: %w", err)
+	}
+
+	if d.config.WriteInfoJSON {
+		if err := d.writeMetadata(req, filepath.Join(videoDir, "metadata.json"), finalPath, audioStream, false); err != nil {
+			logging.Warn("failed to write metadata",
+				"video_id", req.VideoID,
+				"error", err,
+			)
+		}
+	}
+
+	d.reportProgress(req.VideoID, "completed", 100, 0, 0, "", "")
+	return nil
+}
+
 // downloadSingleStream downloads a non-segmented stream
 func (d *Downloader) downloadSingleStream(ctx context.Context, videoID string, stream *Stream, videoDir string) (string, error) {
 	filename := fmt.Sprintf("video.%s", stream.Extension)
@@ -337,7 +477,18 @@ func (d *Downloader) downloadSingleStream(ctx context.Context, videoID string, s
 		filename = fmt.Sprintf("audio.%s", stream.Extension)
 	}
 	outputPath := filepath.Join(videoDir, filename)
-	partPath := outputPath + ".part"
+	partPath := partFilePath(outputPath, stream.FormatID)
+
+	// An earlier attempt, possibly by another worker, may have finished this stream
+	if _, err := os.Stat(partPath); os.IsNotExist(err) {
+		if _, err := os.Stat(outputPath); err == nil {
+			logging.Info("stream already downloaded",
+				"video_id", videoID,
+				"path", outputPath,
+			)
+			return outputPath, nil
+		}
+	}
 
 	// Check if we have a partial download
 	var startByte int64 = 0
@@ -358,6 +509,16 @@ func (d *Downloader) downloadSingleStream(ctx context.Context, videoID string, s
 		}
 	}
 
+	// A partial file larger than the stream is not ours to resume
+	if totalSize > 0 && startByte > totalSize {
+		logging.Warn("partial download is larger than the stream, restarting",
+			"video_id", videoID,
+			"start_byte", startByte,
+			"total_size", totalSize,
+		)
+		startByte = 0
+	}
+
 	// Progress callback with speed calculation
 	var lastReportTime time.Time
 	var lastBytes int64
@@ -419,10 +580,12 @@ func (d *Downloader) downloadSingleStream(ctx context.Context, videoID string, s
 		d.reportProgress(videoID, "downloading", percentage, downloaded, total, FormatSpeed(avgSpeed), eta)
 	}
 
-	// Download with resume support
-	err := d.downloadFileWithResume(ctx, stream.URL, partPath, startByte, totalSize, progressCallback)
-	if err != nil {
-		return "", err
+	// Download with resume support, unless the partial file is already complete
+	if totalSize == 0 || startByte < totalSize {
+		err := d.downloadFileWithResume(ctx, stream.URL, partPath, startByte, totalSize, progressCallback)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	// Rename .part file to final name
@@ -433,6 +596,15 @@ func (d *Downloader) downloadSingleStream(ctx context.Context, videoID string, s
 	return outputPath, nil
 }
 
+// partFilePath returns the partial file of a stream download. It names the
+// format so a resumed download never appends to bytes of another format.
+func partFilePath(outputPath, formatID string) string {
+	if formatID == "" {
+		return outputPath + ".part"
+	}
+	return outputPath + ".f" + formatID + ".part"
+}
+
 // downloadSegmentedStream downloads a segmented (DASH/HLS) stream
 func (d *Downloader) downloadSegmentedStream(ctx context.Context, videoID string, stream *Stream, videoDir string) (string, error) {
 	if len(stream.SegmentURLs) == 0 {
@@ -445,18 +617,30 @@ func (d *Downloader) downloadSegmentedStream(ctx context.Context, videoID string
 	}
 	outputPath := filepath.Join(videoDir, filename)
 
-	// Create a temporary directory for segments
-	segmentDir := filepath.Join(videoDir, ".segments")
+	// Segments are kept until they are concatenated, so an interrupted download
+	// resumes from the first missing segment. Each stream gets its own directory.
+	segmentDir := filepath.Join(videoDir, ".segments", string(stream.StreamType)+"-"+stream.FormatID)
+
+	// The segment directory is removed only after concatenating, so an output
+	// file without one is complete
+	if _, err := os.Stat(segmentDir); os.IsNotExist(err) {
+		if _, err := os.Stat(outputPath); err == nil {
+			logging.Info("stream already downloaded",
+				"video_id", videoID,
+				"path", outputPath,
+			)
+			return outputPath, nil
+		}
+	}
 	if err := os.MkdirAll(segmentDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create segment directory: %w", err)
 	}
-	defer os.RemoveAll(segmentDir)
 
 	// Download init segment if present
 	var segmentPaths []string
 	if stream.InitURL != "" {
 		initPath := filepath.Join(segmentDir, "init.mp4")
-		if err := d.downloadFile(ctx, stream.InitURL, initPath, nil); err != nil {
+		if err := d.downloadSegment(ctx, stream.InitURL, initPath); err != nil {
 			return "", fmt.Errorf("failed to download init segment: %w", err)
 		}
 		segmentPaths = append(segmentPaths, initPath)
@@ -472,7 +656,7 @@ func (d *Downloader) downloadSegmentedStream(ctx context.Context, videoID string
 		}
 
 		segPath := filepath.Join(segmentDir, fmt.Sprintf("segment_%05d.ts", i))
-		if err := d.downloadFile(ctx, segURL, segPath, nil); err != nil {
+		if err := d.downloadSegment(ctx, segURL, segPath); err != nil {
 			return "", fmt.Errorf("failed to download segment %d: %w", i, err)
 		}
 		segmentPaths = append(segmentPaths, segPath)
@@ -496,10 +680,29 @@ func (d *Downloader) downloadSegmentedStream(ctx context.Context, videoID string
 			return "", fmt.Errorf("failed to concatenate segments: %w", err)
 		}
 	}
+	os.RemoveAll(segmentDir)
 
 	return outputPath, nil
 }
 
+// downloadSegment downloads one segment of a segmented stream, skipping it if an
+// earlier attempt already finished it. Segments are written to a .part file
+// first so a segment file on disk is always complete.
+func (d *Downloader) downloadSegment(ctx context.Context, url, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	partPath := path + ".part"
+	if err := d.downloadFile(ctx, url, partPath, nil); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return fmt.Errorf("failed to rename segment: %w", err)
+	}
+	return nil
+}
+
 // downloadFileWithResume downloads a file with resume support using Range headers
 func (d *Downloader) downloadFileWithResume(ctx context.Context, url, outputPath string, startByte, totalSize int64, progressCallback func(downloaded, total int64)) error {
 	// Open file for writing (append if resuming)
@@ -548,6 +751,12 @@ func (d *Downloader) downloadFileWithResume(ctx context.Context, url, outputPath
 		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startByte))
 	}
 
+	if d.rateLimiter != nil {
+		if err := d.rateLimiter.Wait(ctx, ratelimit.BudgetMedia); err != nil {
+			return fmt.Errorf("rate limit wait cancelled: %w", err)
+		}
+	}
+
 	// Execute request
 	resp, err := d.httpClient.Do(req)
 	if err != nil {
@@ -555,9 +764,33 @@ func (d *Downloader) downloadFileWithResume(ctx context.Context, url, outputPath
 	}
 	defer resp.Body.Close()
 
+	// Slow every worker down, not just this one
+	if resp.StatusCode == http.StatusTooManyRequests && d.rateLimiter != nil {
+		d.rateLimiter.Backoff(ctx, ratelimit.BudgetMedia)
+	}
+
+	// The partial file already holds the whole stream
+	if startByte > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil
+	}
+
 	// Check response status
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	// The server ignored the Range header and sent the whole stream; start over
+	if startByte > 0 && resp.StatusCode == http.StatusOK {
+		logging.Warn("server does not support resuming, restarting download",
+			"worker_id", d.workerID,
+			"path", outputPath,
+			"start_byte", startByte,
+		)
+		if err := file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate output file: %w", err)
+		}
+		startByte = 0
+		totalSize = 0
 	}
 
 	// Update total size if we didn't have it
@@ -619,8 +852,75 @@ func (d *Downloader) concatenateFiles(inputPaths []string, outputPath string) er
 	return nil
 }
 
-// writeMetadata writes video metadata to a JSON file
-func (d *Downloader) writeMetadata(req *DownloadRequest, path string, videoPath string, selectedStream *Stream) error {
+// processingOptions returns the merge options that embed the request's chapters
+// and apply its SponsorBlock segments, along with the chapters the output file ends up with
+func (d *Downloader) processingOptions(req *DownloadRequest) ([]MergeOption, []Chapter) {
+	if len(req.Segments) == 0 {
+		if len(req.Chapters) == 0 {
+			return nil, nil
+		}
+		return []MergeOption{WithChapters(req.Chapters)}, req.Chapters
+	}
+
+	if d.config.SegmentMode == SegmentModeRemove {
+		opts := []MergeOption{WithRemovedSegments(req.Segments)}
+		if len(req.Chapters) > 0 {
+			opts = append(opts, WithChapters(req.Chapters))
+		}
+		return opts, CutChapters(req.Chapters, req.Segments)
+	}
+
+	chapters := MarkSegments(req.Chapters, req.Segments, float64(req.Duration))
+	return []MergeOption{WithChapters(chapters)}, chapters
+}
+
+// downloadThumbnail downloads the video's thumbnail into videoDir, if enabled
+func (d *Downloader) downloadThumbnail(ctx context.Context, req *DownloadRequest, videoDir string) {
+	if !d.config.WriteThumbnail || req.ThumbnailURL == "" {
+		return
+	}
+
+	thumbPath := filepath.Join(videoDir, "thumbnail.jpg")
+	if err := d.downloadFile(ctx, req.ThumbnailURL, thumbPath, nil); err != nil {
+		logging.Warn("failed to download thumbnail",
+			"video_id", req.VideoID,
+			"error", err,
+		)
+	}
+}
+
+// downloadCaptions downloads the subtitle tracks in the configured languages, if enabled
+func (d *Downloader) downloadCaptions(ctx context.Context, req *DownloadRequest) {
+	if !d.config.WriteSubtitles || len(req.Captions) == 0 {
+		return
+	}
+
+	captions := SelectCaptions(req.Captions, d.config.SubtitleLangs)
+	if len(captions) == 0 {
+		return
+	}
+	if err := d.DownloadSubtitles(ctx, req.ChannelID, req.VideoID, captions); err != nil {
+		logging.Warn("failed to download subtitles",
+			"video_id", req.VideoID,
+			"error", err,
+		)
+	}
+}
+
+// embedInfo returns the metadata of a request that is embedded in the video file
+func embedInfo(req *DownloadRequest) EmbedInfo {
+	return EmbedInfo{
+		VideoID:     req.VideoID,
+		Title:       req.Title,
+		Channel:     req.ChannelName,
+		Description: req.Description,
+		UploadDate:  req.UploadDate,
+	}
+}
+
+// writeMetadata writes video metadata to a JSON file. processed reports whether
+// the request's chapters and segments were applied to the video file.
+func (d *Downloader) writeMetadata(req *DownloadRequest, path string, videoPath string, selectedStream *Stream, processed bool) error {
 	var fileSize int64
 	if info, err := os.Stat(videoPath); err == nil {
 		fileSize = info.Size()
@@ -661,6 +961,30 @@ func (d *Downloader) writeMetadata(req *DownloadRequest, path string, videoPath
 		"available_resolutions": availableResolutions,
 		"selected_resolution":   selectedResolution,
 	}
+	if req.Profile != nil {
+		metadata["profile"] = req.Profile.Name
+		if processed && req.Profile.Transcode != nil {
+			metadata["transcode"] = req.Profile.Transcode
+		}
+	}
+
+	// Chapters are stored as they are in the video file
+	chapters := req.Chapters
+	if processed {
+		_, chapters = d.processingOptions(req)
+	}
+	if len(chapters) > 0 {
+		metadata["chapters"] = chapters
+	}
+	if len(req.Segments) > 0 {
+		mode := d.config.SegmentMode
+		if mode == "" {
+			mode = SegmentModeMark
+		}
+		metadata["sponsor_segments"] = req.Segments
+		metadata["sponsor_segment_mode"] = mode
+		metadata["sponsor_segments_applied"] = processed
+	}
 
 	data, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
@@ -696,7 +1020,8 @@ func (d *Downloader) findDownloadedFile(channelID, videoID string) (string, int6
 
 	// Look for the main video file - check for new naming format first (*.mp4)
 	// then fall back to legacy naming (video.mp4, video.mkv, etc.)
-	patterns := []string{"*-ep*-*.mp4", "*-ep*-*.mkv", "*-ep*-*.webm", "video.mp4", "video.mkv", "video.webm", "*.mp4", "*.mkv", "*.webm"}
+	patterns := []string{"*-ep*-*.mp4", "*-ep*-*.mkv", "*-ep*-*.webm", "video.mp4", "video.mkv", "video.webm", "*.mp4", "*.mkv", "*.webm",
+		"*-ep*-*.m4a", "video.m4a"} // audio-only profiles
 
 	for _, pattern := range patterns {
 		matches, err := filepath.Glob(filepath.Join(videoDir, pattern))
@@ -897,7 +1222,7 @@ func (d *Downloader) downloadAudioStream(ctx context.Context, videoID string, st
 		ext = "m4a"
 	}
 	outputPath := filepath.Join(videoDir, fmt.Sprintf("audio.%s", ext))
-	partPath := outputPath + ".part"
+	partPath := partFilePath(outputPath, stream.FormatID)
 
 	// Check if we have a partial download
 	var startByte int64 = 0
@@ -666,6 +666,16 @@ func TestConfigValidate(t *testing.T) {
 			},
 			hasError: true,
 		},
+		{
+			name: "unknown segment mode",
+			config: &Config{
+				OutputPath:  "/data",
+				MaxHeight:   1080,
+				Retries:     3,
+				SegmentMode: "skip",
+			},
+			hasError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -811,3 +821,170 @@ func TestGetAvailableResolutions(t *testing.T) {
 		t.Errorf("expected 720p to have bitrate 3000000, got %d", resolutions[1].Bitrate)
 	}
 }
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"mp4", "mp4"},
+		{"mkv", "mkv"},
+		{"MKV", "mkv"},
+		{"webm", "mp4"},
+		{"", "mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			config := &Config{MergeOutputFormat: tt.format}
+			if got := config.OutputFormat(); got != tt.want {
+				t.Errorf("OutputFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloader_downloadFileWithResume_RangeIgnored(t *testing.T) {
+	// A server that ignores Range headers and always sends the whole file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "13")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Hello, World!"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d := NewDownloader(DefaultConfig(tempDir), nil, "test-worker")
+
+	outputPath := filepath.Join(tempDir, "test.txt")
+	if err := os.WriteFile(outputPath, []byte("Hello"), 0644); err != nil {
+		t.Fatalf("failed to write partial file: %v", err)
+	}
+
+	if err := d.downloadFileWithResume(context.Background(), server.URL, outputPath, 5, 13, nil); err != nil {
+		t.Fatalf("resume download failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "Hello, World!" {
+		t.Errorf("content = %q, want the file downloaded from the start", string(content))
+	}
+}
+
+func TestDownloader_downloadFileWithResume_AlreadyComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d := NewDownloader(DefaultConfig(tempDir), nil, "test-worker")
+
+	outputPath := filepath.Join(tempDir, "test.txt")
+	if err := os.WriteFile(outputPath, []byte("Hello, World!"), 0644); err != nil {
+		t.Fatalf("failed to write partial file: %v", err)
+	}
+
+	if err := d.downloadFileWithResume(context.Background(), server.URL, outputPath, 13, 0, nil); err != nil {
+		t.Fatalf("resume of a complete file failed: %v", err)
+	}
+	content, _ := os.ReadFile(outputPath)
+	if string(content) != "Hello, World!" {
+		t.Errorf("content = %q, want it unchanged", string(content))
+	}
+}
+
+func TestDownloader_downloadSingleStream_Resume(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Range", "bytes 5-12/13")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(", World!"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d := NewDownloader(DefaultConfig(tempDir), nil, "test-worker")
+	stream := &Stream{FormatID: "22", URL: server.URL, Extension: "mp4", StreamType: StreamTypeCombined, ContentLength: 13}
+
+	// A partial file of another format must not be resumed
+	if err := os.WriteFile(filepath.Join(tempDir, "video.mp4.f18.part"), []byte("other"), 0644); err != nil {
+		t.Fatalf("failed to write partial file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "video.mp4.f22.part"), []byte("Hello"), 0644); err != nil {
+		t.Fatalf("failed to write partial file: %v", err)
+	}
+
+	path, err := d.downloadSingleStream(context.Background(), "vid", stream, tempDir)
+	if err != nil {
+		t.Fatalf("downloadSingleStream() error = %v", err)
+	}
+	if path != filepath.Join(tempDir, "video.mp4") {
+		t.Errorf("path = %s", path)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "Hello, World!" {
+		t.Errorf("content = %q, want %q", string(content), "Hello, World!")
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=5-" {
+		t.Errorf("Range headers = %v, want [bytes=5-]", ranges)
+	}
+
+	// The finished stream is reused without another request
+	if _, err := d.downloadSingleStream(context.Background(), "vid", stream, tempDir); err != nil {
+		t.Fatalf("downloadSingleStream() error = %v", err)
+	}
+	if len(ranges) != 1 {
+		t.Errorf("finished stream was downloaded again")
+	}
+}
+
+func TestDownloader_downloadSegment(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("segment"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d := NewDownloader(DefaultConfig(tempDir), nil, "test-worker")
+	path := filepath.Join(tempDir, "segment_00000.ts")
+
+	for i := 0; i < 2; i++ {
+		if err := d.downloadSegment(context.Background(), server.URL, path); err != nil {
+			t.Fatalf("downloadSegment() error = %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want an existing segment to be skipped", requests)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("partial segment file left behind")
+	}
+}
+
+func TestDownload_AlreadyDownloaded(t *testing.T) {
+	tempDir := t.TempDir()
+	d := NewDownloader(DefaultConfig(tempDir), nil, "test-worker")
+
+	videoDir := d.getVideoDir("chan", "vid")
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		t.Fatalf("failed to create video dir: %v", err)
+	}
+	os.WriteFile(filepath.Join(videoDir, "Channel-ep1-Title.mp4"), []byte("video"), 0644)
+	os.WriteFile(filepath.Join(videoDir, "metadata.json"), []byte("{}"), 0644)
+
+	// No streams: this would fail if it tried to download
+	result := d.Download(context.Background(), &DownloadRequest{VideoID: "vid", ChannelID: "chan"})
+	if !result.Success {
+		t.Fatalf("Download() error = %v, want the finished video to be reused", result.Error)
+	}
+	if result.FileSize != 5 || filepath.Base(result.FilePath) != "Channel-ep1-Title.mp4" {
+		t.Errorf("result = %s (%d bytes)", result.FilePath, result.FileSize)
+	}
+}
@@ -0,0 +1,303 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EmbedInfo is the descriptive metadata written into a video container
+type EmbedInfo struct {
+	VideoID     string
+	Title       string
+	Channel     string
+	Description string
+	UploadDate  string // YYYYMMDD or YYYY-MM-DD
+}
+
+// Subtitle is a subtitle file to embed as a soft subtitle track
+type Subtitle struct {
+	Path     string
+	Language string // language code, e.g. "en" or "pt-BR"
+}
+
+// Caption is a subtitle track offered by YouTube
+type Caption struct {
+	Language  string
+	URL       string
+	Automatic bool // generated by speech recognition
+}
+
+// WithMetadata writes title, channel, date and description tags to the output file
+func WithMetadata(info EmbedInfo) MergeOption {
+	return func(o *mergeOptions) {
+		o.tags = info.tags()
+	}
+}
+
+// WithCoverArt embeds an image as the output file's cover art
+func WithCoverArt(path string) MergeOption {
+	return func(o *mergeOptions) {
+		o.coverArt = path
+	}
+}
+
+// WithSubtitles embeds subtitle files as soft subtitle tracks
+func WithSubtitles(subtitles []Subtitle) MergeOption {
+	return func(o *mergeOptions) {
+		o.subtitles = subtitles
+	}
+}
+
+// tags returns the container tags for the metadata. The date is written as
+// YYYY-MM-DD, which is what Jellyfin and Plex read.
+func (info EmbedInfo) tags() map[string]string {
+	tags := make(map[string]string)
+	if info.Title != "" {
+		tags["title"] = info.Title
+	}
+	if info.Channel != "" {
+		tags["artist"] = info.Channel
+		tags["album_artist"] = info.Channel
+	}
+	if info.Description != "" {
+		tags["description"] = info.Description
+		tags["synopsis"] = info.Description
+	}
+	if date := strings.ReplaceAll(info.UploadDate, "-", ""); len(date) == 8 {
+		tags["date"] = date[:4] + "-" + date[4:6] + "-" + date[6:]
+	}
+	if info.VideoID != "" {
+		tags["comment"] = "https://www.youtube.com/watch?v=" + info.VideoID
+	}
+	return tags
+}
+
+// tagArgs returns the ffmpeg arguments that set tags, in a stable order
+func tagArgs(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, "-metadata", key+"="+tags[key])
+	}
+	return args
+}
+
+// isMatroska reports whether a path is written as Matroska rather than MP4
+func isMatroska(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mkv")
+}
+
+// imageMimeType returns the MIME type of a cover art image
+func imageMimeType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// iso639 maps two-letter language codes to the three-letter codes MP4 requires
+var iso639 = map[string]string{
+	"ar": "ara", "bn": "ben", "cs": "ces", "da": "dan", "de": "deu",
+	"el": "ell", "en": "eng", "es": "spa", "fi": "fin", "fr": "fra",
+	"he": "heb", "hi": "hin", "hu": "hun", "id": "ind", "it": "ita",
+	"ja": "jpn", "ko": "kor", "nl": "nld", "no": "nor", "pl": "pol",
+	"pt": "por", "ro": "ron", "ru": "rus", "sv": "swe", "th": "tha",
+	"tr": "tur", "uk": "ukr", "vi": "vie", "zh": "zho",
+}
+
+// subtitleLanguage converts a YouTube language code such as "pt-BR" to an ISO 639-2 code
+func subtitleLanguage(code string) string {
+	base := strings.ToLower(strings.SplitN(strings.SplitN(code, "-", 2)[0], "_", 2)[0])
+	if language, ok := iso639[base]; ok {
+		return language
+	}
+	if len(base) == 3 {
+		return base
+	}
+	return ""
+}
+
+// SelectCaptions picks one caption track per language in langs, preferring
+// manually written subtitles over automatic ones. The result maps language to URL.
+func SelectCaptions(captions []Caption, langs []string) map[string]string {
+	selected := make(map[string]string)
+	for _, lang := range langs {
+		lang = strings.TrimSpace(lang)
+		var automatic string
+		for _, caption := range captions {
+			if caption.Language != lang || caption.URL == "" {
+				continue
+			}
+			if !caption.Automatic {
+				selected[lang] = caption.URL
+				break
+			}
+			if automatic == "" {
+				automatic = caption.URL
+			}
+		}
+		if _, ok := selected[lang]; !ok && automatic != "" {
+			selected[lang] = automatic
+		}
+	}
+	return selected
+}
+
+// FindSubtitles returns the subtitle files DownloadSubtitles wrote to a video directory
+func FindSubtitles(videoDir string) []Subtitle {
+	matches, _ := filepath.Glob(filepath.Join(videoDir, "subtitles*.vtt"))
+	sort.Strings(matches)
+
+	var subtitles []Subtitle
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), ".vtt")
+		language := "en" // subtitles.vtt holds the primary language
+		if name != "subtitles" {
+			language = strings.TrimPrefix(name, "subtitles.")
+		}
+		subtitles = append(subtitles, Subtitle{Path: path, Language: language})
+	}
+	return subtitles
+}
+
+// FindThumbnail returns the thumbnail image in a video directory, or "" if there is none
+func FindThumbnail(videoDir string) string {
+	for _, name := range []string{"thumbnail.jpg", "thumbnail.png", "thumbnail.webp"} {
+		path := filepath.Join(videoDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// EmbedOptions returns the merge options that embed info together with the
+// thumbnail and subtitle files found in videoDir
+func EmbedOptions(info EmbedInfo, videoDir string) []MergeOption {
+	opts := []MergeOption{WithMetadata(info)}
+	if thumbnail := FindThumbnail(videoDir); thumbnail != "" {
+		opts = append(opts, WithCoverArt(thumbnail))
+	}
+	if subtitles := FindSubtitles(videoDir); len(subtitles) > 0 {
+		opts = append(opts, WithSubtitles(subtitles))
+	}
+	return opts
+}
+
+// archivedMetadata is the part of metadata.json needed to embed an archived video
+type archivedMetadata struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	UploadDate  string    `json:"upload_date"`
+	ChannelName string    `json:"channel_name"`
+	Chapters    []Chapter `json:"chapters"`
+}
+
+// LoadEmbedInfo reads the metadata and chapters of an archived video from its metadata.json
+func LoadEmbedInfo(path string) (EmbedInfo, []Chapter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EmbedInfo{}, nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return ParseEmbedInfo(data)
+}
+
+// ParseEmbedInfo parses the metadata and chapters of a video from the contents of its metadata.json
+func ParseEmbedInfo(data []byte) (EmbedInfo, []Chapter, error) {
+	var metadata archivedMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return EmbedInfo{}, nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	info := EmbedInfo{
+		VideoID:     metadata.ID,
+		Title:       metadata.Title,
+		Channel:     metadata.ChannelName,
+		Description: metadata.Description,
+		UploadDate:  metadata.UploadDate,
+	}
+	return info, metadata.Chapters, nil
+}
+
+// FindVideoFile returns the video file in an archived video directory, or "" if there is none.
+// Partial downloads and leftovers from interrupted merges are ignored.
+func FindVideoFile(videoDir string) string {
+	entries, err := os.ReadDir(videoDir)
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.Contains(name, "_temp.") {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".mp4", ".mkv", ".webm":
+			return filepath.Join(videoDir, name)
+		}
+	}
+	return ""
+}
+
+// remuxInPlace remuxes videoPath with opts into outputPath by way of a temporary
+// file, so videoPath and outputPath may be the same. videoPath is removed if they differ.
+func remuxInPlace(ctx context.Context, m *Merger, videoPath, outputPath string, opts []MergeOption) error {
+	tempPath := filepath.Join(filepath.Dir(outputPath), "processed_temp"+filepath.Ext(outputPath))
+	result := m.Remux(ctx, videoPath, tempPath, opts...)
+	if result.Error != nil {
+		os.Remove(tempPath)
+		return result.Error
+	}
+
+	if err := os.Rename(tempPath, outputPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename processed file: %w", err)
+	}
+	if outputPath != videoPath {
+		os.Remove(videoPath)
+	}
+	return nil
+}
+
+// EmbedArchived rewrites the video in an archived video directory with the
+// metadata, chapters, thumbnail and subtitles stored next to it, keeping its
+// container. It returns the path of the video.
+func EmbedArchived(ctx context.Context, m *Merger, videoDir string) (string, error) {
+	videoPath := FindVideoFile(videoDir)
+	if videoPath == "" {
+		return "", fmt.Errorf("no video file in %s", videoDir)
+	}
+	if strings.EqualFold(filepath.Ext(videoPath), ".webm") {
+		return videoPath, fmt.Errorf("cannot embed into WebM: %s", videoPath)
+	}
+
+	info, chapters, err := LoadEmbedInfo(filepath.Join(videoDir, "metadata.json"))
+	if err != nil {
+		return videoPath, err
+	}
+
+	opts := EmbedOptions(info, videoDir)
+	if len(chapters) > 0 {
+		opts = append(opts, WithChapters(chapters))
+	}
+	if err := remuxInPlace(ctx, m, videoPath, videoPath, opts); err != nil {
+		return videoPath, err
+	}
+	return videoPath, nil
+}
@@ -0,0 +1,209 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmbedInfoTags(t *testing.T) {
+	info := EmbedInfo{
+		VideoID:     "abc123",
+		Title:       "A video",
+		Channel:     "A channel",
+		Description: "About the video",
+		UploadDate:  "20240131",
+	}
+
+	want := map[string]string{
+		"title":        "A video",
+		"artist":       "A channel",
+		"album_artist": "A channel",
+		"description":  "About the video",
+		"synopsis":     "About the video",
+		"date":         "2024-01-31",
+		"comment":      "https://www.youtube.com/watch?v=abc123",
+	}
+	if got := info.tags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("tags() = %v, want %v", got, want)
+	}
+
+	if got := (EmbedInfo{UploadDate: "2024-01-31"}).tags()["date"]; got != "2024-01-31" {
+		t.Errorf("tags() date = %q, want %q", got, "2024-01-31")
+	}
+	if got := (EmbedInfo{UploadDate: "2024"}).tags(); len(got) != 0 {
+		t.Errorf("tags() = %v, want no tags", got)
+	}
+}
+
+func TestSubtitleLanguage(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"en", "eng"},
+		{"pt-BR", "por"},
+		{"zh_Hans", "zho"},
+		{"fil", "fil"},
+		{"xx", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := subtitleLanguage(tt.code); got != tt.want {
+				t.Errorf("subtitleLanguage(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectCaptions(t *testing.T) {
+	captions := []Caption{
+		{Language: "en", URL: "en-auto", Automatic: true},
+		{Language: "en", URL: "en-manual"},
+		{Language: "de", URL: "de-auto", Automatic: true},
+		{Language: "fr", URL: ""},
+	}
+
+	want := map[string]string{
+		"en": "en-manual",
+		"de": "de-auto",
+	}
+	if got := SelectCaptions(captions, []string{"en", " de", "fr", "es"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectCaptions() = %v, want %v", got, want)
+	}
+}
+
+func TestFindSubtitlesAndThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"subtitles.vtt", "subtitles.de.vtt", "thumbnail.png", "video.mp4"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []Subtitle{
+		{Path: filepath.Join(dir, "subtitles.de.vtt"), Language: "de"},
+		{Path: filepath.Join(dir, "subtitles.vtt"), Language: "en"},
+	}
+	if got := FindSubtitles(dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSubtitles() = %+v, want %+v", got, want)
+	}
+
+	if got := FindThumbnail(dir); got != filepath.Join(dir, "thumbnail.png") {
+		t.Errorf("FindThumbnail() = %q", got)
+	}
+	if got := FindThumbnail(t.TempDir()); got != "" {
+		t.Errorf("FindThumbnail() of an empty directory = %q, want empty", got)
+	}
+}
+
+func TestFindVideoFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"merged_temp.mp4", "thumbnail.jpg", "video.mkv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := FindVideoFile(dir); got != filepath.Join(dir, "video.mkv") {
+		t.Errorf("FindVideoFile() = %q, want video.mkv", got)
+	}
+	if got := FindVideoFile(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("FindVideoFile() of a missing directory = %q, want empty", got)
+	}
+}
+
+func TestLoadEmbedInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+	data := `{
+		"id": "abc123",
+		"title": "A video",
+		"channel_name": "A channel",
+		"upload_date": "20240131",
+		"chapters": [{"title": "Intro", "start_time": 0, "end_time": 30}]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, chapters, err := LoadEmbedInfo(path)
+	if err != nil {
+		t.Fatalf("LoadEmbedInfo() error = %v", err)
+	}
+	if info.VideoID != "abc123" || info.Channel != "A channel" || info.UploadDate != "20240131" {
+		t.Errorf("LoadEmbedInfo() info = %+v", info)
+	}
+	if want := []Chapter{{Title: "Intro", Start: 0, End: 30}}; !reflect.DeepEqual(chapters, want) {
+		t.Errorf("LoadEmbedInfo() chapters = %+v, want %+v", chapters, want)
+	}
+
+	if _, _, err := LoadEmbedInfo(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadEmbedInfo() of a missing file expected error")
+	}
+}
+
+func TestBuildProcessingArgsEmbed(t *testing.T) {
+	opts := []MergeOption{
+		WithMetadata(EmbedInfo{Title: "A video", Channel: "A channel"}),
+		WithCoverArt("thumbnail.jpg"),
+		WithSubtitles([]Subtitle{
+			{Path: "subtitles.vtt", Language: "en"},
+			{Path: "subtitles.de.vtt", Language: "de"},
+		}),
+	}
+
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "mp4",
+			output: "out.mp4",
+			want: []string{
+				"-i v.mp4 -i a.m4a -f ffmetadata -i meta.txt -i thumbnail.jpg -i subtitles.vtt -i subtitles.de.vtt",
+				"-map 0:v:0 -map 1:a:0?",
+				"-map 3:v:0 -c:v:1 mjpeg -disposition:v:1 attached_pic",
+				"-map 4:s:0 -metadata:s:s:0 language=eng -map 5:s:0 -metadata:s:s:1 language=deu -c:s mov_text",
+				"-map_metadata 2 -map_chapters 2",
+				"-metadata artist=A channel",
+				"-metadata title=A video",
+				"-movflags +faststart",
+			},
+			notWant: []string{"-attach"},
+		},
+		{
+			name:   "mkv",
+			output: "out.mkv",
+			want: []string{
+				"-i v.mp4 -i a.m4a -f ffmetadata -i meta.txt -i subtitles.vtt -i subtitles.de.vtt",
+				"-map 3:s:0",
+				"-c:s srt",
+				"-attach thumbnail.jpg -metadata:s:t mimetype=image/jpeg -metadata:s:t filename=cover.jpg",
+			},
+			notWant: []string{"attached_pic", "-movflags"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := buildProcessingArgs([]string{"v.mp4", "a.m4a"}, tt.output, "meta.txt", newMergeOptions(opts), "-c", "copy")
+			joined := strings.Join(args, " ")
+
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("args %q missing %q", joined, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(joined, notWant) {
+					t.Errorf("args %q should not contain %q", joined, notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// HLSSegmentSeconds is the length of the segments SegmentHLS cuts
+const HLSSegmentSeconds = 6
+
+// HLSRendition is one quality level of a video packaged for HLS
+type HLSRendition struct {
+	Name         string // directory the rendition is written to, e.g. "720p"
+	Height       int    // frame height; the width keeps the aspect ratio
+	VideoBitrate int    // kbit/s
+	AudioBitrate int    // kbit/s
+}
+
+// Bandwidth returns the peak bits per second of the rendition, as an HLS
+// master playlist advertises it
+func (r HLSRendition) Bandwidth() int {
+	return (r.maxVideoBitrate() + r.AudioBitrate) * 1000
+}
+
+// maxVideoBitrate allows the encoder some headroom over the target bitrate
+func (r HLSRendition) maxVideoBitrate() int {
+	return r.VideoBitrate * 107 / 100
+}
+
+// SegmentHLS transcodes a video to one HLS rendition: H.264 and AAC in
+// MPEG-TS segments next to playlistPath. The playlist is rewritten as each
+// segment completes, so it can be played while the rest is being encoded;
+// it ends with #EXT-X-ENDLIST once the whole video is packaged.
+func (m *Merger) SegmentHLS(ctx context.Context, inputPath, playlistPath string, rendition HLSRendition) *MergeResult {
+	startTime := time.Now()
+	result := &MergeResult{
+		OutputPath: playlistPath,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if err := os.MkdirAll(filepath.Dir(playlistPath), 0755); err != nil {
+		result.Error = fmt.Errorf("failed to create rendition directory: %w", err)
+		return result
+	}
+
+	cmd := exec.CommandContext(ctx, m.ffmpegPath, buildHLSArgs(inputPath, playlistPath, rendition)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		result.Error = fmt.Errorf("ffmpeg hls segmenting failed: %w - output: %s", err, string(output))
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	segments, err := filepath.Glob(filepath.Join(filepath.Dir(playlistPath), "segment_*.ts"))
+	if err != nil || len(segments) == 0 {
+		result.Error = fmt.Errorf("ffmpeg wrote no hls segments")
+		result.Duration = time.Since(startTime)
+		return result
+	}
+	for _, segment := range segments {
+		if info, err := os.Stat(segment); err == nil {
+			result.FileSize += info.Size()
+		}
+	}
+
+	result.Success = true
+	result.Duration = time.Since(startTime)
+	return result
+}
+
+// buildHLSArgs constructs the ffmpeg arguments that package inputPath as a
+// rendition. Keyframes are forced on segment boundaries so every rendition
+// switches at the same points.
+func buildHLSArgs(inputPath, playlistPath string, rendition HLSRendition) []string {
+	kbps := func(rate int) string { return strconv.Itoa(rate) + "k" }
+
+	return []string{
+		"-y",
+		"-i", inputPath,
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:%d", rendition.Height),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "main",
+		"-pix_fmt", "yuv420p",
+		"-b:v", kbps(rendition.VideoBitrate),
+		"-maxrate", kbps(rendition.maxVideoBitrate()),
+		"-bufsize", kbps(rendition.VideoBitrate * 3 / 2),
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", HLSSegmentSeconds),
+		"-c:a", "aac",
+		"-b:a", kbps(rendition.AudioBitrate),
+		"-ac", "2",
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(HLSSegmentSeconds),
+		"-hls_playlist_type", "event",
+		"-hls_flags", "independent_segments+temp_file",
+		"-hls_segment_filename", filepath.Join(filepath.Dir(playlistPath), "segment_%05d.ts"),
+		playlistPath,
+	}
+}
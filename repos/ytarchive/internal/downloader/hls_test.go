@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildHLSArgs(t *testing.T) {
+	rendition := HLSRendition{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128}
+	playlist := filepath.Join("hls", "720p", "index.m3u8")
+
+	want := []string{
+		"-y",
+		"-i", "video.mkv",
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-vf", "scale=-2:720",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "main",
+		"-pix_fmt", "yuv420p",
+		"-b:v", "2800k",
+		"-maxrate", "2996k",
+		"-bufsize", "4200k",
+		"-force_key_frames", "expr:gte(t,n_forced*6)",
+		"-c:a", "aac",
+		"-b:a", "128k",
+		"-ac", "2",
+		"-f", "hls",
+		"-hls_time", "6",
+		"-hls_playlist_type", "event",
+		"-hls_flags", "independent_segments+temp_file",
+		"-hls_segment_filename", filepath.Join("hls", "720p", "segment_%05d.ts"),
+		playlist,
+	}
+	if got := buildHLSArgs("video.mkv", playlist, rendition); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildHLSArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestHLSRendition_Bandwidth(t *testing.T) {
+	rendition := HLSRendition{VideoBitrate: 2800, AudioBitrate: 128}
+	if got := rendition.Bandwidth(); got != 3124000 {
+		t.Errorf("Bandwidth() = %d, want 3124000", got)
+	}
+}
+
+func TestMerger_SegmentHLS_InvalidInput(t *testing.T) {
+	if !MergerAvailable() {
+		t.Skip("ffmpeg not available")
+	}
+
+	m := NewMerger()
+	playlist := filepath.Join(t.TempDir(), "720p", "index.m3u8")
+	result := m.SegmentHLS(context.Background(), "/nonexistent/video.mp4", playlist, HLSRendition{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128})
+	if result.Success {
+		t.Error("expected SegmentHLS to fail with a nonexistent input")
+	}
+	if result.Error == nil {
+		t.Error("expected an error")
+	}
+}
@@ -63,8 +63,77 @@ type MergeResult struct {
 	Error      error
 }
 
+// mergeOptions holds the optional processing applied while merging
+type mergeOptions struct {
+	chapters  []Chapter
+	removed   []Segment
+	tags      map[string]string
+	coverArt  string
+	subtitles []Subtitle
+	transcode *Transcode
+}
+
+// MergeOption configures a single merge operation
+type MergeOption func(*mergeOptions)
+
+// WithChapters embeds chapters in the output file. Chapter times refer to the
+// input; they are adjusted for any segments removed with WithRemovedSegments.
+func WithChapters(chapters []Chapter) MergeOption {
+	return func(o *mergeOptions) {
+		o.chapters = chapters
+	}
+}
+
+// WithRemovedSegments cuts segments out of the output. This re-encodes the
+// video, so it is much slower than a plain merge.
+func WithRemovedSegments(segments []Segment) MergeOption {
+	return func(o *mergeOptions) {
+		o.removed = segments
+	}
+}
+
+// WithTranscode re-encodes the video and audio of the output. This is much
+// slower than a plain merge.
+func WithTranscode(t *Transcode) MergeOption {
+	return func(o *mergeOptions) {
+		o.transcode = t
+	}
+}
+
+// newMergeOptions applies opts, adjusting chapters for removed segments
+func newMergeOptions(opts []MergeOption) *mergeOptions {
+	o := &mergeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.removed = mergeSegments(o.removed)
+	o.chapters = CutChapters(o.chapters, o.removed)
+	return o
+}
+
+// writeChapterMetadata writes the chapters to an ffmpeg metadata file next to
+// outputPath and returns its path, or "" if there are no chapters.
+// The last chapter ends at the end of videoPath unless it has an end time.
+func writeChapterMetadata(o *mergeOptions, videoPath, outputPath string) (string, error) {
+	if len(o.chapters) == 0 {
+		return "", nil
+	}
+
+	var duration float64
+	if last := o.chapters[len(o.chapters)-1]; last.End <= last.Start {
+		duration, _ = GetMediaDuration(videoPath)
+		duration = shiftTime(duration, o.removed)
+	}
+
+	path := outputPath + ".ffmetadata"
+	if err := writeFFMetadata(path, o.chapters, duration); err != nil {
+		return "", fmt.Errorf("failed to write chapter metadata: %w", err)
+	}
+	return path, nil
+}
+
 // Merge combines video and audio files into a single output file
-func (m *Merger) Merge(ctx context.Context, videoPath, audioPath, outputPath string) *MergeResult {
+func (m *Merger) Merge(ctx context.Context, videoPath, audioPath, outputPath string, opts ...MergeOption) *MergeResult {
 	startTime := time.Now()
 	result := &MergeResult{
 		OutputPath: outputPath,
@@ -74,8 +143,18 @@ func (m *Merger) Merge(ctx context.Context, videoPath, audioPath, outputPath str
 	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
+	o := newMergeOptions(opts)
+	metadataPath, err := writeChapterMetadata(o, videoPath, outputPath)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if metadataPath != "" {
+		defer os.Remove(metadataPath)
+	}
+
 	// Build ffmpeg command
-	args := m.buildMergeArgs(videoPath, audioPath, outputPath)
+	args := m.buildMergeArgsWithOptions(videoPath, audioPath, outputPath, metadataPath, o)
 
 	logging.Debug("executing ffmpeg merge",
 		"video_path", videoPath,
@@ -110,6 +189,8 @@ func (m *Merger) Merge(ctx context.Context, videoPath, audioPath, outputPath str
 		"output_path", outputPath,
 		"duration", result.Duration.String(),
 		"file_size", FormatFileSize(result.FileSize),
+		"chapters", len(o.chapters),
+		"removed_segments", len(o.removed),
 	)
 
 	return result
@@ -117,21 +198,162 @@ func (m *Merger) Merge(ctx context.Context, videoPath, audioPath, outputPath str
 
 // buildMergeArgs constructs ffmpeg command arguments for merging
 func (m *Merger) buildMergeArgs(videoPath, audioPath, outputPath string) []string {
-	return []string{
-		"-y",            // Overwrite output file
-		"-i", videoPath, // Input video
-		"-i", audioPath, // Input audio
+	return m.buildMergeArgsWithOptions(videoPath, audioPath, outputPath, "", &mergeOptions{})
+}
+
+// buildMergeArgsWithOptions constructs ffmpeg command arguments for merging,
+// reading chapters from metadataPath if set and applying o
+func (m *Merger) buildMergeArgsWithOptions(videoPath, audioPath, outputPath, metadataPath string, o *mergeOptions) []string {
+	args := buildProcessingArgs([]string{videoPath, audioPath}, outputPath, metadataPath, o,
 		"-c:v", "copy", // Copy video codec (no re-encoding)
 		"-c:a", "aac", // Encode audio to AAC for compatibility
 		"-b:a", "192k", // Audio bitrate
-		"-movflags", "+faststart", // Enable fast start for streaming
+	)
+	return append(args,
 		"-strict", "experimental",
 		outputPath,
+	)
+}
+
+// buildProcessingArgs constructs the ffmpeg arguments, up to the output path, that
+// write mediaInputs (a video, and optionally a separate audio file) to outputPath
+// with o applied. codecArgs select the audio and video codecs when nothing is cut out.
+// The container follows the output extension: Matroska for .mkv, MP4 otherwise.
+func buildProcessingArgs(mediaInputs []string, outputPath, metadataPath string, o *mergeOptions, codecArgs ...string) []string {
+	matroska := isMatroska(outputPath)
+
+	args := []string{"-y"} // Overwrite output file
+	for _, input := range mediaInputs {
+		args = append(args, "-i", input)
 	}
+	next := len(mediaInputs)
+
+	metadataInput := -1
+	if metadataPath != "" {
+		args = append(args, "-f", "ffmetadata", "-i", metadataPath)
+		metadataInput = next
+		next++
+	}
+
+	// MP4 stores cover art as an extra video stream; Matroska uses an attachment
+	coverInput := -1
+	if o.coverArt != "" && !matroska {
+		args = append(args, "-i", o.coverArt)
+		coverInput = next
+		next++
+	}
+
+	subtitleInput := next
+	for _, subtitle := range o.subtitles {
+		args = append(args, "-i", subtitle.Path)
+	}
+
+	audioStream := "0:a"
+	if len(mediaInputs) > 1 {
+		audioStream = "1:a"
+	}
+
+	// Extra streams need explicit mapping; otherwise ffmpeg picks the streams itself
+	if len(o.removed) > 0 {
+		args = append(args, cutArgs("0:v", audioStream, o.removed, o.transcode, matroska)...)
+	} else {
+		if coverInput >= 0 || len(o.subtitles) > 0 || o.transcode != nil {
+			args = append(args, "-map", "0:v:0", "-map", audioStream+":0?")
+		}
+		if o.transcode != nil {
+			args = append(args, transcodeArgs(o.transcode, matroska)...)
+			if o.transcode.MaxHeight > 0 {
+				args = append(args, "-filter:v:0", scaleFilter(o.transcode.MaxHeight))
+			}
+		} else {
+			args = append(args, codecArgs...)
+		}
+	}
+
+	if coverInput >= 0 {
+		args = append(args,
+			"-map", fmt.Sprintf("%d:v:0", coverInput),
+			"-c:v:1", "mjpeg",
+			"-disposition:v:1", "attached_pic",
+		)
+	}
+
+	if len(o.subtitles) > 0 {
+		for i, subtitle := range o.subtitles {
+			args = append(args, "-map", fmt.Sprintf("%d:s:0", subtitleInput+i))
+			if language := subtitleLanguage(subtitle.Language); language != "" {
+				args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "language="+language)
+			}
+		}
+		codec := "mov_text"
+		if matroska {
+			codec = "srt"
+		}
+		args = append(args, "-c:s", codec)
+	}
+
+	if o.coverArt != "" && matroska {
+		args = append(args,
+			"-attach", o.coverArt,
+			"-metadata:s:t", "mimetype="+imageMimeType(o.coverArt),
+			"-metadata:s:t", "filename=cover"+filepath.Ext(o.coverArt),
+		)
+	}
+
+	args = append(args, chapterArgs(metadataInput)...)
+	args = append(args, tagArgs(o.tags)...)
+
+	if !matroska {
+		args = append(args, "-movflags", "+faststart") // Enable fast start for streaming
+	}
+	return args
 }
 
-// MergeWithCodecCopy merges without any re-encoding (fastest but may have compatibility issues)
-func (m *Merger) MergeWithCodecCopy(ctx context.Context, videoPath, audioPath, outputPath string) *MergeResult {
+// chapterArgs returns the arguments that copy the global metadata and chapters
+// from input index inputIndex, or nothing if there is no metadata input
+func chapterArgs(inputIndex int) []string {
+	if inputIndex < 0 {
+		return nil
+	}
+	index := fmt.Sprint(inputIndex)
+	return []string{
+		"-map_metadata", index,
+		"-map_chapters", index,
+	}
+}
+
+// cutArgs returns the arguments that drop the removed segments from the video and
+// audio streams. Cutting needs a re-encode, since cuts rarely fall on keyframes;
+// it uses the transcode's settings when there is one.
+func cutArgs(videoStream, audioStream string, removed []Segment, transcode *Transcode, matroska bool) []string {
+	keep := keepExpression(removed)
+	videoFilter := fmt.Sprintf("select='%s',setpts=N/FRAME_RATE/TB", keep)
+	if transcode != nil && transcode.MaxHeight > 0 {
+		videoFilter += "," + scaleFilter(transcode.MaxHeight)
+	}
+	filter := fmt.Sprintf("[%s]%s[v];[%s]aselect='%s',asetpts=N/SR/TB[a]",
+		videoStream, videoFilter, audioStream, keep)
+
+	args := []string{
+		"-filter_complex", filter,
+		"-map", "[v]",
+		"-map", "[a]",
+	}
+	if transcode != nil {
+		return append(args, transcodeArgs(transcode, matroska)...)
+	}
+	return append(args,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-c:a", "aac",
+		"-b:a", "192k",
+	)
+}
+
+// MergeWithCodecCopy merges without any re-encoding (fastest but may have compatibility issues).
+// Removing segments or a transcode always re-encodes.
+func (m *Merger) MergeWithCodecCopy(ctx context.Context, videoPath, audioPath, outputPath string, opts ...MergeOption) *MergeResult {
 	startTime := time.Now()
 	result := &MergeResult{
 		OutputPath: outputPath,
@@ -140,15 +362,21 @@ func (m *Merger) MergeWithCodecCopy(ctx context.Context, videoPath, audioPath, o
 	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	args := []string{
-		"-y",
-		"-i", videoPath,
-		"-i", audioPath,
-		"-c", "copy", // Copy both streams without re-encoding
-		"-movflags", "+faststart",
-		outputPath,
+	o := newMergeOptions(opts)
+	metadataPath, err := writeChapterMetadata(o, videoPath, outputPath)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if metadataPath != "" {
+		defer os.Remove(metadataPath)
 	}
 
+	args := buildProcessingArgs([]string{videoPath, audioPath}, outputPath, metadataPath, o,
+		"-c", "copy", // Copy both streams without re-encoding
+	)
+	args = append(args, outputPath)
+
 	cmd := exec.CommandContext(ctx, m.ffmpegPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -277,8 +505,15 @@ func (m *Merger) ConvertToMP4(ctx context.Context, inputPath, outputPath string)
 	return result
 }
 
-// RemuxToMP4 remuxes a video to MP4 without re-encoding
-func (m *Merger) RemuxToMP4(ctx context.Context, inputPath, outputPath string) *MergeResult {
+// RemuxToMP4 remuxes a video to MP4 without re-encoding.
+// Removing segments always re-encodes.
+func (m *Merger) RemuxToMP4(ctx context.Context, inputPath, outputPath string, opts ...MergeOption) *MergeResult {
+	return m.Remux(ctx, inputPath, outputPath, opts...)
+}
+
+// Remux rewrites a video without re-encoding, into MP4 or, for a .mkv output
+// path, Matroska. Removing segments or a transcode always re-encodes.
+func (m *Merger) Remux(ctx context.Context, inputPath, outputPath string, opts ...MergeOption) *MergeResult {
 	startTime := time.Now()
 	result := &MergeResult{
 		OutputPath: outputPath,
@@ -287,14 +522,19 @@ func (m *Merger) RemuxToMP4(ctx context.Context, inputPath, outputPath string) *
 	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	args := []string{
-		"-y",
-		"-i", inputPath,
-		"-c", "copy",
-		"-movflags", "+faststart",
-		outputPath,
+	o := newMergeOptions(opts)
+	metadataPath, err := writeChapterMetadata(o, inputPath, outputPath)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if metadataPath != "" {
+		defer os.Remove(metadataPath)
 	}
 
+	args := buildProcessingArgs([]string{inputPath}, outputPath, metadataPath, o, "-c", "copy")
+	args = append(args, outputPath)
+
 	cmd := exec.CommandContext(ctx, m.ffmpegPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,205 @@
+package downloader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Video codecs profiles prefer and transcode to
+const (
+	CodecAV1  = "av1"
+	CodecVP9  = "vp9"
+	CodecH264 = "h264"
+	CodecHEVC = "hevc" // only as a transcode target; YouTube does not serve HEVC
+)
+
+// Profile is a named set of quality preferences that can be assigned to a
+// channel. Zero fields fall back to the downloader's Config.
+type Profile struct {
+	Name              string     `json:"name"`
+	Description       string     `json:"description,omitempty"`
+	MaxHeight         int        `json:"max_height,omitempty"`           // lower cap than Config.MaxHeight; 0 keeps the config's
+	Codecs            []string   `json:"codecs,omitempty"`               // preferred video codecs, best first; empty prefers H.264
+	Container         string     `json:"container,omitempty"`            // mp4 or mkv; empty uses Config.MergeOutputFormat
+	AudioOnly         bool       `json:"audio_only,omitempty"`           // keep only the best audio stream, as M4A
+	MaxBytesPerMinute int64      `json:"max_bytes_per_minute,omitempty"` // skip streams that would be larger; 0 for no cap
+	Transcode         *Transcode `json:"transcode,omitempty"`            // re-encode after downloading
+}
+
+// Transcode re-encodes videos after they are downloaded, usually to save space
+type Transcode struct {
+	Codec        string `json:"codec"`                   // h264, hevc or av1
+	MaxHeight    int    `json:"max_height,omitempty"`    // scale taller videos down; 0 keeps the height
+	CRF          int    `json:"crf,omitempty"`           // quality, lower is better; 0 uses the encoder's default
+	Preset       string `json:"preset,omitempty"`        // encoder speed preset; empty uses the encoder's default
+	AudioBitrate int    `json:"audio_bitrate,omitempty"` // AAC bitrate in kbit/s; 0 uses 128
+}
+
+// encoder is the ffmpeg encoder of a transcode codec
+type encoder struct {
+	name   string
+	crf    int
+	preset string
+	maxCRF int
+}
+
+// encoders are the ffmpeg encoders transcodes use
+var encoders = map[string]encoder{
+	CodecH264: {name: "libx264", crf: 23, preset: "medium", maxCRF: 51},
+	CodecHEVC: {name: "libx265", crf: 28, preset: "medium", maxCRF: 51},
+	CodecAV1:  {name: "libsvtav1", crf: 35, preset: "8", maxCRF: 63},
+}
+
+// presetPattern matches the names and numbers encoders use for presets
+var presetPattern = regexp.MustCompile(`^[a-z0-9]{1,16}$`)
+
+// Validate checks a profile's settings; the name is checked by whoever stores it
+func (p *Profile) Validate() error {
+	if p.MaxHeight < 0 {
+		return fmt.Errorf("max_height must not be negative")
+	}
+	for _, codec := range p.Codecs {
+		switch codec {
+		case CodecAV1, CodecVP9, CodecH264:
+		default:
+			return fmt.Errorf("unknown codec %q: use av1, vp9 or h264", codec)
+		}
+	}
+	switch p.Container {
+	case "", "mp4", "mkv":
+	default:
+		return fmt.Errorf("unknown container %q: use mp4 or mkv", p.Container)
+	}
+	if p.MaxBytesPerMinute < 0 {
+		return fmt.Errorf("max_bytes_per_minute must not be negative")
+	}
+	if p.Transcode != nil {
+		if p.AudioOnly {
+			return fmt.Errorf("an audio-only profile cannot transcode video")
+		}
+		if err := p.Transcode.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a transcode's settings
+func (t *Transcode) Validate() error {
+	enc, ok := encoders[t.Codec]
+	if !ok {
+		return fmt.Errorf("unknown transcode codec %q: use h264, hevc or av1", t.Codec)
+	}
+	if t.MaxHeight < 0 {
+		return fmt.Errorf("transcode max_height must not be negative")
+	}
+	if t.CRF < 0 || t.CRF > enc.maxCRF {
+		return fmt.Errorf("transcode crf must be between 0 and %d for %s", enc.maxCRF, t.Codec)
+	}
+	if t.Preset != "" && !presetPattern.MatchString(t.Preset) {
+		return fmt.Errorf("invalid transcode preset %q", t.Preset)
+	}
+	if t.AudioBitrate < 0 || t.AudioBitrate > 512 {
+		return fmt.Errorf("transcode audio_bitrate must be between 0 and 512")
+	}
+	return nil
+}
+
+// maxHeight returns the height cap of a profile under a configured one. A nil
+// profile keeps the configured cap.
+func (p *Profile) maxHeight(configured int) int {
+	if p == nil || p.MaxHeight <= 0 {
+		return configured
+	}
+	if configured <= 0 || p.MaxHeight < configured {
+		return p.MaxHeight
+	}
+	return configured
+}
+
+// outputFormat returns the container of a profile, or the configured one
+func (p *Profile) outputFormat(config *Config) string {
+	if p != nil && p.Container != "" {
+		return p.Container
+	}
+	return config.OutputFormat()
+}
+
+// VideoCodecFamily returns the codec family of an RFC 6381 codec string, such
+// as h264 for "avc1.640028" or av1 for "av01.0.08M.08". Unknown codecs are
+// returned lowercased.
+func VideoCodecFamily(codec string) string {
+	codec = strings.ToLower(strings.TrimSpace(codec))
+	switch {
+	case strings.HasPrefix(codec, "av01"), codec == "av1":
+		return CodecAV1
+	case strings.HasPrefix(codec, "vp09"), strings.HasPrefix(codec, "vp9"):
+		return CodecVP9
+	case strings.HasPrefix(codec, "avc"), codec == "h264":
+		return CodecH264
+	case strings.HasPrefix(codec, "hev1"), strings.HasPrefix(codec, "hvc1"), codec == "h265", codec == "hevc":
+		return CodecHEVC
+	}
+	return codec
+}
+
+// CodecsFromMimeType returns the codecs parameter of a MIME type such as
+// `video/mp4; codecs="avc1.640028, mp4a.40.2"`, in order
+func CodecsFromMimeType(mimeType string) []string {
+	_, params, found := strings.Cut(mimeType, "codecs=")
+	if !found {
+		return nil
+	}
+	params = strings.Trim(strings.TrimSpace(params), `"`)
+	if end := strings.IndexByte(params, '"'); end >= 0 {
+		params = params[:end]
+	}
+
+	var codecs []string
+	for _, codec := range strings.Split(params, ",") {
+		if codec = strings.TrimSpace(codec); codec != "" {
+			codecs = append(codecs, codec)
+		}
+	}
+	return codecs
+}
+
+// transcodeArgs returns the ffmpeg arguments that encode the first video
+// stream and the audio for a transcode. Scaling is left to the caller, since
+// it is a plain filter or part of a filter graph.
+func transcodeArgs(t *Transcode, matroska bool) []string {
+	enc := encoders[t.Codec]
+	crf := t.CRF
+	if crf == 0 {
+		crf = enc.crf
+	}
+	preset := t.Preset
+	if preset == "" {
+		preset = enc.preset
+	}
+	audioBitrate := t.AudioBitrate
+	if audioBitrate == 0 {
+		audioBitrate = 128
+	}
+
+	args := []string{
+		"-c:v:0", enc.name,
+		"-crf", strconv.Itoa(crf),
+		"-preset", preset,
+	}
+	if t.Codec == CodecHEVC && !matroska {
+		args = append(args, "-tag:v:0", "hvc1") // Apple players only play HEVC in MP4 tagged hvc1
+	}
+	return append(args,
+		"-c:a", "aac",
+		"-b:a", fmt.Sprintf("%dk", audioBitrate),
+	)
+}
+
+// scaleFilter returns the filter that scales videos taller than maxHeight
+// down to it, keeping the aspect ratio
+func scaleFilter(maxHeight int) string {
+	return fmt.Sprintf("scale=-2:'min(%d,ih)'", maxHeight)
+}
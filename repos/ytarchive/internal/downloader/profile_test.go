@@ -0,0 +1,326 @@
+package downloader
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr bool
+	}{
+		{name: "empty", profile: Profile{Name: "empty"}},
+		{name: "codecs and container", profile: Profile{Codecs: []string{CodecAV1, CodecVP9}, Container: "mkv", MaxHeight: 1080}},
+		{name: "transcode", profile: Profile{Transcode: &Transcode{Codec: CodecHEVC, MaxHeight: 720, CRF: 28, Preset: "slow"}}},
+		{name: "audio only", profile: Profile{AudioOnly: true}},
+		{name: "unknown codec", profile: Profile{Codecs: []string{"hevc"}}, wantErr: true},
+		{name: "unknown container", profile: Profile{Container: "webm"}, wantErr: true},
+		{name: "negative height", profile: Profile{MaxHeight: -1}, wantErr: true},
+		{name: "negative size", profile: Profile{MaxBytesPerMinute: -1}, wantErr: true},
+		{name: "audio only transcode", profile: Profile{AudioOnly: true, Transcode: &Transcode{Codec: CodecH264}}, wantErr: true},
+		{name: "unknown transcode codec", profile: Profile{Transcode: &Transcode{Codec: "vp9"}}, wantErr: true},
+		{name: "crf out of range", profile: Profile{Transcode: &Transcode{Codec: CodecH264, CRF: 52}}, wantErr: true},
+		{name: "av1 crf", profile: Profile{Transcode: &Transcode{Codec: CodecAV1, CRF: 60}}},
+		{name: "invalid preset", profile: Profile{Transcode: &Transcode{Codec: CodecH264, Preset: "-y"}}, wantErr: true},
+		{name: "audio bitrate out of range", profile: Profile{Transcode: &Transcode{Codec: CodecH264, AudioBitrate: 1024}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileMaxHeight(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    *Profile
+		configured int
+		want       int
+	}{
+		{name: "no profile", profile: nil, configured: 1080, want: 1080},
+		{name: "no cap", profile: &Profile{}, configured: 1080, want: 1080},
+		{name: "lower cap", profile: &Profile{MaxHeight: 720}, configured: 1080, want: 720},
+		{name: "higher cap", profile: &Profile{MaxHeight: 2160}, configured: 1080, want: 1080},
+		{name: "unlimited config", profile: &Profile{MaxHeight: 720}, configured: 0, want: 720},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.maxHeight(tt.configured); got != tt.want {
+				t.Errorf("maxHeight(%d) = %d, want %d", tt.configured, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVideoCodecFamily(t *testing.T) {
+	tests := map[string]string{
+		"avc1.640028":   CodecH264,
+		"av01.0.08M.08": CodecAV1,
+		"vp09.00.40.08": CodecVP9,
+		"vp9":           CodecVP9,
+		"hvc1.1.6.L93":  CodecHEVC,
+		" AVC1.4d401f":  CodecH264,
+		"mp4a.40.2":     "mp4a.40.2",
+	}
+	for codec, want := range tests {
+		if got := VideoCodecFamily(codec); got != want {
+			t.Errorf("VideoCodecFamily(%q) = %q, want %q", codec, got, want)
+		}
+	}
+}
+
+func TestCodecsFromMimeType(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     []string
+	}{
+		{mimeType: `video/mp4; codecs="avc1.640028"`, want: []string{"avc1.640028"}},
+		{mimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, want: []string{"avc1.42001E", "mp4a.40.2"}},
+		{mimeType: `video/webm; codecs="vp9"`, want: []string{"vp9"}},
+		{mimeType: `audio/mp4`, want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := CodecsFromMimeType(tt.mimeType); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CodecsFromMimeType(%q) = %q, want %q", tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestTranscodeArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		transcode Transcode
+		matroska  bool
+		want      string
+		notWant   string
+	}{
+		{
+			name:      "encoder defaults",
+			transcode: Transcode{Codec: CodecH264},
+			want:      "-c:v:0 libx264 -crf 23 -preset medium -c:a aac -b:a 128k",
+		},
+		{
+			name:      "hevc in mp4 is tagged hvc1",
+			transcode: Transcode{Codec: CodecHEVC, CRF: 26, Preset: "slow", AudioBitrate: 96},
+			want:      "-c:v:0 libx265 -crf 26 -preset slow -tag:v:0 hvc1 -c:a aac -b:a 96k",
+		},
+		{
+			name:      "hevc in mkv",
+			transcode: Transcode{Codec: CodecHEVC},
+			matroska:  true,
+			want:      "-c:v:0 libx265",
+			notWant:   "hvc1",
+		},
+		{
+			name:      "av1",
+			transcode: Transcode{Codec: CodecAV1},
+			want:      "-c:v:0 libsvtav1 -crf 35 -preset 8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joined := strings.Join(transcodeArgs(&tt.transcode, tt.matroska), " ")
+			if !strings.Contains(joined, tt.want) {
+				t.Errorf("args %q missing %q", joined, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(joined, tt.notWant) {
+				t.Errorf("args %q should not contain %q", joined, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestBuildProcessingArgsTranscode(t *testing.T) {
+	transcode := &Transcode{Codec: CodecHEVC, MaxHeight: 720}
+
+	t.Run("remux", func(t *testing.T) {
+		o := newMergeOptions([]MergeOption{WithTranscode(transcode)})
+		joined := strings.Join(buildProcessingArgs([]string{"in.mp4"}, "out.mp4", "", o, "-c", "copy"), " ")
+
+		for _, want := range []string{"-map 0:v:0 -map 0:a:0?", "-c:v:0 libx265", "-filter:v:0 scale=-2:'min(720,ih)'"} {
+			if !strings.Contains(joined, want) {
+				t.Errorf("args %q missing %q", joined, want)
+			}
+		}
+		if strings.Contains(joined, "-c copy") {
+			t.Errorf("args %q should not copy streams", joined)
+		}
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		o := newMergeOptions([]MergeOption{WithTranscode(transcode), WithRemovedSegments([]Segment{{Start: 10, End: 20}})})
+		joined := strings.Join(buildProcessingArgs([]string{"v.mp4", "a.m4a"}, "out.mp4", "", o, "-c", "copy"), " ")
+
+		for _, want := range []string{"setpts=N/FRAME_RATE/TB,scale=-2:'min(720,ih)'[v]", "-c:v:0 libx265"} {
+			if !strings.Contains(joined, want) {
+				t.Errorf("args %q missing %q", joined, want)
+			}
+		}
+		if strings.Contains(joined, "libx264") {
+			t.Errorf("args %q should use the transcode's encoder", joined)
+		}
+	})
+}
+
+func TestStreamSelectorProfiles(t *testing.T) {
+	streams := []Stream{
+		{FormatID: "137", URL: "http://example.com/137", Height: 1080, StreamType: StreamTypeVideo, VCodec: "avc1.640028", Extension: "mp4", ContentLength: 300_000_000},
+		{FormatID: "248", URL: "http://example.com/248", Height: 1080, StreamType: StreamTypeVideo, VCodec: "vp9", Extension: "webm", ContentLength: 200_000_000},
+		{FormatID: "399", URL: "http://example.com/399", Height: 1080, StreamType: StreamTypeVideo, VCodec: "av01.0.08M.08", Extension: "mp4", ContentLength: 150_000_000},
+		{FormatID: "136", URL: "http://example.com/136", Height: 720, StreamType: StreamTypeVideo, VCodec: "avc1.4d401f", Extension: "mp4", ContentLength: 100_000_000},
+		{FormatID: "140", URL: "http://example.com/140", StreamType: StreamTypeAudio, ACodec: "mp4a.40.2", Extension: "m4a", Bitrate: 128000, ContentLength: 10_000_000},
+	}
+
+	tests := []struct {
+		name     string
+		selector *StreamSelector
+		want     string
+	}{
+		{
+			name:     "h264 by default",
+			selector: NewStreamSelector(1080, false),
+			want:     "137",
+		},
+		{
+			name:     "preferred codecs",
+			selector: NewStreamSelector(1080, false).WithPreferredCodecs([]string{CodecAV1, CodecVP9}),
+			want:     "399",
+		},
+		{
+			name:     "second preferred codec",
+			selector: NewStreamSelector(1080, false).WithPreferredCodecs([]string{"unknown", CodecVP9}),
+			want:     "248",
+		},
+		{
+			// 20 minutes at 10 MB a minute leaves 190 MB for the video after the audio
+			name:     "size limit",
+			selector: NewStreamSelector(1080, false).WithSizeLimit(10_000_000, 1200),
+			want:     "136",
+		},
+		{
+			name:     "size limit with preferred codecs",
+			selector: NewStreamSelector(1080, false).WithPreferredCodecs([]string{CodecAV1}).WithSizeLimit(10_000_000, 1200),
+			want:     "399",
+		},
+		{
+			name:     "nothing fits takes the smallest",
+			selector: NewStreamSelector(1080, false).WithSizeLimit(1_000_000, 1200),
+			want:     "136",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video, audio, err := tt.selector.SelectBestStream(streams)
+			if err != nil {
+				t.Fatalf("SelectBestStream() error = %v", err)
+			}
+			if video.FormatID != tt.want {
+				t.Errorf("video format = %s, want %s", video.FormatID, tt.want)
+			}
+			if audio == nil || audio.FormatID != "140" {
+				t.Errorf("audio = %v, want format 140", audio)
+			}
+		})
+	}
+}
+
+func TestPlanReprofile(t *testing.T) {
+	hevc := &Transcode{Codec: CodecHEVC, MaxHeight: 720, CRF: 28}
+
+	tests := []struct {
+		name      string
+		videoPath string
+		height    int
+		profile   Profile
+		applied   *Transcode
+		want      reprofilePlan
+		wantOK    bool
+	}{
+		{
+			name:      "default profile leaves the video",
+			videoPath: "video.mp4",
+			height:    1080,
+			profile:   Profile{Name: "default"},
+			want:      reprofilePlan{ext: ".mp4"},
+		},
+		{
+			name:      "transcode",
+			videoPath: "video.mp4",
+			height:    1080,
+			profile:   Profile{Transcode: hevc},
+			want:      reprofilePlan{transcode: hevc, ext: ".mp4"},
+			wantOK:    true,
+		},
+		{
+			name:      "transcode already applied",
+			videoPath: "video.mp4",
+			height:    720,
+			profile:   Profile{Transcode: hevc},
+			applied:   &Transcode{Codec: CodecHEVC, MaxHeight: 720, CRF: 28},
+			want:      reprofilePlan{ext: ".mp4"},
+		},
+		{
+			name:      "too tall is scaled down in h264",
+			videoPath: "video.mp4",
+			height:    1080,
+			profile:   Profile{MaxHeight: 720},
+			want:      reprofilePlan{transcode: &Transcode{Codec: CodecH264, MaxHeight: 720}, ext: ".mp4"},
+			wantOK:    true,
+		},
+		{
+			name:      "short enough",
+			videoPath: "video.mp4",
+			height:    480,
+			profile:   Profile{MaxHeight: 720},
+			want:      reprofilePlan{ext: ".mp4"},
+		},
+		{
+			name:      "container",
+			videoPath: "video.mp4",
+			height:    1080,
+			profile:   Profile{Container: "mkv"},
+			want:      reprofilePlan{ext: ".mkv"},
+			wantOK:    true,
+		},
+		{
+			name:      "webm transcoded to mp4",
+			videoPath: "video.webm",
+			height:    1080,
+			profile:   Profile{Transcode: hevc},
+			want:      reprofilePlan{transcode: hevc, ext: ".mp4"},
+			wantOK:    true,
+		},
+		{
+			name:      "audio only",
+			videoPath: "video.mkv",
+			height:    1080,
+			profile:   Profile{AudioOnly: true},
+			want:      reprofilePlan{audioOnly: true, ext: ".m4a"},
+			wantOK:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := planReprofile(tt.videoPath, tt.height, &tt.profile, tt.applied)
+			if ok != tt.wantOK {
+				t.Errorf("planReprofile() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planReprofile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
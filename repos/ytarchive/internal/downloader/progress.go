@@ -28,11 +28,16 @@ type DownloadProgress struct {
 // ProgressCallback is called when download progress is updated
 type ProgressCallback func(progress *DownloadProgress)
 
+// TokenSource returns the bearer token to authenticate to the controller with,
+// or "" to send none
+type TokenSource func() (string, error)
+
 // ProgressReporter handles reporting progress to the controller API
 type ProgressReporter struct {
 	controllerURL string
 	workerID      string
 	httpClient    *http.Client
+	token         TokenSource
 }
 
 // NewProgressReporter creates a new ProgressReporter
@@ -46,6 +51,26 @@ func NewProgressReporter(controllerURL, workerID string) *ProgressReporter {
 	}
 }
 
+// SetTokenSource makes the reporter authenticate its reports with tokens from source
+func (r *ProgressReporter) SetTokenSource(source TokenSource) {
+	r.token = source
+}
+
+// authorize adds the reporter's bearer token to a request
+func (r *ProgressReporter) authorize(req *http.Request) error {
+	if r.token == nil {
+		return nil
+	}
+	token, err := r.token()
+	if err != nil {
+		return fmt.Errorf("failed to get controller token: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
 // ReportProgress sends progress to the controller API
 func (r *ProgressReporter) ReportProgress(progress *DownloadProgress) error {
 	progress.WorkerID = r.workerID
@@ -77,6 +102,9 @@ func (r *ProgressReporter) ReportProgress(progress *DownloadProgress) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if err := r.authorize(req); err != nil {
+		return err
+	}
 
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
@@ -120,6 +148,9 @@ func (r *ProgressReporter) ReportVideoStatus(status *VideoStatus) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if err := r.authorize(req); err != nil {
+		return err
+	}
 
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
@@ -0,0 +1,152 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reprofilePlan is what re-processing an archived video under a profile does
+type reprofilePlan struct {
+	audioOnly bool       // keep only the audio, as M4A
+	transcode *Transcode // re-encode the video; nil to copy it
+	ext       string     // extension of the new file, with the dot
+}
+
+// planReprofile works out how to bring an archived video of a height to a
+// profile, given the transcode it was last given, if any. Only what can be
+// done to the file itself is planned: codec preferences and size caps choose
+// between the streams YouTube offers, so they need a new download. A video
+// taller than the profile allows is scaled down in H.264 when the profile has
+// no transcode of its own. It returns false if the video already fits.
+func planReprofile(videoPath string, height int, profile *Profile, applied *Transcode) (reprofilePlan, bool) {
+	ext := strings.ToLower(filepath.Ext(videoPath))
+	if profile.AudioOnly {
+		return reprofilePlan{audioOnly: true, ext: ".m4a"}, true
+	}
+
+	transcode := profile.Transcode
+	if transcode == nil && profile.MaxHeight > 0 && height > profile.MaxHeight {
+		transcode = &Transcode{Codec: CodecH264, MaxHeight: profile.MaxHeight}
+	}
+	if transcode != nil && applied != nil && *transcode == *applied {
+		transcode = nil // encoding again would only lose quality
+	}
+
+	plan := reprofilePlan{transcode: transcode, ext: ext}
+	switch {
+	case profile.Container != "":
+		plan.ext = "." + profile.Container
+	case ext == ".webm" && transcode != nil:
+		plan.ext = ".mp4" // WebM only holds VP9 and AV1
+	}
+	return plan, plan.transcode != nil || plan.ext != ext
+}
+
+// ReprofileArchived re-processes the video in an archived video directory
+// under a profile: it transcodes or scales it down, changes its container or
+// keeps only its audio, carrying over the metadata stored next to it. The
+// profile and transcode are recorded in metadata.json. It returns the path of
+// the video and whether it was changed.
+func ReprofileArchived(ctx context.Context, m *Merger, videoDir string, profile *Profile) (string, bool, error) {
+	videoPath := FindVideoFile(videoDir)
+	if videoPath == "" {
+		return "", false, fmt.Errorf("no video file in %s", videoDir)
+	}
+
+	_, _, height, err := GetVideoInfo(videoPath)
+	if err != nil {
+		return videoPath, false, fmt.Errorf("failed to probe video: %w", err)
+	}
+
+	metadataPath := filepath.Join(videoDir, "metadata.json")
+	plan, ok := planReprofile(videoPath, height, profile, appliedTranscode(metadataPath))
+	if !ok {
+		return videoPath, false, updateProfileMetadata(metadataPath, profile, nil, videoPath)
+	}
+
+	outputPath := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + plan.ext
+	if plan.audioOnly {
+		tempPath := filepath.Join(videoDir, "processed_temp.m4a")
+		if result := m.ExtractAudio(ctx, videoPath, tempPath); result.Error != nil {
+			os.Remove(tempPath)
+			return videoPath, false, result.Error
+		}
+		if err := os.Rename(tempPath, outputPath); err != nil {
+			os.Remove(tempPath)
+			return videoPath, false, fmt.Errorf("failed to rename processed file: %w", err)
+		}
+		os.Remove(videoPath)
+	} else {
+		var opts []MergeOption
+		if info, chapters, err := LoadEmbedInfo(metadataPath); err == nil && plan.ext != ".webm" {
+			opts = EmbedOptions(info, videoDir)
+			if len(chapters) > 0 {
+				opts = append(opts, WithChapters(chapters))
+			}
+		}
+		if plan.transcode != nil {
+			opts = append(opts, WithTranscode(plan.transcode))
+		}
+		if err := remuxInPlace(ctx, m, videoPath, outputPath, opts); err != nil {
+			return videoPath, false, err
+		}
+	}
+
+	return outputPath, true, updateProfileMetadata(metadataPath, profile, plan.transcode, outputPath)
+}
+
+// appliedTranscode returns the transcode recorded in a metadata.json, or nil
+func appliedTranscode(metadataPath string) *Transcode {
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return nil
+	}
+	var metadata struct {
+		Transcode *Transcode `json:"transcode"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil
+	}
+	return metadata.Transcode
+}
+
+// updateProfileMetadata records a video's profile, file size and, if it was
+// just re-encoded, transcode in its metadata.json. A missing metadata.json is
+// left missing.
+func updateProfileMetadata(metadataPath string, profile *Profile, transcode *Transcode, videoPath string) error {
+	data, err := os.ReadFile(metadataPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	metadata["profile"] = profile.Name
+	switch {
+	case profile.AudioOnly:
+		delete(metadata, "transcode")
+	case transcode != nil:
+		metadata["transcode"] = transcode
+	}
+	if info, err := os.Stat(videoPath); err == nil {
+		metadata["file_size"] = info.Size()
+	}
+
+	data, err = json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
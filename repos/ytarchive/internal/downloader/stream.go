@@ -61,9 +61,11 @@ type VideoInfo struct {
 
 // StreamSelector handles stream selection based on quality preferences
 type StreamSelector struct {
-	maxHeight       int
-	preferCombined  bool
-	preferredCodecs []string
+	maxHeight         int
+	preferCombined    bool
+	preferredCodecs   []string // video codec families, best first
+	maxBytesPerMinute int64
+	duration          int // seconds, to estimate stream sizes
 }
 
 // NewStreamSelector creates a new StreamSelector with the given preferences
@@ -71,10 +73,83 @@ func NewStreamSelector(maxHeight int, preferCombined bool) *StreamSelector {
 	return &StreamSelector{
 		maxHeight:       maxHeight,
 		preferCombined:  preferCombined,
-		preferredCodecs: []string{"avc1", "h264", "mp4a", "aac"},
+		preferredCodecs: []string{CodecH264},
 	}
 }
 
+// WithPreferredCodecs makes the selector prefer video codecs in order, such as
+// av1 then vp9, over any resolution. Codecs not listed come last.
+func (s *StreamSelector) WithPreferredCodecs(codecs []string) *StreamSelector {
+	if len(codecs) > 0 {
+		s.preferredCodecs = codecs
+	}
+	return s
+}
+
+// WithSizeLimit makes the selector skip streams that would take more than
+// bytesPerMinute for a video of durationSeconds, audio included. When every
+// stream is larger, the smallest is taken. A zero limit or duration disables it.
+func (s *StreamSelector) WithSizeLimit(bytesPerMinute int64, durationSeconds int) *StreamSelector {
+	s.maxBytesPerMinute = bytesPerMinute
+	s.duration = durationSeconds
+	return s
+}
+
+// codecRank returns the position of a stream's video codec in the preferred codecs
+func (s *StreamSelector) codecRank(stream Stream) int {
+	family := VideoCodecFamily(stream.VCodec)
+	for i, codec := range s.preferredCodecs {
+		if codec == family {
+			return i
+		}
+	}
+	return len(s.preferredCodecs)
+}
+
+// sizeBudget returns the bytes a video may take under the size limit, or 0 for no limit
+func (s *StreamSelector) sizeBudget() int64 {
+	if s.maxBytesPerMinute <= 0 || s.duration <= 0 {
+		return 0
+	}
+	return s.maxBytesPerMinute * int64(s.duration) / 60
+}
+
+// estimatedSize returns the size of a stream, from its content length or
+// else its bitrate, or 0 if neither is known
+func (s *StreamSelector) estimatedSize(stream Stream) int64 {
+	if stream.ContentLength > 0 {
+		return stream.ContentLength
+	}
+	if stream.FileSize > 0 {
+		return stream.FileSize
+	}
+	return int64(stream.Bitrate) * int64(s.duration) / 8
+}
+
+// withinSize returns the candidates that fit in budget bytes, or the smallest
+// one if none does. Streams of unknown size are assumed to fit.
+func (s *StreamSelector) withinSize(candidates []Stream, budget int64) []Stream {
+	if s.sizeBudget() == 0 || len(candidates) == 0 {
+		return candidates
+	}
+
+	var fitting []Stream
+	smallest := candidates[0]
+	for _, stream := range candidates {
+		size := s.estimatedSize(stream)
+		if size <= budget {
+			fitting = append(fitting, stream)
+		}
+		if size < s.estimatedSize(smallest) {
+			smallest = stream
+		}
+	}
+	if len(fitting) == 0 {
+		return []Stream{smallest}
+	}
+	return fitting
+}
+
 // SelectBestStream selects the best stream based on quality preferences
 // If preferCombined is true, it will ALWAYS use combined streams when available
 // (this is required when ffmpeg is not available for merging video+audio).
@@ -143,6 +218,7 @@ func (s *StreamSelector) findBestCombined(streams []Stream) *Stream {
 		candidates = append(candidates, stream)
 	}
 
+	candidates = s.withinSize(candidates, s.sizeBudget())
 	if len(candidates) == 0 {
 		return nil
 	}
@@ -184,17 +260,22 @@ func (s *StreamSelector) findBestVideo(streams []Stream) *Stream {
 		candidates = append(candidates, stream)
 	}
 
+	// The audio stream merged in takes its share of the size limit
+	if budget := s.sizeBudget(); budget > 0 {
+		if audio := s.findBestAudio(streams); audio != nil {
+			budget -= s.estimatedSize(*audio)
+		}
+		candidates = s.withinSize(candidates, budget)
+	}
 	if len(candidates) == 0 {
 		return nil
 	}
 
-	// Sort by height (descending), prefer MP4/H264, then by bitrate
+	// Sort by preferred codec (H264/AVC unless set), prefer MP4, then by height and bitrate
 	sort.Slice(candidates, func(i, j int) bool {
-		// Prefer H264/AVC
-		iH264 := strings.Contains(candidates[i].VCodec, "avc") || strings.Contains(candidates[i].VCodec, "h264")
-		jH264 := strings.Contains(candidates[j].VCodec, "avc") || strings.Contains(candidates[j].VCodec, "h264")
-		if iH264 != jH264 {
-			return iH264
+		iRank, jRank := s.codecRank(candidates[i]), s.codecRank(candidates[j])
+		if iRank != jRank {
+			return iRank < jRank
 		}
 		// Prefer MP4
 		iMP4 := candidates[i].Extension == "mp4"
@@ -0,0 +1,261 @@
+// Package feed builds podcast RSS feeds of archived channels. Feeds carry the
+// iTunes tags podcast apps expect, and chapters both inline (Podlove Simple
+// Chapters) and as a Podcasting 2.0 JSON chapters file.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// XML namespaces used in feeds
+const (
+	itunesNamespace  = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	podcastNamespace = "https://podcastindex.org/namespace/1.0"
+	pscNamespace     = "http://podlove.org/simple-chapters"
+	atomNamespace    = "http://www.w3.org/2005/Atom"
+)
+
+// Channel describes the podcast
+type Channel struct {
+	Title       string
+	Description string
+	Author      string
+	Link        string // the channel on YouTube
+	FeedURL     string // the feed itself, for atom:link rel="self"
+	ImageURL    string
+	Language    string
+}
+
+// Chapter is a chapter of an episode. Times are in seconds.
+type Chapter struct {
+	Title string
+	Start float64
+}
+
+// Item is an episode of the podcast
+type Item struct {
+	GUID        string
+	Title       string
+	Description string
+	Link        string
+	Published   time.Time
+	Duration    int // seconds
+	ImageURL    string
+
+	EnclosureURL    string
+	EnclosureType   string
+	EnclosureLength int64
+
+	Chapters    []Chapter
+	ChaptersURL string // JSON chapters file; only set if there are chapters
+}
+
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ItunesNS  string     `xml:"xmlns:itunes,attr"`
+	PodcastNS string     `xml:"xmlns:podcast,attr"`
+	PscNS     string     `xml:"xmlns:psc,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string       `xml:"title"`
+	Link           string       `xml:"link,omitempty"`
+	Description    cdata        `xml:"description"`
+	Language       string       `xml:"language,omitempty"`
+	Generator      string       `xml:"generator"`
+	LastBuildDate  string       `xml:"lastBuildDate"`
+	AtomLink       *atomLink    `xml:"atom:link,omitempty"`
+	ItunesAuthor   string       `xml:"itunes:author,omitempty"`
+	ItunesSummary  string       `xml:"itunes:summary,omitempty"`
+	ItunesType     string       `xml:"itunes:type"`
+	ItunesExplicit string       `xml:"itunes:explicit"`
+	ItunesImage    *itunesImage `xml:"itunes:image,omitempty"`
+	Image          *rssImage    `xml:"image,omitempty"`
+	Items          []rssItem    `xml:"item"`
+}
+
+type rssItem struct {
+	Title             string           `xml:"title"`
+	Link              string           `xml:"link,omitempty"`
+	GUID              rssGUID          `xml:"guid"`
+	PubDate           string           `xml:"pubDate,omitempty"`
+	Description       cdata            `xml:"description"`
+	Enclosure         rssEnclosure     `xml:"enclosure"`
+	ItunesTitle       string           `xml:"itunes:title"`
+	ItunesDuration    string           `xml:"itunes:duration,omitempty"`
+	ItunesEpisodeType string           `xml:"itunes:episodeType"`
+	ItunesExplicit    string           `xml:"itunes:explicit"`
+	ItunesImage       *itunesImage     `xml:"itunes:image,omitempty"`
+	PodcastChapters   *podcastChapters `xml:"podcast:chapters,omitempty"`
+	PscChapters       *pscChapters     `xml:"psc:chapters,omitempty"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type podcastChapters struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type pscChapters struct {
+	Version  string       `xml:"version,attr"`
+	Chapters []pscChapter `xml:"psc:chapter"`
+}
+
+type pscChapter struct {
+	Start string `xml:"start,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// Build renders the podcast feed of a channel
+func Build(channel Channel, items []Item, now time.Time) ([]byte, error) {
+	ch := rssChannel{
+		Title:          channel.Title,
+		Link:           channel.Link,
+		Description:    cdata{channel.Description},
+		Language:       channel.Language,
+		Generator:      "ytarchive",
+		LastBuildDate:  now.UTC().Format(time.RFC1123Z),
+		ItunesAuthor:   channel.Author,
+		ItunesSummary:  channel.Description,
+		ItunesType:     "episodic",
+		ItunesExplicit: "false",
+		Items:          make([]rssItem, 0, len(items)),
+	}
+	if channel.FeedURL != "" {
+		ch.AtomLink = &atomLink{Href: channel.FeedURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	if channel.ImageURL != "" {
+		ch.ItunesImage = &itunesImage{Href: channel.ImageURL}
+		ch.Image = &rssImage{URL: channel.ImageURL, Title: channel.Title, Link: channel.Link}
+	}
+
+	for _, item := range items {
+		ri := rssItem{
+			Title:             item.Title,
+			Link:              item.Link,
+			GUID:              rssGUID{Value: item.GUID},
+			Description:       cdata{item.Description},
+			Enclosure:         rssEnclosure{URL: item.EnclosureURL, Length: item.EnclosureLength, Type: item.EnclosureType},
+			ItunesTitle:       item.Title,
+			ItunesEpisodeType: "full",
+			ItunesExplicit:    "false",
+		}
+		if !item.Published.IsZero() {
+			ri.PubDate = item.Published.UTC().Format(time.RFC1123Z)
+		}
+		if item.Duration > 0 {
+			ri.ItunesDuration = FormatDuration(float64(item.Duration))
+		}
+		if item.ImageURL != "" {
+			ri.ItunesImage = &itunesImage{Href: item.ImageURL}
+		}
+		if item.ChaptersURL != "" {
+			ri.PodcastChapters = &podcastChapters{URL: item.ChaptersURL, Type: "application/json+chapters"}
+		}
+		if len(item.Chapters) > 0 {
+			psc := &pscChapters{Version: "1.2"}
+			for _, chapter := range item.Chapters {
+				psc.Chapters = append(psc.Chapters, pscChapter{Start: FormatTimestamp(chapter.Start), Title: chapter.Title})
+			}
+			ri.PscChapters = psc
+		}
+		ch.Items = append(ch.Items, ri)
+	}
+
+	doc := rss{
+		Version:   "2.0",
+		ItunesNS:  itunesNamespace,
+		PodcastNS: podcastNamespace,
+		PscNS:     pscNamespace,
+		AtomNS:    atomNamespace,
+		Channel:   ch,
+	}
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal feed: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// jsonChapters is the Podcasting 2.0 JSON chapters format
+type jsonChapters struct {
+	Version  string        `json:"version"`
+	Chapters []jsonChapter `json:"chapters"`
+}
+
+type jsonChapter struct {
+	StartTime float64 `json:"startTime"`
+	Title     string  `json:"title"`
+}
+
+// ChaptersJSON renders chapters in the Podcasting 2.0 JSON chapters format
+func ChaptersJSON(chapters []Chapter) ([]byte, error) {
+	doc := jsonChapters{Version: "1.2.0", Chapters: make([]jsonChapter, 0, len(chapters))}
+	for _, chapter := range chapters {
+		doc.Chapters = append(doc.Chapters, jsonChapter{StartTime: chapter.Start, Title: chapter.Title})
+	}
+	return json.Marshal(doc)
+}
+
+// FormatDuration formats seconds as HH:MM:SS for itunes:duration
+func FormatDuration(seconds float64) string {
+	total := int(math.Round(seconds))
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, total/60%60, total%60)
+}
+
+// FormatTimestamp formats seconds as HH:MM:SS.mmm for Podlove Simple Chapters
+func FormatTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// ParseUploadDate parses a YYYYMMDD or YYYY-MM-DD upload date, returning the zero time if it is invalid
+func ParseUploadDate(date string) time.Time {
+	date = strings.ReplaceAll(date, "-", "")
+	if len(date) < 8 {
+		return time.Time{}
+	}
+	t, err := time.Parse("20060102", date[:8])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,133 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	channel := Channel{
+		Title:       "A Channel",
+		Description: "Videos about <things>",
+		Author:      "A Channel",
+		Link:        "https://www.youtube.com/@achannel",
+		FeedURL:     "https://archive.example/feeds/channels/c1.xml?token=t",
+		ImageURL:    "https://archive.example/api/videos/v1/thumbnail",
+	}
+	items := []Item{
+		{
+			GUID:            "v1",
+			Title:           "First & best",
+			Description:     "About the video",
+			Link:            "https://www.youtube.com/watch?v=v1",
+			Published:       time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			Duration:        3725,
+			EnclosureURL:    "https://archive.example/api/videos/v1/audio?token=t",
+			EnclosureType:   "audio/mp4",
+			EnclosureLength: 1234,
+			Chapters:        []Chapter{{Title: "Intro", Start: 0}, {Title: "Main", Start: 61.5}},
+			ChaptersURL:     "https://archive.example/feeds/channels/c1/chapters/v1.json?token=t",
+		},
+		{
+			GUID:          "v2",
+			Title:         "No chapters",
+			EnclosureURL:  "https://archive.example/api/videos/v2/stream?token=t",
+			EnclosureType: "video/mp4",
+		},
+	}
+
+	data, err := Build(channel, items, time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		`<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"`,
+		`<description><![CDATA[Videos about <things>]]></description>`,
+		`<atom:link href="https://archive.example/feeds/channels/c1.xml?token=t" rel="self" type="application/rss+xml"></atom:link>`,
+		`<itunes:image href="https://archive.example/api/videos/v1/thumbnail"></itunes:image>`,
+		`<title>First &amp; best</title>`,
+		`<guid isPermaLink="false">v1</guid>`,
+		`<pubDate>Mon, 15 Jan 2024 00:00:00 +0000</pubDate>`,
+		`<enclosure url="https://archive.example/api/videos/v1/audio?token=t" length="1234" type="audio/mp4"></enclosure>`,
+		`<itunes:duration>01:02:05</itunes:duration>`,
+		`<podcast:chapters url="https://archive.example/feeds/channels/c1/chapters/v1.json?token=t" type="application/json+chapters"></podcast:chapters>`,
+		`<psc:chapter start="00:01:01.500" title="Main"></psc:chapter>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("feed missing %s\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "<psc:chapters") != 1 {
+		t.Errorf("feed should only have chapters for the first item\n%s", out)
+	}
+
+	// The feed must be well-formed XML
+	var doc struct {
+		Items []struct {
+			GUID string `xml:"guid"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	if len(doc.Items) != 2 {
+		t.Errorf("feed has %d items, want 2", len(doc.Items))
+	}
+}
+
+func TestChaptersJSON(t *testing.T) {
+	data, err := ChaptersJSON([]Chapter{{Title: "Intro", Start: 0}, {Title: "Main", Start: 12.5}})
+	if err != nil {
+		t.Fatalf("ChaptersJSON() error = %v", err)
+	}
+	want := `{"version":"1.2.0","chapters":[{"startTime":0,"title":"Intro"},{"startTime":12.5,"title":"Main"}]}`
+	if string(data) != want {
+		t.Errorf("ChaptersJSON() = %s, want %s", data, want)
+	}
+}
+
+func TestFormatTimes(t *testing.T) {
+	if got := FormatDuration(59.6); got != "00:01:00" {
+		t.Errorf("FormatDuration(59.6) = %q", got)
+	}
+	if got := FormatTimestamp(3723.0456); got != "01:02:03.046" {
+		t.Errorf("FormatTimestamp(3723.0456) = %q", got)
+	}
+}
+
+func TestParseUploadDate(t *testing.T) {
+	want := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, date := range []string{"20240115", "2024-01-15"} {
+		if got := ParseUploadDate(date); !got.Equal(want) {
+			t.Errorf("ParseUploadDate(%q) = %v, want %v", date, got, want)
+		}
+	}
+	if got := ParseUploadDate("2024"); !got.IsZero() {
+		t.Errorf("ParseUploadDate(2024) = %v, want zero", got)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	token := signer.Sign("channel-1")
+
+	if !signer.Verify("channel-1", token) {
+		t.Error("Verify() rejected a valid token")
+	}
+	if signer.Verify("channel-2", token) {
+		t.Error("Verify() accepted a token for another channel")
+	}
+	if signer.Verify("channel-1", "") {
+		t.Error("Verify() accepted an empty token")
+	}
+	if NewSigner([]byte("other")).Verify("channel-1", token) {
+		t.Error("Verify() accepted a token signed with another secret")
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("token %q is not URL safe", token)
+	}
+}
@@ -0,0 +1,34 @@
+package feed
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Signer issues and checks feed access tokens. Podcast apps cannot send auth
+// headers, so access to a channel's feed and media is granted by a token in the
+// URL. Tokens don't expire; changing the secret revokes them all.
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner creates a signer with the given secret
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// Sign returns the access token for a channel's feed
+func (s *Signer) Sign(channelID string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("feed:channel:" + channelID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether token grants access to a channel's feed
+func (s *Signer) Verify(channelID, token string) bool {
+	if token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(s.Sign(channelID)))
+}
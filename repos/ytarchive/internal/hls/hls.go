@@ -0,0 +1,106 @@
+// Package hls packages archived videos for adaptive streaming.
+//
+// A video is transcoded to a ladder of renditions, each a playlist of short
+// MPEG-TS segments cached under hls/ in the video's directory. The master
+// playlist lists the renditions no taller than the video, so players can
+// switch between them as their bandwidth changes instead of stalling on the
+// original file. Renditions are built the first time they are played, or as
+// soon as a video is downloaded for the renditions its channel's Policy names.
+package hls
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+)
+
+// Renditions is the ladder videos are packaged in, tallest first
+var Renditions = []downloader.HLSRendition{
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 128},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+}
+
+// LookupRendition returns the rendition of the ladder with a name
+func LookupRendition(name string) (downloader.HLSRendition, bool) {
+	for _, rendition := range Renditions {
+		if rendition.Name == name {
+			return rendition, true
+		}
+	}
+	return downloader.HLSRendition{}, false
+}
+
+// SelectRenditions returns the renditions of the ladder suited to a video of
+// the given height: none taller than the video, but always the smallest so
+// every video can be streamed. A height of 0, for videos that could not be
+// probed, selects the whole ladder.
+func SelectRenditions(height int) []downloader.HLSRendition {
+	if height <= 0 {
+		return Renditions
+	}
+
+	var selected []downloader.HLSRendition
+	for _, rendition := range Renditions {
+		if rendition.Height <= height {
+			selected = append(selected, rendition)
+		}
+	}
+	if len(selected) == 0 {
+		selected = Renditions[len(Renditions)-1:]
+	}
+	return selected
+}
+
+// Policy decides which renditions of a channel's videos are built as soon
+// as they are downloaded. Other renditions are built when first played.
+type Policy struct {
+	Prebuild []string `json:"prebuild,omitempty"` // names of renditions, e.g. "720p"
+}
+
+// IsZero reports whether the policy builds nothing ahead of time
+func (p Policy) IsZero() bool {
+	return len(p.Prebuild) == 0
+}
+
+// Validate checks that the policy only names renditions of the ladder
+func (p Policy) Validate() error {
+	for _, name := range p.Prebuild {
+		if _, ok := LookupRendition(name); !ok {
+			return fmt.Errorf("unknown rendition %q, expected one of %s", name, strings.Join(RenditionNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// RenditionNames returns the names of the renditions of the ladder
+func RenditionNames() []string {
+	names := make([]string, len(Renditions))
+	for i, rendition := range Renditions {
+		names[i] = rendition.Name
+	}
+	return names
+}
+
+// MasterPlaylist returns a master playlist of renditions, each at
+// {name}/index.m3u8 relative to it. The frame size of each rendition is
+// given when the size of the video is known.
+func MasterPlaylist(renditions []downloader.HLSRendition, width, height int) []byte {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for _, rendition := range renditions {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", rendition.Bandwidth())
+		if width > 0 && height > 0 {
+			// ffmpeg's scale=-2:h rounds the width to an even number
+			scaled := (width*rendition.Height/height + 1) / 2 * 2
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", scaled, rendition.Height)
+		}
+		fmt.Fprintf(&b, ",NAME=\"%s\"\n", rendition.Name)
+		fmt.Fprintf(&b, "%s/%s\n", rendition.Name, playlistName)
+	}
+	return []byte(b.String())
+}
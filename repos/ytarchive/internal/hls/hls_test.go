@@ -0,0 +1,75 @@
+package hls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectRenditions(t *testing.T) {
+	tests := []struct {
+		height int
+		want   []string
+	}{
+		{2160, []string{"1080p", "720p", "480p", "360p"}},
+		{1080, []string{"1080p", "720p", "480p", "360p"}},
+		{720, []string{"720p", "480p", "360p"}},
+		{544, []string{"480p", "360p"}},
+		{240, []string{"360p"}},
+		{0, []string{"1080p", "720p", "480p", "360p"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, rendition := range SelectRenditions(tt.height) {
+			got = append(got, rendition.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SelectRenditions(%d) = %v, want %v", tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"empty", Policy{}, false},
+		{"known renditions", Policy{Prebuild: []string{"720p", "360p"}}, false},
+		{"unknown rendition", Policy{Prebuild: []string{"720p", "4k"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	want := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=3124000,RESOLUTION=1280x720,NAME="720p"
+720p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1626000,RESOLUTION=854x480,NAME="480p"
+480p/index.m3u8
+`
+	if got := string(MasterPlaylist(Renditions[1:3], 1920, 1080)); got != want {
+		t.Errorf("MasterPlaylist() = %q, want %q", got, want)
+	}
+
+	// Without the size of the video the frame sizes are left out
+	want = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=952000,NAME="360p"
+360p/index.m3u8
+`
+	if got := string(MasterPlaylist(Renditions[3:], 0, 0)); got != want {
+		t.Errorf("MasterPlaylist() = %q, want %q", got, want)
+	}
+}
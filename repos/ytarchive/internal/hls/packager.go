@@ -0,0 +1,298 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+const (
+	dirName      = "hls" // cache directory inside a video's directory
+	masterName   = "master.m3u8"
+	playlistName = "index.m3u8"
+	endList      = "#EXT-X-ENDLIST"
+
+	// firstSegmentWait bounds how long a request for a rendition that is
+	// not built yet waits for its first segment
+	firstSegmentWait = 90 * time.Second
+	pollInterval     = 500 * time.Millisecond
+)
+
+// Errors returned for requests the packager cannot serve
+var (
+	ErrNoSource         = errors.New("video file not found")
+	ErrUnknownRendition = errors.New("unknown rendition")
+	ErrNotFound         = errors.New("segment not found")
+)
+
+// sourcePatterns are the video files a video is packaged from, in order of preference
+var sourcePatterns = []string{"video.mp4", "video.mkv", "video.webm", "*.mp4", "*.mkv", "*.webm"}
+
+// segmentPattern matches the names of the segments SegmentHLS writes
+var segmentPattern = regexp.MustCompile(`^segment_\d+\.ts$`)
+
+// Packager packages the videos of a local archive for HLS and caches the
+// renditions under their video directories
+type Packager struct {
+	root   string
+	merger *downloader.Merger
+	probe  func(path string) (width, height int, err error)
+
+	mu       sync.Mutex
+	building map[string]chan struct{} // playlist path -> closed when its build ends
+}
+
+// NewPackager creates a packager for the archive at root that transcodes with merger
+func NewPackager(root string, merger *downloader.Merger) *Packager {
+	return &Packager{
+		root:   root,
+		merger: merger,
+		probe: func(path string) (int, int, error) {
+			_, width, height, err := downloader.GetVideoInfo(path)
+			return width, height, err
+		},
+		building: make(map[string]chan struct{}),
+	}
+}
+
+// Master returns the master playlist of a video. The renditions it lists
+// are chosen from the size of the video file the first time and cached.
+func (p *Packager) Master(channelID, videoID string) ([]byte, error) {
+	dir, err := p.videoDir(channelID, videoID)
+	if err != nil {
+		return nil, err
+	}
+	masterPath := filepath.Join(dir, dirName, masterName)
+	if data, err := os.ReadFile(masterPath); err == nil {
+		return data, nil
+	}
+
+	source, err := sourceFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := p.probe(source)
+	if err != nil {
+		logging.Warn("failed to probe video size, listing every rendition", "path", source, "error", err)
+		width, height = 0, 0
+	}
+
+	data := MasterPlaylist(SelectRenditions(height), width, height)
+	if err := os.MkdirAll(filepath.Dir(masterPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create hls directory: %w", err)
+	}
+	if err := os.WriteFile(masterPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to cache master playlist: %w", err)
+	}
+	return data, nil
+}
+
+// Playlist returns the path of the playlist of a rendition, starting to
+// build the rendition if it is not cached. A rendition being built is
+// returned once its first segment is ready; its playlist grows as the rest
+// is encoded.
+func (p *Packager) Playlist(ctx context.Context, channelID, videoID, name string) (string, error) {
+	playlistPath, done, err := p.ensure(channelID, videoID, name)
+	if err != nil || done == nil {
+		return playlistPath, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, firstSegmentWait)
+	defer cancel()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if hasSegment(playlistPath) {
+			return playlistPath, nil
+		}
+		select {
+		case <-done:
+			if isComplete(playlistPath) {
+				return playlistPath, nil
+			}
+			return "", fmt.Errorf("failed to build rendition %s of %s", name, videoID)
+		case <-ctx.Done():
+			return "", fmt.Errorf("rendition %s of %s is not ready: %w", name, videoID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Build builds a rendition of a video unless it is cached, and waits until it is complete
+func (p *Packager) Build(ctx context.Context, channelID, videoID, name string) error {
+	playlistPath, done, err := p.ensure(channelID, videoID, name)
+	if err != nil || done == nil {
+		return err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !isComplete(playlistPath) {
+		return fmt.Errorf("failed to build rendition %s of %s", name, videoID)
+	}
+	return nil
+}
+
+// Prebuild builds the renditions a policy names, one after another, skipping
+// those taller than the video
+func (p *Packager) Prebuild(ctx context.Context, channelID, videoID string, policy Policy) error {
+	if policy.IsZero() {
+		return nil
+	}
+	master, err := p.Master(channelID, videoID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range policy.Prebuild {
+		if !bytes.Contains(master, []byte("\n"+name+"/"+playlistName+"\n")) {
+			continue
+		}
+		if err := p.Build(ctx, channelID, videoID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SegmentPath returns the path of a cached segment of a rendition
+func (p *Packager) SegmentPath(channelID, videoID, name, segment string) (string, error) {
+	dir, err := p.videoDir(channelID, videoID)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := LookupRendition(name); !ok {
+		return "", ErrUnknownRendition
+	}
+	if !segmentPattern.MatchString(segment) {
+		return "", ErrNotFound
+	}
+
+	segmentPath := filepath.Join(dir, dirName, name, segment)
+	if _, err := os.Stat(segmentPath); err != nil {
+		return "", ErrNotFound
+	}
+	return segmentPath, nil
+}
+
+// Remove deletes the cached renditions of a video
+func (p *Packager) Remove(channelID, videoID string) error {
+	dir, err := p.videoDir(channelID, videoID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(dir, dirName))
+}
+
+// ensure returns the playlist path of a rendition and, unless it is cached,
+// a channel closed when the build that produces it ends
+func (p *Packager) ensure(channelID, videoID, name string) (string, chan struct{}, error) {
+	dir, err := p.videoDir(channelID, videoID)
+	if err != nil {
+		return "", nil, err
+	}
+	rendition, ok := LookupRendition(name)
+	if !ok {
+		return "", nil, ErrUnknownRendition
+	}
+
+	playlistPath := filepath.Join(dir, dirName, name, playlistName)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if done, ok := p.building[playlistPath]; ok {
+		return playlistPath, done, nil
+	}
+	if isComplete(playlistPath) {
+		return playlistPath, nil, nil
+	}
+
+	source, err := sourceFile(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	done := make(chan struct{})
+	p.building[playlistPath] = done
+	go p.build(source, playlistPath, rendition, done)
+	return playlistPath, done, nil
+}
+
+// build packages a rendition, replacing what an interrupted build left behind.
+// Builds outlive the requests that start them; the merger's timeout bounds them.
+func (p *Packager) build(source, playlistPath string, rendition downloader.HLSRendition, done chan struct{}) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.building, playlistPath)
+		p.mu.Unlock()
+		close(done)
+	}()
+
+	renditionDir := filepath.Dir(playlistPath)
+	if err := os.RemoveAll(renditionDir); err != nil {
+		logging.Warn("failed to clear hls rendition", "path", renditionDir, "error", err)
+		return
+	}
+
+	logging.Info("building hls rendition", "source", source, "rendition", rendition.Name)
+	result := p.merger.SegmentHLS(context.Background(), source, playlistPath, rendition)
+	if !result.Success {
+		logging.Warn("failed to build hls rendition", "source", source, "rendition", rendition.Name, "error", result.Error)
+		os.RemoveAll(renditionDir)
+		return
+	}
+	logging.Info("hls rendition built",
+		"source", source,
+		"rendition", rendition.Name,
+		"bytes", result.FileSize,
+		"duration", result.Duration.String(),
+	)
+}
+
+// videoDir returns the directory of a video in the archive
+func (p *Packager) videoDir(channelID, videoID string) (string, error) {
+	for _, id := range []string{channelID, videoID} {
+		if id == "" || id == "." || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+			return "", ErrNoSource
+		}
+	}
+	return filepath.Join(p.root, filepath.FromSlash(storage.VideoKey(channelID, videoID, ""))), nil
+}
+
+// sourceFile returns the video file in a video directory
+func sourceFile(dir string) (string, error) {
+	for _, pattern := range sourcePatterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err == nil && len(matches) > 0 {
+			return matches[0], nil
+		}
+	}
+	return "", ErrNoSource
+}
+
+// isComplete reports whether a playlist has been written to the end
+func isComplete(playlistPath string) bool {
+	data, err := os.ReadFile(playlistPath)
+	return err == nil && bytes.Contains(data, []byte(endList))
+}
+
+// hasSegment reports whether a playlist lists a segment yet
+func hasSegment(playlistPath string) bool {
+	data, err := os.ReadFile(playlistPath)
+	return err == nil && bytes.Contains(data, []byte(".ts"))
+}
@@ -0,0 +1,116 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+)
+
+// setupTestPackager returns a packager for an archive holding one 720p video
+func setupTestPackager(t *testing.T) (*Packager, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "channels", "ch1", "videos", "v1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "video.mkv"), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packager := NewPackager(root, downloader.NewMerger())
+	packager.probe = func(path string) (int, int, error) {
+		return 1280, 720, nil
+	}
+	return packager, dir
+}
+
+func TestPackager_Master(t *testing.T) {
+	packager, dir := setupTestPackager(t)
+
+	master, err := packager.Master("ch1", "v1")
+	if err != nil {
+		t.Fatalf("Master() error = %v", err)
+	}
+	if strings.Contains(string(master), "1080p") || !strings.Contains(string(master), "720p/index.m3u8") {
+		t.Errorf("Master() = %q, want renditions up to 720p", master)
+	}
+
+	// The playlist is cached, so the video is not probed again
+	packager.probe = func(path string) (int, int, error) {
+		t.Error("cached master playlist was rebuilt")
+		return 0, 0, nil
+	}
+	if cached, err := packager.Master("ch1", "v1"); err != nil || string(cached) != string(master) {
+		t.Errorf("Master() = %q, %v, want the cached playlist", cached, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hls", "master.m3u8")); err != nil {
+		t.Errorf("master playlist not cached: %v", err)
+	}
+
+	for _, ids := range [][2]string{{"ch1", "missing"}, {"ch1", "../v1"}, {"..", "v1"}} {
+		if _, err := packager.Master(ids[0], ids[1]); !errors.Is(err, ErrNoSource) {
+			t.Errorf("Master(%q, %q) error = %v, want ErrNoSource", ids[0], ids[1], err)
+		}
+	}
+}
+
+func TestPackager_CachedRendition(t *testing.T) {
+	packager, dir := setupTestPackager(t)
+
+	renditionDir := filepath.Join(dir, "hls", "720p")
+	if err := os.MkdirAll(renditionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	playlist := "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:6.0,\nsegment_00000.ts\n#EXT-X-ENDLIST\n"
+	if err := os.WriteFile(filepath.Join(renditionDir, "index.m3u8"), []byte(playlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(renditionDir, "segment_00000.ts"), []byte("ts"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A complete rendition is served as it is, without building it again
+	ctx := context.Background()
+	path, err := packager.Playlist(ctx, "ch1", "v1", "720p")
+	if err != nil || path != filepath.Join(renditionDir, "index.m3u8") {
+		t.Errorf("Playlist() = %q, %v, want the cached playlist", path, err)
+	}
+	if err := packager.Build(ctx, "ch1", "v1", "720p"); err != nil {
+		t.Errorf("Build() error = %v for a cached rendition", err)
+	}
+	if _, err := packager.Playlist(ctx, "ch1", "v1", "4k"); !errors.Is(err, ErrUnknownRendition) {
+		t.Errorf("Playlist() error = %v, want ErrUnknownRendition", err)
+	}
+
+	tests := []struct {
+		rendition string
+		segment   string
+		wantErr   error
+	}{
+		{"720p", "segment_00000.ts", nil},
+		{"720p", "segment_00001.ts", ErrNotFound},
+		{"720p", "index.m3u8", ErrNotFound},
+		{"720p", "../../video.mkv", ErrNotFound},
+		{"4k", "segment_00000.ts", ErrUnknownRendition},
+	}
+	for _, tt := range tests {
+		_, err := packager.SegmentPath("ch1", "v1", tt.rendition, tt.segment)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("SegmentPath(%q, %q) error = %v, want %v", tt.rendition, tt.segment, err, tt.wantErr)
+		}
+	}
+
+	if err := packager.Remove("ch1", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hls")); !os.IsNotExist(err) {
+		t.Errorf("Remove() left the hls directory: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package hls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// PolicyFunc returns the HLS policy of a channel
+type PolicyFunc func(ctx context.Context, channelID string) (Policy, error)
+
+// prebuildJob is a video whose policy renditions are to be built
+type prebuildJob struct {
+	channelID string
+	videoID   string
+}
+
+// Prebuilder builds the renditions channel policies name in the background,
+// one video at a time, so packaging does not starve streaming of CPU
+type Prebuilder struct {
+	packager *Packager
+	policy   PolicyFunc
+
+	mu      sync.Mutex
+	pending []prebuildJob
+	queued  map[prebuildJob]bool
+	wake    chan struct{}
+}
+
+// NewPrebuilder creates a prebuilder that looks up channel policies with policy
+func NewPrebuilder(packager *Packager, policy PolicyFunc) *Prebuilder {
+	return &Prebuilder{
+		packager: packager,
+		policy:   policy,
+		queued:   make(map[prebuildJob]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Run builds queued videos until ctx is cancelled
+func (b *Prebuilder) Run(ctx context.Context) {
+	for {
+		job, ok := b.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.wake:
+				continue
+			}
+		}
+
+		policy, err := b.policy(ctx, job.channelID)
+		if err != nil {
+			logging.Warn("failed to get hls policy", "channel_id", job.channelID, "error", err)
+			continue
+		}
+		if err := b.packager.Prebuild(ctx, job.channelID, job.videoID, policy); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logging.Warn("failed to prebuild hls renditions",
+				"channel_id", job.channelID,
+				"video_id", job.videoID,
+				"error", err,
+			)
+		}
+	}
+}
+
+// VideoCompleted drops the renditions cached for an earlier download of a
+// video and queues the ones its channel's policy names. It is called with the
+// video's ID and the path of its file.
+func (b *Prebuilder) VideoCompleted(videoID, filePath string) {
+	channelID := b.channelIDFromPath(filePath, videoID)
+	if channelID == "" {
+		logging.Warn("archived video is outside the archive, not packaging it for hls",
+			"video_id", videoID,
+			"file_path", filePath,
+		)
+		return
+	}
+
+	if err := b.packager.Remove(channelID, videoID); err != nil {
+		logging.Warn("failed to remove stale hls renditions", "channel_id", channelID, "video_id", videoID, "error", err)
+	}
+	b.queue(prebuildJob{channelID: channelID, videoID: videoID})
+}
+
+// QueueChannel queues every video of a channel in the archive, so a policy
+// applies to videos downloaded before it was set. Returns the number queued.
+func (b *Prebuilder) QueueChannel(channelID string) (int, error) {
+	if channelID == "" || strings.ContainsAny(channelID, `/\`) || strings.Contains(channelID, "..") {
+		return 0, ErrNoSource
+	}
+
+	entries, err := os.ReadDir(filepath.Join(b.packager.root, "channels", channelID, "videos"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var queued int
+	for _, entry := range entries {
+		if entry.IsDir() {
+			b.queue(prebuildJob{channelID: channelID, videoID: entry.Name()})
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// queue adds a job unless it is already waiting
+func (b *Prebuilder) queue(job prebuildJob) {
+	b.mu.Lock()
+	if !b.queued[job] {
+		b.queued[job] = true
+		b.pending = append(b.pending, job)
+	}
+	b.mu.Unlock()
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the oldest waiting job
+func (b *Prebuilder) next() (prebuildJob, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		return prebuildJob{}, false
+	}
+	job := b.pending[0]
+	b.pending = b.pending[1:]
+	delete(b.queued, job)
+	return job, true
+}
+
+// channelIDFromPath returns the channel ID of a file stored at
+// {archive}/channels/{channel_id}/videos/{video_id}/..., or "" for other paths
+func (b *Prebuilder) channelIDFromPath(filePath, videoID string) string {
+	rel, err := filepath.Rel(b.packager.root, filePath)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 4 || parts[0] != "channels" || parts[2] != "videos" || parts[3] != videoID {
+		return ""
+	}
+	return parts[1]
+}
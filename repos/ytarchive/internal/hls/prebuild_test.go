@@ -0,0 +1,47 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrebuilder_Queue(t *testing.T) {
+	packager, dir := setupTestPackager(t)
+	prebuilder := NewPrebuilder(packager, nil)
+
+	if err := os.MkdirAll(filepath.Join(dir, "hls", "720p"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new download replaces the renditions of the old one
+	prebuilder.VideoCompleted("v1", filepath.Join(dir, "video.mkv"))
+	if _, err := os.Stat(filepath.Join(dir, "hls")); !os.IsNotExist(err) {
+		t.Errorf("VideoCompleted() kept stale renditions: %v", err)
+	}
+	prebuilder.VideoCompleted("v1", "/elsewhere/channels/ch1/videos/v1/video.mkv")
+	prebuilder.VideoCompleted("v2", filepath.Join(dir, "video.mkv"))
+
+	// Videos already waiting are not queued twice
+	if n, err := prebuilder.QueueChannel("ch1"); err != nil || n != 1 {
+		t.Errorf("QueueChannel() = %d, %v, want 1", n, err)
+	}
+	if n, err := prebuilder.QueueChannel("unknown"); err != nil || n != 0 {
+		t.Errorf("QueueChannel() = %d, %v, want 0 for a channel without videos", n, err)
+	}
+	if _, err := prebuilder.QueueChannel("../ch1"); err == nil {
+		t.Error("QueueChannel() accepted a path outside the archive")
+	}
+
+	var jobs []prebuildJob
+	for {
+		job, ok := prebuilder.next()
+		if !ok {
+			break
+		}
+		jobs = append(jobs, job)
+	}
+	if len(jobs) != 1 || jobs[0] != (prebuildJob{channelID: "ch1", videoID: "v1"}) {
+		t.Errorf("queued %+v, want only ch1/v1", jobs)
+	}
+}
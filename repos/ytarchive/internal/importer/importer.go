@@ -0,0 +1,400 @@
+// Package importer adopts videos downloaded with yt-dlp into the archive. It
+// reads the .info.json files yt-dlp --write-info-json leaves next to each
+// video, places the media, thumbnail and subtitles in the archive layout
+//
+//	channels/{channel_id}/videos/{video_id}/
+//	├── video.mp4
+//	├── metadata.json
+//	├── info.json
+//	├── thumbnail.jpg
+//	└── subtitles.en.vtt
+//
+// and records the videos as downloaded, so syncs do not download them again.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// maxErrors bounds the errors kept in a Result
+const maxErrors = 100
+
+// Mode selects how media files are placed into the archive
+type Mode string
+
+const (
+	// ModeCopy copies files, leaving the yt-dlp directory untouched
+	ModeCopy Mode = "copy"
+	// ModeHardlink hardlinks files into the archive, so they take no extra
+	// space. The yt-dlp directory must be on the archive's filesystem.
+	ModeHardlink Mode = "hardlink"
+	// ModeMove moves files into the archive, copying them when they are on
+	// another filesystem
+	ModeMove Mode = "move"
+)
+
+// ParseMode parses an import mode, defaulting to hardlinks
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(strings.ToLower(mode)) {
+	case "", ModeHardlink:
+		return ModeHardlink, nil
+	case ModeCopy:
+		return ModeCopy, nil
+	case ModeMove:
+		return ModeMove, nil
+	default:
+		return "", fmt.Errorf("unknown import mode %q (want hardlink, copy or move)", mode)
+	}
+}
+
+// Catalog records imported channels and videos
+type Catalog interface {
+	// Channel returns the archive ID of a YouTube channel, adding the channel
+	// to the archive if it is not there yet
+	Channel(ctx context.Context, channel *youtube.Channel) (string, error)
+	// Archived reports whether a video has already been downloaded
+	Archived(ctx context.Context, channelID, videoID string) (bool, error)
+	// AddVideo records an imported video as downloaded
+	AddVideo(ctx context.Context, video *Imported) error
+}
+
+// Imported is a video placed in the archive
+type Imported struct {
+	ChannelID string             `json:"channel_id"`
+	Info      *youtube.VideoInfo `json:"info"`
+	FilePath  string             `json:"file_path"`
+	FileSize  int64              `json:"file_size"`
+	Checksum  string             `json:"checksum"`
+}
+
+// Options configures an import
+type Options struct {
+	Mode   Mode `json:"mode"`
+	DryRun bool `json:"dry_run"`
+
+	// Progress is called with the result so far after each video
+	Progress func(Result) `json:"-"`
+}
+
+// Result summarizes an import
+type Result struct {
+	Path       string     `json:"path"`
+	Mode       Mode       `json:"mode"`
+	DryRun     bool       `json:"dry_run"`
+	Found      int        `json:"found"`
+	Imported   int        `json:"imported"`
+	Skipped    int        `json:"skipped"` // already archived, or unusable info files
+	Failed     int        `json:"failed"`
+	Bytes      int64      `json:"bytes"`
+	Channels   []string   `json:"channels"` // archive IDs of the channels videos were imported into
+	Errors     []Skipped  `json:"errors,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// addError records a file that was skipped or failed
+func (r *Result) addError(path, reason string) {
+	if len(r.Errors) < maxErrors {
+		r.Errors = append(r.Errors, Skipped{Path: path, Reason: reason})
+	}
+}
+
+// Importer adopts yt-dlp downloads into an archive
+type Importer struct {
+	backend storage.Backend
+	catalog Catalog
+}
+
+// New creates an importer that stores files in backend and records them in catalog
+func New(backend storage.Backend, catalog Catalog) *Importer {
+	return &Importer{backend: backend, catalog: catalog}
+}
+
+// Import scans root for yt-dlp downloads and imports every video that is not
+// archived yet. Videos that fail are logged and counted; the import goes on.
+func (im *Importer) Import(ctx context.Context, root string, opts Options) (*Result, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeHardlink
+	}
+	result := &Result{
+		Path:      root,
+		Mode:      opts.Mode,
+		DryRun:    opts.DryRun,
+		Channels:  []string{},
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		finished := time.Now()
+		result.FinishedAt = &finished
+	}()
+
+	if opts.Mode == ModeHardlink {
+		if _, ok := im.backend.(*storage.LocalBackend); !ok {
+			return result, fmt.Errorf("hardlink imports need local storage, use copy or move")
+		}
+	}
+
+	videos, skipped, err := Scan(ctx, root)
+	if err != nil {
+		return result, err
+	}
+	result.Found = len(videos)
+	result.Skipped = len(skipped)
+	for _, s := range skipped {
+		result.addError(s.Path, s.Reason)
+	}
+
+	channels := make(map[string]string) // YouTube channel ID to archive ID
+	for i := range videos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		video := &videos[i]
+
+		imported, err := im.importVideo(ctx, video, channels, opts)
+		switch {
+		case errors.Is(err, errArchived):
+			result.Skipped++
+		case err != nil:
+			logging.Warn("failed to import video",
+				"video_id", video.Info.ID,
+				"path", video.MediaPath,
+				"error", err,
+			)
+			result.Failed++
+			result.addError(video.MediaPath, err.Error())
+		default:
+			result.Imported++
+			result.Bytes += imported.FileSize
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(*result)
+		}
+	}
+
+	for _, channelID := range channels {
+		result.Channels = append(result.Channels, channelID)
+	}
+	sort.Strings(result.Channels)
+	return result, nil
+}
+
+// errArchived is returned by importVideo for videos that are already archived
+var errArchived = errors.New("video already archived")
+
+// importVideo places one video in the archive and records it
+func (im *Importer) importVideo(ctx context.Context, video *Video, channels map[string]string, opts Options) (*Imported, error) {
+	info := video.Info
+	stat, err := os.Stat(video.MediaPath)
+	if err != nil {
+		return nil, err
+	}
+	imported := &Imported{Info: info, FileSize: stat.Size()}
+
+	if opts.DryRun {
+		logging.Info("would import video", "video_id", info.ID, "path", video.MediaPath)
+		return imported, nil
+	}
+
+	channelID, ok := channels[info.Channel.ID]
+	if !ok {
+		channelID, err = im.catalog.Channel(ctx, &info.Channel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add channel %s: %w", info.Channel.ID, err)
+		}
+		channels[info.Channel.ID] = channelID
+	}
+	imported.ChannelID = channelID
+
+	archived, err := im.catalog.Archived(ctx, channelID, info.ID)
+	if err != nil {
+		return nil, err
+	}
+	if archived {
+		return nil, errArchived
+	}
+
+	// Sidecar files first: a video file in place marks a finished import
+	if err := im.placeSidecars(ctx, channelID, video); err != nil {
+		return nil, err
+	}
+
+	key := storage.VideoKey(channelID, info.ID, "video"+strings.ToLower(filepath.Ext(video.MediaPath)))
+	checksum, err := im.placeMedia(ctx, video.MediaPath, key, stat.Size(), opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	imported.FilePath = im.backend.Location(key)
+	imported.Checksum = checksum
+
+	if _, err := storage.Deduplicate(ctx, im.backend, key, checksum, stat.Size()); err != nil {
+		logging.Warn("failed to deduplicate video", "video_id", info.ID, "error", err)
+	}
+
+	if err := im.catalog.AddVideo(ctx, imported); err != nil {
+		return nil, fmt.Errorf("failed to record video: %w", err)
+	}
+
+	if opts.Mode == ModeMove {
+		removeSources(video)
+	}
+	logging.Info("imported video",
+		"video_id", info.ID,
+		"channel_id", channelID,
+		"file_path", imported.FilePath,
+		"file_size", imported.FileSize,
+	)
+	return imported, nil
+}
+
+// placeMedia puts a video file in the archive and returns its SHA-256
+func (im *Importer) placeMedia(ctx context.Context, src, key string, size int64, mode Mode) (string, error) {
+	if local, ok := im.backend.(*storage.LocalBackend); ok && mode != ModeCopy {
+		dest := local.Location(key)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		os.Remove(dest)
+
+		var err error
+		if mode == ModeHardlink {
+			err = os.Link(src, dest)
+		} else {
+			err = os.Rename(src, dest)
+		}
+		if err == nil {
+			return hashFile(dest)
+		}
+		if mode == ModeHardlink {
+			return "", fmt.Errorf("failed to hardlink %s: %w", src, err)
+		}
+		// Files cannot be renamed across filesystems; copy them instead
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hashing := storage.NewHashingReader(f)
+	if err := im.backend.Put(ctx, key, hashing, size); err != nil {
+		return "", err
+	}
+	return hashing.Checksum(), nil
+}
+
+// placeSidecars copies the info file, thumbnail and subtitles of a video and
+// writes its metadata.json
+func (im *Importer) placeSidecars(ctx context.Context, channelID string, video *Video) error {
+	info := video.Info
+	files := map[string]string{"info.json": video.InfoPath}
+	if video.Thumbnail != "" {
+		files["thumbnail"+strings.ToLower(filepath.Ext(video.Thumbnail))] = video.Thumbnail
+	}
+	for _, subtitle := range video.Subtitles {
+		files["subtitles."+subtitle.Language+subtitleExtension] = subtitle.Path
+	}
+
+	for name, src := range files {
+		if err := im.copyFile(ctx, src, storage.VideoKey(channelID, info.ID, name)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(metadataJSON(channelID, video), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	key := storage.VideoKey(channelID, info.ID, "metadata.json")
+	if err := im.backend.Put(ctx, key, strings.NewReader(string(data)), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to write metadata.json: %w", err)
+	}
+	return nil
+}
+
+// copyFile copies a local file into the archive
+func (im *Importer) copyFile(ctx context.Context, src, key string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return im.backend.Put(ctx, key, f, stat.Size())
+}
+
+// metadataJSON builds the metadata.json the worker writes for a download
+func metadataJSON(channelID string, video *Video) map[string]interface{} {
+	info := video.Info
+	metadata := map[string]interface{}{
+		"id":            info.ID,
+		"title":         info.Title,
+		"description":   info.Description,
+		"duration":      info.Duration,
+		"upload_date":   info.UploadDate,
+		"channel_id":    channelID,
+		"channel_name":  info.Channel.Name,
+		"view_count":    info.ViewCount,
+		"thumbnail_url": info.ThumbnailURL,
+		"kind":          info.Kind,
+		"imported_from": video.InfoPath,
+		"imported_at":   time.Now().Format(time.RFC3339),
+	}
+	if len(info.Chapters) > 0 {
+		metadata["chapters"] = info.Chapters
+	}
+	if len(info.Tags) > 0 {
+		metadata["tags"] = info.Tags
+	}
+	return metadata
+}
+
+// removeSources deletes the yt-dlp files of a moved video
+func removeSources(video *Video) {
+	paths := []string{video.MediaPath, video.InfoPath, video.Thumbnail}
+	for _, subtitle := range video.Subtitles {
+		paths = append(paths, subtitle.Path)
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			logging.Warn("failed to remove imported file", "path", p, "error", err)
+		}
+	}
+}
+
+// hashFile returns the SHA-256 of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hashing := storage.NewHashingReader(f)
+	if _, err := io.Copy(io.Discard, hashing); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hashing.Checksum(), nil
+}
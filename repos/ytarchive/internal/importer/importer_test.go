@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// fakeCatalog records imports in memory
+type fakeCatalog struct {
+	channels map[string]string // YouTube channel ID to archive ID
+	videos   map[string]*Imported
+}
+
+func newFakeCatalog() *fakeCatalog {
+	return &fakeCatalog{channels: make(map[string]string), videos: make(map[string]*Imported)}
+}
+
+func (c *fakeCatalog) Channel(ctx context.Context, channel *youtube.Channel) (string, error) {
+	if id, ok := c.channels[channel.ID]; ok {
+		return id, nil
+	}
+	id := "archive-" + channel.ID
+	c.channels[channel.ID] = id
+	return id, nil
+}
+
+func (c *fakeCatalog) Archived(ctx context.Context, channelID, videoID string) (bool, error) {
+	_, ok := c.videos[channelID+"/"+videoID]
+	return ok, nil
+}
+
+func (c *fakeCatalog) AddVideo(ctx context.Context, video *Imported) error {
+	c.videos[video.ChannelID+"/"+video.Info.ID] = video
+	return nil
+}
+
+// writeDownload writes the files yt-dlp leaves for one video and returns their base path
+func writeDownload(t *testing.T, dir, id, channelID, uploadDate string, files ...string) string {
+	t.Helper()
+	base := filepath.Join(dir, "Title ["+id+"]")
+	info := map[string]interface{}{
+		"id":          id,
+		"title":       "Title " + id,
+		"upload_date": uploadDate,
+		"channel_id":  channelID,
+		"channel":     "Channel " + channelID,
+		"ext":         "mp4",
+		"chapters":    []map[string]interface{}{{"title": "Intro", "start_time": 0, "end_time": 10}},
+	}
+	data, _ := json.Marshal(info)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".info.json", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range files {
+		if err := os.WriteFile(base+suffix, []byte(id+suffix), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	writeDownload(t, filepath.Join(root, "a"), "newer", "UC1", "20240201", ".mp4", ".webp", ".en.vtt", ".de.vtt", ".en.srt")
+	writeDownload(t, filepath.Join(root, "b"), "older", "UC1", "20230101", ".mkv")
+	writeDownload(t, filepath.Join(root, "b"), "missing", "UC1", "20230101")
+	os.WriteFile(filepath.Join(root, "UC1.info.json"), []byte(`{"_type": "playlist", "id": "UC1"}`), 0644)
+	os.WriteFile(filepath.Join(root, "broken.info.json"), []byte(`{`), 0644)
+
+	videos, skipped, err := Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(videos) != 2 || videos[0].Info.ID != "older" || videos[1].Info.ID != "newer" {
+		t.Fatalf("Scan() = %+v, want older then newer", videos)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("Scan() skipped %+v, want the broken and the missing video", skipped)
+	}
+
+	newer := videos[1]
+	if !strings.HasSuffix(newer.MediaPath, ".mp4") || !strings.HasSuffix(newer.Thumbnail, ".webp") {
+		t.Errorf("Scan() found media %q and thumbnail %q", newer.MediaPath, newer.Thumbnail)
+	}
+	if len(newer.Subtitles) != 2 || newer.Subtitles[0].Language != "de" || newer.Subtitles[1].Language != "en" {
+		t.Errorf("Scan() found subtitles %+v, want de and en", newer.Subtitles)
+	}
+	if !strings.HasSuffix(videos[0].MediaPath, ".mkv") {
+		t.Errorf("Scan() did not fall back to other containers: %q", videos[0].MediaPath)
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	downloads := t.TempDir()
+	archive := t.TempDir()
+	base := writeDownload(t, downloads, "vid1", "UC1", "20240101", ".mp4", ".jpg", ".en.vtt")
+	writeDownload(t, downloads, "vid2", "UC2", "20240102", ".webm")
+
+	catalog := newFakeCatalog()
+	backend := storage.NewLocalBackend(archive)
+	im := New(backend, catalog)
+
+	var progress int
+	result, err := im.Import(ctx, downloads, Options{Mode: ModeHardlink, Progress: func(Result) { progress++ }})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if result.Found != 2 || result.Imported != 2 || result.Failed != 0 || progress != 2 {
+		t.Fatalf("Import() = %+v after %d progress calls", result, progress)
+	}
+	if len(result.Channels) != 2 {
+		t.Errorf("Import() channels = %v", result.Channels)
+	}
+
+	video := catalog.videos["archive-UC1/vid1"]
+	if video == nil {
+		t.Fatalf("video was not recorded: %v", catalog.videos)
+	}
+	videoDir := filepath.Join(archive, "channels", "archive-UC1", "videos", "vid1")
+	if video.FilePath != filepath.Join(videoDir, "video.mp4") || video.FileSize != int64(len("vid1.mp4")) {
+		t.Errorf("recorded %s (%d bytes)", video.FilePath, video.FileSize)
+	}
+	if len(video.Checksum) != 64 {
+		t.Errorf("recorded checksum %q", video.Checksum)
+	}
+
+	src, _ := os.Stat(base + ".mp4")
+	dst, _ := os.Stat(video.FilePath)
+	if !os.SameFile(src, dst) {
+		t.Error("hardlink import copied the video")
+	}
+	for _, name := range []string{"metadata.json", "info.json", "thumbnail.jpg", "subtitles.en.vtt"} {
+		if _, err := os.Stat(filepath.Join(videoDir, name)); err != nil {
+			t.Errorf("%s was not imported: %v", name, err)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(videoDir, "metadata.json"))
+	var metadata struct {
+		ChannelID string            `json:"channel_id"`
+		Chapters  []youtube.Chapter `json:"chapters"`
+	}
+	json.Unmarshal(data, &metadata)
+	if metadata.ChannelID != "archive-UC1" || len(metadata.Chapters) != 1 {
+		t.Errorf("metadata.json = %s", data)
+	}
+
+	// Importing again skips everything
+	result, err = im.Import(ctx, downloads, Options{Mode: ModeCopy})
+	if err != nil || result.Imported != 0 || result.Skipped != 2 {
+		t.Errorf("second Import() = %+v, %v, want both skipped", result, err)
+	}
+}
+
+func TestImportMove(t *testing.T) {
+	downloads := t.TempDir()
+	base := writeDownload(t, downloads, "vid1", "UC1", "20240101", ".mp4")
+
+	catalog := newFakeCatalog()
+	im := New(storage.NewLocalBackend(t.TempDir()), catalog)
+	result, err := im.Import(context.Background(), downloads, Options{Mode: ModeMove})
+	if err != nil || result.Imported != 1 {
+		t.Fatalf("Import() = %+v, %v", result, err)
+	}
+	for _, p := range []string{base + ".mp4", base + ".info.json"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("move import left %s behind", p)
+		}
+	}
+	if data, err := os.ReadFile(catalog.videos["archive-UC1/vid1"].FilePath); err != nil || string(data) != "vid1.mp4" {
+		t.Errorf("moved video = %q, %v", data, err)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	downloads := t.TempDir()
+	archive := t.TempDir()
+	writeDownload(t, downloads, "vid1", "UC1", "20240101", ".mp4")
+
+	catalog := newFakeCatalog()
+	result, err := New(storage.NewLocalBackend(archive), catalog).Import(context.Background(), downloads, Options{DryRun: true})
+	if err != nil || result.Imported != 1 || result.Bytes != int64(len("vid1.mp4")) {
+		t.Fatalf("Import() = %+v, %v", result, err)
+	}
+	if len(catalog.channels) != 0 || len(catalog.videos) != 0 {
+		t.Error("dry run recorded videos")
+	}
+	if entries, _ := os.ReadDir(archive); len(entries) != 0 {
+		t.Error("dry run wrote to the archive")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Mode
+		wantErr bool
+	}{
+		{"", ModeHardlink, false},
+		{"hardlink", ModeHardlink, false},
+		{"COPY", ModeCopy, false},
+		{"move", ModeMove, false},
+		{"symlink", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMode(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
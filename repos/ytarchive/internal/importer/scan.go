@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// infoSuffix is the suffix yt-dlp --write-info-json gives metadata files
+const infoSuffix = ".info.json"
+
+// videoExtensions are the containers yt-dlp writes videos in, in order of preference
+var videoExtensions = []string{".mp4", ".mkv", ".webm", ".mov", ".m4v"}
+
+// thumbnailExtensions are the image formats yt-dlp --write-thumbnail writes
+var thumbnailExtensions = []string{".jpg", ".webp", ".png"}
+
+// subtitleExtension is the subtitle format the archive stores; yt-dlp
+// --write-subs writes it by default
+const subtitleExtension = ".vtt"
+
+// Video is a video found in a directory of yt-dlp downloads
+type Video struct {
+	Info      *youtube.VideoInfo `json:"info"`
+	InfoPath  string             `json:"info_path"`
+	MediaPath string             `json:"media_path"`
+	Thumbnail string             `json:"thumbnail,omitempty"`
+	Subtitles []Subtitle         `json:"subtitles,omitempty"`
+}
+
+// Subtitle is a subtitle file of a video
+type Subtitle struct {
+	Language string `json:"language"`
+	Path     string `json:"path"`
+}
+
+// Skipped is a file the scan could not use
+type Skipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Scan walks root for yt-dlp .info.json files and returns the videos whose
+// media file was found next to them, oldest upload first. Info files of
+// playlists and channels are ignored; other info files that cannot be used
+// are returned as skipped.
+func Scan(ctx context.Context, root string) ([]Video, []Skipped, error) {
+	var videos []Video
+	var skipped []Skipped
+	seen := make(map[string]bool)
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), infoSuffix) {
+			return nil
+		}
+
+		video, err := scanInfo(p)
+		if errors.Is(err, youtube.ErrNotVideo) {
+			return nil
+		}
+		if err != nil {
+			skipped = append(skipped, Skipped{Path: p, Reason: err.Error()})
+			return nil
+		}
+
+		// A video downloaded twice is imported from its first info file
+		key := video.Info.Channel.ID + "/" + video.Info.ID
+		if seen[key] {
+			skipped = append(skipped, Skipped{Path: p, Reason: "duplicate of video " + video.Info.ID})
+			return nil
+		}
+		seen[key] = true
+		videos = append(videos, *video)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	// Import in upload order, so episode numbers follow it
+	sort.SliceStable(videos, func(i, j int) bool {
+		return videos[i].Info.UploadDate < videos[j].Info.UploadDate
+	})
+	return videos, skipped, nil
+}
+
+// scanInfo reads an info file and finds the files yt-dlp wrote next to it
+func scanInfo(infoPath string) (*Video, error) {
+	data, err := os.ReadFile(infoPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := youtube.ParseVideoInfo(data)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(infoPath, infoSuffix)
+	media := findMedia(base, info)
+	if media == "" {
+		return nil, fmt.Errorf("no video file found for %s", info.ID)
+	}
+
+	return &Video{
+		Info:      info,
+		InfoPath:  infoPath,
+		MediaPath: media,
+		Thumbnail: findWithExtension(base, thumbnailExtensions),
+		Subtitles: findSubtitles(base),
+	}, nil
+}
+
+// findMedia returns the video file of an info file: base with the extension
+// yt-dlp recorded, any video extension, or the file name it recorded, which
+// may differ from base when the output template changed after download
+func findMedia(base string, info *youtube.VideoInfo) string {
+	extensions := videoExtensions
+	if info.Ext != "" {
+		extensions = append([]string{"." + info.Ext}, videoExtensions...)
+	}
+	if media := findWithExtension(base, extensions); media != "" {
+		return media
+	}
+
+	if info.Filename != "" {
+		recorded := filepath.Join(filepath.Dir(base), filepath.Base(info.Filename))
+		recordedBase := strings.TrimSuffix(recorded, filepath.Ext(recorded))
+		return findWithExtension(recordedBase, extensions)
+	}
+	return ""
+}
+
+// findWithExtension returns the first regular file base+ext, or "" if there is none
+func findWithExtension(base string, extensions []string) string {
+	for _, ext := range extensions {
+		if info, err := os.Stat(base + ext); err == nil && info.Mode().IsRegular() {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// findSubtitles returns the subtitle files yt-dlp names base.<language>.vtt
+func findSubtitles(base string) []Subtitle {
+	entries, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return nil
+	}
+
+	prefix := filepath.Base(base) + "."
+	var subtitles []Subtitle
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if filepath.Ext(name) != subtitleExtension {
+			continue
+		}
+		language := strings.TrimSuffix(strings.TrimPrefix(name, prefix), subtitleExtension)
+		if language == "" || strings.ContainsAny(language, "./\\") {
+			continue
+		}
+		subtitles = append(subtitles, Subtitle{
+			Language: language,
+			Path:     filepath.Join(filepath.Dir(base), name),
+		})
+	}
+	return subtitles
+}
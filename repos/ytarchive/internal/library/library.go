@@ -0,0 +1,558 @@
+// Package library exports the archive as a media server library. Channels become
+// shows and videos become episodes, laid out as
+//
+//	Channel Name/
+//	├── tvshow.nfo
+//	├── poster.jpg
+//	├── fanart.jpg
+//	└── Season 2024/
+//	    ├── S2024E01 - Title.mp4
+//	    ├── S2024E01 - Title.nfo
+//	    ├── S2024E01 - Title-thumb.jpg
+//	    └── S2024E01 - Title.en.vtt
+//
+// The videos are hardlinks or symlinks into the archive, so the library takes no
+// extra space and can be mounted straight into Jellyfin, Kodi or Plex.
+package library
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/types"
+)
+
+// LinkMode selects how videos are linked into the library
+type LinkMode string
+
+const (
+	// LinkHardlink hardlinks videos into the library. The library must be on the
+	// same filesystem as the archive; otherwise a symlink is made instead.
+	LinkHardlink LinkMode = "hardlink"
+	// LinkSymlink symlinks videos into the library with relative links
+	LinkSymlink LinkMode = "symlink"
+)
+
+// refreshTimeout bounds a library refresh started by a completed download
+const refreshTimeout = 5 * time.Minute
+
+// Source loads the channel and video records to export
+type Source interface {
+	Channel(ctx context.Context, channelID string) (*types.Channel, error)
+	Videos(ctx context.Context, channelID string) ([]types.Video, error)
+}
+
+// Exporter builds the library view of the archive
+type Exporter struct {
+	archivePath string
+	libraryPath string
+	source      Source
+	linkMode    LinkMode
+	httpClient  *http.Client
+	mu          sync.Mutex
+}
+
+// Option configures an Exporter
+type Option func(*Exporter)
+
+// WithLinkMode sets how videos are linked into the library
+func WithLinkMode(mode LinkMode) Option {
+	return func(e *Exporter) {
+		e.linkMode = mode
+	}
+}
+
+// WithHTTPClient sets the client used to download artwork that is not in the archive
+func WithHTTPClient(client *http.Client) Option {
+	return func(e *Exporter) {
+		e.httpClient = client
+	}
+}
+
+// NewExporter creates an exporter that builds a library in libraryPath from the
+// archive in archivePath, using the records from source
+func NewExporter(archivePath, libraryPath string, source Source, opts ...Option) *Exporter {
+	e := &Exporter{
+		archivePath: archivePath,
+		libraryPath: libraryPath,
+		source:      source,
+		linkMode:    LinkHardlink,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ParseLinkMode parses a link mode, defaulting to hardlinks
+func ParseLinkMode(mode string) (LinkMode, error) {
+	switch LinkMode(strings.ToLower(mode)) {
+	case "", LinkHardlink:
+		return LinkHardlink, nil
+	case LinkSymlink:
+		return LinkSymlink, nil
+	default:
+		return "", fmt.Errorf("unknown link mode %q (want hardlink or symlink)", mode)
+	}
+}
+
+// Episode is a video's place in the library
+type Episode struct {
+	Video  types.Video
+	Aired  time.Time
+	Season int // the year the video was uploaded
+	Number int // position within the season, by upload date
+}
+
+// Name returns the episode's file name without extension, e.g. "S2024E03 - Title"
+func (ep Episode) Name() string {
+	name := fmt.Sprintf("S%04dE%02d", ep.Season, ep.Number)
+	if title := cleanName(ep.Video.Title); title != "" {
+		name += " - " + title
+	}
+	return name
+}
+
+// SeasonDir returns the episode's season directory name, e.g. "Season 2024"
+func (ep Episode) SeasonDir() string {
+	return fmt.Sprintf("Season %04d", ep.Season)
+}
+
+// Episodes numbers videos as episodes. Each upload year is a season and episodes
+// are numbered by upload date within it, so numbers only shift when an older
+// video is archived late. Videos without a date fall back to when they were added.
+func Episodes(videos []types.Video) []Episode {
+	episodes := make([]Episode, 0, len(videos))
+	for _, video := range videos {
+		aired := parseDate(video.UploadDate)
+		if aired.IsZero() {
+			aired = video.CreatedAt
+		}
+		if aired.IsZero() {
+			continue
+		}
+		episodes = append(episodes, Episode{Video: video, Aired: aired, Season: aired.Year()})
+	}
+
+	sort.SliceStable(episodes, func(i, j int) bool {
+		if !episodes[i].Aired.Equal(episodes[j].Aired) {
+			return episodes[i].Aired.Before(episodes[j].Aired)
+		}
+		return episodes[i].Video.ID < episodes[j].Video.ID
+	})
+
+	numbers := make(map[int]int)
+	for i := range episodes {
+		numbers[episodes[i].Season]++
+		episodes[i].Number = numbers[episodes[i].Season]
+	}
+	return episodes
+}
+
+// parseDate parses a YYYYMMDD or YYYY-MM-DD upload date
+func parseDate(date string) time.Time {
+	date = strings.ReplaceAll(date, "-", "")
+	if len(date) < 8 {
+		return time.Time{}
+	}
+	t, err := time.Parse("20060102", date[:8])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// invalidNameChars are the characters not allowed in file names on common filesystems
+var invalidNameChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+// cleanName makes a title safe to use as a file or directory name while keeping
+// it readable, since media servers show it when there is no NFO
+func cleanName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "")
+	name = strings.Join(strings.Fields(name), " ")
+
+	// Leave room for the episode prefix and extension within the 255 byte limit
+	if len(name) > 180 {
+		cut := 180
+		for cut > 0 && !isRuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	return strings.TrimRight(name, ". ")
+}
+
+// isRuneStart reports whether b begins a UTF-8 encoded rune
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// ShowDir returns the library directory of a channel
+func (e *Exporter) ShowDir(channel *types.Channel) string {
+	name := cleanName(channel.Name)
+	if name == "" {
+		name = channel.ID
+	}
+	return filepath.Join(e.libraryPath, name)
+}
+
+// Refresh rebuilds the library view of one channel from the source records
+func (e *Exporter) Refresh(ctx context.Context, channelID string) error {
+	channel, err := e.source.Channel(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to load channel: %w", err)
+	}
+	if channel == nil {
+		return fmt.Errorf("channel not found: %s", channelID)
+	}
+
+	videos, err := e.source.Videos(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to load videos: %w", err)
+	}
+
+	return e.ExportChannel(ctx, channel, videos)
+}
+
+// RefreshAll rebuilds the library view of each channel, continuing past failures
+func (e *Exporter) RefreshAll(ctx context.Context, channelIDs []string) error {
+	var failed int
+	for _, channelID := range channelIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := e.Refresh(ctx, channelID); err != nil {
+			logging.Warn("failed to export channel to library", "channel_id", channelID, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to export %d of %d channels", failed, len(channelIDs))
+	}
+	return nil
+}
+
+// VideoCompleted refreshes the channel of a newly archived video in the
+// background. It is called with the video's ID and the path of its file.
+func (e *Exporter) VideoCompleted(videoID, filePath string) {
+	channelID := e.channelIDFromPath(filePath)
+	if channelID == "" {
+		logging.Warn("archived video is outside the archive, not adding it to the library",
+			"video_id", videoID,
+			"file_path", filePath,
+		)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		if err := e.Refresh(ctx, channelID); err != nil {
+			logging.Warn("failed to update library", "channel_id", channelID, "video_id", videoID, "error", err)
+		}
+	}()
+}
+
+// channelIDFromPath returns the channel ID of a file stored at
+// {archive}/channels/{channel_id}/videos/{video_id}/..., or "" for other paths
+func (e *Exporter) channelIDFromPath(filePath string) string {
+	rel, err := filepath.Rel(e.archivePath, filePath)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 4 || parts[0] != "channels" || parts[2] != "videos" {
+		return ""
+	}
+	return parts[1]
+}
+
+// ExportChannel writes the library view of a channel: its show NFO and artwork,
+// and an episode for each archived video. Files in the show directory that no
+// longer belong to an episode are removed.
+func (e *Exporter) ExportChannel(ctx context.Context, channel *types.Channel, videos []types.Video) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var archived []types.Video
+	for _, video := range videos {
+		if video.FilePath != "" && fileExists(video.FilePath) {
+			archived = append(archived, video)
+		}
+	}
+	episodes := Episodes(archived)
+
+	showDir := e.ShowDir(channel)
+	if err := os.MkdirAll(showDir, 0755); err != nil {
+		return fmt.Errorf("failed to create show directory: %w", err)
+	}
+
+	keep := make(map[string]bool)
+	if err := writeNFO(filepath.Join(showDir, "tvshow.nfo"), newShowNFO(channel)); err != nil {
+		return err
+	}
+	keep["tvshow.nfo"] = true
+
+	var latestThumb string
+	for _, ep := range episodes {
+		files, err := e.exportEpisode(ctx, channel, ep, showDir)
+		if err != nil {
+			return fmt.Errorf("failed to export video %s: %w", ep.Video.ID, err)
+		}
+		for _, file := range files {
+			keep[file] = true
+			if strings.HasSuffix(file, "-thumb.jpg") {
+				latestThumb = filepath.Join(showDir, file)
+			}
+		}
+	}
+
+	// Channels without their own artwork show their latest thumbnail instead
+	artwork := []struct {
+		name, archived, url string
+	}{
+		{"poster.jpg", filepath.Join(e.archivePath, "channels", channel.ID, "avatar.jpg"), channel.AvatarURL},
+		{"fanart.jpg", filepath.Join(e.archivePath, "channels", channel.ID, "banner.jpg"), channel.BannerURL},
+	}
+	for _, art := range artwork {
+		if e.exportImage(ctx, filepath.Join(showDir, art.name), art.url, art.archived, latestThumb) {
+			keep[art.name] = true
+		}
+	}
+
+	return prune(showDir, keep)
+}
+
+// exportEpisode links a video and its thumbnail and subtitles into its season
+// directory and writes its NFO. It returns the files written, relative to showDir.
+func (e *Exporter) exportEpisode(ctx context.Context, channel *types.Channel, ep Episode, showDir string) ([]string, error) {
+	seasonDir := filepath.Join(showDir, ep.SeasonDir())
+	if err := os.MkdirAll(seasonDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create season directory: %w", err)
+	}
+
+	base := filepath.Join(ep.SeasonDir(), ep.Name())
+	videoFile := base + strings.ToLower(filepath.Ext(ep.Video.FilePath))
+	if err := e.link(ep.Video.FilePath, filepath.Join(showDir, videoFile)); err != nil {
+		return nil, err
+	}
+	files := []string{videoFile}
+
+	nfoFile := base + ".nfo"
+	if err := writeNFO(filepath.Join(showDir, nfoFile), newEpisodeNFO(channel, ep)); err != nil {
+		return nil, err
+	}
+	files = append(files, nfoFile)
+
+	videoDir := filepath.Dir(ep.Video.FilePath)
+	thumbFile := base + "-thumb.jpg"
+	if e.exportImage(ctx, filepath.Join(showDir, thumbFile), ep.Video.ThumbnailURL, findThumbnail(videoDir), "") {
+		files = append(files, thumbFile)
+	}
+
+	for _, subtitle := range findSubtitles(videoDir) {
+		subtitleFile := base + "." + subtitle.language + ".vtt"
+		if err := e.link(subtitle.path, filepath.Join(showDir, subtitleFile)); err != nil {
+			return nil, err
+		}
+		files = append(files, subtitleFile)
+	}
+
+	return files, nil
+}
+
+// exportImage places an image at dst: linked from archived if it exists, otherwise
+// downloaded from url, otherwise linked from fallback. It reports whether dst exists.
+func (e *Exporter) exportImage(ctx context.Context, dst, url, archived, fallback string) bool {
+	if archived != "" && fileExists(archived) {
+		return e.link(archived, dst) == nil
+	}
+	if url != "" {
+		if fileExists(dst) && !isLinkTo(dst, fallback, e.linkMode) {
+			return true // downloaded earlier
+		}
+		err := e.download(ctx, url, dst)
+		if err == nil {
+			return true
+		}
+		logging.Warn("failed to download library artwork", "url", url, "error", err)
+	}
+	if fallback != "" && fileExists(fallback) {
+		return e.link(fallback, dst) == nil
+	}
+	return false
+}
+
+// download saves url to dst
+func (e *Exporter) download(ctx context.Context, url, dst string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create image file: %w", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	os.Remove(dst)
+	return os.Rename(tmp, dst)
+}
+
+// link makes dst a link to src, replacing whatever dst was unless it already links to src
+func (e *Exporter) link(src, dst string) error {
+	if isLinkTo(dst, src, e.linkMode) {
+		return nil
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", dst, err)
+	}
+
+	if e.linkMode == LinkHardlink {
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+		// Hardlinks cannot cross filesystems; fall back to a symlink
+	}
+
+	target, err := filepath.Rel(filepath.Dir(dst), src)
+	if err != nil {
+		target = src
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return fmt.Errorf("failed to link %s: %w", dst, err)
+	}
+	return nil
+}
+
+// isLinkTo reports whether dst already links to src
+func isLinkTo(dst, src string, mode LinkMode) bool {
+	if src == "" {
+		return false
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err != nil {
+		return false
+	}
+	if dstInfo.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(dst)
+		if err != nil {
+			return false
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(dst), target)
+		}
+		return filepath.Clean(target) == filepath.Clean(src)
+	}
+	if mode != LinkHardlink {
+		return false
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(dstInfo, srcInfo)
+}
+
+// prune removes the files in showDir that are not in keep, and then any empty season directories
+func prune(showDir string, keep map[string]bool) error {
+	var dirs []string
+	err := filepath.WalkDir(showDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(showDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		if !keep[filepath.ToSlash(rel)] && !keep[rel] {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove stale library file: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deepest first, so nested directories are emptied before their parents
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		os.Remove(dir) // fails, harmlessly, if the directory is not empty
+	}
+	return nil
+}
+
+// subtitleFile is a subtitle track stored next to an archived video
+type subtitleFile struct {
+	path     string
+	language string
+}
+
+// findSubtitles returns the subtitle files in an archived video directory.
+// subtitles.vtt holds the primary language (en); others are subtitles.<lang>.vtt.
+func findSubtitles(videoDir string) []subtitleFile {
+	matches, _ := filepath.Glob(filepath.Join(videoDir, "subtitles*.vtt"))
+	sort.Strings(matches)
+
+	var subtitles []subtitleFile
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), ".vtt")
+		language := "en"
+		if name != "subtitles" {
+			language = strings.TrimPrefix(name, "subtitles.")
+		}
+		subtitles = append(subtitles, subtitleFile{path: path, language: language})
+	}
+	return subtitles
+}
+
+// findThumbnail returns the JPEG thumbnail in an archived video directory, or ""
+func findThumbnail(videoDir string) string {
+	path := filepath.Join(videoDir, "thumbnail.jpg")
+	if fileExists(path) {
+		return path
+	}
+	return ""
+}
+
+// fileExists reports whether path exists and is a regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,211 @@
+package library
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/types"
+)
+
+// fakeSource serves fixed records
+type fakeSource struct {
+	channel *types.Channel
+	videos  []types.Video
+}
+
+func (s *fakeSource) Channel(ctx context.Context, channelID string) (*types.Channel, error) {
+	if s.channel == nil || s.channel.ID != channelID {
+		return nil, nil
+	}
+	return s.channel, nil
+}
+
+func (s *fakeSource) Videos(ctx context.Context, channelID string) ([]types.Video, error) {
+	return s.videos, nil
+}
+
+func TestEpisodes(t *testing.T) {
+	videos := []types.Video{
+		{ID: "c", Title: "Third", UploadDate: "20240301"},
+		{ID: "a", Title: "First", UploadDate: "2024-01-15"},
+		{ID: "old", Title: "Last year", UploadDate: "20231231"},
+		{ID: "b", Title: "Same day", UploadDate: "20240115"},
+		{ID: "added", Title: "No date", CreatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "skip", Title: "No date at all"},
+	}
+
+	want := []struct {
+		id   string
+		name string
+	}{
+		{"old", "S2023E01 - Last year"},
+		{"a", "S2024E01 - First"},
+		{"b", "S2024E02 - Same day"},
+		{"c", "S2024E03 - Third"},
+		{"added", "S2024E04 - No date"},
+	}
+
+	episodes := Episodes(videos)
+	if len(episodes) != len(want) {
+		t.Fatalf("Episodes() returned %d episodes, want %d", len(episodes), len(want))
+	}
+	for i, w := range want {
+		if episodes[i].Video.ID != w.id || episodes[i].Name() != w.name {
+			t.Errorf("episode %d = %s %q, want %s %q", i, episodes[i].Video.ID, episodes[i].Name(), w.id, w.name)
+		}
+	}
+	if got := episodes[0].SeasonDir(); got != "Season 2023" {
+		t.Errorf("SeasonDir() = %q, want %q", got, "Season 2023")
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Plain Title", "Plain Title"},
+		{`What? A "Title": Part 1/2`, "What A Title Part 12"},
+		{"  spaced \t out\n", "spaced out"},
+		{"Trailing dots...", "Trailing dots"},
+		{strings.Repeat("é", 100), strings.Repeat("é", 90)},
+	}
+
+	for _, tt := range tests {
+		if got := cleanName(tt.name); got != tt.want {
+			t.Errorf("cleanName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLinkMode(t *testing.T) {
+	for input, want := range map[string]LinkMode{"": LinkHardlink, "hardlink": LinkHardlink, "Symlink": LinkSymlink} {
+		if got, err := ParseLinkMode(input); err != nil || got != want {
+			t.Errorf("ParseLinkMode(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseLinkMode("copy"); err == nil {
+		t.Error("ParseLinkMode(copy) expected error")
+	}
+}
+
+func TestChannelIDFromPath(t *testing.T) {
+	e := NewExporter("/data", "/library", nil)
+
+	tests := map[string]string{
+		"/data/channels/abc/videos/vid/video.mp4": "abc",
+		"/data/channels/abc/channel.json":         "",
+		"/other/channels/abc/videos/vid/v.mp4":    "",
+	}
+	for path, want := range tests {
+		if got := e.channelIDFromPath(path); got != want {
+			t.Errorf("channelIDFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// writeArchivedVideo creates an archived video with a thumbnail and subtitles
+func writeArchivedVideo(t *testing.T, archive, channelID, videoID string) string {
+	t.Helper()
+	dir := filepath.Join(archive, "channels", channelID, "videos", videoID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"video.mp4", "thumbnail.jpg", "subtitles.vtt", "subtitles.de.vtt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(videoID+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "video.mp4")
+}
+
+func TestExportChannel(t *testing.T) {
+	for _, mode := range []LinkMode{LinkHardlink, LinkSymlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			archive, lib := t.TempDir(), t.TempDir()
+			channel := &types.Channel{ID: "chan-1", Name: "Some Channel: Official", Description: "About"}
+			source := &fakeSource{
+				channel: channel,
+				videos: []types.Video{
+					{ID: "v1", Title: "First video", UploadDate: "20240115", Duration: 61, FilePath: writeArchivedVideo(t, archive, "chan-1", "v1")},
+					{ID: "v2", Title: "Not downloaded", UploadDate: "20240120"},
+				},
+			}
+			e := NewExporter(archive, lib, source, WithLinkMode(mode))
+
+			if err := e.Refresh(context.Background(), "chan-1"); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			show := filepath.Join(lib, "Some Channel Official")
+			for _, name := range []string{
+				"tvshow.nfo",
+				"poster.jpg",
+				"fanart.jpg",
+				"Season 2024/S2024E01 - First video.mp4",
+				"Season 2024/S2024E01 - First video.nfo",
+				"Season 2024/S2024E01 - First video-thumb.jpg",
+				"Season 2024/S2024E01 - First video.en.vtt",
+				"Season 2024/S2024E01 - First video.de.vtt",
+			} {
+				if _, err := os.Stat(filepath.Join(show, name)); err != nil {
+					t.Errorf("missing %s: %v", name, err)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(show, "Season 2024", "S2024E01 - First video.mp4"))
+			if err != nil || string(data) != "v1video.mp4" {
+				t.Errorf("linked video = %q, %v", data, err)
+			}
+
+			nfo, _ := os.ReadFile(filepath.Join(show, "Season 2024", "S2024E01 - First video.nfo"))
+			for _, want := range []string{
+				"<episodedetails>",
+				"<showtitle>Some Channel: Official</showtitle>",
+				"<season>2024</season>",
+				"<episode>1</episode>",
+				"<aired>2024-01-15</aired>",
+				"<runtime>2</runtime>",
+				`<uniqueid type="youtube" default="true">v1</uniqueid>`,
+			} {
+				if !strings.Contains(string(nfo), want) {
+					t.Errorf("episode NFO missing %q:\n%s", want, nfo)
+				}
+			}
+
+			// An earlier video shifts the episode; the old files are pruned
+			source.videos = append(source.videos, types.Video{
+				ID: "v0", Title: "Earlier", UploadDate: "20240101", FilePath: writeArchivedVideo(t, archive, "chan-1", "v0"),
+			})
+			if err := e.Refresh(context.Background(), "chan-1"); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			entries, _ := os.ReadDir(filepath.Join(show, "Season 2024"))
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			joined := strings.Join(names, "\n")
+			if strings.Contains(joined, "S2024E01 - First video") {
+				t.Errorf("stale episode files not pruned:\n%s", joined)
+			}
+			for _, want := range []string{"S2024E01 - Earlier.mp4", "S2024E02 - First video.mp4"} {
+				if !strings.Contains(joined, want) {
+					t.Errorf("season missing %s:\n%s", want, joined)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshUnknownChannel(t *testing.T) {
+	e := NewExporter(t.TempDir(), t.TempDir(), &fakeSource{})
+	if err := e.Refresh(context.Background(), "missing"); err == nil {
+		t.Error("Refresh() of an unknown channel expected error")
+	}
+}
@@ -0,0 +1,87 @@
+package library
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"github.com/timholm/ytarchive/internal/types"
+)
+
+// uniqueID identifies a show or episode to the media server
+type uniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// showNFO is the Kodi tvshow.nfo format, which Jellyfin also reads
+type showNFO struct {
+	XMLName  xml.Name `xml:"tvshow"`
+	Title    string   `xml:"title"`
+	Plot     string   `xml:"plot,omitempty"`
+	Studio   string   `xml:"studio"`
+	UniqueID uniqueID `xml:"uniqueid"`
+}
+
+// episodeNFO is the Kodi episode NFO format, which Jellyfin also reads
+type episodeNFO struct {
+	XMLName   xml.Name `xml:"episodedetails"`
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle"`
+	Season    int      `xml:"season"`
+	Episode   int      `xml:"episode"`
+	Plot      string   `xml:"plot,omitempty"`
+	Aired     string   `xml:"aired"`
+	Runtime   int      `xml:"runtime,omitempty"` // minutes
+	Studio    string   `xml:"studio"`
+	UniqueID  uniqueID `xml:"uniqueid"`
+}
+
+// newShowNFO builds the show NFO of a channel
+func newShowNFO(channel *types.Channel) showNFO {
+	return showNFO{
+		Title:    channel.Name,
+		Plot:     channel.Description,
+		Studio:   "YouTube",
+		UniqueID: uniqueID{Type: "ytarchive", Default: true, Value: channel.ID},
+	}
+}
+
+// newEpisodeNFO builds the NFO of an episode
+func newEpisodeNFO(channel *types.Channel, ep Episode) episodeNFO {
+	nfo := episodeNFO{
+		Title:     ep.Video.Title,
+		ShowTitle: channel.Name,
+		Season:    ep.Season,
+		Episode:   ep.Number,
+		Plot:      ep.Video.Description,
+		Aired:     ep.Aired.Format("2006-01-02"),
+		Studio:    "YouTube",
+		UniqueID:  uniqueID{Type: "youtube", Default: true, Value: ep.Video.ID},
+	}
+	if ep.Video.Duration > 0 {
+		nfo.Runtime = (ep.Video.Duration + 59) / 60
+	}
+	return nfo
+}
+
+// writeNFO writes an NFO file. An unchanged file is left alone so media servers
+// don't rescan it.
+func writeNFO(path string, nfo interface{}) error {
+	body, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal NFO: %w", err)
+	}
+	data := append([]byte(xml.Header), body...)
+	data = append(data, '\n')
+
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write NFO: %w", err)
+	}
+	return nil
+}
@@ -32,6 +32,23 @@ var (
 		[]string{"endpoint"},
 	)
 
+	// Shared rate limit metrics
+	RateLimitFactor = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ytarchive_rate_limit_factor",
+			Help: "Fraction of the configured YouTube request rate currently allowed, per budget",
+		},
+		[]string{"budget"},
+	)
+
+	RateLimitBackoffs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ytarchive_rate_limit_backoffs_total",
+			Help: "Total number of times the shared YouTube request rate was lowered",
+		},
+		[]string{"budget"},
+	)
+
 	// Download metrics
 	DownloadsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -67,6 +84,43 @@ var (
 		[]string{"channel_id"},
 	)
 
+	// Autoscaling metrics, for the controller's reconciler or an external
+	// autoscaler such as KEDA or an HPA on external metrics
+	QueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ytarchive_queue_depth",
+			Help: "Videos waiting in the download queue, all channels",
+		},
+	)
+
+	QueueInProgress = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ytarchive_queue_in_progress",
+			Help: "Videos claimed by workers and not finished yet",
+		},
+	)
+
+	DownloadAverageSeconds = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ytarchive_download_average_seconds",
+			Help: "Average time workers took for their recent downloads",
+		},
+	)
+
+	WorkersDesired = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ytarchive_workers_desired",
+			Help: "Workers needed to drain the download queue in the target time",
+		},
+	)
+
+	Workers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ytarchive_workers",
+			Help: "Replicas of the worker deployment, when the controller scales it",
+		},
+	)
+
 	// Job metrics
 	ActiveJobs = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -112,6 +166,28 @@ func RecordDownloadFailure() {
 	DownloadsTotal.WithLabelValues("failure").Inc()
 }
 
+// RecordQueue records the depth of the download queue, in total and per channel
+func RecordQueue(queued, inProgress int64, byChannel map[string]int) {
+	QueueDepth.Set(float64(queued))
+	QueueInProgress.Set(float64(inProgress))
+	QueueSize.Reset() // drop channels whose downloads are all claimed
+	for channelID, n := range byChannel {
+		QueueSize.WithLabelValues(channelID).Set(float64(n))
+	}
+}
+
+// RecordWorkerScaling records the average download time and the worker count
+// the queue needs
+func RecordWorkerScaling(averageSeconds float64, desired int) {
+	DownloadAverageSeconds.Set(averageSeconds)
+	WorkersDesired.Set(float64(desired))
+}
+
+// RecordWorkers records the replicas of the worker deployment
+func RecordWorkers(replicas int) {
+	Workers.Set(float64(replicas))
+}
+
 // RecordAPIRequest records a YouTube API request
 func RecordAPIRequest(endpoint, status string, latencySeconds float64) {
 	YouTubeAPIRequests.WithLabelValues(endpoint, status).Inc()
@@ -122,3 +198,13 @@ func RecordAPIRequest(endpoint, status string, latencySeconds float64) {
 func RecordAPIRetry(endpoint string) {
 	YouTubeAPIRetries.WithLabelValues(endpoint).Inc()
 }
+
+// RecordRateLimitFactor records the fraction of a budget's rate currently allowed
+func RecordRateLimitFactor(budget string, factor float64) {
+	RateLimitFactor.WithLabelValues(budget).Set(factor)
+}
+
+// RecordRateLimitBackoff records a budget's rate being lowered
+func RecordRateLimitBackoff(budget string) {
+	RateLimitBackoffs.WithLabelValues(budget).Inc()
+}
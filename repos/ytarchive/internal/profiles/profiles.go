@@ -0,0 +1,254 @@
+// Package profiles keeps the named quality profiles that can be assigned to
+// channels.
+//
+// A profile sets the codecs and resolution workers prefer, the container, an
+// audio-only mode, a cap on file size per minute and an optional transcode
+// after downloading. A few profiles are built in; admins can add their own.
+// Channels without a profile use the default, which follows the worker's
+// configuration.
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/downloader"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// profilesKey is the Redis hash of custom profiles, by name
+const profilesKey = "profiles"
+
+// DefaultName names the profile of channels that have none
+const DefaultName = "default"
+
+var (
+	// ErrNotFound is returned for profiles that do not exist
+	ErrNotFound = errors.New("profile not found")
+	// ErrBuiltIn is returned when changing or deleting a built-in profile
+	ErrBuiltIn = errors.New("built-in profiles cannot be changed")
+	// ErrInUse is returned when deleting a profile channels are assigned
+	ErrInUse = errors.New("profile is assigned to channels")
+)
+
+// namePattern matches the names accepted for profiles
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// builtIn are the profiles every archive has
+var builtIn = []downloader.Profile{
+	{
+		Name:        DefaultName,
+		Description: "Best quality up to MAX_VIDEO_HEIGHT, preferring H.264 for compatibility",
+	},
+	{
+		Name:        "efficient",
+		Description: "Best quality in AV1 or VP9, smaller than H.264 but harder for older devices to play",
+		Codecs:      []string{downloader.CodecAV1, downloader.CodecVP9, downloader.CodecH264},
+	},
+	{
+		Name:        "space-saver",
+		Description: "720p re-encoded to HEVC, for talking-head channels",
+		MaxHeight:   720,
+		Transcode: &downloader.Transcode{
+			Codec:        downloader.CodecHEVC,
+			MaxHeight:    720,
+			CRF:          28,
+			AudioBitrate: 96,
+		},
+	},
+	{
+		Name:        "audio-only",
+		Description: "Only the best audio stream, for podcasts and music",
+		AudioOnly:   true,
+	},
+}
+
+// BuiltIn returns the built-in profile with a name
+func BuiltIn(name string) (downloader.Profile, bool) {
+	for _, profile := range builtIn {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return downloader.Profile{}, false
+}
+
+// IsBuiltIn reports whether a name is taken by a built-in profile
+func IsBuiltIn(name string) bool {
+	_, ok := BuiltIn(name)
+	return ok
+}
+
+// ValidateName checks that a profile name is accepted
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 32 lowercase letters, digits and dashes", name)
+	}
+	return nil
+}
+
+// Channels looks up the channels profiles are assigned to, as
+// store.Records does
+type Channels interface {
+	ChannelIDs(ctx context.Context) ([]string, error)
+	Channel(ctx context.Context, id string) ([]byte, error)
+}
+
+// Store keeps custom profiles in Redis. Which profile a channel is assigned
+// is read from the channel's record.
+type Store struct {
+	redis    *redis.Client
+	channels Channels
+}
+
+// NewStore creates a store backed by a Redis client and the channel records
+func NewStore(client *redis.Client, channels Channels) *Store {
+	return &Store{redis: client, channels: channels}
+}
+
+// Get returns a built-in or custom profile, or ErrNotFound
+func (s *Store) Get(ctx context.Context, name string) (*downloader.Profile, error) {
+	if profile, ok := BuiltIn(name); ok {
+		return &profile, nil
+	}
+
+	data, err := s.redis.HGet(ctx, profilesKey, name).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	var profile downloader.Profile
+	if err := json.Unmarshal([]byte(data), &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal profile: %w", err)
+	}
+	profile.Name = name
+	return &profile, nil
+}
+
+// List returns the built-in profiles followed by the custom ones, by name
+func (s *Store) List(ctx context.Context) ([]downloader.Profile, error) {
+	values, err := s.redis.HGetAll(ctx, profilesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	custom := make([]downloader.Profile, 0, len(values))
+	for name, data := range values {
+		var profile downloader.Profile
+		if err := json.Unmarshal([]byte(data), &profile); err != nil {
+			continue
+		}
+		profile.Name = name
+		custom = append(custom, profile)
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i].Name < custom[j].Name })
+
+	return append(append([]downloader.Profile{}, builtIn...), custom...), nil
+}
+
+// Put adds or replaces a custom profile
+func (s *Store) Put(ctx context.Context, profile *downloader.Profile) error {
+	if err := ValidateName(profile.Name); err != nil {
+		return err
+	}
+	if IsBuiltIn(profile.Name) {
+		return ErrBuiltIn
+	}
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	if err := s.redis.HSet(ctx, profilesKey, profile.Name, data).Err(); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a custom profile no channel is assigned
+func (s *Store) Delete(ctx context.Context, name string) error {
+	if IsBuiltIn(name) {
+		return ErrBuiltIn
+	}
+	channels, err := s.ChannelsUsing(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(channels) > 0 {
+		return fmt.Errorf("%w: %d channels", ErrInUse, len(channels))
+	}
+
+	removed, err := s.redis.HDel(ctx, profilesKey, name).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete profile: %w", err)
+	}
+	if removed == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ChannelsUsing returns the IDs of the channels assigned a profile
+func (s *Store) ChannelsUsing(ctx context.Context, name string) ([]string, error) {
+	channelIDs, err := s.channels.ChannelIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %w", err)
+	}
+
+	var using []string
+	for _, channelID := range channelIDs {
+		assigned, err := s.channelProfileName(ctx, channelID)
+		if err != nil {
+			continue
+		}
+		if assigned == name {
+			using = append(using, channelID)
+		}
+	}
+	sort.Strings(using)
+	return using, nil
+}
+
+// ForChannel returns the profile assigned to a channel, or the default one.
+// A channel assigned a profile that no longer exists gets ErrNotFound.
+func (s *Store) ForChannel(ctx context.Context, channelID string) (*downloader.Profile, error) {
+	name, err := s.channelProfileName(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = DefaultName
+	}
+	return s.Get(ctx, name)
+}
+
+// channelProfileName returns the name of the profile assigned to a channel, or "" for none
+func (s *Store) channelProfileName(ctx context.Context, channelID string) (string, error) {
+	data, err := s.channels.Channel(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	var channel struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.Unmarshal(data, &channel); err != nil {
+		return "", fmt.Errorf("failed to parse channel: %w", err)
+	}
+	return channel.Profile, nil
+}
@@ -0,0 +1,43 @@
+package profiles
+
+import "testing"
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "talking-heads"},
+		{name: "720p"},
+		{name: "", wantErr: true},
+		{name: "-leading-dash", wantErr: true},
+		{name: "Upper", wantErr: true},
+		{name: "with space", wantErr: true},
+		{name: "../escape", wantErr: true},
+		{name: "a-name-that-is-far-too-long-to-be-accepted", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateName(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuiltIn(t *testing.T) {
+	for _, profile := range builtIn {
+		if err := ValidateName(profile.Name); err != nil {
+			t.Errorf("built-in profile %q has an invalid name: %v", profile.Name, err)
+		}
+		if err := profile.Validate(); err != nil {
+			t.Errorf("built-in profile %q is invalid: %v", profile.Name, err)
+		}
+	}
+
+	if _, ok := BuiltIn(DefaultName); !ok {
+		t.Errorf("BuiltIn(%q) not found", DefaultName)
+	}
+	if IsBuiltIn("custom") {
+		t.Error("IsBuiltIn(\"custom\") = true, want false")
+	}
+}
@@ -0,0 +1,436 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// PriorityQueueKey is the sorted set of queued downloads for all channels.
+	// Members are "channelID:videoID"; the lowest score is downloaded first.
+	PriorityQueueKey = "ytarchive:download:priority"
+	// LegacyQueueKey is the list the download queue was kept in before it had priorities
+	LegacyQueueKey = "ytarchive:download:queue"
+	// ProcessingKeyPrefix prefixes the list of downloads each worker has claimed
+	ProcessingKeyPrefix = "ytarchive:download:processing:"
+	// virtualTimeKey is a hash of each channel's virtual time per priority class
+	virtualTimeKey = "ytarchive:download:vtime"
+	// clockKey holds the virtual time of the last claimed download
+	clockKey = "ytarchive:download:clock"
+
+	// classSpan separates the scores of the priority classes; virtual times stay below it
+	classSpan = 1e9
+	// DefaultWeight is the share of a channel without a weight setting
+	DefaultWeight = 1.0
+	// MaxWeight is the largest channel weight
+	MaxWeight = 100.0
+)
+
+// ErrNotClaimed is returned when releasing an item the processing list does
+// not hold, such as one requeued by another worker after its lease expired
+var ErrNotClaimed = errors.New("item is not claimed by this worker")
+
+// Priority is the class of a queued download. Every download of a class is
+// claimed before any download of a lower class.
+type Priority int
+
+const (
+	// PriorityManual is for downloads requested by hand, and for downloads
+	// interrupted by a worker shutting down so they are resumed first
+	PriorityManual Priority = iota
+	// PriorityNew is for uploads found on channels that have been synced before
+	PriorityNew
+	// PriorityBackfill is for the back catalog of a channel and retried downloads
+	PriorityBackfill
+)
+
+// String returns the name of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityManual:
+		return "manual"
+	case PriorityNew:
+		return "new"
+	case PriorityBackfill:
+		return "backfill"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// MarshalText encodes the priority as its name
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// Entry is a queued download
+type Entry struct {
+	Position  int64    `json:"position"` // 1 is claimed next
+	ChannelID string   `json:"channel_id"`
+	VideoID   string   `json:"video_id"`
+	Priority  Priority `json:"priority"`
+}
+
+// ChannelQueue summarizes the queued downloads of a channel
+type ChannelQueue struct {
+	Queued       int   `json:"queued"`
+	NextPosition int64 `json:"next_position"` // position of the channel's first queued download
+}
+
+// Item returns the queue member of a video
+func Item(channelID, videoID string) string {
+	return channelID + ":" + videoID
+}
+
+// ProcessingKey returns the key of the list of downloads a worker has claimed
+func ProcessingKey(workerID string) string {
+	return ProcessingKeyPrefix + workerID
+}
+
+// ParseItem splits a queue member formatted as "channelID:videoID"
+func ParseItem(item string) (channelID, videoID string, err error) {
+	parts := strings.SplitN(item, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid queue item format: %s (expected channelID:videoID)", item)
+	}
+	return parts[0], parts[1], nil
+}
+
+// PriorityFromScore returns the priority class of a queue score
+func PriorityFromScore(score float64) Priority {
+	return Priority(math.Floor(score / classSpan))
+}
+
+// NormalizeWeight returns the weight to schedule a channel with, using the
+// default for unset or invalid weights and capping large ones
+func NormalizeWeight(weight float64) float64 {
+	if weight <= 0 || math.IsNaN(weight) {
+		return DefaultWeight
+	}
+	return math.Min(weight, MaxWeight)
+}
+
+// enqueueScript adds videos of one channel to the queue. Within a priority
+// class channels take turns: each video advances the channel's virtual time by
+// 1/weight, starting no earlier than the clock, so a channel with thousands of
+// queued videos does not hold back one that is added later. A video already
+// queued in a lower class is moved up; one queued at the same or a higher
+// class keeps its place.
+var enqueueScript = redis.NewScript(`
+local span = tonumber(ARGV[4])
+local offset = tonumber(ARGV[2]) * span
+local field = ARGV[2] .. ':' .. ARGV[1]
+local clock = tonumber(redis.call('GET', KEYS[3]) or '0')
+local vt = tonumber(redis.call('HGET', KEYS[2], field) or '0')
+if vt < clock then
+	vt = clock
+end
+local step = 1 / tonumber(ARGV[3])
+local added = 0
+for i = 5, #ARGV do
+	local current = redis.call('ZSCORE', KEYS[1], ARGV[i])
+	if not current or tonumber(current) >= offset + span then
+		vt = vt + step
+		redis.call('ZADD', KEYS[1], offset + vt, ARGV[i])
+		if not current then
+			added = added + 1
+		end
+	end
+end
+redis.call('HSET', KEYS[2], field, vt)
+return added
+`)
+
+// claimScript moves the first queued download to a processing list and
+// advances the clock to its virtual time
+var claimScript = redis.NewScript(`
+local popped = redis.call('ZPOPMIN', KEYS[1])
+if #popped == 0 then
+	return false
+end
+local vt = math.fmod(tonumber(popped[2]), tonumber(ARGV[1]))
+local clock = tonumber(redis.call('GET', KEYS[3]) or '0')
+if vt > clock then
+	redis.call('SET', KEYS[3], vt)
+end
+redis.call('LPUSH', KEYS[2], popped[1])
+return popped[1]
+`)
+
+// releaseScript moves an item from a processing list back to the front of the queue
+var releaseScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) > 0 then
+	redis.call('ZADD', KEYS[2], tonumber(redis.call('GET', KEYS[3]) or '0'), ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// requeueScript moves all items of a worker's processing list back to the
+// front of the queue and unregisters the worker. Given a lease key, it does
+// nothing and returns -1 while the lease is held.
+var requeueScript = redis.NewScript(`
+if KEYS[5] and redis.call('EXISTS', KEYS[5]) == 1 then
+	return -1
+end
+local clock = tonumber(redis.call('GET', KEYS[4]) or '0')
+local moved = 0
+while true do
+	local item = redis.call('LPOP', KEYS[1])
+	if not item then
+		break
+	end
+	redis.call('ZADD', KEYS[2], clock, item)
+	moved = moved + 1
+end
+redis.call('SREM', KEYS[3], ARGV[1])
+return moved
+`)
+
+// PriorityQueue is the global download queue shared by all channels and workers
+type PriorityQueue struct {
+	client *redis.Client
+}
+
+// NewPriorityQueue creates a PriorityQueue on a Redis client
+func NewPriorityQueue(client *redis.Client) *PriorityQueue {
+	return &PriorityQueue{client: client}
+}
+
+// Enqueue queues videos of a channel at a priority, in the order given, and
+// returns how many were not queued already. weight is the channel's share of
+// the workers relative to other channels at the same priority.
+func (q *PriorityQueue) Enqueue(ctx context.Context, channelID string, videoIDs []string, priority Priority, weight float64) (int64, error) {
+	if channelID == "" {
+		return 0, fmt.Errorf("channel ID cannot be empty")
+	}
+	if len(videoIDs) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, 0, len(videoIDs)+4)
+	args = append(args, channelID, int(priority), NormalizeWeight(weight), classSpan)
+	for _, videoID := range videoIDs {
+		args = append(args, Item(channelID, videoID))
+	}
+
+	added, err := enqueueScript.Run(ctx, q.client, []string{PriorityQueueKey, virtualTimeKey, clockKey}, args...).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue videos: %w", err)
+	}
+	return added, nil
+}
+
+// Claim moves the next download to a worker's processing list and returns it,
+// or returns an empty item if the queue is empty
+func (q *PriorityQueue) Claim(ctx context.Context, processingKey string) (string, error) {
+	item, err := claimScript.Run(ctx, q.client, []string{PriorityQueueKey, processingKey, clockKey}, classSpan).Text()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to claim from queue: %w", err)
+	}
+	return item, nil
+}
+
+// Release moves an unfinished item from a processing list back to the front
+// of the queue. It returns ErrNotClaimed if the list does not hold the item.
+func (q *PriorityQueue) Release(ctx context.Context, processingKey, item string) error {
+	released, err := releaseScript.Run(ctx, q.client, []string{processingKey, PriorityQueueKey, clockKey}, item).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to requeue video: %w", err)
+	}
+	if released == 0 {
+		return ErrNotClaimed
+	}
+	return nil
+}
+
+// RequeueWorker moves all items of a worker's processing list back to the
+// front of the queue and removes the worker from workersKey. If leaseKey is
+// not empty nothing happens while it exists, and -1 is returned.
+func (q *PriorityQueue) RequeueWorker(ctx context.Context, processingKey, workersKey, leaseKey, workerID string) (int64, error) {
+	keys := []string{processingKey, PriorityQueueKey, workersKey, clockKey}
+	if leaseKey != "" {
+		keys = append(keys, leaseKey)
+	}
+	moved, err := requeueScript.Run(ctx, q.client, keys, workerID).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue claims of worker %s: %w", workerID, err)
+	}
+	return moved, nil
+}
+
+// ClaimedBy reports whether a worker has claimed a video and not finished it yet
+func (q *PriorityQueue) ClaimedBy(ctx context.Context, workerID, videoID string) (bool, error) {
+	items, err := q.client.LRange(ctx, ProcessingKey(workerID), 0, -1).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to get claims of worker %s: %w", workerID, err)
+	}
+	for _, item := range items {
+		if _, claimed, err := ParseItem(item); err == nil && claimed == videoID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ClaimVideo moves a given video to a worker's processing list, taking it off
+// the queue if it is there, and returns its item
+func (q *PriorityQueue) ClaimVideo(ctx context.Context, processingKey, channelID, videoID string) (string, error) {
+	item := Item(channelID, videoID)
+	pipe := q.client.TxPipeline()
+	pipe.ZRem(ctx, PriorityQueueKey, item)
+	pipe.LPush(ctx, processingKey, item)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to claim video: %w", err)
+	}
+	return item, nil
+}
+
+// Remove drops a video from the queue
+func (q *PriorityQueue) Remove(ctx context.Context, channelID, videoID string) error {
+	if err := q.client.ZRem(ctx, PriorityQueueKey, Item(channelID, videoID)).Err(); err != nil {
+		return fmt.Errorf("failed to remove video from queue: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of queued downloads
+func (q *PriorityQueue) Len(ctx context.Context) (int64, error) {
+	length, err := q.client.ZCard(ctx, PriorityQueueKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	return length, nil
+}
+
+// Position returns the queue entry of a video, or nil if it is not queued
+func (q *PriorityQueue) Position(ctx context.Context, channelID, videoID string) (*Entry, error) {
+	item := Item(channelID, videoID)
+	rank, err := q.client.ZRank(ctx, PriorityQueueKey, item).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue position: %w", err)
+	}
+	score, err := q.client.ZScore(ctx, PriorityQueueKey, item).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get queue priority: %w", err)
+	}
+
+	return &Entry{
+		Position:  rank + 1,
+		ChannelID: channelID,
+		VideoID:   videoID,
+		Priority:  PriorityFromScore(score),
+	}, nil
+}
+
+// Find returns the queue entry of a video of any channel, or nil if it is not queued
+func (q *PriorityQueue) Find(ctx context.Context, videoID string) (*Entry, error) {
+	iter := q.client.ZScan(ctx, PriorityQueueKey, 0, "*:"+videoID, 100).Iterator()
+	for iter.Next(ctx) {
+		channelID, itemVideoID, err := ParseItem(iter.Val())
+		if err != nil || itemVideoID != videoID {
+			continue
+		}
+		return q.Position(ctx, channelID, videoID)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search queue: %w", err)
+	}
+	return nil, nil
+}
+
+// Entries returns up to limit queued downloads in the order they will be
+// claimed, skipping the first offset
+func (q *PriorityQueue) Entries(ctx context.Context, offset, limit int64) ([]Entry, error) {
+	if limit <= 0 {
+		return []Entry{}, nil
+	}
+
+	members, err := q.client.ZRangeWithScores(ctx, PriorityQueueKey, offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queue: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(members))
+	for i, member := range members {
+		item, _ := member.Member.(string)
+		channelID, videoID, err := ParseItem(item)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, Entry{
+			Position:  offset + int64(i) + 1,
+			ChannelID: channelID,
+			VideoID:   videoID,
+			Priority:  PriorityFromScore(member.Score),
+		})
+	}
+	return entries, nil
+}
+
+// Channels summarizes the queued downloads of every channel with any queued
+func (q *PriorityQueue) Channels(ctx context.Context) (map[string]ChannelQueue, error) {
+	const pageSize = 1000
+
+	channels := make(map[string]ChannelQueue)
+	for start := int64(0); ; start += pageSize {
+		items, err := q.client.ZRange(ctx, PriorityQueueKey, start, start+pageSize-1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list queue: %w", err)
+		}
+
+		for i, item := range items {
+			channelID, _, err := ParseItem(item)
+			if err != nil {
+				continue
+			}
+			summary, ok := channels[channelID]
+			if !ok {
+				summary.NextPosition = start + int64(i) + 1
+			}
+			summary.Queued++
+			channels[channelID] = summary
+		}
+
+		if len(items) < pageSize {
+			return channels, nil
+		}
+	}
+}
+
+// MigrateLegacy moves downloads left in the legacy list into the queue as
+// backfill, oldest first, and returns how many were moved
+func (q *PriorityQueue) MigrateLegacy(ctx context.Context) (int64, error) {
+	var moved int64
+	for {
+		item, err := q.client.RPop(ctx, LegacyQueueKey).Result()
+		if err == redis.Nil {
+			return moved, nil
+		}
+		if err != nil {
+			return moved, fmt.Errorf("failed to read legacy queue: %w", err)
+		}
+
+		channelID, videoID, err := ParseItem(item)
+		if err != nil {
+			continue
+		}
+		if _, err := q.Enqueue(ctx, channelID, []string{videoID}, PriorityBackfill, DefaultWeight); err != nil {
+			// Put it back so it is not lost
+			q.client.RPush(ctx, LegacyQueueKey, item)
+			return moved, err
+		}
+		moved++
+	}
+}
@@ -0,0 +1,245 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestQueue returns a PriorityQueue on an in-memory Redis
+func newTestQueue(t *testing.T) *PriorityQueue {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewPriorityQueue(client)
+}
+
+// mustEnqueue queues videos and returns how many were added
+func mustEnqueue(t *testing.T, q *PriorityQueue, channelID string, priority Priority, weight float64, videoIDs ...string) int64 {
+	t.Helper()
+	added, err := q.Enqueue(context.Background(), channelID, videoIDs, priority, weight)
+	if err != nil {
+		t.Fatalf("Enqueue(%s) error = %v", channelID, err)
+	}
+	return added
+}
+
+// claimN claims up to n items and returns them in the order they were claimed
+func claimN(t *testing.T, q *PriorityQueue, n int) []string {
+	t.Helper()
+	var items []string
+	for i := 0; i < n; i++ {
+		item, err := q.Claim(context.Background(), ProcessingKey("worker-1"))
+		if err != nil {
+			t.Fatalf("Claim() error = %v", err)
+		}
+		if item == "" {
+			break
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestParseItem(t *testing.T) {
+	tests := []struct {
+		item        string
+		wantChannel string
+		wantVideo   string
+		wantErr     bool
+	}{
+		{"chan-1:dQw4w9WgXcQ", "chan-1", "dQw4w9WgXcQ", false},
+		{"chan-1:video:with:colons", "chan-1", "video:with:colons", false},
+		{"no-separator", "", "", true},
+		{":video", "", "", true},
+		{"chan-1:", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.item, func(t *testing.T) {
+			channelID, videoID, err := ParseItem(tt.item)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseItem(%q) error = %v, wantErr %v", tt.item, err, tt.wantErr)
+			}
+			if channelID != tt.wantChannel || videoID != tt.wantVideo {
+				t.Errorf("ParseItem(%q) = %q, %q, want %q, %q", tt.item, channelID, videoID, tt.wantChannel, tt.wantVideo)
+			}
+		})
+	}
+
+	if got := Item("chan-1", "vid"); got != "chan-1:vid" {
+		t.Errorf("Item() = %q", got)
+	}
+}
+
+func TestPriorityFromScore(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  Priority
+	}{
+		{0, PriorityManual},
+		{12.5, PriorityManual},
+		{classSpan + 0.25, PriorityNew},
+		{2*classSpan + 1e6, PriorityBackfill},
+	}
+
+	for _, tt := range tests {
+		if got := PriorityFromScore(tt.score); got != tt.want {
+			t.Errorf("PriorityFromScore(%v) = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+
+	// A late manual request must still beat the earliest backfill
+	if PriorityFromScore(classSpan-1) >= PriorityFromScore(2*classSpan) {
+		t.Error("priority classes overlap")
+	}
+}
+
+func TestNormalizeWeight(t *testing.T) {
+	tests := []struct {
+		weight float64
+		want   float64
+	}{
+		{0, DefaultWeight},
+		{-2, DefaultWeight},
+		{0.5, 0.5},
+		{3, 3},
+		{1000, MaxWeight},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeWeight(tt.weight); got != tt.want {
+			t.Errorf("NormalizeWeight(%v) = %v, want %v", tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestPriorityJSON(t *testing.T) {
+	data, err := json.Marshal(Entry{Position: 1, ChannelID: "c", VideoID: "v", Priority: PriorityNew})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"position":1,"channel_id":"c","video_id":"v","priority":"new"}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestEnqueueTakesTurnsAcrossChannels(t *testing.T) {
+	q := newTestQueue(t)
+	mustEnqueue(t, q, "chan-a", PriorityBackfill, 1, "a1", "a2", "a3", "a4")
+	mustEnqueue(t, q, "chan-b", PriorityBackfill, 1, "b1", "b2")
+
+	// chan-b was added after all of chan-a, but does not wait behind it
+	got := claimN(t, q, 10)
+	want := []string{"chan-a:a1", "chan-b:b1", "chan-a:a2", "chan-b:b2", "chan-a:a3", "chan-a:a4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("claim order = %v, want %v", got, want)
+	}
+}
+
+func TestEnqueueWeights(t *testing.T) {
+	q := newTestQueue(t)
+	mustEnqueue(t, q, "chan-a", PriorityBackfill, 1, "a1", "a2", "a3")
+	mustEnqueue(t, q, "chan-b", PriorityBackfill, 2, "b1", "b2", "b3", "b4")
+
+	// chan-b has twice the weight, so twice the turns
+	got := claimN(t, q, 6)
+	want := []string{"chan-b:b1", "chan-a:a1", "chan-b:b2", "chan-b:b3", "chan-a:a2", "chan-b:b4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("claim order = %v, want %v", got, want)
+	}
+}
+
+func TestEnqueueStartsAtClock(t *testing.T) {
+	q := newTestQueue(t)
+	mustEnqueue(t, q, "chan-a", PriorityBackfill, 1, "a1", "a2", "a3", "a4", "a5", "a6")
+	claimN(t, q, 3)
+
+	// A channel queued later starts at the current clock rather than at zero,
+	// so it takes turns with what is left instead of jumping ahead of it
+	mustEnqueue(t, q, "chan-b", PriorityBackfill, 1, "b1", "b2")
+	got := claimN(t, q, 10)
+	want := []string{"chan-a:a4", "chan-b:b1", "chan-a:a5", "chan-b:b2", "chan-a:a6"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("claim order = %v, want %v", got, want)
+	}
+}
+
+func TestEnqueuePriorityClasses(t *testing.T) {
+	q := newTestQueue(t)
+	mustEnqueue(t, q, "chan-a", PriorityBackfill, MaxWeight, "old")
+	mustEnqueue(t, q, "chan-b", PriorityNew, 1, "new1", "new2")
+	mustEnqueue(t, q, "chan-c", PriorityManual, 1, "requested")
+
+	got := claimN(t, q, 10)
+	want := []string{"chan-c:requested", "chan-b:new1", "chan-b:new2", "chan-a:old"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("claim order = %v, want %v", got, want)
+	}
+	if item, err := q.Claim(context.Background(), ProcessingKey("worker-1")); err != nil || item != "" {
+		t.Errorf("Claim() on an empty queue = %q, %v", item, err)
+	}
+}
+
+func TestEnqueueDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(t)
+
+	if added := mustEnqueue(t, q, "chan-a", PriorityBackfill, 1, "v1", "v2"); added != 2 {
+		t.Fatalf("Enqueue() added %d, want 2", added)
+	}
+	before, _ := q.Position(ctx, "chan-a", "v1")
+
+	// Queued again at the same priority: not added, and keeps its place
+	if added := mustEnqueue(t, q, "chan-a", PriorityBackfill, 1, "v1", "v3"); added != 1 {
+		t.Errorf("Enqueue() of a queued video added %d, want 1", added)
+	}
+	if length, _ := q.Len(ctx); length != 3 {
+		t.Errorf("Len() = %d, want 3", length)
+	}
+	after, _ := q.Position(ctx, "chan-a", "v1")
+	if after == nil || after.Position != before.Position {
+		t.Errorf("Position() = %+v, want %+v", after, before)
+	}
+
+	// A manual request moves it up
+	if added := mustEnqueue(t, q, "chan-a", PriorityManual, 1, "v2"); added != 0 {
+		t.Errorf("Enqueue() at a higher priority added %d, want 0", added)
+	}
+	moved, _ := q.Position(ctx, "chan-a", "v2")
+	if moved == nil || moved.Position != 1 || moved.Priority != PriorityManual {
+		t.Errorf("Position() after moving up = %+v, want first and manual", moved)
+	}
+
+	// A lower priority never moves it down again
+	mustEnqueue(t, q, "chan-a", PriorityBackfill, 1, "v2")
+	if entry, _ := q.Position(ctx, "chan-a", "v2"); entry == nil || entry.Priority != PriorityManual {
+		t.Errorf("Position() after backfill = %+v, want manual", entry)
+	}
+}
+
+func TestReleaseGoesToFront(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(t)
+	mustEnqueue(t, q, "chan-a", PriorityNew, 1, "v1", "v2")
+
+	item, err := q.Claim(ctx, ProcessingKey("worker-1"))
+	if err != nil || item != "chan-a:v1" {
+		t.Fatalf("Claim() = %q, %v", item, err)
+	}
+	if err := q.Release(ctx, ProcessingKey("worker-1"), item); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	got := claimN(t, q, 10)
+	want := []string{"chan-a:v1", "chan-a:v2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("claim order after release = %v, want %v", got, want)
+	}
+}
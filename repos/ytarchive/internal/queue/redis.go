@@ -18,7 +18,10 @@ const (
 	DefaultTimeout = 5 * time.Second
 )
 
-// Queue represents a Redis-backed queue for video downloads.
+// Queue represents a Redis-backed queue for video downloads, with one list per channel.
+//
+// Deprecated: downloads of all channels are scheduled through PriorityQueue.
+// Queue remains for its job status tracking.
 type Queue struct {
 	client *redis.Client
 	ctx    context.Context
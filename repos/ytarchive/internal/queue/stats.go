@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// DownloadTimesKey is the list of the most recent download times, in seconds
+	DownloadTimesKey = "ytarchive:download:times"
+	// downloadTimesKept is how many download times the average is taken over
+	downloadTimesKept = 100
+)
+
+// RecordDownloadTime records how long a successful download took
+func (q *PriorityQueue) RecordDownloadTime(ctx context.Context, d time.Duration) error {
+	pipe := q.client.TxPipeline()
+	pipe.LPush(ctx, DownloadTimesKey, strconv.FormatFloat(d.Seconds(), 'f', 1, 64))
+	pipe.LTrim(ctx, DownloadTimesKey, 0, downloadTimesKept-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record download time: %w", err)
+	}
+	return nil
+}
+
+// AverageDownloadTime returns the average time of the recent downloads and
+// how many it was taken over; 0 if none were recorded
+func (q *PriorityQueue) AverageDownloadTime(ctx context.Context) (time.Duration, int, error) {
+	values, err := q.client.LRange(ctx, DownloadTimesKey, 0, -1).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get download times: %w", err)
+	}
+	avg, n := averageSeconds(values)
+	return avg, n, nil
+}
+
+// InProgress returns the number of downloads claimed by workers and not finished yet
+func (q *PriorityQueue) InProgress(ctx context.Context) (int64, error) {
+	var total int64
+	var cursor uint64
+	for {
+		keys, nextCursor, err := q.client.Scan(ctx, cursor, ProcessingKeyPrefix+"*", 100).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan processing lists: %w", err)
+		}
+		for _, key := range keys {
+			n, err := q.client.LLen(ctx, key).Result()
+			if err != nil {
+				return 0, fmt.Errorf("failed to get processing list length: %w", err)
+			}
+			total += n
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return total, nil
+		}
+	}
+}
+
+// averageSeconds averages durations stored as seconds, skipping malformed
+// ones, and returns how many were averaged
+func averageSeconds(values []string) (time.Duration, int) {
+	var sum float64
+	var n int
+	for _, value := range values {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		sum += seconds
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return time.Duration(sum / float64(n) * float64(time.Second)), n
+}
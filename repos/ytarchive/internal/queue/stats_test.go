@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAverageSeconds(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   time.Duration
+		wantN  int
+	}{
+		{"none", nil, 0, 0},
+		{"one", []string{"90.0"}, 90 * time.Second, 1},
+		{"several", []string{"60.0", "120.0", "30.0"}, 70 * time.Second, 3},
+		{"skips malformed", []string{"60.0", "slow", "-5", "120.0"}, 90 * time.Second, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := averageSeconds(tt.values)
+			if got != tt.want || n != tt.wantN {
+				t.Errorf("averageSeconds(%q) = %v, %d, want %v, %d", tt.values, got, n, tt.want, tt.wantN)
+			}
+		})
+	}
+}
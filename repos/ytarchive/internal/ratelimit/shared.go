@@ -0,0 +1,257 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/metrics"
+)
+
+// Budget is a class of YouTube requests with its own rate limit
+type Budget string
+
+const (
+	// BudgetPlayer is the player endpoint: stream URLs, metadata and caption lists
+	BudgetPlayer Budget = "player"
+	// BudgetBrowse is the browse, next and resolve endpoints: listings and comments
+	BudgetBrowse Budget = "browse"
+	// BudgetMedia is downloads of streams, captions and manifests
+	BudgetMedia Budget = "media"
+)
+
+// Budgets lists every budget
+var Budgets = []Budget{BudgetPlayer, BudgetBrowse, BudgetMedia}
+
+// BudgetLimiter limits requests separately for each budget
+type BudgetLimiter interface {
+	// Wait blocks until a request of a budget may be made or the context is cancelled
+	Wait(ctx context.Context, budget Budget) error
+	// Backoff lowers the rate of a budget after YouTube pushed back with a 429
+	// or a bot check
+	Backoff(ctx context.Context, budget Budget)
+}
+
+// Limit is the rate of a budget
+type Limit struct {
+	Rate  float64 // requests per second
+	Burst int
+}
+
+const (
+	// sharedKeyPrefix starts the Redis hash holding each budget's bucket
+	sharedKeyPrefix = "ratelimit:"
+
+	// backoffHold is how long after one backoff further ones are ignored, so
+	// a burst of 429s seen by many workers at once only halves the rate once
+	backoffHold = 5 * time.Second
+)
+
+// SharedConfig configures a Shared limiter
+type SharedConfig struct {
+	Limits    map[Budget]Limit
+	MinFactor float64       // lowest fraction of a rate backoffs go down to
+	Recovery  time.Duration // time to recover from the lowest rate to the full one; 0 disables backoff
+}
+
+// DefaultSharedConfig returns the limits shared by the whole cluster
+func DefaultSharedConfig() SharedConfig {
+	return SharedConfig{
+		Limits: map[Budget]Limit{
+			BudgetPlayer: {Rate: 2, Burst: 5},
+			BudgetBrowse: {Rate: 2, Burst: 5},
+			BudgetMedia:  {Rate: 20, Burst: 40},
+		},
+		MinFactor: 0.1,
+		Recovery:  10 * time.Minute,
+	}
+}
+
+// SharedConfigFromEnv returns the default limits, overridden by
+// RATE_LIMIT_<BUDGET> and RATE_LIMIT_<BUDGET>_BURST, RATE_LIMIT_MIN_FACTOR
+// and RATE_LIMIT_RECOVERY_MINUTES
+func SharedConfigFromEnv() SharedConfig {
+	config := DefaultSharedConfig()
+	for _, budget := range Budgets {
+		limit := config.Limits[budget]
+		name := "RATE_LIMIT_" + strings.ToUpper(string(budget))
+		if rate, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && rate > 0 {
+			limit.Rate = rate
+		}
+		if burst, err := strconv.Atoi(os.Getenv(name + "_BURST")); err == nil && burst > 0 {
+			limit.Burst = burst
+		}
+		config.Limits[budget] = limit
+	}
+	if factor, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_MIN_FACTOR"), 64); err == nil && factor > 0 && factor <= 1 {
+		config.MinFactor = factor
+	}
+	if minutes, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_RECOVERY_MINUTES"), 64); err == nil && minutes >= 0 {
+		config.Recovery = time.Duration(minutes * float64(time.Minute))
+	}
+	return config
+}
+
+// sharedScript takes a token from, or backs off, a budget's bucket. The
+// bucket refills at the budget's rate times its current factor. A backoff
+// halves the factor, down to the minimum, and the factor then recovers
+// linearly to 1 over the recovery time. Redis's clock is used so every
+// process agrees on the time.
+//
+// It returns the microseconds to wait before the request, the current factor
+// in millionths, and whether a backoff was applied.
+var sharedScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local min_factor = tonumber(ARGV[3])
+local recovery = tonumber(ARGV[4])
+local hold = tonumber(ARGV[5])
+local backoff = ARGV[6] == "1"
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated", "factor", "backoff_at")
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+local factor = tonumber(state[3]) or 1
+local backoff_at = tonumber(state[4]) or 0
+
+local current = 1
+if recovery > 0 then
+  current = math.min(1, factor + math.max(0, now - backoff_at) / recovery * (1 - min_factor))
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate * current)
+
+local applied = 0
+local wait = 0
+if backoff then
+  if recovery > 0 and now - backoff_at >= hold then
+    current = math.max(min_factor, current / 2)
+    redis.call("HSET", KEYS[1], "factor", current, "backoff_at", now)
+    applied = 1
+  end
+else
+  tokens = tokens - 1
+  if tokens < 0 then
+    wait = -tokens / (rate * current)
+  end
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "updated", now)
+return {math.floor(wait * 1000000), math.floor(current * 1000000), applied}
+`)
+
+// Shared is a BudgetLimiter whose budgets are shared, through Redis, by every
+// process using it: the controller and all workers together stay within the
+// configured rates. Everyone slows down when one of them is pushed back.
+// While Redis cannot be reached, each process falls back to limiting itself
+// to the full rates.
+type Shared struct {
+	redis  *redis.Client
+	config SharedConfig
+	local  map[Budget]*Limiter
+}
+
+// NewShared creates a limiter shared through a Redis client
+func NewShared(client *redis.Client, config SharedConfig) *Shared {
+	local := make(map[Budget]*Limiter, len(config.Limits))
+	for budget, limit := range config.Limits {
+		local[budget] = NewLimiter(limit.Rate, limit.Burst)
+	}
+	return &Shared{redis: client, config: config, local: local}
+}
+
+// Wait blocks until a request of a budget may be made or the context is cancelled
+func (s *Shared) Wait(ctx context.Context, budget Budget) error {
+	limit, ok := s.config.Limits[budget]
+	if !ok {
+		return nil
+	}
+
+	wait, _, _, err := s.run(ctx, budget, limit, false)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return s.local[budget].Wait(ctx)
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Backoff halves the rate of a budget for everyone sharing it. Backoffs
+// within a few seconds of the last one are ignored.
+func (s *Shared) Backoff(ctx context.Context, budget Budget) {
+	limit, ok := s.config.Limits[budget]
+	if !ok {
+		return
+	}
+	if _, _, applied, err := s.run(ctx, budget, limit, true); err == nil && applied {
+		metrics.RecordRateLimitBackoff(string(budget))
+	}
+}
+
+// run runs the bucket script for a budget
+func (s *Shared) run(ctx context.Context, budget Budget, limit Limit, backoff bool) (time.Duration, float64, bool, error) {
+	flag := "0"
+	if backoff {
+		flag = "1"
+	}
+	result, err := sharedScript.Run(ctx, s.redis, []string{sharedKeyPrefix + string(budget)},
+		limit.Rate,
+		limit.Burst,
+		s.config.MinFactor,
+		s.config.Recovery.Seconds(),
+		backoffHold.Seconds(),
+		flag,
+	).Slice()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if len(result) != 3 {
+		return 0, 0, false, redis.Nil
+	}
+
+	waitMicros, _ := result[0].(int64)
+	factorMillionths, _ := result[1].(int64)
+	applied, _ := result[2].(int64)
+
+	factor := float64(factorMillionths) / 1e6
+	metrics.RecordRateLimitFactor(string(budget), factor)
+	return time.Duration(waitMicros) * time.Microsecond, factor, applied == 1, nil
+}
+
+// Local is a BudgetLimiter for a single process, with one token bucket for
+// all budgets. It does not back off.
+type Local struct {
+	limiter *Limiter
+}
+
+// NewLocal creates a limiter for a single process
+func NewLocal(rate float64, burst int) *Local {
+	return &Local{limiter: NewLimiter(rate, burst)}
+}
+
+// Wait blocks until a request may be made or the context is cancelled
+func (l *Local) Wait(ctx context.Context, _ Budget) error {
+	return l.limiter.Wait(ctx)
+}
+
+// Backoff does nothing; a single process has nobody else to slow down
+func (l *Local) Backoff(context.Context, Budget) {}
@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/timholm/ytarchive/internal/metrics"
+)
+
+// testRedis is an in-memory Redis whose clock only moves when advanced
+type testRedis struct {
+	*miniredis.Miniredis
+	now time.Time
+}
+
+// advance moves the Redis clock forward
+func (r *testRedis) advance(d time.Duration) {
+	r.now = r.now.Add(d)
+	r.SetTime(r.now)
+}
+
+// newTestShared starts an in-memory Redis with its clock stopped and shares a
+// limiter with one player budget through it
+func newTestShared(t *testing.T, limit Limit, recovery time.Duration) (*testRedis, *Shared) {
+	t.Helper()
+	server := &testRedis{Miniredis: miniredis.RunT(t), now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	server.SetTime(server.now)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	config := SharedConfig{
+		Limits:    map[Budget]Limit{BudgetPlayer: limit},
+		MinFactor: 0.1,
+		Recovery:  recovery,
+	}
+	return server, NewShared(client, config)
+}
+
+// storedFactor returns the factor a budget was last lowered to, or 1 if it
+// never was
+func storedFactor(t *testing.T, server *testRedis, budget Budget) float64 {
+	t.Helper()
+	value := server.HGet(sharedKeyPrefix+string(budget), "factor")
+	if value == "" {
+		return 1
+	}
+	factor, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		t.Fatalf("factor = %q: %v", value, err)
+	}
+	return factor
+}
+
+// timedWait returns how long Wait blocked
+func timedWait(t *testing.T, s *Shared, budget Budget) time.Duration {
+	t.Helper()
+	start := time.Now()
+	if err := s.Wait(context.Background(), budget); err != nil {
+		t.Fatalf("Wait(%s) error = %v", budget, err)
+	}
+	return time.Since(start)
+}
+
+func TestSharedWait(t *testing.T) {
+	server, s := newTestShared(t, Limit{Rate: 10, Burst: 2}, 10*time.Minute)
+
+	// The Redis clock is stopped, so the bucket only refills when it is advanced
+	for i := 0; i < 2; i++ {
+		if elapsed := timedWait(t, s, BudgetPlayer); elapsed > 50*time.Millisecond {
+			t.Errorf("burst request %d waited %v", i, elapsed)
+		}
+	}
+	if elapsed := timedWait(t, s, BudgetPlayer); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("request after the burst waited %v, want about 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Wait(ctx, BudgetPlayer); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() on an empty bucket error = %v, want the context's", err)
+	}
+
+	server.advance(time.Second)
+	if elapsed := timedWait(t, s, BudgetPlayer); elapsed > 50*time.Millisecond {
+		t.Errorf("request after the bucket refilled waited %v", elapsed)
+	}
+
+	// Budgets without a limit are not limited
+	for i := 0; i < 5; i++ {
+		if elapsed := timedWait(t, s, BudgetMedia); elapsed > 50*time.Millisecond {
+			t.Errorf("unlimited request %d waited %v", i, elapsed)
+		}
+	}
+}
+
+func TestSharedWaitWithoutRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	s := NewShared(client, SharedConfig{Limits: map[Budget]Limit{BudgetPlayer: {Rate: 10, Burst: 1}}})
+	server.Close()
+
+	// Each process falls back to the full rate on its own
+	if elapsed := timedWait(t, s, BudgetPlayer); elapsed > 50*time.Millisecond {
+		t.Errorf("first request waited %v", elapsed)
+	}
+	if elapsed := timedWait(t, s, BudgetPlayer); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("second request waited %v, want about 100ms", elapsed)
+	}
+}
+
+func TestSharedBackoff(t *testing.T) {
+	ctx := context.Background()
+	server, s := newTestShared(t, Limit{Rate: 10, Burst: 1}, 10*time.Minute)
+	backoffs := func() float64 {
+		return testutil.ToFloat64(metrics.RateLimitBackoffs.WithLabelValues(string(BudgetPlayer)))
+	}
+	before := backoffs()
+
+	s.Backoff(ctx, BudgetPlayer)
+	if got := storedFactor(t, server, BudgetPlayer); got != 0.5 {
+		t.Fatalf("factor after a backoff = %v, want 0.5", got)
+	}
+
+	// Other workers seeing the same burst of 429s do not halve it again
+	server.advance(time.Second)
+	s.Backoff(ctx, BudgetPlayer)
+	if got := storedFactor(t, server, BudgetPlayer); got != 0.5 {
+		t.Errorf("factor after a backoff within the hold = %v, want 0.5", got)
+	}
+	if got := backoffs() - before; got != 1 {
+		t.Errorf("recorded backoffs = %v, want 1", got)
+	}
+
+	// The bucket refills at half the rate: 200ms per request instead of 100ms
+	timedWait(t, s, BudgetPlayer)
+	if elapsed := timedWait(t, s, BudgetPlayer); elapsed < 150*time.Millisecond || elapsed > 700*time.Millisecond {
+		t.Errorf("request at half the rate waited %v, want about 200ms", elapsed)
+	}
+
+	// Backoffs after the hold keep halving the recovered factor, down to the minimum
+	server.advance(10 * time.Second)
+	s.Backoff(ctx, BudgetPlayer)
+	if got, want := storedFactor(t, server, BudgetPlayer), (0.5+11.0/600*0.9)/2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("factor after a second backoff = %v, want %v", got, want)
+	}
+	for i := 0; i < 5; i++ {
+		server.advance(backoffHold)
+		s.Backoff(ctx, BudgetPlayer)
+	}
+	if got := storedFactor(t, server, BudgetPlayer); got != 0.1 {
+		t.Errorf("factor after repeated backoffs = %v, want the minimum 0.1", got)
+	}
+	if got := backoffs() - before; got != 7 {
+		t.Errorf("recorded backoffs = %v, want 7", got)
+	}
+
+	// The factor climbs linearly from the minimum to 1 over the recovery time
+	factor := func() float64 {
+		return testutil.ToFloat64(metrics.RateLimitFactor.WithLabelValues(string(BudgetPlayer)))
+	}
+	server.advance(5 * time.Minute)
+	timedWait(t, s, BudgetPlayer)
+	if got := factor(); math.Abs(got-0.55) > 1e-6 {
+		t.Errorf("factor halfway through recovery = %v, want 0.55", got)
+	}
+	server.advance(time.Hour)
+	timedWait(t, s, BudgetPlayer)
+	if got := factor(); got != 1 {
+		t.Errorf("factor after recovery = %v, want 1", got)
+	}
+}
+
+func TestSharedBackoffDisabled(t *testing.T) {
+	server, s := newTestShared(t, Limit{Rate: 10, Burst: 1}, 0)
+
+	s.Backoff(context.Background(), BudgetPlayer)
+	if got := storedFactor(t, server, BudgetPlayer); got != 1 {
+		t.Errorf("factor with recovery disabled = %v, want 1", got)
+	}
+}
+
+func TestSharedConfigFromEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT_PLAYER", "0.5")
+	t.Setenv("RATE_LIMIT_PLAYER_BURST", "2")
+	t.Setenv("RATE_LIMIT_BROWSE", "invalid")
+	t.Setenv("RATE_LIMIT_MEDIA_BURST", "-1")
+	t.Setenv("RATE_LIMIT_MIN_FACTOR", "0.2")
+	t.Setenv("RATE_LIMIT_RECOVERY_MINUTES", "0")
+
+	config := SharedConfigFromEnv()
+	defaults := DefaultSharedConfig()
+
+	if got := config.Limits[BudgetPlayer]; got != (Limit{Rate: 0.5, Burst: 2}) {
+		t.Errorf("player limit = %+v, want 0.5/2", got)
+	}
+	if got := config.Limits[BudgetBrowse]; got != defaults.Limits[BudgetBrowse] {
+		t.Errorf("browse limit = %+v, want the default %+v", got, defaults.Limits[BudgetBrowse])
+	}
+	if got := config.Limits[BudgetMedia]; got != defaults.Limits[BudgetMedia] {
+		t.Errorf("media limit = %+v, want the default %+v", got, defaults.Limits[BudgetMedia])
+	}
+	if config.MinFactor != 0.2 {
+		t.Errorf("MinFactor = %v, want 0.2", config.MinFactor)
+	}
+	if config.Recovery != 0 {
+		t.Errorf("Recovery = %v, want 0", config.Recovery)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	// One bucket is shared by all budgets
+	local := NewLocal(10, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for _, budget := range []Budget{BudgetPlayer, BudgetBrowse} {
+		if err := local.Wait(ctx, budget); err != nil {
+			t.Fatalf("Wait(%s) error = %v", budget, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("burst took too long: %v", elapsed)
+	}
+
+	// Backing off does nothing for a single process
+	local.Backoff(ctx, BudgetPlayer)
+
+	start = time.Now()
+	if err := local.Wait(ctx, BudgetMedia); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("third request waited %v, want about 100ms", elapsed)
+	}
+}
@@ -0,0 +1,268 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// Sync backends, selected with SYNC_BACKEND
+const (
+	// SyncBackendJobs discovers videos in the controller and queues them for
+	// the workers scaled by KEDA
+	SyncBackendJobs = "jobs"
+	// SyncBackendArgo submits an Argo workflow per sync, which discovers,
+	// downloads, verifies and exports the channel's videos in its own steps
+	SyncBackendArgo = "argo"
+)
+
+// DefaultSyncTemplate is the WorkflowTemplate sync workflows are created from
+const DefaultSyncTemplate = "ytarchive-sync"
+
+// Names of the steps of the sync WorkflowTemplate the scheduler follows
+const (
+	workflowStepDiscover = "discover"
+	workflowStepDownload = "download"
+)
+
+// How often the status of a sync workflow is checked
+const workflowPollInterval = 10 * time.Second
+
+var workflowGVR = schema.GroupVersionResource{
+	Group:    "argoproj.io",
+	Version:  "v1alpha1",
+	Resource: "workflows",
+}
+
+// WorkflowRunner submits sync workflows to Argo and reads their status.
+// There is no Argo client library in the build, so workflows are handled as
+// unstructured objects through the dynamic client.
+type WorkflowRunner struct {
+	client    dynamic.Interface
+	namespace string
+	template  string
+}
+
+// NewWorkflowRunner creates a WorkflowRunner that submits workflows from a
+// WorkflowTemplate in a namespace
+func NewWorkflowRunner(config *rest.Config, namespace, template string) (*WorkflowRunner, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	if template == "" {
+		template = DefaultSyncTemplate
+	}
+	return &WorkflowRunner{client: client, namespace: namespace, template: template}, nil
+}
+
+// Submit creates the workflow of a sync job and returns its name
+func (w *WorkflowRunner) Submit(ctx context.Context, syncJobID, channelID, youtubeID string) (string, error) {
+	workflow := syncWorkflow(w.namespace, w.template, syncJobID, channelID, youtubeID)
+	created, err := w.client.Resource(workflowGVR).Namespace(w.namespace).Create(ctx, workflow, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to submit workflow: %w", err)
+	}
+	return created.GetName(), nil
+}
+
+// Get returns a workflow by name
+func (w *WorkflowRunner) Get(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+	return w.client.Resource(workflowGVR).Namespace(w.namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// syncWorkflow builds the workflow of a sync job from the sync WorkflowTemplate
+func syncWorkflow(namespace, template, syncJobID, channelID, youtubeID string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Workflow",
+		"metadata": map[string]interface{}{
+			"generateName": "ytarchive-sync-",
+			"namespace":    namespace,
+			"labels": map[string]interface{}{
+				"app":                     "ytarchive",
+				"component":               "sync",
+				"ytarchive.io/channel-id": channelID,
+				"ytarchive.io/sync-job":   syncJobID,
+			},
+		},
+		"spec": map[string]interface{}{
+			"workflowTemplateRef": map[string]interface{}{
+				"name": template,
+			},
+			"arguments": map[string]interface{}{
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "sync-job-id", "value": syncJobID},
+					map[string]interface{}{"name": "channel-id", "value": channelID},
+					map[string]interface{}{"name": "youtube-id", "value": youtubeID},
+				},
+			},
+		},
+	}}
+}
+
+// workflowSyncStatus maps the phase of a sync workflow and its steps to a
+// sync job status: pending until the discover step runs, discovering while it
+// does, running through downloads, verification and export, then completed or
+// failed with the workflow
+func workflowSyncStatus(workflow *unstructured.Unstructured) string {
+	phase, _, _ := unstructured.NestedString(workflow.Object, "status", "phase")
+	switch phase {
+	case "Succeeded":
+		return "completed"
+	case "Failed", "Error":
+		return "failed"
+	case "Running":
+	default:
+		return "pending"
+	}
+
+	nodes, _, _ := unstructured.NestedMap(workflow.Object, "status", "nodes")
+	status := "pending"
+	for _, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := node["displayName"].(string)
+		nodePhase, _ := node["phase"].(string)
+		switch name {
+		case workflowStepDiscover:
+			if nodePhase == "Succeeded" {
+				return "running"
+			}
+			status = "discovering"
+		case workflowStepDownload:
+			return "running"
+		}
+	}
+	return status
+}
+
+// startWorkflowSync submits the workflow of a sync job, records it on the job
+// and follows it in the background
+func (s *Scheduler) startWorkflowSync(ctx context.Context, syncJobID, channelID, youtubeID string) error {
+	name, err := s.workflows.Submit(ctx, syncJobID, channelID, youtubeID)
+	if err != nil {
+		return err
+	}
+	s.updateSyncJobWorkflow(ctx, syncJobID, name)
+	s.updateChannelStatus(ctx, channelID, "syncing")
+
+	logging.Info("sync workflow submitted",
+		"job_id", syncJobID,
+		"channel_id", channelID,
+		"workflow", name,
+	)
+	go s.watchSyncWorkflow(syncJobID, channelID, name, true)
+	return nil
+}
+
+// watchSyncWorkflow follows a sync workflow until it finishes, feeding its
+// status to the sync job and the channel. The sync slot, if held, is released
+// once discovery is over, as with the jobs backend.
+func (s *Scheduler) watchSyncWorkflow(syncJobID, channelID, name string, holdsSlot bool) {
+	ctx := context.Background()
+	releaseSlot := func() {
+		if holdsSlot {
+			s.releaseSyncSlot(ctx, channelID)
+			holdsSlot = false
+		}
+	}
+	defer releaseSlot()
+
+	ticker := time.NewTicker(workflowPollInterval)
+	defer ticker.Stop()
+
+	last := ""
+	for range ticker.C {
+		workflow, err := s.workflows.Get(ctx, name)
+		if apierrors.IsNotFound(err) {
+			logging.Warn("sync workflow deleted", "job_id", syncJobID, "workflow", name)
+			s.updateSyncJobStatus(ctx, syncJobID, "failed")
+			s.updateChannelStatus(ctx, channelID, "error")
+			return
+		}
+		if err != nil {
+			logging.Warn("failed to get sync workflow", "job_id", syncJobID, "workflow", name, "error", err)
+			continue
+		}
+
+		status := workflowSyncStatus(workflow)
+		if status != last {
+			s.updateSyncJobStatus(ctx, syncJobID, status)
+			logging.Info("sync workflow status",
+				"job_id", syncJobID,
+				"channel_id", channelID,
+				"workflow", name,
+				"status", status,
+			)
+			last = status
+		}
+		if status != "pending" && status != "discovering" {
+			releaseSlot()
+		}
+
+		switch status {
+		case "running":
+			downloaded, failed := s.countVideoStatuses(ctx, channelID)
+			s.updateSyncJobProgress(ctx, syncJobID, downloaded, failed)
+		case "completed":
+			s.updateChannelStatus(ctx, channelID, "synced")
+			return
+		case "failed":
+			s.updateChannelStatus(ctx, channelID, "error")
+			return
+		}
+	}
+}
+
+// resumeWorkflowSyncs follows the sync workflows that were still running when
+// the controller stopped. Workflows keep running without the controller, so
+// syncs that were discovering take their sync slot back. The jobs come from
+// the store, as the cached ones expire while a long sync still runs.
+func (s *Scheduler) resumeWorkflowSyncs(ctx context.Context) {
+	jobs, err := s.records.Store().ListUnfinishedSyncJobs(ctx)
+	if err != nil {
+		logging.Warn("failed to list unfinished sync jobs", "error", err)
+		return
+	}
+
+	for i := range jobs {
+		var syncJob SyncJob
+		if err := json.Unmarshal(jobs[i].Document(), &syncJob); err != nil {
+			continue
+		}
+		if syncJob.Workflow == "" {
+			continue
+		}
+
+		holdsSlot := false
+		if syncJob.Status == "pending" || syncJob.Status == "discovering" {
+			holdsSlot = s.acquireSyncSlot(ctx, syncJob.ChannelID) == nil
+		}
+		logging.Info("resuming sync workflow",
+			"job_id", syncJob.ID,
+			"channel_id", syncJob.ChannelID,
+			"workflow", syncJob.Workflow,
+		)
+		go s.watchSyncWorkflow(syncJob.ID, syncJob.ChannelID, syncJob.Workflow, holdsSlot)
+	}
+}
+
+// updateSyncJobWorkflow records the workflow running a sync job
+func (s *Scheduler) updateSyncJobWorkflow(ctx context.Context, syncJobID, workflow string) {
+	s.updateSyncJob(ctx, syncJobID, func(syncJob *SyncJob) {
+		syncJob.Workflow = workflow
+	})
+}
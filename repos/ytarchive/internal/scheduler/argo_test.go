@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSyncWorkflow(t *testing.T) {
+	workflow := syncWorkflow("ytarchive", "ytarchive-sync", "job-1", "c1", "UC123")
+
+	if workflow.GetKind() != "Workflow" || workflow.GetNamespace() != "ytarchive" {
+		t.Errorf("got %s in %q, want Workflow in ytarchive", workflow.GetKind(), workflow.GetNamespace())
+	}
+	if got := workflow.GetLabels()["ytarchive.io/sync-job"]; got != "job-1" {
+		t.Errorf("sync job label = %q, want job-1", got)
+	}
+	template, _, _ := unstructured.NestedString(workflow.Object, "spec", "workflowTemplateRef", "name")
+	if template != "ytarchive-sync" {
+		t.Errorf("template = %q, want ytarchive-sync", template)
+	}
+
+	parameters, _, _ := unstructured.NestedSlice(workflow.Object, "spec", "arguments", "parameters")
+	got := make(map[string]string)
+	for _, p := range parameters {
+		parameter := p.(map[string]interface{})
+		got[parameter["name"].(string)] = parameter["value"].(string)
+	}
+	want := map[string]string{"sync-job-id": "job-1", "channel-id": "c1", "youtube-id": "UC123"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parameters = %v, want %v", got, want)
+	}
+}
+
+func TestWorkflowSyncStatus(t *testing.T) {
+	node := func(name, phase string) map[string]interface{} {
+		return map[string]interface{}{"displayName": name, "phase": phase}
+	}
+
+	tests := []struct {
+		name  string
+		phase string
+		nodes map[string]interface{}
+		want  string
+	}{
+		{"not started", "", nil, "pending"},
+		{"pending", "Pending", nil, "pending"},
+		{"running without nodes", "Running", nil, "pending"},
+		{"discovering", "Running", map[string]interface{}{
+			"wf":   node("ytarchive-sync-abc", "Running"),
+			"d":    node("discover", "Running"),
+			"d(0)": node("discover(0)", "Running"),
+		}, "discovering"},
+		{"discovery retrying", "Running", map[string]interface{}{
+			"d":    node("discover", "Running"),
+			"d(0)": node("discover(0)", "Failed"),
+		}, "discovering"},
+		{"downloading", "Running", map[string]interface{}{
+			"d":  node("discover", "Succeeded"),
+			"dl": node("download", "Running"),
+		}, "running"},
+		{"verifying", "Running", map[string]interface{}{
+			"d": node("discover", "Succeeded"),
+			"v": node("verify", "Running"),
+		}, "running"},
+		{"succeeded", "Succeeded", nil, "completed"},
+		{"failed", "Failed", nil, "failed"},
+		{"error", "Error", nil, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &unstructured.Unstructured{Object: map[string]interface{}{
+				"status": map[string]interface{}{"phase": tt.phase, "nodes": tt.nodes},
+			}}
+			if got := workflowSyncStatus(workflow); got != tt.want {
+				t.Errorf("workflowSyncStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		videoIDs []string
+		size     int
+		want     []string
+	}{
+		{"empty", nil, 10, []string{}},
+		{"one batch", []string{"a", "b"}, 10, []string{"a,b"}},
+		{"exact", []string{"a", "b", "c", "d"}, 2, []string{"a,b", "c,d"}},
+		{"remainder", []string{"a", "b", "c"}, 2, []string{"a,b", "c"}},
+		{"invalid size", []string{"a", "b"}, 0, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SyncBatches(tt.videoIDs, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SyncBatches() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/metrics"
+)
+
+// Worker autoscalers, selected with WORKER_AUTOSCALER
+const (
+	// AutoscalerNone leaves the worker deployment's replicas alone, to a fixed
+	// count or an external autoscaler reading the queue metrics
+	AutoscalerNone = "none"
+	// AutoscalerController has the controller scale the worker deployment
+	AutoscalerController = "controller"
+)
+
+// defaultDownloadTime is assumed until workers have recorded download times
+const defaultDownloadTime = 5 * time.Minute
+
+// autoscaleConfig is how the worker count follows the download queue
+type autoscaleConfig struct {
+	autoscaler     string
+	deployment     string        // worker deployment to scale
+	minWorkers     int           // workers kept with an empty queue
+	maxWorkers     int           // cluster-wide limit
+	drainTime      time.Duration // time the queue should be downloaded in
+	scaleDownDelay time.Duration // time the queue must need fewer workers before they are removed
+	interval       time.Duration
+}
+
+// autoscaleConfigFromEnv reads the autoscaling settings
+func autoscaleConfigFromEnv() autoscaleConfig {
+	cfg := autoscaleConfig{
+		autoscaler:     getEnvWithDefault("WORKER_AUTOSCALER", AutoscalerNone),
+		deployment:     getEnvWithDefault("WORKER_DEPLOYMENT", "ytarchive-worker"),
+		minWorkers:     envInt("MIN_WORKERS", 0),
+		maxWorkers:     envInt("MAX_WORKERS", 5),
+		drainTime:      time.Duration(envInt("AUTOSCALE_DRAIN_MINUTES", 60)) * time.Minute,
+		scaleDownDelay: time.Duration(envInt("AUTOSCALE_SCALE_DOWN_MINUTES", 10)) * time.Minute,
+		interval:       time.Duration(envInt("AUTOSCALE_INTERVAL_SECONDS", 30)) * time.Second,
+	}
+	if cfg.maxWorkers < cfg.minWorkers {
+		cfg.maxWorkers = cfg.minWorkers
+	}
+	if cfg.drainTime <= 0 {
+		cfg.drainTime = time.Hour
+	}
+	if cfg.interval <= 0 {
+		cfg.interval = 30 * time.Second
+	}
+	return cfg
+}
+
+// envInt returns a non-negative integer environment variable, or a default
+func envInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnvWithDefault(key, strconv.Itoa(defaultValue)))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// desiredWorkers returns the workers needed to download the queued and
+// claimed videos within the drain time, when each takes avg. There are never
+// more workers than videos, nor fewer than the videos being downloaded, so a
+// scale down does not interrupt them; the limits apply last.
+func desiredWorkers(queued, inProgress int64, avg time.Duration, cfg autoscaleConfig) int {
+	if avg <= 0 {
+		avg = defaultDownloadTime
+	}
+	backlog := queued + inProgress
+
+	desired := int64(math.Ceil(float64(backlog) * avg.Seconds() / cfg.drainTime.Seconds()))
+	if desired > backlog {
+		desired = backlog
+	}
+	if desired < inProgress {
+		desired = inProgress
+	}
+
+	switch {
+	case desired < int64(cfg.minWorkers):
+		return cfg.minWorkers
+	case desired > int64(cfg.maxWorkers):
+		return cfg.maxWorkers
+	}
+	return int(desired)
+}
+
+// scaleTarget returns the replica count to set: more workers are added right
+// away, but workers are only removed once fewer have been needed for the
+// scale down delay. lowSince is when fewer workers were first needed, zero if
+// they are not; the updated value is returned.
+func scaleTarget(current, desired int, lowSince, now time.Time, delay time.Duration) (int, time.Time) {
+	if desired >= current {
+		return desired, time.Time{}
+	}
+	if lowSince.IsZero() {
+		return current, now
+	}
+	if now.Sub(lowSince) < delay {
+		return current, lowSince
+	}
+	return desired, time.Time{}
+}
+
+// autoscaleLoop keeps the queue metrics current and, with the controller
+// autoscaler, reconciles the worker count against the queue
+func (s *Scheduler) autoscaleLoop() {
+	cfg := autoscaleConfigFromEnv()
+	if cfg.autoscaler == AutoscalerController {
+		logging.Info("autoscaling workers",
+			"deployment", cfg.deployment,
+			"min_workers", cfg.minWorkers,
+			"max_workers", cfg.maxWorkers,
+			"drain_time", cfg.drainTime,
+		)
+	}
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	var lowSince time.Time
+	for range ticker.C {
+		lowSince = s.reconcileWorkers(context.Background(), cfg, lowSince)
+	}
+}
+
+// reconcileWorkers records the queue metrics and scales the worker deployment
+// if the controller autoscales it. It returns the updated scale down timer.
+func (s *Scheduler) reconcileWorkers(ctx context.Context, cfg autoscaleConfig, lowSince time.Time) time.Time {
+	queued, err := s.queue.Len(ctx)
+	if err != nil {
+		logging.Warn("failed to get queue length", "error", err)
+		return lowSince
+	}
+	inProgress, err := s.queue.InProgress(ctx)
+	if err != nil {
+		logging.Warn("failed to count claimed downloads", "error", err)
+		return lowSince
+	}
+	avg, _, err := s.queue.AverageDownloadTime(ctx)
+	if err != nil {
+		logging.Warn("failed to get average download time", "error", err)
+	}
+
+	byChannel := make(map[string]int)
+	if channels, err := s.queue.Channels(ctx); err == nil {
+		for channelID, summary := range channels {
+			byChannel[channelID] = summary.Queued
+		}
+	}
+	metrics.RecordQueue(queued, inProgress, byChannel)
+
+	desired := desiredWorkers(queued, inProgress, avg, cfg)
+	metrics.RecordWorkerScaling(avg.Seconds(), desired)
+
+	if cfg.autoscaler != AutoscalerController || s.k8sClient == nil {
+		return lowSince
+	}
+
+	current, err := s.k8sManager.GetWorkerReplicas(ctx, cfg.deployment)
+	if err != nil {
+		logging.Warn("failed to get worker count", "deployment", cfg.deployment, "error", err)
+		return lowSince
+	}
+	target, lowSince := scaleTarget(current, desired, lowSince, time.Now(), cfg.scaleDownDelay)
+	if target != current {
+		if err := s.k8sManager.ScaleWorkers(ctx, cfg.deployment, target); err != nil {
+			logging.Warn("failed to scale workers", "deployment", cfg.deployment, "error", err)
+			metrics.RecordWorkers(current)
+			return lowSince
+		}
+		logging.Info("scaled workers",
+			"deployment", cfg.deployment,
+			"from", current,
+			"to", target,
+			"queued", queued,
+			"in_progress", inProgress,
+			"average_download", avg,
+		)
+	}
+	metrics.RecordWorkers(target)
+	return lowSince
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDesiredWorkers(t *testing.T) {
+	cfg := autoscaleConfig{minWorkers: 1, maxWorkers: 10, drainTime: time.Hour}
+
+	tests := []struct {
+		name       string
+		queued     int64
+		inProgress int64
+		avg        time.Duration
+		want       int
+	}{
+		{"empty queue keeps the minimum", 0, 0, 2 * time.Minute, 1},
+		{"drains in time", 60, 0, 5 * time.Minute, 5},
+		{"rounds up", 61, 0, 5 * time.Minute, 6},
+		{"counts claimed videos", 58, 2, 5 * time.Minute, 5},
+		{"no more workers than videos", 3, 0, time.Hour, 3},
+		{"keeps claimed videos downloading", 0, 4, time.Minute, 4},
+		{"cluster limit", 1000, 0, 10 * time.Minute, 10},
+		{"default download time", 24, 0, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := desiredWorkers(tt.queued, tt.inProgress, tt.avg, cfg); got != tt.want {
+				t.Errorf("desiredWorkers(%d, %d, %v) = %d, want %d", tt.queued, tt.inProgress, tt.avg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleTarget(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	delay := 10 * time.Minute
+
+	tests := []struct {
+		name         string
+		current      int
+		desired      int
+		lowSince     time.Time
+		want         int
+		wantLowSince time.Time
+	}{
+		{"scale up right away", 2, 5, time.Time{}, 5, time.Time{}},
+		{"scale up resets the timer", 2, 5, now.Add(-time.Minute), 5, time.Time{}},
+		{"steady", 3, 3, time.Time{}, 3, time.Time{}},
+		{"scale down starts the timer", 5, 2, time.Time{}, 5, now},
+		{"scale down waits", 5, 2, now.Add(-5 * time.Minute), 5, now.Add(-5 * time.Minute)},
+		{"scale down after the delay", 5, 2, now.Add(-delay), 2, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lowSince := scaleTarget(tt.current, tt.desired, tt.lowSince, now, delay)
+			if got != tt.want || !lowSince.Equal(tt.wantLowSince) {
+				t.Errorf("scaleTarget(%d, %d) = %d, %v, want %d, %v", tt.current, tt.desired, got, lowSince, tt.want, tt.wantLowSince)
+			}
+		})
+	}
+}
+
+func TestAutoscaleConfigFromEnv(t *testing.T) {
+	t.Setenv("MIN_WORKERS", "4")
+	t.Setenv("MAX_WORKERS", "2")
+	t.Setenv("AUTOSCALE_DRAIN_MINUTES", "soon")
+
+	cfg := autoscaleConfigFromEnv()
+	if cfg.autoscaler != AutoscalerNone {
+		t.Errorf("autoscaler = %q, want %q", cfg.autoscaler, AutoscalerNone)
+	}
+	if cfg.maxWorkers != 4 {
+		t.Errorf("maxWorkers = %d, want it raised to MIN_WORKERS", cfg.maxWorkers)
+	}
+	if cfg.drainTime != time.Hour {
+		t.Errorf("drainTime = %v, want the default", cfg.drainTime)
+	}
+}
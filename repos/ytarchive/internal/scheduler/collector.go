@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// collectorClient reaches the archive storage through the collector, which
+// owns it; the controller does not mount the archive volume
+type collectorClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+// newCollectorClient creates a client for the collector at COLLECTOR_URL
+func newCollectorClient() *collectorClient {
+	return &collectorClient{
+		baseURL: strings.TrimSuffix(getEnvWithDefault("COLLECTOR_URL", "http://collector.ytarchive.svc.cluster.local:8081"), "/"),
+		// Verifying a checksum reads the whole video
+		http: &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+// videoURL returns the collector URL of a video's files
+func (c *collectorClient) videoURL(channelID, videoID string) string {
+	return c.baseURL + "/videos/" + url.PathEscape(channelID) + "/" + url.PathEscape(videoID)
+}
+
+// DeleteVideo deletes the files of a video. With blobChecksum set, the
+// deduplicated blob of that checksum is deleted too.
+func (c *collectorClient) DeleteVideo(ctx context.Context, channelID, videoID, blobChecksum string) error {
+	target := c.videoURL(channelID, videoID)
+	if blobChecksum != "" {
+		target += "?blob=" + url.QueryEscape(blobChecksum)
+	}
+	return c.do(ctx, http.MethodDelete, target, nil, nil)
+}
+
+// VerifyVideo reports whether the file of a video still matches its
+// checksum. A missing file does not match.
+func (c *collectorClient) VerifyVideo(ctx context.Context, channelID, videoID, filePath, checksum string) (bool, error) {
+	request := map[string]string{"file_path": filePath, "checksum": checksum}
+	var result struct {
+		Valid bool `json:"valid"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.videoURL(channelID, videoID)+"/verify", request, &result); err != nil {
+		return false, err
+	}
+	return result.Valid, nil
+}
+
+// PruneBlobs deletes the deduplicated blobs no video links to any more and
+// returns how many were deleted
+func (c *collectorClient) PruneBlobs(ctx context.Context) (int, error) {
+	var result struct {
+		Pruned int `json:"pruned"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/blobs/prune", nil, &result); err != nil {
+		return 0, err
+	}
+	return result.Pruned, nil
+}
+
+// do sends a request to the collector and decodes its JSON response into
+// result, if result is not nil
+func (c *collectorClient) do(ctx context.Context, method, target string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create collector request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach collector: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode collector response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+// newTestCollector serves handler as the collector of a Scheduler
+func newTestCollector(t *testing.T, handler http.HandlerFunc) *Scheduler {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Scheduler{collector: &collectorClient{baseURL: server.URL, http: server.Client()}}
+}
+
+func TestCollectorClient(t *testing.T) {
+	ctx := context.Background()
+	var requests []string
+	s := newTestCollector(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/videos/c1/v1/verify":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(map[string]bool{"valid": req["checksum"] == "good"})
+		case "/blobs/prune":
+			json.NewEncoder(w).Encode(map[string]int{"pruned": 2})
+		default:
+			json.NewEncoder(w).Encode(map[string]int{"deleted": 1})
+		}
+	})
+
+	if err := s.collector.DeleteVideo(ctx, "c1", "v1", "abc"); err != nil {
+		t.Errorf("DeleteVideo() error = %v", err)
+	}
+	if valid, err := s.collector.VerifyVideo(ctx, "c1", "v1", "/data/channels/c1/videos/v1/video.mp4", "good"); err != nil || !valid {
+		t.Errorf("VerifyVideo() = %v, %v, want true", valid, err)
+	}
+	if valid, err := s.collector.VerifyVideo(ctx, "c1", "v1", "/data/channels/c1/videos/v1/video.mp4", "bad"); err != nil || valid {
+		t.Errorf("VerifyVideo() = %v, %v, want false", valid, err)
+	}
+	if pruned, err := s.collector.PruneBlobs(ctx); err != nil || pruned != 2 {
+		t.Errorf("PruneBlobs() = %d, %v, want 2", pruned, err)
+	}
+
+	want := []string{
+		"DELETE /videos/c1/v1?blob=abc",
+		"POST /videos/c1/v1/verify",
+		"POST /videos/c1/v1/verify",
+		"POST /blobs/prune",
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("requests = %v, want %v", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, requests[i], want[i])
+		}
+	}
+}
+
+func TestPruneVideoKeepsVideoWhenDeleteFails(t *testing.T) {
+	s := newTestCollector(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Failed to delete video files", http.StatusInternalServerError)
+	})
+
+	// The records are only touched once the files are gone, so none are needed here
+	err := s.pruneVideo(context.Background(), "c1", storage.RetentionVideo{VideoID: "v1"})
+	if err == nil {
+		t.Fatal("pruneVideo() error = nil, want the collector's error")
+	}
+}
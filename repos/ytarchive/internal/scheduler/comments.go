@@ -0,0 +1,252 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// CommentArchiveResult summarizes a comment archiving pass over a channel
+type CommentArchiveResult struct {
+	ChannelID    string   `json:"channel_id"`
+	Videos       int      `json:"videos"`
+	Comments     int      `json:"comments"`
+	ChatMessages int      `json:"chat_messages"`
+	Failed       []string `json:"failed"`
+}
+
+// ArchiveChannelComments archives the comments of every downloaded video of a
+// channel that has none archived yet, and the chat replay of its livestreams.
+// Does nothing unless comment archiving is turned on for the channel.
+func (s *Scheduler) ArchiveChannelComments(ctx context.Context, channelID string) (*CommentArchiveResult, error) {
+	result := &CommentArchiveResult{ChannelID: channelID, Failed: []string{}}
+	if !s.getChannelArchiveComments(ctx, channelID) {
+		return result, nil
+	}
+	if s.youtubeClient == nil {
+		return nil, fmt.Errorf("YouTube client not available")
+	}
+
+	videos, err := s.getCommentVideos(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if len(videos) == 0 {
+		return result, nil
+	}
+
+	limit := commentLimit()
+	for _, video := range videos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		comments, chat, err := s.archiveVideoComments(ctx, channelID, video, limit)
+		if err != nil {
+			logging.Warn("failed to archive comments",
+				"channel_id", channelID,
+				"video_id", video.VideoID,
+				"error", err,
+			)
+			result.Failed = append(result.Failed, video.VideoID)
+			continue
+		}
+		result.Videos++
+		result.Comments += comments
+		result.ChatMessages += chat
+	}
+
+	logging.Info("comment archiving complete",
+		"channel_id", channelID,
+		"videos", result.Videos,
+		"comments", result.Comments,
+		"chat_messages", result.ChatMessages,
+		"failed", len(result.Failed),
+	)
+	return result, nil
+}
+
+// commentVideo is a downloaded video whose comments have not been archived
+type commentVideo struct {
+	VideoID string
+	Kind    string
+}
+
+// archiveVideoComments stores the comments of a video, and its chat replay if
+// it was a livestream, then records in its video record that they were archived
+func (s *Scheduler) archiveVideoComments(ctx context.Context, channelID string, video commentVideo, limit int) (int, int, error) {
+	comments, err := s.youtubeClient.GetCommentsContext(ctx, video.VideoID, limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch comments: %w", err)
+	}
+	stored, err := s.records.Store().SaveComments(ctx, channelID, video.VideoID, db.CommentKindComment, toDBComments(comments))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var chatStored int
+	if video.Kind == db.KindLive {
+		messages, err := s.youtubeClient.GetLiveChatReplayContext(ctx, video.VideoID)
+		if err != nil && !errors.Is(err, youtube.ErrNoChatReplay) {
+			return stored, 0, fmt.Errorf("failed to fetch chat replay: %w", err)
+		}
+		chatStored, err = s.records.Store().SaveComments(ctx, channelID, video.VideoID, db.CommentKindChat, toDBChatMessages(messages))
+		if err != nil {
+			return stored, 0, err
+		}
+	}
+
+	if err := s.updateVideoRecord(ctx, channelID, video.VideoID, func(record map[string]interface{}) {
+		record["comments_archived_at"] = time.Now()
+		record["comment_count"] = stored
+		if video.Kind == db.KindLive {
+			record["chat_message_count"] = chatStored
+		}
+	}); err != nil {
+		return stored, chatStored, err
+	}
+	return stored, chatStored, nil
+}
+
+// toDBComments converts fetched comments to database records
+func toDBComments(comments []youtube.Comment) []db.Comment {
+	records := make([]db.Comment, 0, len(comments))
+	for _, comment := range comments {
+		records = append(records, db.Comment{
+			ID:              comment.ID,
+			ParentID:        comment.ParentID,
+			AuthorName:      comment.AuthorName,
+			AuthorChannelID: comment.AuthorChannelID,
+			Text:            comment.Text,
+			LikeCount:       comment.LikeCount,
+			ReplyCount:      comment.ReplyCount,
+			PublishedText:   comment.PublishedText,
+		})
+	}
+	return records
+}
+
+// toDBChatMessages converts fetched chat messages to database records
+func toDBChatMessages(messages []youtube.ChatMessage) []db.Comment {
+	records := make([]db.Comment, 0, len(messages))
+	for _, message := range messages {
+		records = append(records, db.Comment{
+			ID:              message.ID,
+			AuthorName:      message.AuthorName,
+			AuthorChannelID: message.AuthorChannelID,
+			Text:            message.Message,
+			OffsetMs:        message.OffsetMs,
+			Amount:          message.Amount,
+		})
+	}
+	return records
+}
+
+// getCommentVideos returns the downloaded videos of a channel whose comments
+// have not been archived
+func (s *Scheduler) getCommentVideos(ctx context.Context, channelID string) ([]commentVideo, error) {
+	records, err := s.records.Store().ListVideos(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list videos: %w", err)
+	}
+
+	var videos []commentVideo
+	for _, record := range records {
+		if record.Status != "downloaded" && record.Status != "completed" {
+			continue
+		}
+
+		var video struct {
+			Kind               string     `json:"kind"`
+			CommentsArchivedAt *time.Time `json:"comments_archived_at"`
+		}
+		if err := json.Unmarshal(record.Document(), &video); err != nil {
+			continue
+		}
+		if video.CommentsArchivedAt != nil {
+			continue
+		}
+
+		videos = append(videos, commentVideo{
+			VideoID: record.ID,
+			Kind:    video.Kind,
+		})
+	}
+
+	return videos, nil
+}
+
+// getChannelArchiveComments reports whether comment archiving is turned on for a channel
+func (s *Scheduler) getChannelArchiveComments(ctx context.Context, channelID string) bool {
+	channelData, err := s.records.Channel(ctx, channelID)
+	if err != nil {
+		return false
+	}
+
+	var channel struct {
+		ArchiveComments bool `json:"archive_comments"`
+	}
+	if err := json.Unmarshal(channelData, &channel); err != nil {
+		return false
+	}
+	return channel.ArchiveComments
+}
+
+// commentLoop periodically archives the comments of newly downloaded videos
+func (s *Scheduler) commentLoop() {
+	interval := commentInterval()
+	if interval == 0 {
+		logging.Info("comment archiving disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.archiveAllComments(context.Background())
+	}
+}
+
+// archiveAllComments archives comments for every channel that has it turned on
+func (s *Scheduler) archiveAllComments(ctx context.Context) {
+	channelIDs, err := s.records.ChannelIDs(ctx)
+	if err != nil {
+		logging.Warn("failed to get channel list", "error", err)
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		if _, err := s.ArchiveChannelComments(ctx, channelID); err != nil {
+			logging.Warn("failed to archive channel comments",
+				"channel_id", channelID,
+				"error", err,
+			)
+		}
+	}
+}
+
+// commentInterval returns how often comments of new downloads are archived; 0 disables archiving
+func commentInterval() time.Duration {
+	hours, err := strconv.ParseFloat(getEnvWithDefault("COMMENT_INTERVAL_HOURS", "6"), 64)
+	if err != nil || hours < 0 {
+		hours = 6
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// commentLimit returns the most comments and replies archived per video; 0 archives all
+func commentLimit() int {
+	limit, err := strconv.Atoi(getEnvWithDefault("COMMENT_LIMIT", "5000"))
+	if err != nil || limit < 0 {
+		return 5000
+	}
+	return limit
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import "testing"
+
+func TestCommentLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 5000},
+		{"200", 200},
+		{"0", 0},
+		{"-1", 5000},
+		{"all", 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("COMMENT_LIMIT", tt.value)
+			if got := commentLimit(); got != tt.want {
+				t.Errorf("commentLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
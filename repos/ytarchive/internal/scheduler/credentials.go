@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/credentials"
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// credentialCheckLoop moves the cookies of earlier versions into the credential
+// pool, then periodically checks that every active credential set is still
+// signed in, so expired sets are quarantined before a worker trips over them
+func (s *Scheduler) credentialCheckLoop() {
+	pool := credentials.NewPool(s.redis)
+
+	if migrated, err := pool.MigrateLegacy(context.Background()); err != nil {
+		logging.Warn("failed to migrate cookies to the credential pool", "error", err)
+	} else if migrated {
+		logging.Info("moved cookies into the credential pool", "credential_set", credentials.DefaultSetName)
+	}
+
+	interval := credentialCheckInterval()
+	if interval == 0 {
+		logging.Info("credential health checks disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.checkAllCredentials(context.Background(), pool)
+	}
+}
+
+// checkAllCredentials checks every active credential set
+func (s *Scheduler) checkAllCredentials(ctx context.Context, pool *credentials.Pool) {
+	sets, err := pool.List(ctx)
+	if err != nil {
+		logging.Warn("failed to list credential sets", "error", err)
+		return
+	}
+
+	for _, set := range sets {
+		if set.State != credentials.StateActive {
+			continue
+		}
+		checked, err := pool.Check(ctx, set.Name)
+		if err != nil {
+			logging.Warn("failed to check credential set", "credential_set", set.Name, "error", err)
+			continue
+		}
+		if checked.State == credentials.StateQuarantined {
+			logging.Warn("credential set is signed out, quarantined",
+				"credential_set", set.Name,
+				"reason", checked.Reason,
+			)
+		}
+	}
+}
+
+// credentialCheckInterval returns how often credential sets are checked; 0 disables the checks
+func credentialCheckInterval() time.Duration {
+	hours, err := strconv.ParseFloat(getEnvWithDefault("CREDENTIAL_CHECK_INTERVAL_HOURS", "6"), 64)
+	if err != nil || hours < 0 {
+		hours = 6
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import "testing"
+
+func TestCredentialCheckInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"", 6},
+		{"0.5", 0.5},
+		{"0", 0},
+		{"-2", 6},
+		{"daily", 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("CREDENTIAL_CHECK_INTERVAL_HOURS", tt.value)
+			if got := credentialCheckInterval().Hours(); got != tt.want {
+				t.Errorf("credentialCheckInterval() = %vh, want %vh", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseCron(expr); err == nil {
+				t.Errorf("ParseCron(%q) expected error", expr)
+			}
+		})
+	}
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	// Wednesday
+	base := time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "every minute",
+			expr: "* * * * *",
+			from: base,
+			want: time.Date(2024, 1, 10, 10, 31, 0, 0, time.UTC),
+		},
+		{
+			name: "strictly after a matching time",
+			expr: "30 10 * * *",
+			from: base,
+			want: time.Date(2024, 1, 11, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "every six hours",
+			expr: "0 */6 * * *",
+			from: base,
+			want: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "list of minutes",
+			expr: "15,45 * * * *",
+			from: base,
+			want: time.Date(2024, 1, 10, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "weekdays only",
+			expr: "0 9 * * 1-5",
+			from: time.Date(2024, 1, 12, 10, 0, 0, 0, time.UTC), // Friday
+			want: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),  // Monday
+		},
+		{
+			name: "sunday as 7",
+			expr: "0 0 * * 7",
+			from: base,
+			want: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "day of month or day of week",
+			expr: "0 0 1 * 5",
+			from: base,
+			want: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "month rollover",
+			expr: "0 0 1 * *",
+			from: base,
+			want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "descriptor",
+			expr: "@daily",
+			from: base,
+			want: time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "never fires",
+			expr: "0 0 30 2 *",
+			from: base,
+			want: time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+			}
+			if got := cron.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelSchedule_NextInterval(t *testing.T) {
+	now := time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC)
+	recent := now.Add(-2 * time.Hour)
+	old := now.Add(-48 * time.Hour)
+
+	tests := []struct {
+		name       string
+		lastSyncAt *time.Time
+		want       time.Time
+	}{
+		{"never synced", nil, now.Add(6 * time.Hour)},
+		{"synced recently", &recent, recent.Add(6 * time.Hour)},
+		{"overdue", &old, now.Add(6 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := &ChannelSchedule{IntervalHours: 6, LastSyncAt: tt.lastSyncAt}
+			if got := schedule.next(now); !got.Equal(tt.want) {
+				t.Errorf("next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
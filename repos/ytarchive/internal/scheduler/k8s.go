@@ -136,6 +136,18 @@ func (m *K8sJobManager) CreateWorkerJob(ctx context.Context, channelID string, w
 									Name:  "CONTROLLER_URL",
 									Value: getEnvWithDefault("CONTROLLER_URL", "http://ytarchive-controller.ytarchive.svc.cluster.local"),
 								},
+								{
+									Name: "WORKER_API_KEY",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "ytarchive-secrets",
+											},
+											Key:      "worker-api-key",
+											Optional: boolPtr(true),
+										},
+									},
+								},
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -272,6 +284,31 @@ func (m *K8sJobManager) CleanupCompletedJobs(ctx context.Context) error {
 	return nil
 }
 
+// GetWorkerReplicas returns the replica count of the worker deployment
+func (m *K8sJobManager) GetWorkerReplicas(ctx context.Context, deployment string) (int, error) {
+	scale, err := m.client.AppsV1().Deployments(m.namespace).GetScale(ctx, deployment, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get scale of %s: %w", deployment, err)
+	}
+	return int(scale.Spec.Replicas), nil
+}
+
+// ScaleWorkers sets the replica count of the worker deployment
+func (m *K8sJobManager) ScaleWorkers(ctx context.Context, deployment string, replicas int) error {
+	scale, err := m.client.AppsV1().Deployments(m.namespace).GetScale(ctx, deployment, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get scale of %s: %w", deployment, err)
+	}
+
+	scale.Spec.Replicas = int32(replicas)
+	if _, err := m.client.AppsV1().Deployments(m.namespace).UpdateScale(ctx, deployment, scale, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to scale %s: %w", deployment, err)
+	}
+
+	log.Printf("Scaled %s to %d workers", deployment, replicas)
+	return nil
+}
+
 // getEnvWithDefault returns environment variable value or default if not set
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,370 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/queue"
+	"github.com/timholm/ytarchive/internal/store"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+const (
+	// playlistSlotPrefix tells playlists from channels among the owners of sync slots
+	playlistSlotPrefix = "playlist:"
+
+	// How often the controller checks for playlists that are due for a re-sync
+	playlistSyncCheckInterval = 5 * time.Minute
+
+	// A playlist stuck in "syncing" longer than this is considered abandoned
+	// (e.g. the controller restarted mid-sync) and becomes eligible again
+	playlistStaleSyncAfter = time.Hour
+)
+
+// StartPlaylistSync initiates a sync for a playlist.
+// Playlist entries are recorded in order in the state database.
+// Videos already archived under any tracked channel are reused; the rest are
+// saved under their owning channel when it is tracked, or under the playlist
+// itself otherwise, and queued as backfill in the download queue.
+func (s *Scheduler) StartPlaylistSync(ctx context.Context, playlistID, youtubePlaylistID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.acquireSyncSlot(ctx, playlistSlotPrefix+playlistID); err != nil {
+		return "", err
+	}
+
+	syncJobID := uuid.New().String()
+	syncJob := SyncJob{
+		ID:         syncJobID,
+		PlaylistID: playlistID,
+		YouTubeID:  youtubePlaylistID,
+		Status:     "pending",
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	syncJobJSON, err := json.Marshal(syncJob)
+	if err != nil {
+		s.releaseSyncSlot(ctx, playlistSlotPrefix+playlistID)
+		return "", fmt.Errorf("failed to marshal sync job: %w", err)
+	}
+	if err := s.records.PutSyncJob(ctx, syncJobJSON); err != nil {
+		s.releaseSyncSlot(ctx, playlistSlotPrefix+playlistID)
+		return "", fmt.Errorf("failed to save sync job: %w", err)
+	}
+
+	// Mark as syncing before returning so the scheduled loop does not start a second sync
+	s.updatePlaylistStatus(ctx, playlistID, "syncing", nil)
+
+	go s.executePlaylistSync(syncJobID, playlistID, youtubePlaylistID)
+
+	logging.Info("playlist sync job created",
+		"job_id", syncJobID,
+		"playlist_id", playlistID,
+	)
+	return syncJobID, nil
+}
+
+// executePlaylistSync performs the playlist sync asynchronously
+func (s *Scheduler) executePlaylistSync(syncJobID, playlistID, youtubePlaylistID string) {
+	ctx := context.Background()
+	defer s.releaseSyncSlot(ctx, playlistSlotPrefix+playlistID)
+
+	s.updateSyncJobStatus(ctx, syncJobID, "discovering")
+
+	queued, err := s.discoverPlaylistVideos(ctx, playlistID, youtubePlaylistID)
+	if err != nil {
+		logging.Error("error discovering playlist videos",
+			"job_id", syncJobID,
+			"playlist_id", playlistID,
+			"error", err,
+		)
+		s.updateSyncJobStatus(ctx, syncJobID, "failed")
+		s.updatePlaylistStatus(ctx, playlistID, "error", nil)
+		return
+	}
+
+	s.updateSyncJobVideoCount(ctx, syncJobID, len(queued))
+	s.updateSyncJobStatus(ctx, syncJobID, "completed")
+
+	logging.Info("playlist sync completed",
+		"job_id", syncJobID,
+		"playlist_id", playlistID,
+		"queued", len(queued),
+	)
+}
+
+// discoverPlaylistVideos enumerates the playlist, stores its order and queues missing videos.
+// Returns the queue items ("ownerID:videoID") that were pushed.
+func (s *Scheduler) discoverPlaylistVideos(ctx context.Context, playlistID, youtubePlaylistID string) ([]string, error) {
+	if s.youtubeClient == nil {
+		return nil, fmt.Errorf("YouTube client not available")
+	}
+
+	info, items, err := s.youtubeClient.GetPlaylistContext(ctx, youtubePlaylistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+
+	channels, err := s.records.Store().ListChannels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel list: %w", err)
+	}
+	ownerIndex := channelIndexByYouTubeID(channels)
+
+	now := time.Now()
+	dbItems := make([]db.PlaylistItem, 0, len(items))
+	var queued []string
+	var reused int
+
+	for _, item := range items {
+		ownerID, status := s.findArchivedVideo(ctx, item.ID)
+
+		if ownerID != "" {
+			// Already known under a channel - reuse it instead of downloading twice
+			reused++
+			if status == "pending" || status == "error" {
+				queued = append(queued, ownerID+":"+item.ID)
+			}
+		} else if item.Playable {
+			// Prefer the owning channel when it is tracked, otherwise keep it under the playlist
+			ownerID = ownerIndex[item.ChannelID]
+			episodeNumber := 0
+			if ownerID == "" {
+				ownerID = playlistID
+				episodeNumber = item.Position
+			}
+
+			if err := s.savePlaylistVideo(ctx, ownerID, playlistID, item, episodeNumber, now); err != nil {
+				logging.Warn("error saving playlist video",
+					"playlist_id", playlistID,
+					"video_id", item.ID,
+					"error", err,
+				)
+				continue
+			}
+			queued = append(queued, ownerID+":"+item.ID)
+		}
+
+		dbItems = append(dbItems, db.PlaylistItem{
+			VideoID:          item.ID,
+			Position:         item.Position,
+			ChannelID:        ownerID,
+			YouTubeChannelID: item.ChannelID,
+			ChannelName:      item.ChannelName,
+			Title:            item.Title,
+			Duration:         int64(item.Duration),
+			ThumbnailURL:     item.ThumbnailURL,
+		})
+	}
+
+	// Persist playlist order
+	added, removed, err := s.records.Store().SavePlaylistItems(ctx, playlistID, dbItems)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store playlist order: %w", err)
+	}
+
+	// Queue them as backfill of their owners so workers pick them up like channel videos
+	for _, queueItem := range queued {
+		ownerID, videoID, err := queue.ParseItem(queueItem)
+		if err != nil {
+			continue
+		}
+		if _, err := s.queue.Enqueue(ctx, ownerID, []string{videoID}, queue.PriorityBackfill, s.getChannelWeight(ctx, ownerID)); err != nil {
+			logging.Error("error pushing video to queue",
+				"playlist_id", playlistID,
+				"queue_item", queueItem,
+				"error", err,
+			)
+		}
+	}
+
+	s.updatePlaylistStatus(ctx, playlistID, "synced", map[string]interface{}{
+		"title":       info.Title,
+		"description": info.Description,
+		"owner_name":  info.OwnerName,
+		"video_count": len(items),
+	})
+
+	logging.Info("playlist discovery complete",
+		"playlist_id", playlistID,
+		"items", len(items),
+		"added", added,
+		"removed", removed,
+		"reused", reused,
+		"queued", len(queued),
+	)
+
+	return queued, nil
+}
+
+// savePlaylistVideo saves a newly discovered playlist video under its owner
+func (s *Scheduler) savePlaylistVideo(ctx context.Context, ownerID, playlistID string, item youtube.PlaylistItem, episodeNumber int, now time.Time) error {
+	channelName := s.getChannelName(ctx, ownerID)
+	if channelName == "" {
+		channelName = item.ChannelName
+	}
+
+	videoData := map[string]interface{}{
+		"id":             item.ID,
+		"youtube_id":     item.ID,
+		"channel_id":     ownerID,
+		"playlist_id":    playlistID,
+		"title":          item.Title,
+		"duration":       item.Duration,
+		"thumbnail_url":  item.ThumbnailURL,
+		"episode_number": episodeNumber,
+		"channel_name":   channelName,
+		"status":         "pending",
+		"created_at":     now,
+		"updated_at":     now,
+	}
+	videoJSON, err := json.Marshal(videoData)
+	if err != nil {
+		return fmt.Errorf("failed to marshal video: %w", err)
+	}
+
+	return s.records.PutVideo(ctx, ownerID, item.ID, videoJSON)
+}
+
+// findArchivedVideo looks for a video under any tracked channel.
+// Returns the owning channel ID and the video status, or empty strings if not found.
+func (s *Scheduler) findArchivedVideo(ctx context.Context, videoID string) (string, string) {
+	channelID, videoData, err := s.records.FindVideo(ctx, videoID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			logging.Warn("error looking up video", "video_id", videoID, "error", err)
+		}
+		return "", ""
+	}
+
+	var video map[string]interface{}
+	if err := json.Unmarshal(videoData, &video); err != nil {
+		return "", ""
+	}
+	status, _ := video["status"].(string)
+	return channelID, status
+}
+
+// channelIndexByYouTubeID maps YouTube channel IDs to tracked channel IDs
+func channelIndexByYouTubeID(channels []store.Channel) map[string]string {
+	index := make(map[string]string, len(channels))
+	for _, channel := range channels {
+		if channel.YouTubeID != "" {
+			index[channel.YouTubeID] = channel.ID
+		}
+	}
+	return index
+}
+
+// updatePlaylistStatus updates the status of a playlist and merges any extra fields
+func (s *Scheduler) updatePlaylistStatus(ctx context.Context, playlistID, status string, fields map[string]interface{}) {
+	_, err := s.records.UpdatePlaylist(ctx, playlistID, func(playlistMap map[string]interface{}) {
+		for key, value := range fields {
+			if str, ok := value.(string); ok && str == "" {
+				continue
+			}
+			playlistMap[key] = value
+		}
+
+		playlistMap["status"] = status
+		playlistMap["updated_at"] = time.Now()
+		if status == "synced" {
+			playlistMap["last_sync_at"] = time.Now()
+		}
+	})
+	if err != nil {
+		logging.Warn("error updating playlist",
+			"playlist_id", playlistID,
+			"error", err,
+		)
+	}
+}
+
+// playlistSyncLoop periodically re-syncs playlists whose sync interval has elapsed
+func (s *Scheduler) playlistSyncLoop() {
+	ticker := time.NewTicker(playlistSyncCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.syncDuePlaylists(context.Background())
+	}
+}
+
+// syncDuePlaylists starts a sync for every playlist that is due
+func (s *Scheduler) syncDuePlaylists(ctx context.Context) {
+	playlists, err := s.records.Store().ListPlaylists(ctx)
+	if err != nil {
+		logging.Warn("failed to get playlist list", "error", err)
+		return
+	}
+
+	defaultInterval := defaultPlaylistSyncInterval()
+	now := time.Now()
+
+	for _, playlist := range playlists {
+		var playlistMap map[string]interface{}
+		if err := json.Unmarshal(playlist.Document(), &playlistMap); err != nil {
+			continue
+		}
+
+		if !playlistSyncDue(playlistMap, defaultInterval, now) {
+			continue
+		}
+
+		if _, err := s.StartPlaylistSync(ctx, playlist.ID, playlist.YouTubeID); err != nil {
+			logging.Warn("failed to start scheduled playlist sync",
+				"playlist_id", playlist.ID,
+				"error", err,
+			)
+		}
+	}
+}
+
+// playlistSyncDue reports whether a playlist record is due for a scheduled sync
+func playlistSyncDue(playlistMap map[string]interface{}, defaultInterval time.Duration, now time.Time) bool {
+	status, _ := playlistMap["status"].(string)
+	if status == "syncing" {
+		updatedAt := parseRecordTime(playlistMap["updated_at"])
+		return !updatedAt.IsZero() && now.Sub(updatedAt) > playlistStaleSyncAfter
+	}
+
+	interval := defaultInterval
+	if hours, ok := playlistMap["sync_interval_hours"].(float64); ok && hours > 0 {
+		interval = time.Duration(hours * float64(time.Hour))
+	}
+
+	lastSync := parseRecordTime(playlistMap["last_sync_at"])
+	return lastSync.IsZero() || now.Sub(lastSync) >= interval
+}
+
+// parseRecordTime parses a JSON-encoded timestamp from a playlist record
+func parseRecordTime(value interface{}) time.Time {
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// defaultPlaylistSyncInterval returns the re-sync interval for playlists without their own setting
+func defaultPlaylistSyncInterval() time.Duration {
+	hours, err := strconv.ParseFloat(getEnvWithDefault("PLAYLIST_SYNC_INTERVAL_HOURS", "24"), 64)
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/storage"
+)
+
+// RetentionPlan lists the videos a channel's retention policy removes
+type RetentionPlan struct {
+	ChannelID string                   `json:"channel_id"`
+	Policy    storage.RetentionPolicy  `json:"policy"`
+	DryRun    bool                     `json:"dry_run"`
+	Videos    []storage.RetentionVideo `json:"videos"`
+	Bytes     int64                    `json:"bytes"`  // total size of Videos
+	Pruned    int                      `json:"pruned"` // videos actually pruned; always 0 for a dry run
+}
+
+// GetRetentionPolicy returns the retention policy stored on a channel
+func (s *Scheduler) GetRetentionPolicy(ctx context.Context, channelID string) (storage.RetentionPolicy, error) {
+	var channel struct {
+		Retention storage.RetentionPolicy `json:"retention"`
+	}
+
+	channelData, err := s.records.Channel(ctx, channelID)
+	if err != nil {
+		return channel.Retention, fmt.Errorf("failed to get channel: %w", err)
+	}
+	if err := json.Unmarshal(channelData, &channel); err != nil {
+		return channel.Retention, fmt.Errorf("failed to unmarshal channel: %w", err)
+	}
+	return channel.Retention, nil
+}
+
+// PlanRetention returns the downloaded videos of a channel that policy would prune
+func (s *Scheduler) PlanRetention(ctx context.Context, channelID string, policy storage.RetentionPolicy) (*RetentionPlan, error) {
+	plan := &RetentionPlan{
+		ChannelID: channelID,
+		Policy:    policy,
+		DryRun:    true,
+		Videos:    []storage.RetentionVideo{},
+	}
+	if policy.IsZero() {
+		return plan, nil
+	}
+
+	videos, err := s.getDownloadedVideos(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pruned := policy.SelectForPruning(videos, time.Now()); pruned != nil {
+		plan.Videos = pruned
+	}
+	for _, video := range plan.Videos {
+		plan.Bytes += video.FileSize
+	}
+	return plan, nil
+}
+
+// EnforceRetention applies a channel's retention policy. Pruned videos have their
+// files deleted and are marked "pruned" in their records, so later syncs do not
+// download them again. With dryRun set, nothing is changed.
+func (s *Scheduler) EnforceRetention(ctx context.Context, channelID string, dryRun bool) (*RetentionPlan, error) {
+	policy, err := s.GetRetentionPolicy(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.PlanRetention(ctx, channelID, policy)
+	if err != nil {
+		return nil, err
+	}
+	plan.DryRun = dryRun
+	if dryRun || len(plan.Videos) == 0 {
+		return plan, nil
+	}
+
+	for _, video := range plan.Videos {
+		if err := s.pruneVideo(ctx, channelID, video); err != nil {
+			logging.Warn("failed to prune video",
+				"channel_id", channelID,
+				"video_id", video.VideoID,
+				"error", err,
+			)
+			continue
+		}
+		plan.Pruned++
+	}
+
+	logging.Info("retention policy enforced",
+		"channel_id", channelID,
+		"pruned", plan.Pruned,
+		"candidates", len(plan.Videos),
+		"bytes", plan.Bytes,
+	)
+	return plan, nil
+}
+
+// pruneVideo has the collector delete a video's files and marks it pruned.
+// Files are deleted first so a failure leaves the video marked as downloaded
+// and the next run retries it.
+func (s *Scheduler) pruneVideo(ctx context.Context, channelID string, video storage.RetentionVideo) error {
+	if err := s.collector.DeleteVideo(ctx, channelID, video.VideoID, ""); err != nil {
+		return fmt.Errorf("failed to delete video files: %w", err)
+	}
+
+	return s.updateVideoRecord(ctx, channelID, video.VideoID, func(record map[string]interface{}) {
+		now := time.Now()
+		record["status"] = string(db.StatusPruned)
+		record["pruned_at"] = now
+		record["prune_reason"] = video.Reason
+		record["updated_at"] = now
+		delete(record, "file_path")
+	})
+}
+
+// getDownloadedVideos returns the videos of a channel that have files on disk
+func (s *Scheduler) getDownloadedVideos(ctx context.Context, channelID string) ([]storage.RetentionVideo, error) {
+	records, err := s.records.Store().ListVideos(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list videos: %w", err)
+	}
+
+	var videos []storage.RetentionVideo
+	for _, record := range records {
+		if record.Status != "downloaded" && record.Status != "completed" {
+			continue
+		}
+
+		uploadedAt := parseUploadDate(record.UploadDate)
+		if uploadedAt.IsZero() {
+			var doc struct {
+				CreatedAt interface{} `json:"created_at"`
+			}
+			json.Unmarshal(record.Document(), &doc)
+			uploadedAt = parseRecordTime(doc.CreatedAt)
+		}
+
+		videos = append(videos, storage.RetentionVideo{
+			VideoID:       record.ID,
+			Title:         record.Title,
+			UploadedAt:    uploadedAt,
+			EpisodeNumber: record.EpisodeNumber,
+			FileSize:      record.FileSize,
+		})
+	}
+
+	return videos, nil
+}
+
+// parseUploadDate parses a YYYYMMDD or YYYY-MM-DD upload date
+func parseUploadDate(value string) time.Time {
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// retentionLoop periodically enforces the retention policy of every channel
+func (s *Scheduler) retentionLoop() {
+	ticker := time.NewTicker(retentionInterval())
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.enforceAllRetention(context.Background())
+	}
+}
+
+// enforceAllRetention enforces the retention policy of every channel that has one
+func (s *Scheduler) enforceAllRetention(ctx context.Context) {
+	channelIDs, err := s.records.ChannelIDs(ctx)
+	if err != nil {
+		logging.Warn("failed to get channel list", "error", err)
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		policy, err := s.GetRetentionPolicy(ctx, channelID)
+		if err != nil || policy.IsZero() {
+			continue
+		}
+		if _, err := s.EnforceRetention(ctx, channelID, false); err != nil {
+			logging.Warn("failed to enforce retention policy",
+				"channel_id", channelID,
+				"error", err,
+			)
+		}
+	}
+
+	// Deduplicated content is only freed once no video links to its blob
+	s.pruneBlobs(ctx)
+}
+
+// retentionInterval returns how often retention policies are enforced
+func retentionInterval() time.Duration {
+	hours, err := strconv.ParseFloat(getEnvWithDefault("RETENTION_INTERVAL_HOURS", "6"), 64)
+	if err != nil || hours <= 0 {
+		hours = 6
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
@@ -0,0 +1,349 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+const (
+	// Redis keys for sync schedules
+	scheduleKeyPrefix = "schedule:"
+	scheduleDueKey    = "schedules:due" // sorted set: channel ID scored by next sync (unix seconds)
+
+	// Redis key tracking syncs that are currently discovering videos
+	activeSyncsKey = "syncs:active" // sorted set: sync owner scored by start time (unix seconds)
+
+	// How often the controller checks for schedules that are due
+	scheduleCheckInterval = 30 * time.Second
+
+	// A sync slot held longer than this is assumed to belong to a crashed controller
+	syncSlotTimeout = 2 * time.Hour
+)
+
+var (
+	// ErrSyncInProgress is returned when a sync is started for a channel that is already syncing
+	ErrSyncInProgress = errors.New("sync already in progress")
+	// ErrSyncLimitReached is returned when MAX_CONCURRENT_SYNCS syncs are already running
+	ErrSyncLimitReached = errors.New("too many syncs running")
+)
+
+// ChannelSchedule is a durable per-channel auto-sync schedule.
+// Exactly one of Cron and IntervalHours is set.
+type ChannelSchedule struct {
+	ChannelID     string     `json:"channel_id"`
+	Cron          string     `json:"cron,omitempty"`
+	IntervalHours float64    `json:"interval_hours,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	LastSyncAt    *time.Time `json:"last_sync_at,omitempty"`
+	NextSyncAt    *time.Time `json:"next_sync_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// next returns the next sync time after t, or the zero time if there is none
+func (cs *ChannelSchedule) next(t time.Time) time.Time {
+	if cs.Cron != "" {
+		cron, err := ParseCron(cs.Cron)
+		if err != nil {
+			return time.Time{}
+		}
+		return cron.Next(t)
+	}
+
+	interval := time.Duration(cs.IntervalHours * float64(time.Hour))
+	if interval <= 0 {
+		return time.Time{}
+	}
+	// Interval schedules count from the last sync so manual syncs push the next one back
+	if cs.LastSyncAt != nil && cs.LastSyncAt.Add(interval).After(t) {
+		return cs.LastSyncAt.Add(interval)
+	}
+	return t.Add(interval)
+}
+
+// ValidateSchedule checks that a schedule has exactly one valid cron expression or interval
+func ValidateSchedule(cronExpr string, intervalHours float64) error {
+	if cronExpr == "" && intervalHours == 0 {
+		return fmt.Errorf("either cron or interval_hours is required")
+	}
+	if cronExpr != "" && intervalHours != 0 {
+		return fmt.Errorf("cron and interval_hours are mutually exclusive")
+	}
+	if cronExpr != "" {
+		cron, err := ParseCron(cronExpr)
+		if err != nil {
+			return err
+		}
+		if cron.Next(time.Now()).IsZero() {
+			return fmt.Errorf("cron expression %q never fires", cronExpr)
+		}
+		return nil
+	}
+	// Anything more frequent than every 5 minutes would overlap with the previous sync
+	if intervalHours < 1.0/12 {
+		return fmt.Errorf("interval_hours must be at least 5 minutes (0.0833)")
+	}
+	return nil
+}
+
+// SetSchedule creates or replaces the auto-sync schedule of a channel
+func (s *Scheduler) SetSchedule(ctx context.Context, channelID, cronExpr string, intervalHours float64, enabled bool) (*ChannelSchedule, error) {
+	if err := ValidateSchedule(cronExpr, intervalHours); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := &ChannelSchedule{
+		ChannelID:     channelID,
+		Cron:          cronExpr,
+		IntervalHours: intervalHours,
+		Enabled:       enabled,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	// Keep history from the previous schedule
+	if existing, err := s.GetSchedule(ctx, channelID); err != nil {
+		return nil, err
+	} else if existing != nil {
+		schedule.CreatedAt = existing.CreatedAt
+		schedule.LastSyncAt = existing.LastSyncAt
+	}
+
+	if err := s.saveSchedule(ctx, schedule, now); err != nil {
+		return nil, err
+	}
+
+	logging.Info("sync schedule set",
+		"channel_id", channelID,
+		"cron", cronExpr,
+		"interval_hours", intervalHours,
+		"enabled", enabled,
+		"next_sync_at", schedule.NextSyncAt,
+	)
+	return schedule, nil
+}
+
+// GetSchedule returns the schedule of a channel, or nil if it has none
+func (s *Scheduler) GetSchedule(ctx context.Context, channelID string) (*ChannelSchedule, error) {
+	data, err := s.redis.Get(ctx, scheduleKeyPrefix+channelID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	var schedule ChannelSchedule
+	if err := json.Unmarshal([]byte(data), &schedule); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule removes the schedule of a channel
+func (s *Scheduler) DeleteSchedule(ctx context.Context, channelID string) error {
+	pipe := s.redis.Pipeline()
+	pipe.Del(ctx, scheduleKeyPrefix+channelID)
+	pipe.ZRem(ctx, scheduleDueKey, channelID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+// saveSchedule recomputes the next sync after now and stores the schedule
+func (s *Scheduler) saveSchedule(ctx context.Context, schedule *ChannelSchedule, now time.Time) error {
+	schedule.NextSyncAt = nil
+	if schedule.Enabled {
+		if next := schedule.next(now); !next.IsZero() {
+			schedule.NextSyncAt = &next
+		}
+	}
+	schedule.UpdatedAt = now
+
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule: %w", err)
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, scheduleKeyPrefix+schedule.ChannelID, data, 0)
+	if schedule.NextSyncAt != nil {
+		pipe.ZAdd(ctx, scheduleDueKey, &redis.Z{Score: float64(schedule.NextSyncAt.Unix()), Member: schedule.ChannelID})
+	} else {
+		pipe.ZRem(ctx, scheduleDueKey, schedule.ChannelID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// recordSyncStarted stores the sync time on the channel's schedule, if it has one
+func (s *Scheduler) recordSyncStarted(ctx context.Context, channelID string, at time.Time) {
+	schedule, err := s.GetSchedule(ctx, channelID)
+	if err != nil || schedule == nil {
+		return
+	}
+
+	schedule.LastSyncAt = &at
+	if err := s.saveSchedule(ctx, schedule, at); err != nil {
+		logging.Warn("failed to update schedule after sync",
+			"channel_id", channelID,
+			"error", err,
+		)
+	}
+}
+
+// scheduleLoop starts syncs for channels whose schedule is due.
+// Schedules live in Redis, so they survive controller restarts; a sync missed
+// while the controller was down runs once on startup.
+func (s *Scheduler) scheduleLoop() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.runDueSchedules(context.Background())
+	}
+}
+
+// runDueSchedules starts a sync for every due schedule, up to the concurrency limit
+func (s *Scheduler) runDueSchedules(ctx context.Context) {
+	now := time.Now()
+	channelIDs, err := s.redis.ZRangeByScore(ctx, scheduleDueKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		logging.Warn("failed to get due schedules", "error", err)
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		channelData, err := s.records.Channel(ctx, channelID)
+		if errors.Is(err, store.ErrNotFound) {
+			// Channel was deleted; drop its schedule
+			s.DeleteSchedule(ctx, channelID)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var channel struct {
+			YouTubeID string `json:"youtube_id"`
+			Status    string `json:"status"`
+		}
+		if err := json.Unmarshal(channelData, &channel); err != nil {
+			continue
+		}
+
+		// A sync that is still downloading counts as this run; try again at the next slot
+		if channel.Status == "syncing" {
+			logging.Info("skipping scheduled sync, channel is still syncing", "channel_id", channelID)
+			s.skipScheduledRun(ctx, channelID, now)
+			continue
+		}
+
+		_, err = s.StartSync(ctx, channelID, channel.YouTubeID)
+		if errors.Is(err, ErrSyncLimitReached) {
+			// Leave the rest due; they are retried on the next tick
+			logging.Info("sync limit reached, deferring scheduled syncs", "due", len(channelIDs))
+			return
+		}
+		if errors.Is(err, ErrSyncInProgress) {
+			s.skipScheduledRun(ctx, channelID, now)
+			continue
+		}
+		if err != nil {
+			logging.Warn("failed to start scheduled sync",
+				"channel_id", channelID,
+				"error", err,
+			)
+			continue
+		}
+
+		logging.Info("scheduled sync started", "channel_id", channelID)
+	}
+}
+
+// skipScheduledRun moves a schedule on to its next run without syncing
+func (s *Scheduler) skipScheduledRun(ctx context.Context, channelID string, now time.Time) {
+	schedule, err := s.GetSchedule(ctx, channelID)
+	if err != nil || schedule == nil {
+		return
+	}
+	if err := s.saveSchedule(ctx, schedule, now); err != nil {
+		logging.Warn("failed to reschedule sync",
+			"channel_id", channelID,
+			"error", err,
+		)
+	}
+}
+
+// acquireSyncSlotScript atomically claims a sync slot.
+// Returns 1 on success, 0 if owner already holds a slot and -1 if all slots are taken.
+var acquireSyncSlotScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[3])
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return 0
+end
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[4]) then
+	return -1
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+return 1
+`)
+
+// acquireSyncSlot claims one of the MAX_CONCURRENT_SYNCS slots for owner
+// (a channel ID, or "playlist:<id>" for playlists). The slot covers video
+// discovery; downloads are spread over the queue and do not hold a slot.
+func (s *Scheduler) acquireSyncSlot(ctx context.Context, owner string) error {
+	now := time.Now()
+	result, err := acquireSyncSlotScript.Run(ctx, s.redis, []string{activeSyncsKey},
+		owner,
+		now.Unix(),
+		now.Add(-syncSlotTimeout).Unix(),
+		maxConcurrentSyncs(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to acquire sync slot: %w", err)
+	}
+
+	switch result {
+	case 0:
+		return ErrSyncInProgress
+	case -1:
+		return ErrSyncLimitReached
+	}
+	return nil
+}
+
+// releaseSyncSlot frees the slot held by owner
+func (s *Scheduler) releaseSyncSlot(ctx context.Context, owner string) {
+	if err := s.redis.ZRem(ctx, activeSyncsKey, owner).Err(); err != nil {
+		logging.Warn("failed to release sync slot",
+			"owner", owner,
+			"error", err,
+		)
+	}
+}
+
+// maxConcurrentSyncs returns the global limit on syncs discovering videos at once
+func maxConcurrentSyncs() int {
+	limit, err := strconv.Atoi(getEnvWithDefault("MAX_CONCURRENT_SYNCS", "2"))
+	if err != nil || limit <= 0 {
+		return 2
+	}
+	return limit
+}
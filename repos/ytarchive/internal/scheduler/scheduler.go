@@ -3,7 +3,9 @@ package scheduler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,64 +14,92 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/queue"
+	"github.com/timholm/ytarchive/internal/ratelimit"
+	"github.com/timholm/ytarchive/internal/storage"
+	"github.com/timholm/ytarchive/internal/store"
 	"github.com/timholm/ytarchive/internal/youtube"
 )
 
 const (
 	// Redis key prefixes
-	channelKeyPrefix    = "channel:"
-	videoKeyPrefix      = "video:"
-	syncJobKeyPrefix    = "syncjob:"
 	videoQueueKeyPrefix = "queue:videos:"
-	// Unified queue for all channels - KEDA monitors this
-	unifiedQueueKey = "ytarchive:download:queue"
 )
 
 // SyncJob represents a full channel sync operation
 type SyncJob struct {
 	ID         string    `json:"id"`
 	ChannelID  string    `json:"channel_id"`
+	PlaylistID string    `json:"playlist_id,omitempty"`
 	YouTubeID  string    `json:"youtube_id"`
 	Status     string    `json:"status"` // pending, discovering, running, completed, failed
 	VideoCount int       `json:"video_count"`
 	Downloaded int       `json:"downloaded"`
 	Failed     int       `json:"failed"`
+	Workflow   string    `json:"workflow,omitempty"` // Argo workflow running the sync, with the argo backend
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // Scheduler manages job scheduling and worker coordination
-// With KEDA-based scaling, the scheduler only pushes videos to Redis queues.
-// KEDA monitors queue length and scales workers automatically.
+// The scheduler only queues videos in the download queue shared by all
+// channels; workers of any channel claim them from there.
 type Scheduler struct {
 	k8sClient     *kubernetes.Clientset
 	redis         *redis.Client
 	namespace     string
 	k8sManager    *K8sJobManager // Kept for cleanup operations
 	youtubeClient *youtube.Client
+	storage       *storage.Manager     // archive volume, read by the search indexer where it is mounted
+	collector     *collectorClient     // deletes and verifies archived files
+	queue         *queue.PriorityQueue // download queue shared by all channels
+	records       *store.Records       // channels, videos and sync jobs, cached in Redis
+	workflows     *WorkflowRunner      // runs syncs as Argo workflows; nil for the jobs backend
 	mu            sync.Mutex
 }
 
-// NewScheduler creates a new Scheduler instance
-func NewScheduler(k8sClient *kubernetes.Clientset, redisClient *redis.Client, namespace string) *Scheduler {
-	// Create YouTube client for video discovery
-	ytClient, err := youtube.NewClient()
+// NewScheduler creates a new Scheduler instance. Syncs run as Argo workflows
+// if workflows is not nil, and in the controller otherwise.
+func NewScheduler(k8sClient *kubernetes.Clientset, redisClient *redis.Client, namespace string, records *store.Records, workflows *WorkflowRunner) *Scheduler {
+	s := newScheduler(k8sClient, redisClient, namespace, records)
+	s.workflows = workflows
+
+	// Recover any channels stuck in "syncing" state from previous controller instance
+	go s.recoverStuckChannels()
+	go s.migrateLegacyQueue()
+	go s.playlistSyncLoop()
+	go s.scheduleLoop()
+	go s.retentionLoop()
+	go s.scrubLoop()
+	go s.commentLoop()
+	go s.searchIndexLoop()
+	go s.credentialCheckLoop()
+	go s.autoscaleLoop()
+
+	return s
+}
+
+// newScheduler creates a Scheduler without starting its background loops
+func newScheduler(k8sClient *kubernetes.Clientset, redisClient *redis.Client, namespace string, records *store.Records) *Scheduler {
+	// Create YouTube client for video discovery, sharing rate limits with the workers
+	ytClient, err := youtube.NewClient(
+		youtube.WithRateLimiter(ratelimit.NewShared(redisClient, ratelimit.SharedConfigFromEnv())),
+	)
 	if err != nil {
 		logging.Warn("failed to create YouTube client, video discovery will be limited", "error", err)
 	}
 
-	s := &Scheduler{
+	return &Scheduler{
 		k8sClient:     k8sClient,
 		redis:         redisClient,
 		namespace:     namespace,
 		k8sManager:    NewK8sJobManager(k8sClient, namespace), // Kept for cleanup operations
 		youtubeClient: ytClient,
+		storage:       storage.NewManager(""),
+		collector:     newCollectorClient(),
+		queue:         queue.NewPriorityQueue(redisClient),
+		records:       records,
 	}
-
-	// Recover any channels stuck in "syncing" state from previous controller instance
-	go s.recoverStuckChannels()
-
-	return s
 }
 
 // recoverStuckChannels checks for channels stuck in "syncing" state and fixes them
@@ -81,21 +111,31 @@ func (s *Scheduler) recoverStuckChannels() {
 
 	logging.Info("checking for stuck channels to recover")
 
+	// Discovery does not survive a restart, so no sync slot is still in use
+	if err := s.redis.Del(ctx, activeSyncsKey).Err(); err != nil {
+		logging.Warn("failed to reset active syncs", "error", err)
+	}
+
+	// Sync workflows do, and take their slots back
+	if s.workflows != nil {
+		s.resumeWorkflowSyncs(ctx)
+	}
+
 	// Get all channel IDs
-	channelIDs, err := s.redis.SMembers(ctx, "channels").Result()
+	channelIDs, err := s.records.ChannelIDs(ctx)
 	if err != nil {
 		logging.Warn("failed to get channel list for recovery", "error", err)
 		return
 	}
 
 	for _, channelID := range channelIDs {
-		channelData, err := s.redis.Get(ctx, channelKeyPrefix+channelID).Result()
+		channelData, err := s.records.Channel(ctx, channelID)
 		if err != nil {
 			continue
 		}
 
 		var channelMap map[string]interface{}
-		if err := json.Unmarshal([]byte(channelData), &channelMap); err != nil {
+		if err := json.Unmarshal(channelData, &channelMap); err != nil {
 			continue
 		}
 
@@ -125,16 +165,20 @@ func (s *Scheduler) recoverStuckChannels() {
 }
 
 // StartSync initiates a full sync for a channel.
-// With KEDA-based scaling:
 // 1. Discovers videos from YouTube
-// 2. Pushes video IDs to Redis queue (queue:videos:{channelID})
-// 3. KEDA monitors the queue and scales workers automatically
-// 4. Workers pull videos from queue and download them
-// 5. When queue is empty, KEDA scales workers to 0
+// 2. Queues video IDs in the download queue, new uploads ahead of backfill
+// 3. Workers claim videos from the queue by priority and download them
+//
+// Returns ErrSyncInProgress if the channel is already discovering videos and
+// ErrSyncLimitReached if MAX_CONCURRENT_SYNCS syncs are already discovering.
 func (s *Scheduler) StartSync(ctx context.Context, channelID, youtubeID string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.acquireSyncSlot(ctx, channelID); err != nil {
+		return "", err
+	}
+
 	// Create sync job record
 	syncJobID := uuid.New().String()
 	syncJob := SyncJob{
@@ -146,15 +190,28 @@ func (s *Scheduler) StartSync(ctx context.Context, channelID, youtubeID string)
 		UpdatedAt: time.Now(),
 	}
 
-	// Save sync job to Redis
+	// Save sync job, which stays in the channel's sync history
 	syncJobJSON, err := json.Marshal(syncJob)
 	if err != nil {
+		s.releaseSyncSlot(ctx, channelID)
 		return "", fmt.Errorf("failed to marshal sync job: %w", err)
 	}
-	if err := s.redis.Set(ctx, syncJobKeyPrefix+syncJobID, syncJobJSON, 24*time.Hour).Err(); err != nil {
+	if err := s.records.PutSyncJob(ctx, syncJobJSON); err != nil {
+		s.releaseSyncSlot(ctx, channelID)
 		return "", fmt.Errorf("failed to save sync job: %w", err)
 	}
 
+	s.recordSyncStarted(ctx, channelID, syncJob.CreatedAt)
+
+	if s.workflows != nil {
+		if err := s.startWorkflowSync(ctx, syncJobID, channelID, youtubeID); err != nil {
+			s.releaseSyncSlot(ctx, channelID)
+			s.updateSyncJobStatus(ctx, syncJobID, "failed")
+			return "", err
+		}
+		return syncJobID, nil
+	}
+
 	// Start async sync process
 	go s.executeSyncJob(syncJobID, channelID, youtubeID)
 
@@ -171,6 +228,9 @@ func (s *Scheduler) StartSync(ctx context.Context, channelID, youtubeID string)
 func (s *Scheduler) executeSyncJob(syncJobID, channelID, youtubeID string) {
 	ctx := context.Background()
 
+	// The slot only covers discovery; downloads are monitored without holding it
+	defer s.releaseSyncSlot(ctx, channelID)
+
 	// Update channel status to syncing
 	s.updateChannelStatus(ctx, channelID, "syncing")
 
@@ -180,7 +240,7 @@ func (s *Scheduler) executeSyncJob(syncJobID, channelID, youtubeID string) {
 	// Discover videos from YouTube using streaming pagination.
 	// Videos are pushed to the queue incrementally as they're discovered,
 	// allowing workers to start downloading before discovery is complete.
-	videoIDs, err := s.discoverAndSaveVideos(ctx, channelID, youtubeID)
+	videoIDs, err := s.discoverAndSaveVideos(ctx, channelID, youtubeID, true)
 	if err != nil {
 		logging.Error("error discovering videos",
 			"job_id", syncJobID,
@@ -222,9 +282,10 @@ func (s *Scheduler) executeSyncJob(syncJobID, channelID, youtubeID string) {
 // discoverAndSaveVideos fetches videos from YouTube using streaming pagination.
 // Instead of loading all videos into memory at once, it:
 // 1. First pass: counts new videos (lightweight, needed for episode numbering)
-// 2. Second pass: streams through pages, saves to Redis, and pushes to queue after each batch
+// 2. Second pass: streams through pages, saves the videos, and pushes to queue after each batch
 // This allows workers to start downloading while discovery is still in progress.
-func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtubeID string) ([]string, error) {
+// Without enqueue the videos are only saved, for a sync workflow to download.
+func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtubeID string, enqueue bool) ([]string, error) {
 	if s.youtubeClient == nil {
 		logging.Warn("YouTube client not available, falling back to Redis-only video discovery",
 			"channel_id", channelID,
@@ -232,14 +293,17 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 		return s.getChannelVideoIDs(ctx, channelID)
 	}
 
+	kinds := s.getChannelKinds(ctx, channelID)
+
 	logging.Info("discovering videos from YouTube (streaming mode)",
 		"channel_id", channelID,
 		"youtube_id", youtubeID,
+		"kinds", kinds,
 	)
 
 	// Phase 1: Count new videos and collect their IDs for ordering
 	// This is a lightweight pass - we only check existence, not save data
-	newVideoIDs, requeueVideoIDs, err := s.countNewVideosStreaming(ctx, channelID, youtubeID)
+	newVideoIDs, requeueVideoIDs, listed, err := s.countNewVideosStreaming(ctx, channelID, youtubeID, kinds)
 	if err != nil {
 		logging.Warn("error counting videos from YouTube, falling back to Redis",
 			"channel_id", channelID,
@@ -249,6 +313,9 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 		return s.getChannelVideoIDs(ctx, channelID)
 	}
 
+	// Compare the complete listing with the archive once new videos are queued
+	defer s.detectUpstreamChanges(ctx, channelID, kinds, listed)
+
 	totalNew := len(newVideoIDs)
 	totalRequeue := len(requeueVideoIDs)
 
@@ -265,11 +332,18 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 	// Phase 2: Stream through and save new videos with correct episode numbers
 	// Videos from YouTube come newest-first, but newVideoIDs is in that order
 	// We want oldest = episode 1, so we reverse the order for numbering
-	// Use unified queue so KEDA can monitor a single queue for all channels
 	channelName := s.getChannelName(ctx, channelID)
 	existingEpisodeCount := s.getExistingEpisodeCount(ctx, channelID)
 	now := time.Now()
 
+	// New uploads of a channel synced before are downloaded ahead of any backfill;
+	// the first sync of a channel and retries are backfill
+	newPriority := queue.PriorityBackfill
+	if existingEpisodeCount > 0 {
+		newPriority = queue.PriorityNew
+	}
+	weight := s.getChannelWeight(ctx, channelID)
+
 	// Create a map from video ID to episode number (oldest new video = existingCount + 1)
 	episodeMap := make(map[string]int, totalNew)
 	for i, videoID := range newVideoIDs {
@@ -278,25 +352,13 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 	}
 
 	// Now stream through pages again, this time saving and pushing to queue
-	var pageToken string
 	var processedNew, processedRequeue int
 	var allVideoIDs []string
 
-	for {
-		videos, nextToken, err := s.youtubeClient.GetVideoListPaginatedContext(ctx, youtubeID, 0, pageToken)
-		if err != nil {
-			logging.Error("error fetching video page during save phase",
-				"channel_id", channelID,
-				"error", err,
-			)
-			break
-		}
-
-		var batchIDs []string
+	err = s.forEachVideoPage(ctx, youtubeID, kinds, func(tab youtube.ChannelTab, page int, videos []youtube.Video) {
+		var newIDs, requeueIDs []string
 
 		for _, video := range videos {
-			videoKey := videoKeyPrefix + channelID + ":" + video.ID
-
 			// Check if this is a new video we need to save
 			if episodeNum, isNew := episodeMap[video.ID]; isNew {
 				// Save new video with episode number
@@ -310,6 +372,7 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 					"upload_date":    video.UploadDate,
 					"thumbnail_url":  video.ThumbnailURL,
 					"view_count":     video.ViewCount,
+					"kind":           video.Kind,
 					"episode_number": episodeNum,
 					"channel_name":   channelName,
 					"status":         "pending",
@@ -325,22 +388,22 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 					)
 					continue
 				}
-				if err := s.redis.Set(ctx, videoKey, videoJSON, 0).Err(); err != nil {
-					logging.Warn("error saving video to Redis",
+				if err := s.records.PutVideo(ctx, channelID, video.ID, videoJSON); err != nil {
+					logging.Warn("error saving video",
 						"channel_id", channelID,
 						"video_id", video.ID,
 						"error", err,
 					)
 					continue
 				}
-				batchIDs = append(batchIDs, video.ID)
+				newIDs = append(newIDs, video.ID)
 				processedNew++
 				delete(episodeMap, video.ID) // Mark as processed
 			} else {
 				// Check if this is an existing video that needs requeue
 				for _, requeueID := range requeueVideoIDs {
 					if requeueID == video.ID {
-						batchIDs = append(batchIDs, video.ID)
+						requeueIDs = append(requeueIDs, video.ID)
 						processedRequeue++
 						break
 					}
@@ -348,33 +411,43 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 			}
 		}
 
-		// Push this batch to unified queue immediately - workers can start downloading now
-		// Format: channelID:videoID so workers know which channel the video belongs to
-		if len(batchIDs) > 0 {
-			for _, id := range batchIDs {
-				queueItem := channelID + ":" + id
-				if err := s.redis.LPush(ctx, unifiedQueueKey, queueItem).Err(); err != nil {
-					logging.Error("error pushing video to queue",
-						"channel_id", channelID,
-						"video_id", id,
-						"error", err,
-					)
-				}
+		// Queue this batch immediately - workers can start downloading now
+		batchSize := len(newIDs) + len(requeueIDs)
+		if batchSize > 0 && !enqueue {
+			allVideoIDs = append(allVideoIDs, newIDs...)
+			allVideoIDs = append(allVideoIDs, requeueIDs...)
+		} else if batchSize > 0 {
+			if _, err := s.queue.Enqueue(ctx, channelID, newIDs, newPriority, weight); err != nil {
+				logging.Error("error queueing new videos",
+					"channel_id", channelID,
+					"count", len(newIDs),
+					"error", err,
+				)
+			}
+			if _, err := s.queue.Enqueue(ctx, channelID, requeueIDs, queue.PriorityBackfill, weight); err != nil {
+				logging.Error("error requeueing videos",
+					"channel_id", channelID,
+					"count", len(requeueIDs),
+					"error", err,
+				)
 			}
-			allVideoIDs = append(allVideoIDs, batchIDs...)
+			allVideoIDs = append(allVideoIDs, newIDs...)
+			allVideoIDs = append(allVideoIDs, requeueIDs...)
 
 			logging.Info("pushed video batch to queue",
 				"channel_id", channelID,
-				"batch_size", len(batchIDs),
+				"tab", tab,
+				"batch_size", batchSize,
+				"priority", newPriority,
 				"total_queued", len(allVideoIDs),
-				"queue_key", unifiedQueueKey,
 			)
 		}
-
-		if nextToken == "" {
-			break
-		}
-		pageToken = nextToken
+	})
+	if err != nil {
+		logging.Error("error fetching video page during save phase",
+			"channel_id", channelID,
+			"error", err,
+		)
 	}
 
 	logging.Info("streaming discovery complete",
@@ -388,23 +461,23 @@ func (s *Scheduler) discoverAndSaveVideos(ctx context.Context, channelID, youtub
 }
 
 // countNewVideosStreaming does a lightweight streaming pass to count new videos.
-// Returns two slices: new video IDs (in discovery order, newest first) and requeue video IDs.
-func (s *Scheduler) countNewVideosStreaming(ctx context.Context, channelID, youtubeID string) (newVideoIDs, requeueVideoIDs []string, err error) {
-	var pageToken string
-	pageCount := 0
-
-	for {
-		videos, nextToken, err := s.youtubeClient.GetVideoListPaginatedContext(ctx, youtubeID, 0, pageToken)
-		if err != nil {
-			return nil, nil, fmt.Errorf("error fetching video page %d: %w", pageCount, err)
-		}
-
-		pageCount++
+// Returns two slices: new video IDs (newest first) and requeue video IDs, and
+// every video listed on the channel by ID.
+func (s *Scheduler) countNewVideosStreaming(ctx context.Context, channelID, youtubeID string, kinds []string) (newVideoIDs, requeueVideoIDs []string, listed map[string]youtube.Video, err error) {
+	var newVideos []youtube.Video
+	listed = make(map[string]youtube.Video)
 
+	err = s.forEachVideoPage(ctx, youtubeID, kinds, func(tab youtube.ChannelTab, page int, videos []youtube.Video) {
 		for _, video := range videos {
-			videoKey := videoKeyPrefix + channelID + ":" + video.ID
+			listed[video.ID] = video
 
-			exists, err := s.redis.Exists(ctx, videoKey).Result()
+			// The records, not the cache, decide what is new, so a lost
+			// cache does not turn archived videos back into new ones
+			videoData, err := s.records.Video(ctx, channelID, video.ID)
+			if errors.Is(err, store.ErrNotFound) {
+				newVideos = append(newVideos, video)
+				continue
+			}
 			if err != nil {
 				logging.Warn("error checking video existence",
 					"channel_id", channelID,
@@ -414,41 +487,83 @@ func (s *Scheduler) countNewVideosStreaming(ctx context.Context, channelID, yout
 				continue
 			}
 
-			if exists == 0 {
-				// New video
-				newVideoIDs = append(newVideoIDs, video.ID)
-			} else {
-				// Check if it needs requeue (pending or error status)
-				videoData, err := s.redis.Get(ctx, videoKey).Result()
-				if err != nil {
-					continue
-				}
-				var existingVideo map[string]interface{}
-				if err := json.Unmarshal([]byte(videoData), &existingVideo); err != nil {
-					continue
-				}
-				status, _ := existingVideo["status"].(string)
-				if status == "pending" || status == "error" {
-					requeueVideoIDs = append(requeueVideoIDs, video.ID)
-				}
+			// Check if it needs requeue (pending or error status)
+			var existingVideo map[string]interface{}
+			if err := json.Unmarshal(videoData, &existingVideo); err != nil {
+				continue
+			}
+			status, _ := existingVideo["status"].(string)
+			if status == "pending" || status == "error" {
+				requeueVideoIDs = append(requeueVideoIDs, video.ID)
 			}
 		}
 
 		logging.Info("counting videos - page processed",
 			"channel_id", channelID,
-			"page", pageCount,
+			"tab", tab,
+			"page", page,
 			"videos_in_page", len(videos),
-			"new_so_far", len(newVideoIDs),
+			"new_so_far", len(newVideos),
 			"requeue_so_far", len(requeueVideoIDs),
 		)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Each tab is listed newest first; merge tabs so episode numbers follow upload order
+	if len(youtube.TabsForKinds(kinds)) > 1 {
+		sort.SliceStable(newVideos, func(i, j int) bool {
+			return newVideos[i].UploadDate > newVideos[j].UploadDate
+		})
+	}
+
+	newVideoIDs = make([]string, 0, len(newVideos))
+	for _, video := range newVideos {
+		newVideoIDs = append(newVideoIDs, video.ID)
+	}
+
+	return newVideoIDs, requeueVideoIDs, listed, nil
+}
+
+// forEachVideoPage walks every page of the channel tabs that list the given kinds and
+// calls fn with the videos of each page that have one of the kinds. Upcoming premieres,
+// scheduled streams and streams that are still live are skipped; they are picked up by
+// a later sync once they have finished. Videos listed on more than one tab are only
+// passed to fn once.
+func (s *Scheduler) forEachVideoPage(ctx context.Context, youtubeID string, kinds []string, fn func(tab youtube.ChannelTab, page int, videos []youtube.Video)) error {
+	wanted := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	seen := make(map[string]bool)
+
+	for _, tab := range youtube.TabsForKinds(kinds) {
+		var pageToken string
+		for page := 1; ; page++ {
+			videos, nextToken, err := s.youtubeClient.GetTabVideoListPaginatedContext(ctx, youtubeID, tab, 0, pageToken)
+			if err != nil {
+				return fmt.Errorf("error fetching %s page %d: %w", tab, page, err)
+			}
 
-		if nextToken == "" {
-			break
+			filtered := make([]youtube.Video, 0, len(videos))
+			for _, video := range videos {
+				if video.Upcoming || !wanted[video.Kind] || seen[video.ID] {
+					continue
+				}
+				seen[video.ID] = true
+				filtered = append(filtered, video)
+			}
+			fn(tab, page, filtered)
+
+			if nextToken == "" {
+				break
+			}
+			pageToken = nextToken
 		}
-		pageToken = nextToken
 	}
 
-	return newVideoIDs, requeueVideoIDs, nil
+	return nil
 }
 
 // monitorSyncProgress monitors queue length and video statuses for a sync operation.
@@ -502,10 +617,10 @@ func (s *Scheduler) monitorSyncProgress(ctx context.Context, syncJobID, channelI
 	}
 }
 
-// checkSyncProgress checks the unified queue length and video statuses for a channel
+// checkSyncProgress checks the download queue length and video statuses for a channel
 func (s *Scheduler) checkSyncProgress(ctx context.Context, channelID string) (queueLen int64, downloaded, failed int) {
-	// Check unified queue length (shared across all channels)
-	queueLen, err := s.redis.LLen(ctx, unifiedQueueKey).Result()
+	// Check download queue length (shared across all channels)
+	queueLen, err := s.queue.Len(ctx)
 	if err != nil {
 		logging.Warn("failed to check queue length", "error", err)
 	}
@@ -518,104 +633,39 @@ func (s *Scheduler) checkSyncProgress(ctx context.Context, channelID string) (qu
 
 // countVideoStatuses counts downloaded and failed videos for a channel
 func (s *Scheduler) countVideoStatuses(ctx context.Context, channelID string) (downloaded, failed int) {
-	// Scan for all videos belonging to this channel
-	videoPattern := videoKeyPrefix + channelID + ":*"
-	var cursor uint64
-
-	for {
-		keys, nextCursor, err := s.redis.Scan(ctx, cursor, videoPattern, 100).Result()
-		if err != nil {
-			logging.Warn("failed to scan video keys", "channel_id", channelID, "error", err)
-			break
-		}
-
-		for _, key := range keys {
-			videoData, err := s.redis.Get(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var video map[string]interface{}
-			if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-				continue
-			}
-
-			status, _ := video["status"].(string)
-			switch status {
-			case "downloaded", "completed":
-				downloaded++
-			case "failed", "error":
-				failed++
-			}
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
-	}
-
+	downloaded, failed, _ = s.countAllVideoStatuses(ctx, channelID)
 	return downloaded, failed
 }
 
 // checkAllVideosProcessed verifies that all videos for a channel have been processed
 func (s *Scheduler) checkAllVideosProcessed(ctx context.Context, channelID string) bool {
-	videoPattern := videoKeyPrefix + channelID + ":*"
-	var cursor uint64
-
-	for {
-		keys, nextCursor, err := s.redis.Scan(ctx, cursor, videoPattern, 100).Result()
-		if err != nil {
-			return false
-		}
-
-		for _, key := range keys {
-			videoData, err := s.redis.Get(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var video map[string]interface{}
-			if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-				continue
-			}
-
-			status, _ := video["status"].(string)
-			// If any video is still pending or downloading, not all are processed
-			if status == "pending" || status == "downloading" || status == "queued" {
-				return false
-			}
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+	counts, err := s.records.Store().CountVideos(ctx, channelID)
+	if err != nil {
+		logging.Warn("failed to count videos", "channel_id", channelID, "error", err)
+		return false
 	}
-
-	return true
+	// If any video is still pending or downloading, not all are processed
+	return counts["pending"]+counts["downloading"]+counts["queued"] == 0
 }
 
-// getChannelVideoIDs retrieves video IDs for a channel from Redis
+// getChannelVideoIDs retrieves the video IDs of a channel
 func (s *Scheduler) getChannelVideoIDs(ctx context.Context, channelID string) ([]string, error) {
-	videoKeys, err := s.redis.Keys(ctx, videoKeyPrefix+channelID+":*").Result()
+	videos, err := s.records.Store().ListVideos(ctx, channelID)
 	if err != nil {
 		return nil, err
 	}
 
-	videoIDs := make([]string, 0, len(videoKeys))
-	for _, key := range videoKeys {
-		// Extract video ID from key (format: video:channelID:videoID)
-		videoID := key[len(videoKeyPrefix+channelID+":"):]
-		videoIDs = append(videoIDs, videoID)
+	videoIDs := make([]string, 0, len(videos))
+	for _, video := range videos {
+		videoIDs = append(videoIDs, video.ID)
 	}
 
 	return videoIDs, nil
 }
 
-// updateSyncJobStatus updates the status of a sync job
-func (s *Scheduler) updateSyncJobStatus(ctx context.Context, syncJobID, status string) {
-	syncJobData, err := s.redis.Get(ctx, syncJobKeyPrefix+syncJobID).Result()
+// updateSyncJob applies update to a sync job and saves it
+func (s *Scheduler) updateSyncJob(ctx context.Context, syncJobID string, update func(*SyncJob)) {
+	syncJobData, err := s.records.SyncJob(ctx, syncJobID)
 	if err != nil {
 		logging.Warn("error fetching sync job",
 			"job_id", syncJobID,
@@ -625,7 +675,7 @@ func (s *Scheduler) updateSyncJobStatus(ctx context.Context, syncJobID, status s
 	}
 
 	var syncJob SyncJob
-	if err := json.Unmarshal([]byte(syncJobData), &syncJob); err != nil {
+	if err := json.Unmarshal(syncJobData, &syncJob); err != nil {
 		logging.Warn("error unmarshaling sync job",
 			"job_id", syncJobID,
 			"error", err,
@@ -633,80 +683,56 @@ func (s *Scheduler) updateSyncJobStatus(ctx context.Context, syncJobID, status s
 		return
 	}
 
-	syncJob.Status = status
+	update(&syncJob)
 	syncJob.UpdatedAt = time.Now()
 
 	syncJobJSON, _ := json.Marshal(syncJob)
-	s.redis.Set(ctx, syncJobKeyPrefix+syncJobID, syncJobJSON, 24*time.Hour)
+	if err := s.records.PutSyncJob(ctx, syncJobJSON); err != nil {
+		logging.Warn("error saving sync job",
+			"job_id", syncJobID,
+			"error", err,
+		)
+	}
+}
+
+// updateSyncJobStatus updates the status of a sync job
+func (s *Scheduler) updateSyncJobStatus(ctx context.Context, syncJobID, status string) {
+	s.updateSyncJob(ctx, syncJobID, func(syncJob *SyncJob) {
+		syncJob.Status = status
+	})
 }
 
 // updateSyncJobVideoCount updates the video count of a sync job
 func (s *Scheduler) updateSyncJobVideoCount(ctx context.Context, syncJobID string, videoCount int) {
-	syncJobData, err := s.redis.Get(ctx, syncJobKeyPrefix+syncJobID).Result()
-	if err != nil {
-		return
-	}
-
-	var syncJob SyncJob
-	if err := json.Unmarshal([]byte(syncJobData), &syncJob); err != nil {
-		return
-	}
-
-	syncJob.VideoCount = videoCount
-	syncJob.UpdatedAt = time.Now()
-
-	syncJobJSON, _ := json.Marshal(syncJob)
-	s.redis.Set(ctx, syncJobKeyPrefix+syncJobID, syncJobJSON, 24*time.Hour)
+	s.updateSyncJob(ctx, syncJobID, func(syncJob *SyncJob) {
+		syncJob.VideoCount = videoCount
+	})
 }
 
 // updateSyncJobProgress updates the progress counters of a sync job
 func (s *Scheduler) updateSyncJobProgress(ctx context.Context, syncJobID string, downloaded, failed int) {
-	syncJobData, err := s.redis.Get(ctx, syncJobKeyPrefix+syncJobID).Result()
-	if err != nil {
-		return
-	}
-
-	var syncJob SyncJob
-	if err := json.Unmarshal([]byte(syncJobData), &syncJob); err != nil {
-		return
-	}
-
-	syncJob.Downloaded = downloaded
-	syncJob.Failed = failed
-	syncJob.UpdatedAt = time.Now()
-
-	syncJobJSON, _ := json.Marshal(syncJob)
-	s.redis.Set(ctx, syncJobKeyPrefix+syncJobID, syncJobJSON, 24*time.Hour)
+	s.updateSyncJob(ctx, syncJobID, func(syncJob *SyncJob) {
+		syncJob.Downloaded = downloaded
+		syncJob.Failed = failed
+	})
 }
 
 // updateChannelStatus updates the status of a channel
 func (s *Scheduler) updateChannelStatus(ctx context.Context, channelID, status string) {
-	channelData, err := s.redis.Get(ctx, channelKeyPrefix+channelID).Result()
+	_, err := s.records.UpdateChannel(ctx, channelID, func(channelMap map[string]interface{}) {
+		channelMap["status"] = status
+		channelMap["updated_at"] = time.Now()
+		if status == "synced" {
+			channelMap["last_sync_at"] = time.Now()
+		}
+	})
 	if err != nil {
-		logging.Warn("error fetching channel",
-			"channel_id", channelID,
-			"error", err,
-		)
-		return
-	}
-
-	var channelMap map[string]interface{}
-	if err := json.Unmarshal([]byte(channelData), &channelMap); err != nil {
-		logging.Warn("error unmarshaling channel",
+		logging.Warn("error updating channel status",
 			"channel_id", channelID,
+			"status", status,
 			"error", err,
 		)
-		return
 	}
-
-	channelMap["status"] = status
-	channelMap["updated_at"] = time.Now()
-	if status == "synced" {
-		channelMap["last_sync_at"] = time.Now()
-	}
-
-	channelJSON, _ := json.Marshal(channelMap)
-	s.redis.Set(ctx, channelKeyPrefix+channelID, channelJSON, 0)
 }
 
 // GetProgress returns the current progress for a channel sync.
@@ -723,44 +749,15 @@ func (s *Scheduler) GetProgress(ctx context.Context, channelID string) (download
 
 // countAllVideoStatuses counts videos by status for a channel
 func (s *Scheduler) countAllVideoStatuses(ctx context.Context, channelID string) (downloaded, failed, pending int) {
-	videoPattern := videoKeyPrefix + channelID + ":*"
-	var cursor uint64
-
-	for {
-		keys, nextCursor, err := s.redis.Scan(ctx, cursor, videoPattern, 100).Result()
-		if err != nil {
-			logging.Warn("failed to scan video keys", "channel_id", channelID, "error", err)
-			break
-		}
-
-		for _, key := range keys {
-			videoData, err := s.redis.Get(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var video map[string]interface{}
-			if err := json.Unmarshal([]byte(videoData), &video); err != nil {
-				continue
-			}
-
-			status, _ := video["status"].(string)
-			switch status {
-			case "completed":
-				downloaded++
-			case "failed", "error":
-				failed++
-			case "pending", "downloading":
-				pending++
-			}
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+	counts, err := s.records.Store().CountVideos(ctx, channelID)
+	if err != nil {
+		logging.Warn("failed to count videos", "channel_id", channelID, "error", err)
+		return 0, 0, 0
 	}
 
+	downloaded = counts["downloaded"] + counts["completed"]
+	failed = counts["failed"] + counts["error"]
+	pending = counts["pending"] + counts["downloading"]
 	return downloaded, failed, pending
 }
 
@@ -789,16 +786,15 @@ func (s *Scheduler) CleanupChannel(ctx context.Context, channelID string) error
 	return nil
 }
 
-// getChannelName retrieves the channel name from Redis
+// getChannelName retrieves the channel name
 func (s *Scheduler) getChannelName(ctx context.Context, channelID string) string {
-	channelKey := channelKeyPrefix + channelID
-	channelData, err := s.redis.Get(ctx, channelKey).Result()
+	channelData, err := s.records.Channel(ctx, channelID)
 	if err != nil {
 		return ""
 	}
 
 	var channelMap map[string]interface{}
-	if err := json.Unmarshal([]byte(channelData), &channelMap); err != nil {
+	if err := json.Unmarshal(channelData, &channelMap); err != nil {
 		return ""
 	}
 
@@ -808,23 +804,64 @@ func (s *Scheduler) getChannelName(ctx context.Context, channelID string) string
 	return ""
 }
 
+// getChannelKinds returns the video kinds archived for a channel.
+// Channels without a kinds setting archive youtube.DefaultKinds.
+func (s *Scheduler) getChannelKinds(ctx context.Context, channelID string) []string {
+	channelData, err := s.records.Channel(ctx, channelID)
+	if err != nil {
+		return youtube.DefaultKinds
+	}
+
+	var channel struct {
+		Kinds []string `json:"kinds"`
+	}
+	if err := json.Unmarshal(channelData, &channel); err != nil || len(channel.Kinds) == 0 {
+		return youtube.DefaultKinds
+	}
+	return channel.Kinds
+}
+
+// getChannelWeight returns a channel's share of the workers relative to other
+// channels with downloads queued at the same priority
+func (s *Scheduler) getChannelWeight(ctx context.Context, channelID string) float64 {
+	channelData, err := s.records.Channel(ctx, channelID)
+	if err != nil {
+		return queue.DefaultWeight
+	}
+
+	var channel struct {
+		Weight float64 `json:"weight"`
+	}
+	if err := json.Unmarshal(channelData, &channel); err != nil {
+		return queue.DefaultWeight
+	}
+	return queue.NormalizeWeight(channel.Weight)
+}
+
+// migrateLegacyQueue moves downloads queued before the download queue had
+// priorities into it
+func (s *Scheduler) migrateLegacyQueue() {
+	moved, err := s.queue.MigrateLegacy(context.Background())
+	if err != nil {
+		logging.Warn("failed to migrate legacy download queue", "moved", moved, "error", err)
+		return
+	}
+	if moved > 0 {
+		logging.Info("migrated legacy download queue", "moved", moved)
+	}
+}
+
 // getExistingEpisodeCount counts existing videos for a channel to determine starting episode number
 func (s *Scheduler) getExistingEpisodeCount(ctx context.Context, channelID string) int {
-	videoPattern := videoKeyPrefix + channelID + ":*"
-	var count int
-	var cursor uint64
-
-	for {
-		keys, nextCursor, err := s.redis.Scan(ctx, cursor, videoPattern, 100).Result()
-		if err != nil {
-			break
-		}
-		count += len(keys)
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+	counts, err := s.records.Store().CountVideos(ctx, channelID)
+	if err != nil {
+		logging.Warn("failed to count videos", "channel_id", channelID, "error", err)
+		return 0
 	}
 
+	var count int
+	for _, n := range counts {
+		count += n
+	}
 	return count
 }
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/queue"
+)
+
+// ScrubResult summarizes a checksum scrub of a channel
+type ScrubResult struct {
+	ChannelID string   `json:"channel_id"`
+	Verified  int      `json:"verified"`
+	Corrupted []string `json:"corrupted"` // videos whose files no longer match their checksum
+	Requeued  int      `json:"requeued"`
+}
+
+// scrubVideo is a downloaded video with a recorded checksum
+type scrubVideo struct {
+	VideoID  string
+	FilePath string
+	Checksum string
+}
+
+// ScrubChannel re-verifies the checksum of every downloaded video of a channel.
+// Videos whose files are missing or no longer match are deleted, marked pending
+// and queued to be downloaded again.
+func (s *Scheduler) ScrubChannel(ctx context.Context, channelID string) (*ScrubResult, error) {
+	result := &ScrubResult{ChannelID: channelID, Corrupted: []string{}}
+
+	videos, err := s.getScrubVideos(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if len(videos) == 0 {
+		return result, nil
+	}
+
+	var corrupted []string
+	for _, video := range videos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		valid, err := s.collector.VerifyVideo(ctx, channelID, video.VideoID, video.FilePath, video.Checksum)
+		if err != nil {
+			logging.Warn("failed to verify checksum",
+				"channel_id", channelID,
+				"video_id", video.VideoID,
+				"error", err,
+			)
+			continue
+		}
+		if valid {
+			result.Verified++
+			s.markVideoVerified(ctx, channelID, video.VideoID)
+			continue
+		}
+
+		logging.Warn("video failed checksum verification",
+			"channel_id", channelID,
+			"video_id", video.VideoID,
+			"file_path", video.FilePath,
+		)
+		result.Corrupted = append(result.Corrupted, video.VideoID)
+		if err := s.resetCorruptedVideo(ctx, channelID, video); err != nil {
+			logging.Warn("failed to reset corrupted video",
+				"channel_id", channelID,
+				"video_id", video.VideoID,
+				"error", err,
+			)
+			continue
+		}
+		corrupted = append(corrupted, video.VideoID)
+	}
+
+	if len(corrupted) > 0 {
+		queued, err := s.queue.Enqueue(ctx, channelID, corrupted, queue.PriorityBackfill, s.getChannelWeight(ctx, channelID))
+		if err != nil {
+			return result, fmt.Errorf("failed to requeue corrupted videos: %w", err)
+		}
+		result.Requeued = int(queued)
+	}
+
+	logging.Info("checksum scrub complete",
+		"channel_id", channelID,
+		"verified", result.Verified,
+		"corrupted", len(result.Corrupted),
+		"requeued", result.Requeued,
+	)
+	return result, nil
+}
+
+// resetCorruptedVideo has the collector delete a corrupted video's files and
+// marks the video pending. The file's blob is deleted too, since every video
+// linked to it shares the corrupted content.
+func (s *Scheduler) resetCorruptedVideo(ctx context.Context, channelID string, video scrubVideo) error {
+	if err := s.collector.DeleteVideo(ctx, channelID, video.VideoID, video.Checksum); err != nil {
+		return fmt.Errorf("failed to delete corrupted files: %w", err)
+	}
+
+	return s.updateVideoRecord(ctx, channelID, video.VideoID, func(record map[string]interface{}) {
+		now := time.Now()
+		record["status"] = string(db.StatusPending)
+		record["scrub_error"] = "checksum mismatch"
+		record["scrubbed_at"] = now
+		record["updated_at"] = now
+		delete(record, "file_path")
+		delete(record, "file_size")
+		delete(record, "checksum")
+	})
+}
+
+// markVideoVerified records when a video last passed checksum verification
+func (s *Scheduler) markVideoVerified(ctx context.Context, channelID, videoID string) {
+	err := s.updateVideoRecord(ctx, channelID, videoID, func(record map[string]interface{}) {
+		record["verified_at"] = time.Now()
+		delete(record, "scrub_error")
+	})
+	if err != nil {
+		logging.Warn("failed to record verification",
+			"channel_id", channelID,
+			"video_id", videoID,
+			"error", err,
+		)
+	}
+}
+
+// updateVideoRecord applies update to a video's record
+func (s *Scheduler) updateVideoRecord(ctx context.Context, channelID, videoID string, update func(map[string]interface{})) error {
+	if _, err := s.records.UpdateVideo(ctx, channelID, videoID, update); err != nil {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+	return nil
+}
+
+// getScrubVideos returns the downloaded videos of a channel that have a
+// checksum and a file on the archive volume
+func (s *Scheduler) getScrubVideos(ctx context.Context, channelID string) ([]scrubVideo, error) {
+	records, err := s.records.Store().ListVideos(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list videos: %w", err)
+	}
+
+	var videos []scrubVideo
+	for _, video := range records {
+		if !scrubbable(video.Status, video.FilePath, video.Checksum) {
+			continue
+		}
+
+		videos = append(videos, scrubVideo{
+			VideoID:  video.ID,
+			FilePath: video.FilePath,
+			Checksum: video.Checksum,
+		})
+	}
+
+	return videos, nil
+}
+
+// scrubbable reports whether a video record can be verified: it must be
+// downloaded, have a checksum and be stored on the local archive volume.
+// Videos in object storage are verified by the storage provider instead.
+func scrubbable(status, filePath, checksum string) bool {
+	if status != "downloaded" && status != "completed" {
+		return false
+	}
+	if checksum == "" || filePath == "" || strings.Contains(filePath, "://") {
+		return false
+	}
+	return filepath.IsAbs(filePath)
+}
+
+// scrubLoop periodically re-verifies the checksums of every channel's videos
+func (s *Scheduler) scrubLoop() {
+	interval := scrubInterval()
+	if interval == 0 {
+		logging.Info("checksum scrub disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.scrubAllChannels(context.Background())
+	}
+}
+
+// scrubAllChannels scrubs every channel, then deletes blobs no video links to
+func (s *Scheduler) scrubAllChannels(ctx context.Context) {
+	channelIDs, err := s.records.ChannelIDs(ctx)
+	if err != nil {
+		logging.Warn("failed to get channel list", "error", err)
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		if _, err := s.ScrubChannel(ctx, channelID); err != nil {
+			logging.Warn("failed to scrub channel",
+				"channel_id", channelID,
+				"error", err,
+			)
+		}
+	}
+
+	s.pruneBlobs(ctx)
+}
+
+// pruneBlobs has the collector delete deduplicated blobs that no video links to any more
+func (s *Scheduler) pruneBlobs(ctx context.Context) {
+	pruned, err := s.collector.PruneBlobs(ctx)
+	if err != nil {
+		logging.Warn("failed to prune blobs", "error", err)
+		return
+	}
+	if pruned > 0 {
+		logging.Info("pruned unreferenced blobs", "pruned", pruned)
+	}
+}
+
+// scrubInterval returns how often checksums are re-verified; 0 disables scrubbing
+func scrubInterval() time.Duration {
+	hours, err := strconv.ParseFloat(getEnvWithDefault("SCRUB_INTERVAL_HOURS", "168"), 64)
+	if err != nil || hours < 0 {
+		hours = 168
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
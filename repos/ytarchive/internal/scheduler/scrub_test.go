@@ -0,0 +1,53 @@
+package scheduler
+
+import "testing"
+
+func TestScrubbable(t *testing.T) {
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	tests := []struct {
+		name     string
+		status   string
+		filePath string
+		checksum string
+		want     bool
+	}{
+		{"downloaded", "downloaded", "/data/channels/c1/videos/v1/video.mp4", checksum, true},
+		{"completed", "completed", "/data/channels/c1/videos/v1/video.mp4", checksum, true},
+		{"pending", "pending", "/data/channels/c1/videos/v1/video.mp4", checksum, false},
+		{"pruned", "pruned", "", checksum, false},
+		{"no checksum", "downloaded", "/data/channels/c1/videos/v1/video.mp4", "", false},
+		{"object storage", "downloaded", "s3://archive/channels/c1/videos/v1/video.mp4", checksum, false},
+		{"relative path", "downloaded", "channels/c1/videos/v1/video.mp4", checksum, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scrubbable(tt.status, tt.filePath, tt.checksum); got != tt.want {
+				t.Errorf("scrubbable(%q, %q) = %v, want %v", tt.status, tt.filePath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"", 168},
+		{"24", 24},
+		{"0", 0},
+		{"-1", 168},
+		{"weekly", 168},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("SCRUB_INTERVAL_HOURS", tt.value)
+			if got := scrubInterval().Hours(); got != tt.want {
+				t.Errorf("scrubInterval() = %vh, want %vh", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/search"
+)
+
+// SearchIndexResult summarizes bringing the search index up to date
+type SearchIndexResult struct {
+	Channels int      `json:"channels"`
+	Indexed  int      `json:"indexed"`
+	Removed  int      `json:"removed"`
+	Failed   []string `json:"failed"`
+}
+
+// UpdateSearchIndex brings the global search index in line with the video
+// records of every channel. Only videos that changed since they were last
+// indexed are reindexed, and channels that were deleted are dropped.
+func (s *Scheduler) UpdateSearchIndex(ctx context.Context) (*SearchIndexResult, error) {
+	channelIDs, err := s.records.ChannelIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel list: %w", err)
+	}
+
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open search index: %w", err)
+	}
+	defer index.Close()
+
+	indexer := search.NewIndexer(index, s.storage.GetBasePath(), s.records)
+	if s.youtubeClient != nil && transcriptFallbackEnabled() {
+		indexer.SetCaptionFallback(s.youtubeClient, subtitleLangs())
+	}
+	result := &SearchIndexResult{Channels: len(channelIDs), Failed: []string{}}
+	for _, channelID := range channelIDs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		videos, err := s.getIndexVideos(ctx, channelID)
+		if err == nil {
+			var synced *search.SyncResult
+			synced, err = indexer.SyncChannel(ctx, channelID, videos)
+			if synced != nil {
+				result.Indexed += synced.Indexed
+				result.Removed += synced.Removed
+			}
+		}
+		if err != nil {
+			logging.Warn("failed to update search index",
+				"channel_id", channelID,
+				"error", err,
+			)
+			result.Failed = append(result.Failed, channelID)
+		}
+	}
+
+	if err := indexer.RemoveOtherChannels(channelIDs); err != nil {
+		return result, err
+	}
+
+	logging.Info("search index updated",
+		"channels", result.Channels,
+		"indexed", result.Indexed,
+		"removed", result.Removed,
+		"failed", len(result.Failed),
+	)
+	return result, nil
+}
+
+// getIndexVideos returns the index entries of a channel's video records
+func (s *Scheduler) getIndexVideos(ctx context.Context, channelID string) ([]db.IndexedVideo, error) {
+	records, err := s.records.Store().ListVideos(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	videos := make([]db.IndexedVideo, 0, len(records))
+	for i := range records {
+		video, err := search.FromRecord(channelID, records[i].ID, records[i].Document())
+		if err != nil {
+			continue
+		}
+		videos = append(videos, *video)
+	}
+
+	return videos, nil
+}
+
+// searchIndexLoop builds the search index when the controller starts, then
+// periodically catches it up with changes to the video records. Downloads
+// are indexed by the collector as they complete.
+func (s *Scheduler) searchIndexLoop() {
+	interval := searchIndexInterval()
+	if interval == 0 {
+		logging.Info("search index updates disabled")
+		return
+	}
+
+	// Give the system a moment to initialize
+	time.Sleep(30 * time.Second)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.UpdateSearchIndex(context.Background()); err != nil {
+			logging.Warn("failed to update search index", "error", err)
+		}
+		<-ticker.C
+	}
+}
+
+// searchIndexInterval returns how often the search index catches up with the
+// video records; 0 disables updates
+func searchIndexInterval() time.Duration {
+	minutes, err := strconv.ParseFloat(getEnvWithDefault("SEARCH_INDEX_INTERVAL_MINUTES", "30"), 64)
+	if err != nil || minutes < 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+// transcriptFallbackEnabled reports whether archived videos without subtitle
+// files are indexed with the automatic captions YouTube has for them
+func transcriptFallbackEnabled() bool {
+	enabled, err := strconv.ParseBool(getEnvWithDefault("TRANSCRIPT_FALLBACK", "true"))
+	return err != nil || enabled
+}
+
+// subtitleLangs returns the languages the workers download subtitles in, in
+// order of preference
+func subtitleLangs() []string {
+	var langs []string
+	for _, lang := range strings.Split(getEnvWithDefault("SUBTITLE_LANGS", "en"), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	if len(langs) == 0 {
+		langs = []string{"en"}
+	}
+	return langs
+}
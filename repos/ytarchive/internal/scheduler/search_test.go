@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchIndexInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"", 30},
+		{"5", 5},
+		{"0", 0},
+		{"-1", 30},
+		{"hourly", 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("SEARCH_INDEX_INTERVAL_MINUTES", tt.value)
+			if got := searchIndexInterval().Minutes(); got != tt.want {
+				t.Errorf("searchIndexInterval() = %vm, want %vm", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtitleLangs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{"en"}},
+		{"de", []string{"de"}},
+		{"en, de,,fr", []string{"en", "de", "fr"}},
+		{" , ", []string{"en"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("SUBTITLE_LANGS", tt.value)
+			if got := subtitleLangs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subtitleLangs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/store"
+)
+
+// SyncSteps runs the steps of a sync workflow. It shares the scheduler's
+// discovery and bookkeeping without starting any of its background loops.
+type SyncSteps struct {
+	s *Scheduler
+}
+
+// NewSyncSteps creates SyncSteps on a Redis client and the archive's records
+func NewSyncSteps(redisClient *redis.Client, records *store.Records) *SyncSteps {
+	return &SyncSteps{s: newScheduler(nil, redisClient, "", records)}
+}
+
+// VerifyResult summarizes the verification step of a sync workflow
+type VerifyResult struct {
+	SyncJobID  string   `json:"sync_job_id"`
+	ChannelID  string   `json:"channel_id"`
+	Downloaded int      `json:"downloaded"`
+	Failed     int      `json:"failed"`
+	Corrupted  []string `json:"corrupted"` // videos whose files did not match their checksum
+}
+
+// Discover saves the new videos of a channel without queueing them, since the
+// workflow downloads them itself, and returns the IDs of the videos to download
+func (p *SyncSteps) Discover(ctx context.Context, syncJobID, channelID, youtubeID string) ([]string, error) {
+	videoIDs, err := p.s.discoverAndSaveVideos(ctx, channelID, youtubeID, false)
+	if err != nil {
+		return nil, err
+	}
+	p.s.updateSyncJobVideoCount(ctx, syncJobID, len(videoIDs))
+	return videoIDs, nil
+}
+
+// Verify checks the videos a sync workflow downloaded: videos still
+// unfinished count as failed, and the collector verifies the checksums of
+// downloaded videos on the archive volume. Corrupted files are deleted and
+// their videos marked pending, so the next sync downloads them again. The
+// counts are recorded as the progress of the sync job.
+func (p *SyncSteps) Verify(ctx context.Context, syncJobID, channelID string, videoIDs []string) (*VerifyResult, error) {
+	result := &VerifyResult{SyncJobID: syncJobID, ChannelID: channelID, Corrupted: []string{}}
+
+	for _, videoID := range videoIDs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		data, err := p.s.records.Video(ctx, channelID, videoID)
+		if errors.Is(err, store.ErrNotFound) {
+			result.Failed++
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to get video %s: %w", videoID, err)
+		}
+		var video struct {
+			Status   string `json:"status"`
+			FilePath string `json:"file_path"`
+			Checksum string `json:"checksum"`
+		}
+		if err := json.Unmarshal(data, &video); err != nil {
+			result.Failed++
+			continue
+		}
+
+		if video.Status != "downloaded" && video.Status != "completed" {
+			result.Failed++
+			continue
+		}
+		if !scrubbable(video.Status, video.FilePath, video.Checksum) {
+			result.Downloaded++
+			continue
+		}
+
+		valid, err := p.s.collector.VerifyVideo(ctx, channelID, videoID, video.FilePath, video.Checksum)
+		if err != nil {
+			return result, fmt.Errorf("failed to verify checksum of %s: %w", videoID, err)
+		}
+		if valid {
+			result.Downloaded++
+			p.s.markVideoVerified(ctx, channelID, videoID)
+			continue
+		}
+
+		logging.Warn("downloaded video failed checksum verification",
+			"job_id", syncJobID,
+			"channel_id", channelID,
+			"video_id", videoID,
+		)
+		result.Failed++
+		result.Corrupted = append(result.Corrupted, videoID)
+		if err := p.s.resetCorruptedVideo(ctx, channelID, scrubVideo{VideoID: videoID, FilePath: video.FilePath, Checksum: video.Checksum}); err != nil {
+			logging.Warn("failed to reset corrupted video",
+				"channel_id", channelID,
+				"video_id", videoID,
+				"error", err,
+			)
+		}
+	}
+
+	p.s.updateSyncJobProgress(ctx, syncJobID, result.Downloaded, result.Failed)
+	logging.Info("sync verification complete",
+		"job_id", syncJobID,
+		"channel_id", channelID,
+		"downloaded", result.Downloaded,
+		"failed", result.Failed,
+		"corrupted", len(result.Corrupted),
+	)
+	return result, nil
+}
+
+// Export publishes what a sync archived: the comments of the new videos, if
+// the channel archives comments, and the search index
+func (p *SyncSteps) Export(ctx context.Context, channelID string) error {
+	if _, err := p.s.ArchiveChannelComments(ctx, channelID); err != nil {
+		return fmt.Errorf("failed to archive comments: %w", err)
+	}
+	if _, err := p.s.UpdateSearchIndex(ctx); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
+
+// SyncBatches splits video IDs into comma-separated batches of at most size
+// videos, one per download step of a sync workflow
+func SyncBatches(videoIDs []string, size int) []string {
+	if size <= 0 {
+		size = 1
+	}
+	batches := make([]string, 0, (len(videoIDs)+size-1)/size)
+	for start := 0; start < len(videoIDs); start += size {
+		end := start + size
+		if end > len(videoIDs) {
+			end = len(videoIDs)
+		}
+		batches = append(batches, strings.Join(videoIDs[start:end], ","))
+	}
+	return batches
+}
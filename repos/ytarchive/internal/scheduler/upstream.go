@@ -0,0 +1,289 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// unlistedReason is recorded for videos that are missing from their channel
+// but still play, such as videos made unlisted
+const unlistedReason = "Unlisted"
+
+// maxMissingFraction is the share of archived videos that may be missing from
+// a listing before it is distrusted. A listing cut short by a page that failed
+// to parse would otherwise flag most of the channel.
+const maxMissingFraction = 0.5
+
+// archivedVideo is what the record of a video says about it upstream
+type archivedVideo struct {
+	ID            string     `json:"-"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Kind          string     `json:"kind"`
+	RemovedAt     *time.Time `json:"removed_at"`
+	RemovedReason string     `json:"removed_reason"`
+}
+
+// videoVersion is the metadata a video had on YouTube from RecordedAt until
+// the next version. A video record keeps its versions once it is edited.
+type videoVersion struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// upstreamChanges summarizes how a channel changed on YouTube since its last sync
+type upstreamChanges struct {
+	Removed  []string // videos newly flagged as no longer listed on the channel
+	Restored []string // videos listed again after being removed
+	Edited   []string // videos whose title or description changed
+}
+
+// detectUpstreamChanges compares the videos listed on a channel with its video
+// records. Videos that disappeared are flagged with the reason the player
+// gives, and edits to titles and descriptions are kept as versions. Only videos
+// of the kinds whose tabs were listed are compared.
+func (s *Scheduler) detectUpstreamChanges(ctx context.Context, channelID string, kinds []string, listed map[string]youtube.Video) {
+	if len(listed) == 0 {
+		// An empty listing is more likely a parsing failure than an empty channel
+		return
+	}
+
+	archived, err := s.archivedVideos(ctx, channelID)
+	if err != nil {
+		logging.Warn("failed to read video records", "channel_id", channelID, "error", err)
+		return
+	}
+
+	changes := s.applyUpstreamChanges(ctx, channelID, kinds, archived, listed)
+	if len(changes.Removed) > 0 || len(changes.Restored) > 0 || len(changes.Edited) > 0 {
+		logging.Info("channel changed upstream",
+			"channel_id", channelID,
+			"removed", len(changes.Removed),
+			"restored", len(changes.Restored),
+			"edited", len(changes.Edited),
+		)
+	}
+}
+
+// archivedVideos returns the records of a channel's videos
+func (s *Scheduler) archivedVideos(ctx context.Context, channelID string) ([]archivedVideo, error) {
+	records, err := s.records.Store().ListVideos(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	videos := make([]archivedVideo, 0, len(records))
+	for i := range records {
+		var video archivedVideo
+		if err := json.Unmarshal(records[i].Document(), &video); err != nil {
+			logging.Warn("failed to parse video record", "channel_id", channelID, "video_id", records[i].ID, "error", err)
+			continue
+		}
+		video.ID = records[i].ID
+		videos = append(videos, video)
+	}
+	return videos, nil
+}
+
+// applyUpstreamChanges records the differences between the archived videos of
+// a channel and its listing
+func (s *Scheduler) applyUpstreamChanges(ctx context.Context, channelID string, kinds []string, archived []archivedVideo, listed map[string]youtube.Video) *upstreamChanges {
+	changes := &upstreamChanges{}
+	wanted := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	var missing []archivedVideo
+	var compared int
+	for _, video := range archived {
+		kind := video.Kind
+		if kind == "" {
+			kind = db.KindVideo
+		}
+		if !wanted[kind] {
+			continue
+		}
+		compared++
+
+		current, ok := listed[video.ID]
+		if !ok {
+			missing = append(missing, video)
+			continue
+		}
+
+		if video.RemovedAt != nil {
+			if err := s.restoreVideo(ctx, channelID, video.ID); err != nil {
+				logging.Warn("failed to restore video", "channel_id", channelID, "video_id", video.ID, "error", err)
+			} else {
+				changes.Restored = append(changes.Restored, video.ID)
+			}
+		}
+
+		if metadataChanged(video, current) {
+			edited, err := s.recordEdit(ctx, channelID, video, current)
+			if err != nil {
+				logging.Warn("failed to record video edit", "channel_id", channelID, "video_id", video.ID, "error", err)
+			} else if edited {
+				changes.Edited = append(changes.Edited, video.ID)
+			}
+		}
+	}
+
+	if len(missing) > 1 && float64(len(missing)) > maxMissingFraction*float64(compared) {
+		logging.Warn("listing is missing most archived videos, not checking for removals",
+			"channel_id", channelID,
+			"missing", len(missing),
+			"archived", compared,
+		)
+		return changes
+	}
+
+	for _, video := range missing {
+		if ctx.Err() != nil {
+			break
+		}
+		removed, err := s.checkRemovedVideo(ctx, channelID, video)
+		if err != nil {
+			logging.Warn("failed to check missing video", "channel_id", channelID, "video_id", video.ID, "error", err)
+			continue
+		}
+		if removed {
+			changes.Removed = append(changes.Removed, video.ID)
+		}
+	}
+
+	return changes
+}
+
+// checkRemovedVideo asks YouTube why a video is missing from its channel and
+// flags it. Returns whether the video was newly flagged or its reason changed.
+func (s *Scheduler) checkRemovedVideo(ctx context.Context, channelID string, video archivedVideo) (bool, error) {
+	availability, err := s.youtubeClient.GetVideoAvailabilityContext(ctx, video.ID)
+	if err != nil {
+		return false, err
+	}
+
+	reason := availability.Reason
+	if availability.Playable {
+		reason = unlistedReason
+	}
+	if video.RemovedAt != nil && video.RemovedReason == reason {
+		return false, nil
+	}
+
+	// The time it was first missed is kept; the reason is updated, since a
+	// private video may later be deleted
+	removedAt := time.Now()
+	if video.RemovedAt != nil {
+		removedAt = *video.RemovedAt
+	}
+	if err := s.updateVideoRecord(ctx, channelID, video.ID, func(record map[string]interface{}) {
+		record["removed_at"] = removedAt
+		record["removed_reason"] = reason
+	}); err != nil {
+		return false, err
+	}
+
+	logging.Info("video removed upstream",
+		"channel_id", channelID,
+		"video_id", video.ID,
+		"reason", reason,
+	)
+	return true, nil
+}
+
+// restoreVideo clears the removed flag of a video listed on its channel again
+func (s *Scheduler) restoreVideo(ctx context.Context, channelID, videoID string) error {
+	return s.updateVideoRecord(ctx, channelID, videoID, func(record map[string]interface{}) {
+		delete(record, "removed_at")
+		delete(record, "removed_reason")
+	})
+}
+
+// recordEdit fetches the full metadata of a video whose listing no longer
+// matches the archive and keeps the previous version. The listing only has a
+// snippet of the description; if the metadata cannot be fetched, the new title
+// is recorded with the archived description.
+func (s *Scheduler) recordEdit(ctx context.Context, channelID string, archived archivedVideo, listed youtube.Video) (bool, error) {
+	title, description := listed.Title, archived.Description
+	if title == "" {
+		title = archived.Title
+	}
+	if metadata, err := s.youtubeClient.GetVideoMetadataContext(ctx, archived.ID); err == nil {
+		if metadata.Title != "" {
+			title = metadata.Title
+		}
+		description = metadata.Description
+	} else {
+		logging.Warn("failed to fetch edited video metadata, recording the listed title",
+			"channel_id", channelID,
+			"video_id", archived.ID,
+			"error", err,
+		)
+	}
+
+	var edited bool
+	err := s.updateVideoRecord(ctx, channelID, archived.ID, func(record map[string]interface{}) {
+		edited = recordVersion(record, title, description, time.Now())
+	})
+	return edited, err
+}
+
+// recordVersion stores the title and description a video has upstream on its
+// record. When they differ from the archived ones, the change is kept in the
+// record's versions; the first edit also keeps the metadata the video was
+// archived with. Returns whether the metadata changed.
+func recordVersion(record map[string]interface{}, title, description string, now time.Time) bool {
+	oldTitle, _ := record["title"].(string)
+	oldDescription, _ := record["description"].(string)
+	if oldTitle == title && oldDescription == description {
+		return false
+	}
+
+	versions, _ := record["versions"].([]interface{})
+	if len(versions) == 0 {
+		archivedAt := now
+		if createdAt, ok := record["created_at"].(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+				archivedAt = t
+			}
+		}
+		versions = append(versions, videoVersion{Title: oldTitle, Description: oldDescription, RecordedAt: archivedAt})
+	}
+	record["versions"] = append(versions, videoVersion{Title: title, Description: description, RecordedAt: now})
+	record["title"] = title
+	record["description"] = description
+	record["edited_at"] = now
+	return true
+}
+
+// metadataChanged reports whether a listed video's title or description
+// snippet no longer matches its archived metadata. Listings only carry the
+// start of the description, so the snippet is compared as a prefix with
+// whitespace collapsed.
+func metadataChanged(archived archivedVideo, listed youtube.Video) bool {
+	if listed.Title != "" && listed.Title != archived.Title {
+		return true
+	}
+
+	snippet := strings.TrimSpace(listed.Description)
+	snippet = strings.TrimSuffix(strings.TrimSuffix(snippet, "..."), "…")
+	snippet = collapseSpace(snippet)
+	if snippet == "" {
+		return false
+	}
+	return !strings.HasPrefix(collapseSpace(archived.Description), snippet)
+}
+
+// collapseSpace replaces each run of whitespace with a single space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/store"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+func TestMetadataChanged(t *testing.T) {
+	archived := archivedVideo{
+		ID:          "vid",
+		Title:       "How to archive",
+		Description: "In this video we archive\na channel.\n\nLinks below",
+	}
+
+	tests := []struct {
+		name    string
+		title   string
+		snippet string
+		want    bool
+	}{
+		{"unchanged", "How to archive", "In this video we archive a channel.", false},
+		{"no snippet", "How to archive", "", false},
+		{"truncated snippet", "How to archive", "In this video we arch...", false},
+		{"no title", "", "In this video", false},
+		{"title edited", "How to archive (2024)", "In this video we archive", true},
+		{"description edited", "How to archive", "Sponsored by", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := youtube.Video{ID: "vid", Title: tt.title, Description: tt.snippet}
+			if got := metadataChanged(archived, listed); got != tt.want {
+				t.Errorf("metadataChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// playerTransport answers YouTube's player endpoint from a map of video IDs
+// to player responses, and every other endpoint with an empty response
+type playerTransport map[string]string
+
+func (p playerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := "{}"
+	if r.URL.Path == "/youtubei/v1/player" {
+		var req struct {
+			VideoID string `json:"videoId"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		body = p[req.VideoID]
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+		Request:    r,
+	}, nil
+}
+
+// newUpstreamTest returns a scheduler with a SQLite store of records and a
+// YouTube client answered by player
+func newUpstreamTest(t *testing.T, player playerTransport) *Scheduler {
+	t.Helper()
+	state, err := store.Open(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { state.Close() })
+
+	client, err := youtube.NewClient(youtube.WithHTTPClient(&http.Client{Transport: player}), youtube.WithRateLimit(1000, 100))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return &Scheduler{records: store.NewRecords(state, nil), youtubeClient: client}
+}
+
+func TestDetectUpstreamChanges(t *testing.T) {
+	ctx := context.Background()
+	const (
+		kept     = "kept0000000"
+		edited   = "edited00000"
+		gone     = "gone0000000"
+		restored = "restored000"
+		short    = "short000000"
+	)
+	s := newUpstreamTest(t, playerTransport{
+		edited: `{"playabilityStatus":{"status":"OK"},"videoDetails":{"videoId":"` + edited + `","title":"New title","shortDescription":"New description"}}`,
+		gone:   `{"playabilityStatus":{"status":"ERROR","reason":"This video has been removed by the uploader"}}`,
+	})
+
+	removedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := map[string]map[string]interface{}{
+		kept:     {"title": "Kept", "description": "Same"},
+		edited:   {"title": "Old title", "description": "Old description", "created_at": "2023-05-01T00:00:00Z"},
+		gone:     {"title": "Gone"},
+		restored: {"title": "Restored", "removed_at": removedAt, "removed_reason": "Private video"},
+		short:    {"title": "Short", "kind": "short"},
+	}
+	for videoID, record := range records {
+		record["id"] = videoID
+		record["status"] = "downloaded"
+		data, _ := json.Marshal(record)
+		if err := s.records.PutVideo(ctx, "c1", videoID, data); err != nil {
+			t.Fatalf("PutVideo(%s) error = %v", videoID, err)
+		}
+	}
+
+	listed := map[string]youtube.Video{
+		kept:     {ID: kept, Title: "Kept", Description: "Same"},
+		edited:   {ID: edited, Title: "New title"},
+		restored: {ID: restored, Title: "Restored"},
+	}
+	// The short is missing too, but only the Videos tab was listed
+	s.detectUpstreamChanges(ctx, "c1", []string{"video"}, listed)
+
+	video := func(videoID string) map[string]interface{} {
+		t.Helper()
+		data, err := s.records.Video(ctx, "c1", videoID)
+		if err != nil {
+			t.Fatalf("Video(%s) error = %v", videoID, err)
+		}
+		var record map[string]interface{}
+		json.Unmarshal(data, &record)
+		return record
+	}
+
+	if got := video(gone); got["removed_reason"] != "This video has been removed by the uploader" || got["removed_at"] == nil {
+		t.Errorf("gone video = %v, want it flagged removed", got)
+	}
+	if got := video(restored); got["removed_at"] != nil || got["removed_reason"] != nil {
+		t.Errorf("restored video = %v, want the removed flag cleared", got)
+	}
+	for _, videoID := range []string{kept, short} {
+		if got := video(videoID); got["removed_at"] != nil || got["versions"] != nil {
+			t.Errorf("video %s = %v, want it unchanged", videoID, got)
+		}
+	}
+
+	got := video(edited)
+	if got["title"] != "New title" || got["description"] != "New description" {
+		t.Errorf("edited video = %v, want the metadata from the player", got)
+	}
+	versions, _ := got["versions"].([]interface{})
+	if len(versions) != 2 {
+		t.Fatalf("edited video versions = %v, want the original and the edit", got["versions"])
+	}
+	original := versions[0].(map[string]interface{})
+	if original["title"] != "Old title" || original["recorded_at"] != "2023-05-01T00:00:00Z" {
+		t.Errorf("original version = %v", original)
+	}
+
+	// A second sync with the same listing changes nothing
+	firstMissed := video(gone)["removed_at"]
+	s.detectUpstreamChanges(ctx, "c1", []string{"video"}, listed)
+	if versions, _ := video(edited)["versions"].([]interface{}); len(versions) != 2 {
+		t.Errorf("versions after a second sync = %d, want 2", len(versions))
+	}
+	if got := video(gone)["removed_at"]; got != firstMissed {
+		t.Errorf("removed_at after a second sync = %v, want %v", got, firstMissed)
+	}
+}
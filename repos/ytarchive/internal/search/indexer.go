@@ -0,0 +1,336 @@
+// Package search keeps the global search index in step with the archive.
+//
+// Each video is indexed with its title and description, the titles of the
+// chapters in its metadata.json and the text of the subtitles saved next to
+// it, so one query finds a video by anything said in it on any channel. The
+// subtitles are also kept cue by cue, so a search of what was said returns
+// the moment it was said. Archived videos without subtitle files can fall
+// back to the captions YouTube generates for them.
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+// indexTimeout bounds indexing a video once its download completes
+const indexTimeout = 2 * time.Minute
+
+// indexVersion is part of every fingerprint; changing it reindexes every
+// video, for when what is indexed changes. Version 2 added transcript cues.
+const indexVersion = "2"
+
+// CaptionFetcher fetches the caption tracks of videos from YouTube.
+// *youtube.Client implements it.
+type CaptionFetcher interface {
+	GetCaptionsContext(ctx context.Context, videoID string) ([]youtube.CaptionInfo, error)
+	GetCaptionByLanguage(captions []youtube.CaptionInfo, langCode string, preferAutomatic bool) *youtube.CaptionInfo
+	DownloadCaptionContext(ctx context.Context, captionURL string) ([]byte, error)
+}
+
+// VideoRecords reads the video records of the state database.
+// *store.Records implements it.
+type VideoRecords interface {
+	Video(ctx context.Context, channelID, videoID string) ([]byte, error)
+}
+
+// Indexer adds videos to the search index
+type Indexer struct {
+	index        *sql.DB
+	archivePath  string // root of the archive volume; "" indexes no chapters or transcripts
+	records      VideoRecords
+	captions     CaptionFetcher
+	captionLangs []string
+}
+
+// NewIndexer creates an indexer writing to index that reads the chapters and
+// subtitles of videos from the archive at archivePath, and the metadata of
+// newly downloaded videos from records
+func NewIndexer(index *sql.DB, archivePath string, records VideoRecords) *Indexer {
+	return &Indexer{index: index, archivePath: archivePath, records: records}
+}
+
+// SyncResult summarizes bringing the index entries of a channel up to date
+type SyncResult struct {
+	Indexed int // videos added or reindexed
+	Removed int // entries of videos the channel no longer has
+}
+
+// SetCaptionFallback makes the indexer fetch the automatic captions of
+// archived videos that have no subtitle files, in the first of langs YouTube
+// has them in
+func (ix *Indexer) SetCaptionFallback(fetcher CaptionFetcher, langs []string) {
+	ix.captions = fetcher
+	ix.captionLangs = langs
+}
+
+// Index adds a video to the index together with the chapters and transcript
+// found in its directory of the archive
+func (ix *Indexer) Index(ctx context.Context, video *db.IndexedVideo) error {
+	video.Fingerprint = Fingerprint(video)
+
+	var transcripts []Transcript
+	if dir := ix.videoDir(video.ChannelID, video.VideoID); dir != "" {
+		video.Chapters = strings.Join(readChapters(dir), "\n")
+		transcripts = readTranscripts(dir)
+	}
+	if len(transcripts) == 0 && ix.captions != nil && isArchived(video.Status) {
+		fetched, err := ix.fetchCaptions(ctx, video.VideoID)
+		if err != nil {
+			logging.Warn("failed to fetch captions",
+				"channel_id", video.ChannelID,
+				"video_id", video.VideoID,
+				"error", err,
+			)
+			// Without a fingerprint the next sync tries again
+			video.Fingerprint = ""
+		}
+		transcripts = fetched
+	}
+	video.Transcript = transcriptText(transcripts)
+
+	if err := db.IndexVideo(ix.index, video); err != nil {
+		return err
+	}
+
+	var cues []db.TranscriptCue
+	for _, transcript := range transcripts {
+		for _, cue := range transcript.Cues {
+			cues = append(cues, db.TranscriptCue{
+				Language: transcript.Language,
+				Source:   transcript.Source,
+				StartMs:  cue.Start.Milliseconds(),
+				EndMs:    cue.End.Milliseconds(),
+				Text:     cue.Text,
+			})
+		}
+	}
+	return db.ReplaceTranscriptCues(ix.index, video.ChannelID, video.VideoID, cues)
+}
+
+// fetchCaptions returns the transcript of a video from the first caption
+// language YouTube has, preferring automatic captions, or nil if it has none
+func (ix *Indexer) fetchCaptions(ctx context.Context, videoID string) ([]Transcript, error) {
+	captions, err := ix.captions.GetCaptionsContext(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lang := range ix.captionLangs {
+		caption := ix.captions.GetCaptionByLanguage(captions, lang, true)
+		if caption == nil {
+			continue
+		}
+
+		data, err := ix.captions.DownloadCaptionContext(ctx, srv3URL(caption.BaseURL))
+		if err != nil {
+			return nil, err
+		}
+		cues, err := ParseSRV3(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if len(cues) == 0 {
+			continue
+		}
+		return []Transcript{{Language: caption.LanguageCode, Source: db.TranscriptSourceCaptions, Cues: cues}}, nil
+	}
+	return nil, nil
+}
+
+// srv3URL returns the URL of a caption track in the srv3 format
+func srv3URL(baseURL string) string {
+	if strings.Contains(baseURL, "?") {
+		return baseURL + "&fmt=srv3"
+	}
+	return baseURL + "?fmt=srv3"
+}
+
+// isArchived reports whether a video status means its files are in the archive
+func isArchived(status string) bool {
+	return status == "downloaded" || status == string(db.StatusCompleted)
+}
+
+// SyncChannel brings the index entries of a channel in line with its videos.
+// Videos that changed since they were indexed are reindexed, and entries of
+// videos not among them are removed.
+func (ix *Indexer) SyncChannel(ctx context.Context, channelID string, videos []db.IndexedVideo) (*SyncResult, error) {
+	indexed, err := db.GetIndexFingerprints(ix.index, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	current := make(map[string]bool, len(videos))
+	for i := range videos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		video := &videos[i]
+		video.ChannelID = channelID
+		current[video.VideoID] = true
+		if fingerprint, ok := indexed[video.VideoID]; ok && fingerprint == Fingerprint(video) {
+			continue
+		}
+		if err := ix.Index(ctx, video); err != nil {
+			return result, err
+		}
+		result.Indexed++
+	}
+
+	for videoID := range indexed {
+		if current[videoID] {
+			continue
+		}
+		if err := db.RemoveIndexedVideo(ix.index, channelID, videoID); err != nil {
+			return result, err
+		}
+		result.Removed++
+	}
+
+	return result, nil
+}
+
+// RemoveOtherChannels removes the index entries of every channel not listed
+func (ix *Indexer) RemoveOtherChannels(channelIDs []string) error {
+	indexed, err := db.GetIndexedChannels(ix.index)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(channelIDs))
+	for _, channelID := range channelIDs {
+		keep[channelID] = true
+	}
+	for _, channelID := range indexed {
+		if keep[channelID] {
+			continue
+		}
+		if err := db.RemoveIndexedChannel(ix.index, channelID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VideoCompleted indexes a video once its download is recorded, so it can be
+// found by its transcript right away. It is called with the video's ID and
+// the path of its file.
+func (ix *Indexer) VideoCompleted(videoID, filePath string) {
+	channelID := channelIDFromPath(filePath, videoID)
+	if channelID == "" {
+		logging.Warn("archived video is outside the archive, not indexing it",
+			"video_id", videoID,
+			"file_path", filePath,
+		)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+		defer cancel()
+		if err := ix.indexRecord(ctx, channelID, videoID); err != nil {
+			logging.Warn("failed to update search index", "channel_id", channelID, "video_id", videoID, "error", err)
+		}
+	}()
+}
+
+// indexRecord indexes a video as its record in the state database has it
+func (ix *Indexer) indexRecord(ctx context.Context, channelID, videoID string) error {
+	doc, err := ix.records.Video(ctx, channelID, videoID)
+	if err != nil {
+		return fmt.Errorf("failed to get video: %w", err)
+	}
+	video, err := FromRecord(channelID, videoID, doc)
+	if err != nil {
+		return err
+	}
+	return ix.Index(ctx, video)
+}
+
+// FromRecord returns the index entry of a video from its record
+func FromRecord(channelID, videoID string, doc []byte) (*db.IndexedVideo, error) {
+	var record struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		Duration    int64      `json:"duration"`
+		UploadDate  string     `json:"upload_date"`
+		Kind        string     `json:"kind"`
+		Status      string     `json:"status"`
+		RemovedAt   *time.Time `json:"removed_at"`
+	}
+	if err := json.Unmarshal(doc, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse video %s: %w", videoID, err)
+	}
+	// Videos discovered before kinds were recorded all came from the Videos tab
+	if record.Kind == "" {
+		record.Kind = db.KindVideo
+	}
+
+	return &db.IndexedVideo{
+		ChannelID:   channelID,
+		VideoID:     videoID,
+		Title:       record.Title,
+		Description: record.Description,
+		UploadDate:  record.UploadDate,
+		Duration:    record.Duration,
+		Kind:        record.Kind,
+		Status:      record.Status,
+		RemovedAt:   record.RemovedAt,
+	}, nil
+}
+
+// Fingerprint identifies the metadata a video is indexed with. A video whose
+// fingerprint is unchanged does not need to be reindexed; its chapters and
+// transcript only change when it is downloaded, which changes its status.
+func Fingerprint(video *db.IndexedVideo) string {
+	var removedAt string
+	if video.RemovedAt != nil {
+		removedAt = video.RemovedAt.UTC().Format(time.RFC3339)
+	}
+
+	hash := sha256.New()
+	for _, field := range []string{
+		indexVersion, video.Title, video.Description, video.UploadDate, strconv.FormatInt(video.Duration, 10),
+		video.Kind, video.Status, removedAt,
+	} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// videoDir returns the directory of a video in the archive, or "" without one
+func (ix *Indexer) videoDir(channelID, videoID string) string {
+	if ix.archivePath == "" {
+		return ""
+	}
+	return filepath.Join(ix.archivePath, "channels", channelID, "videos", videoID)
+}
+
+// channelIDFromPath returns the channel ID of a video file stored at
+// .../channels/{channel_id}/videos/{video_id}/..., or "" for other paths.
+// Paths in object stores are laid out the same way.
+func channelIDFromPath(filePath, videoID string) string {
+	parts := strings.Split(filepath.ToSlash(filePath), "/")
+	for i := len(parts) - 1; i >= 3; i-- {
+		if parts[i] == videoID && parts[i-1] == "videos" && parts[i-3] == "channels" {
+			return parts[i-2]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,262 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/youtube"
+)
+
+const autoCaptions = `WEBVTT
+Kind: captions
+Language: en
+
+NOTE generated by YouTube
+with word timings
+
+00:00:00.000 --> 00:00:02.000 align:start position:0%
+welcome<00:00:00.500><c> back</c><00:00:01.000><c> to</c>
+
+00:00:02.000 --> 00:00:02.010
+welcome back to
+
+00:00:02.010 --> 00:00:04.000
+welcome back to
+the<00:00:02.500><c> workshop</c> &amp; yard
+
+intro
+00:00:04.000 --> 00:00:06.000
+<v Speaker>today we build a bridge</v>
+`
+
+func TestParseVTT(t *testing.T) {
+	want := []Cue{
+		{Start: 0, End: 2 * time.Second, Text: "welcome back to"},
+		{Start: 2010 * time.Millisecond, End: 4 * time.Second, Text: "the workshop & yard"},
+		{Start: 4 * time.Second, End: 6 * time.Second, Text: "today we build a bridge"},
+	}
+	if got := ParseVTT(strings.NewReader(autoCaptions)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseVTT() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseSRV3(t *testing.T) {
+	captions := `<?xml version="1.0" encoding="utf-8" ?>
+<timedtext format="3">
+<body>
+<p t="0" d="2500" w="1"><s ac="0">welcome</s><s t="500" ac="0"> back</s></p>
+<p t="1200" d="1300" a="1">
+</p>
+<p t="2500" d="1500">the workshop &amp;amp; yard</p>
+</body>
+</timedtext>`
+
+	got, err := ParseSRV3(strings.NewReader(captions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cue{
+		{Start: 0, End: 2500 * time.Millisecond, Text: "welcome back"},
+		{Start: 2500 * time.Millisecond, End: 4 * time.Second, Text: "the workshop & yard"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSRV3() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseSRV3(strings.NewReader("not xml")); err == nil {
+		t.Error("ParseSRV3() of invalid input should fail")
+	}
+}
+
+func TestChannelIDFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/data/channels/ch1/videos/vid1/video.mp4", "ch1"},
+		{"s3://bucket/archive/channels/ch1/videos/vid1/video.mp4", "ch1"},
+		{"/data/imports/vid1/video.mp4", ""},
+		{"/data/channels/ch1/videos/other/video.mp4", ""},
+	}
+
+	for _, tt := range tests {
+		if got := channelIDFromPath(tt.path, "vid1"); got != tt.want {
+			t.Errorf("channelIDFromPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSyncChannel(t *testing.T) {
+	t.Setenv("STORAGE_PATH", t.TempDir())
+	archive := t.TempDir()
+
+	dir := filepath.Join(archive, "channels", "ch1", "videos", "v1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "subtitles.vtt"), []byte(autoCaptions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata := `{"id": "v1", "chapters": [{"title": "Intro", "start_time": 0, "end_time": 4}, {"title": "Clamping", "start_time": 4, "end_time": 6}]}`
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	indexer := NewIndexer(index, archive, nil)
+	ctx := context.Background()
+
+	videos := []db.IndexedVideo{
+		{VideoID: "v1", Title: "Workshop tour", Status: "downloaded"},
+		{VideoID: "v2", Title: "Upcoming", Status: "pending"},
+	}
+	result, err := indexer.SyncChannel(ctx, "ch1", videos)
+	if err != nil || result.Indexed != 2 || result.Removed != 0 {
+		t.Fatalf("SyncChannel() = %+v, %v, want 2 indexed", result, err)
+	}
+
+	for _, query := range []string{"bridge", "clamping"} {
+		found, err := db.SearchIndex(index, db.IndexSearch{Query: query})
+		if err != nil || len(found.Hits) != 1 || found.Hits[0].VideoID != "v1" {
+			t.Errorf("SearchIndex(%q) = %+v, %v, want v1", query, found, err)
+		}
+	}
+
+	moments, err := db.SearchTranscripts(index, db.TranscriptSearch{Query: "bridge"})
+	if err != nil || len(moments.Hits) != 1 || moments.Hits[0].StartMs != 4000 {
+		t.Errorf("SearchTranscripts() = %+v, %v, want the cue at 4s", moments, err)
+	}
+
+	// Unchanged videos are skipped and ones no longer listed are removed
+	result, err = indexer.SyncChannel(ctx, "ch1", videos[:1])
+	if err != nil || result.Indexed != 0 || result.Removed != 1 {
+		t.Errorf("SyncChannel() = %+v, %v, want 1 removed", result, err)
+	}
+
+	// A changed video is reindexed
+	videos[0].Title = "Workshop tour, part one"
+	result, err = indexer.SyncChannel(ctx, "ch1", videos[:1])
+	if err != nil || result.Indexed != 1 {
+		t.Errorf("SyncChannel() = %+v, %v, want 1 indexed", result, err)
+	}
+
+	if err := indexer.RemoveOtherChannels([]string{"ch2"}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := db.CountIndexedVideos(index); count != 0 {
+		t.Errorf("CountIndexedVideos() = %d, want 0 after removing ch1", count)
+	}
+}
+
+// fakeRecords serves video records from memory
+type fakeRecords map[string]string
+
+func (f fakeRecords) Video(ctx context.Context, channelID, videoID string) ([]byte, error) {
+	doc, ok := f[channelID+"/"+videoID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(doc), nil
+}
+
+func TestIndexRecord(t *testing.T) {
+	t.Setenv("STORAGE_PATH", t.TempDir())
+
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	records := fakeRecords{
+		"ch1/v1": `{"id":"v1","title":"Bridge building","description":"Timber trusses","duration":600,"status":"downloaded","removed_at":"2024-03-01T00:00:00Z"}`,
+	}
+	indexer := NewIndexer(index, "", records)
+	ctx := context.Background()
+
+	if err := indexer.indexRecord(ctx, "ch1", "v1"); err != nil {
+		t.Fatalf("indexRecord() error = %v", err)
+	}
+	found, err := db.SearchIndex(index, db.IndexSearch{Query: "trusses"})
+	if err != nil || len(found.Hits) != 1 || found.Hits[0].VideoID != "v1" || found.Hits[0].ChannelID != "ch1" {
+		t.Fatalf("SearchIndex() = %+v, %v, want v1", found, err)
+	}
+	if hit := found.Hits[0]; hit.Kind != db.KindVideo || hit.Status != "downloaded" || hit.RemovedAt == nil {
+		t.Errorf("indexed video = %+v, want a removed, downloaded video", hit)
+	}
+
+	if err := indexer.indexRecord(ctx, "ch1", "missing"); err == nil {
+		t.Error("indexRecord() of a video without a record succeeded")
+	}
+}
+
+// fakeCaptions serves one automatic caption track
+type fakeCaptions struct {
+	fetched []string
+}
+
+func (f *fakeCaptions) GetCaptionsContext(ctx context.Context, videoID string) ([]youtube.CaptionInfo, error) {
+	return []youtube.CaptionInfo{
+		{LanguageCode: "de", BaseURL: "https://example.com/api/timedtext?v=" + videoID + "&lang=de", IsAutomatic: true},
+		{LanguageCode: "en", BaseURL: "https://example.com/api/timedtext?v=" + videoID + "&lang=en", IsAutomatic: true},
+	}, nil
+}
+
+func (f *fakeCaptions) GetCaptionByLanguage(captions []youtube.CaptionInfo, langCode string, preferAutomatic bool) *youtube.CaptionInfo {
+	return (&youtube.Client{}).GetCaptionByLanguage(captions, langCode, preferAutomatic)
+}
+
+func (f *fakeCaptions) DownloadCaptionContext(ctx context.Context, captionURL string) ([]byte, error) {
+	f.fetched = append(f.fetched, captionURL)
+	return []byte(`<timedtext format="3"><body><p t="61000" d="2000">sawing the planks</p></body></timedtext>`), nil
+}
+
+func TestIndexCaptionFallback(t *testing.T) {
+	t.Setenv("STORAGE_PATH", t.TempDir())
+
+	index, err := db.OpenSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+
+	captions := &fakeCaptions{}
+	indexer := NewIndexer(index, t.TempDir(), nil)
+	indexer.SetCaptionFallback(captions, []string{"fr", "en"})
+	ctx := context.Background()
+
+	// Videos not in the archive have no transcript to fetch
+	if err := indexer.Index(ctx, &db.IndexedVideo{ChannelID: "ch1", VideoID: "v1", Status: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(captions.fetched) != 0 {
+		t.Errorf("fetched %q for a video that is not archived", captions.fetched)
+	}
+
+	if err := indexer.Index(ctx, &db.IndexedVideo{ChannelID: "ch1", VideoID: "v2", Status: "downloaded"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/api/timedtext?v=v2&lang=en&fmt=srv3"}
+	if !reflect.DeepEqual(captions.fetched, want) {
+		t.Errorf("fetched %q, want %q", captions.fetched, want)
+	}
+
+	cues, err := db.GetTranscriptCues(index, "ch1", "v2", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCues := []db.TranscriptCue{{Language: "en", Source: db.TranscriptSourceCaptions, StartMs: 61000, EndMs: 63000, Text: "sawing the planks"}}
+	if !reflect.DeepEqual(cues, wantCues) {
+		t.Errorf("GetTranscriptCues() = %+v, want %+v", cues, wantCues)
+	}
+}
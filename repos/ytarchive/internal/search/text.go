@@ -0,0 +1,251 @@
+package search
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/downloader"
+)
+
+// Cue is a caption shown from Start to End in a video
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Transcript is the cues of a video in one language
+type Transcript struct {
+	Language string
+	Source   string // db.TranscriptSourceSubtitles or db.TranscriptSourceCaptions
+	Cues     []Cue
+}
+
+// cueTagPattern matches the markup inside WebVTT cue text, such as the
+// <00:00:01.500><c> word timings of automatic captions
+var cueTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// readChapters returns the chapter titles in the metadata.json of a video directory
+func readChapters(videoDir string) []string {
+	data, err := os.ReadFile(filepath.Join(videoDir, "metadata.json"))
+	if err != nil {
+		return nil
+	}
+
+	var metadata struct {
+		Chapters []downloader.Chapter `json:"chapters"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil
+	}
+
+	var titles []string
+	for _, chapter := range metadata.Chapters {
+		if title := strings.TrimSpace(chapter.Title); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// readTranscripts parses every subtitle file in a video directory
+func readTranscripts(videoDir string) []Transcript {
+	var transcripts []Transcript
+	for _, subtitle := range downloader.FindSubtitles(videoDir) {
+		file, err := os.Open(subtitle.Path)
+		if err != nil {
+			continue
+		}
+		cues := ParseVTT(file)
+		file.Close()
+
+		if len(cues) > 0 {
+			transcripts = append(transcripts, Transcript{
+				Language: subtitle.Language,
+				Source:   db.TranscriptSourceSubtitles,
+				Cues:     cues,
+			})
+		}
+	}
+	return transcripts
+}
+
+// ParseVTT reads the cues of a WebVTT file. Markup is removed from the text
+// and the lines of a cue are joined. Automatic captions roll each line up
+// into the next cue, so a line repeating the one before it is left out, and
+// cues left without text are dropped.
+func ParseVTT(r io.Reader) []Cue {
+	var cues []Cue
+	var cue *Cue
+	var lastLine string
+	skipBlock := true // the header block up to the first blank line
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			if cue != nil && cue.Text != "" {
+				cues = append(cues, *cue)
+			}
+			cue = nil
+			skipBlock = false
+		case skipBlock:
+		case strings.Contains(line, "-->"):
+			start, end, err := parseCueTiming(line)
+			if err != nil {
+				skipBlock = true
+				continue
+			}
+			cue = &Cue{Start: start, End: end}
+		case cue == nil:
+			// A cue identifier, or a NOTE, STYLE or REGION block
+			if line == "NOTE" || strings.HasPrefix(line, "NOTE ") || line == "STYLE" || line == "REGION" {
+				skipBlock = true
+			}
+		default:
+			text := cleanCueText(cueTagPattern.ReplaceAllString(line, ""))
+			if text == "" || text == lastLine {
+				continue
+			}
+			lastLine = text
+			if cue.Text != "" {
+				cue.Text += " "
+			}
+			cue.Text += text
+		}
+	}
+	if cue != nil && cue.Text != "" {
+		cues = append(cues, *cue)
+	}
+	return cues
+}
+
+// parseCueTiming parses a WebVTT timing line such as
+// "00:01:02.500 --> 00:01:04.000 align:start position:0%"
+func parseCueTiming(line string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	start, err := parseTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("missing cue end time")
+	}
+	end, err := parseTimestamp(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseTimestamp parses a WebVTT timestamp, hh:mm:ss.ttt or mm:ss.ttt
+func parseTimestamp(value string) (time.Duration, error) {
+	parts := strings.Split(strings.Replace(value, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	var total time.Duration
+	for i, part := range parts {
+		unit := time.Minute
+		if len(parts) == 3 && i == 0 {
+			unit = time.Hour
+		}
+		if i == len(parts)-1 {
+			seconds, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid timestamp %q", value)
+			}
+			total += time.Duration(math.Round(seconds*1000)) * time.Millisecond
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		total += time.Duration(n) * unit
+	}
+	return total, nil
+}
+
+// srv3Document is YouTube's timed text format 3
+type srv3Document struct {
+	Paragraphs []srv3Paragraph `xml:"body>p"`
+}
+
+// srv3Paragraph is a caption shown from T for D milliseconds. Automatic
+// captions split the text into timed word segments.
+type srv3Paragraph struct {
+	T        int64         `xml:"t,attr"`
+	D        int64         `xml:"d,attr"`
+	Text     string        `xml:",chardata"`
+	Segments []srv3Segment `xml:"s"`
+}
+
+// srv3Segment is a word or phrase of a caption
+type srv3Segment struct {
+	Text string `xml:",chardata"`
+}
+
+// ParseSRV3 reads the cues of a caption track in YouTube's srv3 XML format,
+// the format fetched when a video has no subtitle files.
+func ParseSRV3(r io.Reader) ([]Cue, error) {
+	var document srv3Document
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to parse srv3 captions: %w", err)
+	}
+
+	var cues []Cue
+	for _, p := range document.Paragraphs {
+		text := p.Text
+		if len(p.Segments) > 0 {
+			var words strings.Builder
+			for _, segment := range p.Segments {
+				words.WriteString(segment.Text)
+			}
+			text = words.String()
+		}
+		text = cleanCueText(text)
+		if text == "" {
+			continue
+		}
+
+		start := time.Duration(p.T) * time.Millisecond
+		cues = append(cues, Cue{
+			Start: start,
+			End:   start + time.Duration(p.D)*time.Millisecond,
+			Text:  text,
+		})
+	}
+	return cues, nil
+}
+
+// cleanCueText decodes entities and collapses the whitespace of caption text
+func cleanCueText(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// transcriptText returns the text of transcripts, one cue per line
+func transcriptText(transcripts []Transcript) string {
+	var lines []string
+	for _, transcript := range transcripts {
+		for _, cue := range transcript.Cues {
+			lines = append(lines, cue.Text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,120 @@
+// Package sponsorblock reads skip segments from a local SponsorBlock-compatible JSON database
+package sponsorblock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Action types a segment can have
+const (
+	ActionSkip    = "skip"
+	ActionMute    = "mute"
+	ActionPOI     = "poi"
+	ActionChapter = "chapter"
+	ActionFull    = "full"
+)
+
+// DefaultCategories are the segment categories used when none are configured
+var DefaultCategories = []string{"sponsor"}
+
+// Segment is a span of a video submitted to SponsorBlock. Times are in seconds.
+type Segment struct {
+	Segment    [2]float64 `json:"segment"`
+	Category   string     `json:"category"`
+	ActionType string     `json:"actionType,omitempty"`
+	UUID       string     `json:"UUID,omitempty"`
+}
+
+// Start returns the segment's start time in seconds
+func (s Segment) Start() float64 {
+	return s.Segment[0]
+}
+
+// End returns the segment's end time in seconds
+func (s Segment) End() float64 {
+	return s.Segment[1]
+}
+
+// videoSegments is one entry of the SponsorBlock skipSegments response
+type videoSegments struct {
+	VideoID  string    `json:"videoID"`
+	Segments []Segment `json:"segments"`
+}
+
+// DB is an in-memory index of segments by video ID
+type DB struct {
+	videos map[string][]Segment
+}
+
+// Load reads a SponsorBlock database from a JSON file. Two layouts are accepted:
+// the API's skipSegments response, an array of {"videoID", "segments"} objects,
+// or an object mapping video IDs to their segments.
+func Load(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sponsorblock database: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a SponsorBlock database; see Load for the accepted layouts
+func Parse(data []byte) (*DB, error) {
+	db := &DB{videos: make(map[string][]Segment)}
+
+	var list []videoSegments
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, v := range list {
+			db.videos[v.VideoID] = append(db.videos[v.VideoID], v.Segments...)
+		}
+		return db, nil
+	}
+
+	var byVideo map[string][]Segment
+	if err := json.Unmarshal(data, &byVideo); err != nil {
+		return nil, fmt.Errorf("failed to parse sponsorblock database: %w", err)
+	}
+	for videoID, segments := range byVideo {
+		db.videos[videoID] = segments
+	}
+	return db, nil
+}
+
+// Segments returns the skippable segments of a video in the given categories,
+// ordered by start time. Segments that only mark a point or label the whole
+// video are left out, as are malformed ones.
+func (db *DB) Segments(videoID string, categories []string) []Segment {
+	if db == nil {
+		return nil
+	}
+	if len(categories) == 0 {
+		categories = DefaultCategories
+	}
+
+	wanted := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		wanted[category] = true
+	}
+
+	var segments []Segment
+	for _, segment := range db.videos[videoID] {
+		if !wanted[segment.Category] {
+			continue
+		}
+		switch segment.ActionType {
+		case ActionPOI, ActionFull, ActionChapter:
+			continue
+		}
+		if segment.Start() < 0 || segment.End() <= segment.Start() {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start() < segments[j].Start()
+	})
+	return segments
+}
@@ -0,0 +1,91 @@
+package sponsorblock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "skipSegments response",
+			input: `[
+				{"videoID": "abc", "segments": [
+					{"segment": [120, 150], "category": "sponsor", "actionType": "skip", "UUID": "2"},
+					{"segment": [10, 20.5], "category": "sponsor", "actionType": "skip", "UUID": "1"},
+					{"segment": [30, 40], "category": "intro", "actionType": "skip", "UUID": "3"},
+					{"segment": [60, 60], "category": "poi_highlight", "actionType": "poi", "UUID": "4"}
+				]},
+				{"videoID": "other", "segments": [{"segment": [0, 5], "category": "sponsor"}]}
+			]`,
+		},
+		{
+			name: "map of video IDs",
+			input: `{
+				"abc": [
+					{"segment": [120, 150], "category": "sponsor", "UUID": "2"},
+					{"segment": [10, 20.5], "category": "sponsor", "UUID": "1"},
+					{"segment": [30, 40], "category": "intro", "UUID": "3"},
+					{"segment": [90, 80], "category": "sponsor", "UUID": "bad"}
+				],
+				"other": [{"segment": [0, 5], "category": "sponsor"}]
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			segments := db.Segments("abc", nil)
+			if len(segments) != 2 {
+				t.Fatalf("Segments() returned %d segments, want 2: %+v", len(segments), segments)
+			}
+			if segments[0].UUID != "1" || segments[1].UUID != "2" {
+				t.Errorf("Segments() not ordered by start time: %+v", segments)
+			}
+			if segments[0].End() != 20.5 {
+				t.Errorf("End() = %v, want 20.5", segments[0].End())
+			}
+
+			if got := db.Segments("abc", []string{"sponsor", "intro"}); len(got) != 3 {
+				t.Errorf("Segments() with intro returned %d segments, want 3", len(got))
+			}
+			if got := db.Segments("missing", nil); got != nil {
+				t.Errorf("Segments() for unknown video = %+v, want nil", got)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "segments.json")
+	if err := os.WriteFile(path, []byte(`{"abc": [{"segment": [1, 2], "category": "sponsor"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := db.Segments("abc", nil); len(got) != 1 {
+		t.Errorf("Segments() returned %d segments, want 1", len(got))
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load() of a missing file expected error")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(bad, []byte(`"not a database"`), 0644)
+	if _, err := Load(bad); err == nil {
+		t.Error("Load() of an invalid file expected error")
+	}
+}
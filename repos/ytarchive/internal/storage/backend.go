@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Errors returned by storage backends
+var (
+	// ErrNotExist is returned for objects that do not exist
+	ErrNotExist = errors.New("object does not exist")
+
+	// ErrPresignUnsupported is returned by backends that cannot create presigned URLs
+	ErrPresignUnsupported = errors.New("storage backend does not support presigned URLs")
+)
+
+// Backend stores the files of the archive as objects. Keys are slash separated
+// paths relative to the archive root, using the same layout as Manager, for
+// example channels/{channel_id}/videos/{video_id}/video.mp4.
+type Backend interface {
+	// Put stores an object, replacing any existing object with the same key.
+	// size is the length of r, or -1 if it is not known in advance.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Open reads length bytes of an object starting at offset. A negative
+	// length reads to the end of the object.
+	Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Stat returns the size and modification time of an object
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List calls fn for every object whose key starts with prefix
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+
+	// PresignGet returns a URL that downloads an object without credentials
+	// until expiry has passed
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+
+	// Location returns where an object is stored, for logs and database records
+	Location(key string) string
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Name returns the last element of the object's key
+func (o ObjectInfo) Name() string {
+	return path.Base(o.Key)
+}
+
+// VideoKey returns the key of a file in a video's directory
+func VideoKey(channelID, videoID, name string) string {
+	return path.Join("channels", channelID, "videos", videoID, name)
+}
+
+// cleanKey validates an object key and returns it in canonical form
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return cleaned, nil
+}
+
+// OpenBackend opens the backend at location, which is either a local
+// directory or an s3://bucket/prefix URL. S3 buckets are accessed with the
+// endpoint and credentials of s3Config.
+func OpenBackend(location string, s3Config S3Config) (Backend, error) {
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("missing bucket in storage location %q", location)
+		}
+		s3Config.Bucket = bucket
+		s3Config.Prefix = prefix
+		return NewS3Backend(s3Config)
+	}
+	if location == "" {
+		return nil, fmt.Errorf("storage location is empty")
+	}
+	return NewLocalBackend(location), nil
+}
+
+// BackendFromEnv opens the backend configured by STORAGE_URL, falling back to
+// the local directory localPath
+func BackendFromEnv(localPath string) (Backend, error) {
+	location := os.Getenv("STORAGE_URL")
+	if location == "" {
+		location = localPath
+	}
+	return OpenBackend(location, S3ConfigFromEnv())
+}
+
+// objectReader reads an object through a backend, opening a new range read
+// whenever it seeks. It lets http.ServeContent serve range requests from any
+// backend.
+type objectReader struct {
+	ctx     context.Context
+	backend Backend
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// NewReadSeeker returns a reader over an object of the given size that
+// fetches the requested ranges from the backend on demand
+func NewReadSeeker(ctx context.Context, backend Backend, key string, size int64) io.ReadSeekCloser {
+	return &objectReader{ctx: ctx, backend: backend, key: key, size: size}
+}
+
+// Read reads from the current offset, opening the object there if needed
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.backend.Open(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek moves the offset, dropping the open read if the offset changes
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = r.offset + offset
+	case io.SeekEnd:
+		next = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if next < 0 {
+		return 0, fmt.Errorf("negative offset %d", next)
+	}
+
+	if next != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = next
+	return next, nil
+}
+
+// Close closes the open read, if any
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
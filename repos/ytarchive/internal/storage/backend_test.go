@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "channels/c1/videos/v1/video.mp4", want: "channels/c1/videos/v1/video.mp4"},
+		{key: "/channels/c1/channel.json", want: "channels/c1/channel.json"},
+		{key: "channels/../../etc/passwd", wantErr: true},
+		{key: "channels/c1/./video.mp4", wantErr: true},
+		{key: "channels/c1/", wantErr: true},
+		{key: `channels\c1`, wantErr: true},
+		{key: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := cleanKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cleanKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("cleanKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBackend(t *testing.T) {
+	backend, err := OpenBackend("/data", S3Config{})
+	if err != nil {
+		t.Fatalf("OpenBackend(/data) error = %v", err)
+	}
+	if _, ok := backend.(*LocalBackend); !ok {
+		t.Errorf("OpenBackend(/data) = %T, want *LocalBackend", backend)
+	}
+
+	backend, err = OpenBackend("s3://archive/ytarchive", S3Config{Endpoint: "http://minio:9000", PathStyle: true})
+	if err != nil {
+		t.Fatalf("OpenBackend(s3://) error = %v", err)
+	}
+	if got := backend.Location("channels/c1/channel.json"); got != "s3://archive/ytarchive/channels/c1/channel.json" {
+		t.Errorf("Location() = %q", got)
+	}
+
+	if _, err := OpenBackend("s3:///prefix", S3Config{}); err == nil {
+		t.Error("OpenBackend() accepted a location without a bucket")
+	}
+}
+
+// testBackend runs the behaviour every backend must have
+func testBackend(t *testing.T, backend Backend) {
+	ctx := context.Background()
+	key := VideoKey("c1", "v1", "video.mp4")
+	data := []byte("0123456789abcdef")
+
+	if _, err := backend.Stat(ctx, key); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("Stat() of a missing object error = %v, want ErrNotExist", err)
+	}
+	if _, err := backend.Open(ctx, key, 0, -1); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("Open() of a missing object error = %v, want ErrNotExist", err)
+	}
+
+	if err := backend.Put(ctx, key, bytes.NewReader(data), -1); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := backend.Put(ctx, VideoKey("c1", "v1", "thumbnail.jpg"), strings.NewReader("jpg"), 3); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := backend.Put(ctx, VideoKey("c2", "v2", "video.mp4"), strings.NewReader("other"), 5); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	info, err := backend.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size != int64(len(data)) {
+		t.Errorf("Stat().Size = %d, want %d", info.Size, len(data))
+	}
+
+	for _, tt := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789abcdef"},
+		{10, -1, "abcdef"},
+		{2, 3, "234"},
+	} {
+		body, err := backend.Open(ctx, key, tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("Open(%d, %d) error = %v", tt.offset, tt.length, err)
+		}
+		got, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Open(%d, %d) read %q, %v, want %q", tt.offset, tt.length, got, err, tt.want)
+		}
+	}
+
+	var keys []string
+	err = backend.List(ctx, "channels/c1/", func(object ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{"channels/c1/videos/v1/thumbnail.jpg", "channels/c1/videos/v1/video.mp4"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("List() = %v, want %v", keys, want)
+	}
+
+	if err := backend.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := backend.Stat(ctx, key); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat() after Delete() error = %v, want ErrNotExist", err)
+	}
+	if err := backend.Delete(ctx, key); err != nil {
+		t.Errorf("Delete() of a missing object error = %v", err)
+	}
+}
+
+func TestLocalBackend(t *testing.T) {
+	root := t.TempDir()
+	backend := NewLocalBackend(root)
+	testBackend(t, backend)
+
+	if err := backend.Put(context.Background(), "../outside", strings.NewReader("x"), 1); err == nil {
+		t.Error("Put() accepted a key outside the root")
+	}
+	if err := backend.List(context.Background(), "channels/../../", func(ObjectInfo) error { return nil }); err == nil {
+		t.Error("List() accepted a prefix outside the root")
+	}
+	if err := backend.Put(context.Background(), "short", strings.NewReader("x"), 2); err == nil {
+		t.Error("Put() accepted a body shorter than its size")
+	}
+	if _, err := os.Stat(filepath.Join(root, "short")); !os.IsNotExist(err) {
+		t.Error("Put() left a file behind after failing")
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(root, "channels", "c1", "videos", "v1"))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			t.Errorf("Put() left temporary file %s behind", entry.Name())
+		}
+	}
+}
+
+func TestReadSeekerServesRanges(t *testing.T) {
+	ctx := context.Background()
+	backend := NewLocalBackend(t.TempDir())
+	key := VideoKey("c1", "v1", "video.mp4")
+	if err := backend.Put(ctx, key, strings.NewReader("0123456789"), 10); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := NewReadSeeker(r.Context(), backend, key, 10)
+		defer content.Close()
+		http.ServeContent(w, r, "video.mp4", time.Time{}, content)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "345" {
+		t.Errorf("range request = %d %q, want 206 \"345\"", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 3-5/10" {
+		t.Errorf("Content-Range = %q", got)
+	}
+}
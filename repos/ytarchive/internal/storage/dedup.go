@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// blobPrefix is where content-addressed blobs are stored
+const blobPrefix = "blobs/sha256/"
+
+// Linker is implemented by backends that can give an object a second key
+// without storing its content twice
+type Linker interface {
+	// Link makes newKey refer to the content of key, replacing any object at newKey
+	Link(ctx context.Context, key, newKey string) error
+}
+
+// BlobPruner is implemented by backends that can find blobs no longer
+// referenced by any other key
+type BlobPruner interface {
+	// PruneBlobs deletes unreferenced blobs and returns how many were deleted
+	PruneBlobs(ctx context.Context) (int, error)
+}
+
+// BlobKey returns the key of the content-addressed blob of a SHA-256 checksum
+func BlobKey(checksum string) string {
+	return blobPrefix + checksum[:2] + "/" + checksum
+}
+
+// IsBlobKey reports whether key is a content-addressed blob
+func IsBlobKey(key string) bool {
+	return strings.HasPrefix(key, blobPrefix)
+}
+
+// validChecksum reports whether s is a hex SHA-256 checksum
+func validChecksum(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Deduplicate stores the content of the object at key once per checksum. If
+// a blob with the same checksum and size exists, key is replaced by a link to
+// it; otherwise the object becomes the blob, for later copies to link to. It
+// returns whether key was linked to content that was already stored. Backends
+// that cannot link, such as S3Backend, are left untouched.
+func Deduplicate(ctx context.Context, backend Backend, key, checksum string, size int64) (bool, error) {
+	linker, ok := backend.(Linker)
+	if !ok {
+		return false, nil
+	}
+	if !validChecksum(checksum) {
+		return false, fmt.Errorf("invalid checksum %q", checksum)
+	}
+
+	blobKey := BlobKey(checksum)
+	blob, err := backend.Stat(ctx, blobKey)
+	switch {
+	case err == nil && blob.Size == size:
+		if err := linker.Link(ctx, blobKey, key); err != nil {
+			return false, fmt.Errorf("failed to link %s to blob: %w", key, err)
+		}
+		return true, nil
+	case err != nil && !errors.Is(err, ErrNotExist):
+		return false, err
+	}
+
+	// First copy of this content, or a blob that does not match its name
+	if err := linker.Link(ctx, key, blobKey); err != nil {
+		return false, fmt.Errorf("failed to store blob of %s: %w", key, err)
+	}
+	return false, nil
+}
+
+// HashingReader computes the SHA-256 checksum and length of everything read
+// through it, so a file can be hashed while it is streamed to storage
+type HashingReader struct {
+	r    io.Reader
+	hash hash.Hash
+	n    int64
+}
+
+// NewHashingReader wraps r
+func NewHashingReader(r io.Reader) *HashingReader {
+	return &HashingReader{r: r, hash: sha256.New()}
+}
+
+// Read reads from the underlying reader and hashes the bytes read
+func (h *HashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	h.n += int64(n)
+	return n, err
+}
+
+// Checksum returns the hex SHA-256 of the bytes read so far
+func (h *HashingReader) Checksum() string {
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// Size returns the number of bytes read so far
+func (h *HashingReader) Size() int64 {
+	return h.n
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHashingReader(t *testing.T) {
+	data := "identical video content"
+	sum := sha256.Sum256([]byte(data))
+
+	r := NewHashingReader(strings.NewReader(data))
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if got, want := r.Checksum(), hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("Checksum() = %s, want %s", got, want)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Errorf("Size() = %d, want %d", r.Size(), len(data))
+	}
+}
+
+func TestBlobKey(t *testing.T) {
+	checksum := strings.Repeat("ab", sha256.Size)
+	key := BlobKey(checksum)
+	if key != "blobs/sha256/ab/"+checksum {
+		t.Errorf("BlobKey() = %q", key)
+	}
+	if !IsBlobKey(key) || IsBlobKey(VideoKey("c1", "v1", "video.mp4")) {
+		t.Error("IsBlobKey() does not tell blobs from videos")
+	}
+}
+
+// putHashed stores data at key the way the collector does
+func putHashed(t *testing.T, backend Backend, key, data string) (string, int64) {
+	t.Helper()
+	r := NewHashingReader(strings.NewReader(data))
+	if err := backend.Put(context.Background(), key, r, -1); err != nil {
+		t.Fatalf("Put(%s) error = %v", key, err)
+	}
+	return r.Checksum(), r.Size()
+}
+
+func TestDeduplicateLocalBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("link counts are not available on windows")
+	}
+	ctx := context.Background()
+	root := t.TempDir()
+	backend := NewLocalBackend(root)
+
+	first := VideoKey("c1", "v1", "video.mp4")
+	checksum, size := putHashed(t, backend, first, "same content")
+	linked, err := Deduplicate(ctx, backend, first, checksum, size)
+	if err != nil || linked {
+		t.Fatalf("Deduplicate() of the first copy = %v, %v, want false, nil", linked, err)
+	}
+
+	reupload := VideoKey("c2", "v9", "video.mp4")
+	putHashed(t, backend, reupload, "same content")
+	linked, err = Deduplicate(ctx, backend, reupload, checksum, size)
+	if err != nil || !linked {
+		t.Fatalf("Deduplicate() of a re-upload = %v, %v, want true, nil", linked, err)
+	}
+
+	firstInfo, _ := os.Stat(backend.Location(first))
+	reuploadInfo, _ := os.Stat(backend.Location(reupload))
+	if !os.SameFile(firstInfo, reuploadInfo) {
+		t.Error("re-upload is not stored as a link to the first copy")
+	}
+
+	other := VideoKey("c3", "v3", "video.mp4")
+	otherChecksum, otherSize := putHashed(t, backend, other, "different content")
+	if linked, err := Deduplicate(ctx, backend, other, otherChecksum, otherSize); err != nil || linked {
+		t.Errorf("Deduplicate() of different content = %v, %v, want false, nil", linked, err)
+	}
+
+	if _, err := Deduplicate(ctx, backend, other, "not-a-checksum", otherSize); err == nil {
+		t.Error("Deduplicate() accepted an invalid checksum")
+	}
+
+	// Blobs are kept while any video links to them
+	if pruned, err := backend.PruneBlobs(ctx); err != nil || pruned != 0 {
+		t.Fatalf("PruneBlobs() = %d, %v, want 0, nil", pruned, err)
+	}
+
+	backend.Delete(ctx, first)
+	backend.Delete(ctx, reupload)
+	pruned, err := backend.PruneBlobs(ctx)
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneBlobs() after deleting both copies = %d, %v, want 1, nil", pruned, err)
+	}
+	if _, err := backend.Stat(ctx, BlobKey(checksum)); err == nil {
+		t.Error("PruneBlobs() kept an unreferenced blob")
+	}
+	if _, err := backend.Stat(ctx, BlobKey(otherChecksum)); err != nil {
+		t.Errorf("PruneBlobs() deleted a referenced blob: %v", err)
+	}
+}
+
+func TestDeduplicateWithoutLinker(t *testing.T) {
+	backend := newTestS3Backend(t, newFakeS3("archive"), "")
+
+	key := VideoKey("c1", "v1", "video.mp4")
+	checksum, size := putHashed(t, backend, key, "content")
+	if linked, err := Deduplicate(context.Background(), backend, key, checksum, size); err != nil || linked {
+		t.Errorf("Deduplicate() on S3 = %v, %v, want false, nil", linked, err)
+	}
+}
+
+func TestFindDuplicatesIgnoresHardlinks(t *testing.T) {
+	root := t.TempDir()
+	manager := NewManager(root)
+
+	original := filepath.Join(root, "channels", "c1", "videos", "v1", "video.mp4")
+	linked := filepath.Join(root, "channels", "c2", "videos", "v2", "video.mp4")
+	copied := filepath.Join(root, "channels", "c3", "videos", "v3", "video.mp4")
+	for _, p := range []string{original, linked, copied} {
+		os.MkdirAll(filepath.Dir(p), 0755)
+	}
+	os.WriteFile(original, []byte("video"), 0644)
+	os.WriteFile(copied, []byte("video"), 0644)
+	if err := os.Link(original, linked); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	duplicates, err := NewUsageAnalyzer(manager).FindDuplicates()
+	if err != nil {
+		t.Fatalf("FindDuplicates() error = %v", err)
+	}
+	if len(duplicates) != 1 || len(duplicates[0].Files) != 2 {
+		t.Fatalf("FindDuplicates() = %+v, want one set of two files", duplicates)
+	}
+	if duplicates[0].WastedSize != 5 {
+		t.Errorf("WastedSize = %d, want 5", duplicates[0].WastedSize)
+	}
+}
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempPrefix marks files that are still being written by a LocalBackend
+const tempPrefix = ".ytarchive-"
+
+// LocalBackend stores objects as files under a directory, such as the
+// Trident volume mounted at STORAGE_PATH
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates a backend that stores objects under root
+func NewLocalBackend(root string) *LocalBackend {
+	return &LocalBackend{root: root}
+}
+
+// Root returns the directory objects are stored under
+func (b *LocalBackend) Root() string {
+	return b.root
+}
+
+// path returns the file path of an object
+func (b *LocalBackend) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(b.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes an object to a temporary file and renames it into place, so
+// readers never see a partial file
+func (b *LocalBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	dest, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), tempPrefix+filepath.Base(dest)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r})
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if size >= 0 && written != size {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: wrote %d of %d bytes", key, written, size)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", key, err)
+	}
+	return nil
+}
+
+// Open opens a file and seeks to offset
+func (b *LocalBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotExist)
+		}
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+// Stat returns the size and modification time of a file
+func (b *LocalBackend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ObjectInfo{}, fmt.Errorf("%s: %w", key, ErrNotExist)
+		}
+		return ObjectInfo{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", key, ErrNotExist)
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes a file
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List walks the files under the directory of prefix in lexical order
+func (b *LocalBackend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	dir := b.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		prefixDir, err := cleanKey(prefix[:i])
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(b.root, filepath.FromSlash(prefixDir))
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == dir {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	return nil
+}
+
+// Link hardlinks newKey to the file of key, replacing newKey atomically
+func (b *LocalBackend) Link(ctx context.Context, key, newKey string) error {
+	src, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	dest, err := b.path(newKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Link under a temporary name first, as os.Link does not replace files
+	tmp := filepath.Join(filepath.Dir(dest), tempPrefix+filepath.Base(dest)+".link")
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%s: %w", key, ErrNotExist)
+		}
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// PruneBlobs deletes blobs that are no longer linked from any video, such as
+// the blobs of videos removed by a retention policy
+func (b *LocalBackend) PruneBlobs(ctx context.Context) (int, error) {
+	pruned := 0
+	err := b.List(ctx, blobPrefix, func(object ObjectInfo) error {
+		p, err := b.path(object.Key)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil
+		}
+		if linkCount(info) != 1 {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("failed to delete blob %s: %w", object.Key, err)
+		}
+		pruned++
+		return nil
+	})
+	return pruned, err
+}
+
+// PresignGet is not supported for local files
+func (b *LocalBackend) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+// Location returns the file path of an object
+func (b *LocalBackend) Location(key string) string {
+	return filepath.Join(b.root, filepath.FromSlash(key))
+}
+
+// limitedReadCloser reads part of a file and closes the file
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// contextReader stops reading once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -14,7 +14,9 @@ import (
 // DefaultStoragePath is the default path for the iSCSI PVC mount
 const DefaultStoragePath = "/data"
 
-// Manager handles all storage operations for the YouTube archiver
+// Manager handles all storage operations for the YouTube archiver on a local
+// directory. Archived files that may be in an object store are accessed
+// through a Backend instead.
 type Manager struct {
 	basePath string
 	mu       sync.RWMutex
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// MigrateOptions controls a migration between backends
+type MigrateOptions struct {
+	// Prefix limits the migration to keys starting with it
+	Prefix string
+	// Skip excludes objects from the migration
+	Skip func(key string) bool
+	// DryRun lists the objects that would be copied without copying them
+	DryRun bool
+	// DeleteSource removes each object from the source once it is in the destination
+	DeleteSource bool
+}
+
+// MigrateResult counts the objects handled by a migration
+type MigrateResult struct {
+	Copied  int   `json:"copied"`
+	Skipped int   `json:"skipped"` // already in the destination
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Migrate copies the objects of src to dst. Objects that are already in dst
+// with the same size are skipped, so an interrupted migration can be run again.
+// Failed objects are logged and counted, and the migration continues.
+func Migrate(ctx context.Context, src, dst Backend, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+
+	err := src.List(ctx, opts.Prefix, func(object ObjectInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.Skip != nil && opts.Skip(object.Key) {
+			return nil
+		}
+
+		existing, err := dst.Stat(ctx, object.Key)
+		switch {
+		case err == nil && existing.Size == object.Size:
+			result.Skipped++
+			if opts.DeleteSource && !opts.DryRun {
+				deleteSource(ctx, src, object.Key)
+			}
+			return nil
+		case err != nil && !errors.Is(err, ErrNotExist):
+			logging.Warn("failed to check destination object", "key", object.Key, "error", err)
+			result.Failed++
+			return nil
+		}
+
+		if opts.DryRun {
+			logging.Info("would copy object", "key", object.Key, "size", object.Size)
+			result.Copied++
+			result.Bytes += object.Size
+			return nil
+		}
+
+		if err := copyObject(ctx, src, dst, object); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logging.Warn("failed to copy object", "key", object.Key, "error", err)
+			result.Failed++
+			return nil
+		}
+		result.Copied++
+		result.Bytes += object.Size
+
+		if opts.DeleteSource {
+			deleteSource(ctx, src, object.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// copyObject copies one object and checks the size of the copy
+func copyObject(ctx context.Context, src, dst Backend, object ObjectInfo) error {
+	body, err := src.Open(ctx, object.Key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := dst.Put(ctx, object.Key, body, object.Size); err != nil {
+		return err
+	}
+
+	copied, err := dst.Stat(ctx, object.Key)
+	if err != nil {
+		return fmt.Errorf("failed to verify copy: %w", err)
+	}
+	if copied.Size != object.Size {
+		return fmt.Errorf("copy has %d bytes, want %d", copied.Size, object.Size)
+	}
+	return nil
+}
+
+// deleteSource removes a migrated object from the source
+func deleteSource(ctx context.Context, src Backend, key string) {
+	if err := src.Delete(ctx, key); err != nil {
+		logging.Warn("failed to delete migrated object from source", "key", key, "error", err)
+	}
+}
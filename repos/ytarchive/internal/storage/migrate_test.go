@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src := NewLocalBackend(t.TempDir())
+	fake := newFakeS3("archive")
+	dst := newTestS3Backend(t, fake, "")
+
+	files := map[string]string{
+		VideoKey("c1", "v1", "video.mp4"):     "video one",
+		VideoKey("c1", "v1", "metadata.json"): "{}",
+		VideoKey("c1", "v2", "video.mp4"):     "video two",
+		"channels/c1/metadata.db":             "sqlite",
+	}
+	for key, data := range files {
+		if err := src.Put(ctx, key, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	// Already migrated by an earlier, interrupted run
+	fake.objects[VideoKey("c1", "v2", "video.mp4")] = []byte("video two")
+
+	skipDB := func(key string) bool { return strings.HasSuffix(key, ".db") }
+
+	result, err := Migrate(ctx, src, dst, MigrateOptions{Prefix: "channels/", Skip: skipDB, DryRun: true})
+	if err != nil {
+		t.Fatalf("Migrate(dry run) error = %v", err)
+	}
+	if result.Copied != 2 || result.Skipped != 1 || len(fake.objects) != 1 {
+		t.Errorf("Migrate(dry run) = %+v with %d objects, want 2 to copy, 1 skipped and nothing copied", result, len(fake.objects))
+	}
+
+	result, err = Migrate(ctx, src, dst, MigrateOptions{Prefix: "channels/", Skip: skipDB, DeleteSource: true})
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	want := MigrateResult{Copied: 2, Skipped: 1, Bytes: int64(len("video one") + len("{}"))}
+	if result != want {
+		t.Errorf("Migrate() = %+v, want %+v", result, want)
+	}
+
+	for key, data := range files {
+		_, err := src.Stat(ctx, key)
+		if key == "channels/c1/metadata.db" {
+			if err != nil {
+				t.Errorf("skipped object %s was deleted from the source", key)
+			}
+			if _, ok := fake.objects[key]; ok {
+				t.Errorf("skipped object %s was copied", key)
+			}
+			continue
+		}
+		if string(fake.objects[key]) != data {
+			t.Errorf("destination %s = %q, want %q", key, fake.objects[key], data)
+		}
+		if !errors.Is(err, ErrNotExist) {
+			t.Errorf("migrated object %s is still in the source", key)
+		}
+	}
+}
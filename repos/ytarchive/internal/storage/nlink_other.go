@@ -0,0 +1,10 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// linkCount returns 0, as hardlink counts are not available on this platform
+func linkCount(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reasons a video is selected for pruning
+const (
+	PruneReasonKeepLast = "keep_last"
+	PruneReasonKeepDays = "keep_days"
+	PruneReasonMaxBytes = "max_bytes"
+)
+
+// RetentionPolicy limits how much of a channel is kept on disk.
+// A zero field means no limit of that kind; a video is kept only if every set limit keeps it.
+type RetentionPolicy struct {
+	KeepLast int   `json:"keep_last,omitempty"` // keep the newest N downloaded videos
+	KeepDays int   `json:"keep_days,omitempty"` // keep videos uploaded in the last N days
+	MaxBytes int64 `json:"max_bytes,omitempty"` // keep the newest videos that fit in N bytes
+}
+
+// IsZero reports whether the policy keeps everything
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast == 0 && p.KeepDays == 0 && p.MaxBytes == 0
+}
+
+// Validate checks that no limit is negative
+func (p RetentionPolicy) Validate() error {
+	if p.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative")
+	}
+	if p.KeepDays < 0 {
+		return fmt.Errorf("keep_days must not be negative")
+	}
+	if p.MaxBytes < 0 {
+		return fmt.Errorf("max_bytes must not be negative")
+	}
+	return nil
+}
+
+// RetentionVideo is a downloaded video as seen by a retention policy
+type RetentionVideo struct {
+	VideoID       string    `json:"video_id"`
+	Title         string    `json:"title,omitempty"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	EpisodeNumber int       `json:"episode_number,omitempty"`
+	FileSize      int64     `json:"file_size"`
+	Reason        string    `json:"reason,omitempty"` // why the video is pruned
+}
+
+// SelectForPruning returns the videos the policy would remove, newest first.
+// Videos are ranked by upload date, then by episode number; the newest videos
+// are kept until a limit is reached.
+func (p RetentionPolicy) SelectForPruning(videos []RetentionVideo, now time.Time) []RetentionVideo {
+	if p.IsZero() || len(videos) == 0 {
+		return nil
+	}
+
+	ranked := make([]RetentionVideo, len(videos))
+	copy(ranked, videos)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if !ranked[i].UploadedAt.Equal(ranked[j].UploadedAt) {
+			return ranked[i].UploadedAt.After(ranked[j].UploadedAt)
+		}
+		return ranked[i].EpisodeNumber > ranked[j].EpisodeNumber
+	})
+
+	cutoff := now.AddDate(0, 0, -p.KeepDays)
+
+	var pruned []RetentionVideo
+	var kept int
+	var keptBytes int64
+	for _, video := range ranked {
+		switch {
+		case p.KeepLast > 0 && kept >= p.KeepLast:
+			video.Reason = PruneReasonKeepLast
+		case p.KeepDays > 0 && video.UploadedAt.Before(cutoff):
+			video.Reason = PruneReasonKeepDays
+		case p.MaxBytes > 0 && keptBytes+video.FileSize > p.MaxBytes:
+			video.Reason = PruneReasonMaxBytes
+		default:
+			kept++
+			keptBytes += video.FileSize
+			continue
+		}
+		pruned = append(pruned, video)
+	}
+
+	return pruned
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicy_SelectForPruning(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	videos := []RetentionVideo{
+		{VideoID: "oldest", UploadedAt: now.Add(-90 * day), FileSize: 400},
+		{VideoID: "newest", UploadedAt: now.Add(-1 * day), FileSize: 100},
+		{VideoID: "old", UploadedAt: now.Add(-40 * day), FileSize: 300},
+		{VideoID: "recent", UploadedAt: now.Add(-10 * day), FileSize: 200},
+	}
+
+	tests := []struct {
+		name    string
+		policy  RetentionPolicy
+		want    []string
+		reasons []string
+	}{
+		{
+			name:   "no limits",
+			policy: RetentionPolicy{},
+		},
+		{
+			name:    "keep last",
+			policy:  RetentionPolicy{KeepLast: 2},
+			want:    []string{"old", "oldest"},
+			reasons: []string{PruneReasonKeepLast, PruneReasonKeepLast},
+		},
+		{
+			name:    "keep days",
+			policy:  RetentionPolicy{KeepDays: 30},
+			want:    []string{"old", "oldest"},
+			reasons: []string{PruneReasonKeepDays, PruneReasonKeepDays},
+		},
+		{
+			name:    "max bytes keeps the newest that fit",
+			policy:  RetentionPolicy{MaxBytes: 350},
+			want:    []string{"old", "oldest"},
+			reasons: []string{PruneReasonMaxBytes, PruneReasonMaxBytes},
+		},
+		{
+			name:    "max bytes exactly at the limit",
+			policy:  RetentionPolicy{MaxBytes: 600},
+			want:    []string{"oldest"},
+			reasons: []string{PruneReasonMaxBytes},
+		},
+		{
+			name:    "strictest limit wins",
+			policy:  RetentionPolicy{KeepLast: 3, KeepDays: 60, MaxBytes: 250},
+			want:    []string{"recent", "old", "oldest"},
+			reasons: []string{PruneReasonMaxBytes, PruneReasonMaxBytes, PruneReasonKeepDays},
+		},
+		{
+			name:   "limits larger than the channel",
+			policy: RetentionPolicy{KeepLast: 10, KeepDays: 365, MaxBytes: 1 << 30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruned := tt.policy.SelectForPruning(videos, now)
+
+			var got, reasons []string
+			for _, v := range pruned {
+				got = append(got, v.VideoID)
+				reasons = append(reasons, v.Reason)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectForPruning() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("SelectForPruning() reasons = %v, want %v", reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicy_SelectForPruning_SameDayUsesEpisodeNumber(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	uploaded := now.Add(-24 * time.Hour)
+
+	videos := []RetentionVideo{
+		{VideoID: "ep1", UploadedAt: uploaded, EpisodeNumber: 1},
+		{VideoID: "ep3", UploadedAt: uploaded, EpisodeNumber: 3},
+		{VideoID: "ep2", UploadedAt: uploaded, EpisodeNumber: 2},
+	}
+
+	pruned := RetentionPolicy{KeepLast: 1}.SelectForPruning(videos, now)
+	if len(pruned) != 2 || pruned[0].VideoID != "ep2" || pruned[1].VideoID != "ep1" {
+		t.Errorf("SelectForPruning() = %+v, want ep2 and ep1", pruned)
+	}
+
+	// The input must not be reordered
+	if videos[0].VideoID != "ep1" {
+		t.Errorf("SelectForPruning() modified its input")
+	}
+}
+
+func TestRetentionPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetentionPolicy
+		wantErr bool
+	}{
+		{"zero", RetentionPolicy{}, false},
+		{"all set", RetentionPolicy{KeepLast: 5, KeepDays: 30, MaxBytes: 1 << 40}, false},
+		{"negative keep last", RetentionPolicy{KeepLast: -1}, true},
+		{"negative keep days", RetentionPolicy{KeepDays: -1}, true},
+		{"negative max bytes", RetentionPolicy{MaxBytes: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,578 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPartSize is the size of the parts of multipart uploads
+	DefaultPartSize = 16 * 1024 * 1024
+
+	// minPartSize is the smallest part S3 accepts, except for the last part
+	minPartSize = 5 * 1024 * 1024
+
+	// maxParts is the largest number of parts of a multipart upload
+	maxParts = 10000
+
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// unsignedPayload is the payload hash of presigned requests
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// s3TimeFormat is the time format of SigV4 signatures
+	s3TimeFormat = "20060102T150405Z"
+)
+
+// S3Config configures an S3-compatible object store such as AWS S3 or MinIO
+type S3Config struct {
+	// Endpoint is the URL of the object store; empty means AWS S3 in Region
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to all keys, to share a bucket with other data
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses buckets as {endpoint}/{bucket} instead of
+	// {bucket}.{endpoint}, as MinIO expects
+	PathStyle bool
+	PartSize  int64
+}
+
+// S3ConfigFromEnv loads the S3 endpoint and credentials from the environment
+func S3ConfigFromEnv() S3Config {
+	config := S3Config{
+		Endpoint:        os.Getenv("S3_ENDPOINT"),
+		Region:          os.Getenv("S3_REGION"),
+		AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		// Self-hosted stores rarely have wildcard DNS for bucket subdomains
+		PathStyle: os.Getenv("S3_ENDPOINT") != "",
+	}
+	if value := os.Getenv("S3_PATH_STYLE"); value != "" {
+		config.PathStyle, _ = strconv.ParseBool(value)
+	}
+	if value := os.Getenv("S3_PART_SIZE_MB"); value != "" {
+		if mb, err := strconv.ParseInt(value, 10, 64); err == nil && mb > 0 {
+			config.PartSize = mb * 1024 * 1024
+		}
+	}
+	return config
+}
+
+// S3Backend stores objects in a bucket of an S3-compatible object store. It
+// signs requests with AWS Signature Version 4 and uploads large objects in
+// parts, so objects of unknown size can be streamed without buffering them.
+type S3Backend struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Backend creates a backend for a bucket
+func NewS3Backend(config S3Config) (*S3Backend, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is not configured")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	if config.PartSize == 0 {
+		config.PartSize = DefaultPartSize
+	}
+	if config.PartSize < minPartSize {
+		return nil, fmt.Errorf("S3 part size must be at least %d bytes", minPartSize)
+	}
+	if config.Prefix != "" && !strings.HasSuffix(config.Prefix, "/") {
+		config.Prefix += "/"
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+
+	return &S3Backend{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of an object, or of the bucket if key is empty
+func (b *S3Backend) objectURL(key string, query url.Values) *url.URL {
+	u := *b.endpoint
+	objectPath := strings.TrimSuffix(u.Path, "/")
+	if b.config.PathStyle {
+		objectPath += "/" + b.config.Bucket
+	} else {
+		u.Host = b.config.Bucket + "." + u.Host
+	}
+	objectPath += "/" + key
+
+	u.Path = objectPath
+	u.RawPath = uriEncode(objectPath, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// objectKey returns the bucket key of a backend key
+func (b *S3Backend) objectKey(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return b.config.Prefix + key, nil
+}
+
+// do signs and sends a request and checks its status code
+func (b *S3Backend) do(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	b.sign(req, payloadHash)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s request failed: %w", method, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, parseS3Error(resp)
+	}
+	return resp, nil
+}
+
+// Put uploads an object in a single request if it fits in one part and as a
+// multipart upload otherwise
+func (b *S3Backend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return err
+	}
+
+	partSize := b.config.PartSize
+	if size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+
+	// Objects of known size that fit in one part only need a buffer of their size
+	bufSize := partSize
+	if size >= 0 && size < partSize {
+		bufSize = size + 1
+	}
+	buf := make([]byte, bufSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		resp, err := b.do(ctx, http.MethodPut, b.objectURL(objectKey, nil), nil, buf[:n])
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return b.putMultipart(ctx, key, objectKey, r, buf)
+}
+
+// completedPart is a part of a multipart upload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads an object in parts, starting with the full part in buf.
+// The upload is aborted if any part fails, so no incomplete parts are billed.
+func (b *S3Backend) putMultipart(ctx context.Context, key, objectKey string, r io.Reader, buf []byte) error {
+	resp, err := b.do(ctx, http.MethodPost, b.objectURL(objectKey, url.Values{"uploads": {""}}), nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload of %s: %w", key, err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("failed to start multipart upload of %s: invalid response", key)
+	}
+
+	abort := func(cause error) error {
+		// Use a fresh context, the upload's may be what was cancelled
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		query := url.Values{"uploadId": {initiated.UploadID}}
+		if resp, err := b.do(abortCtx, http.MethodDelete, b.objectURL(objectKey, query), nil, nil); err == nil {
+			resp.Body.Close()
+		}
+		return cause
+	}
+
+	var parts []completedPart
+	data := buf
+	for partNumber := 1; len(data) > 0; partNumber++ {
+		query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {initiated.UploadID}}
+		resp, err := b.do(ctx, http.MethodPut, b.objectURL(objectKey, query), nil, data)
+		if err != nil {
+			return abort(fmt.Errorf("failed to upload part %d of %s: %w", partNumber, key, err))
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return abort(fmt.Errorf("failed to read %s: %w", key, err))
+		}
+		data = buf[:n]
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return abort(err)
+	}
+	resp, err = b.do(ctx, http.MethodPost, b.objectURL(objectKey, url.Values{"uploadId": {initiated.UploadID}}), nil, body)
+	if err != nil {
+		return abort(fmt.Errorf("failed to complete multipart upload of %s: %w", key, err))
+	}
+	defer resp.Body.Close()
+
+	// S3 reports some failures with a 200 response and an error document
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return abort(fmt.Errorf("failed to complete multipart upload of %s: %w", key, err))
+	}
+	if s3Err := decodeS3Error(resp.StatusCode, result); s3Err != nil {
+		return abort(fmt.Errorf("failed to complete multipart upload of %s: %w", key, s3Err))
+	}
+	return nil
+}
+
+// Open reads an object with a ranged GET
+func (b *S3Backend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	if length >= 0 {
+		if length == 0 {
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := b.do(ctx, http.MethodGet, b.objectURL(objectKey, nil), header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// Stat returns the size and modification time of an object with a HEAD request
+func (b *S3Backend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	resp, err := b.do(ctx, http.MethodHead, b.objectURL(objectKey, nil), nil, nil)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return ObjectInfo{Key: key, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// Delete removes an object
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do(ctx, http.MethodDelete, b.objectURL(objectKey, nil), nil, nil)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List pages through the objects under prefix with ListObjectsV2
+func (b *S3Backend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {b.config.Prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := b.do(ctx, http.MethodGet, b.objectURL("", query), nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		var page struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse listing of %s: %w", prefix, err)
+		}
+
+		for _, object := range page.Contents {
+			info := ObjectInfo{
+				Key:     strings.TrimPrefix(object.Key, b.config.Prefix),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// PresignGet returns a presigned GET URL of an object
+func (b *S3Backend) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return "", err
+	}
+	return b.presign(http.MethodGet, objectKey, expiry), nil
+}
+
+// Location returns the s3:// URL of an object
+func (b *S3Backend) Location(key string) string {
+	return "s3://" + b.config.Bucket + "/" + b.config.Prefix + key
+}
+
+// sign adds a SigV4 Authorization header to a request
+func (b *S3Backend) sign(req *http.Request, payloadHash string) {
+	now := b.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Sign the host, the range and all x-amz-* headers
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope, signature := b.signature(now, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// presign returns a URL with a SigV4 signature in its query string
+func (b *S3Backend) presign(method, objectKey string, expiry time.Duration) string {
+	now := b.now().UTC()
+	scope := credentialScope(now, b.config.Region)
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {b.config.AccessKeyID + "/" + scope},
+		"X-Amz-Date":          {now.Format(s3TimeFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u := b.objectURL(objectKey, query)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	_, signature := b.signature(now, canonicalRequest)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+// signature returns the credential scope and the signature of a canonical request
+func (b *S3Backend) signature(now time.Time, canonicalRequest string) (string, string) {
+	scope := credentialScope(now, b.config.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(s3TimeFormat) + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+b.config.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, b.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// credentialScope returns the SigV4 credential scope of a day and region
+func credentialScope(now time.Time, region string) string {
+	return now.Format("20060102") + "/" + region + "/s3/aws4_request"
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes a string as SigV4 requires, leaving slashes
+// unencoded unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// S3Error is an error response of the object store
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+// Error implements the error interface
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("S3 returned status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap maps missing objects to ErrNotExist
+func (e *S3Error) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey" {
+		return ErrNotExist
+	}
+	return nil
+}
+
+// parseS3Error reads the error document of a failed response
+func parseS3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := decodeS3Error(resp.StatusCode, body); err != nil {
+		return err
+	}
+	return &S3Error{StatusCode: resp.StatusCode}
+}
+
+// decodeS3Error returns the error in a response body, or nil if the body is
+// not an error document
+func decodeS3Error(statusCode int, body []byte) error {
+	if !bytes.Contains(body, []byte("<Error")) {
+		return nil
+	}
+	s3Err := &S3Error{StatusCode: statusCode}
+	if err := xml.Unmarshal(body, s3Err); err != nil {
+		return &S3Error{StatusCode: statusCode}
+	}
+	return s3Err
+}
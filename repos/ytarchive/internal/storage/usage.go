@@ -240,6 +240,13 @@ func (ua *UsageAnalyzer) FindDuplicates() ([]DuplicateSet, error) {
 			return nil
 		}
 
+		// Hardlinks of deduplicated files share their content, so they waste nothing
+		for _, other := range sizeMap[info.Size()] {
+			if otherInfo, err := os.Stat(other); err == nil && os.SameFile(info, otherInfo) {
+				return nil
+			}
+		}
+
 		sizeMap[info.Size()] = append(sizeMap[info.Size()], path)
 		return nil
 	})
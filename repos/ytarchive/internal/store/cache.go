@@ -0,0 +1,417 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// Redis keys of the cached records, which the rest of the archive reads
+const (
+	channelKeyPrefix  = "channel:"
+	channelListKey    = "channels"
+	playlistKeyPrefix = "playlist:"
+	playlistListKey   = "playlists"
+	videoKeyPrefix    = "video:"
+	syncJobKeyPrefix  = "syncjob:"
+
+	// syncJobCacheTTL is how long sync jobs stay cached; they stay in the store
+	syncJobCacheTTL = 24 * time.Hour
+)
+
+// Records writes channels, playlists, videos and sync jobs to the Store and keeps their
+// copies in Redis current. Reads go to Redis first and fall back to the
+// Store, caching what they find again. Records without Redis, as in a
+// collector started without it, only use the Store.
+type Records struct {
+	store Store
+	redis *redis.Client
+}
+
+// NewRecords creates Records on a Store and an optional Redis cache
+func NewRecords(s Store, redisClient *redis.Client) *Records {
+	return &Records{store: s, redis: redisClient}
+}
+
+// Store returns the Store the records are kept in
+func (r *Records) Store() Store {
+	return r.store
+}
+
+// PutChannel saves a channel document
+func (r *Records) PutChannel(ctx context.Context, doc []byte) error {
+	channel, err := ParseChannel(doc)
+	if err != nil {
+		return err
+	}
+	if err := r.store.SaveChannel(ctx, channel); err != nil {
+		return err
+	}
+	r.cacheChannel(ctx, channel.ID, doc)
+	return nil
+}
+
+// Channel returns the document of a channel, or ErrNotFound
+func (r *Records) Channel(ctx context.Context, id string) ([]byte, error) {
+	if doc, ok := r.cached(ctx, channelKeyPrefix+id); ok {
+		return doc, nil
+	}
+	channel, err := r.store.GetChannel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	doc := channel.Document()
+	r.cacheChannel(ctx, id, doc)
+	return doc, nil
+}
+
+// ChannelIDs returns the IDs of all channels. They come from the Store, as
+// the cached channel list only holds what was cached since Redis started.
+func (r *Records) ChannelIDs(ctx context.Context) ([]string, error) {
+	channels, err := r.store.ListChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(channels))
+	for i := range channels {
+		ids[i] = channels[i].ID
+	}
+	return ids, nil
+}
+
+// UpdateChannel applies update to the stored document of a channel and saves
+// it. Concurrent updates of the channel run one after the other; the cached
+// copy is dropped, as they may cache out of order, and read again from the
+// Store.
+func (r *Records) UpdateChannel(ctx context.Context, id string, update func(map[string]interface{})) ([]byte, error) {
+	channel, err := r.store.UpdateChannel(ctx, id, func(channel *Channel) (*Channel, error) {
+		doc, err := updateDoc(channel.Document(), update)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update channel %s: %w", id, err)
+		}
+		return ParseChannel(doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.uncache(ctx, channelKeyPrefix+id)
+	return channel.Document(), nil
+}
+
+// DeleteChannel deletes a channel with its videos and sync history
+func (r *Records) DeleteChannel(ctx context.Context, id string) error {
+	if err := r.store.DeleteChannel(ctx, id); err != nil {
+		return err
+	}
+	if r.redis == nil {
+		return nil
+	}
+
+	pipe := r.redis.Pipeline()
+	pipe.Del(ctx, channelKeyPrefix+id)
+	pipe.SRem(ctx, channelListKey, id)
+	if keys, err := r.redis.Keys(ctx, videoKeyPrefix+id+":*").Result(); err == nil {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logging.Warn("failed to remove channel from cache", "channel_id", id, "error", err)
+	}
+	return nil
+}
+
+// PutPlaylist saves a playlist document
+func (r *Records) PutPlaylist(ctx context.Context, doc []byte) error {
+	playlist, err := ParsePlaylist(doc)
+	if err != nil {
+		return err
+	}
+	if err := r.store.SavePlaylist(ctx, playlist); err != nil {
+		return err
+	}
+	r.cachePlaylist(ctx, playlist.ID, doc)
+	return nil
+}
+
+// Playlist returns the document of a playlist, or ErrNotFound
+func (r *Records) Playlist(ctx context.Context, id string) ([]byte, error) {
+	if doc, ok := r.cached(ctx, playlistKeyPrefix+id); ok {
+		return doc, nil
+	}
+	playlist, err := r.store.GetPlaylist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	doc := playlist.Document()
+	r.cachePlaylist(ctx, id, doc)
+	return doc, nil
+}
+
+// PlaylistIDs returns the IDs of all playlists, from the Store as in ChannelIDs
+func (r *Records) PlaylistIDs(ctx context.Context) ([]string, error) {
+	playlists, err := r.store.ListPlaylists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(playlists))
+	for i := range playlists {
+		ids[i] = playlists[i].ID
+	}
+	return ids, nil
+}
+
+// UpdatePlaylist applies update to the stored document of a playlist and
+// saves it. The cached copy is dropped as in UpdateChannel.
+func (r *Records) UpdatePlaylist(ctx context.Context, id string, update func(map[string]interface{})) ([]byte, error) {
+	playlist, err := r.store.UpdatePlaylist(ctx, id, func(playlist *Playlist) (*Playlist, error) {
+		doc, err := updateDoc(playlist.Document(), update)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update playlist %s: %w", id, err)
+		}
+		return ParsePlaylist(doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.uncache(ctx, playlistKeyPrefix+id)
+	return playlist.Document(), nil
+}
+
+// DeletePlaylist deletes a playlist with its entries. The videos saved under
+// it are kept.
+func (r *Records) DeletePlaylist(ctx context.Context, id string) error {
+	if err := r.store.DeletePlaylist(ctx, id); err != nil {
+		return err
+	}
+	if r.redis == nil {
+		return nil
+	}
+
+	pipe := r.redis.Pipeline()
+	pipe.Del(ctx, playlistKeyPrefix+id)
+	pipe.SRem(ctx, playlistListKey, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logging.Warn("failed to remove playlist from cache", "playlist_id", id, "error", err)
+	}
+	return nil
+}
+
+// PutVideo saves the document of a video of channelID
+func (r *Records) PutVideo(ctx context.Context, channelID, videoID string, doc []byte) error {
+	video, err := ParseVideo(channelID, videoID, doc)
+	if err != nil {
+		return err
+	}
+	if err := r.store.SaveVideo(ctx, video); err != nil {
+		return err
+	}
+	r.cache(ctx, videoKeyPrefix+channelID+":"+videoID, doc, 0)
+	return nil
+}
+
+// Video returns the document of a video of channelID, or ErrNotFound
+func (r *Records) Video(ctx context.Context, channelID, videoID string) ([]byte, error) {
+	key := videoKeyPrefix + channelID + ":" + videoID
+	if doc, ok := r.cached(ctx, key); ok {
+		return doc, nil
+	}
+	video, err := r.store.GetVideo(ctx, channelID, videoID)
+	if err != nil {
+		return nil, err
+	}
+	doc := video.Document()
+	r.cache(ctx, key, doc, 0)
+	return doc, nil
+}
+
+// FindVideo returns the channel and document of a video of whichever channel
+// has it, or ErrNotFound
+func (r *Records) FindVideo(ctx context.Context, videoID string) (string, []byte, error) {
+	video, err := r.store.FindVideo(ctx, videoID)
+	if err != nil {
+		return "", nil, err
+	}
+	return video.ChannelID, video.Document(), nil
+}
+
+// UpdateVideo applies update to the stored document of a video and saves it.
+// Concurrent updates of the video run one after the other; the cached copy
+// is dropped as in UpdateChannel.
+func (r *Records) UpdateVideo(ctx context.Context, channelID, videoID string, update func(map[string]interface{})) ([]byte, error) {
+	video, err := r.store.UpdateVideo(ctx, channelID, videoID, func(video *Video) (*Video, error) {
+		doc, err := updateDoc(video.Document(), update)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update video %s: %w", videoID, err)
+		}
+		return ParseVideo(channelID, videoID, doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.uncache(ctx, videoKeyPrefix+channelID+":"+videoID)
+	return video.Document(), nil
+}
+
+// PutSyncJob saves a sync job document
+func (r *Records) PutSyncJob(ctx context.Context, doc []byte) error {
+	job, err := ParseSyncJob(doc)
+	if err != nil {
+		return err
+	}
+	if err := r.store.SaveSyncJob(ctx, job); err != nil {
+		return err
+	}
+	r.cache(ctx, syncJobKeyPrefix+job.ID, doc, syncJobCacheTTL)
+	return nil
+}
+
+// SyncJob returns the document of a sync job, or ErrNotFound
+func (r *Records) SyncJob(ctx context.Context, id string) ([]byte, error) {
+	if doc, ok := r.cached(ctx, syncJobKeyPrefix+id); ok {
+		return doc, nil
+	}
+	job, err := r.store.GetSyncJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	doc := job.Document()
+	r.cache(ctx, syncJobKeyPrefix+id, doc, syncJobCacheTTL)
+	return doc, nil
+}
+
+// Warm caches every channel, playlist and video of the Store in Redis again,
+// after Redis lost them or they were reconciled. It returns the records
+// cached.
+func (r *Records) Warm(ctx context.Context) (int, error) {
+	if r.redis == nil {
+		return 0, nil
+	}
+	channels, err := r.store.ListChannels(ctx)
+	if err != nil {
+		return 0, err
+	}
+	playlists, err := r.store.ListPlaylists(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var cached int
+	for _, channel := range channels {
+		n, err := r.warmOwner(ctx, channel.ID, channelKeyPrefix, channelListKey, channel.Document())
+		cached += n
+		if err != nil {
+			return cached, fmt.Errorf("failed to cache channel %s: %w", channel.ID, err)
+		}
+	}
+	// Videos of channels not followed are saved under the playlist
+	for _, playlist := range playlists {
+		n, err := r.warmOwner(ctx, playlist.ID, playlistKeyPrefix, playlistListKey, playlist.Document())
+		cached += n
+		if err != nil {
+			return cached, fmt.Errorf("failed to cache playlist %s: %w", playlist.ID, err)
+		}
+	}
+	return cached, nil
+}
+
+// warmOwner caches a channel or playlist, lists it and caches the videos
+// saved under it. It returns the records cached.
+func (r *Records) warmOwner(ctx context.Context, id, keyPrefix, listKey string, doc []byte) (int, error) {
+	videos, err := r.store.ListVideos(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := r.redis.Pipeline()
+	pipe.Set(ctx, keyPrefix+id, doc, 0)
+	pipe.SAdd(ctx, listKey, id)
+	for i := range videos {
+		pipe.Set(ctx, videoKeyPrefix+id+":"+videos[i].ID, videos[i].Document(), 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return 1 + len(videos), nil
+}
+
+// cached returns a record from Redis
+func (r *Records) cached(ctx context.Context, key string) ([]byte, bool) {
+	if r.redis == nil {
+		return nil, false
+	}
+	doc, err := r.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logging.Warn("failed to read record from cache", "key", key, "error", err)
+		}
+		return nil, false
+	}
+	return doc, true
+}
+
+// cacheChannel caches a channel and lists it
+func (r *Records) cacheChannel(ctx context.Context, id string, doc []byte) {
+	r.cacheListed(ctx, channelKeyPrefix, channelListKey, id, doc)
+}
+
+// cachePlaylist caches a playlist and lists it
+func (r *Records) cachePlaylist(ctx context.Context, id string, doc []byte) {
+	r.cacheListed(ctx, playlistKeyPrefix, playlistListKey, id, doc)
+}
+
+// cacheListed caches a record and adds its ID to a list
+func (r *Records) cacheListed(ctx context.Context, keyPrefix, listKey, id string, doc []byte) {
+	if r.redis == nil {
+		return
+	}
+	pipe := r.redis.Pipeline()
+	pipe.Set(ctx, keyPrefix+id, doc, 0)
+	pipe.SAdd(ctx, listKey, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		r.evict(ctx, keyPrefix+id, err)
+	}
+}
+
+// cache caches a record. The Store already has it, so a failure only evicts
+// the stale copy, which the next read takes from the Store again.
+func (r *Records) cache(ctx context.Context, key string, doc []byte, ttl time.Duration) {
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Set(ctx, key, doc, ttl).Err(); err != nil {
+		r.evict(ctx, key, err)
+	}
+}
+
+// uncache removes a record from Redis, so the next read takes it from the Store
+func (r *Records) uncache(ctx context.Context, key string) {
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Del(ctx, key).Err(); err != nil {
+		logging.Warn("failed to remove record from cache", "key", key, "error", err)
+	}
+}
+
+// evict removes a record that could not be cached
+func (r *Records) evict(ctx context.Context, key string, cacheErr error) {
+	logging.Warn("failed to cache record", "key", key, "error", cacheErr)
+	r.redis.Del(ctx, key)
+}
+
+// updateDoc applies update to a JSON document
+func updateDoc(doc []byte, update func(map[string]interface{})) ([]byte, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, err
+	}
+	update(m)
+	return json.Marshal(m)
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migration is a numbered change to the schema of the state database
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrationLock is the advisory lock that keeps components starting together
+// from migrating a PostgreSQL database at the same time
+const migrationLock = 0x79746172 // "ytar"
+
+// postgresMigrations starts from the schema the collector created, so an
+// existing collector database becomes the state database in place
+var postgresMigrations = []migration{
+	{1, "collector schema", `
+CREATE TABLE IF NOT EXISTS channels (
+    id VARCHAR(64) PRIMARY KEY,
+    youtube_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    video_count INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS videos (
+    id VARCHAR(64) PRIMARY KEY,
+    channel_id VARCHAR(64) NOT NULL REFERENCES channels(id),
+    title VARCHAR(500) NOT NULL,
+    description TEXT,
+    duration INTEGER,
+    upload_date VARCHAR(20),
+    episode_number INTEGER,
+    filename VARCHAR(500),
+    file_path VARCHAR(1000),
+    file_size BIGINT,
+    resolution VARCHAR(20),
+    format VARCHAR(20),
+    status VARCHAR(20) DEFAULT 'pending',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_videos_channel ON videos(channel_id);
+CREATE INDEX IF NOT EXISTS idx_videos_status ON videos(status);
+
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS checksum VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_videos_checksum ON videos(checksum);
+`},
+	// Videos are keyed by their owner like in Redis, since a video can be
+	// saved under a channel and under a playlist of channels not followed
+	{2, "unified state", `
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS doc TEXT;
+
+ALTER TABLE videos DROP CONSTRAINT IF EXISTS videos_channel_id_fkey;
+ALTER TABLE videos DROP CONSTRAINT IF EXISTS videos_pkey;
+ALTER TABLE videos ADD PRIMARY KEY (channel_id, id);
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS doc TEXT;
+CREATE INDEX IF NOT EXISTS idx_videos_id ON videos(id);
+
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id VARCHAR(64) PRIMARY KEY,
+    channel_id VARCHAR(64) NOT NULL DEFAULT '',
+    playlist_id VARCHAR(64) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    video_count INTEGER NOT NULL DEFAULT 0,
+    downloaded INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    doc TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_channel ON sync_jobs(channel_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_playlist ON sync_jobs(playlist_id, created_at);
+`},
+	{3, "comments", `
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    channel_id VARCHAR(64) NOT NULL,
+    video_id VARCHAR(64) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    parent_id TEXT NOT NULL DEFAULT '',
+    author_name TEXT NOT NULL DEFAULT '',
+    author_channel_id VARCHAR(64) NOT NULL DEFAULT '',
+    text TEXT NOT NULL,
+    like_count BIGINT NOT NULL DEFAULT 0,
+    reply_count INTEGER NOT NULL DEFAULT 0,
+    published_text VARCHAR(64) NOT NULL DEFAULT '',
+    offset_ms BIGINT NOT NULL DEFAULT 0,
+    amount VARCHAR(64) NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    archived_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_video ON comments(video_id, kind, offset_ms, position);
+CREATE INDEX IF NOT EXISTS idx_comments_channel ON comments(channel_id);
+`},
+	{4, "playlists", `
+CREATE TABLE IF NOT EXISTS playlists (
+    id VARCHAR(64) PRIMARY KEY,
+    youtube_id VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT '',
+    doc TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS playlist_items (
+    playlist_id VARCHAR(64) NOT NULL,
+    video_id VARCHAR(64) NOT NULL,
+    position INTEGER NOT NULL,
+    channel_id VARCHAR(64) NOT NULL DEFAULT '',
+    youtube_channel_id VARCHAR(64) NOT NULL DEFAULT '',
+    channel_name TEXT NOT NULL DEFAULT '',
+    title TEXT NOT NULL DEFAULT '',
+    duration BIGINT NOT NULL DEFAULT 0,
+    thumbnail_url TEXT NOT NULL DEFAULT '',
+    added_at TIMESTAMP NOT NULL,
+    removed_at TIMESTAMP,
+    PRIMARY KEY (playlist_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_items_position ON playlist_items(playlist_id, position);
+`},
+}
+
+// sqliteMigrations creates the same tables for a SQLite state database
+var sqliteMigrations = []migration{
+	{1, "unified state", `
+CREATE TABLE IF NOT EXISTS channels (
+    id TEXT PRIMARY KEY,
+    youtube_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    video_count INTEGER DEFAULT 0,
+    status TEXT NOT NULL DEFAULT '',
+    doc TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS videos (
+    id TEXT NOT NULL,
+    channel_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    duration INTEGER,
+    upload_date TEXT,
+    episode_number INTEGER,
+    filename TEXT,
+    file_path TEXT,
+    file_size INTEGER,
+    resolution TEXT,
+    format TEXT,
+    status TEXT DEFAULT 'pending',
+    checksum TEXT,
+    last_error TEXT,
+    doc TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (channel_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_videos_id ON videos(id);
+CREATE INDEX IF NOT EXISTS idx_videos_status ON videos(status);
+CREATE INDEX IF NOT EXISTS idx_videos_checksum ON videos(checksum);
+
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL DEFAULT '',
+    playlist_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    video_count INTEGER NOT NULL DEFAULT 0,
+    downloaded INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    doc TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_channel ON sync_jobs(channel_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_playlist ON sync_jobs(playlist_id, created_at);
+`},
+	{2, "comments", `
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    parent_id TEXT NOT NULL DEFAULT '',
+    author_name TEXT NOT NULL DEFAULT '',
+    author_channel_id TEXT NOT NULL DEFAULT '',
+    text TEXT NOT NULL,
+    like_count INTEGER NOT NULL DEFAULT 0,
+    reply_count INTEGER NOT NULL DEFAULT 0,
+    published_text TEXT NOT NULL DEFAULT '',
+    offset_ms INTEGER NOT NULL DEFAULT 0,
+    amount TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    archived_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_video ON comments(video_id, kind, offset_ms, position);
+CREATE INDEX IF NOT EXISTS idx_comments_channel ON comments(channel_id);
+`},
+	{3, "playlists", `
+CREATE TABLE IF NOT EXISTS playlists (
+    id TEXT PRIMARY KEY,
+    youtube_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT '',
+    doc TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS playlist_items (
+    playlist_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    channel_id TEXT NOT NULL DEFAULT '',
+    youtube_channel_id TEXT NOT NULL DEFAULT '',
+    channel_name TEXT NOT NULL DEFAULT '',
+    title TEXT NOT NULL DEFAULT '',
+    duration INTEGER NOT NULL DEFAULT 0,
+    thumbnail_url TEXT NOT NULL DEFAULT '',
+    added_at DATETIME NOT NULL,
+    removed_at DATETIME,
+    PRIMARY KEY (playlist_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_items_position ON playlist_items(playlist_id, position);
+`},
+}
+
+// migrate applies the migrations the database has not had yet, in one
+// transaction, and returns the schema version
+func (s *sqlStore) migrate(ctx context.Context) (int, error) {
+	migrations := sqliteMigrations
+	if s.postgres {
+		migrations = postgresMigrations
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.postgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+			return 0, fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`); err != nil {
+		return 0, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			return 0, fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			m.version, m.name, time.Now().UTC()); err != nil {
+			return 0, fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+		version = m.version
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,523 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/db"
+	"github.com/timholm/ytarchive/internal/logging"
+)
+
+// ReconcileOptions are the places Reconcile collects records from, besides
+// the Store itself
+type ReconcileOptions struct {
+	Redis      *redis.Client // records saved in Redis before it was a cache
+	ChannelDBs bool          // the per-channel and per-playlist SQLite databases
+	Legacy     Store         // another state database, such as the collector's PostgreSQL
+	DryRun     bool          // count what would change without saving it
+}
+
+// ReconcileResult counts the records Reconcile saved
+type ReconcileResult struct {
+	Channels  int
+	Playlists int
+	Videos    int
+	SyncJobs  int
+	Comments  int
+	Conflicts int // videos whose copies disagreed on their status
+}
+
+// Reconcile merges the state kept in Redis, the per-channel databases and a
+// legacy database into the Store, once, when the archive moves to it. For
+// each video the copy furthest along wins, so a download recorded in any
+// copy is kept; the Redis document is kept as the base since it has the most
+// fields. Comments are taken from the channel databases for the videos that
+// have none in the Store, and playlist entries from the playlist databases
+// for the playlists that have none. Running it again changes nothing.
+func Reconcile(ctx context.Context, s Store, opts ReconcileOptions) (ReconcileResult, error) {
+	var result ReconcileResult
+
+	channels := make(map[string]*Channel)
+	addChannels := func(list []Channel) {
+		for i := range list {
+			if existing, ok := channels[list[i].ID]; !ok || len(existing.Doc) == 0 {
+				channels[list[i].ID] = &list[i]
+			}
+		}
+	}
+
+	// Redis documents first, they are the ones the API served
+	if opts.Redis != nil {
+		list, err := redisChannels(ctx, opts.Redis)
+		if err != nil {
+			return result, err
+		}
+		addChannels(list)
+	}
+	for _, source := range []Store{s, opts.Legacy} {
+		if source == nil {
+			continue
+		}
+		list, err := source.ListChannels(ctx)
+		if err != nil {
+			return result, err
+		}
+		addChannels(list)
+	}
+
+	for id, channel := range channels {
+		if !opts.DryRun {
+			if err := s.SaveChannel(ctx, channel); err != nil {
+				return result, err
+			}
+		}
+		result.Channels++
+
+		if err := reconcileVideos(ctx, s, opts, id, &result); err != nil {
+			return result, err
+		}
+
+		if opts.ChannelDBs {
+			comments, err := reconcileComments(ctx, s, id, opts.DryRun)
+			if err != nil {
+				return result, err
+			}
+			result.Comments += comments
+		}
+	}
+
+	playlists := make(map[string]*Playlist)
+	if opts.Redis != nil {
+		list, err := redisPlaylists(ctx, opts.Redis)
+		if err != nil {
+			return result, err
+		}
+		for i := range list {
+			playlists[list[i].ID] = &list[i]
+		}
+	}
+	stored, err := s.ListPlaylists(ctx)
+	if err != nil {
+		return result, err
+	}
+	for i := range stored {
+		if _, ok := playlists[stored[i].ID]; !ok {
+			playlists[stored[i].ID] = &stored[i]
+		}
+	}
+
+	for id, playlist := range playlists {
+		if !opts.DryRun {
+			if err := s.SavePlaylist(ctx, playlist); err != nil {
+				return result, err
+			}
+		}
+		result.Playlists++
+
+		// Videos of channels not followed are saved under the playlist
+		if err := reconcileVideos(ctx, s, opts, id, &result); err != nil {
+			return result, err
+		}
+
+		if opts.ChannelDBs && !opts.DryRun {
+			if err := reconcilePlaylistItems(ctx, s, id); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if opts.Redis != nil {
+		jobs, err := redisSyncJobs(ctx, opts.Redis)
+		if err != nil {
+			return result, err
+		}
+		for i := range jobs {
+			if existing, err := s.GetSyncJob(ctx, jobs[i].ID); err == nil && !existing.UpdatedAt.Before(jobs[i].UpdatedAt) {
+				continue
+			}
+			if !opts.DryRun {
+				if err := s.SaveSyncJob(ctx, &jobs[i]); err != nil {
+					return result, err
+				}
+			}
+			result.SyncJobs++
+		}
+	}
+
+	return result, nil
+}
+
+// reconcileVideos merges the copies of the videos saved under a channel or
+// playlist into the Store
+func reconcileVideos(ctx context.Context, s Store, opts ReconcileOptions, ownerID string, result *ReconcileResult) error {
+	copies, err := channelVideoCopies(ctx, s, opts, ownerID)
+	if err != nil {
+		return err
+	}
+	for _, videoCopies := range copies {
+		merged, conflict := mergeVideos(videoCopies)
+		if conflict {
+			result.Conflicts++
+			logging.Info("reconciled conflicting video",
+				"channel_id", ownerID,
+				"video_id", merged.ID,
+				"status", merged.Status,
+			)
+		}
+		if !opts.DryRun {
+			if err := s.SaveVideo(ctx, merged); err != nil {
+				return err
+			}
+		}
+		result.Videos++
+	}
+	return nil
+}
+
+// channelVideoCopies collects every copy of the videos of a channel, by video ID
+func channelVideoCopies(ctx context.Context, s Store, opts ReconcileOptions, channelID string) (map[string][]Video, error) {
+	copies := make(map[string][]Video)
+	add := func(videos []Video) {
+		for _, video := range videos {
+			copies[video.ID] = append(copies[video.ID], video)
+		}
+	}
+
+	if opts.Redis != nil {
+		videos, err := redisVideos(ctx, opts.Redis, channelID)
+		if err != nil {
+			return nil, err
+		}
+		add(videos)
+	}
+	for _, source := range []Store{s, opts.Legacy} {
+		if source == nil {
+			continue
+		}
+		videos, err := source.ListVideos(ctx, channelID)
+		if err != nil {
+			return nil, err
+		}
+		add(videos)
+	}
+	if opts.ChannelDBs {
+		videos, err := channelDBVideos(channelID)
+		if err != nil {
+			return nil, err
+		}
+		add(videos)
+	}
+	return copies, nil
+}
+
+// statusRank orders video statuses by how far along a video is
+func statusRank(status string) int {
+	switch status {
+	case "downloading":
+		return 1
+	case "failed", "error":
+		return 2
+	case "skipped":
+		return 3
+	case "downloaded", "completed":
+		return 4
+	case "pruned":
+		return 5
+	}
+	return 0 // pending, queued or unknown
+}
+
+// mergeVideos merges the copies of a video. The status and files come from
+// the copy furthest along, the latest of those on a tie; the rest of the
+// document from the first copy that has one. It reports whether the copies
+// disagreed on the status.
+func mergeVideos(copies []Video) (*Video, bool) {
+	winner := copies[0]
+	base := copies[0]
+	conflict := false
+	for _, c := range copies[1:] {
+		rank := statusRank(c.Status)
+		if rank != statusRank(copies[0].Status) {
+			conflict = true
+		}
+		if winnerRank := statusRank(winner.Status); rank > winnerRank || (rank == winnerRank && c.UpdatedAt.After(winner.UpdatedAt)) {
+			winner = c
+		}
+		if len(base.Doc) == 0 && len(c.Doc) > 0 {
+			base = c
+		}
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(base.Document(), &m); err != nil {
+		m = make(map[string]interface{})
+	}
+	m["status"] = winner.Status
+
+	// A copy that lost may still know the files, if the winner did not record them
+	ordered := append([]Video{winner}, copies...)
+	filePath := firstOf(ordered, func(v Video) string { return v.FilePath })
+	checksum := firstOf(ordered, func(v Video) string { return v.Checksum })
+	if filePath != "" && winner.Status != "pruned" {
+		m["file_path"] = filePath
+	} else {
+		delete(m, "file_path")
+	}
+	if checksum != "" {
+		m["checksum"] = checksum
+	}
+	for _, c := range ordered {
+		if c.FileSize > 0 {
+			m["file_size"] = c.FileSize
+			break
+		}
+	}
+	if !winner.UpdatedAt.IsZero() {
+		m["updated_at"] = winner.UpdatedAt
+	}
+
+	doc, _ := json.Marshal(m)
+	merged, err := ParseVideo(base.ChannelID, base.ID, doc)
+	if err != nil {
+		return &winner, conflict
+	}
+	return merged, conflict
+}
+
+// firstOf returns the first non-empty value of a field of the copies
+func firstOf(copies []Video, field func(Video) string) string {
+	for _, c := range copies {
+		if value := field(c); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// redisChannels reads the channels saved in Redis
+func redisChannels(ctx context.Context, client *redis.Client) ([]Channel, error) {
+	ids, err := client.SMembers(ctx, channelListKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get channel list: %w", err)
+	}
+
+	var channels []Channel
+	for _, id := range ids {
+		doc, err := client.Get(ctx, channelKeyPrefix+id).Bytes()
+		if err != nil {
+			continue
+		}
+		channel, err := ParseChannel(doc)
+		if err != nil {
+			logging.Warn("skipping unreadable channel", "channel_id", id, "error", err)
+			continue
+		}
+		channels = append(channels, *channel)
+	}
+	return channels, nil
+}
+
+// redisPlaylists reads the playlists saved in Redis
+func redisPlaylists(ctx context.Context, client *redis.Client) ([]Playlist, error) {
+	ids, err := client.SMembers(ctx, playlistListKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get playlist list: %w", err)
+	}
+
+	var playlists []Playlist
+	for _, id := range ids {
+		doc, err := client.Get(ctx, playlistKeyPrefix+id).Bytes()
+		if err != nil {
+			continue
+		}
+		playlist, err := ParsePlaylist(doc)
+		if err != nil {
+			logging.Warn("skipping unreadable playlist", "playlist_id", id, "error", err)
+			continue
+		}
+		playlists = append(playlists, *playlist)
+	}
+	return playlists, nil
+}
+
+// redisVideos reads the videos of a channel saved in Redis
+func redisVideos(ctx context.Context, client *redis.Client, channelID string) ([]Video, error) {
+	prefix := videoKeyPrefix + channelID + ":"
+	var videos []Video
+	var cursor uint64
+	for {
+		keys, nextCursor, err := client.Scan(ctx, cursor, prefix+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan videos: %w", err)
+		}
+		for _, key := range keys {
+			doc, err := client.Get(ctx, key).Bytes()
+			if err != nil {
+				continue
+			}
+			video, err := ParseVideo(channelID, strings.TrimPrefix(key, prefix), doc)
+			if err != nil {
+				logging.Warn("skipping unreadable video", "key", key, "error", err)
+				continue
+			}
+			videos = append(videos, *video)
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return videos, nil
+		}
+	}
+}
+
+// redisSyncJobs reads the sync jobs still in Redis
+func redisSyncJobs(ctx context.Context, client *redis.Client) ([]SyncJob, error) {
+	var jobs []SyncJob
+	var cursor uint64
+	for {
+		keys, nextCursor, err := client.Scan(ctx, cursor, syncJobKeyPrefix+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sync jobs: %w", err)
+		}
+		for _, key := range keys {
+			doc, err := client.Get(ctx, key).Bytes()
+			if err != nil {
+				continue
+			}
+			job, err := ParseSyncJob(doc)
+			if err != nil {
+				logging.Warn("skipping unreadable sync job", "key", key, "error", err)
+				continue
+			}
+			jobs = append(jobs, *job)
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return jobs, nil
+		}
+	}
+}
+
+// reconcileComments copies the comments in a channel's SQLite database to the
+// Store, for each video and kind the Store has no comments of. Returns the
+// number of comments copied.
+func reconcileComments(ctx context.Context, s Store, channelID string, dryRun bool) (int, error) {
+	comments, err := channelDBComments(channelID)
+	if err != nil {
+		return 0, err
+	}
+
+	var copied int
+	for start := 0; start < len(comments); {
+		// Comments come grouped by video and kind
+		end := start + 1
+		for end < len(comments) && comments[end].VideoID == comments[start].VideoID && comments[end].Kind == comments[start].Kind {
+			end++
+		}
+		group := comments[start:end]
+		start = end
+
+		videoID, kind := group[0].VideoID, group[0].Kind
+		count, err := s.CountComments(ctx, videoID, kind)
+		if err != nil {
+			return copied, err
+		}
+		if count > 0 {
+			continue
+		}
+		if !dryRun {
+			if _, err := s.SaveComments(ctx, channelID, videoID, kind, group); err != nil {
+				return copied, err
+			}
+		}
+		copied += len(group)
+	}
+	return copied, nil
+}
+
+// reconcilePlaylistItems copies the entries in a playlist's SQLite database
+// to the Store, if the Store has none of the playlist
+func reconcilePlaylistItems(ctx context.Context, s Store, playlistID string) error {
+	existing, err := s.ListPlaylistItems(ctx, playlistID, true)
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(db.PlaylistDataDir(), playlistID, db.DBFileName)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	playlistDB, err := db.OpenPlaylistDB(playlistID)
+	if err != nil {
+		return fmt.Errorf("failed to open playlist database %s: %w", playlistID, err)
+	}
+	defer playlistDB.Close()
+
+	items, err := db.GetPlaylistItems(playlistDB, true)
+	if err != nil {
+		return fmt.Errorf("failed to read playlist database %s: %w", playlistID, err)
+	}
+	_, _, err = s.SavePlaylistItems(ctx, playlistID, items)
+	return err
+}
+
+// channelDBComments reads the comments of a channel's SQLite database, if it has one
+func channelDBComments(channelID string) ([]db.Comment, error) {
+	if !db.ChannelDBExists(channelID) {
+		return nil, nil
+	}
+	channelDB, err := db.OpenChannelDB(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel database %s: %w", channelID, err)
+	}
+	defer channelDB.Close()
+
+	comments, err := db.GetAllComments(channelDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comments of channel database %s: %w", channelID, err)
+	}
+	return comments, nil
+}
+
+// channelDBVideos reads the videos of a channel's SQLite database, if it has one
+func channelDBVideos(channelID string) ([]Video, error) {
+	if _, err := os.Stat(filepath.Join(db.DataDir(), channelID, db.DBFileName)); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	channelDB, err := db.OpenChannelDB(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel database %s: %w", channelID, err)
+	}
+	defer channelDB.Close()
+
+	dbVideos, err := db.GetAllVideos(channelDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channel database %s: %w", channelID, err)
+	}
+
+	videos := make([]Video, 0, len(dbVideos))
+	for _, v := range dbVideos {
+		videos = append(videos, Video{
+			ID:          v.ID,
+			ChannelID:   channelID,
+			Title:       v.Title,
+			Description: v.Description,
+			Duration:    int(v.Duration),
+			UploadDate:  v.UploadDate,
+			Status:      string(v.Status),
+			FilePath:    v.FilePath,
+			FileSize:    v.FileSize,
+			Checksum:    v.Checksum,
+			Error:       v.LastError,
+			UpdatedAt:   v.UpdatedAt,
+		})
+	}
+	return videos, nil
+}
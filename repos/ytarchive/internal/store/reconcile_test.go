@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/timholm/ytarchive/internal/db"
+)
+
+func TestMergeVideos(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		copies       []Video
+		wantStatus   string
+		wantPath     string
+		wantTitle    string
+		wantConflict bool
+	}{
+		{
+			name: "single copy",
+			copies: []Video{
+				{ID: "v1", ChannelID: "c1", Status: "pending", Doc: []byte(`{"title":"Redis","status":"pending"}`)},
+			},
+			wantStatus: "pending",
+			wantTitle:  "Redis",
+		},
+		{
+			name: "download recorded only in the channel database",
+			copies: []Video{
+				{ID: "v1", ChannelID: "c1", Status: "pending", UpdatedAt: newer, Doc: []byte(`{"title":"Redis","status":"pending","kind":"short"}`)},
+				{ID: "v1", ChannelID: "c1", Title: "SQLite", Status: "completed", FilePath: "/data/v1.mp4", UpdatedAt: older},
+			},
+			wantStatus:   "completed",
+			wantPath:     "/data/v1.mp4",
+			wantTitle:    "Redis",
+			wantConflict: true,
+		},
+		{
+			name: "downloaded and completed agree",
+			copies: []Video{
+				{ID: "v1", ChannelID: "c1", Status: "downloaded", UpdatedAt: older, Doc: []byte(`{"title":"Redis"}`)},
+				{ID: "v1", ChannelID: "c1", Status: "completed", FilePath: "/data/v1.mp4", UpdatedAt: newer},
+			},
+			wantStatus: "completed",
+			wantPath:   "/data/v1.mp4",
+			wantTitle:  "Redis",
+		},
+		{
+			name: "retried download beats the failure",
+			copies: []Video{
+				{ID: "v1", ChannelID: "c1", Status: "failed", UpdatedAt: newer, Doc: []byte(`{"title":"Redis"}`)},
+				{ID: "v1", ChannelID: "c1", Status: "downloaded", FilePath: "s3://bucket/v1.mp4", UpdatedAt: older},
+			},
+			wantStatus:   "downloaded",
+			wantPath:     "s3://bucket/v1.mp4",
+			wantTitle:    "Redis",
+			wantConflict: true,
+		},
+		{
+			name: "pruned video has no file",
+			copies: []Video{
+				{ID: "v1", ChannelID: "c1", Status: "pruned", Doc: []byte(`{"title":"Redis","status":"pruned"}`)},
+				{ID: "v1", ChannelID: "c1", Status: "completed", FilePath: "/data/v1.mp4"},
+			},
+			wantStatus:   "pruned",
+			wantTitle:    "Redis",
+			wantConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflict := mergeVideos(tt.copies)
+			if merged.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", merged.Status, tt.wantStatus)
+			}
+			if merged.FilePath != tt.wantPath {
+				t.Errorf("file path = %q, want %q", merged.FilePath, tt.wantPath)
+			}
+			if merged.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", merged.Title, tt.wantTitle)
+			}
+			if conflict != tt.wantConflict {
+				t.Errorf("conflict = %v, want %v", conflict, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestReconcileChannelDBs(t *testing.T) {
+	t.Setenv("STORAGE_PATH", t.TempDir())
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	if err := s.SaveChannel(ctx, &Channel{ID: "c1", YouTubeID: "UC123", Status: "synced"}); err != nil {
+		t.Fatalf("SaveChannel() error = %v", err)
+	}
+	if err := s.SaveVideo(ctx, &Video{ID: "v1", ChannelID: "c1", Title: "First", Status: "pending"}); err != nil {
+		t.Fatalf("SaveVideo() error = %v", err)
+	}
+
+	// The collector recorded the download only in the channel database
+	channelDB, err := db.OpenChannelDB("c1")
+	if err != nil {
+		t.Fatalf("OpenChannelDB() error = %v", err)
+	}
+	for _, id := range []string{"v1", "v2"} {
+		if err := db.InsertVideo(channelDB, &db.Video{ID: id, Title: id, Status: db.StatusPending}); err != nil {
+			t.Fatalf("InsertVideo() error = %v", err)
+		}
+		if err := db.MarkDownloadCompleted(channelDB, id, "/data/"+id+".mp4", 100, "abc"); err != nil {
+			t.Fatalf("MarkDownloadCompleted() error = %v", err)
+		}
+	}
+	// Comments were archived only there as well
+	comments := []db.Comment{{ID: "c-1", Text: "First!"}, {ID: "c-1.r1", ParentID: "c-1", Text: "Second"}}
+	if _, err := db.SaveComments(channelDB, "v1", db.CommentKindComment, comments); err != nil {
+		t.Fatalf("SaveComments() error = %v", err)
+	}
+	channelDB.Close()
+
+	if _, err := Reconcile(ctx, s, ReconcileOptions{ChannelDBs: true, DryRun: true}); err != nil {
+		t.Fatalf("Reconcile() dry run error = %v", err)
+	}
+	if video, _ := s.GetVideo(ctx, "c1", "v1"); video.Status != "pending" {
+		t.Errorf("dry run saved status %q", video.Status)
+	}
+	if count, _ := s.CountComments(ctx, "v1", db.CommentKindComment); count != 0 {
+		t.Errorf("dry run saved %d comments", count)
+	}
+
+	result, err := Reconcile(ctx, s, ReconcileOptions{ChannelDBs: true})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.Channels != 1 || result.Videos != 2 || result.Comments != 2 || result.Conflicts != 1 {
+		t.Errorf("Reconcile() = %+v, want 1 channel, 2 videos, 2 comments, 1 conflict", result)
+	}
+
+	video, err := s.GetVideo(ctx, "c1", "v1")
+	if err != nil {
+		t.Fatalf("GetVideo() error = %v", err)
+	}
+	if video.Status != "completed" || video.FilePath != "/data/v1.mp4" || video.Checksum != "abc" || video.Title != "First" {
+		t.Errorf("reconciled video = %+v", video)
+	}
+	if _, err := s.GetVideo(ctx, "c1", "v2"); err != nil {
+		t.Errorf("video only in the channel database was not added: %v", err)
+	}
+	copied, err := s.ListComments(ctx, "v1", db.CommentKindComment, 10, 0)
+	if err != nil || len(copied) != 2 || copied[1].ParentID != "c-1" {
+		t.Errorf("ListComments() = %+v, %v; want the comments of the channel database", copied, err)
+	}
+
+	// A second run agrees with itself
+	result, err = Reconcile(ctx, s, ReconcileOptions{ChannelDBs: true})
+	if err != nil {
+		t.Fatalf("Reconcile() again error = %v", err)
+	}
+	if result.Conflicts != 0 || result.Comments != 0 {
+		t.Errorf("Reconcile() again = %+v, want no conflicts and no comments copied", result)
+	}
+}
+
+func TestReconcilePlaylists(t *testing.T) {
+	t.Setenv("STORAGE_PATH", t.TempDir())
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// The playlist and the video of a channel not followed were only in Redis
+	server.Set(playlistKeyPrefix+"p1", `{"id":"p1","youtube_id":"PL123","status":"synced"}`)
+	server.SAdd(playlistListKey, "p1")
+	server.Set(videoKeyPrefix+"p1:v1", `{"id":"v1","channel_id":"p1","title":"One","status":"downloaded"}`)
+
+	// and the playlist order only in the playlist database
+	playlistDB, err := db.OpenPlaylistDB("p1")
+	if err != nil {
+		t.Fatalf("OpenPlaylistDB() error = %v", err)
+	}
+	if _, _, err := db.SyncPlaylistItems(playlistDB, []db.PlaylistItem{
+		{VideoID: "v1", Position: 1, ChannelID: "p1"},
+		{VideoID: "v2", Position: 2},
+	}); err != nil {
+		t.Fatalf("SyncPlaylistItems() error = %v", err)
+	}
+	if _, _, err := db.SyncPlaylistItems(playlistDB, []db.PlaylistItem{{VideoID: "v1", Position: 1, ChannelID: "p1"}}); err != nil {
+		t.Fatalf("SyncPlaylistItems() error = %v", err)
+	}
+	playlistDB.Close()
+
+	result, err := Reconcile(ctx, s, ReconcileOptions{Redis: client, ChannelDBs: true})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.Playlists != 1 || result.Videos != 1 {
+		t.Errorf("Reconcile() = %+v, want 1 playlist and 1 video", result)
+	}
+
+	if playlist, err := s.GetPlaylist(ctx, "p1"); err != nil || playlist.YouTubeID != "PL123" {
+		t.Errorf("GetPlaylist() = %+v, %v", playlist, err)
+	}
+	if video, err := s.GetVideo(ctx, "p1", "v1"); err != nil || video.Status != "downloaded" {
+		t.Errorf("GetVideo() = %+v, %v; want the video saved under the playlist", video, err)
+	}
+	items, err := s.ListPlaylistItems(ctx, "p1", true)
+	if err != nil || len(items) != 2 || items[0].VideoID != "v1" || items[0].RemovedAt != nil || items[1].RemovedAt == nil {
+		t.Errorf("ListPlaylistItems() = %+v, %v; want v1 listed and v2 removed", items, err)
+	}
+}
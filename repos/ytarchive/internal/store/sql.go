@@ -0,0 +1,864 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"github.com/timholm/ytarchive/internal/db"
+)
+
+// sqlStore is the Store on a SQLite or PostgreSQL database. Both use the
+// same queries; only the placeholders differ.
+type sqlStore struct {
+	db       *sql.DB
+	postgres bool
+	owned    bool // whether Close closes db
+}
+
+// openSQLite opens the SQLite state database at path
+func openSQLite(ctx context.Context, path string) (Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state database directory: %w", err)
+	}
+
+	// The controller and the collector may both write, so wait for the other's
+	// lock. Transactions take the write lock when they begin, so the row an
+	// update reads cannot change before it is written.
+	sqlDB, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return openSQLStore(ctx, sqlDB, false, true)
+}
+
+// openPostgres connects to the PostgreSQL state database at url
+func openPostgres(ctx context.Context, url string) (Store, error) {
+	sqlDB, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return openSQLStore(ctx, sqlDB, true, true)
+}
+
+// NewPostgres creates a Store on an open PostgreSQL connection, such as the
+// collector's, and migrates it. Closing the Store leaves the connection open.
+func NewPostgres(ctx context.Context, sqlDB *sql.DB) (Store, error) {
+	return openSQLStore(ctx, sqlDB, true, false)
+}
+
+// openSQLStore checks the connection and migrates the database
+func openSQLStore(ctx context.Context, sqlDB *sql.DB, postgres, owned bool) (Store, error) {
+	s := &sqlStore{db: sqlDB, postgres: postgres, owned: owned}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	if _, err := s.migrate(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database if the store opened it
+func (s *sqlStore) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// rebind replaces the ? placeholders of a query with PostgreSQL's $1, $2, ...
+func (s *sqlStore) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *sqlStore) exec(ctx context.Context, e execer, query string, args ...interface{}) error {
+	_, err := e.ExecContext(ctx, s.rebind(query), args...)
+	return err
+}
+
+// lockRow returns the clause that locks the rows a query selects until the
+// transaction ends. SQLite has none; its transactions hold the write lock.
+func (s *sqlStore) lockRow() string {
+	if s.postgres {
+		return ` FOR UPDATE`
+	}
+	return ""
+}
+
+// SaveChannel adds a channel or replaces it
+func (s *sqlStore) SaveChannel(ctx context.Context, channel *Channel) error {
+	return s.saveChannel(ctx, s.db, channel)
+}
+
+func (s *sqlStore) saveChannel(ctx context.Context, e execer, channel *Channel) error {
+	err := s.exec(ctx, e, `
+		INSERT INTO channels (id, youtube_id, name, status, doc, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			youtube_id = excluded.youtube_id,
+			name = excluded.name,
+			status = excluded.status,
+			doc = excluded.doc,
+			updated_at = excluded.updated_at`,
+		channel.ID, channel.YouTubeID, channel.Name, channel.Status,
+		string(channel.Document()), timestamp(channel.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save channel %s: %w", channel.ID, err)
+	}
+	return nil
+}
+
+const channelColumns = `id, youtube_id, name, status, COALESCE(doc, ''), updated_at`
+
+// GetChannel returns a channel, or ErrNotFound
+func (s *sqlStore) GetChannel(ctx context.Context, id string) (*Channel, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+channelColumns+` FROM channels WHERE id = ?`), id)
+	channel, err := scanChannel(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel %s: %w", id, err)
+	}
+	return channel, nil
+}
+
+// UpdateChannel replaces a channel with what update returns for it, in one
+// transaction that holds the channel's row
+func (s *sqlStore) UpdateChannel(ctx context.Context, id string, update func(*Channel) (*Channel, error)) (*Channel, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, s.rebind(`SELECT `+channelColumns+` FROM channels WHERE id = ?`+s.lockRow()), id)
+	channel, err := scanChannel(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel %s: %w", id, err)
+	}
+
+	channel, err = update(channel)
+	if err != nil {
+		return nil, err
+	}
+	channel.ID = id
+	if err := s.saveChannel(ctx, tx, channel); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update channel %s: %w", id, err)
+	}
+	return channel, nil
+}
+
+// ListChannels returns all channels
+func (s *sqlStore) ListChannels(ctx context.Context) ([]Channel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+channelColumns+` FROM channels ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []Channel
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan channel: %w", err)
+		}
+		channels = append(channels, *channel)
+	}
+	return channels, rows.Err()
+}
+
+// DeleteChannel deletes a channel with its videos, sync history and comments
+func (s *sqlStore) DeleteChannel(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM videos WHERE channel_id = ?`,
+		`DELETE FROM comments WHERE channel_id = ?`,
+		`DELETE FROM sync_jobs WHERE channel_id = ?`,
+		`DELETE FROM channels WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, s.rebind(query), id); err != nil {
+			return fmt.Errorf("failed to delete channel %s: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SavePlaylist adds a playlist or replaces it
+func (s *sqlStore) SavePlaylist(ctx context.Context, playlist *Playlist) error {
+	return s.savePlaylist(ctx, s.db, playlist)
+}
+
+func (s *sqlStore) savePlaylist(ctx context.Context, e execer, playlist *Playlist) error {
+	err := s.exec(ctx, e, `
+		INSERT INTO playlists (id, youtube_id, status, doc, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			youtube_id = excluded.youtube_id,
+			status = excluded.status,
+			doc = excluded.doc,
+			updated_at = excluded.updated_at`,
+		playlist.ID, playlist.YouTubeID, playlist.Status,
+		string(playlist.Document()), timestamp(playlist.CreatedAt), timestamp(playlist.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save playlist %s: %w", playlist.ID, err)
+	}
+	return nil
+}
+
+const playlistColumns = `id, youtube_id, status, COALESCE(doc, ''), created_at, updated_at`
+
+// GetPlaylist returns a playlist, or ErrNotFound
+func (s *sqlStore) GetPlaylist(ctx context.Context, id string) (*Playlist, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+playlistColumns+` FROM playlists WHERE id = ?`), id)
+	playlist, err := scanPlaylist(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist %s: %w", id, err)
+	}
+	return playlist, nil
+}
+
+// UpdatePlaylist replaces a playlist with what update returns for it, in one
+// transaction that holds the playlist's row
+func (s *sqlStore) UpdatePlaylist(ctx context.Context, id string, update func(*Playlist) (*Playlist, error)) (*Playlist, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, s.rebind(`SELECT `+playlistColumns+` FROM playlists WHERE id = ?`+s.lockRow()), id)
+	playlist, err := scanPlaylist(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist %s: %w", id, err)
+	}
+
+	playlist, err = update(playlist)
+	if err != nil {
+		return nil, err
+	}
+	playlist.ID = id
+	if err := s.savePlaylist(ctx, tx, playlist); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update playlist %s: %w", id, err)
+	}
+	return playlist, nil
+}
+
+// ListPlaylists returns all playlists
+func (s *sqlStore) ListPlaylists(ctx context.Context) ([]Playlist, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+playlistColumns+` FROM playlists ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlists: %w", err)
+	}
+	defer rows.Close()
+
+	var playlists []Playlist
+	for rows.Next() {
+		playlist, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan playlist: %w", err)
+		}
+		playlists = append(playlists, *playlist)
+	}
+	return playlists, rows.Err()
+}
+
+// DeletePlaylist deletes a playlist with its entries
+func (s *sqlStore) DeletePlaylist(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM playlist_items WHERE playlist_id = ?`,
+		`DELETE FROM playlists WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, s.rebind(query), id); err != nil {
+			return fmt.Errorf("failed to delete playlist %s: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SavePlaylistItems records the entries a playlist lists now, in one
+// transaction
+func (s *sqlStore) SavePlaylistItems(ctx context.Context, playlistID string, items []db.PlaylistItem) (added, removed int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The entries listed before; those not listed again are removed below
+	rows, err := tx.QueryContext(ctx, s.rebind(`SELECT video_id FROM playlist_items WHERE playlist_id = ? AND removed_at IS NULL`), playlistID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get playlist items: %w", err)
+	}
+	listed := make(map[string]bool)
+	for rows.Next() {
+		var videoID string
+		if err := rows.Scan(&videoID); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan playlist item: %w", err)
+		}
+		listed[videoID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to get playlist items: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`
+		INSERT INTO playlist_items (playlist_id, video_id, position, channel_id, youtube_channel_id,
+		                            channel_name, title, duration, thumbnail_url, added_at, removed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (playlist_id, video_id) DO UPDATE SET
+			position = excluded.position,
+			channel_id = excluded.channel_id,
+			youtube_channel_id = excluded.youtube_channel_id,
+			channel_name = excluded.channel_name,
+			title = excluded.title,
+			duration = excluded.duration,
+			thumbnail_url = excluded.thumbnail_url,
+			removed_at = excluded.removed_at`))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prepare playlist item insert: %w", err)
+	}
+	defer stmt.Close()
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		var removedAt sql.NullTime
+		if item.RemovedAt != nil {
+			removedAt = sql.NullTime{Time: item.RemovedAt.UTC(), Valid: true}
+		} else if !listed[item.VideoID] && !seen[item.VideoID] {
+			added++
+		}
+		if _, err := stmt.ExecContext(ctx,
+			playlistID, item.VideoID, item.Position, item.ChannelID, item.YouTubeChannelID,
+			item.ChannelName, item.Title, item.Duration, item.ThumbnailURL,
+			timestamp(item.AddedAt), removedAt,
+		); err != nil {
+			return 0, 0, fmt.Errorf("failed to save playlist item %s: %w", item.VideoID, err)
+		}
+		if item.RemovedAt == nil {
+			seen[item.VideoID] = true
+		}
+	}
+
+	now := time.Now().UTC()
+	for videoID := range listed {
+		if seen[videoID] {
+			continue
+		}
+		result, err := tx.ExecContext(ctx, s.rebind(`UPDATE playlist_items SET removed_at = ? WHERE playlist_id = ? AND video_id = ? AND removed_at IS NULL`),
+			now, playlistID, videoID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to mark playlist item %s removed: %w", videoID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			removed += int(n)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit playlist items: %w", err)
+	}
+	return added, removed, nil
+}
+
+// ListPlaylistItems returns the entries of a playlist in playlist order
+func (s *sqlStore) ListPlaylistItems(ctx context.Context, playlistID string, includeRemoved bool) ([]db.PlaylistItem, error) {
+	query := `SELECT video_id, position, channel_id, youtube_channel_id, channel_name, title, duration,
+		thumbnail_url, added_at, removed_at
+		FROM playlist_items WHERE playlist_id = ?`
+	if !includeRemoved {
+		query += ` AND removed_at IS NULL`
+	}
+	query += ` ORDER BY removed_at IS NOT NULL, position, video_id`
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), playlistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlist items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []db.PlaylistItem
+	for rows.Next() {
+		var item db.PlaylistItem
+		var addedAt, removedAt sql.NullTime
+		if err := rows.Scan(
+			&item.VideoID, &item.Position, &item.ChannelID, &item.YouTubeChannelID, &item.ChannelName,
+			&item.Title, &item.Duration, &item.ThumbnailURL, &addedAt, &removedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan playlist item: %w", err)
+		}
+		item.AddedAt = addedAt.Time
+		if removedAt.Valid {
+			item.RemovedAt = &removedAt.Time
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// SaveVideo adds a video or replaces it
+func (s *sqlStore) SaveVideo(ctx context.Context, video *Video) error {
+	return s.saveVideo(ctx, s.db, video)
+}
+
+func (s *sqlStore) saveVideo(ctx context.Context, e execer, video *Video) error {
+	err := s.exec(ctx, e, `
+		INSERT INTO videos (id, channel_id, title, description, duration, upload_date, episode_number,
+		                    status, filename, file_path, file_size, resolution, format, checksum,
+		                    last_error, doc, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (channel_id, id) DO UPDATE SET
+			title = excluded.title,
+			description = excluded.description,
+			duration = excluded.duration,
+			upload_date = excluded.upload_date,
+			episode_number = excluded.episode_number,
+			status = excluded.status,
+			filename = excluded.filename,
+			file_path = excluded.file_path,
+			file_size = excluded.file_size,
+			resolution = excluded.resolution,
+			format = excluded.format,
+			checksum = excluded.checksum,
+			last_error = excluded.last_error,
+			doc = excluded.doc,
+			updated_at = excluded.updated_at`,
+		video.ID, video.ChannelID, video.Title, video.Description, video.Duration, video.UploadDate,
+		video.EpisodeNumber, video.Status, video.Filename, video.FilePath, video.FileSize,
+		video.Resolution, video.Format, video.Checksum, video.Error,
+		string(video.Document()), timestamp(video.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save video %s: %w", video.ID, err)
+	}
+	return nil
+}
+
+// Columns recorded by the collector before documents were may be NULL
+const videoColumns = `id, channel_id, title, COALESCE(description, ''), COALESCE(duration, 0),
+	COALESCE(upload_date, ''), COALESCE(episode_number, 0), COALESCE(status, ''),
+	COALESCE(filename, ''), COALESCE(file_path, ''), COALESCE(file_size, 0),
+	COALESCE(resolution, ''), COALESCE(format, ''), COALESCE(checksum, ''),
+	COALESCE(last_error, ''), COALESCE(doc, ''), updated_at`
+
+// GetVideo returns a video of a channel, or ErrNotFound
+func (s *sqlStore) GetVideo(ctx context.Context, channelID, videoID string) (*Video, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+videoColumns+` FROM videos WHERE channel_id = ? AND id = ?`), channelID, videoID)
+	video, err := scanVideo(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video %s: %w", videoID, err)
+	}
+	return video, nil
+}
+
+// FindVideo returns a video of whichever channel has it, or ErrNotFound
+func (s *sqlStore) FindVideo(ctx context.Context, videoID string) (*Video, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+videoColumns+` FROM videos
+		WHERE id = ? AND channel_id IN (SELECT id FROM channels) ORDER BY channel_id LIMIT 1`), videoID)
+	video, err := scanVideo(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find video %s: %w", videoID, err)
+	}
+	return video, nil
+}
+
+// UpdateVideo replaces a video with what update returns for it, in one
+// transaction that holds the video's row
+func (s *sqlStore) UpdateVideo(ctx context.Context, channelID, videoID string, update func(*Video) (*Video, error)) (*Video, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, s.rebind(`SELECT `+videoColumns+` FROM videos WHERE channel_id = ? AND id = ?`+s.lockRow()), channelID, videoID)
+	video, err := scanVideo(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video %s: %w", videoID, err)
+	}
+
+	video, err = update(video)
+	if err != nil {
+		return nil, err
+	}
+	video.ChannelID, video.ID = channelID, videoID
+	if err := s.saveVideo(ctx, tx, video); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update video %s: %w", videoID, err)
+	}
+	return video, nil
+}
+
+// ListVideos returns the videos of a channel by episode
+func (s *sqlStore) ListVideos(ctx context.Context, channelID string) ([]Video, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+videoColumns+` FROM videos WHERE channel_id = ? ORDER BY episode_number, id`), channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list videos: %w", err)
+	}
+	defer rows.Close()
+
+	var videos []Video
+	for rows.Next() {
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan video: %w", err)
+		}
+		videos = append(videos, *video)
+	}
+	return videos, rows.Err()
+}
+
+// CountVideos counts a channel's videos by status
+func (s *sqlStore) CountVideos(ctx context.Context, channelID string) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT COALESCE(status, ''), COUNT(*) FROM videos WHERE channel_id = ? GROUP BY status`), channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count videos: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan video count: %w", err)
+		}
+		counts[status] += count
+	}
+	return counts, rows.Err()
+}
+
+// SaveSyncJob adds a sync job or replaces it
+func (s *sqlStore) SaveSyncJob(ctx context.Context, job *SyncJob) error {
+	err := s.exec(ctx, s.db, `
+		INSERT INTO sync_jobs (id, channel_id, playlist_id, status, video_count, downloaded, failed, doc, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			video_count = excluded.video_count,
+			downloaded = excluded.downloaded,
+			failed = excluded.failed,
+			doc = excluded.doc,
+			updated_at = excluded.updated_at`,
+		job.ID, job.ChannelID, job.PlaylistID, job.Status, job.VideoCount, job.Downloaded, job.Failed,
+		string(job.Document()), timestamp(job.CreatedAt), timestamp(job.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save sync job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+const syncJobColumns = `id, channel_id, playlist_id, status, video_count, downloaded, failed, COALESCE(doc, ''), created_at, updated_at`
+
+// GetSyncJob returns a sync job, or ErrNotFound
+func (s *sqlStore) GetSyncJob(ctx context.Context, id string) (*SyncJob, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+syncJobColumns+` FROM sync_jobs WHERE id = ?`), id)
+	job, err := scanSyncJob(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// ListSyncJobs returns the sync history of a channel or playlist, newest first
+func (s *sqlStore) ListSyncJobs(ctx context.Context, ownerID string, limit int) ([]SyncJob, error) {
+	query := `SELECT ` + syncJobColumns + ` FROM sync_jobs WHERE channel_id = ? OR playlist_id = ? ORDER BY created_at DESC, id`
+	args := []interface{}{ownerID, ownerID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	return s.querySyncJobs(ctx, query, args...)
+}
+
+// ListUnfinishedSyncJobs returns the sync jobs that have not completed or
+// failed, oldest first
+func (s *sqlStore) ListUnfinishedSyncJobs(ctx context.Context) ([]SyncJob, error) {
+	return s.querySyncJobs(ctx, `SELECT `+syncJobColumns+` FROM sync_jobs
+		WHERE status NOT IN ('completed', 'failed') ORDER BY created_at, id`)
+}
+
+// querySyncJobs returns the sync jobs a query selects
+func (s *sqlStore) querySyncJobs(ctx context.Context, query string, args ...interface{}) ([]SyncJob, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sync jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []SyncJob
+	for rows.Next() {
+		job, err := scanSyncJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sync job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveComments stores the comments of a video of one kind in the order they
+// were listed, in one transaction
+func (s *sqlStore) SaveComments(ctx context.Context, channelID, videoID, kind string, comments []db.Comment) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`
+		INSERT INTO comments (id, channel_id, video_id, kind, parent_id, author_name, author_channel_id,
+		                      text, like_count, reply_count, published_text, offset_ms, amount,
+		                      position, archived_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			author_name = excluded.author_name,
+			text = excluded.text,
+			like_count = excluded.like_count,
+			reply_count = excluded.reply_count,
+			published_text = excluded.published_text,
+			amount = excluded.amount,
+			position = excluded.position,
+			archived_at = excluded.archived_at`))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare comment insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for i, comment := range comments {
+		if _, err := stmt.ExecContext(ctx,
+			comment.ID, channelID, videoID, kind, comment.ParentID, comment.AuthorName,
+			comment.AuthorChannelID, comment.Text, comment.LikeCount, comment.ReplyCount,
+			comment.PublishedText, comment.OffsetMs, comment.Amount, i, now,
+		); err != nil {
+			return 0, fmt.Errorf("failed to save comment %s: %w", comment.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit comments: %w", err)
+	}
+	return len(comments), nil
+}
+
+const commentColumns = `id, video_id, kind, parent_id, author_name, author_channel_id, text, like_count,
+	reply_count, published_text, offset_ms, amount, position, archived_at`
+
+// ListComments returns a page of the archived comments of a video of one kind
+func (s *sqlStore) ListComments(ctx context.Context, videoID, kind string, limit, offset int) ([]db.Comment, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return s.queryComments(ctx, `SELECT `+commentColumns+` FROM comments
+		WHERE video_id = ? AND kind = ? ORDER BY offset_ms, position LIMIT ? OFFSET ?`,
+		videoID, kind, limit, offset)
+}
+
+// CountComments returns the number of archived comments of a video of one kind
+func (s *sqlStore) CountComments(ctx context.Context, videoID, kind string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM comments WHERE video_id = ? AND kind = ?`), videoID, kind).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+	return count, nil
+}
+
+// SearchComments returns the comments of a video of one kind whose text or
+// author contains query, ignoring case
+func (s *sqlStore) SearchComments(ctx context.Context, videoID, kind, query string, limit int) ([]db.Comment, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(query)) + "%"
+	return s.queryComments(ctx, `SELECT `+commentColumns+` FROM comments
+		WHERE video_id = ? AND kind = ?
+		  AND (LOWER(text) LIKE ? ESCAPE '\' OR LOWER(author_name) LIKE ? ESCAPE '\')
+		ORDER BY offset_ms, position LIMIT ?`,
+		videoID, kind, pattern, pattern, limit)
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// queryComments returns the comments a query selects
+func (s *sqlStore) queryComments(ctx context.Context, query string, args ...interface{}) ([]db.Comment, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []db.Comment
+	for rows.Next() {
+		var comment db.Comment
+		var archivedAt sql.NullTime
+		if err := rows.Scan(
+			&comment.ID, &comment.VideoID, &comment.Kind, &comment.ParentID, &comment.AuthorName,
+			&comment.AuthorChannelID, &comment.Text, &comment.LikeCount, &comment.ReplyCount,
+			&comment.PublishedText, &comment.OffsetMs, &comment.Amount, &comment.Position, &archivedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comment.ArchivedAt = archivedAt.Time
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanChannel(row scanner) (*Channel, error) {
+	var channel Channel
+	var doc string
+	var updatedAt sql.NullTime
+	if err := row.Scan(&channel.ID, &channel.YouTubeID, &channel.Name, &channel.Status, &doc, &updatedAt); err != nil {
+		return nil, err
+	}
+	channel.Doc = rawDoc(doc)
+	channel.UpdatedAt = updatedAt.Time
+	return &channel, nil
+}
+
+func scanPlaylist(row scanner) (*Playlist, error) {
+	var playlist Playlist
+	var doc string
+	var createdAt, updatedAt sql.NullTime
+	if err := row.Scan(&playlist.ID, &playlist.YouTubeID, &playlist.Status, &doc, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	playlist.Doc = rawDoc(doc)
+	playlist.CreatedAt = createdAt.Time
+	playlist.UpdatedAt = updatedAt.Time
+	return &playlist, nil
+}
+
+func scanVideo(row scanner) (*Video, error) {
+	var video Video
+	var doc string
+	var updatedAt sql.NullTime
+	err := row.Scan(&video.ID, &video.ChannelID, &video.Title, &video.Description, &video.Duration,
+		&video.UploadDate, &video.EpisodeNumber, &video.Status, &video.Filename, &video.FilePath,
+		&video.FileSize, &video.Resolution, &video.Format, &video.Checksum, &video.Error, &doc, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	video.Doc = rawDoc(doc)
+	video.UpdatedAt = updatedAt.Time
+	return &video, nil
+}
+
+func scanSyncJob(row scanner) (*SyncJob, error) {
+	var job SyncJob
+	var doc string
+	var createdAt, updatedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.ChannelID, &job.PlaylistID, &job.Status, &job.VideoCount,
+		&job.Downloaded, &job.Failed, &doc, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Doc = rawDoc(doc)
+	job.CreatedAt = createdAt.Time
+	job.UpdatedAt = updatedAt.Time
+	return &job, nil
+}
+
+// rawDoc returns a stored document, nil if there is none
+func rawDoc(doc string) json.RawMessage {
+	if doc == "" {
+		return nil
+	}
+	return json.RawMessage(doc)
+}
+
+// timestamp returns t in UTC, or the current time if it is zero
+func timestamp(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t.UTC()
+}
@@ -0,0 +1,380 @@
+// Package store is the archive's record of its channels, playlists, videos,
+// syncs and archived comments.
+// One database, SQLite or PostgreSQL, holds them for every component: the API,
+// the scheduler, the sync steps and the collector. Workers report through the
+// API and the collector. Redis keeps a copy of the records as a cache, see
+// Records, next to the download queue.
+//
+// Each record keeps the JSON document the API returns in Doc; the fields that
+// are queried are copied from it into columns.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+)
+
+// ErrNotFound is returned for a channel, playlist, video or sync job that is not stored
+var ErrNotFound = errors.New("not found")
+
+// Store holds the channels, playlists, videos, sync jobs and comments of the archive
+type Store interface {
+	// SaveChannel adds a channel or replaces it
+	SaveChannel(ctx context.Context, channel *Channel) error
+	GetChannel(ctx context.Context, id string) (*Channel, error)
+	// UpdateChannel replaces a channel with what update returns for it. No
+	// other update of the channel runs in between.
+	UpdateChannel(ctx context.Context, id string, update func(*Channel) (*Channel, error)) (*Channel, error)
+	ListChannels(ctx context.Context) ([]Channel, error)
+	// DeleteChannel deletes a channel with its videos, sync history and comments
+	DeleteChannel(ctx context.Context, id string) error
+
+	// SavePlaylist adds a playlist or replaces it
+	SavePlaylist(ctx context.Context, playlist *Playlist) error
+	GetPlaylist(ctx context.Context, id string) (*Playlist, error)
+	// UpdatePlaylist replaces a playlist with what update returns for it. No
+	// other update of the playlist runs in between.
+	UpdatePlaylist(ctx context.Context, id string, update func(*Playlist) (*Playlist, error)) (*Playlist, error)
+	ListPlaylists(ctx context.Context) ([]Playlist, error)
+	// DeletePlaylist deletes a playlist with its entries; the videos saved
+	// under it are kept
+	DeletePlaylist(ctx context.Context, id string) error
+	// SavePlaylistItems records the entries a playlist lists now. Entries no
+	// longer listed are kept as removed, and so are items that carry
+	// RemovedAt. Returns the number of entries newly added and newly removed.
+	SavePlaylistItems(ctx context.Context, playlistID string, items []db.PlaylistItem) (added, removed int, err error)
+	// ListPlaylistItems returns the entries of a playlist in playlist order;
+	// removed entries are only listed with includeRemoved, and last
+	ListPlaylistItems(ctx context.Context, playlistID string, includeRemoved bool) ([]db.PlaylistItem, error)
+
+	// SaveVideo adds a video or replaces it
+	SaveVideo(ctx context.Context, video *Video) error
+	GetVideo(ctx context.Context, channelID, videoID string) (*Video, error)
+	// FindVideo returns a video of whichever channel has it, or ErrNotFound;
+	// copies kept under playlists are not searched
+	FindVideo(ctx context.Context, videoID string) (*Video, error)
+	// UpdateVideo replaces a video with what update returns for it. No other
+	// update of the video runs in between.
+	UpdateVideo(ctx context.Context, channelID, videoID string, update func(*Video) (*Video, error)) (*Video, error)
+	ListVideos(ctx context.Context, channelID string) ([]Video, error)
+	// CountVideos counts a channel's videos by status
+	CountVideos(ctx context.Context, channelID string) (map[string]int, error)
+
+	// SaveSyncJob adds a sync job or replaces it
+	SaveSyncJob(ctx context.Context, job *SyncJob) error
+	GetSyncJob(ctx context.Context, id string) (*SyncJob, error)
+	// ListSyncJobs returns the sync history of a channel or playlist, newest
+	// first; limit 0 returns all of it
+	ListSyncJobs(ctx context.Context, ownerID string, limit int) ([]SyncJob, error)
+	// ListUnfinishedSyncJobs returns the sync jobs that have not completed or
+	// failed, oldest first
+	ListUnfinishedSyncJobs(ctx context.Context) ([]SyncJob, error)
+
+	// SaveComments stores the comments of a video of one kind in the order
+	// they were listed. Comments archived before are updated, and ones no
+	// longer listed upstream are kept. Returns the number of comments stored.
+	SaveComments(ctx context.Context, channelID, videoID, kind string, comments []db.Comment) (int, error)
+	// ListComments returns a page of the archived comments of a video of one
+	// kind: comments in the order YouTube listed them, chat messages in the
+	// order they were shown
+	ListComments(ctx context.Context, videoID, kind string, limit, offset int) ([]db.Comment, error)
+	CountComments(ctx context.Context, videoID, kind string) (int, error)
+	// SearchComments returns the comments of a video of one kind whose text
+	// or author contains query, in listing order
+	SearchComments(ctx context.Context, videoID, kind, query string, limit int) ([]db.Comment, error)
+
+	Close() error
+}
+
+// Channel is a channel the archive follows
+type Channel struct {
+	ID        string
+	YouTubeID string
+	Name      string
+	Status    string
+	Doc       json.RawMessage
+	UpdatedAt time.Time
+}
+
+// Playlist is a YouTube playlist the archive follows
+type Playlist struct {
+	ID        string
+	YouTubeID string
+	Status    string
+	Doc       json.RawMessage
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Video is a video of a channel, or of a playlist when its channel is not
+// followed; ChannelID is the owner either way, as in the Redis keys
+type Video struct {
+	ID            string
+	ChannelID     string
+	Title         string
+	Description   string
+	Duration      int
+	UploadDate    string
+	EpisodeNumber int
+	Status        string
+	Filename      string
+	FilePath      string
+	FileSize      int64
+	Resolution    string
+	Format        string
+	Checksum      string
+	Error         string
+	Doc           json.RawMessage
+	UpdatedAt     time.Time
+}
+
+// SyncJob is a sync of a channel or playlist. Sync jobs are kept once they
+// finish and make up the sync history.
+type SyncJob struct {
+	ID         string
+	ChannelID  string
+	PlaylistID string
+	Status     string
+	VideoCount int
+	Downloaded int
+	Failed     int
+	Doc        json.RawMessage
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Open opens the store at a database URL, a postgres:// URL or a sqlite://
+// path, and migrates it to the current schema
+func Open(ctx context.Context, url string) (Store, error) {
+	switch {
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return openPostgres(ctx, url)
+	case strings.HasPrefix(url, "sqlite://"):
+		return openSQLite(ctx, strings.TrimPrefix(url, "sqlite://"))
+	}
+	return nil, fmt.Errorf("unsupported state database %q, use postgres:// or sqlite://", url)
+}
+
+// URLFromEnv returns the state database from STATE_DATABASE_URL, or a SQLite
+// database next to the channel databases
+func URLFromEnv() string {
+	if url := os.Getenv("STATE_DATABASE_URL"); url != "" {
+		return url
+	}
+	return "sqlite://" + filepath.Join(filepath.Dir(db.DataDir()), "state.db")
+}
+
+// ParseChannel reads the queried fields of a channel document
+func ParseChannel(doc []byte) (*Channel, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse channel: %w", err)
+	}
+	channel := &Channel{
+		ID:        docString(m, "id"),
+		YouTubeID: docString(m, "youtube_id"),
+		Name:      docString(m, "name"),
+		Status:    docString(m, "status"),
+		Doc:       json.RawMessage(doc),
+		UpdatedAt: docTime(m, "updated_at"),
+	}
+	if channel.ID == "" {
+		return nil, fmt.Errorf("channel has no id")
+	}
+	return channel, nil
+}
+
+// ParsePlaylist reads the queried fields of a playlist document
+func ParsePlaylist(doc []byte) (*Playlist, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse playlist: %w", err)
+	}
+	playlist := &Playlist{
+		ID:        docString(m, "id"),
+		YouTubeID: docString(m, "youtube_id"),
+		Status:    docString(m, "status"),
+		Doc:       json.RawMessage(doc),
+		CreatedAt: docTime(m, "created_at"),
+		UpdatedAt: docTime(m, "updated_at"),
+	}
+	if playlist.ID == "" {
+		return nil, fmt.Errorf("playlist has no id")
+	}
+	return playlist, nil
+}
+
+// ParseVideo reads the queried fields of the document of a video of channelID
+func ParseVideo(channelID, videoID string, doc []byte) (*Video, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse video: %w", err)
+	}
+	return &Video{
+		ID:            videoID,
+		ChannelID:     channelID,
+		Title:         docString(m, "title"),
+		Description:   docString(m, "description"),
+		Duration:      int(docInt(m, "duration")),
+		UploadDate:    docString(m, "upload_date"),
+		EpisodeNumber: int(docInt(m, "episode_number")),
+		Status:        docString(m, "status"),
+		Filename:      docString(m, "filename"),
+		FilePath:      docString(m, "file_path"),
+		FileSize:      docInt(m, "file_size"),
+		Resolution:    docString(m, "resolution"),
+		Format:        docString(m, "format"),
+		Checksum:      docString(m, "checksum"),
+		Error:         docString(m, "error"),
+		Doc:           json.RawMessage(doc),
+		UpdatedAt:     docTime(m, "updated_at"),
+	}, nil
+}
+
+// ParseSyncJob reads the queried fields of a sync job document
+func ParseSyncJob(doc []byte) (*SyncJob, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(doc, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse sync job: %w", err)
+	}
+	job := &SyncJob{
+		ID:         docString(m, "id"),
+		ChannelID:  docString(m, "channel_id"),
+		PlaylistID: docString(m, "playlist_id"),
+		Status:     docString(m, "status"),
+		VideoCount: int(docInt(m, "video_count")),
+		Downloaded: int(docInt(m, "downloaded")),
+		Failed:     int(docInt(m, "failed")),
+		Doc:        json.RawMessage(doc),
+		CreatedAt:  docTime(m, "created_at"),
+		UpdatedAt:  docTime(m, "updated_at"),
+	}
+	if job.ID == "" {
+		return nil, fmt.Errorf("sync job has no id")
+	}
+	return job, nil
+}
+
+// Document returns the channel's document, built from its fields for
+// channels stored before documents were
+func (c *Channel) Document() []byte {
+	if len(c.Doc) > 0 {
+		return c.Doc
+	}
+	doc, _ := json.Marshal(map[string]interface{}{
+		"id":         c.ID,
+		"youtube_id": c.YouTubeID,
+		"name":       c.Name,
+		"status":     c.Status,
+		"updated_at": c.UpdatedAt,
+	})
+	return doc
+}
+
+// Document returns the playlist's document
+func (p *Playlist) Document() []byte {
+	if len(p.Doc) > 0 {
+		return p.Doc
+	}
+	doc, _ := json.Marshal(map[string]interface{}{
+		"id":         p.ID,
+		"youtube_id": p.YouTubeID,
+		"status":     p.Status,
+		"created_at": p.CreatedAt,
+		"updated_at": p.UpdatedAt,
+	})
+	return doc
+}
+
+// Document returns the video's document, built from its fields for videos
+// stored before documents were, such as uploads recorded by the collector
+func (v *Video) Document() []byte {
+	if len(v.Doc) > 0 {
+		return v.Doc
+	}
+	m := map[string]interface{}{
+		"id":             v.ID,
+		"youtube_id":     v.ID,
+		"channel_id":     v.ChannelID,
+		"title":          v.Title,
+		"description":    v.Description,
+		"duration":       v.Duration,
+		"upload_date":    v.UploadDate,
+		"episode_number": v.EpisodeNumber,
+		"status":         v.Status,
+		"updated_at":     v.UpdatedAt,
+	}
+	for key, value := range map[string]string{
+		"filename":   v.Filename,
+		"file_path":  v.FilePath,
+		"resolution": v.Resolution,
+		"format":     v.Format,
+		"checksum":   v.Checksum,
+		"error":      v.Error,
+	} {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	if v.FileSize > 0 {
+		m["file_size"] = v.FileSize
+	}
+	doc, _ := json.Marshal(m)
+	return doc
+}
+
+// Document returns the sync job's document
+func (j *SyncJob) Document() []byte {
+	if len(j.Doc) > 0 {
+		return j.Doc
+	}
+	doc, _ := json.Marshal(map[string]interface{}{
+		"id":          j.ID,
+		"channel_id":  j.ChannelID,
+		"playlist_id": j.PlaylistID,
+		"status":      j.Status,
+		"video_count": j.VideoCount,
+		"downloaded":  j.Downloaded,
+		"failed":      j.Failed,
+		"created_at":  j.CreatedAt,
+		"updated_at":  j.UpdatedAt,
+	})
+	return doc
+}
+
+// docString returns a string field of a document, "" if it is missing or not a string
+func docString(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// docInt returns a numeric field of a document, also when it was stored as a string
+func docInt(m map[string]interface{}, key string) int64 {
+	switch v := m[key].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// docTime returns a timestamp field of a document, the current time if it is missing
+func docTime(m map[string]interface{}, key string) time.Time {
+	if s, ok := m[key].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Now().UTC()
+}
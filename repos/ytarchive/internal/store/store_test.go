@@ -0,0 +1,462 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/db"
+)
+
+// setupTestStore opens a SQLite store in a temporary directory
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+
+	s, err := Open(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	ctx := context.Background()
+
+	s, err := Open(ctx, "sqlite://"+path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	version, err := s.(*sqlStore).migrate(ctx)
+	if err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	if want := sqliteMigrations[len(sqliteMigrations)-1].version; version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+	s.Close()
+
+	// Opening an existing database applies nothing again
+	s, err = Open(ctx, "sqlite://"+path)
+	if err != nil {
+		t.Fatalf("Open() existing database error = %v", err)
+	}
+	s.Close()
+
+	if _, err := Open(ctx, "mysql://localhost/ytarchive"); err == nil {
+		t.Error("Open() with an unsupported URL succeeded")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM videos WHERE channel_id = ? AND id = ?`
+
+	if got := (&sqlStore{}).rebind(query); got != query {
+		t.Errorf("SQLite rebind() = %q, want the query unchanged", got)
+	}
+	want := `SELECT id FROM videos WHERE channel_id = $1 AND id = $2`
+	if got := (&sqlStore{postgres: true}).rebind(query); got != want {
+		t.Errorf("PostgreSQL rebind() = %q, want %q", got, want)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	doc := []byte(`{"id":"c1","youtube_id":"UC123","name":"Channel","status":"pending","kinds":["videos"]}`)
+	channel, err := ParseChannel(doc)
+	if err != nil {
+		t.Fatalf("ParseChannel() error = %v", err)
+	}
+	if err := s.SaveChannel(ctx, channel); err != nil {
+		t.Fatalf("SaveChannel() error = %v", err)
+	}
+
+	channel.Status = "synced"
+	if err := s.SaveChannel(ctx, channel); err != nil {
+		t.Fatalf("SaveChannel() update error = %v", err)
+	}
+
+	got, err := s.GetChannel(ctx, "c1")
+	if err != nil {
+		t.Fatalf("GetChannel() error = %v", err)
+	}
+	if got.YouTubeID != "UC123" || got.Status != "synced" || string(got.Doc) != string(doc) {
+		t.Errorf("GetChannel() = %+v", got)
+	}
+
+	channels, err := s.ListChannels(ctx)
+	if err != nil || len(channels) != 1 {
+		t.Fatalf("ListChannels() = %d channels, %v; want 1", len(channels), err)
+	}
+
+	if _, err := s.GetChannel(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetChannel() missing error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPlaylists(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	doc := []byte(`{"id":"p1","youtube_id":"PL123","status":"pending","sync_interval_hours":12}`)
+	playlist, err := ParsePlaylist(doc)
+	if err != nil {
+		t.Fatalf("ParsePlaylist() error = %v", err)
+	}
+	if err := s.SavePlaylist(ctx, playlist); err != nil {
+		t.Fatalf("SavePlaylist() error = %v", err)
+	}
+
+	updated, err := s.UpdatePlaylist(ctx, "p1", func(p *Playlist) (*Playlist, error) {
+		p.Status = "synced"
+		p.Doc = nil
+		return p, nil
+	})
+	if err != nil || updated.Status != "synced" {
+		t.Fatalf("UpdatePlaylist() = %+v, %v", updated, err)
+	}
+	got, err := s.GetPlaylist(ctx, "p1")
+	if err != nil || got.YouTubeID != "PL123" || got.Status != "synced" {
+		t.Errorf("GetPlaylist() = %+v, %v", got, err)
+	}
+	if playlists, err := s.ListPlaylists(ctx); err != nil || len(playlists) != 1 {
+		t.Errorf("ListPlaylists() = %d playlists, %v; want 1", len(playlists), err)
+	}
+
+	first := []db.PlaylistItem{
+		{VideoID: "v1", Position: 1, ChannelID: "c1", Title: "One"},
+		{VideoID: "v2", Position: 2, ChannelID: "p1", Title: "Two"},
+		{VideoID: "v3", Position: 3, ChannelID: "c1", Title: "Three"},
+	}
+	added, removed, err := s.SavePlaylistItems(ctx, "p1", first)
+	if err != nil || added != 3 || removed != 0 {
+		t.Fatalf("SavePlaylistItems() = (%d, %d), %v; want (3, 0)", added, removed, err)
+	}
+
+	// v2 leaves the playlist, v4 joins and v1 moves to the end
+	second := []db.PlaylistItem{
+		{VideoID: "v3", Position: 1, ChannelID: "c1", Title: "Three"},
+		{VideoID: "v4", Position: 2, ChannelID: "c1", Title: "Four"},
+		{VideoID: "v1", Position: 3, ChannelID: "c1", Title: "One"},
+	}
+	added, removed, err = s.SavePlaylistItems(ctx, "p1", second)
+	if err != nil || added != 1 || removed != 1 {
+		t.Fatalf("SavePlaylistItems() = (%d, %d), %v; want (1, 1)", added, removed, err)
+	}
+
+	items, err := s.ListPlaylistItems(ctx, "p1", false)
+	if err != nil {
+		t.Fatalf("ListPlaylistItems() error = %v", err)
+	}
+	var order []string
+	for _, item := range items {
+		order = append(order, item.VideoID)
+	}
+	if want := []string{"v3", "v4", "v1"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ListPlaylistItems() = %v, want %v", order, want)
+	}
+
+	all, err := s.ListPlaylistItems(ctx, "p1", true)
+	if err != nil || len(all) != 4 || all[3].VideoID != "v2" || all[3].RemovedAt == nil || all[3].AddedAt.IsZero() {
+		t.Errorf("ListPlaylistItems(includeRemoved) = %+v, %v; want v2 last and removed", all, err)
+	}
+
+	// An entry that comes back counts as added again
+	added, _, err = s.SavePlaylistItems(ctx, "p1", append(second, db.PlaylistItem{VideoID: "v2", Position: 4}))
+	if err != nil || added != 1 {
+		t.Errorf("SavePlaylistItems() re-added = %d, %v; want 1", added, err)
+	}
+
+	if err := s.DeletePlaylist(ctx, "p1"); err != nil {
+		t.Fatalf("DeletePlaylist() error = %v", err)
+	}
+	if _, err := s.GetPlaylist(ctx, "p1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPlaylist() after delete error = %v, want ErrNotFound", err)
+	}
+	if items, _ := s.ListPlaylistItems(ctx, "p1", true); len(items) != 0 {
+		t.Errorf("ListPlaylistItems() after delete = %d items, want 0", len(items))
+	}
+}
+
+func TestVideos(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	for i, doc := range []string{
+		`{"id":"v1","title":"First","episode_number":1,"status":"downloaded","file_path":"/data/v1.mp4","file_size":100}`,
+		`{"id":"v2","title":"Second","episode_number":2,"status":"pending"}`,
+		`{"id":"v3","title":"Third","episode_number":"3","status":"failed","error":"HTTP 429"}`,
+	} {
+		video, err := ParseVideo("c1", []string{"v1", "v2", "v3"}[i], []byte(doc))
+		if err != nil {
+			t.Fatalf("ParseVideo() error = %v", err)
+		}
+		if err := s.SaveVideo(ctx, video); err != nil {
+			t.Fatalf("SaveVideo() error = %v", err)
+		}
+	}
+
+	// The same video may be saved under a playlist too
+	playlistVideo := &Video{ID: "v1", ChannelID: "p1", Title: "First", Status: "pending"}
+	if err := s.SaveVideo(ctx, playlistVideo); err != nil {
+		t.Fatalf("SaveVideo() under playlist error = %v", err)
+	}
+
+	video, err := s.GetVideo(ctx, "c1", "v1")
+	if err != nil {
+		t.Fatalf("GetVideo() error = %v", err)
+	}
+	if video.Status != "downloaded" || video.FilePath != "/data/v1.mp4" || video.FileSize != 100 || video.EpisodeNumber != 1 {
+		t.Errorf("GetVideo() = %+v", video)
+	}
+
+	videos, err := s.ListVideos(ctx, "c1")
+	if err != nil {
+		t.Fatalf("ListVideos() error = %v", err)
+	}
+	if len(videos) != 3 || videos[2].ID != "v3" || videos[2].EpisodeNumber != 3 || videos[2].Error != "HTTP 429" {
+		t.Errorf("ListVideos() = %+v", videos)
+	}
+
+	counts, err := s.CountVideos(ctx, "c1")
+	if err != nil {
+		t.Fatalf("CountVideos() error = %v", err)
+	}
+	want := map[string]int{"downloaded": 1, "pending": 1, "failed": 1}
+	for status, n := range want {
+		if counts[status] != n {
+			t.Errorf("CountVideos()[%q] = %d, want %d", status, counts[status], n)
+		}
+	}
+
+	// Only videos of followed channels are found by ID
+	if _, err := s.FindVideo(ctx, "v1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindVideo() without channel c1 error = %v, want ErrNotFound", err)
+	}
+	if err := s.SaveChannel(ctx, &Channel{ID: "c1", Name: "Channel"}); err != nil {
+		t.Fatalf("SaveChannel() error = %v", err)
+	}
+	if found, err := s.FindVideo(ctx, "v1"); err != nil || found.ChannelID != "c1" {
+		t.Errorf("FindVideo() = %+v, %v; want the video of c1", found, err)
+	}
+
+	if err := s.DeleteChannel(ctx, "c1"); err != nil {
+		t.Fatalf("DeleteChannel() error = %v", err)
+	}
+	if _, err := s.GetVideo(ctx, "c1", "v1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetVideo() after DeleteChannel() error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetVideo(ctx, "p1", "v1"); err != nil {
+		t.Errorf("GetVideo() of the playlist copy error = %v", err)
+	}
+}
+
+func TestSyncJobs(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, id := range []string{"job-1", "job-2", "job-3"} {
+		job := &SyncJob{
+			ID:        id,
+			ChannelID: "c1",
+			Status:    "completed",
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: start.Add(time.Duration(i) * time.Hour),
+		}
+		if err := s.SaveSyncJob(ctx, job); err != nil {
+			t.Fatalf("SaveSyncJob() error = %v", err)
+		}
+	}
+	if err := s.SaveSyncJob(ctx, &SyncJob{ID: "job-4", PlaylistID: "p1", Status: "running", CreatedAt: start}); err != nil {
+		t.Fatalf("SaveSyncJob() playlist error = %v", err)
+	}
+
+	jobs, err := s.ListSyncJobs(ctx, "c1", 2)
+	if err != nil {
+		t.Fatalf("ListSyncJobs() error = %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "job-3" || jobs[1].ID != "job-2" {
+		t.Errorf("ListSyncJobs() = %+v, want job-3 and job-2", jobs)
+	}
+
+	unfinished, err := s.ListUnfinishedSyncJobs(ctx)
+	if err != nil || len(unfinished) != 1 || unfinished[0].ID != "job-4" {
+		t.Errorf("ListUnfinishedSyncJobs() = %+v, %v; want job-4", unfinished, err)
+	}
+
+	job, err := s.GetSyncJob(ctx, "job-4")
+	if err != nil {
+		t.Fatalf("GetSyncJob() error = %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(job.Document(), &doc); err != nil || doc["playlist_id"] != "p1" {
+		t.Errorf("GetSyncJob() document = %s, %v", job.Document(), err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	comments := []db.Comment{
+		{ID: "c-1", AuthorName: "Alice", Text: "First!", LikeCount: 3, ReplyCount: 1},
+		{ID: "c-1.r1", ParentID: "c-1", AuthorName: "Bob", Text: "100% agreed"},
+		{ID: "c-2", AuthorName: "Carol", Text: "Great video"},
+	}
+	stored, err := s.SaveComments(ctx, "c1", "v1", db.CommentKindComment, comments)
+	if err != nil || stored != 3 {
+		t.Fatalf("SaveComments() = %d, %v; want 3", stored, err)
+	}
+	chat := []db.Comment{{ID: "m-1", AuthorName: "Dave", Text: "hello", OffsetMs: 1500, Amount: "$5.00"}}
+	if _, err := s.SaveComments(ctx, "c1", "v1", db.CommentKindChat, chat); err != nil {
+		t.Fatalf("SaveComments() chat error = %v", err)
+	}
+
+	page, err := s.ListComments(ctx, "v1", db.CommentKindComment, 2, 1)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	if len(page) != 2 || page[0].ID != "c-1.r1" || page[0].ParentID != "c-1" || page[1].ID != "c-2" {
+		t.Errorf("ListComments() = %+v, want c-1.r1 and c-2", page)
+	}
+	if count, err := s.CountComments(ctx, "v1", db.CommentKindComment); err != nil || count != 3 {
+		t.Errorf("CountComments() = %d, %v; want 3", count, err)
+	}
+
+	messages, err := s.ListComments(ctx, "v1", db.CommentKindChat, 10, 0)
+	if err != nil || len(messages) != 1 || messages[0].OffsetMs != 1500 || messages[0].Amount != "$5.00" || messages[0].ArchivedAt.IsZero() {
+		t.Errorf("ListComments() chat = %+v, %v", messages, err)
+	}
+
+	// Archiving again updates the comments and keeps ones no longer listed
+	if _, err := s.SaveComments(ctx, "c1", "v1", db.CommentKindComment, []db.Comment{
+		{ID: "c-2", AuthorName: "Carol", Text: "Great video, edited"},
+	}); err != nil {
+		t.Fatalf("SaveComments() again error = %v", err)
+	}
+	if count, _ := s.CountComments(ctx, "v1", db.CommentKindComment); count != 3 {
+		t.Errorf("CountComments() after archiving again = %d, want 3", count)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"great", []string{"c-2"}},
+		{"bob", []string{"c-1.r1"}},
+		{"100%", []string{"c-1.r1"}},
+		{"_", nil},
+	}
+	for _, tt := range tests {
+		found, err := s.SearchComments(ctx, "v1", db.CommentKindComment, tt.query, 10)
+		if err != nil {
+			t.Fatalf("SearchComments(%q) error = %v", tt.query, err)
+		}
+		var ids []string
+		for _, comment := range found {
+			ids = append(ids, comment.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("SearchComments(%q) = %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+
+	if err := s.DeleteChannel(ctx, "c1"); err != nil {
+		t.Fatalf("DeleteChannel() error = %v", err)
+	}
+	if count, _ := s.CountComments(ctx, "v1", db.CommentKindComment); count != 0 {
+		t.Errorf("CountComments() after DeleteChannel() = %d, want 0", count)
+	}
+}
+
+func TestVideoDocument(t *testing.T) {
+	// Videos recorded by the collector before documents were have none
+	video := &Video{ID: "v1", ChannelID: "c1", Title: "Upload", Status: "downloaded", FilePath: "/data/v1.mp4", FileSize: 100}
+
+	parsed, err := ParseVideo("c1", "v1", video.Document())
+	if err != nil {
+		t.Fatalf("ParseVideo() error = %v", err)
+	}
+	if parsed.Title != "Upload" || parsed.Status != "downloaded" || parsed.FilePath != "/data/v1.mp4" || parsed.FileSize != 100 {
+		t.Errorf("ParseVideo(Document()) = %+v", parsed)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	// Two stores on the database, as the controller and the collector have
+	var records []*Records
+	for i := 0; i < 2; i++ {
+		s, err := Open(ctx, "sqlite://"+path)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		records = append(records, NewRecords(s, nil))
+	}
+
+	if err := records[0].PutChannel(ctx, []byte(`{"id":"c1","count":0}`)); err != nil {
+		t.Fatalf("PutChannel() error = %v", err)
+	}
+	if err := records[0].PutVideo(ctx, "c1", "v1", []byte(`{"id":"v1","count":0}`)); err != nil {
+		t.Fatalf("PutVideo() error = %v", err)
+	}
+
+	increment := func(doc map[string]interface{}) {
+		count, _ := doc["count"].(float64)
+		doc["count"] = count + 1
+	}
+
+	const updates = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*updates)
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(r *Records) {
+			defer wg.Done()
+			if _, err := r.UpdateChannel(ctx, "c1", increment); err != nil {
+				errs <- err
+			}
+			if _, err := r.UpdateVideo(ctx, "c1", "v1", increment); err != nil {
+				errs <- err
+			}
+		}(records[i%2])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("update error = %v", err)
+	}
+
+	for name, read := range map[string]func() ([]byte, error){
+		"channel": func() ([]byte, error) { return records[0].Channel(ctx, "c1") },
+		"video":   func() ([]byte, error) { return records[0].Video(ctx, "c1", "v1") },
+	} {
+		data, err := read()
+		if err != nil {
+			t.Fatalf("reading %s error = %v", name, err)
+		}
+		var doc map[string]interface{}
+		json.Unmarshal(data, &doc)
+		if doc["count"] != float64(updates) {
+			t.Errorf("%s count = %v, want %d: updates were lost", name, doc["count"], updates)
+		}
+	}
+
+	if _, err := records[0].UpdateVideo(ctx, "c1", "missing", increment); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateVideo() missing error = %v, want ErrNotFound", err)
+	}
+}
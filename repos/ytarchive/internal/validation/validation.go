@@ -12,6 +12,8 @@ var (
 	ErrInvalidURL       = fmt.Errorf("invalid URL format")
 	ErrInvalidChannelID = fmt.Errorf("invalid channel ID format")
 	ErrInvalidVideoID   = fmt.Errorf("invalid video ID format")
+	ErrInvalidPlaylist  = fmt.Errorf("invalid playlist ID format")
+	ErrInvalidKind      = fmt.Errorf("invalid video kind")
 	ErrUnsafeInput      = fmt.Errorf("input contains potentially unsafe characters")
 )
 
@@ -23,12 +25,23 @@ var (
 	// YouTube video ID: 11 alphanumeric characters (including - and _)
 	videoIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{11}$`)
 
+	// YouTube playlist ID: known prefix (PL, UU, OL, FL, LL, ...) followed by alphanumeric characters
+	playlistIDRegex = regexp.MustCompile(`^(PL|UU|OL|FL|LL|UL|EL|RD)[a-zA-Z0-9_-]{8,62}$`)
+
 	// YouTube handle: @ followed by alphanumeric, underscores, dots, hyphens (3-30 chars)
 	handleRegex = regexp.MustCompile(`^@[a-zA-Z0-9._-]{3,30}$`)
 
 	// Safe URL characters (no shell injection, XSS, etc.)
 	unsafeCharsRegex = regexp.MustCompile(`[<>'";&|$\x60\\]`)
 
+	// Video kinds a channel can opt into archiving
+	validVideoKinds = map[string]bool{
+		"video":    true,
+		"short":    true,
+		"live":     true,
+		"premiere": true,
+	}
+
 	// Valid YouTube URL hosts
 	validYouTubeHosts = map[string]bool{
 		"youtube.com":     true,
@@ -129,6 +142,70 @@ func validateYouTubeURL(rawURL string) (string, error) {
 	}
 }
 
+// ValidatePlaylistInput validates playlist input (URL or ID) and returns the playlist ID
+func ValidatePlaylistInput(input string) (string, error) {
+	input = strings.TrimSpace(input)
+
+	if input == "" {
+		return "", fmt.Errorf("playlist input cannot be empty")
+	}
+
+	// If it looks like a URL, take the playlist ID from the list parameter.
+	// Playlist URLs usually carry several query parameters, so the unsafe
+	// character check is applied to the extracted ID instead of the raw URL.
+	if strings.Contains(input, "://") || strings.HasPrefix(input, "www.") || strings.Contains(input, "youtube.com/") {
+		rawURL := input
+		if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+			rawURL = "https://" + rawURL
+		}
+
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return "", ErrInvalidURL
+		}
+		if !validYouTubeHosts[parsed.Host] {
+			return "", fmt.Errorf("not a valid YouTube URL: %s", parsed.Host)
+		}
+
+		input = parsed.Query().Get("list")
+		if input == "" {
+			return "", fmt.Errorf("no playlist ID in URL")
+		}
+	}
+
+	if unsafeCharsRegex.MatchString(input) {
+		return "", ErrUnsafeInput
+	}
+
+	if !playlistIDRegex.MatchString(input) {
+		return "", ErrInvalidPlaylist
+	}
+
+	return input, nil
+}
+
+// ValidateVideoKinds validates a channel's list of video kinds to archive.
+// Kinds are lowercased and de-duplicated; an empty list is returned unchanged
+// so callers can fall back to their default.
+func ValidateVideoKinds(kinds []string) ([]string, error) {
+	result := make([]string, 0, len(kinds))
+	seen := make(map[string]bool, len(kinds))
+
+	for _, kind := range kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if !validVideoKinds[kind] {
+			return nil, fmt.Errorf("%w: %q (expected video, short, live or premiere)", ErrInvalidKind, kind)
+		}
+		if seen[kind] {
+			continue
+		}
+		seen[kind] = true
+		result = append(result, kind)
+	}
+
+	return result, nil
+}
+
 // ValidateVideoID validates a YouTube video ID
 func ValidateVideoID(videoID string) error {
 	videoID = strings.TrimSpace(videoID)
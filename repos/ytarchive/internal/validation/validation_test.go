@@ -77,6 +77,135 @@ func TestValidateChannelInput(t *testing.T) {
 	}
 }
 
+func TestValidatePlaylistInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "valid playlist ID",
+			input:   "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			want:    "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			wantErr: false,
+		},
+		{
+			name:    "uploads playlist ID",
+			input:   "UUddiUEpeqJcYeBxX1IVBKvQ",
+			want:    "UUddiUEpeqJcYeBxX1IVBKvQ",
+			wantErr: false,
+		},
+		{
+			name:    "playlist URL",
+			input:   "https://www.youtube.com/playlist?list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			want:    "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			wantErr: false,
+		},
+		{
+			name:    "watch URL with list parameter",
+			input:   "https://youtube.com/watch?v=dQw4w9WgXcQ&list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			want:    "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			wantErr: false,
+		},
+		{
+			name:    "URL without scheme",
+			input:   "www.youtube.com/playlist?list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			want:    "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			wantErr: false,
+		},
+		{
+			name:    "URL without list parameter",
+			input:   "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+			wantErr: true,
+		},
+		{
+			name:    "non-YouTube URL",
+			input:   "https://example.com/playlist?list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			wantErr: true,
+		},
+		{
+			name:    "channel ID is not a playlist",
+			input:   "UCddiUEpeqJcYeBxX1IVBKvQ",
+			wantErr: true,
+		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "unsafe characters",
+			input:   "PLabc<script>",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidatePlaylistInput(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePlaylistInput() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ValidatePlaylistInput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateVideoKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		kinds    []string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "empty list",
+			kinds:    nil,
+			expected: []string{},
+		},
+		{
+			name:     "all kinds",
+			kinds:    []string{"video", "short", "live", "premiere"},
+			expected: []string{"video", "short", "live", "premiere"},
+		},
+		{
+			name:     "normalizes case and duplicates",
+			kinds:    []string{"Video", " live ", "video"},
+			expected: []string{"video", "live"},
+		},
+		{
+			name:    "unknown kind",
+			kinds:   []string{"video", "clip"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ValidateVideoKinds(tt.kinds)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateVideoKinds() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("ValidateVideoKinds() = %v, want %v", result, tt.expected)
+			}
+			for i := range result {
+				if result[i] != tt.expected[i] {
+					t.Errorf("ValidateVideoKinds()[%d] = %q, want %q", i, result[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
 func TestValidateVideoID(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,182 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/ratelimit"
+)
+
+// accountMenuEndpoint returns the account menu, which names the signed-in account
+const accountMenuEndpoint = "https://www.youtube.com/youtubei/v1/account/account_menu"
+
+// youtubeOrigin is the origin authenticated innertube requests are hashed for
+const youtubeOrigin = "https://www.youtube.com"
+
+// StatusError is returned when YouTube answers a request with an unexpected HTTP status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// PlayabilityError is returned when the player endpoint will not play a video
+type PlayabilityError struct {
+	Status string // LOGIN_REQUIRED, UNPLAYABLE, ERROR, ...
+	Reason string
+	Client string // innertube client that asked, if not the default one
+}
+
+func (e *PlayabilityError) Error() string {
+	if e.Client != "" {
+		return fmt.Sprintf("video not playable with %s: %s", e.Client, e.Reason)
+	}
+	return fmt.Sprintf("video not playable: %s", e.Reason)
+}
+
+// RequiresSignIn reports whether YouTube asked for a signed-in account, as it
+// does for its bot check ("Sign in to confirm you're not a bot") and age gate
+func (e *PlayabilityError) RequiresSignIn() bool {
+	return strings.Contains(strings.ToLower(e.Reason), "sign in to confirm")
+}
+
+// IsBotCheck reports whether YouTube suspects the requests come from a bot,
+// which it does when they come too fast
+func (e *PlayabilityError) IsBotCheck() bool {
+	return strings.Contains(strings.ToLower(e.Reason), "not a bot")
+}
+
+// newPlayabilityError creates the error for a playability status other than OK
+func newPlayabilityError(status *PlayabilityStatus, client string) *PlayabilityError {
+	reason := status.Reason
+	if reason == "" {
+		reason = status.Status
+	}
+	return &PlayabilityError{Status: status.Status, Reason: reason, Client: client}
+}
+
+// IsCredentialError reports whether a request failed because of the cookies it
+// was made with: YouTube refused it with 403 Forbidden, or asked to sign in.
+// Trying again with other cookies may succeed.
+func IsCredentialError(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusForbidden
+	}
+	var playErr *PlayabilityError
+	if errors.As(err, &playErr) {
+		return playErr.RequiresSignIn()
+	}
+	return false
+}
+
+// SetCookies replaces the cookies requests are made with. Requests already
+// under way keep the cookies they started with.
+func (c *Client) SetCookies(cookies []*http.Cookie) {
+	c.cookieMu.Lock()
+	defer c.cookieMu.Unlock()
+	c.cookies = cookies
+	c.cookieHeader = GetCookieHeader(cookies)
+}
+
+// currentCookieHeader returns the Cookie header requests are made with
+func (c *Client) currentCookieHeader() string {
+	c.cookieMu.RLock()
+	defer c.cookieMu.RUnlock()
+	return c.cookieHeader
+}
+
+// CheckLogin asks YouTube whether the client's cookies are still signed in to
+// an account. It fetches the account menu, a small authenticated request, and
+// returns false without an error when the cookies are signed out or expired.
+func (c *Client) CheckLogin(ctx context.Context) (bool, error) {
+	c.cookieMu.RLock()
+	cookies, cookieHeader := c.cookies, c.cookieHeader
+	c.cookieMu.RUnlock()
+
+	sapisid := sapisidCookie(cookies)
+	if sapisid == "" {
+		// Without SAPISID the cookies were never signed in
+		return false, nil
+	}
+
+	if err := c.wait(ctx, ratelimit.BudgetBrowse); err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"context": c.createContext()})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", accountMenuEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Origin", youtubeOrigin)
+	req.Header.Set("X-Origin", youtubeOrigin)
+	req.Header.Set("Referer", youtubeOrigin+"/")
+	req.Header.Set("X-Goog-AuthUser", "0")
+	req.Header.Set("Authorization", "SAPISIDHASH "+sapisidHash(sapisid, youtubeOrigin, time.Now()))
+	req.Header.Set("Cookie", cookieHeader)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return isSignedInAccountMenu(data), nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, &StatusError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+}
+
+// isSignedInAccountMenu reports whether an account menu response names a
+// signed-in account; a signed-out menu only offers to sign in
+func isSignedInAccountMenu(data []byte) bool {
+	return bytes.Contains(data, []byte(`"activeAccountHeaderRenderer"`))
+}
+
+// sapisidCookie returns the SAPISID cookie authenticated requests are signed with
+func sapisidCookie(cookies []*http.Cookie) string {
+	var secure string
+	for _, cookie := range cookies {
+		switch cookie.Name {
+		case "SAPISID":
+			return cookie.Value
+		case "__Secure-3PAPISID":
+			secure = cookie.Value
+		}
+	}
+	return secure
+}
+
+// sapisidHash returns the SAPISIDHASH YouTube expects in the Authorization
+// header of cookie-authenticated innertube requests
+func sapisidHash(sapisid, origin string, now time.Time) string {
+	timestamp := now.Unix()
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d %s %s", timestamp, sapisid, origin)))
+	return fmt.Sprintf("%d_%x", timestamp, sum)
+}
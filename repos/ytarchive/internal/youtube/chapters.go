@@ -0,0 +1,133 @@
+package youtube
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Engagement panel target IDs that hold chapter lists
+const (
+	descriptionChaptersPanel = "engagement-panel-macro-markers-description-chapters"
+	autoChaptersPanel        = "engagement-panel-macro-markers-auto-chapters"
+)
+
+// minDescriptionChapters is the fewest timestamps YouTube turns into chapters
+const minDescriptionChapters = 3
+
+// chapterTimestampRegex matches a timestamp such as 1:02:03 or 4:05 on a description line
+var chapterTimestampRegex = regexp.MustCompile(`(?:^|[\s(\[])((?:\d{1,2}:)?\d{1,2}:\d{2})(?:$|[\s)\]])`)
+
+// ParseDescriptionChapters extracts chapters from the timestamp lines of a video
+// description, following YouTube's rules: the first chapter starts at 0:00, times
+// increase, and there are at least three of them. duration (in seconds) ends the
+// last chapter; pass 0 if it is unknown. Returns nil if the description has no chapters.
+func ParseDescriptionChapters(description string, duration int) []Chapter {
+	var chapters []Chapter
+
+	for _, line := range strings.Split(description, "\n") {
+		match := chapterTimestampRegex.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+
+		start, ok := parseTimestamp(line[match[2]:match[3]])
+		if !ok {
+			continue
+		}
+
+		title := strings.TrimSpace(line[:match[2]] + " " + line[match[3]:])
+		title = strings.Trim(title, " \t-–—:|•()[]")
+		title = strings.TrimSpace(title)
+
+		if len(chapters) == 0 && start != 0 {
+			// Timestamps before the chapter list (e.g. in a paragraph) are ignored
+			continue
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].StartTime {
+			break
+		}
+
+		chapters = append(chapters, Chapter{Title: title, StartTime: start})
+	}
+
+	if len(chapters) < minDescriptionChapters {
+		return nil
+	}
+	return finishChapters(chapters, duration)
+}
+
+// parseEngagementPanelChapters extracts chapters from the watch page's engagement panels.
+// Creator chapters are preferred over automatically generated ones.
+func parseEngagementPanelChapters(resp *NextResponse, duration int) []Chapter {
+	var auto []Chapter
+
+	for _, panel := range resp.EngagementPanels {
+		renderer := panel.EngagementPanelSectionListRenderer
+		if renderer == nil || renderer.Content.MacroMarkersListRenderer == nil {
+			continue
+		}
+
+		var chapters []Chapter
+		for _, item := range renderer.Content.MacroMarkersListRenderer.Contents {
+			marker := item.MacroMarkersListItemRenderer
+			if marker == nil || marker.OnTap.WatchEndpoint == nil {
+				continue
+			}
+			chapters = append(chapters, Chapter{
+				Title:     marker.Title.GetText(),
+				StartTime: float64(marker.OnTap.WatchEndpoint.StartTimeSeconds),
+			})
+		}
+		if len(chapters) == 0 {
+			continue
+		}
+
+		switch renderer.TargetID {
+		case descriptionChaptersPanel:
+			return finishChapters(chapters, duration)
+		case autoChaptersPanel:
+			auto = chapters
+		default:
+			if auto == nil {
+				auto = chapters
+			}
+		}
+	}
+
+	if auto == nil {
+		return nil
+	}
+	return finishChapters(auto, duration)
+}
+
+// finishChapters sets each chapter's end time to the start of the next one,
+// and the last chapter's to the video duration
+func finishChapters(chapters []Chapter, duration int) []Chapter {
+	for i := range chapters {
+		if i+1 < len(chapters) {
+			chapters[i].EndTime = chapters[i+1].StartTime
+		} else if float64(duration) > chapters[i].StartTime {
+			chapters[i].EndTime = float64(duration)
+		}
+	}
+	return chapters
+}
+
+// parseTimestamp parses h:mm:ss or m:ss into seconds
+func parseTimestamp(s string) (float64, bool) {
+	parts := strings.Split(s, ":")
+	var seconds int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		// Minutes and seconds after the first field must be below 60
+		if i > 0 && n >= 60 {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return float64(seconds), true
+}
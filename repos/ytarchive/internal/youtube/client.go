@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/timholm/ytarchive/internal/metrics"
@@ -23,6 +24,7 @@ const (
 	browseEndpoint  = "https://www.youtube.com/youtubei/v1/browse"
 	playerEndpoint  = "https://www.youtube.com/youtubei/v1/player"
 	resolveEndpoint = "https://www.youtube.com/youtubei/v1/navigation/resolve_url"
+	nextEndpoint    = "https://www.youtube.com/youtubei/v1/next"
 
 	// Default client configuration (WEB client)
 	defaultClientName    = "WEB"
@@ -49,7 +51,8 @@ type Client struct {
 	userAgent     string
 	cookies       []*http.Cookie
 	cookieHeader  string
-	rateLimiter   *ratelimit.Limiter
+	cookieMu      sync.RWMutex // guards cookies and cookieHeader, which SetCookies swaps
+	rateLimiter   ratelimit.BudgetLimiter
 	hlsParser     *HLSManifestParser
 }
 
@@ -114,10 +117,18 @@ func WithCookiesFromString(content string) ClientOption {
 	}
 }
 
-// WithRateLimit sets custom rate limiting
+// WithRateLimit sets custom rate limiting for this client alone
 func WithRateLimit(requestsPerSecond float64, burst int) ClientOption {
 	return func(c *Client) {
-		c.rateLimiter = ratelimit.NewLimiter(requestsPerSecond, burst)
+		c.rateLimiter = ratelimit.NewLocal(requestsPerSecond, burst)
+	}
+}
+
+// WithRateLimiter sets the rate limiter requests wait on, such as one shared
+// with other processes
+func WithRateLimiter(limiter ratelimit.BudgetLimiter) ClientOption {
+	return func(c *Client) {
+		c.rateLimiter = limiter
 	}
 }
 
@@ -128,7 +139,7 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		clientName:    defaultClientName,
 		clientVersion: defaultClientVersion,
 		userAgent:     defaultUserAgent,
-		rateLimiter:   ratelimit.NewLimiter(defaultRateLimit, defaultBurstSize),
+		rateLimiter:   ratelimit.NewLocal(defaultRateLimit, defaultBurstSize),
 	}
 
 	for _, opt := range opts {
@@ -178,13 +189,6 @@ var retryableStatusCodes = map[int]bool{
 
 // doRequest performs an HTTP POST request with retry and exponential backoff
 func (c *Client) doRequest(ctx context.Context, endpoint string, body interface{}) ([]byte, error) {
-	// Apply rate limiting
-	if c.rateLimiter != nil {
-		if err := c.rateLimiter.Wait(ctx); err != nil {
-			return nil, fmt.Errorf("rate limit wait cancelled: %w", err)
-		}
-	}
-
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
@@ -192,6 +196,7 @@ func (c *Client) doRequest(ctx context.Context, endpoint string, body interface{
 
 	// Extract endpoint name for metrics
 	endpointName := extractEndpointName(endpoint)
+	budget := endpointBudget(endpointName)
 
 	var lastErr error
 	for attempt := 0; attempt <= maxRetries; attempt++ {
@@ -207,6 +212,11 @@ func (c *Client) doRequest(ctx context.Context, endpoint string, body interface{
 			}
 		}
 
+		// Apply rate limiting, retries included
+		if err := c.wait(ctx, budget); err != nil {
+			return nil, err
+		}
+
 		startTime := time.Now()
 		respBody, statusCode, err := c.doSingleRequest(ctx, endpoint, jsonBody)
 		latency := time.Since(startTime).Seconds()
@@ -228,6 +238,10 @@ func (c *Client) doRequest(ctx context.Context, endpoint string, body interface{
 			return respBody, nil
 		}
 
+		if statusCode == http.StatusTooManyRequests {
+			c.backoff(ctx, budget)
+		}
+
 		// Check if status code is retryable
 		if retryableStatusCodes[statusCode] {
 			lastErr = fmt.Errorf("request failed with status %d", statusCode)
@@ -237,12 +251,40 @@ func (c *Client) doRequest(ctx context.Context, endpoint string, body interface{
 
 		// Non-retryable error
 		metrics.RecordAPIRequest(endpointName, fmt.Sprintf("%d", statusCode), latency)
-		return nil, fmt.Errorf("request failed with status %d: %s", statusCode, string(respBody))
+		return nil, &StatusError{StatusCode: statusCode, Body: string(respBody)}
 	}
 
 	return nil, fmt.Errorf("request failed after %d attempts: %w", maxRetries+1, lastErr)
 }
 
+// wait waits for the rate limiter before a request of a budget
+func (c *Client) wait(ctx context.Context, budget ratelimit.Budget) error {
+	if c.rateLimiter == nil {
+		return nil
+	}
+	if err := c.rateLimiter.Wait(ctx, budget); err != nil {
+		return fmt.Errorf("rate limit wait cancelled: %w", err)
+	}
+	return nil
+}
+
+// backoff slows requests of a budget down after YouTube pushed back
+func (c *Client) backoff(ctx context.Context, budget ratelimit.Budget) {
+	if c.rateLimiter != nil {
+		c.rateLimiter.Backoff(ctx, budget)
+	}
+}
+
+// playabilityError creates the error for a playability status other than OK,
+// slowing the player budget down when it is a bot check
+func (c *Client) playabilityError(ctx context.Context, status *PlayabilityStatus, client string) *PlayabilityError {
+	err := newPlayabilityError(status, client)
+	if err.IsBotCheck() {
+		c.backoff(ctx, ratelimit.BudgetPlayer)
+	}
+	return err
+}
+
 // doSingleRequest performs a single HTTP request without retry logic
 func (c *Client) doSingleRequest(ctx context.Context, endpoint string, jsonBody []byte) ([]byte, int, error) {
 	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
@@ -258,8 +300,8 @@ func (c *Client) doSingleRequest(ctx context.Context, endpoint string, jsonBody
 	req.Header.Set("Referer", "https://www.youtube.com/")
 
 	// Add cookies if available for authenticated requests
-	if c.cookieHeader != "" {
-		req.Header.Set("Cookie", c.cookieHeader)
+	if cookieHeader := c.currentCookieHeader(); cookieHeader != "" {
+		req.Header.Set("Cookie", cookieHeader)
 	}
 
 	resp, err := c.httpClient.Do(req)
@@ -295,11 +337,24 @@ func extractEndpointName(endpoint string) string {
 		return "player"
 	case strings.Contains(endpoint, "/resolve_url"):
 		return "resolve_url"
+	case strings.Contains(endpoint, "/next"):
+		return "next"
+	case strings.Contains(endpoint, "/get_live_chat_replay"):
+		return "live_chat_replay"
 	default:
 		return "unknown"
 	}
 }
 
+// endpointBudget returns the rate limit budget of an innertube endpoint, by its
+// name from extractEndpointName
+func endpointBudget(endpointName string) ratelimit.Budget {
+	if endpointName == "player" {
+		return ratelimit.BudgetPlayer
+	}
+	return ratelimit.BudgetBrowse
+}
+
 // normalizeChannelURL converts various YouTube channel URL formats to a standard format
 func normalizeChannelURL(url string) string {
 	url = strings.TrimSpace(url)
@@ -456,6 +511,7 @@ func (c *Client) GetVideoListContext(ctx context.Context, channelID string) ([]V
 	}
 
 	videos := parseVideosFromBrowseResponse(&resp)
+	applyDefaultKind(videos, KindVideo)
 
 	// Fetch continuation pages
 	continuationToken := extractContinuationToken(&resp)
@@ -465,6 +521,7 @@ func (c *Client) GetVideoListContext(ctx context.Context, channelID string) ([]V
 			// Log error but continue with what we have
 			break
 		}
+		applyDefaultKind(moreVideos, KindVideo)
 		videos = append(videos, moreVideos...)
 		continuationToken = nextToken
 	}
@@ -529,14 +586,92 @@ func (c *Client) GetVideoMetadataContext(ctx context.Context, videoID string) (*
 
 	// Check playability status
 	if resp.PlayabilityStatus != nil && resp.PlayabilityStatus.Status != "OK" {
-		reason := resp.PlayabilityStatus.Reason
-		if reason == "" {
-			reason = resp.PlayabilityStatus.Status
+		return nil, c.playabilityError(ctx, resp.PlayabilityStatus, "")
+	}
+
+	metadata := parseVideoMetadataFromPlayerResponse(&resp)
+
+	// Chapters come from the description when it has them; otherwise the watch page
+	// may still list chapters in an engagement panel. That lookup is best-effort.
+	if len(metadata.Chapters) == 0 {
+		if chapters, err := c.getChaptersFromWatchPage(ctx, videoID, metadata.Duration); err == nil {
+			metadata.Chapters = chapters
+		}
+	}
+
+	return metadata, nil
+}
+
+// GetVideoAvailabilityContext asks the player endpoint whether a video can still be
+// watched. Unlike GetVideoMetadataContext, a video that is not playable is not an error;
+// its status and reason are returned instead.
+func (c *Client) GetVideoAvailabilityContext(ctx context.Context, videoID string) (*Availability, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	videoID = extractVideoID(videoID)
+	if videoID == "" {
+		return nil, fmt.Errorf("invalid video ID")
+	}
+
+	req := PlayerRequest{
+		Context: c.createContext(),
+		VideoID: videoID,
+	}
+
+	data, err := c.doRequest(ctx, playerEndpoint, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch video availability: %w", err)
+	}
+
+	var resp PlayerResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse player response: %w", err)
+	}
+
+	return parseAvailability(&resp), nil
+}
+
+// parseAvailability extracts the playability status of a player response. A response
+// without one is taken as playable when it has video details.
+func parseAvailability(resp *PlayerResponse) *Availability {
+	status := resp.PlayabilityStatus
+	if status == nil {
+		if resp.VideoDetails != nil {
+			return &Availability{Playable: true, Status: "OK"}
 		}
-		return nil, fmt.Errorf("video not playable: %s", reason)
+		return &Availability{Status: "ERROR", Reason: "Video unavailable"}
 	}
 
-	return parseVideoMetadataFromPlayerResponse(&resp), nil
+	availability := &Availability{
+		Playable: status.Status == "OK",
+		Status:   status.Status,
+		Reason:   status.Reason,
+	}
+	if !availability.Playable && availability.Reason == "" {
+		availability.Reason = status.Status
+	}
+	return availability
+}
+
+// getChaptersFromWatchPage fetches the chapters listed in the watch page's engagement panels
+func (c *Client) getChaptersFromWatchPage(ctx context.Context, videoID string, duration int) ([]Chapter, error) {
+	req := NextRequest{
+		Context: c.createContext(),
+		VideoID: videoID,
+	}
+
+	data, err := c.doRequest(ctx, nextEndpoint, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch watch page: %w", err)
+	}
+
+	var resp NextResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse next response: %w", err)
+	}
+
+	return parseEngagementPanelChapters(&resp, duration), nil
 }
 
 // GetStreamURL gets the best stream URL for a video
@@ -577,11 +712,7 @@ func (c *Client) GetStreamURLContext(ctx context.Context, videoID string) (*Stre
 	}
 
 	if resp.PlayabilityStatus != nil && resp.PlayabilityStatus.Status != "OK" {
-		reason := resp.PlayabilityStatus.Reason
-		if reason == "" {
-			reason = resp.PlayabilityStatus.Status
-		}
-		return nil, fmt.Errorf("video not playable: %s", reason)
+		return nil, c.playabilityError(ctx, resp.PlayabilityStatus, "")
 	}
 
 	streamInfo, err = parseStreamInfoFromPlayerResponse(&resp)
@@ -691,8 +822,12 @@ func (c *Client) getStreamURLWithAndroidClient(ctx context.Context, videoID stri
 		httpReq.Header.Set("X-Goog-Api-Format-Version", "2")
 
 		// Add cookies if available
-		if c.cookieHeader != "" {
-			httpReq.Header.Set("Cookie", c.cookieHeader)
+		if cookieHeader := c.currentCookieHeader(); cookieHeader != "" {
+			httpReq.Header.Set("Cookie", cookieHeader)
+		}
+
+		if err := c.wait(ctx, ratelimit.BudgetPlayer); err != nil {
+			return nil, err
 		}
 
 		resp, err := c.httpClient.Do(httpReq)
@@ -702,9 +837,12 @@ func (c *Client) getStreamURLWithAndroidClient(ctx context.Context, videoID stri
 		}
 
 		if resp.StatusCode != http.StatusOK {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				c.backoff(ctx, ratelimit.BudgetPlayer)
+			}
 			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			lastErr = fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+			lastErr = &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 			continue
 		}
 
@@ -722,11 +860,7 @@ func (c *Client) getStreamURLWithAndroidClient(ctx context.Context, videoID stri
 		}
 
 		if playerResp.PlayabilityStatus != nil && playerResp.PlayabilityStatus.Status != "OK" {
-			reason := playerResp.PlayabilityStatus.Reason
-			if reason == "" {
-				reason = playerResp.PlayabilityStatus.Status
-			}
-			lastErr = fmt.Errorf("video not playable with %s: %s", cfg.ClientName, reason)
+			lastErr = c.playabilityError(ctx, playerResp.PlayabilityStatus, cfg.ClientName)
 			continue
 		}
 
@@ -784,6 +918,22 @@ func (c *Client) GetVideoListPaginated(channelID string, pageSize int, pageToken
 
 // GetVideoListPaginatedContext fetches videos with pagination support and context
 func (c *Client) GetVideoListPaginatedContext(ctx context.Context, channelID string, pageSize int, pageToken string) ([]Video, string, error) {
+	return c.GetTabVideoListPaginatedContext(ctx, channelID, TabVideos, pageSize, pageToken)
+}
+
+// GetTabVideoListPaginated fetches videos from a specific channel tab with pagination support
+func (c *Client) GetTabVideoListPaginated(channelID string, tab ChannelTab, pageSize int, pageToken string) ([]Video, string, error) {
+	return c.GetTabVideoListPaginatedContext(context.Background(), channelID, tab, pageSize, pageToken)
+}
+
+// GetTabVideoListPaginatedContext fetches videos from a specific channel tab with pagination support and context.
+// Every returned video has its Kind set; videos the renderer does not classify get the tab's default kind.
+func (c *Client) GetTabVideoListPaginatedContext(ctx context.Context, channelID string, tab ChannelTab, pageSize int, pageToken string) ([]Video, string, error) {
+	params, defaultKind, err := tab.browseParams()
+	if err != nil {
+		return nil, "", err
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
@@ -791,7 +941,7 @@ func (c *Client) GetVideoListPaginatedContext(ctx context.Context, channelID str
 	var nextToken string
 
 	if pageToken == "" {
-		// First page - resolve channel and fetch videos tab
+		// First page - resolve channel and fetch the tab
 		resolvedID, err := c.resolveChannelID(ctx, channelID)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to resolve channel: %w", err)
@@ -800,7 +950,7 @@ func (c *Client) GetVideoListPaginatedContext(ctx context.Context, channelID str
 		req := BrowseRequest{
 			Context:  c.createContext(),
 			BrowseID: resolvedID,
-			Params:   "EgZ2aWRlb3PyBgQKAjoA",
+			Params:   params,
 		}
 
 		data, err := c.doRequest(ctx, browseEndpoint, req)
@@ -836,6 +986,8 @@ func (c *Client) GetVideoListPaginatedContext(ctx context.Context, channelID str
 		nextToken = extractContinuationTokenFromActions(&resp)
 	}
 
+	applyDefaultKind(videos, defaultKind)
+
 	// Limit to pageSize if needed
 	if pageSize > 0 && len(videos) > pageSize {
 		videos = videos[:pageSize]
@@ -844,6 +996,128 @@ func (c *Client) GetVideoListPaginatedContext(ctx context.Context, channelID str
 	return videos, nextToken, nil
 }
 
+// browseParams returns the browse params selecting the tab (sorted newest first)
+// and the kind of the videos it lists
+func (t ChannelTab) browseParams() (params string, defaultKind string, err error) {
+	switch t {
+	case TabVideos:
+		return "EgZ2aWRlb3PyBgQKAjoA", KindVideo, nil
+	case TabShorts:
+		return "EgZzaG9ydHPyBgUKA5oBAA%3D%3D", KindShort, nil
+	case TabStreams:
+		return "EgdzdHJlYW1z8gYECgJ6AA%3D%3D", KindLive, nil
+	}
+	return "", "", fmt.Errorf("unknown channel tab: %q", t)
+}
+
+// GetPlaylist fetches playlist info and all of its entries in playlist order
+func (c *Client) GetPlaylist(playlistID string) (*Playlist, []PlaylistItem, error) {
+	return c.GetPlaylistContext(context.Background(), playlistID)
+}
+
+// GetPlaylistContext fetches playlist info and all of its entries in playlist order.
+// Each page is fetched with its own timeout so very long playlists can be enumerated.
+func (c *Client) GetPlaylistContext(ctx context.Context, playlistID string) (*Playlist, []PlaylistItem, error) {
+	playlist, items, nextToken, err := c.GetPlaylistPaginatedContext(ctx, playlistID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for nextToken != "" {
+		var moreItems []PlaylistItem
+		_, moreItems, nextToken, err = c.GetPlaylistPaginatedContext(ctx, playlistID, nextToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, moreItems...)
+	}
+
+	// Fill in positions the response did not render
+	for i := range items {
+		if items[i].Position == 0 {
+			items[i].Position = i + 1
+		}
+	}
+
+	return playlist, items, nil
+}
+
+// GetPlaylistPaginatedContext fetches a single page of playlist entries.
+// The playlist info is only returned for the first page (empty pageToken).
+func (c *Client) GetPlaylistPaginatedContext(ctx context.Context, playlistID string, pageToken string) (*Playlist, []PlaylistItem, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	playlistID = extractPlaylistID(playlistID)
+	if playlistID == "" {
+		return nil, nil, "", fmt.Errorf("invalid playlist ID")
+	}
+
+	if pageToken != "" {
+		req := BrowseRequest{
+			Context:      c.createContext(),
+			Continuation: pageToken,
+		}
+
+		data, err := c.doRequest(ctx, browseEndpoint, req)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to fetch playlist continuation: %w", err)
+		}
+
+		var resp BrowseResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to parse continuation response: %w", err)
+		}
+
+		return nil, parsePlaylistItemsFromContinuationResponse(&resp), extractContinuationTokenFromActions(&resp), nil
+	}
+
+	// Playlist pages are browsed with the "VL" prefix
+	req := BrowseRequest{
+		Context:  c.createContext(),
+		BrowseID: "VL" + playlistID,
+	}
+
+	data, err := c.doRequest(ctx, browseEndpoint, req)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+
+	var resp BrowseResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to parse browse response: %w", err)
+	}
+
+	playlist := parsePlaylistFromBrowseResponse(&resp, playlistID)
+	items, nextToken := parsePlaylistItemsFromBrowseResponse(&resp)
+	if playlist.Title == "" && len(items) == 0 {
+		return nil, nil, "", fmt.Errorf("playlist not found: %s", playlistID)
+	}
+
+	return playlist, items, nextToken, nil
+}
+
+// extractPlaylistID extracts a playlist ID from a raw ID, a "VL" browse ID or a URL
+func extractPlaylistID(input string) string {
+	input = strings.TrimSpace(input)
+
+	if strings.Contains(input, "list=") {
+		if parsed, err := url.Parse(input); err == nil {
+			if list := parsed.Query().Get("list"); list != "" {
+				input = list
+			}
+		}
+	}
+
+	input = strings.TrimPrefix(input, "VL")
+
+	playlistIDPattern := regexp.MustCompile(`^[\w-]{10,64}$`)
+	if !playlistIDPattern.MatchString(input) {
+		return ""
+	}
+	return input
+}
+
 // decodeSignatureCipher decodes a signature cipher URL
 func decodeSignatureCipher(cipher string) (string, string, string, error) {
 	params, err := url.ParseQuery(cipher)
@@ -977,11 +1251,7 @@ func (c *Client) GetCaptionsContext(ctx context.Context, videoID string) ([]Capt
 	}
 
 	if resp.PlayabilityStatus != nil && resp.PlayabilityStatus.Status != "OK" {
-		reason := resp.PlayabilityStatus.Reason
-		if reason == "" {
-			reason = resp.PlayabilityStatus.Status
-		}
-		return nil, fmt.Errorf("video not playable: %s", reason)
+		return nil, c.playabilityError(ctx, resp.PlayabilityStatus, "")
 	}
 
 	return parseCaptionsFromPlayerResponse(&resp), nil
@@ -1036,8 +1306,12 @@ func (c *Client) DownloadCaptionContext(ctx context.Context, captionURL string)
 	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Accept", "*/*")
 
-	if c.cookieHeader != "" {
-		req.Header.Set("Cookie", c.cookieHeader)
+	if cookieHeader := c.currentCookieHeader(); cookieHeader != "" {
+		req.Header.Set("Cookie", cookieHeader)
+	}
+
+	if err := c.wait(ctx, ratelimit.BudgetMedia); err != nil {
+		return nil, err
 	}
 
 	resp, err := c.httpClient.Do(req)
@@ -1046,6 +1320,9 @@ func (c *Client) DownloadCaptionContext(ctx context.Context, captionURL string)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		c.backoff(ctx, ratelimit.BudgetMedia)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("caption request failed with status %d", resp.StatusCode)
 	}
@@ -1,7 +1,21 @@
 package youtube
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/timholm/ytarchive/internal/ratelimit"
 )
 
 func TestNormalizeChannelURL(t *testing.T) {
@@ -313,6 +327,71 @@ func TestParseVideoList(t *testing.T) {
 	}
 }
 
+func TestParseVideoInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantChannel string
+		wantKind    string
+		wantErr     error
+	}{
+		{
+			name:        "video",
+			input:       `{"id": "abc123", "title": "Test", "channel_id": "UC1", "channel": "Test Channel", "ext": "mp4", "_filename": "Test [abc123].mp4"}`,
+			wantChannel: "UC1",
+			wantKind:    KindVideo,
+		},
+		{
+			name:        "uploader fallback",
+			input:       `{"id": "abc123", "uploader_id": "@test", "uploader": "Test Channel"}`,
+			wantChannel: "@test",
+			wantKind:    KindVideo,
+		},
+		{
+			name:        "short",
+			input:       `{"id": "abc123", "channel_id": "UC1", "webpage_url": "https://www.youtube.com/shorts/abc123"}`,
+			wantChannel: "UC1",
+			wantKind:    KindShort,
+		},
+		{
+			name:        "past live stream",
+			input:       `{"id": "abc123", "channel_id": "UC1", "live_status": "was_live"}`,
+			wantChannel: "UC1",
+			wantKind:    KindLive,
+		},
+		{
+			name:    "playlist",
+			input:   `{"_type": "playlist", "id": "UC1", "channel_id": "UC1"}`,
+			wantErr: ErrNotVideo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseVideoInfo([]byte(tt.input))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseVideoInfo() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVideoInfo() unexpected error: %v", err)
+			}
+			if info.Channel.ID != tt.wantChannel {
+				t.Errorf("ParseVideoInfo() Channel.ID = %q, want %q", info.Channel.ID, tt.wantChannel)
+			}
+			if info.Kind != tt.wantKind {
+				t.Errorf("ParseVideoInfo() Kind = %q, want %q", info.Kind, tt.wantKind)
+			}
+		})
+	}
+
+	if _, err := ParseVideoInfo([]byte(`{"id": "abc123"}`)); err == nil {
+		t.Error("ParseVideoInfo() accepted a video without a channel")
+	}
+}
+
 func TestParseVideoList_ThumbnailURL(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -466,6 +545,542 @@ func TestExtractVideoID(t *testing.T) {
 	}
 }
 
+func TestExtractPlaylistID(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "raw playlist ID",
+			input:    "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			expected: "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+		},
+		{
+			name:     "browse ID with VL prefix",
+			input:    "VLPLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			expected: "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+		},
+		{
+			name:     "playlist URL",
+			input:    "https://www.youtube.com/playlist?list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			expected: "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+		},
+		{
+			name:     "watch URL with list parameter",
+			input:    "https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+			expected: "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf",
+		},
+		{
+			name:     "invalid - too short",
+			input:    "PL123",
+			expected: "",
+		},
+		{
+			name:     "empty string",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := extractPlaylistID(tt.input)
+			if result != tt.expected {
+				t.Errorf("extractPlaylistID(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePlaylistBrowseResponse(t *testing.T) {
+	input := []byte(`{
+		"metadata": {"playlistMetadataRenderer": {"title": "Curated Talks", "description": "Talks from several channels"}},
+		"header": {"playlistHeaderRenderer": {
+			"playlistId": "PLtest1234567",
+			"ownerText": {"runs": [{"text": "Curator", "navigationEndpoint": {"browseEndpoint": {"browseId": "UCcuratorcuratorcurator1"}}}]},
+			"numVideosText": {"runs": [{"text": "2"}, {"text": " videos"}]}
+		}},
+		"contents": {"twoColumnBrowseResultsRenderer": {"tabs": [{"tabRenderer": {"content": {"sectionListRenderer": {"contents": [
+			{"itemSectionRenderer": {"contents": [{"playlistVideoListRenderer": {"contents": [
+				{"playlistVideoRenderer": {
+					"videoId": "aaaaaaaaaaa",
+					"title": {"runs": [{"text": "First Talk"}]},
+					"index": {"simpleText": "1"},
+					"lengthSeconds": "125",
+					"isPlayable": true,
+					"shortBylineText": {"runs": [{"text": "Channel A", "navigationEndpoint": {"browseEndpoint": {"browseId": "UCaaaaaaaaaaaaaaaaaaaaaa"}}}]}
+				}},
+				{"playlistVideoRenderer": {
+					"videoId": "bbbbbbbbbbb",
+					"title": {"runs": [{"text": "Second Talk"}]},
+					"index": {"simpleText": "2"},
+					"lengthText": {"simpleText": "1:02:03"},
+					"isPlayable": true,
+					"shortBylineText": {"runs": [{"text": "Channel B", "navigationEndpoint": {"browseEndpoint": {"browseId": "UCbbbbbbbbbbbbbbbbbbbbbb"}}}]}
+				}},
+				{"continuationItemRenderer": {"continuationEndpoint": {"continuationCommand": {"token": "next-page"}}}}
+			]}}]}}
+		]}}}}]}}
+	}`)
+
+	var resp BrowseResponse
+	if err := json.Unmarshal(input, &resp); err != nil {
+		t.Fatalf("failed to unmarshal fixture: %v", err)
+	}
+
+	playlist := parsePlaylistFromBrowseResponse(&resp, "PLtest1234567")
+	if playlist.Title != "Curated Talks" {
+		t.Errorf("Title = %q, want %q", playlist.Title, "Curated Talks")
+	}
+	if playlist.OwnerID != "UCcuratorcuratorcurator1" {
+		t.Errorf("OwnerID = %q, want %q", playlist.OwnerID, "UCcuratorcuratorcurator1")
+	}
+	if playlist.VideoCount != 2 {
+		t.Errorf("VideoCount = %d, want 2", playlist.VideoCount)
+	}
+
+	items, token := parsePlaylistItemsFromBrowseResponse(&resp)
+	if token != "next-page" {
+		t.Errorf("continuation token = %q, want %q", token, "next-page")
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	want := []struct {
+		id        string
+		position  int
+		duration  int
+		channelID string
+	}{
+		{"aaaaaaaaaaa", 1, 125, "UCaaaaaaaaaaaaaaaaaaaaaa"},
+		{"bbbbbbbbbbb", 2, 3723, "UCbbbbbbbbbbbbbbbbbbbbbb"},
+	}
+	for i, w := range want {
+		if items[i].ID != w.id {
+			t.Errorf("items[%d].ID = %q, want %q", i, items[i].ID, w.id)
+		}
+		if items[i].Position != w.position {
+			t.Errorf("items[%d].Position = %d, want %d", i, items[i].Position, w.position)
+		}
+		if items[i].Duration != w.duration {
+			t.Errorf("items[%d].Duration = %d, want %d", i, items[i].Duration, w.duration)
+		}
+		if items[i].ChannelID != w.channelID {
+			t.Errorf("items[%d].ChannelID = %q, want %q", i, items[i].ChannelID, w.channelID)
+		}
+	}
+}
+
+func TestParseDescriptionChapters(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		duration    int
+		want        []Chapter
+	}{
+		{
+			name:        "timestamps before titles",
+			description: "My video\n\n0:00 Intro\n1:30 - Setup\n12:05 Results\n\nThanks for watching",
+			duration:    900,
+			want: []Chapter{
+				{Title: "Intro", StartTime: 0, EndTime: 90},
+				{Title: "Setup", StartTime: 90, EndTime: 725},
+				{Title: "Results", StartTime: 725, EndTime: 900},
+			},
+		},
+		{
+			name:        "timestamps after titles with hours",
+			description: "Intro (00:00)\nPart one [0:10:00]\nPart two 1:00:00",
+			duration:    4000,
+			want: []Chapter{
+				{Title: "Intro", StartTime: 0, EndTime: 600},
+				{Title: "Part one", StartTime: 600, EndTime: 3600},
+				{Title: "Part two", StartTime: 3600, EndTime: 4000},
+			},
+		},
+		{
+			name:        "timestamps in prose before the list are ignored",
+			description: "Skip to 4:20 for the good part\n0:00 Start\n1:00 Middle\n2:00 End",
+			want: []Chapter{
+				{Title: "Start", StartTime: 0, EndTime: 60},
+				{Title: "Middle", StartTime: 60, EndTime: 120},
+				{Title: "End", StartTime: 120},
+			},
+		},
+		{
+			name:        "list stops when times go backwards",
+			description: "0:00 A\n0:30 B\n1:00 C\n0:45 not a chapter",
+			duration:    120,
+			want: []Chapter{
+				{Title: "A", StartTime: 0, EndTime: 30},
+				{Title: "B", StartTime: 30, EndTime: 60},
+				{Title: "C", StartTime: 60, EndTime: 120},
+			},
+		},
+		{
+			name:        "too few timestamps",
+			description: "0:00 Intro\n5:00 Outro",
+			duration:    600,
+		},
+		{
+			name:        "first chapter not at zero",
+			description: "0:10 A\n0:20 B\n0:30 C",
+			duration:    60,
+		},
+		{
+			name:        "invalid seconds",
+			description: "0:00 A\n0:75 B\n1:30 C",
+			duration:    120,
+		},
+		{
+			name:        "no description",
+			description: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDescriptionChapters(tt.description, tt.duration)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDescriptionChapters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEngagementPanelChapters(t *testing.T) {
+	input := []byte(`{"engagementPanels": [
+		{"engagementPanelSectionListRenderer": {"targetId": "engagement-panel-structured-description"}},
+		{"engagementPanelSectionListRenderer": {
+			"targetId": "engagement-panel-macro-markers-auto-chapters",
+			"content": {"macroMarkersListRenderer": {"contents": [
+				{"macroMarkersListItemRenderer": {"title": {"simpleText": "Auto 1"}, "onTap": {"watchEndpoint": {"videoId": "vid", "startTimeSeconds": 0}}}},
+				{"macroMarkersListItemRenderer": {"title": {"simpleText": "Auto 2"}, "onTap": {"watchEndpoint": {"videoId": "vid", "startTimeSeconds": 50}}}}
+			]}}
+		}},
+		{"engagementPanelSectionListRenderer": {
+			"targetId": "engagement-panel-macro-markers-description-chapters",
+			"content": {"macroMarkersListRenderer": {"contents": [
+				{"macroMarkersListItemRenderer": {"title": {"simpleText": "Intro"}, "onTap": {"watchEndpoint": {"videoId": "vid"}}}},
+				{"macroMarkersListItemRenderer": {"title": {"runs": [{"text": "Main "}, {"text": "part"}]}, "onTap": {"watchEndpoint": {"videoId": "vid", "startTimeSeconds": 42}}}}
+			]}}
+		}}
+	]}`)
+
+	var resp NextResponse
+	if err := json.Unmarshal(input, &resp); err != nil {
+		t.Fatalf("failed to unmarshal fixture: %v", err)
+	}
+
+	want := []Chapter{
+		{Title: "Intro", StartTime: 0, EndTime: 42},
+		{Title: "Main part", StartTime: 42, EndTime: 100},
+	}
+	if got := parseEngagementPanelChapters(&resp, 100); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEngagementPanelChapters() = %+v, want %+v", got, want)
+	}
+
+	// Without creator chapters the automatic ones are used
+	resp.EngagementPanels = resp.EngagementPanels[:2]
+	want = []Chapter{
+		{Title: "Auto 1", StartTime: 0, EndTime: 50},
+		{Title: "Auto 2", StartTime: 50, EndTime: 100},
+	}
+	if got := parseEngagementPanelChapters(&resp, 100); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEngagementPanelChapters() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseAvailability(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Availability
+	}{
+		{
+			name:  "playable",
+			input: `{"playabilityStatus": {"status": "OK"}, "videoDetails": {"videoId": "vid"}}`,
+			want:  Availability{Playable: true, Status: "OK"},
+		},
+		{
+			name:  "private",
+			input: `{"playabilityStatus": {"status": "LOGIN_REQUIRED", "reason": "This video is private"}}`,
+			want:  Availability{Status: "LOGIN_REQUIRED", Reason: "This video is private"},
+		},
+		{
+			name:  "deleted",
+			input: `{"playabilityStatus": {"status": "ERROR", "reason": "This video has been removed by the uploader"}}`,
+			want:  Availability{Status: "ERROR", Reason: "This video has been removed by the uploader"},
+		},
+		{
+			name:  "status without reason",
+			input: `{"playabilityStatus": {"status": "UNPLAYABLE"}}`,
+			want:  Availability{Status: "UNPLAYABLE", Reason: "UNPLAYABLE"},
+		},
+		{
+			name:  "no status or details",
+			input: `{}`,
+			want:  Availability{Status: "ERROR", Reason: "Video unavailable"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp PlayerResponse
+			if err := json.Unmarshal([]byte(tt.input), &resp); err != nil {
+				t.Fatalf("failed to unmarshal fixture: %v", err)
+			}
+			if got := parseAvailability(&resp); *got != tt.want {
+				t.Errorf("parseAvailability() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// fixtureTransport answers innertube requests with JSON from tests/testdata,
+// chosen by the request's video ID or continuation token
+type fixtureTransport struct {
+	t        *testing.T
+	fixtures map[string]string
+}
+
+func (ft *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body struct {
+		VideoID      string `json:"videoId"`
+		Continuation string `json:"continuation"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		ft.t.Fatalf("failed to decode request body: %v", err)
+	}
+
+	key := body.VideoID
+	if key == "" {
+		key = body.Continuation
+	}
+	name, ok := ft.fixtures[key]
+	if !ok {
+		ft.t.Fatalf("unexpected request to %s for %q", req.URL.Path, key)
+	}
+	data, err := os.ReadFile(filepath.Join("..", "..", "tests", "testdata", name))
+	if err != nil {
+		ft.t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+func newFixtureClient(t *testing.T, fixtures map[string]string) *Client {
+	client, err := NewClient(
+		WithHTTPClient(&http.Client{Transport: &fixtureTransport{t: t, fixtures: fixtures}}),
+		WithRateLimit(1000, 100),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestGetComments(t *testing.T) {
+	client := newFixtureClient(t, map[string]string{
+		"dQw4w9WgXcQ":     "next_watch.json",
+		"comments-page-1": "next_comments.json",
+		"comments-page-2": "next_comments_more.json",
+		"replies-c1":      "next_replies.json",
+	})
+
+	comments, err := client.GetComments("dQw4w9WgXcQ", 0)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+
+	want := []Comment{
+		{ID: "c1", AuthorName: "@alice", AuthorChannelID: "UCalice", Text: "Great video, thanks!", LikeCount: 1200, ReplyCount: 2, PublishedText: "2 years ago"},
+		{ID: "c1.r1", ParentID: "c1", AuthorName: "@dave", AuthorChannelID: "UCdave", Text: "Agreed", LikeCount: 3, PublishedText: "2 years ago"},
+		{ID: "c1.r2", ParentID: "c1", AuthorName: "@erin", AuthorChannelID: "UCerin", Text: "@alice same here", PublishedText: "1 year ago"},
+		{ID: "c2", AuthorName: "@bob", AuthorChannelID: "UCbob", Text: "Where was this filmed?", LikeCount: 7, PublishedText: "1 year ago (edited)"},
+		{ID: "c3", AuthorName: "@carol", Text: "First!", PublishedText: "3 years ago"},
+	}
+	if !reflect.DeepEqual(comments, want) {
+		t.Errorf("GetComments() =\n%+v\nwant\n%+v", comments, want)
+	}
+
+	// The limit counts replies and stops paging
+	comments, err = client.GetComments("dQw4w9WgXcQ", 2)
+	if err != nil {
+		t.Fatalf("GetComments() with limit error = %v", err)
+	}
+	if len(comments) != 2 || comments[1].ID != "c1.r1" {
+		t.Errorf("GetComments() with limit = %+v, want c1 and its first reply", comments)
+	}
+}
+
+func TestGetLiveChatReplay(t *testing.T) {
+	client := newFixtureClient(t, map[string]string{
+		"dQw4w9WgXcQ": "next_watch.json",
+		"chat-all":    "live_chat_replay.json",
+		"chat-page-2": "live_chat_replay_end.json",
+	})
+
+	messages, err := client.GetLiveChatReplay("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatalf("GetLiveChatReplay() error = %v", err)
+	}
+
+	want := []ChatMessage{
+		{ID: "m1", AuthorName: "viewer1", AuthorChannelID: "UCviewer1", Message: "hello 👋", OffsetMs: 1500},
+		{ID: "m2", AuthorName: "viewer2", AuthorChannelID: "UCviewer2", Message: "great stream :yt:", OffsetMs: 61000, Amount: "$5.00"},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("GetLiveChatReplay() = %+v, want %+v", messages, want)
+	}
+}
+
+func TestExtractLiveChatReplayContinuation(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "no chat",
+			input: `{"contents": {"twoColumnWatchNextResults": {}}}`,
+			want:  "",
+		},
+		{
+			name:  "live stream in progress",
+			input: `{"contents": {"twoColumnWatchNextResults": {"conversationBar": {"liveChatRenderer": {"continuations": [{"reloadContinuationData": {"continuation": "live"}}]}}}}}`,
+			want:  "",
+		},
+		{
+			name:  "replay without view selector",
+			input: `{"contents": {"twoColumnWatchNextResults": {"conversationBar": {"liveChatRenderer": {"isReplay": true, "continuations": [{"reloadContinuationData": {"continuation": "top"}}]}}}}}`,
+			want:  "top",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp NextResponse
+			if err := json.Unmarshal([]byte(tt.input), &resp); err != nil {
+				t.Fatalf("failed to unmarshal fixture: %v", err)
+			}
+			if got := extractLiveChatReplayContinuation(&resp); got != tt.want {
+				t.Errorf("extractLiveChatReplayContinuation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	client := newFixtureClient(t, map[string]string{"noReplay123": "next_comments_more.json"})
+	if _, err := client.GetLiveChatReplay("noReplay123"); !errors.Is(err, ErrNoChatReplay) {
+		t.Errorf("GetLiveChatReplay() error = %v, want ErrNoChatReplay", err)
+	}
+}
+
+func TestClassifyVideoRenderer(t *testing.T) {
+	tests := []struct {
+		name         string
+		renderer     VideoRenderer
+		wantKind     string
+		wantUpcoming bool
+	}{
+		{
+			name:     "regular upload",
+			renderer: VideoRenderer{VideoID: "a", PublishedTimeText: SimpleText{SimpleText: "2 days ago"}},
+			wantKind: "",
+		},
+		{
+			name:     "finished stream",
+			renderer: VideoRenderer{VideoID: "b", PublishedTimeText: SimpleText{SimpleText: "Streamed 3 weeks ago"}},
+			wantKind: KindLive,
+		},
+		{
+			name:     "finished premiere",
+			renderer: VideoRenderer{VideoID: "c", PublishedTimeText: SimpleText{SimpleText: "Premiered 1 month ago"}},
+			wantKind: KindPremiere,
+		},
+		{
+			name: "scheduled premiere",
+			renderer: VideoRenderer{
+				VideoID:           "d",
+				UpcomingEventData: &UpcomingEventData{StartTime: "1700000000", UpcomingEventText: SimpleText{SimpleText: "Premieres DATE_PLACEHOLDER"}},
+			},
+			wantKind:     KindPremiere,
+			wantUpcoming: true,
+		},
+		{
+			name: "live now",
+			renderer: VideoRenderer{
+				VideoID:           "e",
+				ThumbnailOverlays: []ThumbnailOverlay{{ThumbnailOverlayTimeStatusRenderer: &ThumbnailOverlayTimeStatusRenderer{Style: "LIVE"}}},
+			},
+			wantKind:     KindLive,
+			wantUpcoming: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, upcoming := classifyVideoRenderer(&tt.renderer)
+			if kind != tt.wantKind {
+				t.Errorf("classifyVideoRenderer() kind = %q, want %q", kind, tt.wantKind)
+			}
+			if upcoming != tt.wantUpcoming {
+				t.Errorf("classifyVideoRenderer() upcoming = %v, want %v", upcoming, tt.wantUpcoming)
+			}
+		})
+	}
+}
+
+func TestTabsForKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		kinds    []string
+		expected []ChannelTab
+	}{
+		{
+			name:     "default kinds only need the videos tab",
+			kinds:    DefaultKinds,
+			expected: []ChannelTab{TabVideos},
+		},
+		{
+			name:     "all kinds",
+			kinds:    []string{KindVideo, KindShort, KindLive, KindPremiere},
+			expected: []ChannelTab{TabVideos, TabShorts, TabStreams},
+		},
+		{
+			name:     "shorts only",
+			kinds:    []string{KindShort},
+			expected: []ChannelTab{TabShorts},
+		},
+		{
+			name:     "unknown kinds are ignored",
+			kinds:    []string{"clip"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TabsForKinds(tt.kinds)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("TabsForKinds(%v) = %v, want %v", tt.kinds, result, tt.expected)
+			}
+			for i := range result {
+				if result[i] != tt.expected[i] {
+					t.Errorf("TabsForKinds(%v)[%d] = %q, want %q", tt.kinds, i, result[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
 func TestParseDuration(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -702,3 +1317,222 @@ func searchString(s, substr string) bool {
 	}
 	return false
 }
+
+func TestIsCredentialError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "forbidden",
+			err:  fmt.Errorf("failed to fetch stream info: %w", &StatusError{StatusCode: http.StatusForbidden}),
+			want: true,
+		},
+		{
+			name: "not found",
+			err:  &StatusError{StatusCode: http.StatusNotFound},
+			want: false,
+		},
+		{
+			name: "bot check",
+			err:  newPlayabilityError(&PlayabilityStatus{Status: "LOGIN_REQUIRED", Reason: "Sign in to confirm you’re not a bot"}, ""),
+			want: true,
+		},
+		{
+			name: "age gate",
+			err:  newPlayabilityError(&PlayabilityStatus{Status: "LOGIN_REQUIRED", Reason: "Sign in to confirm your age"}, "ANDROID"),
+			want: true,
+		},
+		{
+			name: "private video",
+			err:  newPlayabilityError(&PlayabilityStatus{Status: "LOGIN_REQUIRED", Reason: "This video is private"}, ""),
+			want: false,
+		},
+		{
+			name: "other error",
+			err:  errors.New("request failed: connection reset"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCredentialError(tt.err); got != tt.want {
+				t.Errorf("IsCredentialError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayabilityErrorMessage(t *testing.T) {
+	err := newPlayabilityError(&PlayabilityStatus{Status: "UNPLAYABLE"}, "")
+	if got := err.Error(); got != "video not playable: UNPLAYABLE" {
+		t.Errorf("Error() = %q", got)
+	}
+	err = newPlayabilityError(&PlayabilityStatus{Status: "ERROR", Reason: "Video unavailable"}, "ANDROID")
+	if got := err.Error(); got != "video not playable with ANDROID: Video unavailable" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+// backoffRecorder is a rate limiter that records backoffs
+type backoffRecorder struct {
+	backoffs []ratelimit.Budget
+}
+
+func (r *backoffRecorder) Wait(context.Context, ratelimit.Budget) error { return nil }
+
+func (r *backoffRecorder) Backoff(_ context.Context, budget ratelimit.Budget) {
+	r.backoffs = append(r.backoffs, budget)
+}
+
+func TestPlayabilityErrorBotCheck(t *testing.T) {
+	limiter := &backoffRecorder{}
+	client, err := NewClient(WithRateLimiter(limiter))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	ctx := context.Background()
+
+	playErr := client.playabilityError(ctx, &PlayabilityStatus{Status: "LOGIN_REQUIRED", Reason: "Sign in to confirm your age"}, "")
+	if playErr.IsBotCheck() || len(limiter.backoffs) != 0 {
+		t.Errorf("age gate should not back off, got %v", limiter.backoffs)
+	}
+
+	playErr = client.playabilityError(ctx, &PlayabilityStatus{Status: "LOGIN_REQUIRED", Reason: "Sign in to confirm you’re not a bot"}, "")
+	if !playErr.IsBotCheck() {
+		t.Error("IsBotCheck() = false, want true")
+	}
+	if !reflect.DeepEqual(limiter.backoffs, []ratelimit.Budget{ratelimit.BudgetPlayer}) {
+		t.Errorf("backoffs = %v, want the player budget", limiter.backoffs)
+	}
+}
+
+func TestEndpointBudget(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     ratelimit.Budget
+	}{
+		{endpoint: playerEndpoint, want: ratelimit.BudgetPlayer},
+		{endpoint: "https://www.youtube.com/youtubei/v1/browse", want: ratelimit.BudgetBrowse},
+		{endpoint: "https://www.youtube.com/youtubei/v1/next", want: ratelimit.BudgetBrowse},
+		{endpoint: "https://www.youtube.com/youtubei/v1/navigation/resolve_url", want: ratelimit.BudgetBrowse},
+	}
+
+	for _, tt := range tests {
+		if got := endpointBudget(extractEndpointName(tt.endpoint)); got != tt.want {
+			t.Errorf("endpointBudget(%s) = %s, want %s", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestSapisidHash(t *testing.T) {
+	got := sapisidHash("abc/def", "https://www.youtube.com", time.Unix(1700000000, 0))
+	// sha1("1700000000 abc/def https://www.youtube.com")
+	want := "1700000000_747622f274182ecf105054645d6a0093199ab03d"
+	if got != want {
+		t.Errorf("sapisidHash() = %q, want %q", got, want)
+	}
+}
+
+// accountMenuTransport answers account menu requests with a fixed status and body
+type accountMenuTransport struct {
+	status int
+	body   string
+	auth   string
+}
+
+func (at *accountMenuTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	at.auth = req.Header.Get("Authorization")
+	return &http.Response{
+		StatusCode: at.status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(at.body))),
+		Request:    req,
+	}, nil
+}
+
+func TestCheckLogin(t *testing.T) {
+	signedIn := []*http.Cookie{
+		{Name: "SAPISID", Value: "abc", Domain: ".youtube.com"},
+		{Name: "SID", Value: "xyz", Domain: ".youtube.com"},
+	}
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		status  int
+		body    string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "signed in",
+			cookies: signedIn,
+			status:  http.StatusOK,
+			body:    `{"actions":[{"openPopupAction":{"popup":{"multiPageMenuRenderer":{"header":{"activeAccountHeaderRenderer":{"accountName":{"simpleText":"Archive"}}}}}}}]}`,
+			want:    true,
+		},
+		{
+			name:    "signed out",
+			cookies: signedIn,
+			status:  http.StatusOK,
+			body:    `{"actions":[{"openPopupAction":{"popup":{"multiPageMenuRenderer":{"sections":[]}}}}]}`,
+			want:    false,
+		},
+		{
+			name:    "rejected",
+			cookies: signedIn,
+			status:  http.StatusUnauthorized,
+			want:    false,
+		},
+		{
+			name:    "server error",
+			cookies: signedIn,
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+		{
+			name:    "no SAPISID",
+			cookies: []*http.Cookie{{Name: "YSC", Value: "v", Domain: ".youtube.com"}},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &accountMenuTransport{status: tt.status, body: tt.body}
+			client, err := NewClient(
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithRateLimit(1000, 100),
+				WithCookies(tt.cookies),
+			)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			got, err := client.CheckLogin(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CheckLogin() = %v, want %v", got, tt.want)
+			}
+			if tt.status != 0 && !strings.HasPrefix(transport.auth, "SAPISIDHASH ") {
+				t.Errorf("Authorization = %q, want a SAPISIDHASH", transport.auth)
+			}
+		})
+	}
+}
+
+func TestSetCookies(t *testing.T) {
+	client, err := NewClient(WithCookies([]*http.Cookie{{Name: "SID", Value: "old", Domain: ".youtube.com"}}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetCookies([]*http.Cookie{{Name: "SID", Value: "new", Domain: ".youtube.com"}})
+	if got := client.currentCookieHeader(); got != "SID=new" {
+		t.Errorf("cookie header = %q, want SID=new", got)
+	}
+}
@@ -0,0 +1,418 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// liveChatReplayEndpoint serves the pages of a past livestream's chat
+const liveChatReplayEndpoint = "https://www.youtube.com/youtubei/v1/live_chat/get_live_chat_replay"
+
+// commentsSectionID identifies the comments section of the watch page
+const commentsSectionID = "comment-item-section"
+
+// ErrNoChatReplay is returned for videos without a live chat replay, such as
+// uploads and streams whose chat was disabled
+var ErrNoChatReplay = errors.New("video has no live chat replay")
+
+// commentThread is a top-level comment with the token that loads its replies
+type commentThread struct {
+	Comment
+	repliesToken string
+}
+
+// GetComments fetches the comments of a video and their replies
+func (c *Client) GetComments(videoID string, maxComments int) ([]Comment, error) {
+	return c.GetCommentsContext(context.Background(), videoID, maxComments)
+}
+
+// GetCommentsContext pages through the top comments of a video, fetching the
+// replies of each thread after it. Replies follow the comment they answer.
+// maxComments limits the comments and replies fetched; 0 fetches all of them.
+// Videos with comments turned off have none. If a page fails, the comments
+// fetched so far are returned with the error.
+func (c *Client) GetCommentsContext(ctx context.Context, videoID string, maxComments int) ([]Comment, error) {
+	videoID = extractVideoID(videoID)
+	if videoID == "" {
+		return nil, fmt.Errorf("invalid video ID")
+	}
+
+	resp, err := c.fetchNext(ctx, NextRequest{Context: c.createContext(), VideoID: videoID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch watch page: %w", err)
+	}
+
+	var comments []Comment
+	seen := make(map[string]bool)
+	add := func(comment Comment) bool {
+		if comment.ID == "" || seen[comment.ID] {
+			return true
+		}
+		seen[comment.ID] = true
+		comments = append(comments, comment)
+		return maxComments <= 0 || len(comments) < maxComments
+	}
+
+	token := extractCommentsContinuation(resp)
+	for token != "" {
+		page, err := c.fetchNext(ctx, NextRequest{Context: c.createContext(), Continuation: token})
+		if err != nil {
+			return comments, fmt.Errorf("failed to fetch comments page: %w", err)
+		}
+
+		threads, nextToken := parseCommentPage(page, "")
+		for _, thread := range threads {
+			if !add(thread.Comment) {
+				return comments, nil
+			}
+
+			repliesToken := thread.repliesToken
+			for repliesToken != "" {
+				repliesPage, err := c.fetchNext(ctx, NextRequest{Context: c.createContext(), Continuation: repliesToken})
+				if err != nil {
+					return comments, fmt.Errorf("failed to fetch replies of comment %s: %w", thread.ID, err)
+				}
+
+				replies, nextRepliesToken := parseCommentPage(repliesPage, thread.ID)
+				for _, reply := range replies {
+					if !add(reply.Comment) {
+						return comments, nil
+					}
+				}
+				if nextRepliesToken == repliesToken {
+					break
+				}
+				repliesToken = nextRepliesToken
+			}
+		}
+
+		if nextToken == token {
+			break
+		}
+		token = nextToken
+	}
+
+	return comments, nil
+}
+
+// GetLiveChatReplay fetches the chat replay of a past livestream
+func (c *Client) GetLiveChatReplay(videoID string) ([]ChatMessage, error) {
+	return c.GetLiveChatReplayContext(context.Background(), videoID)
+}
+
+// GetLiveChatReplayContext pages through the chat replay of a past livestream,
+// returning its messages and Super Chats in the order they were shown.
+// Returns ErrNoChatReplay if the video has no replay. If a page fails, the
+// messages fetched so far are returned with the error.
+func (c *Client) GetLiveChatReplayContext(ctx context.Context, videoID string) ([]ChatMessage, error) {
+	videoID = extractVideoID(videoID)
+	if videoID == "" {
+		return nil, fmt.Errorf("invalid video ID")
+	}
+
+	resp, err := c.fetchNext(ctx, NextRequest{Context: c.createContext(), VideoID: videoID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch watch page: %w", err)
+	}
+
+	token := extractLiveChatReplayContinuation(resp)
+	if token == "" {
+		return nil, ErrNoChatReplay
+	}
+
+	var messages []ChatMessage
+	seen := make(map[string]bool)
+	for token != "" {
+		page, err := c.fetchLiveChatReplay(ctx, token)
+		if err != nil {
+			return messages, fmt.Errorf("failed to fetch chat replay page: %w", err)
+		}
+
+		pageMessages, nextToken := parseLiveChatReplayPage(page)
+		for _, message := range pageMessages {
+			if seen[message.ID] {
+				continue
+			}
+			seen[message.ID] = true
+			messages = append(messages, message)
+		}
+
+		if nextToken == token {
+			break
+		}
+		token = nextToken
+	}
+
+	return messages, nil
+}
+
+// fetchNext requests a page from the /next endpoint. Each page gets its own
+// timeout so videos with many comments can be paged through.
+func (c *Client) fetchNext(ctx context.Context, req NextRequest) (*NextResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	data, err := c.doRequest(ctx, nextEndpoint, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp NextResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse next response: %w", err)
+	}
+	return &resp, nil
+}
+
+// fetchLiveChatReplay requests a page of a chat replay
+func (c *Client) fetchLiveChatReplay(ctx context.Context, token string) (*LiveChatReplayResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req := LiveChatReplayRequest{
+		Context:            c.createContext(),
+		Continuation:       token,
+		CurrentPlayerState: CurrentPlayerState{PlayerOffsetMs: "0"},
+	}
+
+	data, err := c.doRequest(ctx, liveChatReplayEndpoint, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp LiveChatReplayResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse chat replay response: %w", err)
+	}
+	return &resp, nil
+}
+
+// extractCommentsContinuation returns the token that loads the first page of
+// comments from a watch page, or "" if comments are turned off
+func extractCommentsContinuation(resp *NextResponse) string {
+	if resp.Contents == nil || resp.Contents.TwoColumnWatchNextResults == nil {
+		return ""
+	}
+
+	for _, content := range resp.Contents.TwoColumnWatchNextResults.Results.Results.Contents {
+		section := content.ItemSectionRenderer
+		if section == nil || section.SectionIdentifier != commentsSectionID {
+			continue
+		}
+		for _, item := range section.Contents {
+			if token := continuationItemToken(item.ContinuationItemRenderer); token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// extractLiveChatReplayContinuation returns the token that opens the chat
+// replay of a watch page, preferring the view with every message over top
+// chat. Returns "" if the video has no replay.
+func extractLiveChatReplayContinuation(resp *NextResponse) string {
+	if resp.Contents == nil || resp.Contents.TwoColumnWatchNextResults == nil {
+		return ""
+	}
+	bar := resp.Contents.TwoColumnWatchNextResults.ConversationBar
+	if bar == nil || bar.LiveChatRenderer == nil || !bar.LiveChatRenderer.IsReplay {
+		return ""
+	}
+	chat := bar.LiveChatRenderer
+
+	// The views are listed top chat first
+	items := chat.Header.LiveChatHeaderRenderer.ViewSelector.SortFilterSubMenuRenderer.SubMenuItems
+	for i := len(items) - 1; i >= 0; i-- {
+		if data := items[i].Continuation.ReloadContinuationData; data != nil && data.Continuation != "" {
+			return data.Continuation
+		}
+	}
+
+	for _, cont := range chat.Continuations {
+		if cont.ReloadContinuationData != nil && cont.ReloadContinuationData.Continuation != "" {
+			return cont.ReloadContinuationData.Continuation
+		}
+	}
+	return ""
+}
+
+// parseCommentPage extracts the comments of a page of comments or replies and
+// the token of the next page. Comments on a replies page get parentID.
+func parseCommentPage(resp *NextResponse, parentID string) ([]commentThread, string) {
+	entities := make(map[string]*CommentEntityPayload)
+	if resp.FrameworkUpdates != nil {
+		for _, mutation := range resp.FrameworkUpdates.EntityBatchUpdate.Mutations {
+			if payload := mutation.Payload.CommentEntityPayload; payload != nil {
+				entities[payload.Properties.CommentID] = payload
+			}
+		}
+	}
+
+	var threads []commentThread
+	var nextToken string
+	for _, action := range resp.OnResponseReceivedEndpoints {
+		var items []CommentItem
+		if action.ReloadContinuationItemsCommand != nil {
+			items = append(items, action.ReloadContinuationItemsCommand.ContinuationItems...)
+		}
+		if action.AppendContinuationItemsAction != nil {
+			items = append(items, action.AppendContinuationItemsAction.ContinuationItems...)
+		}
+
+		for _, item := range items {
+			var thread commentThread
+			switch {
+			case item.CommentThreadRenderer != nil:
+				renderer := item.CommentThreadRenderer
+				if renderer.Comment != nil && renderer.Comment.CommentRenderer != nil {
+					thread.Comment = parseCommentRenderer(renderer.Comment.CommentRenderer)
+				} else if renderer.CommentViewModel != nil && renderer.CommentViewModel.CommentViewModel != nil {
+					thread.Comment = parseCommentEntity(entities[renderer.CommentViewModel.CommentViewModel.CommentID])
+				}
+				if renderer.Replies != nil && renderer.Replies.CommentRepliesRenderer != nil {
+					for _, reply := range renderer.Replies.CommentRepliesRenderer.Contents {
+						if token := continuationItemToken(reply.ContinuationItemRenderer); token != "" {
+							thread.repliesToken = token
+							break
+						}
+					}
+				}
+			case item.CommentRenderer != nil:
+				thread.Comment = parseCommentRenderer(item.CommentRenderer)
+			case item.CommentViewModel != nil:
+				thread.Comment = parseCommentEntity(entities[item.CommentViewModel.CommentID])
+			case item.ContinuationItemRenderer != nil:
+				nextToken = continuationItemToken(item.ContinuationItemRenderer)
+				continue
+			default:
+				// Headers and other non-comment items
+				continue
+			}
+
+			if thread.ID == "" {
+				continue
+			}
+			thread.ParentID = parentID
+			threads = append(threads, thread)
+		}
+	}
+
+	return threads, nextToken
+}
+
+// parseCommentRenderer converts an inline comment
+func parseCommentRenderer(cr *CommentRenderer) Comment {
+	comment := Comment{
+		ID:            cr.CommentID,
+		AuthorName:    strings.TrimSpace(cr.AuthorText.GetText()),
+		Text:          cr.ContentText.GetText(),
+		LikeCount:     parseViewCount(cr.VoteCount.GetText()),
+		ReplyCount:    cr.ReplyCount,
+		PublishedText: cr.PublishedTimeText.GetText(),
+	}
+	if cr.AuthorEndpoint != nil && cr.AuthorEndpoint.BrowseEndpoint != nil {
+		comment.AuthorChannelID = cr.AuthorEndpoint.BrowseEndpoint.BrowseID
+	}
+	return comment
+}
+
+// parseCommentEntity converts a comment entity; a missing entity yields a
+// comment without an ID, which is skipped
+func parseCommentEntity(payload *CommentEntityPayload) Comment {
+	if payload == nil {
+		return Comment{}
+	}
+	return Comment{
+		ID:              payload.Properties.CommentID,
+		AuthorName:      strings.TrimSpace(payload.Author.DisplayName),
+		AuthorChannelID: payload.Author.ChannelID,
+		Text:            payload.Properties.Content.GetText(),
+		LikeCount:       parseViewCount(payload.Toolbar.LikeCountNotliked),
+		ReplyCount:      int(parseViewCount(payload.Toolbar.ReplyCount)),
+		PublishedText:   payload.Properties.PublishedTime,
+	}
+}
+
+// continuationItemToken returns the token of a continuation item, which is
+// either loaded directly or through a button
+func continuationItemToken(item *CommentContinuationItemRenderer) string {
+	if item == nil {
+		return ""
+	}
+	if item.ContinuationEndpoint != nil && item.ContinuationEndpoint.ContinuationCommand.Token != "" {
+		return item.ContinuationEndpoint.ContinuationCommand.Token
+	}
+	if item.Button != nil {
+		return item.Button.ButtonRenderer.Command.ContinuationCommand.Token
+	}
+	return ""
+}
+
+// parseLiveChatReplayPage extracts the messages of a chat replay page and the
+// token of the next page, which is "" after the last page
+func parseLiveChatReplayPage(resp *LiveChatReplayResponse) ([]ChatMessage, string) {
+	chat := resp.ContinuationContents.LiveChatContinuation
+
+	var messages []ChatMessage
+	for _, action := range chat.Actions {
+		replay := action.ReplayChatItemAction
+		if replay == nil {
+			continue
+		}
+		offset, _ := strconv.ParseInt(replay.VideoOffsetTimeMsec, 10, 64)
+
+		for _, chatAction := range replay.Actions {
+			if chatAction.AddChatItemAction == nil {
+				continue
+			}
+			item := chatAction.AddChatItemAction.Item
+			renderer := item.LiveChatTextMessageRenderer
+			if renderer == nil {
+				renderer = item.LiveChatPaidMessageRenderer
+			}
+			if renderer == nil || renderer.ID == "" {
+				continue
+			}
+
+			messages = append(messages, ChatMessage{
+				ID:              renderer.ID,
+				AuthorName:      strings.TrimSpace(renderer.AuthorName.GetText()),
+				AuthorChannelID: renderer.AuthorExternalChannelID,
+				Message:         renderer.Message.GetText(),
+				OffsetMs:        offset,
+				Amount:          renderer.PurchaseAmountText.GetText(),
+			})
+		}
+	}
+
+	var nextToken string
+	for _, cont := range chat.Continuations {
+		if data := cont.LiveChatReplayContinuationData; data != nil && data.Continuation != "" {
+			nextToken = data.Continuation
+			break
+		}
+	}
+	return messages, nextToken
+}
+
+// GetText joins the runs of a chat message, writing emoji as their character
+// or, for custom emoji, their shortcut
+func (m LiveChatMessage) GetText() string {
+	var text strings.Builder
+	for _, run := range m.Runs {
+		switch {
+		case run.Emoji == nil:
+			text.WriteString(run.Text)
+		case run.Emoji.IsCustomEmoji && len(run.Emoji.Shortcuts) > 0:
+			text.WriteString(run.Emoji.Shortcuts[0])
+		default:
+			text.WriteString(run.Emoji.EmojiID)
+		}
+	}
+	return text.String()
+}
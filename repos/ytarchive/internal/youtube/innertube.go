@@ -54,6 +54,7 @@ type BrowseResponse struct {
 type ChannelHeader struct {
 	C4TabbedHeaderRenderer *C4TabbedHeaderRenderer `json:"c4TabbedHeaderRenderer,omitempty"`
 	PageHeaderRenderer     *PageHeaderRenderer     `json:"pageHeaderRenderer,omitempty"`
+	PlaylistHeaderRenderer *PlaylistHeaderRenderer `json:"playlistHeaderRenderer,omitempty"`
 }
 
 // C4TabbedHeaderRenderer is the channel header format
@@ -148,7 +149,8 @@ type TextRuns struct {
 
 // ChannelMetadata contains channel metadata
 type ChannelMetadata struct {
-	ChannelMetadataRenderer *ChannelMetadataRenderer `json:"channelMetadataRenderer,omitempty"`
+	ChannelMetadataRenderer  *ChannelMetadataRenderer  `json:"channelMetadataRenderer,omitempty"`
+	PlaylistMetadataRenderer *PlaylistMetadataRenderer `json:"playlistMetadataRenderer,omitempty"`
 }
 
 // ChannelMetadataRenderer contains channel metadata details
@@ -161,6 +163,22 @@ type ChannelMetadataRenderer struct {
 	Avatar           ThumbnailList `json:"avatar,omitempty"`
 }
 
+// PlaylistHeaderRenderer is the playlist page header
+type PlaylistHeaderRenderer struct {
+	PlaylistID      string        `json:"playlistId"`
+	Title           SimpleText    `json:"title,omitempty"`
+	DescriptionText SimpleText    `json:"descriptionText,omitempty"`
+	OwnerText       SimpleText    `json:"ownerText,omitempty"`
+	NumVideosText   SimpleText    `json:"numVideosText,omitempty"`
+	Thumbnail       ThumbnailList `json:"thumbnail,omitempty"`
+}
+
+// PlaylistMetadataRenderer contains playlist metadata details
+type PlaylistMetadataRenderer struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
 // BrowseContents contains the main content of a browse response
 type BrowseContents struct {
 	TwoColumnBrowseResultsRenderer *TwoColumnBrowseResultsRenderer `json:"twoColumnBrowseResultsRenderer,omitempty"`
@@ -220,6 +238,7 @@ type ChipCloudChipRenderer struct {
 // RichGridContent is a single item in a rich grid
 type RichGridContent struct {
 	RichItemRenderer         *RichItemRenderer         `json:"richItemRenderer,omitempty"`
+	PlaylistVideoRenderer    *PlaylistVideoRenderer    `json:"playlistVideoRenderer,omitempty"`
 	ContinuationItemRenderer *ContinuationItemRenderer `json:"continuationItemRenderer,omitempty"`
 }
 
@@ -265,13 +284,32 @@ type ReelItemRenderer struct {
 
 // VideoRenderer contains video information
 type VideoRenderer struct {
-	VideoID            string        `json:"videoId"`
-	Title              TextRuns      `json:"title,omitempty"`
-	DescriptionSnippet TextRuns      `json:"descriptionSnippet,omitempty"`
-	LengthText         SimpleText    `json:"lengthText,omitempty"`
-	ViewCountText      SimpleText    `json:"viewCountText,omitempty"`
-	PublishedTimeText  SimpleText    `json:"publishedTimeText,omitempty"`
-	Thumbnail          ThumbnailList `json:"thumbnail,omitempty"`
+	VideoID            string             `json:"videoId"`
+	Title              TextRuns           `json:"title,omitempty"`
+	DescriptionSnippet TextRuns           `json:"descriptionSnippet,omitempty"`
+	LengthText         SimpleText         `json:"lengthText,omitempty"`
+	ViewCountText      SimpleText         `json:"viewCountText,omitempty"`
+	PublishedTimeText  SimpleText         `json:"publishedTimeText,omitempty"`
+	Thumbnail          ThumbnailList      `json:"thumbnail,omitempty"`
+	UpcomingEventData  *UpcomingEventData `json:"upcomingEventData,omitempty"`
+	ThumbnailOverlays  []ThumbnailOverlay `json:"thumbnailOverlays,omitempty"`
+}
+
+// UpcomingEventData is present on scheduled premieres and live streams
+type UpcomingEventData struct {
+	StartTime         string     `json:"startTime"`
+	UpcomingEventText SimpleText `json:"upcomingEventText,omitempty"`
+}
+
+// ThumbnailOverlay is a badge drawn over a video thumbnail
+type ThumbnailOverlay struct {
+	ThumbnailOverlayTimeStatusRenderer *ThumbnailOverlayTimeStatusRenderer `json:"thumbnailOverlayTimeStatusRenderer,omitempty"`
+}
+
+// ThumbnailOverlayTimeStatusRenderer shows the duration, or LIVE/UPCOMING for streams
+type ThumbnailOverlayTimeStatusRenderer struct {
+	Text  SimpleText `json:"text,omitempty"`
+	Style string     `json:"style,omitempty"` // DEFAULT, LIVE, UPCOMING, SHORTS
 }
 
 // SectionListRenderer renders a list of sections
@@ -292,7 +330,26 @@ type ItemSectionRenderer struct {
 
 // ItemContent is a single item within a section
 type ItemContent struct {
-	GridRenderer *GridRenderer `json:"gridRenderer,omitempty"`
+	GridRenderer              *GridRenderer              `json:"gridRenderer,omitempty"`
+	PlaylistVideoListRenderer *PlaylistVideoListRenderer `json:"playlistVideoListRenderer,omitempty"`
+}
+
+// PlaylistVideoListRenderer renders the ordered list of videos in a playlist
+type PlaylistVideoListRenderer struct {
+	PlaylistID string            `json:"playlistId"`
+	Contents   []RichGridContent `json:"contents,omitempty"`
+}
+
+// PlaylistVideoRenderer contains information about a single playlist entry
+type PlaylistVideoRenderer struct {
+	VideoID         string        `json:"videoId"`
+	Title           TextRuns      `json:"title,omitempty"`
+	Index           SimpleText    `json:"index,omitempty"`
+	ShortBylineText SimpleText    `json:"shortBylineText,omitempty"`
+	LengthSeconds   string        `json:"lengthSeconds,omitempty"`
+	LengthText      SimpleText    `json:"lengthText,omitempty"`
+	Thumbnail       ThumbnailList `json:"thumbnail,omitempty"`
+	IsPlayable      bool          `json:"isPlayable"`
 }
 
 // GridRenderer renders a grid of items
@@ -376,7 +433,8 @@ type SimpleText struct {
 
 // TextRun is a single text run
 type TextRun struct {
-	Text string `json:"text"`
+	Text               string    `json:"text"`
+	NavigationEndpoint *Endpoint `json:"navigationEndpoint,omitempty"`
 }
 
 // ThumbnailList contains a list of thumbnails
@@ -507,6 +565,360 @@ type ResolvedEndpoint struct {
 	BrowseEndpoint *BrowseEndpoint `json:"browseEndpoint,omitempty"`
 }
 
+// NextRequest is the request body for the /next endpoint. Comment pages are
+// requested with a continuation token instead of a video ID.
+type NextRequest struct {
+	Context      InnertubeContext `json:"context"`
+	VideoID      string           `json:"videoId,omitempty"`
+	Continuation string           `json:"continuation,omitempty"`
+}
+
+// NextResponse is the response from the /next endpoint (watch page data)
+type NextResponse struct {
+	Contents                    *WatchNextContents          `json:"contents,omitempty"`
+	EngagementPanels            []EngagementPanel           `json:"engagementPanels,omitempty"`
+	OnResponseReceivedEndpoints []CommentContinuationAction `json:"onResponseReceivedEndpoints,omitempty"`
+	FrameworkUpdates            *FrameworkUpdates           `json:"frameworkUpdates,omitempty"`
+}
+
+// EngagementPanel is a side panel on the watch page
+type EngagementPanel struct {
+	EngagementPanelSectionListRenderer *EngagementPanelSectionListRenderer `json:"engagementPanelSectionListRenderer,omitempty"`
+}
+
+// EngagementPanelSectionListRenderer contains a panel's identifier and contents
+type EngagementPanelSectionListRenderer struct {
+	TargetID string                 `json:"targetId,omitempty"`
+	PanelID  string                 `json:"panelIdentifier,omitempty"`
+	Content  EngagementPanelContent `json:"content,omitempty"`
+}
+
+// EngagementPanelContent contains the content of an engagement panel
+type EngagementPanelContent struct {
+	MacroMarkersListRenderer *MacroMarkersListRenderer `json:"macroMarkersListRenderer,omitempty"`
+}
+
+// MacroMarkersListRenderer is the chapter list shown in the chapters panel
+type MacroMarkersListRenderer struct {
+	Contents []MacroMarkersListContent `json:"contents,omitempty"`
+}
+
+// MacroMarkersListContent is a single entry in a chapter list
+type MacroMarkersListContent struct {
+	MacroMarkersListItemRenderer *MacroMarkersListItemRenderer `json:"macroMarkersListItemRenderer,omitempty"`
+}
+
+// MacroMarkersListItemRenderer is a single chapter
+type MacroMarkersListItemRenderer struct {
+	Title SimpleText     `json:"title,omitempty"`
+	OnTap MacroMarkerTap `json:"onTap,omitempty"`
+}
+
+// MacroMarkerTap contains the seek target of a chapter
+type MacroMarkerTap struct {
+	WatchEndpoint *WatchEndpoint `json:"watchEndpoint,omitempty"`
+}
+
+// WatchEndpoint contains a video ID and start offset
+type WatchEndpoint struct {
+	VideoID          string `json:"videoId,omitempty"`
+	StartTimeSeconds int    `json:"startTimeSeconds,omitempty"`
+}
+
+// WatchNextContents contains the main contents of the watch page
+type WatchNextContents struct {
+	TwoColumnWatchNextResults *TwoColumnWatchNextResults `json:"twoColumnWatchNextResults,omitempty"`
+}
+
+// TwoColumnWatchNextResults contains the watch page's results column and its
+// live chat bar
+type TwoColumnWatchNextResults struct {
+	Results         WatchNextResults `json:"results,omitempty"`
+	ConversationBar *ConversationBar `json:"conversationBar,omitempty"`
+}
+
+// WatchNextResults wraps the sections below the player
+type WatchNextResults struct {
+	Results WatchNextResultList `json:"results,omitempty"`
+}
+
+// WatchNextResultList contains the sections below the player
+type WatchNextResultList struct {
+	Contents []WatchNextContent `json:"contents,omitempty"`
+}
+
+// WatchNextContent is a section below the player
+type WatchNextContent struct {
+	ItemSectionRenderer *CommentSectionRenderer `json:"itemSectionRenderer,omitempty"`
+}
+
+// CommentSectionRenderer is an item section; the comments section holds the
+// continuation that loads the first page of comments
+type CommentSectionRenderer struct {
+	SectionIdentifier string        `json:"sectionIdentifier,omitempty"`
+	Contents          []CommentItem `json:"contents,omitempty"`
+}
+
+// CommentContinuationAction contains a page of comments or replies
+type CommentContinuationAction struct {
+	AppendContinuationItemsAction  *CommentContinuationItems `json:"appendContinuationItemsAction,omitempty"`
+	ReloadContinuationItemsCommand *CommentContinuationItems `json:"reloadContinuationItemsCommand,omitempty"`
+}
+
+// CommentContinuationItems contains the items of a comment page
+type CommentContinuationItems struct {
+	ContinuationItems []CommentItem `json:"continuationItems,omitempty"`
+}
+
+// CommentItem is an entry in a comment page: a thread, a reply, or the
+// continuation that loads the next page
+type CommentItem struct {
+	CommentThreadRenderer    *CommentThreadRenderer           `json:"commentThreadRenderer,omitempty"`
+	CommentRenderer          *CommentRenderer                 `json:"commentRenderer,omitempty"`
+	CommentViewModel         *CommentViewModel                `json:"commentViewModel,omitempty"`
+	ContinuationItemRenderer *CommentContinuationItemRenderer `json:"continuationItemRenderer,omitempty"`
+}
+
+// CommentThreadRenderer is a top-level comment and its replies. Older
+// responses render the comment inline; newer ones reference an entity in the
+// framework updates through a view model.
+type CommentThreadRenderer struct {
+	Comment          *CommentRendererWrapper  `json:"comment,omitempty"`
+	CommentViewModel *CommentViewModelWrapper `json:"commentViewModel,omitempty"`
+	Replies          *CommentReplies          `json:"replies,omitempty"`
+}
+
+// CommentRendererWrapper wraps an inline comment
+type CommentRendererWrapper struct {
+	CommentRenderer *CommentRenderer `json:"commentRenderer,omitempty"`
+}
+
+// CommentViewModelWrapper wraps a comment view model
+type CommentViewModelWrapper struct {
+	CommentViewModel *CommentViewModel `json:"commentViewModel,omitempty"`
+}
+
+// CommentRenderer is a comment rendered inline
+type CommentRenderer struct {
+	CommentID         string     `json:"commentId"`
+	AuthorText        SimpleText `json:"authorText,omitempty"`
+	AuthorEndpoint    *Endpoint  `json:"authorEndpoint,omitempty"`
+	ContentText       SimpleText `json:"contentText,omitempty"`
+	PublishedTimeText SimpleText `json:"publishedTimeText,omitempty"`
+	VoteCount         SimpleText `json:"voteCount,omitempty"`
+	ReplyCount        int        `json:"replyCount,omitempty"`
+}
+
+// CommentViewModel references a comment entity by its ID
+type CommentViewModel struct {
+	CommentID string `json:"commentId"`
+}
+
+// CommentReplies wraps the replies of a thread
+type CommentReplies struct {
+	CommentRepliesRenderer *CommentRepliesRenderer `json:"commentRepliesRenderer,omitempty"`
+}
+
+// CommentRepliesRenderer holds the continuation that loads a thread's replies
+type CommentRepliesRenderer struct {
+	Contents []CommentItem `json:"contents,omitempty"`
+}
+
+// CommentContinuationItemRenderer loads the next page of comments or replies,
+// either directly or through a "Show more replies" button
+type CommentContinuationItemRenderer struct {
+	ContinuationEndpoint *ContinuationEndpoint `json:"continuationEndpoint,omitempty"`
+	Button               *ContinuationButton   `json:"button,omitempty"`
+}
+
+// ContinuationButton is a button that loads more items
+type ContinuationButton struct {
+	ButtonRenderer ContinuationButtonRenderer `json:"buttonRenderer,omitempty"`
+}
+
+// ContinuationButtonRenderer contains the continuation command of a button
+type ContinuationButtonRenderer struct {
+	Command ContinuationEndpoint `json:"command,omitempty"`
+}
+
+// FrameworkUpdates contains the entities referenced by view models
+type FrameworkUpdates struct {
+	EntityBatchUpdate EntityBatchUpdate `json:"entityBatchUpdate,omitempty"`
+}
+
+// EntityBatchUpdate contains entity mutations
+type EntityBatchUpdate struct {
+	Mutations []EntityMutation `json:"mutations,omitempty"`
+}
+
+// EntityMutation sets the payload of an entity
+type EntityMutation struct {
+	EntityKey string        `json:"entityKey"`
+	Payload   EntityPayload `json:"payload,omitempty"`
+}
+
+// EntityPayload is the payload of an entity mutation
+type EntityPayload struct {
+	CommentEntityPayload *CommentEntityPayload `json:"commentEntityPayload,omitempty"`
+}
+
+// CommentEntityPayload is a comment referenced by a CommentViewModel
+type CommentEntityPayload struct {
+	Properties CommentEntityProperties `json:"properties"`
+	Author     CommentEntityAuthor     `json:"author,omitempty"`
+	Toolbar    CommentEntityToolbar    `json:"toolbar,omitempty"`
+}
+
+// CommentEntityProperties contains a comment's ID, text and age
+type CommentEntityProperties struct {
+	CommentID     string   `json:"commentId"`
+	PublishedTime string   `json:"publishedTime,omitempty"`
+	Content       TextRuns `json:"content,omitempty"`
+}
+
+// CommentEntityAuthor is the author of a comment entity
+type CommentEntityAuthor struct {
+	ChannelID   string `json:"channelId,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// CommentEntityToolbar contains a comment's like and reply counts as display text
+type CommentEntityToolbar struct {
+	LikeCountNotliked string `json:"likeCountNotliked,omitempty"`
+	ReplyCount        string `json:"replyCount,omitempty"`
+}
+
+// ConversationBar holds the live chat of a livestream or its replay
+type ConversationBar struct {
+	LiveChatRenderer *LiveChatRenderer `json:"liveChatRenderer,omitempty"`
+}
+
+// LiveChatRenderer contains the continuations that open the chat. The header's
+// view selector offers "Top chat replay" and "Live chat replay"; the latter
+// has every message.
+type LiveChatRenderer struct {
+	Continuations []Continuation `json:"continuations,omitempty"`
+	Header        LiveChatHeader `json:"header,omitempty"`
+	IsReplay      bool           `json:"isReplay,omitempty"`
+}
+
+// LiveChatHeader wraps the chat header
+type LiveChatHeader struct {
+	LiveChatHeaderRenderer LiveChatHeaderRenderer `json:"liveChatHeaderRenderer,omitempty"`
+}
+
+// LiveChatHeaderRenderer contains the chat's view selector
+type LiveChatHeaderRenderer struct {
+	ViewSelector LiveChatViewSelector `json:"viewSelector,omitempty"`
+}
+
+// LiveChatViewSelector wraps the chat view menu
+type LiveChatViewSelector struct {
+	SortFilterSubMenuRenderer SortFilterSubMenuRenderer `json:"sortFilterSubMenuRenderer,omitempty"`
+}
+
+// SortFilterSubMenuRenderer lists the chat views
+type SortFilterSubMenuRenderer struct {
+	SubMenuItems []SubMenuItem `json:"subMenuItems,omitempty"`
+}
+
+// SubMenuItem is a chat view and the continuation that opens it
+type SubMenuItem struct {
+	Title        string       `json:"title,omitempty"`
+	Continuation Continuation `json:"continuation,omitempty"`
+}
+
+// LiveChatReplayRequest is the request body for the live chat replay endpoint
+type LiveChatReplayRequest struct {
+	Context            InnertubeContext   `json:"context"`
+	Continuation       string             `json:"continuation"`
+	CurrentPlayerState CurrentPlayerState `json:"currentPlayerState"`
+}
+
+// CurrentPlayerState is the playback position the chat replay is requested for
+type CurrentPlayerState struct {
+	PlayerOffsetMs string `json:"playerOffsetMs"`
+}
+
+// LiveChatReplayResponse is a page of a live chat replay
+type LiveChatReplayResponse struct {
+	ContinuationContents LiveChatContinuationContents `json:"continuationContents,omitempty"`
+}
+
+// LiveChatContinuationContents wraps a live chat replay page
+type LiveChatContinuationContents struct {
+	LiveChatContinuation LiveChatContinuation `json:"liveChatContinuation,omitempty"`
+}
+
+// LiveChatContinuation contains the actions of a replay page and the token of
+// the next one
+type LiveChatContinuation struct {
+	Continuations []LiveChatReplayContinuation `json:"continuations,omitempty"`
+	Actions       []LiveChatReplayAction       `json:"actions,omitempty"`
+}
+
+// LiveChatReplayContinuation holds the token of the next replay page. The last
+// page only has a seek continuation.
+type LiveChatReplayContinuation struct {
+	LiveChatReplayContinuationData *NextContinuationData `json:"liveChatReplayContinuationData,omitempty"`
+	PlayerSeekContinuationData     *NextContinuationData `json:"playerSeekContinuationData,omitempty"`
+}
+
+// LiveChatReplayAction wraps the chat actions replayed at an offset
+type LiveChatReplayAction struct {
+	ReplayChatItemAction *ReplayChatItemAction `json:"replayChatItemAction,omitempty"`
+}
+
+// ReplayChatItemAction replays chat actions at an offset into the video
+type ReplayChatItemAction struct {
+	Actions             []LiveChatAction `json:"actions,omitempty"`
+	VideoOffsetTimeMsec string           `json:"videoOffsetTimeMsec,omitempty"`
+}
+
+// LiveChatAction is a chat action; only added items are archived
+type LiveChatAction struct {
+	AddChatItemAction *AddChatItemAction `json:"addChatItemAction,omitempty"`
+}
+
+// AddChatItemAction adds an item to the chat
+type AddChatItemAction struct {
+	Item LiveChatItem `json:"item"`
+}
+
+// LiveChatItem is a chat item; only messages and Super Chats are archived
+type LiveChatItem struct {
+	LiveChatTextMessageRenderer *LiveChatMessageRenderer `json:"liveChatTextMessageRenderer,omitempty"`
+	LiveChatPaidMessageRenderer *LiveChatMessageRenderer `json:"liveChatPaidMessageRenderer,omitempty"`
+}
+
+// LiveChatMessageRenderer is a chat message. Paid messages also have an amount.
+type LiveChatMessageRenderer struct {
+	ID                      string          `json:"id"`
+	Message                 LiveChatMessage `json:"message,omitempty"`
+	AuthorName              SimpleText      `json:"authorName,omitempty"`
+	AuthorExternalChannelID string          `json:"authorExternalChannelId,omitempty"`
+	PurchaseAmountText      SimpleText      `json:"purchaseAmountText,omitempty"`
+}
+
+// LiveChatMessage is the text of a chat message, made of text and emoji runs
+type LiveChatMessage struct {
+	Runs []LiveChatMessageRun `json:"runs,omitempty"`
+}
+
+// LiveChatMessageRun is a run of text or a single emoji
+type LiveChatMessageRun struct {
+	Text  string         `json:"text,omitempty"`
+	Emoji *LiveChatEmoji `json:"emoji,omitempty"`
+}
+
+// LiveChatEmoji is an emoji in a chat message. Standard emoji have the
+// character as their ID; custom ones are written with their shortcut.
+type LiveChatEmoji struct {
+	EmojiID       string   `json:"emojiId,omitempty"`
+	Shortcuts     []string `json:"shortcuts,omitempty"`
+	IsCustomEmoji bool     `json:"isCustomEmoji,omitempty"`
+}
+
 // GetText extracts text from SimpleText, handling both simpleText and runs formats
 func (st SimpleText) GetText() string {
 	if st.SimpleText != "" {
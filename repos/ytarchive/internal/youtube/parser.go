@@ -2,6 +2,7 @@ package youtube
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -236,6 +237,8 @@ func parseVideoRenderer(vr *VideoRenderer) *Video {
 		video.UploadDate = parseRelativeDate(publishedText)
 	}
 
+	video.Kind, video.Upcoming = classifyVideoRenderer(vr)
+
 	// Generate thumbnail if not provided
 	if video.ThumbnailURL == "" && video.ID != "" {
 		video.ThumbnailURL = fmt.Sprintf("https://i.ytimg.com/vi/%s/maxresdefault.jpg", video.ID)
@@ -244,6 +247,55 @@ func parseVideoRenderer(vr *VideoRenderer) *Video {
 	return video
 }
 
+// classifyVideoRenderer works out the kind of a VideoRenderer and whether it is
+// still upcoming or live. Finished streams read "Streamed 2 days ago" and
+// finished premieres read "Premiered 2 days ago". Anything else is left for
+// the caller to default from the tab it was listed on.
+func classifyVideoRenderer(vr *VideoRenderer) (kind string, upcoming bool) {
+	published := strings.ToLower(vr.PublishedTimeText.GetText())
+	switch {
+	case strings.HasPrefix(published, "streamed"):
+		kind = KindLive
+	case strings.HasPrefix(published, "premiered"):
+		kind = KindPremiere
+	}
+
+	if vr.UpcomingEventData != nil {
+		upcoming = true
+		if strings.HasPrefix(strings.ToLower(vr.UpcomingEventData.UpcomingEventText.GetText()), "premieres") {
+			kind = KindPremiere
+		}
+	}
+
+	for _, overlay := range vr.ThumbnailOverlays {
+		if overlay.ThumbnailOverlayTimeStatusRenderer == nil {
+			continue
+		}
+		switch overlay.ThumbnailOverlayTimeStatusRenderer.Style {
+		case "LIVE":
+			upcoming = true
+			if kind == "" {
+				kind = KindLive
+			}
+		case "UPCOMING":
+			upcoming = true
+		case "SHORTS":
+			kind = KindShort
+		}
+	}
+
+	return kind, upcoming
+}
+
+// applyDefaultKind sets kind on videos whose kind could not be determined from the renderer
+func applyDefaultKind(videos []Video, kind string) {
+	for i := range videos {
+		if videos[i].Kind == "" {
+			videos[i].Kind = kind
+		}
+	}
+}
+
 // parseReelItemRenderer extracts video from ReelItemRenderer (shorts)
 func parseReelItemRenderer(rr *ReelItemRenderer) *Video {
 	if rr == nil || rr.VideoID == "" {
@@ -255,6 +307,7 @@ func parseReelItemRenderer(rr *ReelItemRenderer) *Video {
 		Title:        rr.Headline.GetText(),
 		ThumbnailURL: rr.Thumbnail.GetBestThumbnail(),
 		Status:       StatusPending,
+		Kind:         KindShort,
 	}
 
 	// Generate thumbnail if not provided
@@ -294,6 +347,7 @@ func parseShortsLockupViewModel(slvm *ShortsLockupViewModel) *Video {
 		ID:           videoID,
 		ThumbnailURL: fmt.Sprintf("https://i.ytimg.com/vi/%s/maxresdefault.jpg", videoID),
 		Status:       StatusPending,
+		Kind:         KindShort,
 	}
 
 	return video
@@ -330,6 +384,153 @@ func parseGridVideoRenderer(gvr *GridVideoRenderer) *Video {
 	return video
 }
 
+// parsePlaylistFromBrowseResponse extracts playlist info from a "VL" browse response
+func parsePlaylistFromBrowseResponse(resp *BrowseResponse, playlistID string) *Playlist {
+	playlist := &Playlist{
+		ID: playlistID,
+	}
+
+	if resp.Metadata.PlaylistMetadataRenderer != nil {
+		playlist.Title = resp.Metadata.PlaylistMetadataRenderer.Title
+		playlist.Description = resp.Metadata.PlaylistMetadataRenderer.Description
+	}
+
+	if resp.Header.PlaylistHeaderRenderer != nil {
+		header := resp.Header.PlaylistHeaderRenderer
+
+		if playlist.Title == "" {
+			playlist.Title = header.Title.GetText()
+		}
+		if playlist.Description == "" {
+			playlist.Description = header.DescriptionText.GetText()
+		}
+		playlist.OwnerName = header.OwnerText.GetText()
+		playlist.OwnerID = browseIDFromRuns(header.OwnerText.Runs)
+		playlist.ThumbnailURL = header.Thumbnail.GetBestThumbnail()
+
+		// Parse video count from "X videos" text
+		if videosText := header.NumVideosText.GetText(); videosText != "" {
+			playlist.VideoCount = parseVideoCount(videosText)
+		}
+	}
+
+	return playlist
+}
+
+// parsePlaylistItemsFromBrowseResponse extracts playlist entries from the initial browse response
+func parsePlaylistItemsFromBrowseResponse(resp *BrowseResponse) ([]PlaylistItem, string) {
+	items := make([]PlaylistItem, 0)
+	var continuationToken string
+
+	if resp.Contents.TwoColumnBrowseResultsRenderer == nil {
+		return items, ""
+	}
+
+	for _, tab := range resp.Contents.TwoColumnBrowseResultsRenderer.Tabs {
+		if tab.TabRenderer == nil || tab.TabRenderer.Content.SectionListRenderer == nil {
+			continue
+		}
+
+		for _, section := range tab.TabRenderer.Content.SectionListRenderer.Contents {
+			if section.ItemSectionRenderer == nil {
+				continue
+			}
+
+			for _, item := range section.ItemSectionRenderer.Contents {
+				if item.PlaylistVideoListRenderer == nil {
+					continue
+				}
+
+				for _, content := range item.PlaylistVideoListRenderer.Contents {
+					if entry := parsePlaylistVideoRenderer(content.PlaylistVideoRenderer); entry != nil {
+						items = append(items, *entry)
+					}
+					if content.ContinuationItemRenderer != nil {
+						continuationToken = content.ContinuationItemRenderer.ContinuationEndpoint.ContinuationCommand.Token
+					}
+				}
+			}
+		}
+	}
+
+	return items, continuationToken
+}
+
+// parsePlaylistItemsFromContinuationResponse extracts playlist entries from a continuation response
+func parsePlaylistItemsFromContinuationResponse(resp *BrowseResponse) []PlaylistItem {
+	items := make([]PlaylistItem, 0)
+
+	for _, action := range resp.OnResponseReceivedActions {
+		if action.AppendContinuationItemsAction != nil {
+			for _, content := range action.AppendContinuationItemsAction.ContinuationItems {
+				if entry := parsePlaylistVideoRenderer(content.PlaylistVideoRenderer); entry != nil {
+					items = append(items, *entry)
+				}
+			}
+		}
+
+		if action.ReloadContinuationItemsCommand != nil {
+			for _, content := range action.ReloadContinuationItemsCommand.ContinuationItems {
+				if entry := parsePlaylistVideoRenderer(content.PlaylistVideoRenderer); entry != nil {
+					items = append(items, *entry)
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+// parsePlaylistVideoRenderer extracts a playlist entry from PlaylistVideoRenderer
+func parsePlaylistVideoRenderer(pvr *PlaylistVideoRenderer) *PlaylistItem {
+	if pvr == nil || pvr.VideoID == "" {
+		return nil
+	}
+
+	item := &PlaylistItem{
+		Video: Video{
+			ID:           pvr.VideoID,
+			Title:        extractTitle(pvr.Title),
+			ThumbnailURL: pvr.Thumbnail.GetBestThumbnail(),
+			Status:       StatusPending,
+		},
+		ChannelID:   browseIDFromRuns(pvr.ShortBylineText.Runs),
+		ChannelName: pvr.ShortBylineText.GetText(),
+		Playable:    pvr.IsPlayable,
+	}
+
+	// The index is 1-based and rendered as text
+	if index, err := strconv.Atoi(strings.TrimSpace(pvr.Index.GetText())); err == nil {
+		item.Position = index
+	}
+
+	// Parse duration, preferring the exact seconds value
+	if pvr.LengthSeconds != "" {
+		item.Duration, _ = strconv.Atoi(pvr.LengthSeconds)
+	} else if durationText := pvr.LengthText.GetText(); durationText != "" {
+		item.Duration = parseDuration(durationText)
+	}
+
+	// Generate thumbnail if not provided
+	if item.ThumbnailURL == "" {
+		item.ThumbnailURL = fmt.Sprintf("https://i.ytimg.com/vi/%s/maxresdefault.jpg", item.ID)
+	}
+
+	return item
+}
+
+// browseIDFromRuns returns the first channel browse ID linked from text runs
+func browseIDFromRuns(runs []TextRun) string {
+	for _, run := range runs {
+		if run.NavigationEndpoint != nil && run.NavigationEndpoint.BrowseEndpoint != nil {
+			if id := run.NavigationEndpoint.BrowseEndpoint.BrowseID; strings.HasPrefix(id, "UC") {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
 // extractTitle extracts title from TextRuns (handling both formats)
 func extractTitle(tr TextRuns) string {
 	return tr.GetText()
@@ -604,8 +805,12 @@ func parseVideoMetadataFromPlayerResponse(resp *PlayerResponse) *VideoMetadata {
 			}
 			metadata.Subtitles = append(metadata.Subtitles, lang)
 		}
+		metadata.Captions = parseCaptionsFromPlayerResponse(resp)
 	}
 
+	// Extract chapters from the description timestamps
+	metadata.Chapters = ParseDescriptionChapters(metadata.Description, metadata.Duration)
+
 	// Generate thumbnail if not provided
 	if metadata.ThumbnailURL == "" && metadata.ID != "" {
 		metadata.ThumbnailURL = fmt.Sprintf("https://i.ytimg.com/vi/%s/maxresdefault.jpg", metadata.ID)
@@ -700,8 +905,10 @@ type ytdlpVideoEntry struct {
 	} `json:"thumbnails"`
 }
 
-// ytdlpVideoMetadata represents the full JSON structure for a single video (legacy)
+// ytdlpVideoMetadata represents the full JSON structure for a single video,
+// as written to .info.json files by yt-dlp --write-info-json
 type ytdlpVideoMetadata struct {
+	Type        string  `json:"_type"`
 	ID          string  `json:"id"`
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
@@ -709,6 +916,17 @@ type ytdlpVideoMetadata struct {
 	UploadDate  string  `json:"upload_date"`
 	ViewCount   int64   `json:"view_count"`
 	Thumbnail   string  `json:"thumbnail"`
+	WebpageURL  string  `json:"webpage_url"`
+	LiveStatus  string  `json:"live_status"`
+	MediaType   string  `json:"media_type"`
+	Ext         string  `json:"ext"`
+	Filename    string  `json:"_filename"`
+	ChannelID   string  `json:"channel_id"`
+	Channel     string  `json:"channel"`
+	ChannelURL  string  `json:"channel_url"`
+	Uploader    string  `json:"uploader"`
+	UploaderID  string  `json:"uploader_id"`
+	UploaderURL string  `json:"uploader_url"`
 	Thumbnails  []struct {
 		URL string `json:"url"`
 	} `json:"thumbnails"`
@@ -734,6 +952,11 @@ type ytdlpVideoMetadata struct {
 		Height         int     `json:"height"`
 		Width          int     `json:"width"`
 	} `json:"formats"`
+	Chapters []struct {
+		Title     string  `json:"title"`
+		StartTime float64 `json:"start_time"`
+		EndTime   float64 `json:"end_time"`
+	} `json:"chapters"`
 }
 
 // ParseChannelJSON parses yt-dlp JSON output for channel information (legacy compatibility)
@@ -899,6 +1122,14 @@ func ParseVideoMetadata(data []byte) (*VideoMetadata, error) {
 		metadata.Subtitles = append(metadata.Subtitles, lang)
 	}
 
+	for _, chapter := range raw.Chapters {
+		metadata.Chapters = append(metadata.Chapters, Chapter{
+			Title:     chapter.Title,
+			StartTime: chapter.StartTime,
+			EndTime:   chapter.EndTime,
+		})
+	}
+
 	if metadata.Tags == nil {
 		metadata.Tags = []string{}
 	}
@@ -908,3 +1139,76 @@ func ParseVideoMetadata(data []byte) (*VideoMetadata, error) {
 
 	return metadata, nil
 }
+
+// ErrNotVideo is returned by ParseVideoInfo for info.json files that describe
+// a playlist or channel rather than a single video
+var ErrNotVideo = errors.New("info.json does not describe a video")
+
+// VideoInfo is a video described by a yt-dlp .info.json file, with the
+// channel that uploaded it
+type VideoInfo struct {
+	VideoMetadata
+	Channel Channel `json:"channel"`
+
+	// Ext and Filename are the extension and path of the media file yt-dlp
+	// wrote, as they were when the video was downloaded
+	Ext      string `json:"ext,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// ParseVideoInfo parses a yt-dlp .info.json file of a single video
+func ParseVideoInfo(data []byte) (*VideoInfo, error) {
+	var raw ytdlpVideoMetadata
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse video metadata JSON: %w", err)
+	}
+	if (raw.Type != "" && raw.Type != "video") || raw.ID == "" {
+		return nil, ErrNotVideo
+	}
+
+	metadata, err := ParseVideoMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Kind = ytdlpKind(raw.LiveStatus, raw.MediaType, raw.WebpageURL)
+
+	info := &VideoInfo{
+		VideoMetadata: *metadata,
+		Channel: Channel{
+			ID:   raw.ChannelID,
+			Name: raw.Channel,
+			URL:  raw.ChannelURL,
+		},
+		Ext:      raw.Ext,
+		Filename: raw.Filename,
+	}
+
+	// Use uploader info as fallback, as ParseChannelJSON does
+	if info.Channel.ID == "" {
+		info.Channel.ID = raw.UploaderID
+	}
+	if info.Channel.Name == "" {
+		info.Channel.Name = raw.Uploader
+	}
+	if info.Channel.URL == "" {
+		info.Channel.URL = raw.UploaderURL
+	}
+	if info.Channel.ID == "" {
+		return nil, fmt.Errorf("video %s has no channel", raw.ID)
+	}
+
+	return info, nil
+}
+
+// ytdlpKind returns the kind of a video from yt-dlp's live_status and
+// media_type fields, falling back to its URL for older info.json files
+func ytdlpKind(liveStatus, mediaType, webpageURL string) string {
+	switch {
+	case liveStatus == "was_live" || liveStatus == "post_live" || mediaType == "livestream":
+		return KindLive
+	case mediaType == "short" || strings.Contains(webpageURL, "/shorts/"):
+		return KindShort
+	default:
+		return KindVideo
+	}
+}
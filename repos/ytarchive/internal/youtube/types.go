@@ -24,6 +24,31 @@ type Video struct {
 	ThumbnailURL string `json:"thumbnail_url"`
 	ViewCount    int64  `json:"view_count"`
 	Status       string `json:"status"` // pending, downloading, completed, failed
+	Kind         string `json:"kind"`   // video, short, live, premiere
+	// Upcoming is set for scheduled premieres and streams, and for streams
+	// that are still live. Neither can be archived until they have finished.
+	Upcoming bool `json:"upcoming,omitempty"`
+}
+
+// Playlist represents a YouTube playlist with its metadata
+type Playlist struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	OwnerName    string `json:"owner_name"`
+	OwnerID      string `json:"owner_id"`
+	VideoCount   int    `json:"video_count"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// PlaylistItem is a single entry of a playlist. Playlists can span several
+// channels, so each item carries the channel that owns the video.
+type PlaylistItem struct {
+	Video
+	Position    int    `json:"position"`
+	ChannelID   string `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	Playable    bool   `json:"playable"`
 }
 
 // Format represents a video format option available for download
@@ -37,13 +62,54 @@ type Format struct {
 	Quality    string `json:"quality"`
 }
 
+// Availability is whether YouTube still plays a video, from the playability
+// status of its player response
+type Availability struct {
+	Playable bool   `json:"playable"`
+	Status   string `json:"status"`           // OK, UNPLAYABLE, LOGIN_REQUIRED, ERROR, ...
+	Reason   string `json:"reason,omitempty"` // e.g. "This video is private"
+}
+
 // VideoMetadata extends Video with additional detailed information
 type VideoMetadata struct {
 	Video
-	Formats    []Format `json:"formats"`
-	Subtitles  []string `json:"subtitles"`
-	Tags       []string `json:"tags"`
-	Categories []string `json:"categories"`
+	Formats    []Format      `json:"formats"`
+	Subtitles  []string      `json:"subtitles"`
+	Tags       []string      `json:"tags"`
+	Categories []string      `json:"categories"`
+	Chapters   []Chapter     `json:"chapters,omitempty"`
+	Captions   []CaptionInfo `json:"captions,omitempty"`
+}
+
+// Chapter is a titled section of a video. Times are in seconds.
+type Chapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+// Comment is a comment on a video. Replies have the ID of the comment they
+// answer as ParentID.
+type Comment struct {
+	ID              string `json:"id"`
+	ParentID        string `json:"parent_id,omitempty"`
+	AuthorName      string `json:"author_name"`
+	AuthorChannelID string `json:"author_channel_id,omitempty"`
+	Text            string `json:"text"`
+	LikeCount       int64  `json:"like_count"`
+	ReplyCount      int    `json:"reply_count"`
+	PublishedText   string `json:"published_text,omitempty"` // relative, e.g. "2 years ago"
+}
+
+// ChatMessage is a message from the live chat replay of a past livestream.
+// OffsetMs is the position in the video where it was shown.
+type ChatMessage struct {
+	ID              string `json:"id"`
+	AuthorName      string `json:"author_name"`
+	AuthorChannelID string `json:"author_channel_id,omitempty"`
+	Message         string `json:"message"`
+	OffsetMs        int64  `json:"offset_ms"`
+	Amount          string `json:"amount,omitempty"` // Super Chat amount, e.g. "$5.00"
 }
 
 // StreamInfo contains stream URLs and format information for downloading
@@ -137,3 +203,58 @@ const (
 	StatusCompleted   = "completed"
 	StatusFailed      = "failed"
 )
+
+// Video kinds. Each kind is listed on one channel tab.
+const (
+	KindVideo    = "video"
+	KindShort    = "short"
+	KindLive     = "live"
+	KindPremiere = "premiere"
+)
+
+// DefaultKinds are the kinds archived for channels without an explicit
+// setting. They match what the Videos tab has always returned.
+var DefaultKinds = []string{KindVideo, KindPremiere}
+
+// ChannelTab identifies a browsable tab on a channel page
+type ChannelTab string
+
+// Channel tabs that list archivable videos
+const (
+	TabVideos  ChannelTab = "videos"
+	TabShorts  ChannelTab = "shorts"
+	TabStreams ChannelTab = "streams"
+)
+
+// IsValidKind reports whether kind is a known video kind
+func IsValidKind(kind string) bool {
+	switch kind {
+	case KindVideo, KindShort, KindLive, KindPremiere:
+		return true
+	}
+	return false
+}
+
+// TabsForKinds returns the channel tabs that must be browsed to find the given kinds
+func TabsForKinds(kinds []string) []ChannelTab {
+	var tabs []ChannelTab
+	seen := make(map[ChannelTab]bool)
+	for _, kind := range kinds {
+		var tab ChannelTab
+		switch kind {
+		case KindVideo, KindPremiere:
+			tab = TabVideos
+		case KindShort:
+			tab = TabShorts
+		case KindLive:
+			tab = TabStreams
+		default:
+			continue
+		}
+		if !seen[tab] {
+			seen[tab] = true
+			tabs = append(tabs, tab)
+		}
+	}
+	return tabs
+}
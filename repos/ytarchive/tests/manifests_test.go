@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// TestManifestsParse decodes every document of the manifests under deploy/.
+// Kinds built into Kubernetes are decoded into their types, so a field at the
+// wrong level fails too; custom resources only have to be valid YAML.
+func TestManifestsParse(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "deploy", "*", "*.yaml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no manifests found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimPrefix(file, filepath.Join("..", "deploy")+string(filepath.Separator)), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+			for i := 0; ; i++ {
+				doc, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("document %d: %v", i, err)
+				}
+				var object map[string]interface{}
+				if err := yaml.Unmarshal(doc, &object); err != nil {
+					t.Fatalf("document %d: %v", i, err)
+				}
+				if object == nil {
+					continue // only comments
+				}
+				if object["apiVersion"] == nil || object["kind"] == nil {
+					t.Fatalf("document %d has no apiVersion or kind", i)
+				}
+
+				_, _, err = scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+				if err != nil && !runtime.IsNotRegisteredError(err) {
+					t.Errorf("document %d (%v): %v", i, object["kind"], err)
+				}
+			}
+		})
+	}
+}
@@ -23,6 +23,10 @@ async function request(endpoint, options = {}) {
     const response = await fetch(url, config);
 
     if (!response.ok) {
+      // The session expired or was never started; the app shows the sign-in screen
+      if (response.status === 401 && !endpoint.startsWith('/auth/')) {
+        window.dispatchEvent(new CustomEvent('auth-required'));
+      }
       let errorData = null;
       try {
         errorData = await response.json();
@@ -66,6 +70,54 @@ export async function addChannel(url) {
   });
 }
 
+// Update channel settings, e.g. { kinds: ['video', 'short', 'live', 'premiere'] }
+export async function updateChannel(id, settings) {
+  return request(`/channels/${id}`, {
+    method: 'PATCH',
+    body: JSON.stringify(settings)
+  });
+}
+
+// Get a channel's auto-sync schedule; resolves to null if it has none
+export async function getChannelSchedule(id) {
+  try {
+    return await request(`/channels/${id}/schedule`);
+  } catch (err) {
+    if (err.status === 404) return null;
+    throw err;
+  }
+}
+
+// Set a channel's auto-sync schedule: { cron: '0 */6 * * *' } or { interval_hours: 12 }
+export async function setChannelSchedule(id, schedule) {
+  return request(`/channels/${id}/schedule`, {
+    method: 'PUT',
+    body: JSON.stringify(schedule)
+  });
+}
+
+export async function deleteChannelSchedule(id) {
+  return request(`/channels/${id}/schedule`, {
+    method: 'DELETE'
+  });
+}
+
+// Apply a channel's retention policy; with dryRun only report what would be deleted.
+// A policy can be passed with dryRun to preview it before saving.
+export async function pruneChannel(id, { dryRun = false, policy = null } = {}) {
+  return request(`/channels/${id}/prune?dry_run=${dryRun}`, {
+    method: 'POST',
+    body: policy ? JSON.stringify(policy) : undefined
+  });
+}
+
+// Queue a channel's archived videos for the HLS renditions its policy prebuilds
+export async function prebuildChannelHLS(id) {
+  return request(`/channels/${id}/hls`, {
+    method: 'POST'
+  });
+}
+
 export async function deleteChannel(id) {
   return request(`/channels/${id}`, {
     method: 'DELETE'
@@ -78,21 +130,42 @@ export async function triggerSync(channelId) {
   });
 }
 
-// Search API (FTS5 full-text search)
+// Search API (FTS5 full-text search of titles, descriptions, chapters and transcripts)
 export async function searchVideos(query, params = {}) {
   const searchParams = new URLSearchParams();
   searchParams.set('q', query);
   if (params.channel) searchParams.set('channel', params.channel);
   if (params.status) searchParams.set('status', params.status);
+  if (params.kind) searchParams.set('kind', params.kind);
+  if (params.removed) searchParams.set('removed', params.removed);
+  if (params.from) searchParams.set('from', params.from);
+  if (params.to) searchParams.set('to', params.to);
+  if (params.duration) searchParams.set('duration', params.duration);
   if (params.limit) searchParams.set('limit', params.limit);
+  if (params.offset) searchParams.set('offset', params.offset);
 
   return request(`/search?${searchParams.toString()}`);
 }
 
+export async function searchTranscripts(query, params = {}) {
+  const searchParams = new URLSearchParams();
+  searchParams.set('q', query);
+  if (params.channel) searchParams.set('channel', params.channel);
+  if (params.video) searchParams.set('video', params.video);
+  if (params.lang) searchParams.set('lang', params.lang);
+  if (params.context !== undefined) searchParams.set('context', params.context);
+  if (params.limit) searchParams.set('limit', params.limit);
+  if (params.offset) searchParams.set('offset', params.offset);
+
+  return request(`/search/transcripts?${searchParams.toString()}`);
+}
+
 // Videos API
 export async function getChannelVideos(channelId, params = {}) {
   const searchParams = new URLSearchParams();
   if (params.status) searchParams.set('status', params.status);
+  if (params.kind) searchParams.set('kind', params.kind);
+  if (params.removed) searchParams.set('removed', params.removed);
   if (params.limit) searchParams.set('limit', params.limit);
   if (params.offset) searchParams.set('offset', params.offset);
 
@@ -104,6 +177,8 @@ export async function getAllVideos(params = {}) {
   const searchParams = new URLSearchParams();
   if (params.search) searchParams.set('search', params.search);
   if (params.status) searchParams.set('status', params.status);
+  if (params.kind) searchParams.set('kind', params.kind);
+  if (params.removed) searchParams.set('removed', params.removed);
   if (params.limit) searchParams.set('limit', params.limit);
   if (params.offset) searchParams.set('offset', params.offset);
 
@@ -115,6 +190,21 @@ export async function getVideo(id) {
   return request(`/videos/${id}`);
 }
 
+export async function getVideoHistory(id) {
+  return request(`/videos/${id}/history`);
+}
+
+export async function getVideoComments(id, params = {}) {
+  const searchParams = new URLSearchParams();
+  if (params.type) searchParams.set('type', params.type);
+  if (params.q) searchParams.set('q', params.q);
+  if (params.limit) searchParams.set('limit', params.limit);
+  if (params.offset) searchParams.set('offset', params.offset);
+
+  const query = searchParams.toString();
+  return request(`/videos/${id}/comments${query ? `?${query}` : ''}`);
+}
+
 export async function downloadVideo(id) {
   return request(`/videos/${id}/download`, {
     method: 'POST'
@@ -190,29 +280,64 @@ export function formatRelativeTime(dateString) {
   return date.toLocaleDateString();
 }
 
-// Cookies API
-export async function getCookies() {
-  return request('/cookies');
+// Auth API - the session is kept in an HTTP-only cookie set by login
+export async function login(username, password) {
+  return request('/auth/login', {
+    method: 'POST',
+    body: JSON.stringify({ username, password })
+  });
 }
 
-export async function saveCookies(cookies) {
-  return request('/cookies', {
-    method: 'POST',
+export async function logout() {
+  return request('/auth/logout', {
+    method: 'POST'
+  });
+}
+
+export async function getCurrentUser() {
+  return request('/auth/me');
+}
+
+// Credentials API - the YouTube cookie sets workers rotate between
+export async function getCredentials() {
+  return request('/credentials');
+}
+
+export async function saveCredential(name, cookies) {
+  return request(`/credentials/${encodeURIComponent(name)}`, {
+    method: 'PUT',
     body: JSON.stringify({ cookies })
   });
 }
 
-export async function deleteCookies() {
-  return request('/cookies', {
+export async function deleteCredential(name) {
+  return request(`/credentials/${encodeURIComponent(name)}`, {
     method: 'DELETE'
   });
 }
 
+export async function checkCredential(name) {
+  return request(`/credentials/${encodeURIComponent(name)}/check`, {
+    method: 'POST'
+  });
+}
+
+export async function restoreCredential(name) {
+  return request(`/credentials/${encodeURIComponent(name)}/restore`, {
+    method: 'POST'
+  });
+}
+
 // Video metadata and streaming
 export function getVideoStreamUrl(videoId) {
   return `${API_BASE}/videos/${videoId}/stream`;
 }
 
+// HLS master playlist; renditions are packaged by the collector on first play
+export function getVideoHLSUrl(videoId) {
+  return `${API_BASE}/videos/${videoId}/hls/master.m3u8`;
+}
+
 export function getVideoThumbnailUrl(videoId) {
   return `${API_BASE}/videos/${videoId}/thumbnail`;
 }
@@ -226,3 +351,21 @@ export async function getVideoFiles(videoId) {
 }
 
 export { ApiError };
+
+// Profiles API - the quality profiles channels can be assigned
+export async function getProfiles() {
+  return request('/profiles');
+}
+
+export async function saveProfile(name, profile) {
+  return request(`/profiles/${encodeURIComponent(name)}`, {
+    method: 'PUT',
+    body: JSON.stringify(profile)
+  });
+}
+
+export async function deleteProfile(name) {
+  return request(`/profiles/${encodeURIComponent(name)}`, {
+    method: 'DELETE'
+  });
+}